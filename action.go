@@ -66,9 +66,12 @@ var noKeyCmds = map[string]bool{
 	"WAIT":      true,
 	"SCAN":      true,
 
-	"EVAL":    true,
-	"EVALSHA": true,
-	"SCRIPT":  true,
+	"EVAL":     true,
+	"EVALSHA":  true,
+	"SCRIPT":   true,
+	"FCALL":    true,
+	"FCALL_RO": true,
+	"FUNCTION": true,
 
 	"BGREWRITEAOF": true,
 	"BGSAVE":       true,
@@ -81,6 +84,7 @@ var noKeyCmds = map[string]bool{
 	"FLUSHDB":      true,
 	"INFO":         true,
 	"LASTSAVE":     true,
+	"LATENCY":      true,
 	"MONITOR":      true,
 	"ROLE":         true,
 	"SAVE":         true,
@@ -139,6 +143,11 @@ type cmdAction struct {
 	flat     bool
 	flatKey  [1]string // use array to avoid allocation in Keys
 	flatArgs []interface{}
+
+	// explicitKeys is set by ModuleCmd, in which case keys is returned by Keys
+	// rather than it being inferred from the command.
+	explicitKeys bool
+	keys         []string
 }
 
 // BREAM: Benchmarks Rule Everything Around Me
@@ -176,11 +185,35 @@ func Cmd(rcv interface{}, cmd string, args ...string) CmdAction {
 // (generally) for MSET. Use Cmd for those.
 //
 // FlatCmd supports using a resp.LenReader (an io.Reader with a Len() method) as
-// an argument. *bytes.Buffer is an example of a LenReader, and the resp package
-// has a NewLenReader function which can wrap an existing io.Reader.
+// an argument, in which case the argument's contents are streamed onto the
+// connection rather than being read into memory first. The resp package has a
+// NewLenReader function which can wrap an existing io.Reader whose length is
+// known. Readers whose Len method returns an int, such as *bytes.Buffer,
+// *bytes.Reader, and *strings.Reader, are also supported. If the reader returns
+// fewer bytes than its length the command fails, and when using a Pool the
+// connection it was being written to is closed.
+//
+// FlatCmd also supports encoding.Text/BinaryMarshalers, so that types like
+// net.IP or UUIDs can be given as arguments directly, including as map keys and
+// struct fields. It does _not_ currently support resp.Marshaler.
+//
+// An argument may be given as a resp2.Any in order to control how its value is
+// flattened. For example, resp2.Any's TimeUnit field can be used to give
+// time.Durations and time.Times in the unit a command expects:
+//
+//	FlatCmd(nil, "PEXPIRE", key, resp2.Any{I: ttl, TimeUnit: time.Millisecond})
+//	FlatCmd(nil, "EXPIREAT", key, resp2.Any{I: t, TimeUnit: time.Second})
+//
+// Similarly, a resp2.Any may be used as the receiver of either Cmd or FlatCmd:
+//
+//	var ttl time.Duration
+//	Cmd(resp2.Any{I: &ttl, TimeUnit: time.Second}, "TTL", key)
 //
-// FlatCmd also supports encoding.Text/BinaryMarshalers. It does _not_ currently
-// support resp.Marshaler.
+// Note that in that case the -1 and -2 replies of TTL, for keys without an
+// expiry or which don't exist, become negative durations of one or two units.
+//
+// ZMember, ZMembers, and []ZMember arguments are flattened into score/member
+// pairs, as expected by ZADD.
 //
 // The receiver to FlatCmd follows the same rules as for Cmd.
 func FlatCmd(rcv interface{}, cmd, key string, args ...interface{}) CmdAction {
@@ -213,7 +246,9 @@ func findStreamsKeys(args []string) []string {
 }
 
 func (c *cmdAction) Keys() []string {
-	if c.flat {
+	if c.explicitKeys {
+		return c.keys
+	} else if c.flat {
 		return c.flatKey[:]
 	}
 
@@ -227,6 +262,11 @@ func (c *cmdAction) Keys() []string {
 		return c.args[1:2]
 	} else if cmd == "XGROUP" && len(c.args) > 1 {
 		return c.args[1:2]
+	} else if cmd == "MEMORY" {
+		if len(c.args) < 2 || strings.ToUpper(c.args[0]) != "USAGE" {
+			return nil
+		}
+		return c.args[1:2]
 	} else if cmd == "XREAD" || cmd == "XREADGROUP" { // antirez why you still do this
 		return findStreamsKeys(c.args)
 	} else if noKeyCmds[cmd] || len(c.args) == 0 {
@@ -236,20 +276,48 @@ func (c *cmdAction) Keys() []string {
 }
 
 func (c *cmdAction) flatMarshalRESP(w io.Writer) error {
-	var err error
-	a := resp2.Any{
-		I:                     c.flatArgs,
-		MarshalBulkString:     true,
-		MarshalNoArrayHeaders: true,
+	// each argument is marshaled individually, rather than wrapping the whole
+	// of flatArgs in a single Any, since converting the slice into an
+	// interface{} would cause it to be allocated on every call.
+	arrL := 2
+	for _, arg := range c.flatArgs {
+		arrL += flatArg(arg).NumElems()
 	}
-	arrL := 2 + a.NumElems()
-	err = resp2.ArrayHeader{N: arrL}.MarshalRESP(w)
+
+	err := resp2.ArrayHeader{N: arrL}.MarshalRESP(w)
 	err = marshalBulkString(err, w, c.cmd)
 	err = marshalBulkString(err, w, c.flatKey[0])
-	if err != nil {
-		return err
+	for _, arg := range c.flatArgs {
+		if err != nil {
+			return err
+		}
+		a := flatArg(arg)
+		a.MarshalBulkString = true
+		a.MarshalNoArrayHeaders = true
+		err = a.MarshalRESP(w)
 	}
-	return a.MarshalRESP(w)
+	return err
+}
+
+// flatArg wraps a FlatCmd argument in a resp2.Any, unless it already is one,
+// in which case it's used as-is so that its options (e.g. TimeUnit) apply.
+// ZMembers are converted into their flattened form.
+func flatArg(arg interface{}) resp2.Any {
+	switch at := arg.(type) {
+	case resp2.Any:
+		return at
+	case ZMember:
+		return resp2.Any{I: at.flatArgs()}
+	case []ZMember:
+		return flatArg(ZMembers(at))
+	case ZMembers:
+		args := make([]interface{}, 0, len(at)*2)
+		for _, zm := range at {
+			args = append(args, zm.flatArgs()...)
+		}
+		return resp2.Any{I: args}
+	}
+	return resp2.Any{I: arg}
 }
 
 func (c *cmdAction) MarshalRESP(w io.Writer) error {
@@ -274,17 +342,83 @@ func (c *cmdAction) UnmarshalRESP(br *bufio.Reader) error {
 }
 
 func (c *cmdAction) Run(conn Conn) error {
+	// blocking commands may legitimately wait longer than the read timeout for
+	// their response, so the read timeout is suspended for them.
+	if c.blocking() {
+		defer suspendReadTimeout(conn)()
+	}
+
 	if err := conn.Encode(c); err != nil {
 		return err
 	}
 	return conn.Decode(c)
 }
 
+// blocking returns true if the command is expected to block waiting on redis.
+func (c *cmdAction) blocking() bool {
+	switch cmd := strings.ToUpper(c.cmd); cmd {
+	case "XREAD", "XREADGROUP":
+		// these only block when given the BLOCK option, which must come before
+		// STREAMS, after which are the stream names and IDs
+		args := c.args
+		if c.flat {
+			args = []string{c.flatKey[0]}
+			for _, arg := range c.flatArgs {
+				if s, ok := arg.(string); ok {
+					args = append(args, s)
+				}
+			}
+		}
+		for _, arg := range args {
+			switch strings.ToUpper(arg) {
+			case "BLOCK":
+				return true
+			case "STREAMS":
+				return false
+			}
+		}
+		return false
+	case "SAVE":
+		// SAVE can take a while, but it doesn't wait on anything
+		return false
+	default:
+		return blockingCmds[cmd]
+	}
+}
+
 func (c *cmdAction) String() string {
-	return cmdString(c)
+	if !c.flat {
+		return cmdString(c)
+	}
+
+	// readers can only be read once, so rather than being marshaled they're
+	// described by their length
+	cc := *c
+	cc.flatArgs = make([]interface{}, len(c.flatArgs))
+	for i, arg := range c.flatArgs {
+		switch arg := arg.(type) {
+		case resp.LenReader:
+			cc.flatArgs[i] = fmt.Sprintf("<%d bytes>", arg.Len())
+		case interface {
+			io.Reader
+			Len() int
+		}:
+			cc.flatArgs[i] = fmt.Sprintf("<%d bytes>", arg.Len())
+		default:
+			cc.flatArgs[i] = arg
+		}
+	}
+	return cmdString(&cc)
 }
 
 func (c *cmdAction) ClusterCanRetry() bool {
+	// io.Reader arguments are consumed when the command is first written, so
+	// it can't be written again
+	for _, arg := range c.flatArgs {
+		if _, ok := arg.(io.Reader); ok {
+			return false
+		}
+	}
 	return true
 }
 
@@ -294,23 +428,9 @@ func (c *cmdAction) ClusterCanRetry() bool {
 // being received is a nil RESP type (either bulk string or array), and if so
 // set Nil to true. If not the return value will be unmarshaled into Rcv
 // normally.
-type MaybeNil struct {
-	Nil bool
-	Rcv interface{}
-}
-
-// UnmarshalRESP implements the method for the resp.Unmarshaler interface.
-func (mn *MaybeNil) UnmarshalRESP(br *bufio.Reader) error {
-	var rm resp2.RawMessage
-	if err := rm.UnmarshalRESP(br); err != nil {
-		return err
-	} else if rm.IsNil() {
-		mn.Nil = true
-		return nil
-	}
-
-	return rm.UnmarshalInto(resp2.Any{I: mn.Rcv})
-}
+//
+// MaybeNil is an alias of resp2.Maybe, see its documentation for details.
+type MaybeNil = resp2.Maybe
 
 ////////////////////////////////////////////////////////////////////////////////
 
@@ -397,7 +517,7 @@ func (ec *evalAction) Run(conn Conn) error {
 	}
 
 	err := run(false)
-	if err != nil && strings.HasPrefix(err.Error(), "NOSCRIPT") {
+	if xerrors.Is(err, resp2.ErrNoScript) {
 		err = run(true)
 	}
 	return err
@@ -497,6 +617,107 @@ func (p pipeline) MarshalRESP(w io.Writer) error {
 	return nil
 }
 
+// marshalsBatch implements the batchMarshaler interface, so that connWrap
+// writes all of the pipeline's commands using as few syscalls as possible.
+func (p pipeline) marshalsBatch() {}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// PipelineResult describes the outcome of a single CmdAction performed as part
+// of a PipelineWithResults.
+//
+// The CmdAction itself isn't retained, since CmdActions may be reused once
+// their reply has been decoded.
+type PipelineResult struct {
+	// Cmd is the upper-cased name of the command which was performed, or ""
+	// if it isn't known (see ActionCmdNames). If Err is nil then its reply
+	// will have been decoded into the CmdAction's receiver.
+	Cmd string
+
+	// Keys are the keys which the CmdAction was acting on.
+	Keys []string
+
+	// Err is the error which was encountered while performing Cmd, if any.
+	// This may be a resp2.Error if redis replied to Cmd with an error.
+	Err error
+}
+
+// newPipelineResults returns PipelineResults describing the given CmdActions,
+// copying all data out of them so it remains valid after they're performed.
+func newPipelineResults(cmds []CmdAction) PipelineResults {
+	res := make(PipelineResults, len(cmds))
+	for i, cmd := range cmds {
+		res[i].Keys = append([]string(nil), cmd.Keys()...)
+		if names := ActionCmdNames(cmd); len(names) == 1 {
+			res[i].Cmd = names[0]
+		}
+	}
+	return res
+}
+
+// PipelineResults describes the outcome of each CmdAction performed as part of
+// a PipelineWithResults, in the order the CmdActions were given.
+type PipelineResults []PipelineResult
+
+// Err returns the first non-nil error within the PipelineResults, if any.
+func (pr PipelineResults) Err() error {
+	for _, r := range pr {
+		if r.Err != nil {
+			return r.Err
+		}
+	}
+	return nil
+}
+
+type pipelineWithResults struct {
+	pipeline
+	res *PipelineResults
+}
+
+// PipelineWithResults is like Pipeline, except that an error decoding the
+// reply to one CmdAction doesn't prevent the replies to the following
+// CmdActions from being decoded. Instead, the outcome of every CmdAction is
+// written to res, so that each can be inspected individually.
+//
+// Errors which only affect a single CmdAction, such as an error reply from
+// redis or a reply which couldn't be unmarshaled into the CmdAction's receiver,
+// are only recorded in res, and Run will return nil. If the Conn itself
+// encounters an error, e.g. a network error, then that error is recorded for
+// the CmdAction being performed and all following ones, and is returned from
+// Run.
+func PipelineWithResults(res *PipelineResults, cmds ...CmdAction) Action {
+	return pipelineWithResults{pipeline: pipeline(cmds), res: res}
+}
+
+func (p pipelineWithResults) Run(c Conn) error {
+	res := newPipelineResults(p.pipeline)
+	*p.res = res
+
+	setErr := func(from int, err error) error {
+		for i := from; i < len(res); i++ {
+			res[i].Err = err
+		}
+		return err
+	}
+
+	if err := c.Encode(p.pipeline); err != nil {
+		return setErr(0, err)
+	}
+
+	for i, cmd := range p.pipeline {
+		err := c.Decode(cmd)
+		if err == nil {
+			continue
+		} else if xerrors.As(err, new(resp2.Error)) || xerrors.As(err, new(resp.ErrDiscarded)) {
+			// the reply was fully read, so the Conn can continue to be used
+			res[i].Err = err
+			continue
+		}
+		return setErr(i, decodeErr(cmd, err))
+	}
+	return nil
+}
+
 ////////////////////////////////////////////////////////////////////////////////
 
 type withConn struct {
@@ -524,3 +745,55 @@ func (wc *withConn) Keys() []string {
 func (wc *withConn) Run(c Conn) error {
 	return wc.fn(c)
 }
+
+////////////////////////////////////////////////////////////////////////////////
+
+type blockingAction struct {
+	Action
+}
+
+// Blocking wraps an Action which is expected to block for a long time waiting
+// on redis, such as a WithConn which performs a BLPOP or XREAD BLOCK. The read
+// timeout of the Conn the Action is performed on (see DialReadTimeout) is
+// suspended for the duration of the Action, and the Action counts against the
+// limit set by PoolMaxBlocking.
+//
+// Single blocking commands, like those created by Cmd(nil, "BLPOP", ...), are
+// treated this way automatically and don't need to be wrapped.
+func Blocking(a Action) Action {
+	return blockingAction{a}
+}
+
+func (ba blockingAction) Run(c Conn) error {
+	defer suspendReadTimeout(c)()
+	return ba.Action.Run(c)
+}
+
+func (ba blockingAction) ClusterCanRetry() bool {
+	ccra, ok := ba.Action.(ClusterCanRetryAction)
+	return ok && ccra.ClusterCanRetry()
+}
+
+// isBlockingAction returns true if the Action is expected to block waiting on
+// redis, see Blocking.
+func isBlockingAction(a Action) bool {
+	switch a := a.(type) {
+	case blockingAction, *waitAction:
+		return true
+	case *cmdAction:
+		return a.blocking()
+	default:
+		return false
+	}
+}
+
+// suspendReadTimeout suspends the read timeout of the given Conn, if it was
+// created by Dial, until the returned function is called.
+func suspendReadTimeout(conn Conn) func() {
+	tc, ok := conn.NetConn().(*timeoutConn)
+	if !ok {
+		return func() {}
+	}
+	tc.suspendReadTimeout(true)
+	return func() { tc.suspendReadTimeout(false) }
+}
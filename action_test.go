@@ -4,11 +4,18 @@ import (
 	"bufio"
 	"bytes"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"strings"
 	. "testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	errors "golang.org/x/xerrors"
 
+	"github.com/mediocregopher/radix/v3/resp"
 	"github.com/mediocregopher/radix/v3/resp/resp2"
 )
 
@@ -31,6 +38,28 @@ func TestCmdAction(t *T) {
 	assert.Equal(t, val, dstval)
 }
 
+func TestCmdActionRawMessage(t *T) {
+	c := dial()
+	defer c.Close()
+	key := randStr()
+
+	var rm resp2.RawMessage
+	require.Nil(t, c.Do(Cmd(&rm, "RPUSH", key, "a", "bc")))
+	assert.Equal(t, ":2\r\n", string(rm))
+
+	require.Nil(t, c.Do(Cmd(&rm, "LRANGE", key, "0", "-1")))
+	assert.Equal(t, "*2\r\n$1\r\na\r\n$2\r\nbc\r\n", string(rm))
+
+	// the reply can be decoded later on
+	var l []string
+	require.Nil(t, rm.UnmarshalInto(resp2.Any{I: &l}))
+	assert.Equal(t, []string{"a", "bc"}, l)
+
+	// error replies are captured rather than returned
+	require.Nil(t, c.Do(Cmd(&rm, "GET", key)))
+	assert.Equal(t, "-WRONGTYPE", string(rm[:10]))
+}
+
 func TestCmdActionStreams(t *T) {
 	c := dial()
 	key, val := randStr(), randStr()
@@ -116,6 +145,130 @@ func TestFlatCmdAction(t *T) {
 	assert.Equal(t, m, got)
 }
 
+func TestFlatCmdActionStruct(t *T) {
+	c := dial()
+	defer c.Close()
+
+	type user struct {
+		Name    string    `redis:"name"`
+		Age     int       `redis:"age,omitempty"`
+		Admin   bool      `redis:"admin"`
+		Score   float64   `redis:"score"`
+		Created time.Time `redis:"created,omitempty"`
+	}
+
+	key := randStr()
+	u := user{
+		Name:    "alice",
+		Admin:   true,
+		Score:   1.5,
+		Created: time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+	require.Nil(t, c.Do(FlatCmd(nil, "HSET", key, u)))
+
+	// Age was empty, so shouldn't have been set
+	var fields []string
+	require.Nil(t, c.Do(Cmd(&fields, "HKEYS", key)))
+	assert.ElementsMatch(t, []string{"name", "admin", "score", "created"}, fields)
+
+	var got user
+	require.Nil(t, c.Do(Cmd(&got, "HGETALL", key)))
+	assert.Equal(t, u, got)
+}
+
+func TestFlatCmdActionTextMarshaler(t *T) {
+	c := dial()
+	defer c.Close()
+
+	key := randStr()
+	ips := map[string]net.IP{
+		"a": net.ParseIP("10.0.0.1").To4(),
+		"b": net.ParseIP("::1"),
+	}
+	require.Nil(t, c.Do(FlatCmd(nil, "HSET", key, ips)))
+
+	var s string
+	require.Nil(t, c.Do(Cmd(&s, "HGET", key, "a")))
+	assert.Equal(t, "10.0.0.1", s)
+
+	var got map[string]net.IP
+	require.Nil(t, c.Do(Cmd(&got, "HGETALL", key)))
+	require.Len(t, got, len(ips))
+	for k, ip := range ips {
+		assert.True(t, ip.Equal(got[k]), "k:%q got:%v", k, got[k])
+	}
+}
+
+func TestFlatCmdActionJSON(t *T) {
+	c := dial()
+	defer c.Close()
+
+	type doc struct {
+		Name string
+		Tags []string
+	}
+	d := doc{Name: "foo", Tags: []string{"a", "b"}}
+
+	key := randStr()
+	require.Nil(t, c.Do(FlatCmd(nil, "SET", key, resp2.JSON{I: d})))
+	var s string
+	require.Nil(t, c.Do(Cmd(&s, "GET", key)))
+	assert.Equal(t, `{"Name":"foo","Tags":["a","b"]}`, s)
+
+	var got doc
+	require.Nil(t, c.Do(Cmd(resp2.JSON{I: &got}, "GET", key)))
+	assert.Equal(t, d, got)
+
+	type user struct {
+		Name  string            `redis:"name"`
+		Prefs map[string]string `redis:"prefs,json"`
+	}
+	u := user{Name: "alice", Prefs: map[string]string{"theme": "dark"}}
+	hashKey := randStr()
+	require.Nil(t, c.Do(FlatCmd(nil, "HSET", hashKey, u)))
+
+	var gotU user
+	require.Nil(t, c.Do(Cmd(&gotU, "HGETALL", hashKey)))
+	assert.Equal(t, u, gotU)
+}
+
+func TestFlatCmdActionTime(t *T) {
+	c := dial()
+	defer c.Close()
+
+	key := randStr()
+	require.Nil(t, c.Do(Cmd(nil, "SET", key, "foo")))
+
+	var ttl time.Duration
+	require.Nil(t, c.Do(Cmd(resp2.Any{I: &ttl, TimeUnit: time.Second}, "TTL", key)))
+	assert.Equal(t, -1*time.Second, ttl)
+
+	require.Nil(t, c.Do(FlatCmd(nil, "EXPIRE", key, resp2.Any{I: time.Hour, TimeUnit: time.Second})))
+	require.Nil(t, c.Do(Cmd(resp2.Any{I: &ttl, TimeUnit: time.Second}, "TTL", key)))
+	assert.Equal(t, time.Hour, ttl)
+
+	require.Nil(t, c.Do(FlatCmd(nil, "PEXPIRE", key, resp2.Any{I: 90 * time.Second, TimeUnit: time.Millisecond})))
+	require.Nil(t, c.Do(Cmd(resp2.Any{I: &ttl, TimeUnit: time.Millisecond}, "PTTL", key)))
+	assert.Equal(t, 90*time.Second, ttl)
+
+	type event struct {
+		Name string    `redis:"name"`
+		At   time.Time `redis:"at"`
+	}
+	hashKey := randStr()
+	e := event{Name: "deploy", At: time.Unix(1577934245, 0)}
+	require.Nil(t, c.Do(FlatCmd(nil, "HSET", hashKey, resp2.Any{I: e, TimeUnit: time.Second})))
+
+	var at string
+	require.Nil(t, c.Do(Cmd(&at, "HGET", hashKey, "at")))
+	assert.Equal(t, "1577934245", at)
+
+	var got event
+	require.Nil(t, c.Do(Cmd(resp2.Any{I: &got, TimeUnit: time.Second}, "HGETALL", hashKey)))
+	assert.Equal(t, e.Name, got.Name)
+	assert.True(t, e.At.Equal(got.At))
+}
+
 func TestFlatCmdActionNil(t *T) {
 	c := dial()
 	defer c.Close()
@@ -130,6 +283,33 @@ func TestFlatCmdActionNil(t *T) {
 	require.False(t, nilVal.Nil)
 }
 
+func TestFlatCmdActionReader(t *T) {
+	pool := testPool(1)
+	defer pool.Close()
+
+	key := randStr()
+	val := strings.Repeat("a", 1<<20)
+
+	// String shouldn't consume the reader
+	a := FlatCmd(nil, "SET", key, strings.NewReader(val))
+	assert.Equal(t, fmt.Sprintf(`["SET" %q "<%d bytes>"]`, key, len(val)), fmt.Sprint(a))
+	require.Nil(t, pool.Do(a))
+
+	var got string
+	require.Nil(t, pool.Do(Cmd(&got, "GET", key)))
+	assert.True(t, got == val, "got %d bytes back, expected %d", len(got), len(val))
+
+	// a reader which ends early will have left part of the command written to
+	// the connection, so the Pool mustn't reuse it
+	short := resp.NewLenReader(strings.NewReader(val), int64(len(val)+1))
+	err := pool.Do(FlatCmd(nil, "SET", key, short))
+	assert.True(t, errors.Is(err, io.ErrUnexpectedEOF), "err: %v", err)
+
+	var echo string
+	require.Nil(t, pool.Do(Cmd(&echo, "ECHO", "foo")))
+	assert.Equal(t, "foo", echo)
+}
+
 func ExampleFlatCmd() {
 	client, err := NewPool("tcp", "127.0.0.1:6379", 10) // or any other client
 	if err != nil {
@@ -258,6 +438,49 @@ func TestPipelineAction(t *T) {
 	})
 }
 
+func TestPipelineWithResultsAction(t *T) {
+	c := dial()
+	defer c.Close()
+
+	k1, k2, v := randStr(), randStr(), randStr()
+	require.Nil(t, c.Do(Cmd(nil, "SET", k1, v)))
+	require.Nil(t, c.Do(Cmd(nil, "HSET", k2, "foo", "bar")))
+
+	var intRcv int
+	var strRcv1, strRcv2 string
+	var res PipelineResults
+	require.Nil(t, c.Do(PipelineWithResults(&res,
+		Cmd(&intRcv, "GET", k1),
+		Cmd(&strRcv1, "GET", k2),
+		Cmd(&strRcv2, "GET", k1),
+	)))
+
+	require.Len(t, res, 3)
+	assert.Error(t, res[0].Err)
+	assert.True(t, errors.As(res[0].Err, new(resp.ErrDiscarded)))
+	assert.True(t, errors.As(res[1].Err, new(resp2.Error)))
+	assert.Nil(t, res[2].Err)
+	assert.Equal(t, v, strRcv2)
+	assert.Equal(t, res[0].Err, res.Err())
+	for i, key := range []string{k1, k2, k1} {
+		assert.Equal(t, "GET", res[i].Cmd)
+		assert.Equal(t, []string{key}, res[i].Keys)
+	}
+
+	// the successfully performed CmdActions may be reused by new ones, which
+	// mustn't affect the results
+	for i := 0; i < 10; i++ {
+		require.Nil(t, c.Do(Cmd(nil, "SET", randStr(), v)))
+	}
+	assert.Equal(t, "GET", res[2].Cmd)
+	assert.Equal(t, []string{k1}, res[2].Keys)
+
+	// the Conn should still be usable
+	var out string
+	require.Nil(t, c.Do(Cmd(&out, "ECHO", v)))
+	assert.Equal(t, v, out)
+}
+
 func ExamplePipeline() {
 	client, err := NewPool("tcp", "127.0.0.1:6379", 10) // or any other client
 	if err != nil {
@@ -442,3 +665,62 @@ func BenchmarkWithConnKeys(b *B) {
 		benchCmdActionKeys = WithConn("a", func(Conn) error { return nil }).Keys()
 	}
 }
+
+// benchCmdActionRoundTrip marshals the CmdAction returned by mkCmd and then
+// unmarshals an OK reply into it, which is what happens to every CmdAction
+// performed on a Conn.
+func benchCmdActionRoundTrip(b *B, mkCmd func() CmdAction) {
+	bw := bufio.NewWriter(ioutil.Discard)
+	var sr strings.Reader
+	br := bufio.NewReader(&sr)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		cmd := mkCmd()
+		if err := cmd.MarshalRESP(bw); err != nil {
+			b.Fatal(err)
+		}
+		sr.Reset("+OK\r\n")
+		br.Reset(&sr)
+		if err := cmd.UnmarshalRESP(br); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkCmdActionRoundTrip(b *B) {
+	args := []string{"foo", "bar", "EX", "10"}
+	benchCmdActionRoundTrip(b, func() CmdAction {
+		return Cmd(nil, "SET", args...)
+	})
+}
+
+func BenchmarkFlatCmdActionRoundTrip(b *B) {
+	args := []interface{}{"a", 1, "b", 2.5, []byte("c"), "d"}
+	benchCmdActionRoundTrip(b, func() CmdAction {
+		return FlatCmd(nil, "HSET", "foo", args...)
+	})
+}
+
+func TestIsBlockingAction(t *T) {
+	for _, test := range []struct {
+		a   Action
+		exp bool
+	}{
+		{Cmd(nil, "GET", "foo"), false},
+		{Cmd(nil, "blpop", "foo", "0"), true},
+		{Cmd(nil, "BLMOVE", "foo", "bar", "LEFT", "RIGHT", "0"), true},
+		{Cmd(nil, "XREAD", "COUNT", "1", "STREAMS", "foo", "0"), false},
+		{Cmd(nil, "XREAD", "COUNT", "1", "BLOCK", "0", "STREAMS", "foo", "0"), true},
+		{Cmd(nil, "XREADGROUP", "GROUP", "g", "c", "STREAMS", "foo", ">"), false},
+		{Cmd(nil, "xreadgroup", "GROUP", "g", "c", "block", "0", "STREAMS", "foo", ">"), true},
+		// a stream named BLOCK doesn't make XREAD blocking
+		{Cmd(nil, "XREAD", "STREAMS", "BLOCK", "0"), false},
+		{FlatCmd(nil, "XREAD", "BLOCK", 0, "STREAMS", "foo", "0"), true},
+		{Cmd(nil, "SAVE"), false},
+		{Blocking(Cmd(nil, "GET", "foo")), true},
+	} {
+		assert.Equal(t, test.exp, isBlockingAction(test.a), "action:%v", test.a)
+	}
+}
@@ -0,0 +1,146 @@
+package radix
+
+import (
+	"net"
+	"sync"
+
+	"github.com/mediocregopher/radix/v3/resp"
+)
+
+// batchMarshaler is implemented by resp.Marshalers which are made up of many
+// messages, e.g. a pipeline. connWrap encodes these using a batchWriter, when
+// its net.Conn supports it.
+type batchMarshaler interface {
+	resp.Marshaler
+	marshalsBatch()
+}
+
+const (
+	// batchChunkSize is the size of the chunks which a batchWriter buffers
+	// messages in. Writes at least this large aren't buffered at all.
+	batchChunkSize = 4096
+
+	// batchFlushSize is the number of bytes a batchWriter buffers before
+	// writing them to the net.Conn, so that large batches aren't buffered in
+	// their entirety.
+	batchFlushSize = 64 * 1024
+)
+
+var batchChunkPool = sync.Pool{
+	New: func() interface{} {
+		b := make([]byte, 0, batchChunkSize)
+		return &b
+	},
+}
+
+// batchWriter is the io.Writer which a connWrap marshals batchMarshalers into.
+// The marshaled messages are buffered in pooled chunks, and are then written
+// to the net.Conn together as net.Buffers, which is done using a single writev
+// on TCP and unix connections. Compared to the bufio.Writer, which writes each
+// time its buffer fills up, this takes far fewer syscalls to write a large
+// batch of commands.
+type batchWriter struct {
+	w      *connWriter
+	chunks []*[]byte
+	bufs   net.Buffers
+	n      int
+}
+
+func (bw *batchWriter) Write(b []byte) (int, error) {
+	if len(b) >= batchChunkSize {
+		// large writes, e.g. of big values, are written directly along with
+		// whatever is buffered, rather than being copied
+		if err := bw.flush(b); err != nil {
+			return 0, err
+		}
+		return len(b), nil
+	}
+
+	for rem := b; len(rem) > 0; {
+		var chunk *[]byte
+		if l := len(bw.chunks); l > 0 && len(*bw.chunks[l-1]) < batchChunkSize {
+			chunk = bw.chunks[l-1]
+		} else {
+			chunk = batchChunkPool.Get().(*[]byte)
+			bw.chunks = append(bw.chunks, chunk)
+		}
+
+		n := copy((*chunk)[len(*chunk):batchChunkSize], rem)
+		*chunk, rem = (*chunk)[:len(*chunk)+n], rem[n:]
+	}
+
+	if bw.n += len(b); bw.n >= batchFlushSize {
+		if err := bw.flush(nil); err != nil {
+			return 0, err
+		}
+	}
+	return len(b), nil
+}
+
+// flush writes all buffered chunks, followed by extra if it's given, to the
+// net.Conn.
+func (bw *batchWriter) flush(extra []byte) error {
+	defer bw.reset()
+	for _, chunk := range bw.chunks {
+		bw.bufs = append(bw.bufs, *chunk)
+	}
+	if len(extra) > 0 {
+		bw.bufs = append(bw.bufs, extra)
+	}
+	if len(bw.bufs) == 0 {
+		return nil
+	}
+
+	// WriteTo consumes the net.Buffers it's called on, so a copy is used in
+	// order for bw.bufs to be reusable.
+	bufs := bw.bufs
+	_, err := bw.w.writeBuffers(&bufs)
+	return err
+}
+
+// reset discards all buffered chunks, returning them to the pool.
+func (bw *batchWriter) reset() {
+	for i, chunk := range bw.chunks {
+		*chunk = (*chunk)[:0]
+		batchChunkPool.Put(chunk)
+		bw.chunks[i] = nil
+	}
+	for i := range bw.bufs {
+		bw.bufs[i] = nil
+	}
+	bw.chunks, bw.bufs, bw.n = bw.chunks[:0], bw.bufs[:0], 0
+}
+
+// buffersWriter is implemented by the net.Conn wrappers of this package, so
+// that net.Buffers can be passed through them to the net.Conn they wrap.
+type buffersWriter interface {
+	writeBuffers(*net.Buffers) (int64, error)
+}
+
+// writeBuffers writes the net.Buffers to the net.Conn, using a single writev if
+// the net.Conn supports it (see canWritev) and writing each buffer separately
+// otherwise.
+func writeBuffers(conn net.Conn, bufs *net.Buffers) (int64, error) {
+	if bw, ok := conn.(buffersWriter); ok {
+		return bw.writeBuffers(bufs)
+	}
+	return bufs.WriteTo(conn)
+}
+
+// canWritev returns whether net.Buffers passed to writeBuffers for the given
+// net.Conn are written using writev, in which case it's worth using a
+// batchWriter for it.
+func canWritev(conn net.Conn) bool {
+	for {
+		switch c := conn.(type) {
+		case *net.TCPConn, *net.UnixConn:
+			return true
+		case *timeoutConn:
+			conn = c.Conn
+		case *countingConn:
+			conn = c.Conn
+		default:
+			return false
+		}
+	}
+}
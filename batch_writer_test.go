@@ -0,0 +1,103 @@
+package radix
+
+import (
+	"bytes"
+	"net"
+	"strings"
+	. "testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type writesConn struct {
+	net.Conn
+	writes [][]byte
+}
+
+func (wc *writesConn) Write(b []byte) (int, error) {
+	wc.writes = append(wc.writes, append([]byte(nil), b...))
+	return len(b), nil
+}
+
+func TestBatchWriter(t *T) {
+	wc := new(writesConn)
+	bw := batchWriter{w: &connWriter{Conn: wc}}
+	var exp bytes.Buffer
+	write := func(s string) {
+		exp.WriteString(s)
+		_, err := bw.Write([]byte(s))
+		require.NoError(t, err)
+	}
+	written := func() string {
+		return string(bytes.Join(wc.writes, nil))
+	}
+
+	// small writes are buffered in chunks until flushed
+	for i := 0; i < 1000; i++ {
+		write("$5\r\nhello\r\n")
+	}
+	assert.Empty(t, wc.writes)
+	require.NoError(t, bw.flush(nil))
+	assert.Len(t, wc.writes, (exp.Len()+batchChunkSize-1)/batchChunkSize)
+	assert.Equal(t, exp.String(), written())
+	assert.True(t, bw.w.wrote)
+
+	// a large write is written immediately, after whatever is buffered
+	write("$3\r\nfoo\r\n")
+	write(strings.Repeat("a", batchChunkSize))
+	assert.Equal(t, exp.String(), written())
+	require.NoError(t, bw.flush(nil))
+	assert.Equal(t, exp.String(), written())
+
+	// buffered writes are flushed once there's enough of them
+	wc.writes = nil
+	for i := 0; i < batchFlushSize/8; i++ {
+		write("01234567")
+	}
+	assert.NotEmpty(t, wc.writes)
+	require.NoError(t, bw.flush(nil))
+	assert.Equal(t, exp.String()[exp.Len()-batchFlushSize:], written())
+}
+
+func TestCanWritev(t *T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+
+	tcpConn, err := net.Dial("tcp", l.Addr().String())
+	require.NoError(t, err)
+	defer tcpConn.Close()
+	assert.True(t, canWritev(tcpConn))
+	assert.True(t, canWritev(&timeoutConn{Conn: &countingConn{Conn: tcpConn}}))
+
+	pipeConn, _ := net.Pipe()
+	assert.False(t, canWritev(pipeConn))
+	assert.False(t, canWritev(&timeoutConn{Conn: pipeConn}))
+}
+
+func TestPipelineBatchWriter(t *T) {
+	c := dial()
+	defer c.Close()
+	require.True(t, c.(*connWrap).writev)
+
+	// a mix of small and large values which add up to more than a single
+	// flush of the batchWriter
+	keys := make([]string, 200)
+	vals := make([]string, len(keys))
+	cmds := make([]CmdAction, 0, len(keys))
+	for i := range keys {
+		keys[i] = randStr()
+		vals[i] = strings.Repeat(randStr(), 1+(i%10)*(i%10)*5)
+		cmds = append(cmds, Cmd(nil, "SET", keys[i], vals[i]))
+	}
+	require.NoError(t, c.Do(Pipeline(cmds...)))
+
+	got := make([]string, len(keys))
+	cmds = cmds[:0]
+	for i := range keys {
+		cmds = append(cmds, Cmd(&got[i], "GET", keys[i]))
+	}
+	require.NoError(t, c.Do(Pipeline(cmds...)))
+	assert.Equal(t, vals, got)
+}
@@ -0,0 +1,288 @@
+package radix
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	errors "golang.org/x/xerrors"
+
+	"github.com/mediocregopher/radix/v3/resp/resp2"
+)
+
+// ErrBreakerOpen is returned by a CircuitBreaker when an Action isn't performed
+// because the CircuitBreaker is open, or is half-open and already performing
+// as many trial Actions as it allows.
+var ErrBreakerOpen = errors.New("circuit breaker is open")
+
+// BreakerState describes the state of a CircuitBreaker.
+type BreakerState int
+
+// All possible BreakerState values.
+const (
+	// BreakerClosed is the normal state of a CircuitBreaker, in which all
+	// Actions are performed.
+	BreakerClosed BreakerState = iota
+
+	// BreakerOpen is the state of a CircuitBreaker which has tripped, in which
+	// no Actions are performed.
+	BreakerOpen
+
+	// BreakerHalfOpen is the state of a CircuitBreaker which has been open for
+	// BreakerOpts.OpenTimeout, in which a limited number of trial Actions are
+	// performed to determine whether it should close again.
+	BreakerHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	default:
+		return fmt.Sprintf("BreakerState(%d)", int(s))
+	}
+}
+
+// BreakerOpts are options which can be passed into Breaker.
+type BreakerOpts struct {
+	// ConsecutiveFailures is the number of consecutive failed Actions after
+	// which the CircuitBreaker trips.
+	//
+	// The default, if ConsecutiveFailures is 0, is 5. If it's negative then
+	// the CircuitBreaker doesn't trip due to consecutive failures.
+	ConsecutiveFailures int
+
+	// FailureRate, if greater than 0, is the fraction of Actions (between 0 and
+	// 1) which must fail within a Window for the CircuitBreaker to trip, as
+	// long as at least MinRequests Actions were performed within it.
+	FailureRate float64
+
+	// Window is the duration over which FailureRate is measured. Windows are
+	// consecutive, i.e. the counts of Actions are reset at the start of each.
+	//
+	// The default, if Window is 0, is 10 seconds.
+	Window time.Duration
+
+	// MinRequests is the number of Actions which must be performed within a
+	// Window before FailureRate is considered.
+	//
+	// The default, if MinRequests is 0, is 20.
+	MinRequests int
+
+	// OpenTimeout is how long the CircuitBreaker stays open after tripping,
+	// before becoming half-open.
+	//
+	// The default, if OpenTimeout is 0, is 5 seconds.
+	OpenTimeout time.Duration
+
+	// HalfOpenRequests is the number of trial Actions which are performed
+	// while the CircuitBreaker is half-open. If all of them succeed then the
+	// CircuitBreaker closes, if any fail then it opens again.
+	//
+	// The default, if HalfOpenRequests is 0, is 1.
+	HalfOpenRequests int
+
+	// IsFailure determines whether an error returned from an Action counts as
+	// a failure.
+	//
+	// The default, if IsFailure is nil, is IsBreakerFailure.
+	IsFailure func(error) bool
+
+	// OnStateChange, if set, is called synchronously each time the state of
+	// the CircuitBreaker changes.
+	OnStateChange func(from, to BreakerState)
+}
+
+// IsBreakerFailure is the default BreakerOpts.IsFailure. It returns true for
+// all errors except canceled contexts, and errors returned by redis other than
+// those indicating that redis is unable to serve requests (LOADING, BUSY,
+// MASTERDOWN, and CLUSTERDOWN).
+func IsBreakerFailure(err error) bool {
+	var respErr resp2.Error
+	switch {
+	case err == nil, errors.Is(err, context.Canceled):
+		return false
+	case errors.As(err, &respErr):
+		return errors.Is(err, resp2.ErrLoading) ||
+			errors.Is(err, resp2.ErrBusy) ||
+			errors.Is(err, resp2.ErrorKind("MASTERDOWN")) ||
+			errors.Is(err, resp2.ErrClusterDown)
+	default:
+		return true
+	}
+}
+
+// CircuitBreaker is a Client which wraps another Client, and stops performing
+// Actions on it for a time once too many of them have failed, returning
+// ErrBreakerOpen instead. This avoids hammering a redis instance which is down,
+// and bounds the latency of Actions while it is.
+//
+// See BreakerOpts for details on when a CircuitBreaker trips, and how it
+// recovers.
+type CircuitBreaker struct {
+	Client
+	opts BreakerOpts
+
+	l     sync.Mutex
+	state BreakerState
+
+	// generation is incremented every time state changes, so that the results
+	// of Actions which started in a previous state can be ignored.
+	generation uint64
+
+	consecutiveFailures int
+	windowStart         time.Time
+	windowTotal         int
+	windowFailures      int
+
+	openedAt          time.Time
+	halfOpenInFlight  int
+	halfOpenSuccesses int
+}
+
+var _ ContextClient = new(CircuitBreaker)
+
+// Breaker returns a CircuitBreaker which performs Actions using the given
+// Client.
+func Breaker(c Client, opts BreakerOpts) *CircuitBreaker {
+	if opts.ConsecutiveFailures == 0 {
+		opts.ConsecutiveFailures = 5
+	}
+	if opts.Window == 0 {
+		opts.Window = 10 * time.Second
+	}
+	if opts.MinRequests == 0 {
+		opts.MinRequests = 20
+	}
+	if opts.OpenTimeout == 0 {
+		opts.OpenTimeout = 5 * time.Second
+	}
+	if opts.HalfOpenRequests == 0 {
+		opts.HalfOpenRequests = 1
+	}
+	if opts.IsFailure == nil {
+		opts.IsFailure = IsBreakerFailure
+	}
+	return &CircuitBreaker{Client: c, opts: opts, windowStart: time.Now()}
+}
+
+// State returns the current state of the CircuitBreaker.
+func (cb *CircuitBreaker) State() BreakerState {
+	cb.l.Lock()
+	onChange := cb.checkOpenTimeout(time.Now())
+	state := cb.state
+	cb.l.Unlock()
+	onChange()
+	return state
+}
+
+// l must be held. The returned function must be called after unlocking l.
+func (cb *CircuitBreaker) setState(state BreakerState, now time.Time) func() {
+	prev := cb.state
+	cb.state = state
+	cb.generation++
+	cb.consecutiveFailures = 0
+	cb.windowStart, cb.windowTotal, cb.windowFailures = now, 0, 0
+	cb.halfOpenInFlight, cb.halfOpenSuccesses = 0, 0
+	if state == BreakerOpen {
+		cb.openedAt = now
+	}
+
+	if cb.opts.OnStateChange == nil {
+		return func() {}
+	}
+	return func() { cb.opts.OnStateChange(prev, state) }
+}
+
+// checkOpenTimeout transitions an open CircuitBreaker to half-open once
+// OpenTimeout has passed. l must be held.
+func (cb *CircuitBreaker) checkOpenTimeout(now time.Time) func() {
+	if cb.state == BreakerOpen && now.Sub(cb.openedAt) >= cb.opts.OpenTimeout {
+		return cb.setState(BreakerHalfOpen, now)
+	}
+	return func() {}
+}
+
+// allow returns whether an Action may be performed, and the generation it was
+// allowed in.
+func (cb *CircuitBreaker) allow() (uint64, bool) {
+	cb.l.Lock()
+	onChange := cb.checkOpenTimeout(time.Now())
+	defer onChange()
+	defer cb.l.Unlock()
+
+	switch cb.state {
+	case BreakerOpen:
+		return 0, false
+	case BreakerHalfOpen:
+		if cb.halfOpenInFlight >= cb.opts.HalfOpenRequests {
+			return 0, false
+		}
+		cb.halfOpenInFlight++
+	}
+	return cb.generation, true
+}
+
+func (cb *CircuitBreaker) done(generation uint64, failed bool) {
+	now := time.Now()
+	onChange := func() {}
+	defer func() { onChange() }()
+	cb.l.Lock()
+	defer cb.l.Unlock()
+
+	if generation != cb.generation {
+		return
+	}
+
+	switch cb.state {
+	case BreakerHalfOpen:
+		if failed {
+			onChange = cb.setState(BreakerOpen, now)
+		} else if cb.halfOpenSuccesses++; cb.halfOpenSuccesses >= cb.opts.HalfOpenRequests {
+			onChange = cb.setState(BreakerClosed, now)
+		}
+
+	case BreakerClosed:
+		if now.Sub(cb.windowStart) >= cb.opts.Window {
+			cb.windowStart, cb.windowTotal, cb.windowFailures = now, 0, 0
+		}
+		cb.windowTotal++
+		if !failed {
+			cb.consecutiveFailures = 0
+			return
+		}
+		cb.consecutiveFailures++
+		cb.windowFailures++
+
+		if cb.opts.ConsecutiveFailures > 0 && cb.consecutiveFailures >= cb.opts.ConsecutiveFailures {
+			onChange = cb.setState(BreakerOpen, now)
+		} else if cb.opts.FailureRate > 0 && cb.windowTotal >= cb.opts.MinRequests &&
+			float64(cb.windowFailures)/float64(cb.windowTotal) >= cb.opts.FailureRate {
+			onChange = cb.setState(BreakerOpen, now)
+		}
+	}
+}
+
+// Do implements the method for the Client interface, returning ErrBreakerOpen
+// if the Action wasn't performed due to the CircuitBreaker being open.
+func (cb *CircuitBreaker) Do(a Action) error {
+	return cb.DoContext(context.Background(), a)
+}
+
+// DoContext implements the method for the ContextClient interface, returning
+// ErrBreakerOpen if the Action wasn't performed due to the CircuitBreaker being
+// open.
+func (cb *CircuitBreaker) DoContext(ctx context.Context, a Action) error {
+	generation, ok := cb.allow()
+	if !ok {
+		return ErrBreakerOpen
+	}
+	err := DoContext(ctx, cb.Client, a)
+	cb.done(generation, err != nil && cb.opts.IsFailure(err))
+	return err
+}
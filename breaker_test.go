@@ -0,0 +1,137 @@
+package radix
+
+import (
+	"context"
+	. "testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	errors "golang.org/x/xerrors"
+
+	"github.com/mediocregopher/radix/v3/resp/resp2"
+)
+
+// errClient is a Client whose Do returns the current value of err, after
+// blocking on waitCh if it's set.
+type errClient struct {
+	err    error
+	waitCh chan struct{}
+}
+
+func (ec *errClient) Do(Action) error {
+	if ec.waitCh != nil {
+		<-ec.waitCh
+	}
+	return ec.err
+}
+
+func (ec *errClient) Close() error { return nil }
+
+func TestCircuitBreaker(t *T) {
+	errDown := errors.New("connection refused")
+	noop := Cmd(nil, "PING")
+
+	t.Run("ConsecutiveFailures", func(t *T) {
+		var changes []BreakerState
+		ec := new(errClient)
+		cb := Breaker(ec, BreakerOpts{
+			ConsecutiveFailures: 3,
+			OpenTimeout:         50 * time.Millisecond,
+			HalfOpenRequests:    2,
+			OnStateChange: func(from, to BreakerState) {
+				changes = append(changes, to)
+			},
+		})
+
+		// redis errors and successes reset the count
+		ec.err = errDown
+		assert.Equal(t, errDown, cb.Do(noop))
+		assert.Equal(t, errDown, cb.Do(noop))
+		ec.err = resp2.Error{E: errors.New("WRONGTYPE nope")}
+		cb.Do(noop)
+		ec.err = errDown
+		assert.Equal(t, errDown, cb.Do(noop))
+		assert.Equal(t, errDown, cb.Do(noop))
+		assert.Equal(t, BreakerClosed, cb.State())
+
+		assert.Equal(t, errDown, cb.Do(noop))
+		assert.Equal(t, BreakerOpen, cb.State())
+		assert.Equal(t, ErrBreakerOpen, cb.Do(noop))
+
+		// once half-open a failed trial opens it again
+		time.Sleep(50 * time.Millisecond)
+		assert.Equal(t, BreakerHalfOpen, cb.State())
+		assert.Equal(t, errDown, cb.Do(noop))
+		assert.Equal(t, BreakerOpen, cb.State())
+
+		// all trials must succeed to close it
+		ec.err = nil
+		time.Sleep(50 * time.Millisecond)
+		assert.NoError(t, cb.Do(noop))
+		assert.Equal(t, BreakerHalfOpen, cb.State())
+		assert.NoError(t, cb.Do(noop))
+		assert.Equal(t, BreakerClosed, cb.State())
+
+		assert.Equal(t, []BreakerState{
+			BreakerOpen, BreakerHalfOpen, BreakerOpen, BreakerHalfOpen, BreakerClosed,
+		}, changes)
+	})
+
+	t.Run("FailureRate", func(t *T) {
+		ec := new(errClient)
+		cb := Breaker(ec, BreakerOpts{
+			ConsecutiveFailures: -1,
+			FailureRate:         0.5,
+			MinRequests:         10,
+			Window:              time.Hour,
+		})
+
+		for i := 0; i < 9; i++ {
+			if ec.err = nil; i%2 == 0 {
+				ec.err = errDown
+			}
+			cb.Do(noop)
+		}
+		// 5/9 failed, but MinRequests hasn't been reached
+		assert.Equal(t, BreakerClosed, cb.State())
+
+		cb.Do(noop)
+		assert.Equal(t, BreakerOpen, cb.State())
+	})
+
+	t.Run("HalfOpenLimit", func(t *T) {
+		ec := &errClient{err: errDown}
+		cb := Breaker(ec, BreakerOpts{ConsecutiveFailures: 1, OpenTimeout: time.Millisecond})
+		cb.Do(noop)
+		time.Sleep(time.Millisecond)
+
+		// while the trial is in progress other Actions aren't performed
+		ec.err, ec.waitCh = nil, make(chan struct{})
+		doneCh := make(chan error)
+		go func() { doneCh <- cb.Do(noop) }()
+		for {
+			cb.l.Lock()
+			inFlight := cb.halfOpenInFlight
+			cb.l.Unlock()
+			if inFlight > 0 {
+				break
+			}
+			time.Sleep(time.Millisecond)
+		}
+		assert.Equal(t, ErrBreakerOpen, cb.Do(noop))
+
+		close(ec.waitCh)
+		assert.NoError(t, <-doneCh)
+		assert.Equal(t, BreakerClosed, cb.State())
+	})
+}
+
+func TestIsBreakerFailure(t *T) {
+	assert.False(t, IsBreakerFailure(nil))
+	assert.False(t, IsBreakerFailure(context.Canceled))
+	assert.False(t, IsBreakerFailure(resp2.Error{E: errors.New("ERR unknown command")}))
+	assert.True(t, IsBreakerFailure(resp2.Error{E: errors.New("LOADING loading the dataset")}))
+	assert.True(t, IsBreakerFailure(resp2.Error{E: errors.New("CLUSTERDOWN the cluster is down")}))
+	assert.True(t, IsBreakerFailure(context.DeadlineExceeded))
+	assert.True(t, IsBreakerFailure(errors.New("connection reset")))
+}
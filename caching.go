@@ -0,0 +1,452 @@
+package radix
+
+import (
+	"bufio"
+	"bytes"
+	"container/list"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	errors "golang.org/x/xerrors"
+
+	"github.com/mediocregopher/radix/v3/resp/resp2"
+)
+
+// invalidateChannel is the channel redis publishes invalidation messages to
+// when CLIENT TRACKING is used with REDIRECT.
+const invalidateChannel = "__redis__:invalidate"
+
+// cachedCmds are the commands whose results CachingClient will cache. All of
+// them are read-only, and operate on the single key given as their first
+// argument.
+var cachedCmds = map[string]bool{
+	"GET":           true,
+	"GETRANGE":      true,
+	"STRLEN":        true,
+	"HEXISTS":       true,
+	"HGET":          true,
+	"HGETALL":       true,
+	"HKEYS":         true,
+	"HLEN":          true,
+	"HMGET":         true,
+	"HSTRLEN":       true,
+	"HVALS":         true,
+	"LINDEX":        true,
+	"LLEN":          true,
+	"LRANGE":        true,
+	"SCARD":         true,
+	"SISMEMBER":     true,
+	"SMEMBERS":      true,
+	"ZCARD":         true,
+	"ZCOUNT":        true,
+	"ZRANGE":        true,
+	"ZRANGEBYSCORE": true,
+	"ZRANK":         true,
+	"ZREVRANGE":     true,
+	"ZREVRANK":      true,
+	"ZSCORE":        true,
+}
+
+type cachingClientOpts struct {
+	cf         ConnFunc
+	poolSize   int
+	maxEntries int
+	ttl        time.Duration
+}
+
+// CachingClientOpt is an optional behavior which can be applied to the
+// NewCachingClient function to effect a CachingClient's behavior.
+type CachingClientOpt func(*cachingClientOpts)
+
+// CachingClientConnFunc tells the CachingClient to use the given ConnFunc when
+// creating new Conns, both for its Pool and for the Conn which receives
+// invalidation messages.
+func CachingClientConnFunc(cf ConnFunc) CachingClientOpt {
+	return func(co *cachingClientOpts) {
+		co.cf = cf
+	}
+}
+
+// CachingClientPoolSize sets the size of the Pool which the CachingClient uses
+// to perform commands.
+func CachingClientPoolSize(size int) CachingClientOpt {
+	return func(co *cachingClientOpts) {
+		co.poolSize = size
+	}
+}
+
+// CachingClientMaxEntries sets the maximum number of results which will be
+// cached at any one time. Once the limit is reached the least recently used
+// results are evicted to make room for new ones.
+func CachingClientMaxEntries(n int) CachingClientOpt {
+	return func(co *cachingClientOpts) {
+		co.maxEntries = n
+	}
+}
+
+// CachingClientTTL sets the maximum amount of time a result will be cached
+// for, regardless of whether or not it has been invalidated. If 0 then results
+// are only evicted due to invalidation or CachingClientMaxEntries.
+func CachingClientTTL(d time.Duration) CachingClientOpt {
+	return func(co *cachingClientOpts) {
+		co.ttl = d
+	}
+}
+
+type cacheEntry struct {
+	cacheKey, key string
+
+	// raw is nil while the command which will fill the entry is still being
+	// performed. If the key is invalidated during that time the entry is
+	// removed, and so will not be filled.
+	raw       resp2.RawMessage
+	expiresAt time.Time
+	el        *list.Element
+}
+
+// CachingClient is a Client which keeps the results of commands in a local,
+// in-memory cache, using the server-assisted client side caching introduced in
+// redis 6 to evict results when the keys they were read from are modified.
+//
+// Every Conn in the CachingClient's Pool has CLIENT TRACKING enabled, with
+// invalidation messages redirected to a separate Conn which is subscribed to
+// the __redis__:invalidate channel.
+//
+// Only Actions created using Cmd or FlatCmd are cached, and only if their
+// command is a single-key read command such as GET, HGETALL, or SMEMBERS. All
+// other Actions are performed on the Pool as normal. Results are cached in
+// their raw form, and are unmarshaled into the receiver of each Cmd
+// individually, so the same command may be performed with different receiver
+// types.
+//
+// If the Conn receiving invalidation messages encounters an error then the
+// cache is cleared and CachingClient stops caching results, effectively
+// becoming a plain Pool.
+type CachingClient struct {
+	opts cachingClientOpts
+	pool *Pool
+
+	invConn Conn
+	invErr  error
+
+	l          sync.Mutex
+	entries    map[string]*cacheEntry
+	keyEntries map[string]map[*cacheEntry]bool
+	lru        *list.List // of *cacheEntry, most recently used at the front
+
+	wg        sync.WaitGroup
+	closeOnce sync.Once
+	closeCh   chan struct{}
+	closeErr  error
+}
+
+var _ Client = new(CachingClient)
+
+// NewCachingClient creates a CachingClient for the redis instance at the given
+// address. The redis instance must support CLIENT TRACKING (redis 6+).
+//
+// The default options NewCachingClient uses are:
+//
+//	CachingClientConnFunc(DefaultConnFunc)
+//	CachingClientPoolSize(10)
+//	CachingClientMaxEntries(10000)
+//	CachingClientTTL(0)
+//
+func NewCachingClient(network, addr string, opts ...CachingClientOpt) (*CachingClient, error) {
+	cc := &CachingClient{
+		entries:    map[string]*cacheEntry{},
+		keyEntries: map[string]map[*cacheEntry]bool{},
+		lru:        list.New(),
+		closeCh:    make(chan struct{}),
+	}
+
+	defaultCachingClientOpts := []CachingClientOpt{
+		CachingClientConnFunc(DefaultConnFunc),
+		CachingClientPoolSize(10),
+		CachingClientMaxEntries(10000),
+		CachingClientTTL(0),
+	}
+
+	for _, opt := range append(defaultCachingClientOpts, opts...) {
+		opt(&(cc.opts))
+	}
+
+	var err error
+	if cc.invConn, err = cc.opts.cf(network, addr); err != nil {
+		return nil, err
+	}
+
+	var id string
+	if err := cc.invConn.Do(Cmd(&id, "CLIENT", "ID")); err != nil {
+		cc.invConn.Close()
+		return nil, err
+	} else if err := cc.invConn.Do(Cmd(nil, "SUBSCRIBE", invalidateChannel)); err != nil {
+		cc.invConn.Close()
+		return nil, err
+	}
+
+	cc.pool, err = NewPool(network, addr, cc.opts.poolSize, PoolConnFunc(func(network, addr string) (Conn, error) {
+		conn, err := cc.opts.cf(network, addr)
+		if err != nil {
+			return nil, err
+		} else if err := conn.Do(Cmd(nil, "CLIENT", "TRACKING", "ON", "REDIRECT", id)); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		return conn, nil
+	}))
+	if err != nil {
+		cc.invConn.Close()
+		return nil, err
+	}
+
+	cc.wg.Add(2)
+	go cc.spin()
+	go cc.pinger()
+	return cc, nil
+}
+
+type invalidateMessage struct {
+	// ok is false if the message was not an invalidation message, e.g. it was
+	// the response to a PING.
+	ok bool
+
+	// keys is nil if the entire cache should be invalidated, which redis does
+	// when FLUSHALL or FLUSHDB are called.
+	keys []string
+}
+
+func (m *invalidateMessage) UnmarshalRESP(br *bufio.Reader) error {
+	var ah resp2.ArrayHeader
+	if err := ah.UnmarshalRESP(br); err != nil {
+		return err
+	}
+
+	discard := func(n int) error {
+		for i := 0; i < n; i++ {
+			if err := (resp2.Any{}).UnmarshalRESP(br); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if ah.N < 1 {
+		return errors.New("invalidate message has too few elements")
+	}
+
+	var msgType resp2.BulkStringBytes
+	if err := msgType.UnmarshalRESP(br); err != nil {
+		return err
+	} else if string(msgType.B) != "message" || ah.N != 3 {
+		return discard(ah.N - 1)
+	}
+
+	var channel resp2.BulkStringBytes
+	if err := channel.UnmarshalRESP(br); err != nil {
+		return err
+	} else if string(channel.B) != invalidateChannel {
+		return discard(1)
+	}
+
+	mn := MaybeNil{Rcv: &m.keys}
+	if err := mn.UnmarshalRESP(br); err != nil {
+		return err
+	}
+	m.ok = true
+	if mn.Nil {
+		m.keys = nil
+	}
+	return nil
+}
+
+func (cc *CachingClient) spin() {
+	defer cc.wg.Done()
+	for {
+		var m invalidateMessage
+		err := cc.invConn.Decode(&m)
+		if nerr, ok := err.(net.Error); ok && nerr.Timeout() {
+			continue
+		} else if err != nil {
+			cc.l.Lock()
+			cc.invErr = err
+			cc.flush()
+			cc.l.Unlock()
+			return
+		} else if !m.ok {
+			continue
+		}
+
+		cc.l.Lock()
+		if m.keys == nil {
+			cc.flush()
+		}
+		for _, key := range m.keys {
+			for e := range cc.keyEntries[key] {
+				cc.removeEntry(e)
+			}
+		}
+		cc.l.Unlock()
+	}
+}
+
+// pinger periodically pings the Conn receiving invalidation messages, so that
+// it has a keepalive on the application level, as is done by PubSubConn.
+func (cc *CachingClient) pinger() {
+	defer cc.wg.Done()
+	t := time.NewTicker(5 * time.Second)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			if err := cc.invConn.Encode(Cmd(nil, "PING")); err != nil {
+				return
+			}
+		case <-cc.closeCh:
+			return
+		}
+	}
+}
+
+// NOTE l _must_ be held to call this
+func (cc *CachingClient) flush() {
+	cc.entries = map[string]*cacheEntry{}
+	cc.keyEntries = map[string]map[*cacheEntry]bool{}
+	cc.lru.Init()
+}
+
+// NOTE l _must_ be held to call this
+func (cc *CachingClient) addEntry(e *cacheEntry) {
+	cc.entries[e.cacheKey] = e
+	if cc.keyEntries[e.key] == nil {
+		cc.keyEntries[e.key] = map[*cacheEntry]bool{}
+	}
+	cc.keyEntries[e.key][e] = true
+}
+
+// NOTE l _must_ be held to call this
+func (cc *CachingClient) removeEntry(e *cacheEntry) {
+	delete(cc.entries, e.cacheKey)
+	if es := cc.keyEntries[e.key]; es != nil {
+		delete(es, e)
+		if len(es) == 0 {
+			delete(cc.keyEntries, e.key)
+		}
+	}
+	if e.el != nil {
+		cc.lru.Remove(e.el)
+	}
+}
+
+// cachingCmdAction wraps a cmdAction so that its result is read into a
+// RawMessage, rather than the cmdAction's receiver.
+type cachingCmdAction struct {
+	*cmdAction
+	raw resp2.RawMessage
+}
+
+func (c *cachingCmdAction) UnmarshalRESP(br *bufio.Reader) error {
+	return c.raw.UnmarshalRESP(br)
+}
+
+func (c *cachingCmdAction) Run(conn Conn) error {
+	if err := conn.Encode(c); err != nil {
+		return err
+	}
+	return conn.Decode(c)
+}
+
+// Do implements the Do method of the Client interface. If the Action is a
+// cacheable command its result may be served from the cache, otherwise it is
+// performed on the CachingClient's Pool.
+func (cc *CachingClient) Do(a Action) error {
+	cmd, ok := a.(*cmdAction)
+	if !ok || !cachedCmds[strings.ToUpper(cmd.cmd)] {
+		return cc.pool.Do(a)
+	}
+
+	keys := cmd.Keys()
+	if len(keys) != 1 {
+		return cc.pool.Do(a)
+	}
+
+	buf := new(bytes.Buffer)
+	if err := cmd.MarshalRESP(buf); err != nil {
+		return err
+	}
+	cacheKey := buf.String()
+
+	cc.l.Lock()
+	if cc.invErr != nil {
+		cc.l.Unlock()
+		return cc.pool.Do(a)
+	}
+
+	e := cc.entries[cacheKey]
+	if e != nil && e.raw != nil {
+		if cc.opts.ttl == 0 || time.Now().Before(e.expiresAt) {
+			cc.lru.MoveToFront(e.el)
+			raw := e.raw
+			cc.l.Unlock()
+			return raw.UnmarshalInto(cmd)
+		}
+		cc.removeEntry(e)
+		e = nil
+	}
+
+	// if there's no entry then create one, to be filled once the command
+	// returns. If there is one it's already being filled by another call to
+	// Do, so this one shouldn't touch it.
+	var ownE *cacheEntry
+	if e == nil {
+		ownE = &cacheEntry{cacheKey: cacheKey, key: keys[0]}
+		cc.addEntry(ownE)
+	}
+	cc.l.Unlock()
+
+	ca := &cachingCmdAction{cmdAction: cmd}
+	err := cc.pool.Do(ca)
+
+	if ownE != nil {
+		cc.l.Lock()
+		if cc.entries[cacheKey] != ownE {
+			// the key was invalidated while the command was being performed
+		} else if err != nil || isErrorReply(ca.raw) {
+			cc.removeEntry(ownE)
+		} else {
+			ownE.raw = ca.raw
+			if cc.opts.ttl > 0 {
+				ownE.expiresAt = time.Now().Add(cc.opts.ttl)
+			}
+			ownE.el = cc.lru.PushFront(ownE)
+			for cc.lru.Len() > cc.opts.maxEntries {
+				cc.removeEntry(cc.lru.Back().Value.(*cacheEntry))
+			}
+		}
+		cc.l.Unlock()
+	}
+
+	if err != nil {
+		return err
+	}
+	return ca.raw.UnmarshalInto(cmd)
+}
+
+func isErrorReply(raw resp2.RawMessage) bool {
+	return len(raw) > 0 &&
+		(raw[0] == resp2.ErrorPrefix[0] || raw[0] == resp2.BlobErrorPrefix[0])
+}
+
+// Close stops the CachingClient from receiving invalidation messages, and
+// closes its Pool.
+func (cc *CachingClient) Close() error {
+	cc.closeOnce.Do(func() {
+		close(cc.closeCh)
+		cc.invConn.Close()
+		cc.closeErr = cc.pool.Close()
+		cc.wg.Wait()
+	})
+	return cc.closeErr
+}
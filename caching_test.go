@@ -0,0 +1,185 @@
+package radix
+
+import (
+	"strings"
+	"sync"
+	. "testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	errors "golang.org/x/xerrors"
+
+	"github.com/mediocregopher/radix/v3/resp/resp2"
+)
+
+// trackingStub pretends to be a redis instance which supports CLIENT TRACKING,
+// sending an invalidation message on every write.
+type trackingStub struct {
+	l       sync.Mutex
+	kv      map[string]string
+	invConn *stub
+	gets    int
+}
+
+func (ts *trackingStub) invalidate(keys []string) {
+	msg := resp2.Any{I: []interface{}{"message", invalidateChannel, keys}}
+	if keys == nil {
+		msg.I = resp2.RawMessage("*3\r\n$7\r\nmessage\r\n$20\r\n" + invalidateChannel + "\r\n*-1\r\n")
+	}
+	if err := ts.invConn.buffer.Encode(msg); err != nil {
+		panic(err)
+	}
+}
+
+func (ts *trackingStub) connFunc(network, addr string) (Conn, error) {
+	s := &stub{buffer: newBuffer(network, addr)}
+	s.fn = func(args []string) interface{} {
+		ts.l.Lock()
+		defer ts.l.Unlock()
+		switch strings.ToUpper(args[0]) {
+		case "CLIENT":
+			if strings.ToUpper(args[1]) == "ID" {
+				ts.invConn = s
+				return 1
+			}
+			return resp2.SimpleString{S: "OK"}
+		case "SUBSCRIBE":
+			return []interface{}{"subscribe", args[1], 1}
+		case "PING":
+			return resp2.SimpleString{S: "PONG"}
+		case "GET":
+			ts.gets++
+			if args[1] == "error" {
+				return resp2.Error{E: errors.New("ERR this is a test")}
+			} else if v, ok := ts.kv[args[1]]; ok {
+				return v
+			}
+			return nil
+		case "SET":
+			ts.kv[args[1]] = args[2]
+			ts.invalidate(args[1:2])
+			return resp2.SimpleString{S: "OK"}
+		case "FLUSHALL":
+			ts.kv = map[string]string{}
+			ts.invalidate(nil)
+			return resp2.SimpleString{S: "OK"}
+		}
+		return resp2.Error{E: errors.Errorf("unknown command %q", args[0])}
+	}
+	return s, nil
+}
+
+func (ts *trackingStub) numGets() int {
+	ts.l.Lock()
+	defer ts.l.Unlock()
+	return ts.gets
+}
+
+func newTestCachingClient(t *T, opts ...CachingClientOpt) (*CachingClient, *trackingStub) {
+	ts := &trackingStub{kv: map[string]string{}}
+	opts = append([]CachingClientOpt{CachingClientConnFunc(ts.connFunc)}, opts...)
+	cc, err := NewCachingClient("tcp", "127.0.0.1:6379", opts...)
+	require.Nil(t, err)
+	return cc, ts
+}
+
+func (cc *CachingClient) hasEntry(key string) bool {
+	cc.l.Lock()
+	defer cc.l.Unlock()
+	return len(cc.keyEntries[key]) > 0
+}
+
+func waitFor(t *T, fn func() bool) {
+	for i := 0; i < 100; i++ {
+		if fn() {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("condition never became true")
+}
+
+func TestCachingClient(t *T) {
+	cc, ts := newTestCachingClient(t)
+	defer cc.Close()
+
+	key := randStr()
+	require.Nil(t, cc.Do(Cmd(nil, "SET", key, "foo")))
+
+	var out string
+	require.Nil(t, cc.Do(Cmd(&out, "GET", key)))
+	assert.Equal(t, "foo", out)
+	assert.Equal(t, 1, ts.numGets())
+
+	// the second GET should be served from the cache, and a different receiver
+	// type can be used
+	var outB []byte
+	require.Nil(t, cc.Do(FlatCmd(&outB, "GET", key)))
+	assert.Equal(t, []byte("foo"), outB)
+	assert.Equal(t, 1, ts.numGets())
+
+	// writing the key should invalidate the cached result
+	require.Nil(t, cc.Do(Cmd(nil, "SET", key, "bar")))
+	waitFor(t, func() bool { return !cc.hasEntry(key) })
+	require.Nil(t, cc.Do(Cmd(&out, "GET", key)))
+	assert.Equal(t, "bar", out)
+	assert.Equal(t, 2, ts.numGets())
+
+	// nil results are cached too
+	var mn MaybeNil
+	nilKey := randStr()
+	require.Nil(t, cc.Do(Cmd(&mn, "GET", nilKey)))
+	assert.True(t, mn.Nil)
+	require.Nil(t, cc.Do(Cmd(&mn, "GET", nilKey)))
+	assert.True(t, mn.Nil)
+	assert.Equal(t, 3, ts.numGets())
+
+	// FLUSHALL invalidates everything
+	require.Nil(t, cc.Do(Cmd(nil, "FLUSHALL")))
+	waitFor(t, func() bool { return !cc.hasEntry(key) && !cc.hasEntry(nilKey) })
+	require.Nil(t, cc.Do(Cmd(&mn, "GET", key)))
+	assert.True(t, mn.Nil)
+	assert.Equal(t, 4, ts.numGets())
+
+	// errors are not cached
+	assert.NotNil(t, cc.Do(Cmd(nil, "GET", "error")))
+	assert.NotNil(t, cc.Do(Cmd(nil, "GET", "error")))
+	assert.Equal(t, 6, ts.numGets())
+}
+
+func TestCachingClientMaxEntries(t *T) {
+	cc, ts := newTestCachingClient(t, CachingClientMaxEntries(2))
+	defer cc.Close()
+
+	keys := []string{randStr(), randStr(), randStr()}
+	for _, key := range keys {
+		require.Nil(t, cc.Do(Cmd(nil, "GET", key)))
+	}
+	assert.Equal(t, 3, ts.numGets())
+	assert.False(t, cc.hasEntry(keys[0]))
+	assert.True(t, cc.hasEntry(keys[1]))
+	assert.True(t, cc.hasEntry(keys[2]))
+
+	// keys[1] is now the most recently used, so keys[2] should be evicted
+	require.Nil(t, cc.Do(Cmd(nil, "GET", keys[1])))
+	require.Nil(t, cc.Do(Cmd(nil, "GET", keys[0])))
+	assert.Equal(t, 4, ts.numGets())
+	assert.True(t, cc.hasEntry(keys[0]))
+	assert.True(t, cc.hasEntry(keys[1]))
+	assert.False(t, cc.hasEntry(keys[2]))
+}
+
+func TestCachingClientTTL(t *T) {
+	cc, ts := newTestCachingClient(t, CachingClientTTL(50*time.Millisecond))
+	defer cc.Close()
+
+	key := randStr()
+	require.Nil(t, cc.Do(Cmd(nil, "GET", key)))
+	require.Nil(t, cc.Do(Cmd(nil, "GET", key)))
+	assert.Equal(t, 1, ts.numGets())
+
+	time.Sleep(100 * time.Millisecond)
+	require.Nil(t, cc.Do(Cmd(nil, "GET", key)))
+	assert.Equal(t, 2, ts.numGets())
+}
@@ -0,0 +1,129 @@
+package radix
+
+import (
+	"bufio"
+	"strconv"
+	"strings"
+
+	errors "golang.org/x/xerrors"
+
+	"github.com/mediocregopher/radix/v3/resp/resp2"
+)
+
+// ClientInfo describes a single client connection listed in the reply to CLIENT
+// LIST, as described by:
+//
+//	https://redis.io/commands/client-list
+//
+// All fields of the client, including ones which aren't parsed, are available
+// in Fields.
+type ClientInfo struct {
+	ID int64 `info:"id"`
+
+	// Addr is the address of the client, and LAddr is the local address on
+	// the redis instance the client is connected to. LAddr is only returned by
+	// redis 6.2 and above.
+	Addr  string `info:"addr"`
+	LAddr string `info:"laddr"`
+
+	// Name is the name given by the client using CLIENT SETNAME, if any.
+	Name string `info:"name"`
+
+	// Age is the number of seconds the connection has existed for, and Idle is
+	// the number of seconds since it last performed a command.
+	Age  int64 `info:"age"`
+	Idle int64 `info:"idle"`
+
+	// Flags are the client's flags, e.g. "N" for a normal client, "S" for a
+	// replica, or "P" for a pubsub client.
+	Flags string `info:"flags"`
+
+	DB int64 `info:"db"`
+
+	// Cmd is the last command the client performed.
+	Cmd string `info:"cmd"`
+
+	Fields map[string]string
+}
+
+// ParseClientList parses the reply to CLIENT LIST, which has one line per
+// client. See ClientInfo for details.
+func ParseClientList(s string) ([]ClientInfo, error) {
+	var clients []ClientInfo
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if line == "" {
+			continue
+		}
+
+		fields := map[string]string{}
+		for _, kv := range strings.Fields(line) {
+			i := strings.IndexByte(kv, '=')
+			if i < 0 {
+				return nil, errors.Errorf("malformed CLIENT LIST field %q", kv)
+			}
+			fields[kv[:i]] = kv[i+1:]
+		}
+
+		client := ClientInfo{Fields: fields}
+		if err := setInfoFields(&client, fields); err != nil {
+			return nil, err
+		}
+		clients = append(clients, client)
+	}
+	return clients, nil
+}
+
+type clientInfos []ClientInfo
+
+func (cc *clientInfos) UnmarshalRESP(br *bufio.Reader) error {
+	var s string
+	if err := (resp2.Any{I: &s}).UnmarshalRESP(br); err != nil {
+		return err
+	}
+	parsed, err := ParseClientList(s)
+	if err != nil {
+		return err
+	}
+	*cc = parsed
+	return nil
+}
+
+// ClientListCmd returns a CmdAction which performs CLIENT LIST and parses the
+// reply into rcv.
+func ClientListCmd(rcv *[]ClientInfo) CmdAction {
+	return Cmd((*clientInfos)(rcv), "CLIENT", "LIST")
+}
+
+func clientKillCmd(rcv *int, filter, value string) CmdAction {
+	if rcv == nil {
+		return Cmd(nil, "CLIENT", "KILL", filter, value)
+	}
+	return Cmd(rcv, "CLIENT", "KILL", filter, value)
+}
+
+// ClientKillIDCmd returns a CmdAction which performs CLIENT KILL on the client
+// with the given ID, writing the number of clients killed into rcv, if it's not
+// nil.
+func ClientKillIDCmd(rcv *int, id int64) CmdAction {
+	return clientKillCmd(rcv, "ID", strconv.FormatInt(id, 10))
+}
+
+// ClientKillAddrCmd is like ClientKillIDCmd, but kills the client with the
+// given address, as given by ClientInfo.Addr.
+func ClientKillAddrCmd(rcv *int, addr string) CmdAction {
+	return clientKillCmd(rcv, "ADDR", addr)
+}
+
+// ClientKillLAddrCmd is like ClientKillIDCmd, but kills all clients connected
+// to the given local address of the redis instance, as given by
+// ClientInfo.LAddr. This requires redis 6.2 or above.
+func ClientKillLAddrCmd(rcv *int, laddr string) CmdAction {
+	return clientKillCmd(rcv, "LADDR", laddr)
+}
+
+// ClientKillTypeCmd is like ClientKillIDCmd, but kills all clients of the given
+// type, which is one of "normal", "master", "replica", or "pubsub".
+func ClientKillTypeCmd(rcv *int, typ string) CmdAction {
+	return clientKillCmd(rcv, "TYPE", typ)
+}
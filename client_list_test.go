@@ -0,0 +1,66 @@
+package radix
+
+import (
+	. "testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testClientList = "id=3 addr=127.0.0.1:55290 laddr=127.0.0.1:6379 fd=8 name=worker-1 age=12 idle=2 flags=N db=0 sub=0 psub=0 cmd=client|list user=default\r\n" +
+	"id=5 addr=127.0.0.1:55292 fd=9 name= age=7 idle=7 flags=P db=2 sub=1 psub=0 cmd=subscribe\n"
+
+func TestParseClientList(t *T) {
+	clients, err := ParseClientList(testClientList)
+	require.Nil(t, err)
+	require.Len(t, clients, 2)
+
+	assert.Equal(t, "default", clients[0].Fields["user"])
+	clients[0].Fields, clients[1].Fields = nil, nil
+	assert.Equal(t, []ClientInfo{
+		{
+			ID: 3, Addr: "127.0.0.1:55290", LAddr: "127.0.0.1:6379",
+			Name: "worker-1", Age: 12, Idle: 2, Flags: "N", DB: 0,
+			Cmd: "client|list",
+		},
+		{
+			ID: 5, Addr: "127.0.0.1:55292", Age: 7, Idle: 7, Flags: "P",
+			DB: 2, Cmd: "subscribe",
+		},
+	}, clients)
+
+	_, err = ParseClientList("id=3 addr")
+	assert.Error(t, err)
+	_, err = ParseClientList("id=foo")
+	assert.Error(t, err)
+}
+
+func TestClientCmds(t *T) {
+	var gotArgs [][]string
+	conn := Stub("tcp", "127.0.0.1:6379", func(args []string) interface{} {
+		gotArgs = append(gotArgs, args)
+		if args[1] == "LIST" {
+			return testClientList
+		}
+		return 1
+	})
+
+	var clients []ClientInfo
+	require.Nil(t, conn.Do(ClientListCmd(&clients)))
+	assert.Len(t, clients, 2)
+
+	var n int
+	require.Nil(t, conn.Do(ClientKillIDCmd(&n, 3)))
+	assert.Equal(t, 1, n)
+	require.Nil(t, conn.Do(ClientKillAddrCmd(nil, "127.0.0.1:55290")))
+	require.Nil(t, conn.Do(ClientKillLAddrCmd(nil, "127.0.0.1:6379")))
+	require.Nil(t, conn.Do(ClientKillTypeCmd(nil, "pubsub")))
+
+	assert.Equal(t, [][]string{
+		{"CLIENT", "LIST"},
+		{"CLIENT", "KILL", "ID", "3"},
+		{"CLIENT", "KILL", "ADDR", "127.0.0.1:55290"},
+		{"CLIENT", "KILL", "LADDR", "127.0.0.1:6379"},
+		{"CLIENT", "KILL", "TYPE", "pubsub"},
+	}, gotArgs)
+}
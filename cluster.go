@@ -1,7 +1,10 @@
 package radix
 
 import (
+	"context"
+	"math/rand"
 	"reflect"
+	"sort"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -47,8 +50,8 @@ func (d *dedupe) do(fn func()) {
 // ClusterCanRetryAction, and the ClusterCanRetry method returns true, then the
 // Action will be retried on the correct node.
 //
-// NOTE that the Actions which are returned by Cmd, FlatCmd, and EvalScript.Cmd
-// all implicitly implement this interface.
+// NOTE that the Actions which are returned by Cmd, FlatCmd, EvalScript.Cmd, and
+// Function.Cmd all implicitly implement this interface.
 type ClusterCanRetryAction interface {
 	Action
 	ClusterCanRetry() bool
@@ -60,7 +63,27 @@ type clusterOpts struct {
 	pf              ClientFunc
 	clusterDownWait time.Duration
 	syncEvery       time.Duration
-	ct              trace.ClusterTrace
+
+	tryAgainAttempts int
+	tryAgainDelay    time.Duration
+
+	ct           trace.ClusterTrace
+	onTopoChange func(prev, cur ClusterTopo)
+	addrMapper   func(announced string) string
+
+	dnsInterval time.Duration
+	dnsSRV      bool
+
+	readFromSecondaries bool
+	secondaryPolicy     ClusterSecondaryPolicy
+	secondaryFallback   bool
+	hedgeDelay          time.Duration
+
+	scriptRegistry *ScriptRegistry
+	logger         Logger
+
+	// used to create the default pools, if pf isn't given
+	clientName func() string
 }
 
 // ClusterOpt is an optional behavior which can be applied to the NewCluster
@@ -80,6 +103,22 @@ func ClusterPoolFunc(pf ClientFunc) ClusterOpt {
 	}
 }
 
+// ClusterClientNameFunc tells the Cluster to give each connection it creates
+// to a cluster member a name, using CLIENT SETNAME, with fn being called to get
+// the name of each one. SequentialClientName can be used to give each
+// connection a distinct name.
+//
+// This is equivalent to using ClusterPoolFunc with a ClientFunc like
+// DefaultClientFunc, but which passes PoolClientNameFunc(fn) into NewPool. It
+// therefore replaces the ClientFunc given by any earlier ClusterPoolFunc, and
+// is replaced by any later one.
+func ClusterClientNameFunc(fn func() string) ClusterOpt {
+	return func(co *clusterOpts) {
+		co.pf = nil
+		co.clientName = fn
+	}
+}
+
 // ClusterSyncEvery tells the Cluster to synchronize itself with the cluster's
 // topology at the given interval. On every synchronization Cluster will ask the
 // cluster for its topology and make/destroy its connections as necessary.
@@ -89,6 +128,31 @@ func ClusterSyncEvery(d time.Duration) ClusterOpt {
 	}
 }
 
+// ClusterDNSDiscovery tells the Cluster to treat the hosts of the addresses
+// given to NewCluster as DNS names which may each resolve to multiple
+// addresses, all of which are used as seed addresses for discovering the
+// cluster's topology. If the Cluster fails to synchronize with the topology,
+// e.g. because every node it knew of has gone away, the names are resolved
+// again (at most once per the given interval) and the Cluster bootstraps
+// itself from the newly resolved addresses.
+func ClusterDNSDiscovery(interval time.Duration) ClusterOpt {
+	return func(co *clusterOpts) {
+		co.dnsInterval = interval
+		co.dnsSRV = false
+	}
+}
+
+// ClusterDNSSRVDiscovery is like ClusterDNSDiscovery, but the addresses given
+// to NewCluster are treated as the names of SRV records, e.g.
+// "_redis._tcp.example.com", whose targets and ports are used as the seed
+// addresses.
+func ClusterDNSSRVDiscovery(interval time.Duration) ClusterOpt {
+	return func(co *clusterOpts) {
+		co.dnsInterval = interval
+		co.dnsSRV = true
+	}
+}
+
 // ClusterOnDownDelayActionsBy tells the Cluster to delay all commands by the given
 // duration while the cluster is seen to be in the CLUSTERDOWN state. This
 // allows fewer actions to be affected by brief outages, e.g. during a failover.
@@ -102,6 +166,118 @@ func ClusterOnDownDelayActionsBy(d time.Duration) ClusterOpt {
 	}
 }
 
+// ClusterOnTryAgainRetry tells the Cluster to perform an Action again, up to the
+// given number of additional attempts with the given delay before each, when it
+// fails with a TRYAGAIN error. Redis returns TRYAGAIN for multi-key commands
+// whose keys are split between two nodes while their slot is being migrated, and
+// once the migration has progressed the command will succeed.
+//
+// As with MOVED and ASK redirects, only Actions which implement
+// ClusterCanRetryAction are retried. If attempts is 0 then TRYAGAIN errors are
+// returned immediately.
+func ClusterOnTryAgainRetry(attempts int, delay time.Duration) ClusterOpt {
+	return func(co *clusterOpts) {
+		co.tryAgainAttempts = attempts
+		co.tryAgainDelay = delay
+	}
+}
+
+// ClusterAddrMapper tells the Cluster to pass every node address which it
+// learns of from the cluster itself, i.e. from CLUSTER SLOTS or from MOVED and
+// ASK redirects, through the given function, and to use the returned address
+// instead. The addresses given to NewCluster are not passed through it.
+//
+// This is needed when the addresses the nodes announce aren't reachable by
+// the client, e.g. when redis is running within Docker or Kubernetes, or behind
+// NAT, and announces its internal IP address. The mapped addresses are the ones
+// which appear in the Cluster's topology.
+func ClusterAddrMapper(fn func(announced string) string) ClusterOpt {
+	return func(co *clusterOpts) {
+		co.addrMapper = fn
+	}
+}
+
+// ClusterOnTopoChange tells the Cluster to call the given callback whenever it
+// synchronizes itself and sees that the cluster's topology has changed, e.g.
+// due to resharding or a failover. The callback is given the previously known
+// topology and the new one. It is called synchronously, after the Cluster has
+// begun using the new topology.
+//
+// The callback is also called during NewCluster, with an empty previous
+// topology.
+func ClusterOnTopoChange(fn func(prev, cur ClusterTopo)) ClusterOpt {
+	return func(co *clusterOpts) {
+		co.onTopoChange = fn
+	}
+}
+
+// ClusterSecondaryPolicy determines which of a primary's secondaries an Action
+// is sent to by DoSecondary, or by Do when ClusterReadFromSecondaries is used.
+type ClusterSecondaryPolicy int
+
+// All possible values of ClusterSecondaryPolicy.
+const (
+	// ClusterSecondaryRandom picks a random secondary for each Action.
+	ClusterSecondaryRandom ClusterSecondaryPolicy = iota
+
+	// ClusterSecondaryRoundRobin cycles through the secondaries, so that each
+	// receives an equal share of Actions.
+	ClusterSecondaryRoundRobin
+
+	// ClusterSecondaryLowestLatency picks the secondary which responded most
+	// quickly to a PING during the last synchronization (see
+	// ClusterSyncEvery).
+	ClusterSecondaryLowestLatency
+)
+
+// ClusterReadFromSecondaries tells the Cluster to send Actions passed to Do
+// which consist of a single read-only command, such as GET or HGETALL, to one
+// of the secondaries of the primary owning the key. The secondary is chosen
+// using the given ClusterSecondaryPolicy, which is also used by DoSecondary. If
+// the primary has no secondaries then the Action is sent to the primary.
+//
+// Secondaries only serve reads on connections which are in READONLY mode, so
+// when this option is used the Cluster's default pools create connections
+// using DefaultClusterConnFunc. If ClusterPoolFunc is given, regardless of
+// whether it's before or after this option, then the ClientFunc given to it
+// must do the same.
+//
+// Note that secondaries are replicated to asynchronously, so reads from them
+// may return stale data.
+func ClusterReadFromSecondaries(policy ClusterSecondaryPolicy) ClusterOpt {
+	return func(co *clusterOpts) {
+		co.readFromSecondaries = true
+		co.secondaryPolicy = policy
+	}
+}
+
+// ClusterSecondaryFallbackToPrimary tells the Cluster that, if an Action sent
+// to a secondary fails with a network error, it should be performed again on
+// the primary. This only applies to Actions which are sent to secondaries, see
+// DoSecondary and ClusterReadFromSecondaries.
+func ClusterSecondaryFallbackToPrimary() ClusterOpt {
+	return func(co *clusterOpts) {
+		co.secondaryFallback = true
+	}
+}
+
+// ClusterHedgedReads tells the Cluster that, if an Action sent to a secondary
+// hasn't completed within the given delay, it should also be sent to another
+// secondary of the same primary, or to the primary if there are no others. The
+// reply of whichever completes successfully first is used. The Action is also
+// sent to the other node right away if the first fails with a network error.
+//
+// This only applies to Actions consisting of a single read-only command which
+// are sent to secondaries, see DoSecondary and ClusterReadFromSecondaries.
+// Hedging reduces tail latency caused by a single slow node, at the cost of
+// sending more commands to the cluster. The delay should therefore be set to
+// around the 95th or 99th percentile latency of such commands.
+func ClusterHedgedReads(delay time.Duration) ClusterOpt {
+	return func(co *clusterOpts) {
+		co.hedgeDelay = delay
+	}
+}
+
 // ClusterWithTrace tells the Cluster to trace itself with the given
 // ClusterTrace. Note that ClusterTrace will block every point that you set to
 // trace.
@@ -111,6 +287,19 @@ func ClusterWithTrace(ct trace.ClusterTrace) ClusterOpt {
 	}
 }
 
+// ClusterLogger tells the Cluster to log events using the given Logger. The
+// Cluster logs changes to its topology and to whether the cluster is down,
+// warnings when it fails to sync its topology, and the MOVED and ASK redirects
+// it follows at debug level.
+//
+// The Logger isn't passed into the Cluster's ClusterPoolFunc, use PoolLogger
+// for that.
+func ClusterLogger(l Logger) ClusterOpt {
+	return func(co *clusterOpts) {
+		co.logger = l
+	}
+}
+
 // Cluster contains all information about a redis cluster needed to interact
 // with it, including a set of pools to each of its instances. All methods on
 // Cluster are thread-safe
@@ -118,7 +307,8 @@ type Cluster struct {
 	// Atomic fields must be at the beginning of the struct since they must be
 	// correctly aligned or else access may cause panics on 32-bit architectures
 	// See https://golang.org/pkg/sync/atomic/#pkg-note-BUG
-	lastClusterdown int64 // unix timestamp in milliseconds, atomic
+	lastClusterdown int64  // unix timestamp in milliseconds, atomic
+	roundRobin      uint64 // used by ClusterSecondaryRoundRobin, atomic
 
 	co clusterOpts
 
@@ -129,6 +319,13 @@ type Cluster struct {
 	pools          map[string]Client
 	primTopo, topo ClusterTopo
 	secondaries    map[string]map[string]ClusterNode
+	latencies      map[string]time.Duration // used by ClusterSecondaryLowestLatency
+
+	// ClusterShardedPubSubs which need to be told about topology changes
+	shardedPubSubs map[*ClusterShardedPubSub]bool
+
+	// set if ClusterDNSDiscovery or ClusterDNSSRVDiscovery were given
+	seeds []*dnsDiscovery
 
 	closeCh   chan struct{}
 	closeWG   sync.WaitGroup
@@ -140,6 +337,22 @@ type Cluster struct {
 	ErrCh chan error
 }
 
+// defaultPoolFunc returns the ClientFunc used when ClusterPoolFunc isn't given,
+// which is like DefaultClientFunc but also applies the ClusterClientNameFunc
+// and ClusterReadFromSecondaries options to each connection.
+func (co *clusterOpts) defaultPoolFunc() ClientFunc {
+	var poolOpts []PoolOpt
+	if co.readFromSecondaries {
+		poolOpts = append(poolOpts, PoolConnFunc(DefaultClusterConnFunc))
+	}
+	if co.clientName != nil {
+		poolOpts = append(poolOpts, PoolClientNameFunc(co.clientName))
+	}
+	return func(network, addr string) (Client, error) {
+		return NewPool(network, addr, 4, poolOpts...)
+	}
+}
+
 // DefaultClusterConnFunc is a ConnFunc which will return a Conn for a node in a
 // redis cluster using sane defaults and which has READONLY mode enabled, allowing
 // read-only commands on the connection even if the connected instance is currently
@@ -163,9 +376,10 @@ var DefaultClusterConnFunc = func(network, addr string) (Conn, error) {
 // NewCluster takes in a number of options which can overwrite its default
 // behavior. The default options NewCluster uses are:
 //
-//     ClusterPoolFunc(DefaultClientFunc)
+//     ClusterPoolFunc(DefaultClientFunc) // see ClusterReadFromSecondaries
 //     ClusterSyncEvery(5 * time.Second)
 //     ClusterOnDownDelayActionsBy(100 * time.Millisecond)
+//     ClusterOnTryAgainRetry(5, 20 * time.Millisecond)
 //
 func NewCluster(clusterAddrs []string, opts ...ClusterOpt) (*Cluster, error) {
 	c := &Cluster{
@@ -176,9 +390,9 @@ func NewCluster(clusterAddrs []string, opts ...ClusterOpt) (*Cluster, error) {
 	}
 
 	defaultClusterOpts := []ClusterOpt{
-		ClusterPoolFunc(DefaultClientFunc),
 		ClusterSyncEvery(5 * time.Second),
 		ClusterOnDownDelayActionsBy(100 * time.Millisecond),
+		ClusterOnTryAgainRetry(5, 20*time.Millisecond),
 	}
 
 	for _, opt := range append(defaultClusterOpts, opts...) {
@@ -190,6 +404,34 @@ func NewCluster(clusterAddrs []string, opts ...ClusterOpt) (*Cluster, error) {
 		}
 	}
 
+	if c.co.pf == nil {
+		c.co.pf = c.co.defaultPoolFunc()
+	}
+
+	if sr := c.co.scriptRegistry; sr != nil {
+		pf := c.co.pf
+		c.co.pf = func(network, addr string) (Client, error) {
+			p, err := pf(network, addr)
+			if err != nil {
+				return nil, err
+			} else if err := p.Do(sr.Action()); err != nil {
+				p.Close()
+				return nil, err
+			}
+			return p, nil
+		}
+	}
+
+	if c.co.dnsInterval > 0 {
+		for _, addr := range clusterAddrs {
+			c.seeds = append(c.seeds, newDNSDiscovery(addr, c.co.dnsSRV, c.co.dnsInterval))
+		}
+		var err error
+		if clusterAddrs, err = c.seedAddrs(); err != nil {
+			return nil, err
+		}
+	}
+
 	// make a pool to base the cluster on
 	for _, addr := range clusterAddrs {
 		p, err := c.co.pf("tcp", addr)
@@ -212,6 +454,17 @@ func NewCluster(clusterAddrs []string, opts ...ClusterOpt) (*Cluster, error) {
 	return c, nil
 }
 
+func (c *Cluster) log(level LogLevel, msg string, keyvals ...interface{}) {
+	logEvent(c.co.logger, level, msg, keyvals...)
+}
+
+// syncErr handles an error returned from a Sync which was performed in the
+// background.
+func (c *Cluster) syncErr(err error) {
+	c.log(LogLevelWarn, "failed to sync topology", "err", err)
+	c.err(err)
+}
+
 func (c *Cluster) err(err error) {
 	select {
 	case c.ErrCh <- err:
@@ -316,12 +569,50 @@ func (c *Cluster) Topo() ClusterTopo {
 	return c.topo
 }
 
+// NodeForKey returns the primary node which, according to the Cluster's
+// current topology, owns the slot of the given key, and therefore which Do will
+// send Actions on that key to. False is returned if no node is known to own the
+// slot.
+//
+// This is primarily useful for debugging how keys are routed. Since the
+// topology may change at any time there's no guarantee that the node will still
+// own the key by the time it's used.
+func (c *Cluster) NodeForKey(key string) (ClusterNode, bool) {
+	slot := ClusterKeySlot(key)
+	c.l.RLock()
+	defer c.l.RUnlock()
+	for _, node := range c.primTopo {
+		for _, slots := range node.Slots {
+			if slot >= slots[0] && slot < slots[1] {
+				return node, true
+			}
+		}
+	}
+	return ClusterNode{}, false
+}
+
 func (c *Cluster) getTopo(p Client) (ClusterTopo, error) {
 	var tt ClusterTopo
 	err := p.Do(Cmd(&tt, "CLUSTER", "SLOTS"))
+	if err == nil && c.co.addrMapper != nil {
+		for i := range tt {
+			tt[i].Addr = c.mapAddr(tt[i].Addr)
+			tt[i].SecondaryOfAddr = c.mapAddr(tt[i].SecondaryOfAddr)
+		}
+		tt.sort()
+	}
 	return tt, err
 }
 
+// mapAddr returns the given announced address as mapped by ClusterAddrMapper,
+// if it was given.
+func (c *Cluster) mapAddr(addr string) string {
+	if addr == "" || c.co.addrMapper == nil {
+		return addr
+	}
+	return c.co.addrMapper(addr)
+}
+
 // Sync will synchronize the Cluster with the actual cluster, making new pools
 // to new instances and removing ones from instances no longer in the cluster.
 // This will be called periodically automatically, but you can manually call it
@@ -345,8 +636,17 @@ func nodeInfoFromNode(node ClusterNode) trace.ClusterNodeInfo {
 	}
 }
 
+func nodeInfoAddrs(nodes []trace.ClusterNodeInfo) []string {
+	addrs := make([]string, len(nodes))
+	for i := range nodes {
+		addrs[i] = nodes[i].Addr
+	}
+	sort.Strings(addrs)
+	return addrs
+}
+
 func (c *Cluster) traceTopoChanged(prevTopo ClusterTopo, newTopo ClusterTopo) {
-	if c.co.ct.TopoChanged != nil {
+	if c.co.ct.TopoChanged != nil || c.co.logger != nil {
 		var addedNodes []trace.ClusterNodeInfo
 		var removedNodes []trace.ClusterNodeInfo
 		var changedNodes []trace.ClusterNodeInfo
@@ -376,13 +676,21 @@ func (c *Cluster) traceTopoChanged(prevTopo ClusterTopo, newTopo ClusterTopo) {
 		}
 
 		// Callback when any changes detected
-		if len(addedNodes) != 0 || len(removedNodes) != 0 || len(changedNodes) != 0 {
+		if len(addedNodes) == 0 && len(removedNodes) == 0 && len(changedNodes) == 0 {
+			return
+		} else if c.co.ct.TopoChanged != nil {
 			c.co.ct.TopoChanged(trace.ClusterTopoChanged{
 				Added:   addedNodes,
 				Removed: removedNodes,
 				Changed: changedNodes,
 			})
 		}
+
+		c.log(LogLevelInfo, "topology changed",
+			"added", nodeInfoAddrs(addedNodes),
+			"removed", nodeInfoAddrs(removedNodes),
+			"changed", nodeInfoAddrs(changedNodes),
+		)
 	}
 }
 
@@ -394,20 +702,36 @@ func (c *Cluster) sync(p Client) error {
 		return err
 	}
 
+	var latencies map[string]time.Duration
+	if c.co.secondaryPolicy == ClusterSecondaryLowestLatency {
+		latencies = map[string]time.Duration{}
+	}
+
 	for _, t := range tt {
 		// call pool just to ensure one exists for this addr
-		if _, err := c.pool(t.Addr); err != nil {
+		p, err := c.pool(t.Addr)
+		if err != nil {
 			return errors.Errorf("error connecting to %s: %w", t.Addr, err)
 		}
+
+		if latencies != nil && t.SecondaryOfAddr != "" {
+			start := time.Now()
+			if err := p.Do(Cmd(nil, "PING")); err == nil {
+				latencies[t.Addr] = time.Since(start)
+			}
+		}
 	}
 
 	c.traceTopoChanged(c.topo, tt)
 
+	var prevTopo ClusterTopo
 	var toclose []Client
 	func() {
 		c.l.Lock()
 		defer c.l.Unlock()
+		prevTopo = c.topo
 		c.topo = tt
+		c.latencies = latencies
 		c.primTopo = tt.Primaries()
 
 		c.secondaries = make(map[string]map[string]ClusterNode, len(c.primTopo))
@@ -435,6 +759,18 @@ func (c *Cluster) sync(p Client) error {
 		p.Close()
 	}
 
+	if !reflect.DeepEqual(prevTopo, tt) {
+		c.l.RLock()
+		for sp := range c.shardedPubSubs {
+			sp.triggerReconcile()
+		}
+		c.l.RUnlock()
+
+		if c.co.onTopoChange != nil {
+			c.co.onTopoChange(prevTopo, tt)
+		}
+	}
+
 	return nil
 }
 
@@ -449,7 +785,12 @@ func (c *Cluster) syncEvery(d time.Duration) {
 			select {
 			case <-t.C:
 				if err := c.Sync(); err != nil {
-					c.err(err)
+					c.syncErr(err)
+					if len(c.seeds) > 0 {
+						if err := c.syncFromSeeds(); err != nil {
+							c.syncErr(err)
+						}
+					}
 				}
 			case <-c.closeCh:
 				return
@@ -458,28 +799,148 @@ func (c *Cluster) syncEvery(d time.Duration) {
 	}()
 }
 
+// seedAddrs returns all addresses resolved from the Cluster's seeds. An error
+// is only returned if no addresses could be resolved at all.
+func (c *Cluster) seedAddrs() ([]string, error) {
+	var addrs []string
+	var firstErr error
+	for _, seed := range c.seeds {
+		seedAddrs, err := seed.Addrs()
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+		addrs = append(addrs, seedAddrs...)
+	}
+	if len(addrs) == 0 {
+		return nil, firstErr
+	}
+	return addrs, nil
+}
+
+// syncFromSeeds synchronizes the Cluster's topology using the first of the
+// addresses resolved from its seeds which can be synchronized from.
+func (c *Cluster) syncFromSeeds() error {
+	addrs, err := c.seedAddrs()
+	if err != nil {
+		return err
+	}
+
+	c.syncDedupe.do(func() {
+		err = errors.New("no seed addresses available")
+		for _, addr := range addrs {
+			var p Client
+			if p, err = c.co.pf("tcp", addr); err != nil {
+				continue
+			}
+			// sync creates pools for all nodes in the topology itself, so this
+			// one is no longer needed afterwards.
+			err = c.sync(p)
+			p.Close()
+			if err == nil {
+				return
+			}
+		}
+	})
+	return err
+}
+
 func (c *Cluster) addrForKey(key string) string {
-	s := ClusterSlot([]byte(key))
+	node, _ := c.NodeForKey(key)
+	return node.Addr
+}
+
+func (c *Cluster) secondaryAddrForKey(key string) string {
+	primAddr := c.addrForKey(key)
+
 	c.l.RLock()
 	defer c.l.RUnlock()
-	for _, t := range c.primTopo {
-		for _, slot := range t.Slots {
-			if s >= slot[0] && s < slot[1] {
-				return t.Addr
+	secondaries := c.secondaries[primAddr]
+	if len(secondaries) == 0 {
+		return primAddr
+	}
+
+	addrs := make([]string, 0, len(secondaries))
+	for addr := range secondaries {
+		addrs = append(addrs, addr)
+	}
+
+	switch c.co.secondaryPolicy {
+	case ClusterSecondaryRoundRobin:
+		sort.Strings(addrs)
+		i := atomic.AddUint64(&c.roundRobin, 1)
+		return addrs[i%uint64(len(addrs))]
+
+	case ClusterSecondaryLowestLatency:
+		// secondaries whose latency is unknown, because they didn't respond to
+		// the PING, are only used if no others are available.
+		best := addrs[0]
+		bestLatency, bestOK := c.latencies[best]
+		for _, addr := range addrs[1:] {
+			latency, ok := c.latencies[addr]
+			if ok && (!bestOK || latency < bestLatency) {
+				best, bestLatency, bestOK = addr, latency, ok
 			}
 		}
+		return best
+
+	default:
+		return addrs[rand.Intn(len(addrs))]
 	}
-	return ""
 }
 
-func (c *Cluster) secondaryAddrForKey(key string) string {
+// hedgeAddrForKey returns the address of the node which a hedged read for the
+// given key, which was first sent to addr, should be sent to. This is a random
+// secondary of the key's primary other than addr, or the primary itself if
+// there are none. An empty string is returned if there is no such node.
+func (c *Cluster) hedgeAddrForKey(key, addr string) string {
+	primAddr := c.addrForKey(key)
+
 	c.l.RLock()
 	defer c.l.RUnlock()
-	primAddr := c.addrForKey(key)
-	for addr := range c.secondaries[primAddr] {
-		return addr
+	addrs := make([]string, 0, len(c.secondaries[primAddr]))
+	for secAddr := range c.secondaries[primAddr] {
+		if secAddr != addr {
+			addrs = append(addrs, secAddr)
+		}
+	}
+
+	if len(addrs) > 0 {
+		return addrs[rand.Intn(len(addrs))]
+	} else if primAddr != addr {
+		return primAddr
+	}
+	return ""
+}
+
+// readOnlyCmds contains the commands which ClusterReadFromSecondaries will
+// send to secondaries.
+var readOnlyCmds = map[string]bool{
+	"BITCOUNT": true, "BITPOS": true, "DUMP": true, "EXISTS": true,
+	"GEODIST": true, "GEOHASH": true, "GEOPOS": true, "GEORADIUS_RO": true,
+	"GEORADIUSBYMEMBER_RO": true, "GET": true, "GETBIT": true,
+	"GETRANGE": true, "HEXISTS": true, "HGET": true, "HGETALL": true,
+	"HKEYS": true, "HLEN": true, "HMGET": true, "HSCAN": true,
+	"HSTRLEN": true, "HVALS": true, "LINDEX": true, "LLEN": true,
+	"LRANGE": true, "MGET": true, "PFCOUNT": true, "PTTL": true,
+	"SCARD": true, "SDIFF": true, "SINTER": true, "SISMEMBER": true,
+	"SMEMBERS": true, "SRANDMEMBER": true, "SSCAN": true, "STRLEN": true,
+	"SUNION": true, "TTL": true, "TYPE": true, "XLEN": true, "XRANGE": true,
+	"XREVRANGE": true, "ZCARD": true, "ZCOUNT": true, "ZLEXCOUNT": true,
+	"ZRANGE": true, "ZRANGEBYLEX": true, "ZRANGEBYSCORE": true,
+	"ZRANK": true, "ZREVRANGE": true, "ZREVRANGEBYLEX": true,
+	"ZREVRANGEBYSCORE": true, "ZREVRANK": true, "ZSCAN": true,
+	"ZSCORE": true,
+}
+
+func isReadOnlyAction(a Action) bool {
+	switch a := a.(type) {
+	case *cmdAction:
+		return readOnlyCmds[strings.ToUpper(a.cmd)]
+	case *functionAction:
+		return a.readOnly
+	default:
+		return false
 	}
-	return primAddr
 }
 
 type askConn struct {
@@ -511,22 +972,34 @@ const doAttempts = 5
 //
 // This method handles MOVED and ASK errors automatically in most cases, see
 // ClusterCanRetryAction's docs for more.
+//
+// Actions created by Pipeline or PipelineWithResults are handled specially:
+// their CmdActions may act on keys in different slots, in which case they are
+// split into a separate pipeline for each node, with the pipelines being
+// performed concurrently. CmdActions without keys are performed on the same
+// node as the CmdAction with keys preceding them. Each CmdAction which gets a
+// MOVED or ASK error is retried individually. For a Pipeline the returned error
+// is that of the first failed CmdAction, in the order they were given, though
+// unlike on a single Conn the following CmdActions will still have been
+// performed. Pipelines whose keys all belong to the same slot are performed on a
+// single Conn, as with any other Action.
 func (c *Cluster) Do(a Action) error {
-	var addr, key string
-	keys := a.Keys()
-	if len(keys) == 0 {
-		// that's ok, key will then just be ""
-	} else if err := assertKeysSlot(keys); err != nil {
+	return c.DoContext(context.Background(), a)
+}
+
+// DoContext implements the method for the ContextClient interface. It is like
+// Do, but the Action, along with any retries of it due to MOVED or ASK errors,
+// is bound by the given Context.
+func (c *Cluster) DoContext(ctx context.Context, a Action) error {
+	if ok, err := c.doPipelineAction(ctx, a); ok {
 		return err
-	} else {
-		key = keys[0]
-		addr = c.addrForKey(key)
 	}
-
-	return c.doInner(a, addr, key, false, doAttempts)
+	return c.doKey(ctx, a, c.co.readFromSecondaries && isReadOnlyAction(a))
 }
 
-// DoSecondary is like Do but executes the Action on a random secondary for the affected keys.
+// DoSecondary is like Do but executes the Action on a secondary for the affected
+// keys, chosen as per the ClusterSecondaryPolicy (see
+// ClusterReadFromSecondaries). By default a random secondary is chosen.
 //
 // For DoSecondary to work, all connections must be created in read-only mode, by using a
 // custom ClusterPoolFunc that executes the READONLY command on each new connection.
@@ -535,18 +1008,52 @@ func (c *Cluster) Do(a Action) error {
 //
 // If the Action can not be handled by a secondary the Action will be send to the primary instead.
 func (c *Cluster) DoSecondary(a Action) error {
+	return c.doKey(context.Background(), a, true)
+}
+
+func (c *Cluster) doKey(ctx context.Context, a Action, secondary bool) error {
 	var addr, key string
 	keys := a.Keys()
 	if len(keys) == 0 {
 		// that's ok, key will then just be ""
 	} else if err := assertKeysSlot(keys); err != nil {
 		return err
+	} else if key = keys[0]; !secondary {
+		addr = c.addrForKey(key)
 	} else {
-		key = keys[0]
 		addr = c.secondaryAddrForKey(key)
 	}
 
-	return c.doInner(a, addr, key, false, doAttempts)
+	var err error
+	if secondary && c.co.hedgeDelay > 0 && addr != "" && hedgeable(a) {
+		err = c.doHedged(ctx, a, addr, key)
+	} else {
+		err = c.doInner(ctx, a, addr, key, false, doAttempts)
+	}
+	if err == nil || !secondary || !c.co.secondaryFallback || ctx.Err() != nil {
+		return err
+	} else if errors.As(err, new(resp2.Error)) {
+		return err
+	} else if primAddr := c.addrForKey(key); addr != "" && primAddr != addr {
+		return c.doInner(ctx, a, primAddr, key, false, doAttempts)
+	}
+	return err
+}
+
+// doHedged performs the Action on the node at addr, hedging it as per
+// ClusterHedgedReads.
+func (c *Cluster) doHedged(ctx context.Context, a Action, addr, key string) error {
+	doOn := func(addr string) func(Action) error {
+		return func(a Action) error {
+			return c.doInner(ctx, a, addr, key, false, doAttempts)
+		}
+	}
+
+	var second func(Action) error
+	if hedgeAddr := c.hedgeAddrForKey(key, addr); hedgeAddr != "" {
+		second = doOn(hedgeAddr)
+	}
+	return doHedged(ctx, a, c.co.hedgeDelay, doOn(addr), second)
 }
 
 func (c *Cluster) getClusterDownSince() int64 {
@@ -611,11 +1118,19 @@ func (c *Cluster) setClusterDown(down bool) (changed bool) {
 	if changed && c.co.ct.StateChange != nil {
 		c.co.ct.StateChange(trace.ClusterStateChange{IsDown: down})
 	}
+	if changed && down {
+		c.log(LogLevelWarn, "cluster is down")
+	} else if changed {
+		c.log(LogLevelInfo, "cluster is no longer down")
+	}
 
 	return changed
 }
 
 func (c *Cluster) traceRedirected(addr, key string, moved, ask bool, count int, final bool) {
+	if !final {
+		c.log(LogLevelDebug, "following redirect", "addr", addr, "moved", moved, "ask", ask, "count", count)
+	}
 	if c.co.ct.Redirected != nil {
 		c.co.ct.Redirected(trace.ClusterRedirected{
 			Addr:          addr,
@@ -628,13 +1143,20 @@ func (c *Cluster) traceRedirected(addr, key string, moved, ask bool, count int,
 	}
 }
 
-func (c *Cluster) doInner(a Action, addr, key string, ask bool, attempts int) error {
+func (c *Cluster) doInner(ctx context.Context, a Action, addr, key string, ask bool, attempts int) error {
 	if downSince := c.getClusterDownSince(); downSince > 0 && c.co.clusterDownWait > 0 {
 		// only wait when the last command was not too long, because
 		// otherwise the chance it high that the cluster already healed
 		elapsed := (time.Now().UnixNano() / 1000 / 1000) - downSince
 		if elapsed < int64(c.co.clusterDownWait/time.Millisecond) {
-			time.Sleep(c.co.clusterDownWait)
+			t := getTimer(c.co.clusterDownWait)
+			select {
+			case <-t.C:
+			case <-ctx.Done():
+				putTimer(t)
+				return ctx.Err()
+			}
+			putTimer(t)
 		}
 	}
 
@@ -655,7 +1177,7 @@ func (c *Cluster) doInner(a Action, addr, key string, ask bool, attempts int) er
 		})
 	}
 
-	err = p.Do(thisA)
+	err = c.doTryAgain(ctx, p, thisA, a)
 	if err == nil {
 		c.setClusterDown(false)
 		return nil
@@ -666,15 +1188,15 @@ func (c *Cluster) doInner(a Action, addr, key string, ask bool, attempts int) er
 	}
 	msg := err.Error()
 
-	clusterDown := strings.HasPrefix(msg, "CLUSTERDOWN ")
+	clusterDown := errors.Is(err, resp2.ErrClusterDown)
 	clusterDownChanged := c.setClusterDown(clusterDown)
 	if clusterDown && c.co.clusterDownWait > 0 && clusterDownChanged {
-		return c.doInner(a, addr, key, ask, 1)
+		return c.doInner(ctx, a, addr, key, ask, 1)
 	}
 
 	// if the error was a MOVED or ASK we can potentially retry
-	moved := strings.HasPrefix(msg, "MOVED ")
-	ask = strings.HasPrefix(msg, "ASK ")
+	moved := errors.Is(err, resp2.ErrMoved)
+	ask = errors.Is(err, resp2.ErrAsk)
 	if !moved && !ask {
 		return err
 	}
@@ -700,14 +1222,37 @@ func (c *Cluster) doInner(a Action, addr, key string, ask bool, attempts int) er
 	if len(msgParts) < 3 {
 		return errors.Errorf("malformed MOVED/ASK error %q", msg)
 	}
-	ogAddr, addr := addr, msgParts[2]
+	ogAddr, addr := addr, c.mapAddr(msgParts[2])
 
 	c.traceRedirected(ogAddr, key, moved, ask, doAttempts-attempts+1, attempts <= 1)
 	if attempts--; attempts <= 0 {
 		return errors.New("cluster action redirected too many times")
 	}
 
-	return c.doInner(a, addr, key, ask, attempts)
+	return c.doInner(ctx, a, addr, key, ask, attempts)
+}
+
+// doTryAgain performs thisA on p, performing it again if it fails with a
+// TRYAGAIN error as per ClusterOnTryAgainRetry. a is the original Action, which
+// thisA may be wrapping.
+func (c *Cluster) doTryAgain(ctx context.Context, p Client, thisA, a Action) error {
+	for attempt := 0; ; attempt++ {
+		err := DoContext(ctx, p, thisA)
+		if !errors.Is(err, resp2.ErrTryAgain) || attempt >= c.co.tryAgainAttempts {
+			return err
+		} else if ccra, ok := a.(ClusterCanRetryAction); !ok || !ccra.ClusterCanRetry() {
+			return err
+		}
+
+		t := getTimer(c.co.tryAgainDelay)
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			putTimer(t)
+			return ctx.Err()
+		}
+		putTimer(t)
+	}
 }
 
 // Close cleans up all goroutines spawned by Cluster and closes all of its
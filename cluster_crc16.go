@@ -55,10 +55,36 @@ func CRC16(buf []byte) uint16 {
 // ClusterSlot returns the slot number the key belongs to in any redis cluster,
 // taking into account key hash tags
 func ClusterSlot(key []byte) uint16 {
+	if tag, ok := clusterHashTag(key); ok {
+		key = tag
+	}
+	return CRC16(key) % numSlots
+}
+
+// ClusterKeySlot is like ClusterSlot, but takes the key as a string.
+func ClusterKeySlot(key string) uint16 {
+	return ClusterSlot([]byte(key))
+}
+
+// ClusterHashTag returns the hash tag of the given key, and true, if it has
+// one. The hash tag is the non-empty substring between the first "{" in the key
+// and the first "}" following it. If a key has a hash tag then only the hash
+// tag is used to determine the key's slot, so keys which share a hash tag
+// always belong to the same slot. For example, "{user:1}:name" and
+// "{user:1}:email" both have the hash tag "user:1".
+//
+// If the key has no hash tag then "", false is returned, and the whole key is
+// used to determine its slot.
+func ClusterHashTag(key string) (string, bool) {
+	tag, ok := clusterHashTag([]byte(key))
+	return string(tag), ok
+}
+
+func clusterHashTag(key []byte) ([]byte, bool) {
 	if start := bytes.Index(key, []byte("{")); start >= 0 {
 		if end := bytes.Index(key[start+1:], []byte("}")); end > 0 {
-			key = key[start+1 : start+1+end]
+			return key[start+1 : start+1+end], true
 		}
 	}
-	return CRC16(key) % numSlots
+	return nil, false
 }
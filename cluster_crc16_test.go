@@ -33,3 +33,24 @@ func TestClusterSlot(t *T) {
 	// if the braces are empty it should match the whole string
 	assert.Equal(t, rawClusterSlot("foo{}{bar}"), ClusterSlot([]byte(`foo{}{bar}`)))
 }
+
+func TestClusterHashTag(t *T) {
+	for _, test := range []struct {
+		key, tag string
+		ok       bool
+	}{
+		{key: "foo"},
+		{key: "{user:1}:name", tag: "user:1", ok: true},
+		{key: "name:{user:1}", tag: "user:1", ok: true},
+		{key: "{user:1}}", tag: "user:1", ok: true},
+		{key: "foo{}{bar}"},
+		{key: "foo{"},
+	} {
+		tag, ok := ClusterHashTag(test.key)
+		assert.Equal(t, test.tag, tag, "key:%q", test.key)
+		assert.Equal(t, test.ok, ok, "key:%q", test.key)
+	}
+
+	assert.Equal(t, ClusterKeySlot("{user:1}:name"), ClusterKeySlot("{user:1}:email"))
+	assert.Equal(t, ClusterSlot([]byte("user:1")), ClusterKeySlot("{user:1}:name"))
+}
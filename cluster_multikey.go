@@ -0,0 +1,146 @@
+package radix
+
+import (
+	"reflect"
+
+	errors "golang.org/x/xerrors"
+)
+
+// multiKeyAction is a pipeline of CmdActions, each acting on the keys of a
+// single slot, whose results are combined by merge once they've all been
+// performed.
+type multiKeyAction struct {
+	pipeline
+	merge func() error
+}
+
+func (a multiKeyAction) Run(c Conn) error {
+	if err := a.pipeline.Run(c); err != nil {
+		return err
+	}
+	return a.merge()
+}
+
+// groupKeysBySlot returns the indices of the given keys grouped by the slot of
+// each key, with groups ordered by the first appearance of their slot.
+func groupKeysBySlot(keys []string) [][]int {
+	groupsBySlot := map[uint16]int{}
+	var groups [][]int
+	for i, key := range keys {
+		slot := ClusterSlot([]byte(key))
+		gi, ok := groupsBySlot[slot]
+		if !ok {
+			gi = len(groups)
+			groupsBySlot[slot] = gi
+			groups = append(groups, nil)
+		}
+		groups[gi] = append(groups[gi], i)
+	}
+	return groups
+}
+
+// ClusterMGet returns an Action which performs MGET on the given keys, which may
+// belong to any number of slots. A separate MGET is performed for the keys of
+// each slot, and when performed on a Cluster these are performed concurrently
+// on their respective nodes, as with Pipeline. The values are then written to
+// rcv in the same order as keys.
+//
+// rcv must be a pointer to a slice, e.g. *[]string, whose elements each value
+// will be decoded into as with Cmd. rcv may also be nil, in which case the
+// values are discarded.
+func ClusterMGet(rcv interface{}, keys ...string) Action {
+	groups := groupKeysBySlot(keys)
+	cmds := make([]CmdAction, len(groups))
+	if rcv == nil {
+		for gi, is := range groups {
+			cmds[gi] = Cmd(nil, "MGET", keysAt(keys, is)...)
+		}
+		return multiKeyAction{pipeline: cmds, merge: func() error { return nil }}
+	}
+
+	sliceT := reflect.TypeOf(rcv).Elem()
+	outs := make([]reflect.Value, len(groups))
+	for gi, is := range groups {
+		outs[gi] = reflect.New(sliceT)
+		cmds[gi] = Cmd(outs[gi].Interface(), "MGET", keysAt(keys, is)...)
+	}
+
+	return multiKeyAction{pipeline: cmds, merge: func() error {
+		res := reflect.MakeSlice(sliceT, len(keys), len(keys))
+		for gi, is := range groups {
+			out := outs[gi].Elem()
+			if out.Len() != len(is) {
+				return errors.Errorf("MGET returned %d values, expected %d", out.Len(), len(is))
+			}
+			for j, i := range is {
+				res.Index(i).Set(out.Index(j))
+			}
+		}
+		reflect.ValueOf(rcv).Elem().Set(res)
+		return nil
+	}}
+}
+
+// ClusterMSet returns an Action which performs MSET using the given key/value
+// pairs, whose keys may belong to any number of slots. A separate MSET is
+// performed for the keys of each slot, and when performed on a Cluster these
+// are performed concurrently on their respective nodes, as with Pipeline.
+//
+// NOTE that unlike a single MSET, the keys are not all set atomically. If an
+// error is returned then the keys of some slots may have been set, while others
+// were not.
+func ClusterMSet(kvs ...string) Action {
+	if len(kvs)%2 != 0 {
+		return multiKeyAction{merge: func() error {
+			return errors.Errorf("ClusterMSet given %d arguments, expected key/value pairs", len(kvs))
+		}}
+	}
+
+	keys := make([]string, len(kvs)/2)
+	for i := range keys {
+		keys[i] = kvs[i*2]
+	}
+
+	groups := groupKeysBySlot(keys)
+	cmds := make([]CmdAction, len(groups))
+	for gi, is := range groups {
+		args := make([]string, 0, len(is)*2)
+		for _, i := range is {
+			args = append(args, kvs[i*2], kvs[i*2+1])
+		}
+		cmds[gi] = Cmd(nil, "MSET", args...)
+	}
+	return multiKeyAction{pipeline: cmds, merge: func() error { return nil }}
+}
+
+// ClusterDel returns an Action which performs DEL on the given keys, which may
+// belong to any number of slots. A separate DEL is performed for the keys of
+// each slot, and when performed on a Cluster these are performed concurrently
+// on their respective nodes, as with Pipeline. The total number of keys deleted
+// is written to rcv, if it's not nil.
+func ClusterDel(rcv *int, keys ...string) Action {
+	groups := groupKeysBySlot(keys)
+	cmds := make([]CmdAction, len(groups))
+	counts := make([]int, len(groups))
+	for gi, is := range groups {
+		cmds[gi] = Cmd(&counts[gi], "DEL", keysAt(keys, is)...)
+	}
+
+	return multiKeyAction{pipeline: cmds, merge: func() error {
+		if rcv != nil {
+			*rcv = 0
+			for _, n := range counts {
+				*rcv += n
+			}
+		}
+		return nil
+	}}
+}
+
+func keysAt(keys []string, is []int) []string {
+	out := make([]string, len(is))
+	for j, i := range is {
+		out[j] = keys[i]
+	}
+	return out
+}
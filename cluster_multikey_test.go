@@ -0,0 +1,54 @@
+package radix
+
+import (
+	. "testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClusterMultiKey(t *T) {
+	c, _ := newTestCluster()
+	defer c.Close()
+
+	keys := []string{
+		clusterSlotKeys[16000], clusterSlotKeys[0], clusterSlotKeys[8000],
+		clusterSlotKeys[0] + "{" + clusterSlotKeys[0] + "}",
+	}
+	vals := []string{randStr(), randStr(), randStr(), randStr()}
+
+	kvs := make([]string, 0, len(keys)*2)
+	for i := range keys {
+		kvs = append(kvs, keys[i], vals[i])
+	}
+	require.Nil(t, c.Do(ClusterMSet(kvs...)))
+
+	var got []string
+	require.Nil(t, c.Do(ClusterMGet(&got, keys...)))
+	assert.Equal(t, vals, got)
+
+	var n int
+	require.Nil(t, c.Do(ClusterDel(&n, keys[1], keys[2], keys[3])))
+	assert.Equal(t, 3, n)
+
+	require.Nil(t, c.Do(ClusterMGet(&got, keys...)))
+	assert.Equal(t, []string{vals[0], "", "", ""}, got)
+
+	assert.Error(t, c.Do(ClusterMSet("foo")))
+}
+
+func TestClusterMultiKeyConn(t *T) {
+	c := dial()
+	defer c.Close()
+
+	k1, k2, v1, v2 := randStr(), randStr(), randStr(), randStr()
+	require.Nil(t, c.Do(ClusterMSet(k1, v1, k2, v2)))
+
+	var got [][]byte
+	require.Nil(t, c.Do(ClusterMGet(&got, k2, randStr(), k1)))
+	assert.Equal(t, [][]byte{[]byte(v2), nil, []byte(v1)}, got)
+
+	var n int
+	require.Nil(t, c.Do(ClusterDel(&n, k1, k2, randStr())))
+	assert.Equal(t, 2, n)
+}
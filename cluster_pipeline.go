@@ -0,0 +1,168 @@
+package radix
+
+import (
+	"context"
+	"sync"
+
+	errors "golang.org/x/xerrors"
+
+	"github.com/mediocregopher/radix/v3/resp/resp2"
+)
+
+// doPipelineAction performs the Action if it's a Pipeline, PipelineWithResults,
+// or one of the multi-key Actions such as ClusterMGet, returning false if it's
+// none of those.
+//
+// Pipelines whose keys all belong to the same slot are left to be performed
+// as a single Action, so that they're performed on a single Conn, which e.g.
+// MULTI/EXEC within them depends on.
+func (c *Cluster) doPipelineAction(ctx context.Context, a Action) (bool, error) {
+	switch p := a.(type) {
+	case pipeline:
+		if assertKeysSlot(p.Keys()) == nil {
+			return false, nil
+		}
+		res, _ := c.doPipeline(ctx, p)
+		return true, res.Err()
+	case pipelineWithResults:
+		if assertKeysSlot(p.Keys()) == nil {
+			return false, nil
+		}
+		var err error
+		*p.res, err = c.doPipeline(ctx, p.pipeline)
+		return true, err
+	case multiKeyAction:
+		res, _ := c.doPipeline(ctx, p.pipeline)
+		if err := res.Err(); err != nil {
+			return true, err
+		}
+		return true, p.merge()
+	default:
+		return false, nil
+	}
+}
+
+// doPipeline performs the given CmdActions by splitting them into a separate
+// pipeline for each node which owns any of their keys, and performing those
+// concurrently. CmdActions without keys are performed on the same node as the
+// CmdAction with keys preceding them, or following them if there's none
+// preceding. Read-only CmdActions are performed on secondaries if
+// ClusterReadFromSecondaries is used.
+//
+// CmdActions which get a MOVED or ASK error are then retried individually. The
+// returned error is the first one which affected a whole pipeline rather than a
+// single CmdAction, if any.
+func (c *Cluster) doPipeline(ctx context.Context, cmds []CmdAction) (PipelineResults, error) {
+	res := newPipelineResults(cmds)
+	addrs := make([]string, len(cmds))
+	secondary := make([]bool, len(cmds))
+	var firstAddr string
+	for i, cmd := range cmds {
+		keys := cmd.Keys()
+		if len(keys) == 0 {
+			continue
+		} else if err := assertKeysSlot(keys); err != nil {
+			res[i].Err = err
+			continue
+		}
+
+		secondary[i] = c.co.readFromSecondaries && isReadOnlyAction(cmd)
+		if secondary[i] {
+			addrs[i] = c.secondaryAddrForKey(keys[0])
+		} else {
+			addrs[i] = c.addrForKey(keys[0])
+		}
+		if firstAddr == "" {
+			firstAddr = addrs[i]
+		}
+	}
+
+	batches := map[string][]int{}
+	prevAddr := firstAddr
+	for i, cmd := range cmds {
+		if res[i].Err != nil {
+			continue
+		} else if len(cmd.Keys()) == 0 {
+			addrs[i] = prevAddr
+		}
+		prevAddr = addrs[i]
+		batches[addrs[i]] = append(batches[addrs[i]], i)
+	}
+
+	var (
+		wg       sync.WaitGroup
+		errL     sync.Mutex
+		firstErr error
+	)
+	for addr, is := range batches {
+		wg.Add(1)
+		go func(addr string, is []int) {
+			defer wg.Done()
+			// each batch only writes to its own indices of res
+			if err := c.doPipelineBatch(ctx, addr, cmds, is, res); err != nil {
+				errL.Lock()
+				if firstErr == nil {
+					firstErr = err
+				}
+				errL.Unlock()
+			}
+		}(addr, is)
+	}
+	wg.Wait()
+
+	var redirected []int
+	var moved bool
+	for i := range res {
+		if errors.Is(res[i].Err, resp2.ErrMoved) {
+			redirected = append(redirected, i)
+			moved = true
+		} else if errors.Is(res[i].Err, resp2.ErrAsk) {
+			redirected = append(redirected, i)
+		}
+	}
+
+	// a single Sync is enough for all CmdActions which got a MOVED. If it
+	// fails then doKey will handle each MOVED again itself.
+	if moved {
+		if err := c.Sync(); err != nil {
+			c.syncErr(err)
+		}
+	}
+	for _, i := range redirected {
+		res[i].Err = c.doKey(ctx, cmds[i], secondary[i])
+	}
+
+	return res, firstErr
+}
+
+// doPipelineBatch performs the CmdActions at the given indices of cmds as a
+// single pipeline on the node at addr, writing their errors into res.
+func (c *Cluster) doPipelineBatch(ctx context.Context, addr string, cmds []CmdAction, is []int, res PipelineResults) error {
+	setErr := func(err error) error {
+		for _, i := range is {
+			res[i].Err = err
+		}
+		return err
+	}
+
+	p, err := c.pool(addr)
+	if err != nil {
+		return setErr(err)
+	}
+
+	batch := make([]CmdAction, len(is))
+	for j, i := range is {
+		batch[j] = cmds[i]
+	}
+
+	var batchRes PipelineResults
+	err = DoContext(ctx, p, PipelineWithResults(&batchRes, batch...))
+	if len(batchRes) == 0 {
+		// the pipeline was never run
+		return setErr(err)
+	}
+	for j, i := range is {
+		res[i].Err = batchRes[j].Err
+	}
+	return err
+}
@@ -0,0 +1,104 @@
+package radix
+
+import (
+	. "testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mediocregopher/radix/v3/resp/resp2"
+	"github.com/mediocregopher/radix/v3/trace"
+)
+
+func TestClusterPipeline(t *T) {
+	var redirects []trace.ClusterRedirected
+	c, scl := newTestCluster(ClusterWithTrace(trace.ClusterTrace{
+		Redirected: func(r trace.ClusterRedirected) { redirects = append(redirects, r) },
+	}))
+	defer c.Close()
+	stub0, stub16k := scl.stubForSlot(0), scl.stubForSlot(16000)
+	require.NotEqual(t, stub0.addr, stub16k.addr)
+
+	k0, k16k := clusterSlotKeys[0], clusterSlotKeys[16000]
+	v0, v16k := randStr(), randStr()
+	require.Nil(t, c.Do(Pipeline(
+		Cmd(nil, "SET", k0, v0),
+		Cmd(nil, "SET", k16k, v16k),
+	)))
+
+	assertGet := func(t *T) {
+		var got0, got16k string
+		require.Nil(t, c.Do(Pipeline(
+			Cmd(&got16k, "GET", k16k),
+			Cmd(&got0, "GET", k0),
+		)))
+		assert.Equal(t, v0, got0)
+		assert.Equal(t, v16k, got16k)
+	}
+	assertGet(t)
+	assert.Empty(t, redirects)
+
+	t.Run("Keyless", func(t *T) {
+		// CmdActions without keys go to the node of the CmdAction preceding
+		// them, or following them if there's none preceding
+		var addr0, addr1, addr2 string
+		require.Nil(t, c.Do(Pipeline(
+			Cmd(&addr0, "ADDR"),
+			Cmd(nil, "GET", k16k),
+			Cmd(&addr1, "ADDR"),
+			Cmd(nil, "GET", k0),
+			Cmd(&addr2, "ADDR"),
+		)))
+		assert.Equal(t, stub16k.addr, addr0)
+		assert.Equal(t, stub16k.addr, addr1)
+		assert.Equal(t, stub0.addr, addr2)
+
+		// a pipeline within a single slot is performed on a single Conn
+		var addr, got0 string
+		require.Nil(t, c.Do(Pipeline(
+			Cmd(&addr, "ADDR"),
+			Cmd(&got0, "GET", k0),
+		)))
+		assert.Equal(t, stub0.addr, addr)
+		assert.Equal(t, v0, got0)
+	})
+
+	t.Run("Ask", func(t *T) {
+		redirects = nil
+		scl.migrateInit(stub16k.addr, 0)
+		scl.migrateKey(k0)
+		assertGet(t)
+		require.Len(t, redirects, 1)
+		assert.True(t, redirects[0].Ask)
+		assert.Equal(t, k0, redirects[0].Key)
+	})
+
+	t.Run("Moved", func(t *T) {
+		// finish the migration without syncing, so that the cluster still
+		// thinks the key is on stub0
+		redirects = nil
+		scl.migrateAllKeys(0)
+		scl.migrateDone(0)
+		assertGet(t)
+		require.Len(t, redirects, 0)
+		assert.Equal(t, stub16k.addr, c.addrForKey(k0))
+	})
+
+	t.Run("Results", func(t *T) {
+		var got0 int
+		var res PipelineResults
+		require.Nil(t, c.Do(PipelineWithResults(&res,
+			Cmd(&got0, "GET", k0),
+			Cmd(nil, "HGET", k16k, "foo"),
+			Cmd(nil, "SET", k0, v16k),
+		)))
+		require.Len(t, res, 3)
+		assert.Error(t, res[0].Err)
+		assert.IsType(t, resp2.Error{}, res[1].Err)
+		assert.Nil(t, res[2].Err)
+
+		var got string
+		require.Nil(t, c.Do(Cmd(&got, "GET", k0)))
+		assert.Equal(t, v16k, got)
+	})
+}
@@ -0,0 +1,355 @@
+package radix
+
+import (
+	"sync"
+	"time"
+
+	errors "golang.org/x/xerrors"
+)
+
+// shardedPubSubConn is a ShardedPubSubConn to a single cluster member, as used
+// by ClusterShardedPubSub.
+type shardedPubSubConn struct {
+	ShardedPubSubConn
+
+	// closeErrCh is closed once the PubSubConn has been closed for any reason
+	closeErrCh chan error
+}
+
+func (spc shardedPubSubConn) closed() bool {
+	select {
+	case <-spc.closeErrCh:
+		return true
+	default:
+		return false
+	}
+}
+
+// ClusterShardedPubSub subscribes to shard channels, as added in redis 7, across
+// a redis cluster. Each shard channel is subscribed to on the primary instance
+// which owns the channel's slot, using a separate connection to each primary
+// as needed. See Cluster.ShardedPubSub.
+//
+// When the cluster's topology changes, or redis unsubscribes a connection from
+// a shard channel because its slot has been migrated, the affected channels
+// are re-subscribed to on their new owners. Messages published while a channel
+// is being moved may be lost.
+//
+// Messages published to shard channels can be sent with SPUBLISH using the
+// Cluster's Do method, which routes the command by the channel's slot.
+type ClusterShardedPubSub struct {
+	c  *Cluster
+	cf ConnFunc
+
+	l      sync.Mutex
+	subs   chanSet
+	conns  map[string]shardedPubSubConn
+	owners map[string]string // channel -> addr it's currently subscribed on
+
+	// droppedL guards dropped, which holds the channels which redis has
+	// unsubscribed a connection from. It's separate from l since it's written
+	// to from within the PubSubConns.
+	droppedL sync.Mutex
+	dropped  map[string]bool
+
+	reconcileCh chan struct{}
+	closeCh     chan struct{}
+	closeOnce   sync.Once
+	wg          sync.WaitGroup
+
+	// Any errors encountered internally will be written to this channel. If
+	// nothing is reading the channel the errors will be dropped. The channel
+	// will be closed when the Close method is called.
+	ErrCh chan error
+}
+
+// ShardedPubSub returns a ClusterShardedPubSub which subscribes to shard
+// channels on the Cluster's members, making connections to them using the given
+// ConnFunc. If cf is nil then DefaultConnFunc is used.
+//
+// The ClusterShardedPubSub checks that its subscriptions are on the correct
+// instances whenever the Cluster's topology changes, and also at the interval
+// given by ClusterSyncEvery, at which time any failed subscriptions are
+// retried. It should be closed before the Cluster is.
+func (c *Cluster) ShardedPubSub(cf ConnFunc) *ClusterShardedPubSub {
+	if cf == nil {
+		cf = DefaultConnFunc
+	}
+
+	sp := &ClusterShardedPubSub{
+		c:           c,
+		cf:          cf,
+		subs:        chanSet{},
+		conns:       map[string]shardedPubSubConn{},
+		owners:      map[string]string{},
+		dropped:     map[string]bool{},
+		reconcileCh: make(chan struct{}, 1),
+		closeCh:     make(chan struct{}),
+		ErrCh:       make(chan error, 1),
+	}
+
+	c.l.Lock()
+	if c.shardedPubSubs == nil {
+		c.shardedPubSubs = map[*ClusterShardedPubSub]bool{}
+	}
+	c.shardedPubSubs[sp] = true
+	c.l.Unlock()
+
+	sp.wg.Add(1)
+	go func() {
+		defer sp.wg.Done()
+		sp.spin()
+	}()
+	return sp
+}
+
+func (sp *ClusterShardedPubSub) err(err error) {
+	select {
+	case sp.ErrCh <- err:
+	default:
+	}
+}
+
+// triggerReconcile causes reconcile to be called in the background. It never
+// blocks, and so is safe to call from within the PubSubConns and Cluster.
+func (sp *ClusterShardedPubSub) triggerReconcile() {
+	select {
+	case sp.reconcileCh <- struct{}{}:
+	default:
+	}
+}
+
+func (sp *ClusterShardedPubSub) spin() {
+	t := time.NewTicker(sp.c.co.syncEvery)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+		case <-sp.reconcileCh:
+		case <-sp.closeCh:
+			return
+		}
+		sp.reconcile()
+	}
+}
+
+// conn returns the PubSubConn for the given addr, creating it if necessary.
+// l must be held.
+func (sp *ClusterShardedPubSub) conn(addr string) (shardedPubSubConn, error) {
+	if spc, ok := sp.conns[addr]; ok && !spc.closed() {
+		return spc, nil
+	}
+
+	c, err := sp.cf("tcp", addr)
+	if err != nil {
+		return shardedPubSubConn{}, err
+	}
+
+	errCh := make(chan error, 1)
+	spc := shardedPubSubConn{
+		ShardedPubSubConn: newPubSub(c, errCh, nil, func(channel string) {
+			sp.droppedL.Lock()
+			sp.dropped[channel] = true
+			sp.droppedL.Unlock()
+			sp.triggerReconcile()
+		}),
+		closeErrCh: errCh,
+	}
+
+	sp.wg.Add(1)
+	go func() {
+		defer sp.wg.Done()
+		select {
+		case <-errCh:
+			sp.triggerReconcile()
+		case <-sp.closeCh:
+		}
+	}()
+
+	sp.conns[addr] = spc
+	return spc, nil
+}
+
+// subscribe subscribes every msgCh for the channel on the instance which owns
+// it, if it isn't already. l must be held.
+func (sp *ClusterShardedPubSub) subscribe(channel string) error {
+	addr := sp.c.addrForKey(channel)
+	if addr == "" {
+		return errors.Errorf("no known cluster node owns shard channel %q", channel)
+	} else if sp.owners[channel] == addr {
+		return nil
+	}
+
+	sp.unsubscribe(channel)
+	spc, err := sp.conn(addr)
+	if err != nil {
+		return err
+	}
+	for msgCh := range sp.subs[channel] {
+		if err := spc.SSubscribe(msgCh, channel); err != nil {
+			return err
+		}
+	}
+	sp.owners[channel] = addr
+	return nil
+}
+
+// unsubscribe unsubscribes every msgCh for the channel from the instance it's
+// currently subscribed on, if any. l must be held.
+func (sp *ClusterShardedPubSub) unsubscribe(channel string) {
+	addr, ok := sp.owners[channel]
+	if !ok {
+		return
+	}
+	delete(sp.owners, channel)
+
+	spc, ok := sp.conns[addr]
+	if !ok || spc.closed() {
+		return
+	}
+	for msgCh := range sp.subs[channel] {
+		// an error means the connection is closed, in which case the channel
+		// is unsubscribed from anyway
+		spc.SUnsubscribe(msgCh, channel)
+	}
+}
+
+// reconcile makes sure that every subscribed channel is subscribed to on the
+// instance which currently owns it, and closes connections which are no longer
+// needed.
+func (sp *ClusterShardedPubSub) reconcile() {
+	sp.droppedL.Lock()
+	dropped := sp.dropped
+	sp.dropped = map[string]bool{}
+	sp.droppedL.Unlock()
+
+	sp.l.Lock()
+	defer sp.l.Unlock()
+
+	var needSync bool
+	for addr, spc := range sp.conns {
+		if spc.closed() {
+			delete(sp.conns, addr)
+			needSync = true
+		}
+	}
+	for channel, addr := range sp.owners {
+		if _, ok := sp.conns[addr]; !ok || dropped[channel] {
+			delete(sp.owners, channel)
+			needSync = true
+		}
+	}
+
+	// if a connection was lost or a channel was dropped then the topology has
+	// likely changed, and the Cluster might not know yet
+	if needSync {
+		if err := sp.c.Sync(); err != nil {
+			sp.err(err)
+		}
+	}
+
+	for channel := range sp.subs {
+		if err := sp.subscribe(channel); err != nil {
+			sp.err(err)
+		}
+	}
+
+	// close connections which no longer have any channels subscribed on them
+	inUse := map[string]bool{}
+	for _, addr := range sp.owners {
+		inUse[addr] = true
+	}
+	for addr, spc := range sp.conns {
+		if !inUse[addr] {
+			spc.Close()
+			delete(sp.conns, addr)
+		}
+	}
+}
+
+// SSubscribe subscribes msgCh to the given shard channels, which may belong to
+// different slots. msgCh will receive a PubSubMessage with Type "smessage" for
+// every SPUBLISH to any of the channels.
+//
+// If an error is returned then the subscription is still recorded, and will be
+// retried in the background.
+func (sp *ClusterShardedPubSub) SSubscribe(msgCh chan<- PubSubMessage, channels ...string) error {
+	sp.l.Lock()
+	defer sp.l.Unlock()
+
+	var firstErr error
+	for _, channel := range channels {
+		sp.subs.add(channel, msgCh)
+
+		var err error
+		if addr, ok := sp.owners[channel]; ok {
+			// the channel is already subscribed to for other msgChs
+			if spc, ok := sp.conns[addr]; ok {
+				err = spc.SSubscribe(msgCh, channel)
+			}
+		} else {
+			err = sp.subscribe(channel)
+		}
+
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	if firstErr != nil {
+		sp.triggerReconcile()
+	}
+	return firstErr
+}
+
+// SUnsubscribe unsubscribes msgCh from the given shard channels, if it was
+// subscribed at all.
+//
+// NOTE even if msgCh is not subscribed to any other shard channels, it should
+// still be considered "active", and therefore still be having messages read
+// from it, until SUnsubscribe has returned
+func (sp *ClusterShardedPubSub) SUnsubscribe(msgCh chan<- PubSubMessage, channels ...string) error {
+	sp.l.Lock()
+	defer sp.l.Unlock()
+
+	for _, channel := range channels {
+		if addr, ok := sp.owners[channel]; ok {
+			if spc, ok := sp.conns[addr]; ok && !spc.closed() {
+				spc.SUnsubscribe(msgCh, channel)
+			}
+		}
+		if empty := sp.subs.del(channel, msgCh); empty {
+			delete(sp.owners, channel)
+		}
+	}
+	return nil
+}
+
+// Close closes all connections made by the ClusterShardedPubSub. Subscribed
+// msgChs will stop receiving messages, but will not themselves be closed.
+//
+// NOTE all msgChs should be considered "active", and therefore still be having
+// messages read from them, until Close has returned.
+func (sp *ClusterShardedPubSub) Close() error {
+	closeErr := errClientClosed
+	sp.closeOnce.Do(func() {
+		sp.c.l.Lock()
+		delete(sp.c.shardedPubSubs, sp)
+		sp.c.l.Unlock()
+
+		close(sp.closeCh)
+		sp.wg.Wait()
+		close(sp.ErrCh)
+
+		sp.l.Lock()
+		defer sp.l.Unlock()
+		closeErr = nil
+		for _, spc := range sp.conns {
+			if err := spc.Close(); err != nil && closeErr == nil {
+				closeErr = err
+			}
+		}
+		sp.conns = nil
+	})
+	return closeErr
+}
@@ -0,0 +1,78 @@
+package radix
+
+import (
+	"sync"
+	. "testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClusterShardedPubSub(t *T) {
+	c, scl := newTestCluster()
+	defer c.Close()
+
+	// stubChs holds the message channel of the most recent PubSubStub created
+	// for each addr
+	var stubChsL sync.Mutex
+	stubChs := map[string]chan<- PubSubMessage{}
+	sp := c.ShardedPubSub(func(network, addr string) (Conn, error) {
+		conn, stubCh := PubSubStub(network, addr, func([]string) interface{} {
+			return nil
+		})
+		stubChsL.Lock()
+		stubChs[addr] = stubCh
+		stubChsL.Unlock()
+		return conn, nil
+	})
+	defer sp.Close()
+
+	smessage := func(addr, channel, val string) {
+		stubChsL.Lock()
+		stubCh := stubChs[addr]
+		stubChsL.Unlock()
+		require.NotNil(t, stubCh, "no conn to %q", addr)
+		stubCh <- PubSubMessage{Type: "smessage", Channel: channel, Message: []byte(val)}
+	}
+
+	owner := func(channel string) string {
+		sp.l.Lock()
+		defer sp.l.Unlock()
+		return sp.owners[channel]
+	}
+
+	channel := clusterSlotKeys[0]
+	srcStub, dstStub := scl.stubForSlot(0), scl.stubForSlot(16000)
+
+	msgCh := make(chan PubSubMessage, 1)
+	require.Nil(t, sp.SSubscribe(msgCh, channel))
+	assert.Equal(t, srcStub.addr, owner(channel))
+
+	smessage(srcStub.addr, channel, "a")
+	assert.Equal(t, PubSubMessage{
+		Type:    "smessage",
+		Channel: channel,
+		Message: []byte("a"),
+	}, assertMsgRead(t, msgCh))
+
+	// moving the channel's slot should cause the channel to be resubscribed to
+	// on the new owner once the Cluster notices
+	slotRange := srcStub.slotRanges()[0]
+	scl.migrateSlotRange(dstStub.addr, slotRange[0], slotRange[1])
+	require.Nil(t, c.Sync())
+
+	deadline := time.Now().Add(5 * time.Second)
+	for owner(channel) != dstStub.addr {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for shard channel to be moved")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	smessage(dstStub.addr, channel, "b")
+	assert.Equal(t, "b", string(assertMsgRead(t, msgCh).Message))
+
+	require.Nil(t, sp.SUnsubscribe(msgCh, channel))
+	assert.Empty(t, owner(channel))
+}
@@ -1,6 +1,8 @@
 package radix
 
 import (
+	"crypto/sha1"
+	"encoding/hex"
 	"fmt"
 	"sort"
 	"strconv"
@@ -53,7 +55,8 @@ func (sd *clusterDatasetStub) slotRanges() [][2]uint16 {
 // equivalent to a single redis instance
 type clusterNodeStub struct {
 	addr, id                       string
-	secondaryOfAddr, secondaryOfID string // set if secondary
+	secondaryOfAddr, secondaryOfID string   // set if secondary
+	scripts                        []string // scripts loaded with SCRIPT LOAD
 	*clusterDatasetStub
 	*clusterStub
 }
@@ -165,6 +168,30 @@ func (s *clusterNodeStub) newConn() Conn {
 				slot.kv[k] = args[2]
 				return resp2.SimpleString{S: "OK"}
 			})
+		case "MSET":
+			kvs := args[1:]
+			ks := make([]string, 0, len(kvs)/2)
+			for i := 0; i < len(kvs); i += 2 {
+				ks = append(ks, kvs[i])
+			}
+			return s.withKeys(ks, asking, readonly, func(slot clusterSlotStub) interface{} {
+				for i := 0; i+1 < len(kvs); i += 2 {
+					slot.kv[kvs[i]] = kvs[i+1]
+				}
+				return resp2.SimpleString{S: "OK"}
+			})
+		case "DEL":
+			ks := args[1:]
+			return s.withKeys(ks, asking, readonly, func(slot clusterSlotStub) interface{} {
+				var n int
+				for _, k := range ks {
+					if _, ok := slot.kv[k]; ok {
+						delete(slot.kv, k)
+						n++
+					}
+				}
+				return n
+			})
 		case "EVALSHA":
 			return resp2.Error{E: errors.New("NOSCRIPT: clusterNodeStub does not support EVALSHA")}
 		case "EVAL":
@@ -181,6 +208,15 @@ func (s *clusterNodeStub) newConn() Conn {
 			return s.withKey(args[3], asking, readonly, func(slot clusterSlotStub) interface{} {
 				return "EVAL: success!"
 			})
+		case "SCRIPT":
+			if strings.ToUpper(args[1]) != "LOAD" {
+				break
+			}
+			s.clusterDatasetStub.Lock()
+			s.scripts = append(s.scripts, args[2])
+			s.clusterDatasetStub.Unlock()
+			sum := sha1.Sum([]byte(args[2]))
+			return hex.EncodeToString(sum[:])
 		case "PING":
 			return resp2.SimpleString{S: "PONG"}
 		case "CLUSTER":
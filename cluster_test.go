@@ -1,12 +1,17 @@
 package radix
 
 import (
+	"context"
+	"io"
+	"strings"
 	. "testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	errors "golang.org/x/xerrors"
 
+	"github.com/mediocregopher/radix/v3/resp/resp2"
 	"github.com/mediocregopher/radix/v3/trace"
 )
 
@@ -84,6 +89,99 @@ func TestClusterSync(t *T) {
 	}
 }
 
+func TestClusterOnTopoChange(t *T) {
+	type change struct{ prev, cur ClusterTopo }
+	var changes []change
+	c, scl := newTestCluster(ClusterOnTopoChange(func(prev, cur ClusterTopo) {
+		changes = append(changes, change{prev, cur})
+	}))
+	defer c.Close()
+
+	require.Len(t, changes, 1)
+	assert.Empty(t, changes[0].prev)
+	assert.Equal(t, scl.topo(), changes[0].cur)
+
+	// syncing without the topology changing shouldn't call the callback
+	require.Nil(t, c.Sync())
+	require.Len(t, changes, 1)
+
+	prevTopo := scl.topo()
+	srcStub, dstStub := scl.stubForSlot(0), scl.stubForSlot(16000)
+	slotRange := srcStub.slotRanges()[0]
+	scl.migrateSlotRange(dstStub.addr, slotRange[0], slotRange[1])
+
+	require.Nil(t, c.Sync())
+	require.Len(t, changes, 2)
+	assert.Equal(t, prevTopo, changes[1].prev)
+	assert.Equal(t, scl.topo(), changes[1].cur)
+	assert.NotEqual(t, changes[1].prev, changes[1].cur)
+}
+
+func TestClusterLogger(t *T) {
+	var l testLogger
+	c, scl := newTestCluster(ClusterLogger(&l))
+	defer c.Close()
+
+	entries := l.withMsg("topology changed")
+	require.Len(t, entries, 1)
+	assert.Equal(t, LogLevelInfo, entries[0].level)
+	assert.ElementsMatch(t, scl.addrs(), entries[0].keyvals[1])
+
+	srcStub, dstStub := scl.stubForSlot(0), scl.stubForSlot(16000)
+	slotRange := srcStub.slotRanges()[0]
+	scl.migrateSlotRange(dstStub.addr, slotRange[0], slotRange[1])
+	require.Nil(t, c.Sync())
+
+	entries = l.withMsg("topology changed")
+	require.Len(t, entries, 2)
+	// src no longer has any slots, and so it and its secondary are removed
+	assert.Empty(t, entries[1].keyvals[1])
+	assert.Contains(t, entries[1].keyvals[3], srcStub.addr)
+	assert.Contains(t, entries[1].keyvals[5], dstStub.addr)
+}
+
+func TestClusterNodeForKey(t *T) {
+	c, scl := newTestCluster()
+	defer c.Close()
+
+	for _, slot := range []uint16{0, 8000, 16000} {
+		node, ok := c.NodeForKey(clusterSlotKeys[slot])
+		require.True(t, ok)
+		assert.Equal(t, scl.stubForSlot(slot).addr, node.Addr)
+		assert.Empty(t, node.SecondaryOfAddr)
+	}
+}
+
+func TestClusterAddrMapper(t *T) {
+	scl := newStubCluster(testTopo)
+	mapAddr := func(addr string) string {
+		return strings.Replace(addr, "10.128.", "192.168.", 1)
+	}
+	pf := scl.clientFunc()
+	c := scl.newCluster(
+		ClusterAddrMapper(mapAddr),
+		ClusterPoolFunc(func(network, addr string) (Client, error) {
+			return pf(network, strings.Replace(addr, "192.168.", "10.128.", 1))
+		}),
+	)
+	defer c.Close()
+
+	for _, node := range c.Topo() {
+		assert.True(t, strings.HasPrefix(node.Addr, "192.168."), "addr:%q", node.Addr)
+		if node.SecondaryOfAddr != "" {
+			assert.True(t, strings.HasPrefix(node.SecondaryOfAddr, "192.168."), "addr:%q", node.SecondaryOfAddr)
+		}
+	}
+
+	// a MOVED from the wrong node should be followed to the mapped address
+	k, v := clusterSlotKeys[0], randStr()
+	require.Nil(t, c.Do(Cmd(nil, "SET", k, v)))
+	var out string
+	wrongAddr := mapAddr(scl.stubForSlot(16000).addr)
+	require.Nil(t, c.doInner(context.Background(), Cmd(&out, "GET", k), wrongAddr, k, false, doAttempts))
+	assert.Equal(t, v, out)
+}
+
 func TestClusterGet(t *T) {
 	c, _ := newTestCluster()
 	defer c.Close()
@@ -119,7 +217,7 @@ func TestClusterDo(t *T) {
 	{
 		var vgot string
 		cmd := Cmd(&vgot, "GET", k)
-		require.Nil(t, c.doInner(cmd, stub16k.addr, k, false, doAttempts))
+		require.Nil(t, c.doInner(context.Background(), cmd, stub16k.addr, k, false, doAttempts))
 		assert.Equal(t, v, vgot)
 		assert.Equal(t, trace.ClusterRedirected{
 			Addr:          stub16k.addr,
@@ -158,6 +256,41 @@ func TestClusterDo(t *T) {
 	}
 }
 
+func TestClusterDoTryAgain(t *T) {
+	c, scl := newTestCluster(ClusterOnTryAgainRetry(50, 10*time.Millisecond))
+	defer c.Close()
+	stub16k := scl.stubForSlot(16000)
+
+	k1 := clusterSlotKeys[0]
+	k2 := "{" + k1 + "}" + randStr()
+	v1, v2 := randStr(), randStr()
+	require.Nil(t, c.Do(Cmd(nil, "SET", k1, v1)))
+	require.Nil(t, c.Do(Cmd(nil, "SET", k2, v2)))
+
+	// with only one of the keys migrated the MGET will be redirected to the
+	// importing node, which returns TRYAGAIN until the migration is done
+	scl.migrateInit(stub16k.addr, 0)
+	scl.migrateKey(k1)
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		scl.migrateAllKeys(0)
+		scl.migrateDone(0)
+	}()
+
+	var out []string
+	require.Nil(t, c.Do(Cmd(&out, "MGET", k1, k2)))
+	assert.Equal(t, []string{v1, v2}, out)
+
+	// with retries disabled the error is returned
+	c2, scl2 := newTestCluster(ClusterOnTryAgainRetry(0, 0))
+	defer c2.Close()
+	require.Nil(t, c2.Do(Cmd(nil, "SET", k1, v1)))
+	scl2.migrateInit(scl2.stubForSlot(16000).addr, 0)
+	scl2.migrateKey(k1)
+	err := c2.Do(Cmd(&out, "MGET", k1, k2))
+	assert.True(t, errors.Is(err, resp2.ErrTryAgain), "err:%v", err)
+}
+
 func TestClusterDoWhenDown(t *T) {
 	var stub *clusterNodeStub
 
@@ -274,6 +407,142 @@ func TestClusterDoSecondary(t *T) {
 	assert.Equal(t, 2, redirects)
 }
 
+// brokenClient is a Client which always fails with a network error.
+type brokenClient struct{}
+
+func (brokenClient) Do(Action) error { return io.EOF }
+func (brokenClient) Close() error    { return nil }
+
+func TestClusterReadFromSecondaries(t *T) {
+	tt := ClusterTopo{
+		{Addr: "10.0.0.1:6379", ID: "1", Slots: [][2]uint16{{0, numSlots}}},
+		{Addr: "10.0.0.2:6379", ID: "2", Slots: [][2]uint16{{0, numSlots}},
+			SecondaryOfAddr: "10.0.0.1:6379", SecondaryOfID: "1"},
+		{Addr: "10.0.0.3:6379", ID: "3", Slots: [][2]uint16{{0, numSlots}},
+			SecondaryOfAddr: "10.0.0.1:6379", SecondaryOfID: "1"},
+	}
+	newCluster := func(opts ...ClusterOpt) (*Cluster, *int, *bool) {
+		var redirects int
+		var broken bool
+		scl := newStubCluster(tt)
+		cf := scl.clientFunc()
+		opts = append(opts,
+			// connections aren't put into READONLY mode, so a secondary will
+			// redirect any command sent to it
+			ClusterPoolFunc(func(network, addr string) (Client, error) {
+				if broken && addr != "10.0.0.1:6379" {
+					return brokenClient{}, nil
+				}
+				return cf(network, addr)
+			}),
+			ClusterWithTrace(trace.ClusterTrace{
+				Redirected: func(trace.ClusterRedirected) { redirects++ },
+			}),
+		)
+		return scl.newCluster(opts...), &redirects, &broken
+	}
+	key := clusterSlotKeys[0]
+
+	t.Run("Do", func(t *T) {
+		c, redirects, _ := newCluster(ClusterReadFromSecondaries(ClusterSecondaryRandom))
+		defer c.Close()
+
+		require.Nil(t, c.Do(Cmd(nil, "SET", key, "foo")))
+		assert.Equal(t, 0, *redirects)
+
+		var out string
+		require.Nil(t, c.Do(Cmd(&out, "GET", key)))
+		assert.Equal(t, "foo", out)
+		assert.Equal(t, 1, *redirects)
+	})
+
+	t.Run("PoolFuncBefore", func(t *T) {
+		// a ClusterPoolFunc given before ClusterReadFromSecondaries isn't
+		// replaced by it
+		c := newStubCluster(tt).newCluster(ClusterReadFromSecondaries(ClusterSecondaryRandom))
+		defer c.Close()
+
+		var out string
+		require.Nil(t, c.Do(Cmd(nil, "SET", key, "foo")))
+		require.Nil(t, c.Do(Cmd(&out, "GET", key)))
+		assert.Equal(t, "foo", out)
+	})
+
+	t.Run("RoundRobin", func(t *T) {
+		c, _, _ := newCluster(ClusterReadFromSecondaries(ClusterSecondaryRoundRobin))
+		defer c.Close()
+
+		counts := map[string]int{}
+		for i := 0; i < 4; i++ {
+			counts[c.secondaryAddrForKey(key)]++
+		}
+		assert.Equal(t, map[string]int{"10.0.0.2:6379": 2, "10.0.0.3:6379": 2}, counts)
+	})
+
+	t.Run("LowestLatency", func(t *T) {
+		c, _, _ := newCluster(ClusterReadFromSecondaries(ClusterSecondaryLowestLatency))
+		defer c.Close()
+
+		c.l.RLock()
+		assert.Len(t, c.latencies, 2)
+		c.l.RUnlock()
+
+		setLatencies := func(l map[string]time.Duration) {
+			c.l.Lock()
+			defer c.l.Unlock()
+			c.latencies = l
+		}
+
+		setLatencies(map[string]time.Duration{"10.0.0.2:6379": 2, "10.0.0.3:6379": 1})
+		assert.Equal(t, "10.0.0.3:6379", c.secondaryAddrForKey(key))
+		setLatencies(map[string]time.Duration{"10.0.0.2:6379": 1, "10.0.0.3:6379": 2})
+		assert.Equal(t, "10.0.0.2:6379", c.secondaryAddrForKey(key))
+		setLatencies(map[string]time.Duration{"10.0.0.3:6379": 2})
+		assert.Equal(t, "10.0.0.3:6379", c.secondaryAddrForKey(key))
+	})
+
+	t.Run("FallbackToPrimary", func(t *T) {
+		c, redirects, broken := newCluster(ClusterSecondaryFallbackToPrimary())
+		defer c.Close()
+		require.Nil(t, c.Do(Cmd(nil, "SET", key, "foo")))
+
+		// drop the pools to the secondaries, so they're recreated as broken
+		*broken = true
+		c.l.Lock()
+		for addr := range c.pools {
+			if addr != "10.0.0.1:6379" {
+				delete(c.pools, addr)
+			}
+		}
+		c.l.Unlock()
+
+		var out string
+		require.Nil(t, c.DoSecondary(Cmd(&out, "GET", key)))
+		assert.Equal(t, "foo", out)
+		assert.Equal(t, 0, *redirects)
+	})
+
+	t.Run("HedgedReads", func(t *T) {
+		c, _, _ := newCluster(
+			ClusterReadFromSecondaries(ClusterSecondaryRandom),
+			// the stub cluster doesn't support concurrent commands, so the
+			// hedged reads themselves are tested in TestDoHedged
+			ClusterHedgedReads(time.Hour),
+		)
+		defer c.Close()
+
+		assert.Equal(t, "10.0.0.3:6379", c.hedgeAddrForKey(key, "10.0.0.2:6379"))
+		assert.Equal(t, "10.0.0.2:6379", c.hedgeAddrForKey(key, "10.0.0.3:6379"))
+
+		require.Nil(t, c.Do(Cmd(nil, "SET", key, "foo")))
+		for i := 0; i < 10; i++ {
+			var out string
+			require.Nil(t, c.Do(Cmd(&out, "GET", key)))
+			assert.Equal(t, "foo", out)
+		}
+	})
+}
+
 var clusterAddrs []string
 
 func ExampleClusterPoolFunc_defaultClusterConnFunc() {
@@ -5,6 +5,8 @@ import (
 	"io"
 	"net"
 	"sort"
+	"strconv"
+	"strings"
 
 	errors "golang.org/x/xerrors"
 
@@ -21,6 +23,30 @@ type ClusterNode struct {
 	Slots [][2]uint16
 	// address and id this node is the secondary of, if it's a secondary
 	SecondaryOfAddr, SecondaryOfID string
+
+	// The following are only filled in when the ClusterTopo was parsed from the
+	// output of CLUSTER NODES, see ParseClusterNodes.
+
+	// Flags are the comma separated flags of the node, e.g.
+	// "myself,master". Possible flags include "myself", "master", "slave",
+	// "fail?", "fail", "handshake" and "noaddr". See HasFlag.
+	Flags string
+	// LinkState is the state of the link to the node from the node which
+	// CLUSTER NODES was run on, either "connected" or "disconnected".
+	LinkState string
+	// ConfigEpoch is the configuration epoch of the node, or of its primary if
+	// it's a secondary.
+	ConfigEpoch uint64
+}
+
+// HasFlag returns true if the given flag is one of the node's Flags.
+func (n ClusterNode) HasFlag(flag string) bool {
+	for _, f := range strings.Split(n.Flags, ",") {
+		if f == flag {
+			return true
+		}
+	}
+	return false
 }
 
 // ClusterTopo describes the cluster topology at a given moment. It will be
@@ -63,10 +89,26 @@ func (tt ClusterTopo) MarshalRESP(w io.Writer) error {
 	return nil
 }
 
-// UnmarshalRESP implements the resp.Unmarshaler interface, but only supports
-// unmarshaling the return from CLUSTER SLOTS. The unmarshaled nodes will be
-// sorted before they are returned
+// UnmarshalRESP implements the resp.Unmarshaler interface, and supports
+// unmarshaling the return from either CLUSTER SLOTS or CLUSTER NODES. The
+// unmarshaled nodes will be sorted before they are returned
 func (tt *ClusterTopo) UnmarshalRESP(br *bufio.Reader) error {
+	if b, err := br.Peek(1); err != nil {
+		return err
+	} else if b[0] != resp2.ArrayPrefix[0] {
+		// CLUSTER NODES returns a string rather than an array
+		var s string
+		if err := (resp2.Any{I: &s}).UnmarshalRESP(br); err != nil {
+			return err
+		}
+		nodesTT, err := ParseClusterNodes(s)
+		if err != nil {
+			return err
+		}
+		*tt = append(*tt, nodesTT...)
+		return nil
+	}
+
 	var arrHead resp2.ArrayHeader
 	if err := arrHead.UnmarshalRESP(br); err != nil {
 		return err
@@ -107,6 +149,15 @@ func (tt ClusterTopo) sort() {
 	}
 
 	sort.Slice(tt, func(i, j int) bool {
+		// nodes without any slots, which are only possible when parsing CLUSTER
+		// NODES, come last and are sorted by address
+		if len(tt[i].Slots) == 0 || len(tt[j].Slots) == 0 {
+			if len(tt[i].Slots) != len(tt[j].Slots) {
+				return len(tt[j].Slots) == 0
+			}
+			return tt[i].Addr < tt[j].Addr
+		}
+
 		if tt[i].Slots[0] != tt[j].Slots[0] {
 			return tt[i].Slots[0][0] < tt[j].Slots[0][0]
 		}
@@ -138,6 +189,95 @@ func (tt ClusterTopo) Primaries() ClusterTopo {
 	return mtt
 }
 
+// ParseClusterNodes parses the output of CLUSTER NODES, as described by:
+//	https://redis.io/commands/cluster-nodes
+//
+// Every node in the output is returned, including ones which have failed or
+// which don't serve any slots. Secondaries are given the same Slots as their
+// primary, as they would be with CLUSTER SLOTS. Slots which are being migrated
+// are ignored. The returned ClusterTopo is sorted in the same way as when
+// unmarshaling CLUSTER SLOTS, with nodes that don't have any slots last.
+func ParseClusterNodes(s string) (ClusterTopo, error) {
+	var tt ClusterTopo
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) < 8 {
+			return nil, errors.Errorf("malformed CLUSTER NODES line: %q", line)
+		}
+
+		// the address may be followed by the cluster bus port and the
+		// node's hostname, e.g. "127.0.0.1:7000@17000,host"
+		addr := fields[1]
+		if i := strings.IndexAny(addr, "@,"); i >= 0 {
+			addr = addr[:i]
+		}
+
+		configEpoch, err := strconv.ParseUint(fields[6], 10, 64)
+		if err != nil {
+			return nil, errors.Errorf("malformed config epoch in CLUSTER NODES line %q: %w", line, err)
+		}
+
+		node := ClusterNode{
+			Addr:        addr,
+			ID:          fields[0],
+			Flags:       fields[2],
+			LinkState:   fields[7],
+			ConfigEpoch: configEpoch,
+		}
+		if fields[3] != "-" {
+			node.SecondaryOfID = fields[3]
+		}
+
+		for _, slotStr := range fields[8:] {
+			// slots being imported or migrated look like "[1234->-id]" or
+			// "[1234-<-id]"
+			if strings.HasPrefix(slotStr, "[") {
+				continue
+			}
+
+			startStr, endStr := slotStr, slotStr
+			if i := strings.IndexByte(slotStr, '-'); i >= 0 {
+				startStr, endStr = slotStr[:i], slotStr[i+1:]
+			}
+			start, err := strconv.ParseUint(startStr, 10, 16)
+			if err != nil {
+				return nil, errors.Errorf("malformed slot in CLUSTER NODES line %q: %w", line, err)
+			}
+			end, err := strconv.ParseUint(endStr, 10, 16)
+			if err != nil {
+				return nil, errors.Errorf("malformed slot in CLUSTER NODES line %q: %w", line, err)
+			}
+			node.Slots = append(node.Slots, [2]uint16{uint16(start), uint16(end) + 1})
+		}
+
+		tt = append(tt, node)
+	}
+
+	nodeIDM := make(map[string]ClusterNode, len(tt))
+	for _, node := range tt {
+		nodeIDM[node.ID] = node
+	}
+	for i, node := range tt {
+		if node.SecondaryOfID == "" {
+			continue
+		}
+		primary, ok := nodeIDM[node.SecondaryOfID]
+		if !ok {
+			return nil, errors.Errorf("node %q is secondary of unknown node %q", node.ID, node.SecondaryOfID)
+		}
+		tt[i].SecondaryOfAddr = primary.Addr
+		tt[i].Slots = append([][2]uint16(nil), primary.Slots...)
+	}
+
+	tt.sort()
+	return tt, nil
+}
+
 // we only use this type during unmarshalling, the topo Unmarshal method will
 // convert these into ClusterNodes
 type topoSlotSet struct {
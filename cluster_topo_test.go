@@ -197,3 +197,62 @@ func TestClusterTopoSplitSlots(t *T) {
 	}
 
 }
+
+func TestParseClusterNodes(t *T) {
+	clusterNodes := "" +
+		"90900dd4ef2182825bc853c448737b2ba9975a50 127.0.0.1:7001@17001 master - 0 1426238317239 2 connected 0 8192-16383\n" +
+		"073a013f8886b6cf4c1b018612102601534912e9 127.0.0.1:7011@17011,host-11 slave 90900dd4ef2182825bc853c448737b2ba9975a50 0 1426238316232 2 connected\n" +
+		"3ff1ddc420cfceeb4c42dc4b1f8f85c3acf984fe 127.0.0.1:7000@17000 myself,master - 0 0 1 connected 1-8191 [8191->-90900dd4ef2182825bc853c448737b2ba9975a50]\n" +
+		"6ec23923021cf3ffec47632106199cb7f496ce01 127.0.0.1:7005@17005 master,fail - 1426238316232 1426238315000 0 disconnected\n"
+
+	expTopo := ClusterTopo{
+		ClusterNode{
+			Slots: [][2]uint16{{0, 1}, {8192, 16384}},
+			Addr:  "127.0.0.1:7001", ID: "90900dd4ef2182825bc853c448737b2ba9975a50",
+			Flags:       "master",
+			LinkState:   "connected",
+			ConfigEpoch: 2,
+		},
+		ClusterNode{
+			Slots: [][2]uint16{{0, 1}, {8192, 16384}},
+			Addr:  "127.0.0.1:7011", ID: "073a013f8886b6cf4c1b018612102601534912e9",
+			SecondaryOfAddr: "127.0.0.1:7001",
+			SecondaryOfID:   "90900dd4ef2182825bc853c448737b2ba9975a50",
+			Flags:           "slave",
+			LinkState:       "connected",
+			ConfigEpoch:     2,
+		},
+		ClusterNode{
+			Slots: [][2]uint16{{1, 8192}},
+			Addr:  "127.0.0.1:7000", ID: "3ff1ddc420cfceeb4c42dc4b1f8f85c3acf984fe",
+			Flags:       "myself,master",
+			LinkState:   "connected",
+			ConfigEpoch: 1,
+		},
+		ClusterNode{
+			Addr: "127.0.0.1:7005", ID: "6ec23923021cf3ffec47632106199cb7f496ce01",
+			Flags:     "master,fail",
+			LinkState: "disconnected",
+		},
+	}
+
+	topo, err := ParseClusterNodes(clusterNodes)
+	require.Nil(t, err)
+	assert.Equal(t, expTopo, topo)
+	assert.True(t, topo[2].HasFlag("myself"))
+	assert.False(t, topo[0].HasFlag("myself"))
+
+	// CLUSTER NODES replies with a bulk string, which UnmarshalRESP should
+	// also handle
+	buf := new(bytes.Buffer)
+	require.Nil(t, (resp2.BulkString{S: clusterNodes}).MarshalRESP(buf))
+	var topo2 ClusterTopo
+	require.Nil(t, topo2.UnmarshalRESP(bufio.NewReader(buf)))
+	assert.Equal(t, expTopo, topo2)
+
+	_, err = ParseClusterNodes("foo bar baz\n")
+	assert.NotNil(t, err)
+
+	_, err = ParseClusterNodes("073a013f8886b6cf4c1b018612102601534912e9 127.0.0.1:7011@17011 slave 90900dd4ef2182825bc853c448737b2ba9975a50 0 0 2 connected\n")
+	assert.NotNil(t, err)
+}
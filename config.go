@@ -0,0 +1,207 @@
+package radix
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+
+	errors "golang.org/x/xerrors"
+)
+
+// Config holds configuration parameters, as returned by CONFIG GET, mapping
+// each parameter's name to its raw value. Its methods can be used to parse the
+// values of specific parameters, or Decode can be used to parse many into a
+// struct. See ConfigGetCmd.
+type Config map[string]string
+
+// ConfigGetCmd returns a CmdAction which performs CONFIG GET with the given
+// glob-style patterns, writing all matching parameters into rcv. Giving more
+// than one pattern requires redis 7.0 or above.
+func ConfigGetCmd(rcv *Config, patterns ...string) CmdAction {
+	return Cmd((*map[string]string)(rcv), "CONFIG", append([]string{"GET"}, patterns...)...)
+}
+
+// ConfigRewriteCmd returns a CmdAction which performs CONFIG REWRITE, causing
+// redis to write its current configuration to its config file.
+func ConfigRewriteCmd() CmdAction {
+	return Cmd(nil, "CONFIG", "REWRITE")
+}
+
+// ParseConfigSize parses a memory size as used by redis configuration
+// parameters, e.g. "100", "1k", or "2gb". As with redis, the suffixes k, m, and
+// g are multiples of 1000, while kb, mb, and gb are multiples of 1024, and are
+// case-insensitive.
+func ParseConfigSize(s string) (int64, error) {
+	lower := strings.ToLower(s)
+	mul := int64(1)
+	for _, unit := range []struct {
+		suffix string
+		mul    int64
+	}{
+		{"kb", 1 << 10}, {"mb", 1 << 20}, {"gb", 1 << 30},
+		{"k", 1e3}, {"m", 1e6}, {"g", 1e9}, {"b", 1},
+	} {
+		if strings.HasSuffix(lower, unit.suffix) {
+			lower, mul = strings.TrimSuffix(lower, unit.suffix), unit.mul
+			break
+		}
+	}
+
+	n, err := strconv.ParseInt(lower, 10, 64)
+	if err != nil {
+		return 0, errors.Errorf("invalid size %q", s)
+	}
+	return n * mul, nil
+}
+
+func (c Config) get(name string) (string, error) {
+	v, ok := c[name]
+	if !ok {
+		return "", errors.Errorf("config parameter %q not found", name)
+	}
+	return v, nil
+}
+
+// Int parses the value of the given parameter as an integer, which may be a
+// memory size as accepted by ParseConfigSize.
+func (c Config) Int(name string) (int64, error) {
+	v, err := c.get(name)
+	if err != nil {
+		return 0, err
+	}
+	n, err := ParseConfigSize(v)
+	if err != nil {
+		return 0, errors.Errorf("parsing config parameter %q: %w", name, err)
+	}
+	return n, nil
+}
+
+// Bool parses the value of the given parameter as a boolean, which redis
+// represents as "yes" or "no".
+func (c Config) Bool(name string) (bool, error) {
+	v, err := c.get(name)
+	if err != nil {
+		return false, err
+	}
+	switch strings.ToLower(v) {
+	case "yes":
+		return true, nil
+	case "no":
+		return false, nil
+	default:
+		return false, errors.Errorf("parsing config parameter %q: invalid boolean %q", name, v)
+	}
+}
+
+// List parses the value of the given parameter as a space separated list, as
+// used by parameters such as "save" or "client-output-buffer-limit".
+func (c Config) List(name string) ([]string, error) {
+	v, err := c.get(name)
+	if err != nil {
+		return nil, err
+	}
+	return strings.Fields(v), nil
+}
+
+// Decode sets the fields of the struct pointed to by into using the parameters
+// named by the fields' config tags, e.g.:
+//
+//	var cfg struct {
+//		MaxMemory       int64    `config:"maxmemory"`
+//		MaxMemoryPolicy string   `config:"maxmemory-policy"`
+//		AppendOnly      bool     `config:"appendonly"`
+//		Save            []string `config:"save"`
+//	}
+//
+// Fields may be strings, integers (parsed as with Int), bools (parsed as with
+// Bool), or string slices (parsed as with List). Fields whose parameters are
+// not in the Config are left alone.
+func (c Config) Decode(into interface{}) error {
+	vv := reflect.ValueOf(into)
+	if vv.Kind() != reflect.Ptr || vv.Elem().Kind() != reflect.Struct {
+		return errors.Errorf("can't decode Config into %T, must be a pointer to a struct", into)
+	}
+	vv = vv.Elem()
+	tt := vv.Type()
+
+	for i := 0; i < tt.NumField(); i++ {
+		name, ok := tt.Field(i).Tag.Lookup("config")
+		if !ok {
+			continue
+		} else if _, ok := c[name]; !ok {
+			continue
+		}
+
+		fv := vv.Field(i)
+		switch fv.Kind() {
+		case reflect.String:
+			fv.SetString(c[name])
+		case reflect.Int, reflect.Int64:
+			n, err := c.Int(name)
+			if err != nil {
+				return err
+			}
+			fv.SetInt(n)
+		case reflect.Bool:
+			b, err := c.Bool(name)
+			if err != nil {
+				return err
+			}
+			fv.SetBool(b)
+		case reflect.Slice:
+			if fv.Type().Elem().Kind() != reflect.String {
+				return errors.Errorf("unsupported config field type %s", fv.Type())
+			}
+			l, _ := c.List(name)
+			fv.Set(reflect.ValueOf(l).Convert(fv.Type()))
+		default:
+			return errors.Errorf("unsupported config field type %s", fv.Type())
+		}
+	}
+	return nil
+}
+
+type configSetAction struct {
+	args []string
+	err  error
+}
+
+func (a configSetAction) Keys() []string {
+	return nil
+}
+
+func (a configSetAction) Run(c Conn) error {
+	if a.err != nil {
+		return a.err
+	}
+	return c.Do(Cmd(nil, "CONFIG", append([]string{"SET"}, a.args...)...))
+}
+
+// ConfigSet returns an Action which performs CONFIG SET using the given
+// parameter/value pairs. Setting more than one parameter requires redis 7.0 or
+// above, in which case all of them are set atomically.
+//
+// The pairs are validated before anything is sent to redis: an error is
+// returned if there isn't an even number of arguments, or if a parameter name
+// is empty or given more than once.
+func ConfigSet(kvs ...string) Action {
+	a := configSetAction{args: kvs}
+	if len(kvs) == 0 || len(kvs)%2 != 0 {
+		a.err = errors.Errorf("ConfigSet given %d arguments, expected parameter/value pairs", len(kvs))
+		return a
+	}
+
+	seen := map[string]bool{}
+	for i := 0; i < len(kvs); i += 2 {
+		name := strings.ToLower(kvs[i])
+		if name == "" {
+			a.err = errors.New("ConfigSet given an empty parameter name")
+			return a
+		} else if seen[name] {
+			a.err = errors.Errorf("ConfigSet given parameter %q more than once", name)
+			return a
+		}
+		seen[name] = true
+	}
+	return a
+}
@@ -0,0 +1,96 @@
+package radix
+
+import (
+	. "testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseConfigSize(t *T) {
+	for s, exp := range map[string]int64{
+		"0":    0,
+		"-1":   -1,
+		"100":  100,
+		"100b": 100,
+		"1k":   1000,
+		"1kb":  1024,
+		"2m":   2000000,
+		"2MB":  2 << 20,
+		"3g":   3000000000,
+		"2gb":  2 << 30,
+	} {
+		n, err := ParseConfigSize(s)
+		require.Nil(t, err, "s:%q", s)
+		assert.Equal(t, exp, n, "s:%q", s)
+	}
+
+	for _, s := range []string{"", "gb", "1tb", "one"} {
+		_, err := ParseConfigSize(s)
+		assert.Error(t, err, "s:%q", s)
+	}
+}
+
+func TestConfig(t *T) {
+	var gotArgs [][]string
+	conn := Stub("tcp", "127.0.0.1:6379", func(args []string) interface{} {
+		gotArgs = append(gotArgs, args)
+		if args[1] == "GET" {
+			return []string{
+				"maxmemory", "2gb",
+				"maxmemory-policy", "allkeys-lru",
+				"appendonly", "yes",
+				"save", "3600 1 300 100",
+			}
+		}
+		return "OK"
+	})
+
+	var cfg Config
+	require.Nil(t, conn.Do(ConfigGetCmd(&cfg, "max*", "appendonly", "save")))
+
+	n, err := cfg.Int("maxmemory")
+	require.Nil(t, err)
+	assert.Equal(t, int64(2<<30), n)
+	b, err := cfg.Bool("appendonly")
+	require.Nil(t, err)
+	assert.True(t, b)
+	l, err := cfg.List("save")
+	require.Nil(t, err)
+	assert.Equal(t, []string{"3600", "1", "300", "100"}, l)
+
+	_, err = cfg.Int("maxmemory-policy")
+	assert.Error(t, err)
+	_, err = cfg.Bool("maxmemory-policy")
+	assert.Error(t, err)
+	_, err = cfg.Int("unknown")
+	assert.Error(t, err)
+
+	var into struct {
+		MaxMemory       int64    `config:"maxmemory"`
+		MaxMemoryPolicy string   `config:"maxmemory-policy"`
+		AppendOnly      bool     `config:"appendonly"`
+		Save            []string `config:"save"`
+		Unknown         int      `config:"unknown"`
+	}
+	require.Nil(t, cfg.Decode(&into))
+	assert.Equal(t, int64(2<<30), into.MaxMemory)
+	assert.Equal(t, "allkeys-lru", into.MaxMemoryPolicy)
+	assert.True(t, into.AppendOnly)
+	assert.Equal(t, []string{"3600", "1", "300", "100"}, into.Save)
+	assert.Equal(t, 0, into.Unknown)
+	assert.Error(t, cfg.Decode(into))
+
+	require.Nil(t, conn.Do(ConfigSet("maxmemory", "1gb", "appendonly", "no")))
+	require.Nil(t, conn.Do(ConfigRewriteCmd()))
+	assert.Error(t, conn.Do(ConfigSet()))
+	assert.Error(t, conn.Do(ConfigSet("maxmemory")))
+	assert.Error(t, conn.Do(ConfigSet("", "1")))
+	assert.Error(t, conn.Do(ConfigSet("maxmemory", "1", "MAXMEMORY", "2")))
+
+	assert.Equal(t, [][]string{
+		{"CONFIG", "GET", "max*", "appendonly", "save"},
+		{"CONFIG", "SET", "maxmemory", "1gb", "appendonly", "no"},
+		{"CONFIG", "REWRITE"},
+	}, gotArgs)
+}
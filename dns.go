@@ -0,0 +1,161 @@
+package radix
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	errors "golang.org/x/xerrors"
+)
+
+// dnsResolver describes the methods of net.Resolver used by dnsDiscovery, so
+// that tests can replace it.
+type dnsResolver interface {
+	LookupHost(ctx context.Context, host string) ([]string, error)
+	LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error)
+}
+
+var defaultDNSResolver dnsResolver = net.DefaultResolver
+
+// dnsLookupTimeout is the maximum time a single DNS lookup may take.
+const dnsLookupTimeout = 5 * time.Second
+
+// dnsDiscovery resolves a name into a set of addresses, either using the A
+// (and AAAA) records of the host of a "host:port" address, or the SRV records
+// of a name like "_redis._tcp.example.com". Resolved addresses are cached for
+// the given interval.
+type dnsDiscovery struct {
+	name     string
+	srv      bool
+	interval time.Duration
+	resolver dnsResolver
+
+	l          sync.Mutex
+	addrs      []string
+	resolvedAt time.Time
+	next       int
+}
+
+func newDNSDiscovery(name string, srv bool, interval time.Duration) *dnsDiscovery {
+	return &dnsDiscovery{
+		name:     name,
+		srv:      srv,
+		interval: interval,
+		resolver: defaultDNSResolver,
+	}
+}
+
+func (d *dnsDiscovery) resolve() ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), dnsLookupTimeout)
+	defer cancel()
+
+	var addrs []string
+	if d.srv {
+		_, srvs, err := d.resolver.LookupSRV(ctx, "", "", d.name)
+		if err != nil {
+			return nil, errors.Errorf("resolving SRV records of %q: %w", d.name, err)
+		}
+		for _, srv := range srvs {
+			host := strings.TrimSuffix(srv.Target, ".")
+			addrs = append(addrs, net.JoinHostPort(host, strconv.Itoa(int(srv.Port))))
+		}
+	} else {
+		host, port, err := net.SplitHostPort(d.name)
+		if err != nil {
+			return nil, err
+		}
+		ips, err := d.resolver.LookupHost(ctx, host)
+		if err != nil {
+			return nil, errors.Errorf("resolving %q: %w", host, err)
+		}
+		for _, ip := range ips {
+			addrs = append(addrs, net.JoinHostPort(ip, port))
+		}
+	}
+
+	if len(addrs) == 0 {
+		return nil, errors.Errorf("no addresses found for %q", d.name)
+	}
+	sort.Strings(addrs)
+	return addrs, nil
+}
+
+// Addrs returns the resolved addresses, resolving them again first if they
+// were last resolved longer than the interval ago. If resolving fails but
+// addresses were previously resolved then those are returned instead.
+func (d *dnsDiscovery) Addrs() ([]string, error) {
+	d.l.Lock()
+	defer d.l.Unlock()
+	return d.addrsLocked()
+}
+
+func (d *dnsDiscovery) addrsLocked() ([]string, error) {
+	if d.addrs != nil && time.Since(d.resolvedAt) < d.interval {
+		return d.addrs, nil
+	}
+
+	addrs, err := d.resolve()
+	if err != nil {
+		if d.addrs != nil {
+			return d.addrs, nil
+		}
+		return nil, err
+	}
+	d.addrs, d.resolvedAt = addrs, time.Now()
+	return d.addrs, nil
+}
+
+// NextAddr returns one of the resolved addresses, cycling through all of them
+// over subsequent calls.
+func (d *dnsDiscovery) NextAddr() (string, error) {
+	d.l.Lock()
+	defer d.l.Unlock()
+	addrs, err := d.addrsLocked()
+	if err != nil {
+		return "", err
+	}
+	addr := addrs[d.next%len(addrs)]
+	d.next++
+	return addr, nil
+}
+
+// Dial creates a Conn to the next of the resolved addresses, using the given
+// ConnFunc.
+func (d *dnsDiscovery) Dial(cf ConnFunc, network string) (Conn, error) {
+	addr, err := d.NextAddr()
+	if err != nil {
+		return nil, err
+	}
+	return cf(network, addr)
+}
+
+// DialWithOpts is like Dial, but creates the Conn using Dial with the given
+// DialOpts. If the addresses were resolved from the A records of a host, and
+// DialUseTLS is given without a ServerName, then the host is used as the
+// ServerName, so that the certificate is verified against it rather than the
+// resolved IP.
+func (d *dnsDiscovery) DialWithOpts(network string, opts []DialOpt) (Conn, error) {
+	addr, err := d.NextAddr()
+	if err != nil {
+		return nil, err
+	}
+
+	var do dialOpts
+	for _, opt := range opts {
+		opt(&do)
+	}
+	if !d.srv && do.useTLSConfig && (do.tlsConfig == nil || do.tlsConfig.ServerName == "") {
+		tlsConfig := new(tls.Config)
+		if do.tlsConfig != nil {
+			tlsConfig = do.tlsConfig.Clone()
+		}
+		tlsConfig.ServerName, _, _ = net.SplitHostPort(d.name)
+		opts = append(opts[:len(opts):len(opts)], DialUseTLS(tlsConfig))
+	}
+	return Dial(network, addr, opts...)
+}
@@ -0,0 +1,253 @@
+package radix
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	crand "crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"sync"
+	. "testing"
+	"time"
+
+	errors "golang.org/x/xerrors"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeDNSResolver struct {
+	l     sync.Mutex
+	hosts map[string][]string
+	srvs  map[string][]*net.SRV
+}
+
+func (r *fakeDNSResolver) LookupHost(_ context.Context, host string) ([]string, error) {
+	r.l.Lock()
+	defer r.l.Unlock()
+	if addrs, ok := r.hosts[host]; ok {
+		return addrs, nil
+	}
+	return nil, errors.Errorf("unknown host %q", host)
+}
+
+func (r *fakeDNSResolver) LookupSRV(_ context.Context, _, _, name string) (string, []*net.SRV, error) {
+	r.l.Lock()
+	defer r.l.Unlock()
+	if srvs, ok := r.srvs[name]; ok {
+		return name, srvs, nil
+	}
+	return "", nil, errors.Errorf("unknown SRV name %q", name)
+}
+
+func (r *fakeDNSResolver) setHosts(host string, addrs ...string) {
+	r.l.Lock()
+	defer r.l.Unlock()
+	r.hosts[host] = addrs
+}
+
+// withFakeDNSResolver sets the resolver used by any dnsDiscovery created while
+// the returned function has not been called.
+func withFakeDNSResolver() (*fakeDNSResolver, func()) {
+	r := &fakeDNSResolver{
+		hosts: map[string][]string{},
+		srvs:  map[string][]*net.SRV{},
+	}
+	prev := defaultDNSResolver
+	defaultDNSResolver = r
+	return r, func() { defaultDNSResolver = prev }
+}
+
+func TestDNSDiscovery(t *T) {
+	r, restore := withFakeDNSResolver()
+	defer restore()
+
+	r.setHosts("redis.test", "10.0.0.2", "10.0.0.1")
+	r.srvs["_redis._tcp.redis.test"] = []*net.SRV{
+		{Target: "b.redis.test.", Port: 6380},
+		{Target: "a.redis.test.", Port: 6379},
+	}
+
+	d := newDNSDiscovery("redis.test:6379", false, time.Hour)
+	addrs, err := d.Addrs()
+	require.Nil(t, err)
+	assert.Equal(t, []string{"10.0.0.1:6379", "10.0.0.2:6379"}, addrs)
+
+	// the cached addresses are used until the interval has elapsed
+	r.setHosts("redis.test", "10.0.0.3")
+	addrs, err = d.Addrs()
+	require.Nil(t, err)
+	assert.Equal(t, []string{"10.0.0.1:6379", "10.0.0.2:6379"}, addrs)
+
+	d.interval = 0
+	addrs, err = d.Addrs()
+	require.Nil(t, err)
+	assert.Equal(t, []string{"10.0.0.3:6379"}, addrs)
+
+	// if resolving fails the previous addresses are kept
+	r.setHosts("redis.test")
+	addrs, err = d.Addrs()
+	require.Nil(t, err)
+	assert.Equal(t, []string{"10.0.0.3:6379"}, addrs)
+
+	d = newDNSDiscovery("_redis._tcp.redis.test", true, time.Hour)
+	seen := map[string]bool{}
+	for i := 0; i < 4; i++ {
+		addr, err := d.NextAddr()
+		require.Nil(t, err)
+		seen[addr] = true
+	}
+	assert.Equal(t, map[string]bool{
+		"a.redis.test:6379": true,
+		"b.redis.test:6380": true,
+	}, seen)
+
+	_, err = newDNSDiscovery("unknown.test:6379", false, time.Hour).Addrs()
+	assert.Error(t, err)
+}
+
+func TestPoolDNSDiscovery(t *T) {
+	r, restore := withFakeDNSResolver()
+	defer restore()
+	r.setHosts("redis.test", "127.0.0.1", "127.0.0.2")
+
+	var l sync.Mutex
+	dialed := map[string]int{}
+	cf := func(network, addr string) (Conn, error) {
+		l.Lock()
+		dialed[addr]++
+		l.Unlock()
+		return Dial(network, "127.0.0.1:6379")
+	}
+
+	pool, err := NewPool("tcp", "redis.test:6379", 1,
+		PoolConnFunc(cf), PoolDNSDiscovery(time.Hour))
+	require.Nil(t, err)
+	defer pool.Close()
+	require.Nil(t, pool.Do(Cmd(nil, "PING")))
+
+	// the Pool's ConnFunc is what's used to create every new Conn
+	conn, err := pool.opts.cf("tcp", "redis.test:6379")
+	require.Nil(t, err)
+	conn.Close()
+
+	l.Lock()
+	defer l.Unlock()
+	assert.Contains(t, dialed, "127.0.0.1:6379")
+	assert.Contains(t, dialed, "127.0.0.2:6379")
+	assert.NotContains(t, dialed, "redis.test:6379")
+}
+
+// testTLSCert returns a self-signed certificate for the given host.
+func testTLSCert(t *T, host string) (tls.Certificate, *x509.CertPool) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), crand.Reader)
+	require.Nil(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: host},
+		DNSNames:     []string{host},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IsCA:         true,
+
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(crand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.Nil(t, err)
+	x509Cert, err := x509.ParseCertificate(der)
+	require.Nil(t, err)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(x509Cert)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, roots
+}
+
+func TestDNSDiscoveryDialTLS(t *T) {
+	cert, roots := testTLSCert(t, "redis.test")
+	l, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{cert}})
+	require.Nil(t, err)
+	defer l.Close()
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			conn.(*tls.Conn).Handshake()
+			conn.Close()
+		}
+	}()
+
+	r, restore := withFakeDNSResolver()
+	defer restore()
+	r.setHosts("redis.test", "127.0.0.1")
+	_, port, _ := net.SplitHostPort(l.Addr().String())
+
+	// the certificate is only valid for redis.test, not the resolved IP
+	dns := newDNSDiscovery(net.JoinHostPort("redis.test", port), false, time.Hour)
+	tlsConfig := &tls.Config{RootCAs: roots}
+	conn, err := dns.DialWithOpts("tcp", []DialOpt{DialUseTLS(tlsConfig)})
+	require.Nil(t, err)
+	conn.Close()
+	assert.Empty(t, tlsConfig.ServerName)
+
+	// the ConnFunc is given the resolved IP, which can't be verified
+	var dialedAddr string
+	_, err = dns.Dial(func(network, addr string) (Conn, error) {
+		dialedAddr = addr
+		return Dial(network, addr, DialUseTLS(tlsConfig))
+	}, "tcp")
+	assert.Error(t, err)
+	assert.Equal(t, l.Addr().String(), dialedAddr)
+
+	// NewPool given the DialOpts should do the same
+	pool, err := NewPool("tcp", net.JoinHostPort("redis.test", port), 1,
+		PoolDNSDiscovery(time.Hour, DialUseTLS(tlsConfig)))
+	require.Nil(t, err)
+	pool.Close()
+
+	// a ServerName which is set isn't overwritten
+	_, err = dns.DialWithOpts("tcp", []DialOpt{
+		DialUseTLS(&tls.Config{RootCAs: roots, ServerName: "other.test"}),
+	})
+	assert.Error(t, err)
+}
+
+func TestClusterDNSDiscovery(t *T) {
+	r, restore := withFakeDNSResolver()
+	defer restore()
+
+	scl := newStubCluster(testTopo)
+	var ips []string
+	for _, addr := range scl.addrs() {
+		host, _, _ := net.SplitHostPort(addr)
+		ips = append(ips, host)
+	}
+	r.setHosts("cluster.test", ips...)
+
+	c, err := NewCluster([]string{"cluster.test:6379"},
+		ClusterPoolFunc(scl.clientFunc()), ClusterDNSDiscovery(time.Millisecond))
+	require.Nil(t, err)
+	defer c.Close()
+	assert.Equal(t, scl.topo(), c.Topo())
+
+	// the newly resolved address isn't a node of the cluster
+	r.setHosts("cluster.test", "10.0.0.1")
+	time.Sleep(2 * time.Millisecond)
+	assert.Error(t, c.syncFromSeeds())
+
+	r.setHosts("cluster.test", ips[0])
+	time.Sleep(2 * time.Millisecond)
+	require.Nil(t, c.syncFromSeeds())
+	assert.Equal(t, scl.topo(), c.Topo())
+
+	_, err = NewCluster([]string{"unknown.test:6379"},
+		ClusterPoolFunc(scl.clientFunc()), ClusterDNSDiscovery(time.Millisecond))
+	assert.Error(t, err)
+}
@@ -0,0 +1,149 @@
+package radix
+
+import (
+	"strconv"
+	"time"
+)
+
+// ExpiryAuditOpts are options which can be passed into AuditExpiries.
+type ExpiryAuditOpts struct {
+	// Patterns are the patterns, as given to SCAN's MATCH option, of the keys
+	// which are audited. Each pattern is scanned separately, so a key matching
+	// multiple patterns is audited multiple times.
+	//
+	// The default, if Patterns is empty, is to audit all keys.
+	Patterns []string
+
+	// SetTTL, if greater than 0, is the expiry which is given to each key found
+	// without one, using PEXPIRE. It's rounded up to the nearest millisecond,
+	// so that keys aren't deleted outright by a PEXPIRE of 0.
+	SetTTL time.Duration
+
+	// OnNoExpiry, if set, is called with each key found without an expiry,
+	// before SetTTL is applied to it.
+	OnNoExpiry func(key string)
+
+	// BatchSize is the number of keys which are checked, and which SetTTL is
+	// applied to, at a time.
+	//
+	// The default, if BatchSize is 0, is 100.
+	BatchSize int
+
+	// BatchDelay is how long to wait in between batches, in order to limit
+	// the load which the audit puts on redis.
+	BatchDelay time.Duration
+}
+
+// ExpiryAudit describes the outcome of AuditExpiries.
+type ExpiryAudit struct {
+	// Scanned is the number of keys which were checked.
+	Scanned int
+
+	// NoExpiry is the number of keys which were found without an expiry.
+	NoExpiry int
+
+	// ExpirySet is the number of keys which SetTTL was applied to. This may be
+	// less than NoExpiry if keys were deleted in the meantime.
+	ExpirySet int
+}
+
+// scannerFor returns a Scanner for the given Client using the ScanOpts, using
+// the Client's NewScanner method if it's a Cluster.
+func scannerFor(c Client, o ScanOpts) Scanner {
+	if cl, ok := c.(*Cluster); ok {
+		return cl.NewScanner(o)
+	}
+	return NewScanner(c, o)
+}
+
+// AuditExpiries scans the keys matching the patterns given in the
+// ExpiryAuditOpts, and checks which of them have no expiry, using PTTL. Such
+// keys are reported via OnNoExpiry, and are optionally given an expiry (see
+// SetTTL). Keys without expiries are a common cause of redis running out of
+// memory.
+//
+// The Client may be a Cluster, in which case every primary is scanned.
+//
+// NOTE that if a key is given an expiry by another client in between it being
+// checked and SetTTL being applied to it then that expiry is overwritten. The
+// NX option of PEXPIRE, which would prevent this, isn't used since it requires
+// redis 7.0.
+func AuditExpiries(c Client, opts ExpiryAuditOpts) (ExpiryAudit, error) {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 100
+	}
+	patterns := opts.Patterns
+	if len(patterns) == 0 {
+		patterns = []string{""}
+	}
+	setTTL := strconv.FormatInt(int64((opts.SetTTL+time.Millisecond-1)/time.Millisecond), 10)
+
+	var audit ExpiryAudit
+	batch := make([]string, 0, opts.BatchSize)
+	auditBatch := func() error {
+		if len(batch) == 0 {
+			return nil
+		} else if audit.Scanned > 0 && opts.BatchDelay > 0 {
+			time.Sleep(opts.BatchDelay)
+		}
+
+		pttls := make([]int64, len(batch))
+		cmds := make([]CmdAction, len(batch))
+		for i, key := range batch {
+			cmds[i] = Cmd(&pttls[i], "PTTL", key)
+		}
+		if err := c.Do(multiKeyAction{pipeline: cmds, merge: func() error { return nil }}); err != nil {
+			return err
+		}
+		audit.Scanned += len(batch)
+
+		var noExpiry []string
+		for i, key := range batch {
+			if pttls[i] != -1 {
+				continue
+			}
+			noExpiry = append(noExpiry, key)
+			if opts.OnNoExpiry != nil {
+				opts.OnNoExpiry(key)
+			}
+		}
+		audit.NoExpiry += len(noExpiry)
+		batch = batch[:0]
+
+		if opts.SetTTL <= 0 || len(noExpiry) == 0 {
+			return nil
+		}
+
+		set := make([]int, len(noExpiry))
+		cmds = cmds[:0]
+		for i, key := range noExpiry {
+			cmds = append(cmds, Cmd(&set[i], "PEXPIRE", key, setTTL))
+		}
+		if err := c.Do(multiKeyAction{pipeline: cmds, merge: func() error { return nil }}); err != nil {
+			return err
+		}
+		for _, n := range set {
+			audit.ExpirySet += n
+		}
+		return nil
+	}
+
+	for _, pattern := range patterns {
+		s := scannerFor(c, ScanOpts{Command: "SCAN", Pattern: pattern, Count: opts.BatchSize})
+		var key string
+		for s.Next(&key) {
+			if batch = append(batch, key); len(batch) < opts.BatchSize {
+				continue
+			} else if err := auditBatch(); err != nil {
+				s.Close()
+				return audit, err
+			}
+		}
+		if err := s.Close(); err != nil {
+			return audit, err
+		} else if err := auditBatch(); err != nil {
+			return audit, err
+		}
+	}
+	return audit, nil
+}
@@ -0,0 +1,83 @@
+package radix
+
+import (
+	"sort"
+	. "testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	errors "golang.org/x/xerrors"
+)
+
+func TestAuditExpiries(t *T) {
+	c := dial()
+	defer c.Close()
+
+	prefixA, prefixB := randStr(), randStr()
+	var noExpiry []string
+	for i := 0; i < 3; i++ {
+		key := prefixA + ":" + randStr()
+		require.Nil(t, c.Do(Cmd(nil, "SET", key, "foo")))
+		noExpiry = append(noExpiry, key)
+	}
+	for i := 0; i < 2; i++ {
+		require.Nil(t, c.Do(Cmd(nil, "SET", prefixA+":"+randStr(), "foo", "EX", "100")))
+	}
+	bKey := prefixB + ":" + randStr()
+	require.Nil(t, c.Do(Cmd(nil, "SET", bKey, "foo")))
+	noExpiry = append(noExpiry, bKey)
+	sort.Strings(noExpiry)
+
+	var reported []string
+	opts := ExpiryAuditOpts{
+		Patterns:   []string{prefixA + ":*", prefixB + ":*"},
+		OnNoExpiry: func(key string) { reported = append(reported, key) },
+		BatchSize:  2,
+		BatchDelay: time.Millisecond,
+	}
+	audit, err := AuditExpiries(c, opts)
+	require.Nil(t, err)
+	assert.Equal(t, ExpiryAudit{Scanned: 6, NoExpiry: 4}, audit)
+	sort.Strings(reported)
+	assert.Equal(t, noExpiry, reported)
+
+	opts.SetTTL = 50 * time.Second
+	opts.OnNoExpiry = nil
+	audit, err = AuditExpiries(c, opts)
+	require.Nil(t, err)
+	assert.Equal(t, ExpiryAudit{Scanned: 6, NoExpiry: 4, ExpirySet: 4}, audit)
+
+	for _, key := range noExpiry {
+		var ttl int
+		require.Nil(t, c.Do(Cmd(&ttl, "TTL", key)))
+		assert.InDelta(t, 50, ttl, 5)
+	}
+
+	audit, err = AuditExpiries(c, opts)
+	require.Nil(t, err)
+	assert.Equal(t, ExpiryAudit{Scanned: 6}, audit)
+}
+
+func TestAuditExpiriesSubMillisecondTTL(t *T) {
+	var pexpires [][]string
+	c := Stub("tcp", "127.0.0.1:6379", func(args []string) interface{} {
+		switch args[0] {
+		case "SCAN":
+			return []interface{}{"0", []string{"foo"}}
+		case "PTTL":
+			return -1
+		case "PEXPIRE":
+			pexpires = append(pexpires, args)
+			return 1
+		}
+		return errors.Errorf("unexpected command %q", args)
+	})
+
+	// a SetTTL of less than a millisecond is rounded up, rather than being
+	// given as 0, which would delete the key outright
+	audit, err := AuditExpiries(c, ExpiryAuditOpts{SetTTL: time.Microsecond})
+	require.Nil(t, err)
+	assert.Equal(t, ExpiryAudit{Scanned: 1, NoExpiry: 1, ExpirySet: 1}, audit)
+	assert.Equal(t, [][]string{{"PEXPIRE", "foo", "1"}}, pexpires)
+}
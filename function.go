@@ -0,0 +1,111 @@
+package radix
+
+import (
+	"strconv"
+)
+
+// Function describes a function of a redis function library, as introduced in
+// redis 7.0, and the code of the library which registers it. Call Cmd on a
+// Function to create an Action which calls it.
+//
+// Functions are like EvalScripts, but are called by name, and their library
+// is stored by redis persistently, rather than being cached. Like with
+// EvalScript, the library is loaded automatically if the function isn't found
+// when it's called.
+type Function struct {
+	name, library string
+	numKeys       int
+	readOnly      bool
+}
+
+// NewFunction initializes a Function which calls the function with the given
+// name, which is registered by the given library code, e.g.
+//
+//	lib := `#!lua name=mylib
+//	redis.register_function('myfunc', function(keys, args)
+//		return redis.call('GET', keys[1])
+//	end)`
+//
+//	fn := NewFunction(1, "myfunc", lib)
+//
+// numKeys corresponds to the number of arguments which will be keys when Cmd
+// is called.
+func NewFunction(numKeys int, name, library string) Function {
+	return Function{
+		name:    name,
+		library: library,
+		numKeys: numKeys,
+	}
+}
+
+// ReadOnly returns a copy of the Function which is called using FCALL_RO, and
+// so is treated as a read-only command, e.g. by Cluster when using
+// ClusterReadFromSecondaries. The function must be registered with the
+// no-writes flag.
+//
+// Since a library can't be loaded on a secondary, it must be loaded via the
+// primary, e.g. using Load, before the Function is called on a secondary.
+func (f Function) ReadOnly() Function {
+	f.readOnly = true
+	return f
+}
+
+// Load returns a CmdAction which loads the Function's library using FUNCTION
+// LOAD, replacing any existing version of it.
+//
+// Load doesn't have any keys, and so when used with Cluster it's performed on
+// a random node. To load the library on every primary, perform it on the Client
+// of each node in the Cluster's Topo().Primaries().
+func (f Function) Load() CmdAction {
+	return Cmd(nil, "FUNCTION", "LOAD", "REPLACE", f.library)
+}
+
+type functionAction struct {
+	Function
+	args []string
+	rcv  interface{}
+}
+
+// Cmd is like the top-level Cmd but it uses the Function to perform an FCALL
+// command (or FCALL_RO, see ReadOnly), automatically loading the library with
+// FUNCTION LOAD if the function isn't found. args must be at least as long as
+// the numKeys argument of NewFunction.
+func (f Function) Cmd(rcv interface{}, args ...string) Action {
+	if len(args) < f.numKeys {
+		panic("not enough arguments passed into Function.Cmd")
+	}
+	return &functionAction{
+		Function: f,
+		args:     args,
+		rcv:      rcv,
+	}
+}
+
+func (fa *functionAction) cmd() string {
+	if fa.readOnly {
+		return "FCALL_RO"
+	}
+	return "FCALL"
+}
+
+func (fa *functionAction) Keys() []string {
+	return fa.args[:fa.numKeys]
+}
+
+func (fa *functionAction) Run(conn Conn) error {
+	args := make([]string, 0, 2+len(fa.args))
+	args = append(args, fa.name, strconv.Itoa(fa.numKeys))
+	args = append(args, fa.args...)
+
+	err := conn.Do(Cmd(fa.rcv, fa.cmd(), args...))
+	if !isRespErrPrefix(err, "ERR Function not found") {
+		return err
+	} else if err := conn.Do(fa.Load()); err != nil {
+		return err
+	}
+	return conn.Do(Cmd(fa.rcv, fa.cmd(), args...))
+}
+
+func (fa *functionAction) ClusterCanRetry() bool {
+	return true
+}
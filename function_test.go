@@ -0,0 +1,64 @@
+package radix
+
+import (
+	. "testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	errors "golang.org/x/xerrors"
+
+	"github.com/mediocregopher/radix/v3/resp/resp2"
+)
+
+func TestFunction(t *T) {
+	const lib = `#!lua name=testlib
+redis.register_function('echokey', function(keys, args) return keys[1] .. args[1] end)`
+
+	var loaded bool
+	var gotArgs [][]string
+	conn := Stub("tcp", "127.0.0.1:6379", func(args []string) interface{} {
+		gotArgs = append(gotArgs, args)
+		switch args[0] {
+		case "FUNCTION":
+			loaded = true
+			return "testlib"
+		case "FCALL", "FCALL_RO":
+			if !loaded {
+				return resp2.Error{E: errors.New("ERR Function not found")}
+			} else if args[1] != "echokey" {
+				return resp2.Error{E: errors.New("ERR some other error")}
+			}
+			return args[3] + args[4]
+		}
+		return resp2.Error{E: errors.New("ERR unknown command")}
+	})
+
+	fn := NewFunction(1, "echokey", lib)
+	var out string
+	require.NoError(t, conn.Do(fn.Cmd(&out, "foo", "bar")))
+	assert.Equal(t, "foobar", out)
+	assert.Equal(t, [][]string{
+		{"FCALL", "echokey", "1", "foo", "bar"},
+		{"FUNCTION", "LOAD", "REPLACE", lib},
+		{"FCALL", "echokey", "1", "foo", "bar"},
+	}, gotArgs)
+
+	// once loaded the library isn't loaded again
+	gotArgs = nil
+	require.NoError(t, conn.Do(fn.ReadOnly().Cmd(&out, "baz", "qux")))
+	assert.Equal(t, "bazqux", out)
+	assert.Equal(t, [][]string{{"FCALL_RO", "echokey", "1", "baz", "qux"}}, gotArgs)
+
+	// other errors are returned as-is
+	gotArgs = nil
+	err := conn.Do(NewFunction(0, "other", lib).Cmd(nil))
+	assert.True(t, isRespErrPrefix(err, "ERR some other error"))
+	assert.Len(t, gotArgs, 1)
+
+	assert.Equal(t, []string{"foo"}, fn.Cmd(nil, "foo", "bar").Keys())
+	assert.Empty(t, fn.Load().Keys())
+	assert.Panics(t, func() { fn.Cmd(nil) })
+
+	assert.False(t, isReadOnlyAction(fn.Cmd(nil, "foo")))
+	assert.True(t, isReadOnlyAction(fn.ReadOnly().Cmd(nil, "foo")))
+}
@@ -0,0 +1,58 @@
+//go:build go1.18
+// +build go1.18
+
+package radix
+
+import (
+	"bufio"
+	"context"
+
+	"github.com/mediocregopher/radix/v3/resp/resp2"
+)
+
+// Get performs the given command on the Client, and returns its reply
+// unmarshaled into a value of type T, in the same way as with Cmd. For example:
+//
+//	val, err := radix.Get[string](client, "GET", "foo")
+//	n, err := radix.Get[int64](client, "INCR", "bar")
+//	vals, err := radix.Get[[]string](client, "LRANGE", "baz", "0", "-1")
+//
+// When a nil reply needs to be distinguished from the zero value of T, T can be
+// a Maybe:
+//
+//	val, err := radix.Get[radix.Maybe[string]](client, "GET", "foo")
+//
+func Get[T any](c Client, cmd string, args ...string) (T, error) {
+	return GetContext[T](context.Background(), c, cmd, args...)
+}
+
+// GetContext is like Get, but the command is performed using DoContext if the
+// Client implements ContextClient.
+func GetContext[T any](ctx context.Context, c Client, cmd string, args ...string) (T, error) {
+	var t T
+	err := DoContext(ctx, c, Cmd(&t, cmd, args...))
+	return t, err
+}
+
+// Maybe wraps a value of type T, and is used to distinguish a nil reply from
+// redis from the zero value of T. If the reply is nil (either a nil bulk string
+// or nil array) then Nil is set to true and Value is left as its zero value,
+// otherwise the reply is unmarshaled into Value. See also MaybeNil.
+type Maybe[T any] struct {
+	Nil   bool
+	Value T
+}
+
+// UnmarshalRESP implements the method for the resp.Unmarshaler interface.
+func (m *Maybe[T]) UnmarshalRESP(br *bufio.Reader) error {
+	var rm resp2.RawMessage
+	if err := rm.UnmarshalRESP(br); err != nil {
+		return err
+	} else if rm.IsNil() {
+		*m = Maybe[T]{Nil: true}
+		return nil
+	}
+
+	*m = Maybe[T]{}
+	return rm.UnmarshalInto(resp2.Any{I: &m.Value})
+}
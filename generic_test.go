@@ -0,0 +1,55 @@
+//go:build go1.18
+// +build go1.18
+
+package radix
+
+import (
+	"context"
+	. "testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGet(t *T) {
+	c := dial()
+	defer c.Close()
+	key, val := randStr(), randStr()
+
+	require.Nil(t, c.Do(Cmd(nil, "SET", key, val)))
+	got, err := Get[string](c, "GET", key)
+	require.Nil(t, err)
+	assert.Equal(t, val, got)
+
+	n, err := GetContext[int64](context.Background(), c, "APPEND", key, "!")
+	require.Nil(t, err)
+	assert.Equal(t, int64(len(val)+1), n)
+
+	require.Nil(t, c.Do(Cmd(nil, "RPUSH", key+"list", "a", "b")))
+	l, err := Get[[]string](c, "LRANGE", key+"list", "0", "-1")
+	require.Nil(t, err)
+	assert.Equal(t, []string{"a", "b"}, l)
+
+	_, err = Get[int64](c, "LPUSH", key, "a")
+	assert.Error(t, err)
+}
+
+func TestGetMaybe(t *T) {
+	c := dial()
+	defer c.Close()
+	key := randStr()
+
+	m, err := Get[Maybe[string]](c, "GET", key)
+	require.Nil(t, err)
+	assert.Equal(t, Maybe[string]{Nil: true}, m)
+
+	require.Nil(t, c.Do(Cmd(nil, "SET", key, "")))
+	m, err = Get[Maybe[string]](c, "GET", key)
+	require.Nil(t, err)
+	assert.Equal(t, Maybe[string]{}, m)
+
+	require.Nil(t, c.Do(Cmd(nil, "SET", key, "foo")))
+	m, err = Get[Maybe[string]](c, "GET", key)
+	require.Nil(t, err)
+	assert.Equal(t, Maybe[string]{Value: "foo"}, m)
+}
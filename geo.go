@@ -0,0 +1,237 @@
+package radix
+
+import (
+	"bufio"
+	"strconv"
+	"strings"
+
+	errors "golang.org/x/xerrors"
+
+	"github.com/mediocregopher/radix/v3/resp/resp2"
+)
+
+// GeoLocation describes a member of a geospatial index, as returned by GEOPOS,
+// or by GEOSEARCH, GEORADIUS, and GEORADIUSBYMEMBER.
+//
+// GeoLocation implements resp.Unmarshaler, so a *[]GeoLocation may be used as
+// the receiver of any of the search commands, regardless of which of the
+// WITHCOORD, WITHDIST, and WITHHASH options are given. Fields whose options
+// weren't given are left as their zero value.
+type GeoLocation struct {
+	Member string
+
+	// Lon and Lat are the longitude and latitude of the member, in degrees.
+	Lon, Lat float64
+
+	// Dist is the distance of the member from the center of the search, in
+	// the unit the search was given.
+	Dist float64
+
+	// Hash is the raw geohash-encoded sorted set score of the member.
+	Hash int64
+}
+
+// unmarshalGeoCoord unmarshals a longitude/latitude pair into l, returning
+// false if the pair was nil.
+func unmarshalGeoCoord(br *bufio.Reader, l *GeoLocation) (bool, error) {
+	b, err := br.Peek(1)
+	if err != nil {
+		return false, err
+	} else if b[0] != resp2.ArrayPrefix[0] {
+		var m resp2.Maybe
+		if err := m.UnmarshalRESP(br); err != nil {
+			return false, err
+		} else if !m.Nil {
+			return false, errors.New("malformed geo coordinates")
+		}
+		return false, nil
+	}
+
+	var arrHead resp2.ArrayHeader
+	if err := arrHead.UnmarshalRESP(br); err != nil {
+		return false, err
+	} else if arrHead.N == -1 {
+		return false, nil
+	} else if arrHead.N != 2 {
+		return false, errors.Errorf("malformed geo coordinates with %d elements", arrHead.N)
+	}
+	for _, f := range []*float64{&l.Lon, &l.Lat} {
+		if err := (resp2.Any{I: f}).UnmarshalRESP(br); err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// UnmarshalRESP implements the resp.Unmarshaler interface.
+func (l *GeoLocation) UnmarshalRESP(br *bufio.Reader) error {
+	b, err := br.Peek(1)
+	if err != nil {
+		return err
+	}
+
+	// without any WITH* options only the member's name is returned
+	if b[0] != resp2.ArrayPrefix[0] {
+		*l = GeoLocation{}
+		return (resp2.Any{I: &l.Member}).UnmarshalRESP(br)
+	}
+
+	var arrHead resp2.ArrayHeader
+	if err := arrHead.UnmarshalRESP(br); err != nil {
+		return err
+	} else if arrHead.N < 1 {
+		return errors.New("malformed geo location with no elements")
+	}
+
+	var loc GeoLocation
+	if err := (resp2.Any{I: &loc.Member}).UnmarshalRESP(br); err != nil {
+		return err
+	}
+
+	// the remaining elements are the distance, hash, and coordinates, in that
+	// order, but only those which were asked for are returned. They can be
+	// told apart by their types.
+	for i := 1; i < arrHead.N; i++ {
+		if b, err = br.Peek(1); err != nil {
+			return err
+		}
+		switch b[0] {
+		case resp2.ArrayPrefix[0]:
+			_, err = unmarshalGeoCoord(br, &loc)
+		case resp2.IntPrefix[0]:
+			err = (resp2.Any{I: &loc.Hash}).UnmarshalRESP(br)
+		default:
+			err = (resp2.Any{I: &loc.Dist}).UnmarshalRESP(br)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	*l = loc
+	return nil
+}
+
+type geoPositions struct {
+	members []string
+	locs    *[]GeoLocation
+}
+
+func (p geoPositions) UnmarshalRESP(br *bufio.Reader) error {
+	var arrHead resp2.ArrayHeader
+	if err := arrHead.UnmarshalRESP(br); err != nil {
+		return err
+	}
+
+	locs := make([]GeoLocation, 0, arrHead.N)
+	for i := 0; i < arrHead.N; i++ {
+		var loc GeoLocation
+		if i < len(p.members) {
+			loc.Member = p.members[i]
+		}
+
+		// members which don't exist are given as nil
+		if ok, err := unmarshalGeoCoord(br, &loc); err != nil {
+			return err
+		} else if ok {
+			locs = append(locs, loc)
+		}
+	}
+	*p.locs = locs
+	return nil
+}
+
+// GeoPosCmd returns a CmdAction which performs GEOPOS on the given members of
+// the geospatial index at key, writing their locations into rcv. Members which
+// don't exist are left out of rcv.
+func GeoPosCmd(rcv *[]GeoLocation, key string, members ...string) CmdAction {
+	return Cmd(geoPositions{members: members, locs: rcv}, "GEOPOS", append([]string{key}, members...)...)
+}
+
+// GeoSearchOpts describes the arguments to GEOSEARCH. See:
+//
+//	https://redis.io/commands/geosearch
+//
+// The center of the search is FromMember, if it's set, otherwise FromLon and
+// FromLat. The search area is a circle of Radius, if it's set, otherwise a box
+// of Width by Height.
+type GeoSearchOpts struct {
+	FromMember       string
+	FromLon, FromLat float64
+
+	Radius        float64
+	Width, Height float64
+
+	// Unit is the unit of the search area's dimensions and of the returned
+	// distances, one of "m", "km", "ft", or "mi". Defaults to "m".
+	Unit string
+
+	// Sort is "ASC" or "DESC" to sort the results by their distance from the
+	// center. If empty the results aren't sorted.
+	Sort string
+
+	// Count limits the number of results, if it's greater than zero. If Any is
+	// also set then redis returns as soon as enough results are found, rather
+	// than returning the closest ones.
+	Count int
+	Any   bool
+
+	WithCoord, WithDist, WithHash bool
+}
+
+// Args returns the arguments to GEOSEARCH described by the GeoSearchOpts, not
+// including the key. The With* fields aren't valid for GEOSEARCHSTORE, and
+// should be left unset when using Args to build one.
+func (o GeoSearchOpts) Args() []string {
+	fmtFloat := func(f float64) string {
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+
+	var args []string
+	if o.FromMember != "" {
+		args = append(args, "FROMMEMBER", o.FromMember)
+	} else {
+		args = append(args, "FROMLONLAT", fmtFloat(o.FromLon), fmtFloat(o.FromLat))
+	}
+
+	unit := o.Unit
+	if unit == "" {
+		unit = "m"
+	}
+	if o.Radius > 0 {
+		args = append(args, "BYRADIUS", fmtFloat(o.Radius), unit)
+	} else {
+		args = append(args, "BYBOX", fmtFloat(o.Width), fmtFloat(o.Height), unit)
+	}
+
+	if o.Sort != "" {
+		args = append(args, strings.ToUpper(o.Sort))
+	}
+	if o.Count > 0 {
+		args = append(args, "COUNT", strconv.Itoa(o.Count))
+		if o.Any {
+			args = append(args, "ANY")
+		}
+	}
+
+	for _, with := range []struct {
+		set bool
+		arg string
+	}{
+		{o.WithCoord, "WITHCOORD"},
+		{o.WithDist, "WITHDIST"},
+		{o.WithHash, "WITHHASH"},
+	} {
+		if with.set {
+			args = append(args, with.arg)
+		}
+	}
+	return args
+}
+
+// GeoSearchCmd returns a CmdAction which performs GEOSEARCH on the geospatial
+// index at key, writing the matching members into rcv. This requires redis 6.2
+// or above.
+func GeoSearchCmd(rcv *[]GeoLocation, key string, opts GeoSearchOpts) CmdAction {
+	return Cmd(rcv, "GEOSEARCH", append([]string{key}, opts.Args()...)...)
+}
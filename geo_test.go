@@ -0,0 +1,111 @@
+package radix
+
+import (
+	. "testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGeoSearchOptsArgs(t *T) {
+	for _, test := range []struct {
+		opts GeoSearchOpts
+		exp  []string
+	}{
+		{
+			opts: GeoSearchOpts{FromMember: "a", Radius: 10},
+			exp:  []string{"FROMMEMBER", "a", "BYRADIUS", "10", "m"},
+		},
+		{
+			opts: GeoSearchOpts{
+				FromLon: 13.361389, FromLat: 38.115556,
+				Width: 400, Height: 200.5, Unit: "km",
+				Sort: "asc", Count: 5, Any: true,
+				WithCoord: true, WithDist: true, WithHash: true,
+			},
+			exp: []string{
+				"FROMLONLAT", "13.361389", "38.115556",
+				"BYBOX", "400", "200.5", "km",
+				"ASC", "COUNT", "5", "ANY",
+				"WITHCOORD", "WITHDIST", "WITHHASH",
+			},
+		},
+		{
+			// Any is ignored without Count
+			opts: GeoSearchOpts{FromMember: "a", Radius: 1, Any: true, WithDist: true},
+			exp:  []string{"FROMMEMBER", "a", "BYRADIUS", "1", "m", "WITHDIST"},
+		},
+	} {
+		assert.Equal(t, test.exp, test.opts.Args())
+	}
+}
+
+func TestGeoCmds(t *T) {
+	var gotArgs [][]string
+	conn := Stub("tcp", "127.0.0.1:6379", func(args []string) interface{} {
+		gotArgs = append(gotArgs, args)
+		switch args[0] {
+		case "GEOPOS":
+			return []interface{}{
+				[]string{"13.36138933897018433", "38.11555639549629859"},
+				nil,
+				[]string(nil),
+				[]string{"15.08726745843887329", "37.50266842333162032"},
+			}
+		case "GEOSEARCH":
+			if args[len(args)-1] != "WITHHASH" {
+				return []string{"Palermo", "Catania"}
+			}
+			return []interface{}{
+				[]interface{}{
+					"Palermo", "190.4424", int64(3479099956230698),
+					[]string{"13.36138933897018433", "38.11555639549629859"},
+				},
+				[]interface{}{
+					"Catania", "56.4413", int64(3479447370796909),
+					[]string{"15.08726745843887329", "37.50266842333162032"},
+				},
+			}
+		default: // GEORADIUS
+			return []interface{}{
+				[]interface{}{"Palermo", "190.4424"},
+			}
+		}
+	})
+
+	var locs []GeoLocation
+	require.Nil(t, conn.Do(GeoPosCmd(&locs, "Sicily", "Palermo", "Foo", "Bar", "Catania")))
+	assert.Equal(t, []GeoLocation{
+		{Member: "Palermo", Lon: 13.36138933897018433, Lat: 38.11555639549629859},
+		{Member: "Catania", Lon: 15.08726745843887329, Lat: 37.50266842333162032},
+	}, locs)
+
+	require.Nil(t, conn.Do(GeoSearchCmd(&locs, "Sicily", GeoSearchOpts{
+		FromLon: 15, FromLat: 37, Radius: 200, Unit: "km",
+	})))
+	assert.Equal(t, []GeoLocation{{Member: "Palermo"}, {Member: "Catania"}}, locs)
+
+	require.Nil(t, conn.Do(GeoSearchCmd(&locs, "Sicily", GeoSearchOpts{
+		FromLon: 15, FromLat: 37, Radius: 200, Unit: "km",
+		WithCoord: true, WithDist: true, WithHash: true,
+	})))
+	assert.Equal(t, []GeoLocation{
+		{
+			Member: "Palermo", Dist: 190.4424, Hash: 3479099956230698,
+			Lon: 13.36138933897018433, Lat: 38.11555639549629859,
+		},
+		{
+			Member: "Catania", Dist: 56.4413, Hash: 3479447370796909,
+			Lon: 15.08726745843887329, Lat: 37.50266842333162032,
+		},
+	}, locs)
+
+	// GeoLocation can be used with the other search commands too
+	require.Nil(t, conn.Do(Cmd(&locs, "GEORADIUS", "Sicily", "15", "37", "200", "km", "WITHDIST")))
+	assert.Equal(t, []GeoLocation{{Member: "Palermo", Dist: 190.4424}}, locs)
+
+	assert.Equal(t, []string{"GEOPOS", "Sicily", "Palermo", "Foo", "Bar", "Catania"}, gotArgs[0])
+	assert.Equal(t, []string{
+		"GEOSEARCH", "Sicily", "FROMLONLAT", "15", "37", "BYRADIUS", "200", "km",
+	}, gotArgs[1])
+}
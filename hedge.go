@@ -0,0 +1,107 @@
+package radix
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"time"
+
+	errors "golang.org/x/xerrors"
+
+	"github.com/mediocregopher/radix/v3/resp/resp2"
+)
+
+// hedgeRcv is the receiver used by each attempt of a hedged read. It captures
+// the reply as-is, so that only the reply of the winning attempt is
+// unmarshaled into the Action's actual receiver, but still returns error
+// replies as errors, so that MOVED and ASK errors are handled by Cluster.
+type hedgeRcv struct {
+	raw resp2.RawMessage
+}
+
+func (hr *hedgeRcv) UnmarshalRESP(br *bufio.Reader) error {
+	if err := hr.raw.UnmarshalRESP(br); err != nil {
+		return err
+	} else if hr.raw[0] == resp2.ErrorPrefix[0] || hr.raw[0] == resp2.BlobErrorPrefix[0] {
+		return hr.raw.UnmarshalInto(resp2.Any{})
+	}
+	return nil
+}
+
+// hedgeable returns whether the Action can be hedged, i.e. whether it's a
+// single read-only command which can safely be performed more than once.
+func hedgeable(a Action) bool {
+	c, ok := a.(*cmdAction)
+	if !ok || !isReadOnlyAction(a) {
+		return false
+	}
+	for _, arg := range c.flatArgs {
+		// readers can only be read once
+		if _, ok := arg.(io.Reader); ok {
+			return false
+		}
+	}
+	return true
+}
+
+type hedgeResult struct {
+	rcv *hedgeRcv
+	err error
+}
+
+// doHedged performs the Action, which must be hedgeable, by passing it into
+// first. If that hasn't completed within delay, or fails with an error other
+// than an error reply from redis, the Action is also passed into second, if
+// second isn't nil. The reply of whichever completes successfully first is
+// unmarshaled into the Action's receiver.
+//
+// An error reply counts as a completion, since performing the Action on
+// another node would most likely result in the same error. If both fail then
+// the error from first is returned.
+func doHedged(ctx context.Context, a Action, delay time.Duration, first, second func(Action) error) error {
+	orig := a.(*cmdAction)
+	resCh := make(chan hedgeResult, 2)
+	try := func(fn func(Action) error) {
+		// cmdActions are returned to a pool once they're unmarshaled into, so
+		// each attempt gets its own copy rather than sharing one.
+		c := *orig
+		rcv := new(hedgeRcv)
+		c.rcv = rcv
+		go func() { resCh <- hedgeResult{rcv: rcv, err: fn(&c)} }()
+	}
+
+	try(first)
+	t := getTimer(delay)
+	defer putTimer(t)
+
+	pending, hedged := 1, second == nil
+	var firstErr error
+	for {
+		select {
+		case <-t.C:
+			if !hedged {
+				try(second)
+				pending, hedged = pending+1, true
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		case res := <-resCh:
+			pending--
+			if res.err == nil {
+				return res.rcv.raw.UnmarshalInto(resp2.Any{I: orig.rcv})
+			} else if errors.As(res.err, new(resp2.Error)) {
+				return res.err
+			}
+
+			if firstErr == nil {
+				firstErr = res.err
+			}
+			if !hedged {
+				try(second)
+				pending, hedged = pending+1, true
+			} else if pending == 0 {
+				return firstErr
+			}
+		}
+	}
+}
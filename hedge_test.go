@@ -0,0 +1,95 @@
+package radix
+
+import (
+	"context"
+	"io"
+	"strings"
+	. "testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	errors "golang.org/x/xerrors"
+
+	"github.com/mediocregopher/radix/v3/resp"
+	"github.com/mediocregopher/radix/v3/resp/resp2"
+)
+
+func TestDoHedged(t *T) {
+	// reply returns a function which performs an Action on a Stub returning the
+	// given reply, after the given delay, recording that it was called.
+	reply := func(called *bool, delay time.Duration, ret interface{}) func(Action) error {
+		stub := Stub("tcp", "127.0.0.1:6379", func([]string) interface{} {
+			time.Sleep(delay)
+			return ret
+		})
+		return func(a Action) error {
+			*called = true
+			return stub.Do(a)
+		}
+	}
+	fail := func(called *bool, err error) func(Action) error {
+		return func(Action) error {
+			*called = true
+			return err
+		}
+	}
+	ctx := context.Background()
+
+	t.Run("First", func(t *T) {
+		var firstCalled, secondCalled bool
+		var out string
+		err := doHedged(ctx, Cmd(&out, "GET", "foo"), time.Second,
+			reply(&firstCalled, 0, "first"), reply(&secondCalled, 0, "second"))
+		require.NoError(t, err)
+		assert.Equal(t, "first", out)
+		assert.True(t, firstCalled)
+		assert.False(t, secondCalled)
+	})
+
+	t.Run("Slow", func(t *T) {
+		var firstCalled, secondCalled bool
+		var out string
+		err := doHedged(ctx, Cmd(&out, "GET", "foo"), 10*time.Millisecond,
+			reply(&firstCalled, time.Second, "first"), reply(&secondCalled, 0, "second"))
+		require.NoError(t, err)
+		assert.Equal(t, "second", out)
+	})
+
+	t.Run("NetworkError", func(t *T) {
+		var firstCalled, secondCalled bool
+		var out string
+		err := doHedged(ctx, Cmd(&out, "GET", "foo"), time.Hour,
+			fail(&firstCalled, io.EOF), reply(&secondCalled, 0, "second"))
+		require.NoError(t, err)
+		assert.Equal(t, "second", out)
+	})
+
+	t.Run("ErrorReply", func(t *T) {
+		var firstCalled, secondCalled bool
+		errReply := resp2.Error{E: errors.New("WRONGTYPE nope")}
+		err := doHedged(ctx, Cmd(nil, "GET", "foo"), time.Hour,
+			reply(&firstCalled, 0, errReply), reply(&secondCalled, 0, "second"))
+		assert.EqualError(t, err, errReply.Error())
+		assert.False(t, secondCalled)
+	})
+
+	t.Run("AllFail", func(t *T) {
+		var firstCalled, secondCalled bool
+		err := doHedged(ctx, Cmd(nil, "GET", "foo"), time.Hour,
+			fail(&firstCalled, io.EOF), fail(&secondCalled, io.ErrUnexpectedEOF))
+		assert.Equal(t, io.EOF, err)
+		assert.True(t, secondCalled)
+
+		err = doHedged(ctx, Cmd(nil, "GET", "foo"), time.Hour, fail(&firstCalled, io.EOF), nil)
+		assert.Equal(t, io.EOF, err)
+	})
+}
+
+func TestHedgeable(t *T) {
+	assert.True(t, hedgeable(Cmd(nil, "GET", "foo")))
+	assert.True(t, hedgeable(FlatCmd(nil, "HGET", "foo", 1)))
+	assert.False(t, hedgeable(Cmd(nil, "SET", "foo", "bar")))
+	assert.False(t, hedgeable(FlatCmd(nil, "GETRANGE", "foo", resp.NewLenReader(strings.NewReader("bar"), 3))))
+	assert.False(t, hedgeable(Pipeline(Cmd(nil, "GET", "foo"))))
+}
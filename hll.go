@@ -0,0 +1,148 @@
+package radix
+
+import (
+	"math/rand"
+	"strconv"
+
+	errors "golang.org/x/xerrors"
+)
+
+// PFAddCmd returns a CmdAction which performs PFADD, adding the given elements
+// to the HyperLogLog at key. rcv, if not nil, is set to whether the
+// HyperLogLog's estimated cardinality changed as a result.
+func PFAddCmd(rcv *bool, key string, elements ...string) CmdAction {
+	args := append([]string{key}, elements...)
+	if rcv == nil {
+		// a nil *bool would otherwise be written to
+		return Cmd(nil, "PFADD", args...)
+	}
+	return Cmd(rcv, "PFADD", args...)
+}
+
+// PFCountCmd returns a CmdAction which performs PFCOUNT on the given keys,
+// writing the estimated cardinality of the union of their HyperLogLogs into
+// rcv.
+//
+// When performed on a Cluster all of the keys must belong to the same slot, use
+// ClusterPFCount otherwise.
+func PFCountCmd(rcv *int64, keys ...string) CmdAction {
+	return Cmd(rcv, "PFCOUNT", keys...)
+}
+
+// PFMergeCmd returns a CmdAction which performs PFMERGE, merging the
+// HyperLogLogs at the src keys into the one at dst, which is created if it
+// doesn't exist.
+//
+// When performed on a Cluster all of the keys must belong to the same slot, use
+// ClusterPFMerge otherwise.
+func PFMergeCmd(dst string, srcs ...string) CmdAction {
+	return Cmd(nil, "PFMERGE", append([]string{dst}, srcs...)...)
+}
+
+// pfTempKeyTTL is the expiry, in milliseconds, given to the temporary keys
+// used by ClusterPFCount and ClusterPFMerge, in case they aren't deleted.
+const pfTempKeyTTL = "60000"
+
+// pfGather returns a Pipeline which performs the command created by fn on the
+// given keys, all of which are made to belong to the slot of the first key.
+// Keys which belong to a different slot are read using GET, which works since
+// HyperLogLogs are stored as strings, and are written to temporary keys hash
+// tagged to belong to the first key's slot for the duration of the Pipeline.
+func pfGather(c Client, keys []string, fn func(keys []string) CmdAction) (Action, error) {
+	slot := ClusterKeySlot(keys[0])
+	var others []string
+	for _, key := range keys[1:] {
+		if ClusterKeySlot(key) != slot {
+			others = append(others, key)
+		}
+	}
+	if len(others) == 0 {
+		return fn(keys), nil
+	}
+
+	vals := make([]string, len(others))
+	if err := c.Do(ClusterMGet(&vals, others...)); err != nil {
+		return nil, err
+	}
+
+	tag, ok := ClusterHashTag(keys[0])
+	if !ok {
+		tag = keys[0]
+	}
+	tmpPrefix := "{" + tag + "}:radix:pf:" + strconv.FormatInt(rand.Int63(), 36) + ":"
+	if ClusterKeySlot(tmpPrefix) != slot {
+		return nil, errors.Errorf("can't create temporary keys in the slot of %q", keys[0])
+	}
+
+	cmds := make([]CmdAction, 0, len(others)+2)
+	gathered := make([]string, 0, len(keys))
+	tmpKeys := make([]string, 0, len(others))
+	var otherI int
+	for _, key := range keys {
+		if ClusterKeySlot(key) == slot {
+			gathered = append(gathered, key)
+			continue
+		}
+
+		// keys which don't exist are treated as empty HyperLogLogs by redis,
+		// so can be left out
+		val := vals[otherI]
+		if otherI++; val == "" {
+			continue
+		}
+
+		tmpKey := tmpPrefix + strconv.Itoa(len(tmpKeys))
+		cmds = append(cmds, Cmd(nil, "SET", tmpKey, val, "PX", pfTempKeyTTL))
+		gathered = append(gathered, tmpKey)
+		tmpKeys = append(tmpKeys, tmpKey)
+	}
+
+	cmds = append(cmds, fn(gathered))
+	if len(tmpKeys) > 0 {
+		cmds = append(cmds, Cmd(nil, "DEL", tmpKeys...))
+	}
+	return Pipeline(cmds...), nil
+}
+
+// ClusterPFCount is like PFCountCmd, but the keys may belong to any number of
+// slots. The HyperLogLogs at keys which don't belong to the slot of the first
+// key are copied to temporary keys in that slot, which PFCOUNT is then
+// performed on along with the rest. The temporary keys are deleted afterwards.
+//
+// ClusterPFCount takes a Client, rather than returning an Action, since the
+// HyperLogLogs must be read before they can be copied. It works with any
+// Client, not only a Cluster.
+func ClusterPFCount(c Client, keys ...string) (int64, error) {
+	if len(keys) == 0 {
+		return 0, errors.New("ClusterPFCount requires at least one key")
+	}
+
+	var n int64
+	a, err := pfGather(c, keys, func(keys []string) CmdAction {
+		return PFCountCmd(&n, keys...)
+	})
+	if err != nil {
+		return 0, err
+	} else if err := c.Do(a); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// ClusterPFMerge is like PFMergeCmd, but the keys may belong to any number of
+// slots. The HyperLogLogs at src keys which don't belong to the slot of dst are
+// copied to temporary keys in that slot, which are then merged into dst along
+// with the rest. The temporary keys are deleted afterwards.
+//
+// ClusterPFMerge takes a Client, rather than returning an Action, since the
+// HyperLogLogs must be read before they can be copied. It works with any
+// Client, not only a Cluster.
+func ClusterPFMerge(c Client, dst string, srcs ...string) error {
+	a, err := pfGather(c, append([]string{dst}, srcs...), func(keys []string) CmdAction {
+		return PFMergeCmd(keys[0], keys[1:]...)
+	})
+	if err != nil {
+		return err
+	}
+	return c.Do(a)
+}
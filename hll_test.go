@@ -0,0 +1,65 @@
+package radix
+
+import (
+	. "testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHyperLogLog(t *T) {
+	c := dial()
+	defer c.Close()
+
+	// k1 and k2 are in the same slot, k3 and k4 each in a different one
+	tag := randStr()
+	k1, k2 := "{"+tag+"}1", "{"+tag+"}2"
+	k3, k4 := randStr(), randStr()
+
+	var changed bool
+	require.Nil(t, c.Do(PFAddCmd(&changed, k1, "a", "b", "c")))
+	assert.True(t, changed)
+	require.Nil(t, c.Do(PFAddCmd(&changed, k1, "a")))
+	assert.False(t, changed)
+	require.Nil(t, c.Do(PFAddCmd(nil, k2, "c", "d")))
+	require.Nil(t, c.Do(PFAddCmd(nil, k3, "d", "e")))
+	require.Nil(t, c.Do(PFAddCmd(nil, k4, "f")))
+
+	var n int64
+	require.Nil(t, c.Do(PFCountCmd(&n, k1)))
+	assert.Equal(t, int64(3), n)
+
+	dst := "{" + tag + "}dst"
+	require.Nil(t, c.Do(PFMergeCmd(dst, k1, k2)))
+	require.Nil(t, c.Do(PFCountCmd(&n, dst)))
+	assert.Equal(t, int64(4), n)
+
+	t.Run("Cluster", func(t *T) {
+		// ClusterPFCount and ClusterPFMerge rely on HyperLogLogs being stored
+		// as strings, as they are by redis
+		var val []byte
+		if err := c.Do(Cmd(&val, "GET", k4)); err != nil || len(val) == 0 {
+			t.Skip("HyperLogLogs can't be read using GET")
+		}
+
+		n, err := ClusterPFCount(c, k1, k3, k2, k4, randStr())
+		require.Nil(t, err)
+		assert.Equal(t, int64(6), n)
+
+		dst := randStr()
+		require.Nil(t, ClusterPFMerge(c, dst, k1, k3, k4))
+		require.Nil(t, c.Do(PFCountCmd(&n, dst)))
+		assert.Equal(t, int64(6), n)
+
+		// the temporary keys were deleted
+		var tmpKeys []string
+		require.Nil(t, c.Do(Cmd(&tmpKeys, "KEYS", "{*}:radix:pf:*")))
+		assert.Empty(t, tmpKeys)
+	})
+
+	// when all keys are in the same slot no temporary keys are needed
+	require.Nil(t, ClusterPFMerge(c, k2, k1))
+	n, err := ClusterPFCount(c, k2)
+	require.Nil(t, err)
+	assert.Equal(t, int64(4), n)
+}
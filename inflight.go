@@ -0,0 +1,123 @@
+package radix
+
+import (
+	"context"
+	"time"
+
+	errors "golang.org/x/xerrors"
+)
+
+// ErrOverloaded is returned by an InFlightLimiter when an Action can't be
+// performed because the limit on Actions in flight has been reached, and no
+// Action completed in time for it to be performed instead.
+var ErrOverloaded = errors.New("too many actions in flight")
+
+type inFlightLimiterOpts struct {
+	wait time.Duration
+}
+
+// InFlightLimiterOpt is an optional behavior which can be applied to the
+// NewInFlightLimiter function to effect an InFlightLimiter's behavior.
+type InFlightLimiterOpt func(*inFlightLimiterOpts)
+
+// InFlightLimiterWait tells the InFlightLimiter to wait up to the given
+// duration for an Action in flight to complete when the limit has been reached,
+// before returning ErrOverloaded. If the duration is negative then it waits
+// indefinitely, or until the Context passed into DoContext is done.
+//
+// By default an InFlightLimiter doesn't wait, and fails fast.
+func InFlightLimiterWait(d time.Duration) InFlightLimiterOpt {
+	return func(o *inFlightLimiterOpts) {
+		o.wait = d
+	}
+}
+
+// InFlightLimiter is a Client which wraps another Client, limiting the number
+// of Actions which may be performed through it concurrently. This protects both
+// redis and the application from load spikes, by having Actions past the limit
+// fail with ErrOverloaded rather than piling up.
+//
+// Note that an Action which is a Pipeline counts as a single Action, as does
+// an Action which is internally retried, e.g. due to a Cluster redirect.
+type InFlightLimiter struct {
+	Client
+	opts inFlightLimiterOpts
+	sem  chan struct{}
+}
+
+var _ ContextClient = new(InFlightLimiter)
+
+// NewInFlightLimiter returns an InFlightLimiter which performs Actions using
+// the given Client, allowing at most limit Actions to be in flight at once.
+//
+// NewInFlightLimiter takes in a number of options which can overwrite its
+// default behavior. The default options NewInFlightLimiter uses are:
+//
+//	InFlightLimiterWait(0)
+//
+func NewInFlightLimiter(c Client, limit int, opts ...InFlightLimiterOpt) *InFlightLimiter {
+	if limit < 1 {
+		panic("NewInFlightLimiter requires a limit of at least 1")
+	}
+
+	l := &InFlightLimiter{
+		Client: c,
+		sem:    make(chan struct{}, limit),
+	}
+	for _, opt := range opts {
+		opt(&l.opts)
+	}
+	return l
+}
+
+func (l *InFlightLimiter) acquire(ctx context.Context) error {
+	select {
+	case l.sem <- struct{}{}:
+		return nil
+	default:
+	}
+
+	if l.opts.wait == 0 {
+		return ErrOverloaded
+	}
+
+	// only set when we have a timeout, since a nil channel always blocks which
+	// is what we want
+	var tc <-chan time.Time
+	if l.opts.wait > 0 {
+		t := getTimer(l.opts.wait)
+		defer putTimer(t)
+		tc = t.C
+	}
+
+	select {
+	case l.sem <- struct{}{}:
+		return nil
+	case <-tc:
+		return ErrOverloaded
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Do implements the method for the Client interface, returning ErrOverloaded
+// if the Action couldn't be performed due to the limit.
+func (l *InFlightLimiter) Do(a Action) error {
+	return l.DoContext(context.Background(), a)
+}
+
+// DoContext implements the method for the ContextClient interface, returning
+// ErrOverloaded if the Action couldn't be performed due to the limit.
+func (l *InFlightLimiter) DoContext(ctx context.Context, a Action) error {
+	if err := l.acquire(ctx); err != nil {
+		return err
+	}
+	defer func() { <-l.sem }()
+	return DoContext(ctx, l.Client, a)
+}
+
+// InFlight returns the number of Actions currently being performed through the
+// InFlightLimiter.
+func (l *InFlightLimiter) InFlight() int {
+	return len(l.sem)
+}
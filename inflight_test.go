@@ -0,0 +1,85 @@
+package radix
+
+import (
+	"context"
+	. "testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	errors "golang.org/x/xerrors"
+)
+
+func TestInFlightLimiter(t *T) {
+	// each command performed on the returned InFlightLimiter blocks until
+	// the returned function is called
+	setup := func(limit int, opts ...InFlightLimiterOpt) (*InFlightLimiter, func()) {
+		unblockCh := make(chan struct{})
+		l := NewInFlightLimiter(Stub("tcp", "127.0.0.1:6379", func([]string) interface{} {
+			<-unblockCh
+			return "OK"
+		}), limit, opts...)
+		return l, func() { close(unblockCh) }
+	}
+	block := func(l *InFlightLimiter) <-chan error {
+		errCh := make(chan error, 1)
+		go func() {
+			errCh <- l.Do(WithConn("", func(c Conn) error {
+				return c.Do(Cmd(nil, "GET", "foo"))
+			}))
+		}()
+		return errCh
+	}
+	waitInFlight := func(l *InFlightLimiter, n int) {
+		for l.InFlight() != n {
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	t.Run("FailFast", func(t *T) {
+		l, unblock := setup(1)
+		errCh := block(l)
+		waitInFlight(l, 1)
+
+		err := l.Do(Cmd(nil, "GET", "foo"))
+		assert.True(t, errors.Is(err, ErrOverloaded))
+
+		unblock()
+		require.NoError(t, <-errCh)
+		assert.Equal(t, 0, l.InFlight())
+	})
+
+	t.Run("WaitTimeout", func(t *T) {
+		l, unblock := setup(1, InFlightLimiterWait(50*time.Millisecond))
+		defer unblock()
+		block(l)
+		waitInFlight(l, 1)
+
+		start := time.Now()
+		err := l.Do(Cmd(nil, "GET", "foo"))
+		assert.True(t, errors.Is(err, ErrOverloaded))
+		assert.True(t, time.Since(start) >= 50*time.Millisecond)
+	})
+
+	t.Run("WaitSuccess", func(t *T) {
+		l, unblock := setup(1, InFlightLimiterWait(-1))
+		errCh := block(l)
+		waitInFlight(l, 1)
+
+		time.AfterFunc(50*time.Millisecond, unblock)
+		require.NoError(t, l.Do(WithConn("", func(Conn) error { return nil })))
+		require.NoError(t, <-errCh)
+	})
+
+	t.Run("WaitContext", func(t *T) {
+		l, unblock := setup(1, InFlightLimiterWait(-1))
+		defer unblock()
+		block(l)
+		waitInFlight(l, 1)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		err := l.DoContext(ctx, Cmd(nil, "GET", "foo"))
+		assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	})
+}
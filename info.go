@@ -0,0 +1,289 @@
+package radix
+
+import (
+	"bufio"
+	"reflect"
+	"strconv"
+	"strings"
+
+	errors "golang.org/x/xerrors"
+
+	"github.com/mediocregopher/radix/v3/resp/resp2"
+)
+
+// InfoServer describes the "server" section of an INFO reply.
+type InfoServer struct {
+	RedisVersion    string `info:"redis_version"`
+	RedisMode       string `info:"redis_mode"`
+	OS              string `info:"os"`
+	ProcessID       int64  `info:"process_id"`
+	RunID           string `info:"run_id"`
+	TCPPort         int64  `info:"tcp_port"`
+	UptimeInSeconds int64  `info:"uptime_in_seconds"`
+	ConfigFile      string `info:"config_file"`
+}
+
+// InfoClients describes the "clients" section of an INFO reply.
+type InfoClients struct {
+	ConnectedClients int64 `info:"connected_clients"`
+	BlockedClients   int64 `info:"blocked_clients"`
+	TrackingClients  int64 `info:"tracking_clients"`
+	MaxClients       int64 `info:"maxclients"`
+}
+
+// InfoMemory describes the "memory" section of an INFO reply. Memory amounts
+// are given in bytes.
+type InfoMemory struct {
+	UsedMemory            int64   `info:"used_memory"`
+	UsedMemoryRSS         int64   `info:"used_memory_rss"`
+	UsedMemoryPeak        int64   `info:"used_memory_peak"`
+	UsedMemoryLua         int64   `info:"used_memory_lua"`
+	MaxMemory             int64   `info:"maxmemory"`
+	MaxMemoryPolicy       string  `info:"maxmemory_policy"`
+	MemFragmentationRatio float64 `info:"mem_fragmentation_ratio"`
+}
+
+// InfoPersistence describes the "persistence" section of an INFO reply. Times
+// are given as unix timestamps in seconds.
+type InfoPersistence struct {
+	Loading                  bool   `info:"loading"`
+	RDBChangesSinceLastSave  int64  `info:"rdb_changes_since_last_save"`
+	RDBBgsaveInProgress      bool   `info:"rdb_bgsave_in_progress"`
+	RDBLastSaveTime          int64  `info:"rdb_last_save_time"`
+	RDBLastBgsaveStatus      string `info:"rdb_last_bgsave_status"`
+	AOFEnabled               bool   `info:"aof_enabled"`
+	AOFRewriteInProgress     bool   `info:"aof_rewrite_in_progress"`
+	AOFLastBgrewriteStatus   string `info:"aof_last_bgrewrite_status"`
+	AOFLastWriteStatus       string `info:"aof_last_write_status"`
+	AOFRewriteScheduled      bool   `info:"aof_rewrite_scheduled"`
+	AOFLastRewriteTimeInSecs int64  `info:"aof_last_rewrite_time_sec"`
+}
+
+// InfoReplica describes a single replica listed in the "replication" section
+// of an INFO reply.
+type InfoReplica struct {
+	IP     string `info:"ip"`
+	Port   int64  `info:"port"`
+	State  string `info:"state"`
+	Offset int64  `info:"offset"`
+	Lag    int64  `info:"lag"`
+}
+
+// InfoReplication describes the "replication" section of an INFO reply. Which
+// fields are set depends on the Role of the instance.
+type InfoReplication struct {
+	// Role is either "master" or "slave" (or "replica").
+	Role             string `info:"role"`
+	ConnectedSlaves  int64  `info:"connected_slaves,connected_replicas"`
+	MasterReplID     string `info:"master_replid"`
+	MasterReplOffset int64  `info:"master_repl_offset"`
+
+	// These are only set when Role is "slave".
+	MasterHost           string `info:"master_host"`
+	MasterPort           int64  `info:"master_port"`
+	MasterLinkStatus     string `info:"master_link_status"`
+	MasterLastIOSecsAgo  int64  `info:"master_last_io_seconds_ago"`
+	MasterSyncInProgress bool   `info:"master_sync_in_progress"`
+	SlaveReplOffset      int64  `info:"slave_repl_offset,replica_repl_offset"`
+	SlavePriority        int64  `info:"slave_priority,replica_priority"`
+	SlaveReadOnly        bool   `info:"slave_read_only,replica_read_only"`
+
+	// Replicas is only set when Role is "master", and contains the
+	// slave0, slave1, etc... fields, or replica0, replica1, etc... if the
+	// instance names them that way.
+	Replicas []InfoReplica
+}
+
+// InfoKeyspace describes a single database listed in the "keyspace" section of
+// an INFO reply.
+type InfoKeyspace struct {
+	Keys    int64 `info:"keys"`
+	Expires int64 `info:"expires"`
+	AvgTTL  int64 `info:"avg_ttl"`
+}
+
+// Info describes the reply to an INFO command, as described by:
+//	https://redis.io/commands/info
+//
+// Only the sections and fields which are commonly used for monitoring are
+// parsed into typed fields. Fields, or whole sections, which weren't present in
+// the reply are left as their zero value. All fields, including ones which
+// aren't parsed, are available in Fields.
+//
+// Info implements resp.Unmarshaler, and so may be used as the receiver of a Cmd
+// performing INFO. See also InfoCmd.
+type Info struct {
+	Server      InfoServer
+	Clients     InfoClients
+	Memory      InfoMemory
+	Persistence InfoPersistence
+	Replication InfoReplication
+
+	// Keyspace maps the number of each database listed in the "keyspace"
+	// section to its description. Databases without keys aren't listed.
+	Keyspace map[int]InfoKeyspace
+
+	// Fields maps each section name, lowercased, to the raw fields within that
+	// section.
+	Fields map[string]map[string]string
+}
+
+// setInfoFields sets the fields of the struct pointed to by into from the given
+// raw fields, according to their info tags. A tag may list multiple comma
+// separated names, e.g. a "slave" name followed by its "replica" equivalent,
+// in which case the first one present in raw is used. Fields without a
+// matching entry in raw are left alone.
+func setInfoFields(into interface{}, raw map[string]string) error {
+	vv := reflect.ValueOf(into).Elem()
+	tt := vv.Type()
+	for i := 0; i < tt.NumField(); i++ {
+		tag, ok := tt.Field(i).Tag.Lookup("info")
+		if !ok {
+			continue
+		}
+		var name, s string
+		for _, name = range strings.Split(tag, ",") {
+			if s, ok = raw[name]; ok {
+				break
+			}
+		}
+		if !ok {
+			continue
+		}
+
+		fv := vv.Field(i)
+		switch fv.Kind() {
+		case reflect.String:
+			fv.SetString(s)
+		case reflect.Int64:
+			n, err := strconv.ParseInt(s, 10, 64)
+			if err != nil {
+				return errors.Errorf("parsing INFO field %q: %w", name, err)
+			}
+			fv.SetInt(n)
+		case reflect.Float64:
+			f, err := strconv.ParseFloat(s, 64)
+			if err != nil {
+				return errors.Errorf("parsing INFO field %q: %w", name, err)
+			}
+			fv.SetFloat(f)
+		case reflect.Bool:
+			fv.SetBool(s == "1" || s == "yes")
+		default:
+			panic(errors.Errorf("unsupported INFO field type %s", fv.Type()))
+		}
+	}
+	return nil
+}
+
+// parseInfoKV parses a value of the form "a=1,b=2", as used by the keyspace
+// section and replica fields.
+func parseInfoKV(s string) map[string]string {
+	m := map[string]string{}
+	for _, kv := range strings.Split(s, ",") {
+		if i := strings.IndexByte(kv, '='); i >= 0 {
+			m[kv[:i]] = kv[i+1:]
+		}
+	}
+	return m
+}
+
+// ParseInfo parses the reply to an INFO command. See Info for details.
+func ParseInfo(s string) (Info, error) {
+	info := Info{Fields: map[string]map[string]string{}}
+
+	var section map[string]string
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if line == "" {
+			continue
+		} else if line[0] == '#' {
+			name := strings.ToLower(strings.TrimSpace(line[1:]))
+			section = map[string]string{}
+			info.Fields[name] = section
+			continue
+		}
+
+		i := strings.IndexByte(line, ':')
+		if i < 0 {
+			return Info{}, errors.Errorf("malformed INFO line %q", line)
+		} else if section == nil {
+			// fields before any section header are kept under an unnamed
+			// section, older versions of redis didn't have headers
+			section = map[string]string{}
+			info.Fields[""] = section
+		}
+		section[line[:i]] = line[i+1:]
+	}
+
+	sections := []struct {
+		name string
+		into interface{}
+	}{
+		{"server", &info.Server},
+		{"clients", &info.Clients},
+		{"memory", &info.Memory},
+		{"persistence", &info.Persistence},
+		{"replication", &info.Replication},
+	}
+	for _, sec := range sections {
+		if err := setInfoFields(sec.into, info.Fields[sec.name]); err != nil {
+			return Info{}, err
+		}
+	}
+
+	for i := 0; ; i++ {
+		v, ok := info.Fields["replication"]["slave"+strconv.Itoa(i)]
+		if !ok {
+			v, ok = info.Fields["replication"]["replica"+strconv.Itoa(i)]
+		}
+		if !ok {
+			break
+		}
+		var replica InfoReplica
+		if err := setInfoFields(&replica, parseInfoKV(v)); err != nil {
+			return Info{}, err
+		}
+		info.Replication.Replicas = append(info.Replication.Replicas, replica)
+	}
+
+	for k, v := range info.Fields["keyspace"] {
+		if !strings.HasPrefix(k, "db") {
+			continue
+		}
+		db, err := strconv.Atoi(k[2:])
+		if err != nil {
+			return Info{}, errors.Errorf("parsing INFO keyspace field %q: %w", k, err)
+		}
+		var ks InfoKeyspace
+		if err := setInfoFields(&ks, parseInfoKV(v)); err != nil {
+			return Info{}, err
+		}
+		if info.Keyspace == nil {
+			info.Keyspace = map[int]InfoKeyspace{}
+		}
+		info.Keyspace[db] = ks
+	}
+
+	return info, nil
+}
+
+// UnmarshalRESP implements the resp.Unmarshaler interface.
+func (info *Info) UnmarshalRESP(br *bufio.Reader) error {
+	var s string
+	if err := (resp2.Any{I: &s}).UnmarshalRESP(br); err != nil {
+		return err
+	}
+	parsed, err := ParseInfo(s)
+	if err != nil {
+		return err
+	}
+	*info = parsed
+	return nil
+}
+
+// InfoCmd returns a CmdAction which performs INFO, limited to the given
+// sections if any are given, and parses the reply into rcv.
+func InfoCmd(rcv *Info, sections ...string) CmdAction {
+	return Cmd(rcv, "INFO", sections...)
+}
@@ -0,0 +1,125 @@
+package radix
+
+import (
+	"strings"
+	. "testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseInfo(t *T) {
+	raw := strings.Join([]string{
+		"# Server",
+		"redis_version:6.0.9",
+		"redis_mode:standalone",
+		"os:Linux 5.4.0 x86_64",
+		"process_id:1",
+		"tcp_port:6379",
+		"uptime_in_seconds:1234",
+		"",
+		"# Clients",
+		"connected_clients:3",
+		"blocked_clients:1",
+		"",
+		"# Memory",
+		"used_memory:873488",
+		"maxmemory:0",
+		"maxmemory_policy:noeviction",
+		"mem_fragmentation_ratio:11.52",
+		"",
+		"# Persistence",
+		"loading:0",
+		"rdb_changes_since_last_save:5",
+		"rdb_last_bgsave_status:ok",
+		"aof_enabled:1",
+		"",
+		"# Replication",
+		"role:master",
+		"connected_slaves:2",
+		"slave0:ip=10.0.0.2,port=6379,state=online,offset=100,lag=0",
+		"slave1:ip=10.0.0.3,port=6380,state=wait_bgsave,offset=0,lag=1",
+		"master_repl_offset:100",
+		"",
+		"# Keyspace",
+		"db0:keys=10,expires=2,avg_ttl=3000",
+		"db3:keys=1,expires=0,avg_ttl=0",
+		"",
+	}, "\r\n")
+
+	info, err := ParseInfo(raw)
+	require.Nil(t, err)
+
+	assert.Equal(t, InfoServer{
+		RedisVersion:    "6.0.9",
+		RedisMode:       "standalone",
+		OS:              "Linux 5.4.0 x86_64",
+		ProcessID:       1,
+		TCPPort:         6379,
+		UptimeInSeconds: 1234,
+	}, info.Server)
+	assert.Equal(t, InfoClients{ConnectedClients: 3, BlockedClients: 1}, info.Clients)
+	assert.Equal(t, InfoMemory{
+		UsedMemory:            873488,
+		MaxMemoryPolicy:       "noeviction",
+		MemFragmentationRatio: 11.52,
+	}, info.Memory)
+	assert.Equal(t, InfoPersistence{
+		RDBChangesSinceLastSave: 5,
+		RDBLastBgsaveStatus:     "ok",
+		AOFEnabled:              true,
+	}, info.Persistence)
+	assert.Equal(t, InfoReplication{
+		Role:             "master",
+		ConnectedSlaves:  2,
+		MasterReplOffset: 100,
+		Replicas: []InfoReplica{
+			{IP: "10.0.0.2", Port: 6379, State: "online", Offset: 100},
+			{IP: "10.0.0.3", Port: 6380, State: "wait_bgsave", Lag: 1},
+		},
+	}, info.Replication)
+	assert.Equal(t, map[int]InfoKeyspace{
+		0: {Keys: 10, Expires: 2, AvgTTL: 3000},
+		3: {Keys: 1},
+	}, info.Keyspace)
+	assert.Equal(t, "6.0.9", info.Fields["server"]["redis_version"])
+	assert.Len(t, info.Fields, 6)
+
+	// "replica" may be used in place of "slave" in field names
+	info, err = ParseInfo(strings.Join([]string{
+		"# Replication",
+		"role:slave",
+		"replica_repl_offset:50",
+		"replica_priority:100",
+		"replica_read_only:1",
+		"connected_replicas:1",
+		"replica0:ip=10.0.0.4,port=6379,state=online,offset=50,lag=0",
+	}, "\r\n"))
+	require.Nil(t, err)
+	assert.Equal(t, InfoReplication{
+		Role:            "slave",
+		ConnectedSlaves: 1,
+		SlaveReplOffset: 50,
+		SlavePriority:   100,
+		SlaveReadOnly:   true,
+		Replicas: []InfoReplica{
+			{IP: "10.0.0.4", Port: 6379, State: "online", Offset: 50},
+		},
+	}, info.Replication)
+
+	_, err = ParseInfo("# Server\r\nfoo\r\n")
+	assert.NotNil(t, err)
+
+	_, err = ParseInfo("# Clients\r\nconnected_clients:lots\r\n")
+	assert.NotNil(t, err)
+}
+
+func TestInfoCmd(t *T) {
+	c := dial()
+	defer c.Close()
+
+	var info Info
+	require.Nil(t, c.Do(InfoCmd(&info, "clients")))
+	assert.True(t, info.Clients.ConnectedClients > 0)
+	assert.Contains(t, info.Fields, "clients")
+}
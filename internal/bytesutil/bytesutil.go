@@ -42,6 +42,12 @@ func AnyIntToInt64(m interface{}) int64 {
 	panic(fmt.Sprintf("anyIntToInt64 got bad arg: %#v", m))
 }
 
+// maxPooledBytes is the largest capacity a byte slice may have and still be put
+// back into the pool. Larger slices, e.g. from unmarshaling a single large bulk
+// string, are left to the garbage collector so that they aren't kept alive by
+// the pool indefinitely.
+const maxPooledBytes = 64 * 1024
+
 var bytePool = sync.Pool{
 	New: func() interface{} {
 		b := make([]byte, 0, 64)
@@ -60,6 +66,9 @@ func GetBytes() *[]byte {
 //
 // After calling PutBytes the given pointer and byte slice must not be accessed anymore.
 func PutBytes(b *[]byte) {
+	if cap(*b) > maxPooledBytes {
+		return
+	}
 	*b = (*b)[:0]
 	bytePool.Put(b)
 }
@@ -124,9 +133,26 @@ func Expand(b []byte, n int) []byte {
 	return b[:n]
 }
 
+// BufferedLine reads a line from br, including the trailing \n. Like
+// ReadSlice, the returned bytes are only valid until the next read, unless the
+// line didn't fit in br's buffer, in which case it's copied out of it.
+func BufferedLine(br *bufio.Reader) ([]byte, error) {
+	b, err := br.ReadSlice('\n')
+	if err != bufio.ErrBufferFull {
+		return b, err
+	}
+
+	line := append([]byte(nil), b...)
+	for err == bufio.ErrBufferFull {
+		b, err = br.ReadSlice('\n')
+		line = append(line, b...)
+	}
+	return line, err
+}
+
 // BufferedBytesDelim reads a line from br and checks that the line ends with \r\n, returning the line without \r\n.
 func BufferedBytesDelim(br *bufio.Reader) ([]byte, error) {
-	b, err := br.ReadSlice('\n')
+	b, err := BufferedLine(br)
 	if err != nil {
 		return nil, err
 	} else if len(b) < 2 || b[len(b)-2] != '\r' {
@@ -155,6 +181,71 @@ func ReadNAppend(r io.Reader, b []byte, n int) ([]byte, error) {
 	return b, err
 }
 
+// ReadNWrite reads exactly n bytes from r and writes them to w.
+//
+// If r is a *bufio.Reader then any bytes already in its buffer are written to w
+// directly out of that buffer. The remaining bytes are read using w's ReadFrom
+// method if it has one, and otherwise are copied through a pooled buffer, so
+// that no intermediate buffer needs to be allocated.
+//
+// If w returns an error then the remainder of the n bytes is discarded from r,
+// so that r is left at the end of the n bytes, and a resp.ErrDiscarded wrapping
+// the error is returned.
+func ReadNWrite(r io.Reader, w io.Writer, n int) error {
+	discardRest := func(err error, rest int) error {
+		if discardErr := ReadNDiscard(r, rest); discardErr != nil {
+			return discardErr
+		}
+		return resp.ErrDiscarded{Err: err}
+	}
+
+	if br, ok := r.(*bufio.Reader); ok && n > 0 {
+		chunk := br.Buffered()
+		if chunk > n {
+			chunk = n
+		}
+		// Peek won't block or fail, since the bytes are already buffered
+		b, _ := br.Peek(chunk)
+		_, err := w.Write(b)
+		br.Discard(chunk)
+		n -= chunk
+		if err != nil {
+			return discardRest(err, n)
+		}
+	}
+
+	if n == 0 {
+		return nil
+	}
+
+	lr := &io.LimitedReader{R: r, N: int64(n)}
+	var err error
+	if rf, ok := w.(io.ReaderFrom); ok {
+		_, err = rf.ReadFrom(lr)
+	} else {
+		scratch := GetBytes()
+		*scratch = (*scratch)[:cap(*scratch)]
+		if len(*scratch) < copyBufSize {
+			*scratch = make([]byte, copyBufSize)
+		}
+		_, err = io.CopyBuffer(w, lr, *scratch)
+		PutBytes(scratch)
+	}
+
+	if err != nil {
+		// the error may have come from either r or w. If it came from r then
+		// discarding will also fail, and that error is returned instead.
+		return discardRest(err, int(lr.N))
+	} else if lr.N > 0 {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+
+// copyBufSize is the size of the buffer ReadNWrite copies through, which is the
+// same as io.Copy uses.
+const copyBufSize = 32 * 1024
+
 // ReadNDiscard discards exactly n bytes from r.
 func ReadNDiscard(r io.Reader, n int) error {
 	type discarder interface {
@@ -226,6 +317,27 @@ func ReadUint(r io.Reader, n int) (uint64, error) {
 	return ui, nil
 }
 
+// ReadBool reads the next n bytes from r as a boolean. Unsigned integers are
+// true if they are non-zero, otherwise any value accepted by strconv.ParseBool
+// may be used.
+func ReadBool(r io.Reader, n int) (bool, error) {
+	scratch := GetBytes()
+	defer PutBytes(scratch)
+
+	var err error
+	if *scratch, err = ReadNAppend(r, *scratch, n); err != nil {
+		return false, err
+	}
+	if ui, err := ParseUint(*scratch); err == nil {
+		return ui > 0, nil
+	}
+	b, err := strconv.ParseBool(string(*scratch))
+	if err != nil {
+		return false, resp.ErrDiscarded{Err: err}
+	}
+	return b, nil
+}
+
 // ReadFloat reads the next n bytes from r as a 64 bit floating point number with the given precision.
 func ReadFloat(r io.Reader, precision, n int) (float64, error) {
 	scratch := GetBytes()
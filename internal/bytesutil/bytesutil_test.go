@@ -5,12 +5,16 @@ import (
 	"bytes"
 	crand "crypto/rand"
 	"io"
+	"io/ioutil"
 	"math/rand"
 	. "testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	errors "golang.org/x/xerrors"
+
+	"github.com/mediocregopher/radix/v3/resp"
 )
 
 func TestReadNAppend(t *T) {
@@ -21,6 +25,22 @@ func TestReadNAppend(t *T) {
 	assert.Equal(t, buf, []byte("hello world"))
 }
 
+func TestBufferedBytesDelim(t *T) {
+	long := bytes.Repeat([]byte("a"), 100)
+	br := bufio.NewReaderSize(bytes.NewReader(append(long, "\r\nfoo\r\n"...)), 16)
+
+	b, err := BufferedBytesDelim(br)
+	require.Nil(t, err)
+	assert.Equal(t, long, b)
+
+	b, err = BufferedBytesDelim(br)
+	require.Nil(t, err)
+	assert.Equal(t, []byte("foo"), b)
+
+	_, err = BufferedBytesDelim(br)
+	assert.Equal(t, io.EOF, err)
+}
+
 type discarder struct {
 	didDiscard bool
 	*bufio.Reader
@@ -77,3 +97,58 @@ func TestReadNDiscard(t *T) {
 	// edge cases
 	assert(testT{n: 0, discarder: true})
 }
+
+type errWriter struct {
+	n   int
+	err error
+}
+
+func (ew *errWriter) Write(b []byte) (int, error) {
+	ew.n += len(b)
+	return len(b), ew.err
+}
+
+func TestReadNWrite(t *T) {
+	src := make([]byte, 1000)
+	_, err := crand.Read(src)
+	require.Nil(t, err)
+	const n = 900
+
+	for _, buffered := range []bool{false, true} {
+		for _, readerFrom := range []bool{false, true} {
+			var r io.Reader = bytes.NewReader(src)
+			if buffered {
+				br := bufio.NewReaderSize(r, 16)
+				br.Peek(1)
+				r = br
+			}
+
+			// bytes.Buffer implements io.ReaderFrom, wrapping it hides that
+			var dst bytes.Buffer
+			var w io.Writer = &dst
+			if !readerFrom {
+				w = struct{ io.Writer }{&dst}
+			}
+
+			require.Nil(t, ReadNWrite(r, w, n))
+			assert.Equal(t, src[:n], dst.Bytes())
+
+			rest, err := ioutil.ReadAll(r)
+			require.Nil(t, err)
+			assert.Equal(t, src[n:], rest)
+		}
+	}
+
+	// if the writer errors the rest of the bytes should still be consumed
+	writeErr := errors.New("write failed")
+	ew := &errWriter{err: writeErr}
+	r := bufio.NewReaderSize(bytes.NewReader(src), 16)
+	r.Peek(1)
+	err = ReadNWrite(r, ew, n)
+	assert.True(t, errors.Is(err, writeErr))
+	assert.True(t, errors.As(err, new(resp.ErrDiscarded)))
+	assert.Equal(t, 16, ew.n)
+	rest, err := ioutil.ReadAll(r)
+	require.Nil(t, err)
+	assert.Equal(t, src[n:], rest)
+}
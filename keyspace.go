@@ -0,0 +1,265 @@
+package radix
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+
+	errors "golang.org/x/xerrors"
+)
+
+// KeyspaceEvent describes a single keyspace notification, as described by:
+//
+//	https://redis.io/topics/notifications
+type KeyspaceEvent struct {
+	// DB is the database the event occurred in.
+	DB int
+
+	// Key is the key which the operation was performed on.
+	Key string
+
+	// Op is the name of the operation, e.g. "set", "del", or "expired".
+	Op string
+}
+
+const (
+	keyspacePrefix = "__keyspace@"
+	keyeventPrefix = "__keyevent@"
+)
+
+// parseKeyspaceChannel parses the channel and message of a keyspace or keyevent
+// notification into a KeyspaceEvent.
+func parseKeyspaceChannel(channel, msg string) (KeyspaceEvent, error) {
+	var isKeyspace bool
+	switch {
+	case strings.HasPrefix(channel, keyspacePrefix):
+		isKeyspace = true
+		channel = channel[len(keyspacePrefix):]
+	case strings.HasPrefix(channel, keyeventPrefix):
+		channel = channel[len(keyeventPrefix):]
+	default:
+		return KeyspaceEvent{}, errors.Errorf("unknown notification channel %q", channel)
+	}
+
+	i := strings.Index(channel, "__:")
+	if i < 0 {
+		return KeyspaceEvent{}, errors.Errorf("malformed notification channel %q", channel)
+	}
+
+	db, err := strconv.Atoi(channel[:i])
+	if err != nil {
+		return KeyspaceEvent{}, errors.Errorf("malformed notification channel %q: %w", channel, err)
+	}
+
+	if isKeyspace {
+		return KeyspaceEvent{DB: db, Key: channel[i+3:], Op: msg}, nil
+	}
+	return KeyspaceEvent{DB: db, Key: msg, Op: channel[i+3:]}, nil
+}
+
+type keyspaceNotifierOpts struct {
+	cf     ConnFunc
+	config string
+	db     int
+	keys   string
+}
+
+// KeyspaceNotifierOpt is an optional behavior which can be applied to the
+// NewKeyspaceNotifier function to effect its behavior.
+type KeyspaceNotifierOpt func(*keyspaceNotifierOpts)
+
+// KeyspaceNotifierConnFunc tells the KeyspaceNotifier to use the given ConnFunc
+// when connecting to redis.
+func KeyspaceNotifierConnFunc(cf ConnFunc) KeyspaceNotifierOpt {
+	return func(ko *keyspaceNotifierOpts) {
+		ko.cf = cf
+	}
+}
+
+// KeyspaceNotifierConfig tells the KeyspaceNotifier to set the
+// notify-keyspace-events config parameter to the given flags on every
+// connection it makes, so that notifications stay enabled even if redis is
+// restarted. If flags is empty then the config parameter is left as-is.
+//
+// If the flags contain "K" then keyspace notifications are subscribed to, and
+// if they contain "E" then keyevent notifications are subscribed to. Since
+// these describe the same operations, enabling both will cause each event to
+// be delivered twice. If flags is empty then keyspace notifications are
+// subscribed to, and if flags is non-empty but contains neither "K" nor "E"
+// then NewKeyspaceNotifier returns an error, since nothing would be delivered.
+func KeyspaceNotifierConfig(flags string) KeyspaceNotifierOpt {
+	return func(ko *keyspaceNotifierOpts) {
+		ko.config = flags
+	}
+}
+
+// KeyspaceNotifierDB tells the KeyspaceNotifier to only deliver events which
+// occur in the given database. If db is negative then events from all
+// databases are delivered.
+func KeyspaceNotifierDB(db int) KeyspaceNotifierOpt {
+	return func(ko *keyspaceNotifierOpts) {
+		ko.db = db
+	}
+}
+
+// KeyspaceNotifierKeys tells the KeyspaceNotifier to only deliver events for
+// keys matching the given glob-style pattern. This only has an effect on
+// keyspace notifications, see KeyspaceNotifierConfig.
+func KeyspaceNotifierKeys(pattern string) KeyspaceNotifierOpt {
+	return func(ko *keyspaceNotifierOpts) {
+		ko.keys = pattern
+	}
+}
+
+// KeyspaceNotifier delivers keyspace notifications from a redis instance as
+// KeyspaceEvents. See NewKeyspaceNotifier.
+type KeyspaceNotifier struct {
+	ps    PubSubConn
+	msgCh chan PubSubMessage
+
+	// closeCh is closed when Close is called, and doneCh once the PubSubConn
+	// has been closed
+	closeCh, doneCh chan struct{}
+	wg              sync.WaitGroup
+	closeOnce       sync.Once
+	closeErr        error
+
+	// Any errors encountered while parsing notifications will be written to
+	// this channel. If nothing is reading the channel the errors will be
+	// dropped. The channel will be closed when Close is called.
+	ErrCh chan error
+}
+
+// NewKeyspaceNotifier subscribes to the keyspace notifications of the redis
+// instance at the given address, and writes each one to the given channel as a
+// KeyspaceEvent. The channel should be read from continuously until Close is
+// called on the returned KeyspaceNotifier, otherwise the notifications will
+// back up.
+//
+// The subscription is made using PersistentPubSubWithOpts, so if the
+// connection is lost it will be re-established and re-subscribed
+// automatically. Notifications which occur while disconnected are lost.
+//
+// When using a redis cluster each instance only publishes notifications for the
+// keys it holds, so a KeyspaceNotifier must be created for each primary.
+//
+// The default options NewKeyspaceNotifier uses are:
+//
+//	KeyspaceNotifierConnFunc(DefaultConnFunc)
+//	KeyspaceNotifierConfig("KA")
+//	KeyspaceNotifierDB(-1)
+//	KeyspaceNotifierKeys("*")
+func NewKeyspaceNotifier(network, addr string, eventCh chan<- KeyspaceEvent, opts ...KeyspaceNotifierOpt) (*KeyspaceNotifier, error) {
+	var ko keyspaceNotifierOpts
+	defaultKeyspaceNotifierOpts := []KeyspaceNotifierOpt{
+		KeyspaceNotifierConnFunc(DefaultConnFunc),
+		KeyspaceNotifierConfig("KA"),
+		KeyspaceNotifierDB(-1),
+		KeyspaceNotifierKeys("*"),
+	}
+	for _, opt := range append(defaultKeyspaceNotifierOpts, opts...) {
+		opt(&ko)
+	}
+
+	if ko.config != "" && !strings.ContainsAny(ko.config, "KE") {
+		return nil, errors.Errorf("notify-keyspace-events flags %q contain neither K nor E", ko.config)
+	}
+
+	cf := ko.cf
+	if ko.config != "" {
+		cf = func(network, addr string) (Conn, error) {
+			c, err := ko.cf(network, addr)
+			if err != nil {
+				return nil, err
+			} else if err := c.Do(Cmd(nil, "CONFIG", "SET", "notify-keyspace-events", ko.config)); err != nil {
+				c.Close()
+				return nil, err
+			}
+			return c, nil
+		}
+	}
+
+	db := "*"
+	if ko.db >= 0 {
+		db = strconv.Itoa(ko.db)
+	}
+
+	var patterns []string
+	if ko.config == "" || strings.Contains(ko.config, "K") {
+		patterns = append(patterns, keyspacePrefix+db+"__:"+ko.keys)
+	}
+	if strings.Contains(ko.config, "E") {
+		patterns = append(patterns, keyeventPrefix+db+"__:*")
+	}
+
+	// PersistentPubSub will retry connecting forever, so first make sure that
+	// a connection can actually be made.
+	c, err := cf(network, addr)
+	if err != nil {
+		return nil, err
+	}
+	c.Close()
+
+	ps, err := PersistentPubSubWithOpts(network, addr, PersistentPubSubConnFunc(cf))
+	if err != nil {
+		return nil, err
+	}
+
+	kn := &KeyspaceNotifier{
+		ps:      ps,
+		msgCh:   make(chan PubSubMessage, 32),
+		closeCh: make(chan struct{}),
+		doneCh:  make(chan struct{}),
+		ErrCh:   make(chan error, 1),
+	}
+
+	kn.wg.Add(1)
+	go func() {
+		defer kn.wg.Done()
+		kn.spin(eventCh)
+	}()
+
+	if err := ps.PSubscribe(kn.msgCh, patterns...); err != nil {
+		kn.Close()
+		return nil, err
+	}
+	return kn, nil
+}
+
+func (kn *KeyspaceNotifier) spin(eventCh chan<- KeyspaceEvent) {
+	for {
+		select {
+		case m := <-kn.msgCh:
+			e, err := parseKeyspaceChannel(m.Channel, string(m.Message))
+			if err != nil {
+				select {
+				case kn.ErrCh <- err:
+				default:
+				}
+				continue
+			}
+
+			// once Close has been called events are dropped, so that the
+			// PubSubConn doesn't block on msgCh while closing
+			select {
+			case eventCh <- e:
+			case <-kn.closeCh:
+			}
+		case <-kn.doneCh:
+			return
+		}
+	}
+}
+
+// Close stops the delivery of KeyspaceEvents and closes the underlying
+// connection.
+func (kn *KeyspaceNotifier) Close() error {
+	kn.closeOnce.Do(func() {
+		close(kn.closeCh)
+		kn.closeErr = kn.ps.Close()
+		close(kn.doneCh)
+		kn.wg.Wait()
+		close(kn.ErrCh)
+	})
+	return kn.closeErr
+}
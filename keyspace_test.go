@@ -0,0 +1,146 @@
+package radix
+
+import (
+	"strings"
+	"sync"
+	. "testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	errors "golang.org/x/xerrors"
+
+	"github.com/mediocregopher/radix/v3/resp/resp2"
+)
+
+func TestParseKeyspaceChannel(t *T) {
+	type test struct {
+		channel, msg string
+		exp          KeyspaceEvent
+		expErr       bool
+	}
+
+	tests := []test{
+		{channel: "__keyspace@0__:foo", msg: "set", exp: KeyspaceEvent{DB: 0, Key: "foo", Op: "set"}},
+		{channel: "__keyspace@12__:foo:bar", msg: "del", exp: KeyspaceEvent{DB: 12, Key: "foo:bar", Op: "del"}},
+		{channel: "__keyevent@3__:expired", msg: "foo", exp: KeyspaceEvent{DB: 3, Key: "foo", Op: "expired"}},
+		{channel: "foo", msg: "set", expErr: true},
+		{channel: "__keyspace@0", msg: "set", expErr: true},
+		{channel: "__keyspace@a__:foo", msg: "set", expErr: true},
+	}
+
+	for _, test := range tests {
+		e, err := parseKeyspaceChannel(test.channel, test.msg)
+		if test.expErr {
+			assert.NotNil(t, err, "channel:%q", test.channel)
+			continue
+		}
+		require.Nil(t, err, "channel:%q", test.channel)
+		assert.Equal(t, test.exp, e)
+	}
+}
+
+func TestKeyspaceNotifier(t *T) {
+	var l sync.Mutex
+	var configs []string
+	var stubCh chan<- PubSubMessage
+	cf := func(network, addr string) (Conn, error) {
+		conn, ch := PubSubStub(network, addr, func(args []string) interface{} {
+			if strings.ToUpper(args[0]) != "CONFIG" {
+				return resp2.Error{E: errors.Errorf("unknown command %q", args[0])}
+			} else if args[3] == "Kbad" {
+				return resp2.Error{E: errors.New("ERR invalid flags")}
+			}
+			l.Lock()
+			defer l.Unlock()
+			configs = append(configs, args[3])
+			return resp2.SimpleString{S: "OK"}
+		})
+		l.Lock()
+		stubCh = ch
+		l.Unlock()
+		return conn, nil
+	}
+
+	t.Run("Keyspace", func(t *T) {
+		eventCh := make(chan KeyspaceEvent)
+		kn, err := NewKeyspaceNotifier("tcp", "127.0.0.1:6379", eventCh,
+			KeyspaceNotifierConnFunc(cf),
+			KeyspaceNotifierDB(2),
+			KeyspaceNotifierKeys("foo*"),
+		)
+		require.Nil(t, err)
+		defer kn.Close()
+
+		l.Lock()
+		assert.Equal(t, []string{"KA", "KA"}, configs)
+		ch := stubCh
+		l.Unlock()
+
+		ch <- PubSubMessage{
+			Pattern: "__keyspace@2__:foo*",
+			Channel: "__keyspace@2__:foobar",
+			Message: []byte("lpush"),
+		}
+		assert.Equal(t, KeyspaceEvent{DB: 2, Key: "foobar", Op: "lpush"}, <-eventCh)
+
+		// messages for patterns which weren't subscribed to aren't delivered by
+		// the stub, so this checks that the pattern is correct
+		ch <- PubSubMessage{
+			Pattern: "__keyevent@2__:*",
+			Channel: "__keyevent@2__:del",
+			Message: []byte("foo"),
+		}
+		ch <- PubSubMessage{
+			Pattern: "__keyspace@2__:foo*",
+			Channel: "__keyspace@2__:foobaz",
+			Message: []byte("del"),
+		}
+		assert.Equal(t, KeyspaceEvent{DB: 2, Key: "foobaz", Op: "del"}, <-eventCh)
+	})
+
+	t.Run("Keyevent", func(t *T) {
+		eventCh := make(chan KeyspaceEvent)
+		kn, err := NewKeyspaceNotifier("tcp", "127.0.0.1:6379", eventCh,
+			KeyspaceNotifierConnFunc(cf),
+			KeyspaceNotifierConfig("Eg$"),
+		)
+		require.Nil(t, err)
+		defer kn.Close()
+
+		l.Lock()
+		ch := stubCh
+		l.Unlock()
+
+		ch <- PubSubMessage{
+			Pattern: "__keyevent@*__:*",
+			Channel: "__keyevent@0__:del",
+			Message: []byte("foo"),
+		}
+		assert.Equal(t, KeyspaceEvent{DB: 0, Key: "foo", Op: "del"}, <-eventCh)
+	})
+
+	t.Run("BadConfig", func(t *T) {
+		_, err := NewKeyspaceNotifier("tcp", "127.0.0.1:6379", make(chan KeyspaceEvent),
+			KeyspaceNotifierConnFunc(cf),
+			KeyspaceNotifierConfig("Kbad"),
+		)
+		assert.NotNil(t, err)
+	})
+
+	t.Run("NoKeyspaceOrKeyevent", func(t *T) {
+		l.Lock()
+		configs = nil
+		l.Unlock()
+
+		// without K or E no notifications would be delivered
+		_, err := NewKeyspaceNotifier("tcp", "127.0.0.1:6379", make(chan KeyspaceEvent),
+			KeyspaceNotifierConnFunc(cf),
+			KeyspaceNotifierConfig("A"),
+		)
+		assert.NotNil(t, err)
+
+		l.Lock()
+		assert.Empty(t, configs)
+		l.Unlock()
+	})
+}
@@ -0,0 +1,131 @@
+package radix
+
+import (
+	"bufio"
+	"time"
+
+	errors "golang.org/x/xerrors"
+
+	"github.com/mediocregopher/radix/v3/resp/resp2"
+)
+
+// LatencyEvent describes the latest latency spike of a single event, as
+// returned by LATENCY LATEST.
+type LatencyEvent struct {
+	// Name is the name of the event, e.g. "command" or "fast-command".
+	Name string
+
+	// Timestamp is when the latest spike occurred.
+	Timestamp time.Time
+
+	// Latest is the latency of the latest spike, and Max is the greatest
+	// latency seen for the event. Both have millisecond precision.
+	Latest, Max time.Duration
+}
+
+// LatencySample describes a single latency spike of an event, as returned by
+// LATENCY HISTORY.
+type LatencySample struct {
+	Timestamp time.Time
+
+	// Latency has millisecond precision.
+	Latency time.Duration
+}
+
+type latencyEvents []LatencyEvent
+
+func (ee *latencyEvents) UnmarshalRESP(br *bufio.Reader) error {
+	var arrHead resp2.ArrayHeader
+	if err := arrHead.UnmarshalRESP(br); err != nil {
+		return err
+	}
+
+	*ee = make(latencyEvents, arrHead.N)
+	for i := range *ee {
+		var eventHead resp2.ArrayHeader
+		if err := eventHead.UnmarshalRESP(br); err != nil {
+			return err
+		} else if eventHead.N != 4 {
+			return errors.Errorf("malformed latency event with %d elements", eventHead.N)
+		}
+
+		var name string
+		var ts, latest, max int64
+		if err := (resp2.Any{I: &name}).UnmarshalRESP(br); err != nil {
+			return err
+		}
+		for _, n := range []*int64{&ts, &latest, &max} {
+			if err := (resp2.Any{I: n}).UnmarshalRESP(br); err != nil {
+				return err
+			}
+		}
+
+		(*ee)[i] = LatencyEvent{
+			Name:      name,
+			Timestamp: time.Unix(ts, 0),
+			Latest:    time.Duration(latest) * time.Millisecond,
+			Max:       time.Duration(max) * time.Millisecond,
+		}
+	}
+	return nil
+}
+
+type latencySamples []LatencySample
+
+func (ss *latencySamples) UnmarshalRESP(br *bufio.Reader) error {
+	var arrHead resp2.ArrayHeader
+	if err := arrHead.UnmarshalRESP(br); err != nil {
+		return err
+	}
+
+	*ss = make(latencySamples, arrHead.N)
+	for i := range *ss {
+		var sampleHead resp2.ArrayHeader
+		if err := sampleHead.UnmarshalRESP(br); err != nil {
+			return err
+		} else if sampleHead.N != 2 {
+			return errors.Errorf("malformed latency sample with %d elements", sampleHead.N)
+		}
+
+		var ts, latency int64
+		for _, n := range []*int64{&ts, &latency} {
+			if err := (resp2.Any{I: n}).UnmarshalRESP(br); err != nil {
+				return err
+			}
+		}
+		(*ss)[i] = LatencySample{
+			Timestamp: time.Unix(ts, 0),
+			Latency:   time.Duration(latency) * time.Millisecond,
+		}
+	}
+	return nil
+}
+
+// LatencyLatestCmd returns a CmdAction which performs LATENCY LATEST, writing
+// the latest latency spike of each event into rcv.
+func LatencyLatestCmd(rcv *[]LatencyEvent) CmdAction {
+	return Cmd((*latencyEvents)(rcv), "LATENCY", "LATEST")
+}
+
+// LatencyHistoryCmd returns a CmdAction which performs LATENCY HISTORY, writing
+// the latency spikes of the given event into rcv, oldest first.
+func LatencyHistoryCmd(rcv *[]LatencySample, event string) CmdAction {
+	return Cmd((*latencySamples)(rcv), "LATENCY", "HISTORY", event)
+}
+
+// LatencyResetCmd returns a CmdAction which performs LATENCY RESET, resetting
+// the latency spikes of the given events, or of all events if none are given.
+// The number of events reset is written into rcv, if it's not nil.
+func LatencyResetCmd(rcv *int, events ...string) CmdAction {
+	args := append([]string{"RESET"}, events...)
+	if rcv == nil {
+		return Cmd(nil, "LATENCY", args...)
+	}
+	return Cmd(rcv, "LATENCY", args...)
+}
+
+// LatencyDoctorCmd returns a CmdAction which performs LATENCY DOCTOR, writing
+// the human readable report into rcv.
+func LatencyDoctorCmd(rcv *string) CmdAction {
+	return Cmd(rcv, "LATENCY", "DOCTOR")
+}
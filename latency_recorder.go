@@ -0,0 +1,253 @@
+package radix
+
+import (
+	"context"
+	"math"
+	"math/bits"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// latencyHistogram is a histogram of durations, in nanoseconds, in the style of
+// an HDR histogram: values are counted in buckets whose width grows with the
+// value, such that every value is recorded with a relative error of at most
+// 1/histSubBuckets. Recording is lock-free.
+type latencyHistogram struct {
+	counts   [histBuckets]int64
+	sum      int64
+	min, max int64
+}
+
+func newLatencyHistogram() *latencyHistogram {
+	return &latencyHistogram{min: math.MaxInt64}
+}
+
+const (
+	histSubBucketBits = 5
+	histSubBuckets    = 1 << histSubBucketBits
+
+	// values below 2*histSubBuckets are each counted in their own bucket,
+	// the rest in histSubBuckets buckets for each power of two. Values are
+	// never negative, so have at most 63 bits.
+	histBuckets = (64 - histSubBucketBits) * histSubBuckets
+)
+
+func histBucketIdx(v uint64) int {
+	if v < 2*histSubBuckets {
+		return int(v)
+	}
+	shift := bits.Len64(v) - histSubBucketBits - 1
+	return shift*histSubBuckets + int(v>>uint(shift))
+}
+
+// histBucketRange returns the lowest and highest values counted by the bucket
+// at the given index.
+func histBucketRange(idx int) (uint64, uint64) {
+	if idx < 2*histSubBuckets {
+		return uint64(idx), uint64(idx)
+	}
+	shift := uint(idx/histSubBuckets - 1)
+	top := uint64(idx%histSubBuckets + histSubBuckets)
+	return top << shift, (top+1)<<shift - 1
+}
+
+func (h *latencyHistogram) record(d time.Duration) {
+	v := int64(d)
+	if v < 0 {
+		v = 0
+	}
+	atomic.AddInt64(&h.counts[histBucketIdx(uint64(v))], 1)
+	atomic.AddInt64(&h.sum, v)
+
+	for {
+		max := atomic.LoadInt64(&h.max)
+		if v <= max || atomic.CompareAndSwapInt64(&h.max, max, v) {
+			break
+		}
+	}
+	for {
+		min := atomic.LoadInt64(&h.min)
+		if v >= min || atomic.CompareAndSwapInt64(&h.min, min, v) {
+			break
+		}
+	}
+}
+
+// LatencyStats describes the latencies recorded by a LatencyRecorder for a
+// single command. Percentiles are accurate to within ~3%.
+type LatencyStats struct {
+	Count          int64
+	Min, Max, Mean time.Duration
+	P50, P90, P99  time.Duration
+	P999           time.Duration
+}
+
+func (h *latencyHistogram) stats() LatencyStats {
+	// take a copy of the counts so that all percentiles are calculated from
+	// the same data
+	var counts [histBuckets]int64
+	var total int64
+	for i := range counts {
+		counts[i] = atomic.LoadInt64(&h.counts[i])
+		total += counts[i]
+	}
+	if total == 0 {
+		return LatencyStats{}
+	}
+
+	s := LatencyStats{
+		Count: total,
+		Min:   time.Duration(atomic.LoadInt64(&h.min)),
+		Max:   time.Duration(atomic.LoadInt64(&h.max)),
+		// a concurrent record may have been counted without having been
+		// added to sum yet, which makes the mean slightly low at worst
+		Mean: time.Duration(atomic.LoadInt64(&h.sum) / total),
+	}
+	if s.Min > s.Max {
+		// a concurrent record hasn't finished updating min and max yet
+		s.Min = s.Max
+	}
+
+	percentile := func(p float64) time.Duration {
+		rank := int64(math.Ceil(p / 100 * float64(total)))
+		if rank < 1 {
+			rank = 1
+		}
+		var seen int64
+		for i, n := range counts {
+			if seen += n; seen < rank {
+				continue
+			}
+			lo, hi := histBucketRange(i)
+			d := time.Duration(lo + (hi-lo)/2)
+			if d < s.Min {
+				d = s.Min
+			} else if d > s.Max {
+				d = s.Max
+			}
+			return d
+		}
+		return s.Max
+	}
+
+	s.P50, s.P90 = percentile(50), percentile(90)
+	s.P99, s.P999 = percentile(99), percentile(99.9)
+	return s
+}
+
+// LatencyRecorder records the latency of Actions performed through the Clients
+// it wraps, in a histogram per command, which can then be queried for
+// percentiles. It's intended for benchmarking and for detecting performance
+// regressions without the need for an external metrics system.
+//
+// Recording a latency doesn't take any locks once a command has been seen, and
+// each command's histogram takes a fixed ~15KB of memory.
+type LatencyRecorder struct {
+	l     sync.RWMutex
+	hists map[string]*latencyHistogram
+}
+
+// NewLatencyRecorder initializes and returns an empty LatencyRecorder.
+func NewLatencyRecorder() *LatencyRecorder {
+	return &LatencyRecorder{hists: map[string]*latencyHistogram{}}
+}
+
+func (lr *LatencyRecorder) hist(cmd string) *latencyHistogram {
+	lr.l.RLock()
+	h := lr.hists[cmd]
+	lr.l.RUnlock()
+	if h != nil {
+		return h
+	}
+
+	lr.l.Lock()
+	defer lr.l.Unlock()
+	if h = lr.hists[cmd]; h == nil {
+		h = newLatencyHistogram()
+		lr.hists[cmd] = h
+	}
+	return h
+}
+
+// Record records the latency of a single performance of the given command.
+// This is normally called by a Client returned from Wrap, but may be called
+// directly in order to record the latency of other operations.
+func (lr *LatencyRecorder) Record(cmd string, d time.Duration) {
+	lr.hist(cmd).record(d)
+}
+
+// Commands returns the names of the commands which latencies have been
+// recorded for, sorted.
+func (lr *LatencyRecorder) Commands() []string {
+	lr.l.RLock()
+	defer lr.l.RUnlock()
+	cmds := make([]string, 0, len(lr.hists))
+	for cmd := range lr.hists {
+		cmds = append(cmds, cmd)
+	}
+	sort.Strings(cmds)
+	return cmds
+}
+
+// Stats returns the LatencyStats of the given command. If no latencies have
+// been recorded for it then the zero LatencyStats is returned.
+func (lr *LatencyRecorder) Stats(cmd string) LatencyStats {
+	lr.l.RLock()
+	h := lr.hists[cmd]
+	lr.l.RUnlock()
+	if h == nil {
+		return LatencyStats{}
+	}
+	return h.stats()
+}
+
+// AllStats returns the LatencyStats of every command which latencies have been
+// recorded for.
+func (lr *LatencyRecorder) AllStats() map[string]LatencyStats {
+	m := map[string]LatencyStats{}
+	for _, cmd := range lr.Commands() {
+		m[cmd] = lr.Stats(cmd)
+	}
+	return m
+}
+
+// Reset discards all recorded latencies.
+func (lr *LatencyRecorder) Reset() {
+	lr.l.Lock()
+	defer lr.l.Unlock()
+	lr.hists = map[string]*latencyHistogram{}
+}
+
+type latencyRecordingClient struct {
+	Client
+	lr *LatencyRecorder
+}
+
+// Wrap returns a ContextClient which performs Actions using the given Client,
+// recording the latency of each one. Actions are recorded under the name of the
+// command they perform (see ActionCmdNames), Pipelines under "PIPELINE", and
+// other Actions, e.g. WithConn, under "unknown".
+func (lr *LatencyRecorder) Wrap(c Client) ContextClient {
+	return &latencyRecordingClient{Client: c, lr: lr}
+}
+
+func (c *latencyRecordingClient) Do(a Action) error {
+	return c.DoContext(context.Background(), a)
+}
+
+func (c *latencyRecordingClient) DoContext(ctx context.Context, a Action) error {
+	start := time.Now()
+	err := DoContext(ctx, c.Client, a)
+	elapsed := time.Since(start)
+
+	cmd := "unknown"
+	if cmds := ActionCmdNames(a); len(cmds) == 1 {
+		cmd = cmds[0]
+	} else if len(cmds) > 1 {
+		cmd = "PIPELINE"
+	}
+	c.lr.Record(cmd, elapsed)
+	return err
+}
@@ -0,0 +1,81 @@
+package radix
+
+import (
+	"math/rand"
+	. "testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHistBuckets(t *T) {
+	prevIdx := -1
+	for _, v := range []uint64{0, 1, 63, 64, 65, 127, 128, 1000, 1 << 20, 1<<20 + 12345, 1<<63 - 1} {
+		idx := histBucketIdx(v)
+		require.True(t, idx >= prevIdx, "v:%d idx:%d prevIdx:%d", v, idx, prevIdx)
+		require.True(t, idx < histBuckets, "v:%d idx:%d", v, idx)
+		prevIdx = idx
+
+		lo, hi := histBucketRange(idx)
+		assert.True(t, lo <= v && v <= hi, "v:%d lo:%d hi:%d", v, lo, hi)
+		assert.True(t, hi-lo <= lo/histSubBuckets, "v:%d lo:%d hi:%d", v, lo, hi)
+		assert.Equal(t, idx, histBucketIdx(lo))
+		assert.Equal(t, idx, histBucketIdx(hi))
+	}
+}
+
+func TestLatencyRecorder(t *T) {
+	lr := NewLatencyRecorder()
+	assert.Equal(t, LatencyStats{}, lr.Stats("GET"))
+
+	// record 1ms to 1000ms, in a random order
+	for _, i := range rand.Perm(1000) {
+		lr.Record("GET", time.Duration(i+1)*time.Millisecond)
+	}
+
+	s := lr.Stats("GET")
+	assert.Equal(t, int64(1000), s.Count)
+	assert.Equal(t, time.Millisecond, s.Min)
+	assert.Equal(t, 1000*time.Millisecond, s.Max)
+	assert.Equal(t, 500500*time.Microsecond, s.Mean)
+	assertNear := func(exp, got time.Duration) {
+		assert.InEpsilon(t, float64(exp), float64(got), 0.03, "exp:%v got:%v", exp, got)
+	}
+	assertNear(500*time.Millisecond, s.P50)
+	assertNear(900*time.Millisecond, s.P90)
+	assertNear(990*time.Millisecond, s.P99)
+	assertNear(999*time.Millisecond, s.P999)
+
+	lr.Reset()
+	assert.Empty(t, lr.Commands())
+}
+
+func TestLatencyHistogramPartialRecord(t *T) {
+	// stats may be taken while the first record is only partially done, i.e.
+	// it's been counted in its bucket but nothing else has been updated
+	h := newLatencyHistogram()
+	h.counts[histBucketIdx(uint64(time.Millisecond))]++
+	s := h.stats()
+	assert.Equal(t, int64(1), s.Count)
+	assert.Equal(t, time.Duration(0), s.Mean)
+}
+
+func TestLatencyRecorderWrap(t *T) {
+	lr := NewLatencyRecorder()
+	c := lr.Wrap(Stub("tcp", "127.0.0.1:6379", func(args []string) interface{} {
+		return args[len(args)-1]
+	}))
+
+	for i := 0; i < 3; i++ {
+		require.NoError(t, c.Do(Cmd(nil, "GET", "foo")))
+	}
+	require.NoError(t, c.Do(Pipeline(Cmd(nil, "SET", "foo", "1"), Cmd(nil, "GET", "foo"))))
+	require.NoError(t, c.Do(WithConn("", func(Conn) error { return nil })))
+
+	assert.Equal(t, []string{"GET", "PIPELINE", "unknown"}, lr.Commands())
+	all := lr.AllStats()
+	assert.Equal(t, int64(3), all["GET"].Count)
+	assert.Equal(t, int64(1), all["PIPELINE"].Count)
+	assert.Equal(t, int64(1), all["unknown"].Count)
+}
@@ -0,0 +1,65 @@
+package radix
+
+import (
+	. "testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLatencyCmds(t *T) {
+	var gotArgs [][]string
+	conn := Stub("tcp", "127.0.0.1:6379", func(args []string) interface{} {
+		gotArgs = append(gotArgs, args)
+		switch args[1] {
+		case "LATEST":
+			return []interface{}{
+				[]interface{}{"command", int64(1405067976), int64(251), int64(1001)},
+			}
+		case "HISTORY":
+			return []interface{}{
+				[]interface{}{int64(1405067822), int64(251)},
+				[]interface{}{int64(1405067941), int64(1001)},
+			}
+		case "RESET":
+			return int64(1)
+		default:
+			return "Dave, no latency spike was observed"
+		}
+	})
+
+	var events []LatencyEvent
+	require.Nil(t, conn.Do(LatencyLatestCmd(&events)))
+	assert.Equal(t, []LatencyEvent{{
+		Name:      "command",
+		Timestamp: time.Unix(1405067976, 0),
+		Latest:    251 * time.Millisecond,
+		Max:       1001 * time.Millisecond,
+	}}, events)
+
+	var samples []LatencySample
+	require.Nil(t, conn.Do(LatencyHistoryCmd(&samples, "command")))
+	assert.Equal(t, []LatencySample{
+		{Timestamp: time.Unix(1405067822, 0), Latency: 251 * time.Millisecond},
+		{Timestamp: time.Unix(1405067941, 0), Latency: 1001 * time.Millisecond},
+	}, samples)
+
+	var n int
+	require.Nil(t, conn.Do(LatencyResetCmd(&n, "command")))
+	assert.Equal(t, 1, n)
+	require.Nil(t, conn.Do(LatencyResetCmd(nil)))
+
+	var report string
+	require.Nil(t, conn.Do(LatencyDoctorCmd(&report)))
+	assert.NotEmpty(t, report)
+
+	assert.Equal(t, [][]string{
+		{"LATENCY", "LATEST"},
+		{"LATENCY", "HISTORY", "command"},
+		{"LATENCY", "RESET", "command"},
+		{"LATENCY", "RESET"},
+		{"LATENCY", "DOCTOR"},
+	}, gotArgs)
+	assert.Nil(t, LatencyLatestCmd(&events).Keys())
+}
@@ -0,0 +1,350 @@
+package radix
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	mrand "math/rand"
+	"strconv"
+	"sync"
+	"time"
+
+	errors "golang.org/x/xerrors"
+)
+
+// ErrLockNotAcquired is returned from Lock.Acquire when the lock could not be
+// acquired within the allowed number of attempts, because it is held by
+// someone else.
+var ErrLockNotAcquired = errors.New("lock not acquired")
+
+// ErrLockNotHeld is returned from Lock.Refresh and Lock.Release when the lock
+// is not held, either because it was never acquired or because it expired and
+// may have been acquired by someone else.
+var ErrLockNotHeld = errors.New("lock not held")
+
+var (
+	// lockRefreshScript sets the expiry of the lock's key, but only if it still
+	// holds the given token.
+	lockRefreshScript = NewEvalScript(1, `
+		if redis.call("GET", KEYS[1]) == ARGV[1] then
+			return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+		end
+		return 0
+	`)
+
+	// lockReleaseScript deletes the lock's key, but only if it still holds the
+	// given token.
+	lockReleaseScript = NewEvalScript(1, `
+		if redis.call("GET", KEYS[1]) == ARGV[1] then
+			return redis.call("DEL", KEYS[1])
+		end
+		return 0
+	`)
+)
+
+type lockOpts struct {
+	ttl         time.Duration
+	maxAttempts int
+	retryDelay  time.Duration
+	driftFactor float64
+}
+
+// LockOpt is an optional behavior which can be applied to the NewLock and
+// NewRedlock functions to effect their behavior.
+type LockOpt func(*lockOpts)
+
+// LockTTL sets the amount of time the lock is held for once acquired or
+// refreshed. If the holder of the lock does not call Refresh or Release
+// before the TTL has passed the lock expires and may be acquired by someone
+// else.
+func LockTTL(d time.Duration) LockOpt {
+	return func(lo *lockOpts) {
+		lo.ttl = d
+	}
+}
+
+// LockMaxAttempts sets the maximum number of times Acquire will attempt to
+// acquire the lock, including the first attempt, before returning
+// ErrLockNotAcquired. If n is 0 or less then Acquire will keep attempting
+// until the lock is acquired or the Context is canceled.
+func LockMaxAttempts(n int) LockOpt {
+	return func(lo *lockOpts) {
+		lo.maxAttempts = n
+	}
+}
+
+// LockRetryDelay sets the upper bound on how long Acquire waits between
+// attempts. The actual wait is chosen randomly between half of d and d, so
+// that competing clients don't retry in lockstep.
+func LockRetryDelay(d time.Duration) LockOpt {
+	return func(lo *lockOpts) {
+		lo.retryDelay = d
+	}
+}
+
+// LockDriftFactor sets the fraction of the TTL which is assumed to be lost to
+// clock drift between the client and the redis instances. The lock is
+// considered to have expired once the TTL, minus the drift, has passed since
+// the lock was last acquired or refreshed.
+func LockDriftFactor(f float64) LockOpt {
+	return func(lo *lockOpts) {
+		lo.driftFactor = f
+	}
+}
+
+// Lock is a distributed mutual exclusion lock backed by one or more
+// independent redis instances. See NewLock and NewRedlock.
+//
+// A Lock may be acquired, refreshed, and released many times, but should not
+// be shared between goroutines which each intend to hold it; each holder should
+// create its own Lock for the same key.
+type Lock struct {
+	clients []Client
+	key     string
+	opts    lockOpts
+
+	l     sync.Mutex
+	token string
+	until time.Time
+}
+
+// NewLock returns a Lock which uses the given key on the given Client. The lock
+// is acquired by setting the key to a random token, if it isn't already set,
+// and is only refreshed or released if the key still holds that token.
+//
+// When using a Cluster the key determines which instance the lock lives on. If
+// that instance fails over to a secondary before the key has been replicated to
+// it the lock may be lost. NewRedlock can be used when this is a concern.
+//
+// The default options NewLock uses are:
+//
+//	LockTTL(10 * time.Second)
+//	LockMaxAttempts(0)
+//	LockRetryDelay(100 * time.Millisecond)
+//	LockDriftFactor(0.01)
+//
+func NewLock(c Client, key string, opts ...LockOpt) *Lock {
+	return NewRedlock([]Client{c}, key, opts...)
+}
+
+// NewRedlock returns a Lock which implements the Redlock algorithm across the
+// given Clients, as described by:
+//	https://redis.io/topics/distlock
+//
+// Each Client should be connected to an independent redis instance (i.e. not
+// replicas of one another). The lock is only considered acquired if it was set
+// on a majority of the instances, within a time which leaves some of the TTL
+// remaining. Refresh likewise requires a majority of the instances to succeed.
+//
+// See NewLock for the default options.
+func NewRedlock(clients []Client, key string, opts ...LockOpt) *Lock {
+	lk := &Lock{clients: clients, key: key}
+
+	defaultLockOpts := []LockOpt{
+		LockTTL(10 * time.Second),
+		LockMaxAttempts(0),
+		LockRetryDelay(100 * time.Millisecond),
+		LockDriftFactor(0.01),
+	}
+
+	for _, opt := range append(defaultLockOpts, opts...) {
+		opt(&(lk.opts))
+	}
+	return lk
+}
+
+func newLockToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", errors.Errorf("generating lock token: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func (lk *Lock) quorum() int {
+	return len(lk.clients)/2 + 1
+}
+
+// validity returns the time until which a lock which was set starting at the
+// given time can be considered held, accounting for clock drift.
+func (lk *Lock) validity(start time.Time) time.Time {
+	drift := time.Duration(float64(lk.opts.ttl)*lk.opts.driftFactor) + 2*time.Millisecond
+	return start.Add(lk.opts.ttl - drift)
+}
+
+// forEach calls fn for each of the Lock's Clients concurrently, and returns the
+// number of calls which returned true along with the first error encountered.
+func (lk *Lock) forEach(fn func(Client) (bool, error)) (int, error) {
+	if len(lk.clients) == 1 {
+		ok, err := fn(lk.clients[0])
+		if ok {
+			return 1, err
+		}
+		return 0, err
+	}
+
+	var (
+		wg       sync.WaitGroup
+		l        sync.Mutex
+		n        int
+		firstErr error
+	)
+	for _, c := range lk.clients {
+		wg.Add(1)
+		go func(c Client) {
+			defer wg.Done()
+			ok, err := fn(c)
+			l.Lock()
+			defer l.Unlock()
+			if ok {
+				n++
+			}
+			if err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}(c)
+	}
+	wg.Wait()
+	return n, firstErr
+}
+
+func (lk *Lock) ttlArg() string {
+	return strconv.FormatInt(int64(lk.opts.ttl/time.Millisecond), 10)
+}
+
+// releaseToken releases the lock on every instance, if it still holds the given
+// token, returning the number of instances it was released on.
+func (lk *Lock) releaseToken(ctx context.Context, token string) (int, error) {
+	return lk.forEach(func(c Client) (bool, error) {
+		var n int
+		err := DoContext(ctx, c, lockReleaseScript.Cmd(&n, lk.key, token))
+		return n > 0, err
+	})
+}
+
+// tryAcquire makes a single attempt at acquiring the lock using the given
+// token, returning whether it was acquired and until when it is valid.
+func (lk *Lock) tryAcquire(ctx context.Context, token string) (bool, time.Time, error) {
+	start := time.Now()
+	n, err := lk.forEach(func(c Client) (bool, error) {
+		var mn MaybeNil
+		err := DoContext(ctx, c, Cmd(&mn, "SET", lk.key, token, "NX", "PX", lk.ttlArg()))
+		return err == nil && !mn.Nil, err
+	})
+
+	until := lk.validity(start)
+	if n >= lk.quorum() && time.Now().Before(until) {
+		return true, until, nil
+	}
+
+	// the lock may have been set on some of the instances, which must be
+	// undone so that others can acquire it. The given Context may have been
+	// canceled, so a fresh one is used, which is bounded by the TTL since the
+	// lock will have expired by then anyway.
+	releaseCtx, cancel := context.WithTimeout(context.Background(), lk.opts.ttl)
+	defer cancel()
+	lk.releaseToken(releaseCtx, token)
+
+	// with multiple instances some of them failing is expected, and is
+	// treated the same as the lock being held by someone else
+	if len(lk.clients) > 1 {
+		err = nil
+	}
+	return false, time.Time{}, err
+}
+
+func (lk *Lock) retryDelay() time.Duration {
+	if lk.opts.retryDelay <= 0 {
+		return 0
+	}
+	half := int64(lk.opts.retryDelay / 2)
+	return time.Duration(half + mrand.Int63n(half+1))
+}
+
+// Acquire acquires the lock, waiting for it to be released by its current
+// holder if necessary. Acquire returns ErrLockNotAcquired if the lock could
+// not be acquired within the number of attempts given by LockMaxAttempts, or
+// the Context's error if it is canceled first.
+func (lk *Lock) Acquire(ctx context.Context) error {
+	for attempt := 1; ; attempt++ {
+		token, err := newLockToken()
+		if err != nil {
+			return err
+		}
+
+		ok, until, err := lk.tryAcquire(ctx, token)
+		if err != nil {
+			return err
+		} else if ok {
+			lk.l.Lock()
+			lk.token, lk.until = token, until
+			lk.l.Unlock()
+			return nil
+		} else if lk.opts.maxAttempts > 0 && attempt >= lk.opts.maxAttempts {
+			return ErrLockNotAcquired
+		}
+
+		t := getTimer(lk.retryDelay())
+		select {
+		case <-t.C:
+			putTimer(t)
+		case <-ctx.Done():
+			putTimer(t)
+			return ctx.Err()
+		}
+	}
+}
+
+// Refresh resets the TTL of the lock, so that it is held for longer. If the
+// lock is not held, or has expired, then ErrLockNotHeld is returned.
+func (lk *Lock) Refresh(ctx context.Context) error {
+	lk.l.Lock()
+	defer lk.l.Unlock()
+	if lk.token == "" || !time.Now().Before(lk.until) {
+		return ErrLockNotHeld
+	}
+
+	start := time.Now()
+	n, err := lk.forEach(func(c Client) (bool, error) {
+		var n int
+		err := DoContext(ctx, c, lockRefreshScript.Cmd(&n, lk.key, lk.token, lk.ttlArg()))
+		return n > 0, err
+	})
+
+	if until := lk.validity(start); n >= lk.quorum() && time.Now().Before(until) {
+		lk.until = until
+		return nil
+	} else if err != nil && len(lk.clients) == 1 {
+		return err
+	}
+	return ErrLockNotHeld
+}
+
+// Release releases the lock so that it may be acquired by someone else. If the
+// lock is not held, or has expired, then ErrLockNotHeld is returned.
+func (lk *Lock) Release(ctx context.Context) error {
+	lk.l.Lock()
+	defer lk.l.Unlock()
+	if lk.token == "" {
+		return ErrLockNotHeld
+	}
+
+	token := lk.token
+	lk.token, lk.until = "", time.Time{}
+
+	n, err := lk.releaseToken(ctx, token)
+	if err != nil && len(lk.clients) == 1 {
+		return err
+	} else if n < lk.quorum() {
+		return ErrLockNotHeld
+	}
+	return nil
+}
+
+// Token returns the random token which the lock's key is set to while the lock
+// is held, or an empty string if the lock has not been acquired.
+func (lk *Lock) Token() string {
+	lk.l.Lock()
+	defer lk.l.Unlock()
+	return lk.token
+}
@@ -0,0 +1,154 @@
+package radix
+
+import (
+	"context"
+	. "testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLock(t *T) {
+	ctx := context.Background()
+	c := dial()
+	defer c.Close()
+
+	key := randStr()
+	lockA := NewLock(c, key, LockMaxAttempts(1))
+	lockB := NewLock(c, key, LockMaxAttempts(1))
+
+	// releasing or refreshing a lock which was never acquired fails
+	assert.Equal(t, ErrLockNotHeld, lockA.Release(ctx))
+	assert.Equal(t, ErrLockNotHeld, lockA.Refresh(ctx))
+
+	require.Nil(t, lockA.Acquire(ctx))
+	assert.NotEmpty(t, lockA.Token())
+	var token string
+	require.Nil(t, c.Do(Cmd(&token, "GET", key)))
+	assert.Equal(t, lockA.Token(), token)
+
+	assert.Equal(t, ErrLockNotAcquired, lockB.Acquire(ctx))
+	assert.Empty(t, lockB.Token())
+
+	// refreshing resets the TTL
+	require.Nil(t, c.Do(Cmd(nil, "PEXPIRE", key, "1000")))
+	require.Nil(t, lockA.Refresh(ctx))
+	var pttl int
+	require.Nil(t, c.Do(Cmd(&pttl, "PTTL", key)))
+	assert.True(t, pttl > 1000, "pttl:%d", pttl)
+
+	require.Nil(t, lockA.Release(ctx))
+	assert.Empty(t, lockA.Token())
+	assert.Equal(t, ErrLockNotHeld, lockA.Release(ctx))
+
+	var exists int
+	require.Nil(t, c.Do(Cmd(&exists, "EXISTS", key)))
+	assert.Equal(t, 0, exists)
+
+	// once released the other lock can acquire it
+	require.Nil(t, lockB.Acquire(ctx))
+
+	// a lock whose key was taken over can't be refreshed or released, and the
+	// key is left alone
+	require.Nil(t, c.Do(Cmd(nil, "SET", key, "foo")))
+	assert.Equal(t, ErrLockNotHeld, lockB.Refresh(ctx))
+	assert.Equal(t, ErrLockNotHeld, lockB.Release(ctx))
+	require.Nil(t, c.Do(Cmd(&token, "GET", key)))
+	assert.Equal(t, "foo", token)
+}
+
+func TestLockAcquireWait(t *T) {
+	ctx := context.Background()
+	cA, cB := dial(), dial()
+	defer cA.Close()
+	defer cB.Close()
+
+	key := randStr()
+	lockA := NewLock(cA, key)
+	lockB := NewLock(cB, key, LockRetryDelay(20*time.Millisecond))
+	require.Nil(t, lockA.Acquire(ctx))
+
+	// lockB should get the lock once lockA releases it
+	start := time.Now()
+	time.AfterFunc(100*time.Millisecond, func() {
+		assert.Nil(t, lockA.Release(ctx))
+	})
+	require.Nil(t, lockB.Acquire(ctx))
+	assert.True(t, time.Since(start) >= 100*time.Millisecond)
+
+	// with a Context which is canceled Acquire gives up
+	ctx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, lockA.Acquire(ctx))
+	assert.Empty(t, lockA.Token())
+}
+
+// hangingClient is a Client whose Actions never complete, unless they're given
+// a Context which is canceled.
+type hangingClient struct{}
+
+func (hangingClient) Do(Action) error { select {} }
+func (hangingClient) Close() error    { return nil }
+
+func (hangingClient) DoContext(ctx context.Context, _ Action) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestLockAcquireHanging(t *T) {
+	c := dial()
+	defer c.Close()
+
+	// releasing the lock after failing to acquire it doesn't hang along with
+	// the instances which failed
+	lk := NewRedlock([]Client{c, hangingClient{}, hangingClient{}}, randStr(),
+		LockMaxAttempts(1), LockTTL(100*time.Millisecond))
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	assert.Equal(t, ErrLockNotAcquired, lk.Acquire(ctx))
+	assert.True(t, time.Since(start) < time.Second)
+}
+
+func TestRedlock(t *T) {
+	ctx := context.Background()
+
+	// each Conn uses a different database to simulate independent instances
+	var clients []Client
+	for db := 1; db <= 3; db++ {
+		c := dial(DialSelectDB(db))
+		defer c.Close()
+		clients = append(clients, c)
+	}
+
+	key := randStr()
+	lockA := NewRedlock(clients, key, LockMaxAttempts(1))
+	lockB := NewRedlock(clients, key, LockMaxAttempts(1))
+
+	// with the key already held on two of the three instances the lock can't
+	// be acquired, and it shouldn't be left set on the third
+	for _, c := range clients[:2] {
+		require.Nil(t, c.Do(Cmd(nil, "SET", key, "foo")))
+	}
+	assert.Equal(t, ErrLockNotAcquired, lockA.Acquire(ctx))
+	var exists int
+	require.Nil(t, clients[2].Do(Cmd(&exists, "EXISTS", key)))
+	assert.Equal(t, 0, exists)
+
+	// with only one instance held the lock can be acquired
+	require.Nil(t, clients[1].Do(Cmd(nil, "DEL", key)))
+	require.Nil(t, lockA.Acquire(ctx))
+	assert.Equal(t, ErrLockNotAcquired, lockB.Acquire(ctx))
+	require.Nil(t, lockA.Refresh(ctx))
+	require.Nil(t, lockA.Release(ctx))
+
+	var token string
+	require.Nil(t, clients[0].Do(Cmd(&token, "GET", key)))
+	assert.Equal(t, "foo", token)
+	for _, c := range clients[1:] {
+		require.Nil(t, c.Do(Cmd(&exists, "EXISTS", key)))
+		assert.Equal(t, 0, exists)
+	}
+}
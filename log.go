@@ -0,0 +1,101 @@
+package radix
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+// LogLevel describes the severity of an event passed into a Logger.
+type LogLevel int
+
+// All possible LogLevel values, in order of increasing severity.
+const (
+	// LogLevelDebug is used for routine events which are only of interest
+	// when debugging, e.g. a Cluster following a MOVED redirect.
+	LogLevelDebug LogLevel = iota
+
+	// LogLevelInfo is used for notable but expected events, e.g. a Cluster's
+	// topology changing.
+	LogLevelInfo
+
+	// LogLevelWarn is used for failures which radix will attempt to recover
+	// from on its own, e.g. a Pool failing to create a connection.
+	LogLevelWarn
+
+	// LogLevelError is used for failures which radix can't recover from on its
+	// own, e.g. a PersistentPubSub giving up on reconnecting.
+	LogLevelError
+)
+
+func (l LogLevel) String() string {
+	switch l {
+	case LogLevelDebug:
+		return "DEBUG"
+	case LogLevelInfo:
+		return "INFO"
+	case LogLevelWarn:
+		return "WARN"
+	case LogLevelError:
+		return "ERROR"
+	default:
+		return fmt.Sprintf("LogLevel(%d)", int(l))
+	}
+}
+
+// Logger is used by Dial, Pool, Cluster, Sentinel, and PersistentPubSub to
+// report events which happen internally, e.g. connections being re-created or
+// topology changes, which would otherwise go unnoticed. See the DialLogger,
+// PoolLogger, ClusterLogger, SentinelLogger, and PersistentPubSubLogger
+// options.
+//
+// Log may be called from many go-routines at once, and should not block.
+type Logger interface {
+	// Log is called with a short, constant, message describing the event, and
+	// an even number of key/value pairs describing its details. Keys are
+	// always strings.
+	Log(level LogLevel, msg string, keyvals ...interface{})
+}
+
+// LoggerFunc is a function which implements the Logger interface.
+type LoggerFunc func(level LogLevel, msg string, keyvals ...interface{})
+
+// Log implements the method for the Logger interface.
+func (f LoggerFunc) Log(level LogLevel, msg string, keyvals ...interface{}) {
+	f(level, msg, keyvals...)
+}
+
+// NewStdLogger returns a Logger which writes events of at least the given
+// level to the given *log.Logger, in the form:
+//
+//	[WARN] failed to create connection addr=127.0.0.1:6379 err="dial tcp: connection refused"
+//
+func NewStdLogger(l *log.Logger, minLevel LogLevel) Logger {
+	return LoggerFunc(func(level LogLevel, msg string, keyvals ...interface{}) {
+		if level < minLevel {
+			return
+		}
+
+		var b strings.Builder
+		fmt.Fprintf(&b, "[%s] %s", level, msg)
+		for i := 0; i < len(keyvals); i += 2 {
+			var v interface{} = "MISSING"
+			if i+1 < len(keyvals) {
+				v = keyvals[i+1]
+			}
+			vStr := fmt.Sprint(v)
+			if strings.ContainsAny(vStr, " \"=") {
+				vStr = fmt.Sprintf("%q", vStr)
+			}
+			fmt.Fprintf(&b, " %v=%s", keyvals[i], vStr)
+		}
+		l.Print(b.String())
+	})
+}
+
+// logEvent calls Log on the given Logger, if it's not nil.
+func logEvent(l Logger, level LogLevel, msg string, keyvals ...interface{}) {
+	if l != nil {
+		l.Log(level, msg, keyvals...)
+	}
+}
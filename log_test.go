@@ -0,0 +1,56 @@
+package radix
+
+import (
+	"bytes"
+	"log"
+	"sync"
+	. "testing"
+
+	"github.com/stretchr/testify/assert"
+	errors "golang.org/x/xerrors"
+)
+
+type logEntry struct {
+	level   LogLevel
+	msg     string
+	keyvals []interface{}
+}
+
+// testLogger is a Logger which records all events logged to it.
+type testLogger struct {
+	l       sync.Mutex
+	entries []logEntry
+}
+
+func (tl *testLogger) Log(level LogLevel, msg string, keyvals ...interface{}) {
+	tl.l.Lock()
+	defer tl.l.Unlock()
+	tl.entries = append(tl.entries, logEntry{level: level, msg: msg, keyvals: keyvals})
+}
+
+// withMsg returns all entries which were logged with the given message.
+func (tl *testLogger) withMsg(msg string) []logEntry {
+	tl.l.Lock()
+	defer tl.l.Unlock()
+	var entries []logEntry
+	for _, e := range tl.entries {
+		if e.msg == msg {
+			entries = append(entries, e)
+		}
+	}
+	return entries
+}
+
+func TestNewStdLogger(t *T) {
+	buf := new(bytes.Buffer)
+	l := NewStdLogger(log.New(buf, "", 0), LogLevelInfo)
+
+	l.Log(LogLevelDebug, "not logged")
+	l.Log(LogLevelInfo, "something happened", "addr", "127.0.0.1:6379", "n", 2)
+	l.Log(LogLevelWarn, "something failed", "err", errors.New("it broke"), "odd")
+	l.Log(LogLevel(10), "unknown level")
+	assert.Equal(t, `[INFO] something happened addr=127.0.0.1:6379 n=2
+[WARN] something failed err="it broke" odd=MISSING
+[LogLevel(10)] unknown level
+`, buf.String())
+}
@@ -0,0 +1,122 @@
+package radix
+
+import (
+	"bufio"
+	"strconv"
+	"strings"
+
+	errors "golang.org/x/xerrors"
+
+	"github.com/mediocregopher/radix/v3/resp/resp2"
+)
+
+// MemoryStatsDB describes the memory overhead of a single database, as listed
+// in the reply to MEMORY STATS. Amounts are given in bytes.
+type MemoryStatsDB struct {
+	OverheadHashtableMain    int64 `info:"overhead.hashtable.main"`
+	OverheadHashtableExpires int64 `info:"overhead.hashtable.expires"`
+}
+
+// MemoryStats describes the reply to MEMORY STATS, as described by:
+//
+//	https://redis.io/commands/memory-stats
+//
+// Amounts are given in bytes. Fields which weren't present in the reply are
+// left as their zero value. All fields, including ones which aren't parsed,
+// are available in Fields, except for the per-database ones which are parsed
+// into DBs.
+type MemoryStats struct {
+	PeakAllocated      int64   `info:"peak.allocated"`
+	TotalAllocated     int64   `info:"total.allocated"`
+	StartupAllocated   int64   `info:"startup.allocated"`
+	ReplicationBacklog int64   `info:"replication.backlog"`
+	ClientsSlaves      int64   `info:"clients.slaves"`
+	ClientsNormal      int64   `info:"clients.normal"`
+	AOFBuffer          int64   `info:"aof.buffer"`
+	LuaCaches          int64   `info:"lua.caches"`
+	OverheadTotal      int64   `info:"overhead.total"`
+	KeysCount          int64   `info:"keys.count"`
+	KeysBytesPerKey    int64   `info:"keys.bytes-per-key"`
+	DatasetBytes       int64   `info:"dataset.bytes"`
+	DatasetPercentage  float64 `info:"dataset.percentage"`
+	PeakPercentage     float64 `info:"peak.percentage"`
+	Fragmentation      float64 `info:"fragmentation"`
+
+	// DBs maps the number of each database listed in the reply to its
+	// description. Databases without keys aren't listed.
+	DBs map[int]MemoryStatsDB
+
+	Fields map[string]string
+}
+
+// UnmarshalRESP implements the resp.Unmarshaler interface.
+func (ms *MemoryStats) UnmarshalRESP(br *bufio.Reader) error {
+	var arrHead resp2.ArrayHeader
+	if err := arrHead.UnmarshalRESP(br); err != nil {
+		return err
+	}
+
+	stats := MemoryStats{
+		DBs:    map[int]MemoryStatsDB{},
+		Fields: map[string]string{},
+	}
+	for i := 0; i < arrHead.N/2; i++ {
+		var name string
+		if err := (resp2.Any{I: &name}).UnmarshalRESP(br); err != nil {
+			return err
+		}
+
+		if !strings.HasPrefix(name, "db.") {
+			var val string
+			if err := (resp2.Any{I: &val}).UnmarshalRESP(br); err != nil {
+				return err
+			}
+			stats.Fields[name] = val
+			continue
+		}
+
+		var raw map[string]string
+		if err := (resp2.Any{I: &raw}).UnmarshalRESP(br); err != nil {
+			return err
+		}
+		db, err := strconv.Atoi(name[3:])
+		if err != nil {
+			return errors.Errorf("malformed MEMORY STATS field %q", name)
+		}
+		var dbStats MemoryStatsDB
+		if err := setInfoFields(&dbStats, raw); err != nil {
+			return err
+		}
+		stats.DBs[db] = dbStats
+	}
+
+	if err := setInfoFields(&stats, stats.Fields); err != nil {
+		return err
+	}
+	*ms = stats
+	return nil
+}
+
+// MemoryStatsCmd returns a CmdAction which performs MEMORY STATS and parses the
+// reply into rcv.
+func MemoryStatsCmd(rcv *MemoryStats) CmdAction {
+	return Cmd(rcv, "MEMORY", "STATS")
+}
+
+// MemoryUsageCmd returns a CmdAction which performs MEMORY USAGE on the given
+// key, writing the number of bytes it and its value use into rcv, or 0 if the
+// key doesn't exist. If samples is 0 or more then it's passed to redis as the
+// number of elements of nested values to sample, with 0 sampling all of them.
+func MemoryUsageCmd(rcv *int64, key string, samples int) CmdAction {
+	args := []string{"USAGE", key}
+	if samples >= 0 {
+		args = append(args, "SAMPLES", strconv.Itoa(samples))
+	}
+	return Cmd(rcv, "MEMORY", args...)
+}
+
+// MemoryDoctorCmd returns a CmdAction which performs MEMORY DOCTOR, writing the
+// human readable report into rcv.
+func MemoryDoctorCmd(rcv *string) CmdAction {
+	return Cmd(rcv, "MEMORY", "DOCTOR")
+}
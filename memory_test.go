@@ -0,0 +1,63 @@
+package radix
+
+import (
+	. "testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryCmds(t *T) {
+	var gotArgs [][]string
+	conn := Stub("tcp", "127.0.0.1:6379", func(args []string) interface{} {
+		gotArgs = append(gotArgs, args)
+		switch args[1] {
+		case "STATS":
+			return []interface{}{
+				"peak.allocated", int64(1048576),
+				"total.allocated", int64(917504),
+				"db.0", []interface{}{
+					"overhead.hashtable.main", int64(72),
+					"overhead.hashtable.expires", int64(32),
+				},
+				"keys.count", int64(3),
+				"dataset.percentage", "12.5",
+				"allocator.frag.ratio", "1.03",
+			}
+		case "USAGE":
+			return int64(56)
+		default:
+			return "Hi Sam, I can't find any memory issue in your instance."
+		}
+	})
+
+	var stats MemoryStats
+	require.Nil(t, conn.Do(MemoryStatsCmd(&stats)))
+	assert.Equal(t, int64(1048576), stats.PeakAllocated)
+	assert.Equal(t, int64(917504), stats.TotalAllocated)
+	assert.Equal(t, int64(3), stats.KeysCount)
+	assert.Equal(t, 12.5, stats.DatasetPercentage)
+	assert.Equal(t, map[int]MemoryStatsDB{
+		0: {OverheadHashtableMain: 72, OverheadHashtableExpires: 32},
+	}, stats.DBs)
+	assert.Equal(t, "1.03", stats.Fields["allocator.frag.ratio"])
+
+	var usage int64
+	usageCmd := MemoryUsageCmd(&usage, "foo", -1)
+	assert.Equal(t, []string{"foo"}, usageCmd.Keys())
+	require.Nil(t, conn.Do(usageCmd))
+	assert.Equal(t, int64(56), usage)
+	require.Nil(t, conn.Do(MemoryUsageCmd(&usage, "foo", 0)))
+
+	var report string
+	require.Nil(t, conn.Do(MemoryDoctorCmd(&report)))
+	assert.NotEmpty(t, report)
+	assert.Nil(t, MemoryStatsCmd(&stats).Keys())
+
+	assert.Equal(t, [][]string{
+		{"MEMORY", "STATS"},
+		{"MEMORY", "USAGE", "foo"},
+		{"MEMORY", "USAGE", "foo", "SAMPLES", "0"},
+		{"MEMORY", "DOCTOR"},
+	}, gotArgs)
+}
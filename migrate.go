@@ -0,0 +1,257 @@
+package radix
+
+import (
+	"strconv"
+	"time"
+)
+
+// DumpedKey describes a key which was serialized using DUMP, see DumpKeys.
+type DumpedKey struct {
+	Key string
+
+	// Value is the serialized value of the key, as returned by DUMP, or nil if
+	// the key didn't exist.
+	Value []byte
+
+	// ExpireAt is the time at which the key expires, or the zero time.Time if
+	// it has no expiry.
+	ExpireAt time.Time
+}
+
+// DumpKeys returns an Action which serializes the values of the given keys
+// using DUMP, and retrieves their expiries using PTTL. The results are written
+// to rcv in the same order as the keys, and can be passed into RestoreKeys.
+//
+// Like ClusterMGet, the keys may belong to any number of slots, and when
+// performed on a Cluster the commands for each node are performed concurrently.
+func DumpKeys(rcv *[]DumpedKey, keys ...string) Action {
+	vals := make([][]byte, len(keys))
+	pttls := make([]int64, len(keys))
+	cmds := make([]CmdAction, 0, len(keys)*2)
+	for i, key := range keys {
+		cmds = append(cmds,
+			Cmd(&vals[i], "DUMP", key),
+			Cmd(&pttls[i], "PTTL", key),
+		)
+	}
+
+	return multiKeyAction{pipeline: cmds, merge: func() error {
+		now := time.Now()
+		*rcv = make([]DumpedKey, len(keys))
+		for i, key := range keys {
+			dk := DumpedKey{Key: key}
+			if pttls[i] != -2 && len(vals[i]) > 0 {
+				dk.Value = vals[i]
+			}
+			if dk.Value != nil && pttls[i] >= 0 {
+				dk.ExpireAt = now.Add(time.Duration(pttls[i]) * time.Millisecond)
+			}
+			(*rcv)[i] = dk
+		}
+		return nil
+	}}
+}
+
+// RestoreOpts are options which can be passed into RestoreKeys.
+type RestoreOpts struct {
+	// Replace causes keys which already exist to be overwritten. Otherwise
+	// RESTORE fails with a BUSYKEY error for them.
+	Replace bool
+}
+
+// RestoreKeys returns an Action which creates the given keys from their
+// serialized values using RESTORE. Keys whose Value is nil, i.e. which didn't
+// exist when they were dumped, are skipped.
+//
+// Expiries are given to RESTORE as absolute timestamps (using ABSTTL), so that
+// the time which passes between the keys being dumped and restored isn't added
+// to them. Keys which expired in the meantime are not created. This requires
+// the clocks of the source and destination instances to be reasonably in sync.
+//
+// Like ClusterMSet, the keys may belong to any number of slots, and when
+// performed on a Cluster the commands for each node are performed concurrently.
+// If an error is returned then some keys may have been restored, while others
+// were not.
+func RestoreKeys(opts RestoreOpts, keys ...DumpedKey) Action {
+	cmds := make([]CmdAction, 0, len(keys))
+	for _, dk := range keys {
+		if dk.Value == nil {
+			continue
+		}
+
+		ttl := "0"
+		if !dk.ExpireAt.IsZero() {
+			// a timestamp of 0 means no expiry, so keys which have already
+			// expired are given one which is just in the past instead.
+			ms := dk.ExpireAt.UnixNano() / int64(time.Millisecond)
+			if ms < 1 {
+				ms = 1
+			}
+			ttl = strconv.FormatInt(ms, 10)
+		}
+
+		args := []string{dk.Key, ttl, string(dk.Value), "ABSTTL"}
+		if opts.Replace {
+			args = append(args, "REPLACE")
+		}
+		cmds = append(cmds, Cmd(nil, "RESTORE", args...))
+	}
+	return multiKeyAction{pipeline: cmds, merge: func() error { return nil }}
+}
+
+// MigrateOpts are options which can be passed into MigrateKeys.
+type MigrateOpts struct {
+	RestoreOpts
+
+	// Delete causes keys to be deleted from the source once they've been
+	// restored to the destination.
+	//
+	// NOTE that a key which is written to in between being dumped and being
+	// deleted will lose that write.
+	Delete bool
+
+	// BatchSize is the number of keys which are dumped and restored at a time.
+	//
+	// The default, if BatchSize is 0, is 100.
+	BatchSize int
+}
+
+// MigrateKeys copies every key returned by the Scanner, which should be
+// scanning the src Client, to the dst Client using DumpKeys and RestoreKeys.
+// Keys are copied in batches, see MigrateOpts. The number of keys which were
+// copied is returned, which doesn't include keys which were deleted or expired
+// before they could be dumped.
+//
+// Either Client may be a Cluster, in which case the Scanner should be created
+// using the Cluster's NewScanner method:
+//
+//	s := src.NewScanner(radix.ScanOpts{Command: "SCAN", Pattern: "user:*"})
+//	n, err := radix.MigrateKeys(dst, src, s, radix.MigrateOpts{})
+//
+// MigrateKeys returns as soon as any error is encountered, in which case the
+// keys of the current batch may have been partially copied. The Scanner is
+// always closed.
+func MigrateKeys(dst, src Client, s Scanner, opts MigrateOpts) (int, error) {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 100
+	}
+
+	var n int
+	batch := make([]string, 0, opts.BatchSize)
+	migrate := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+
+		var dumped []DumpedKey
+		if err := src.Do(DumpKeys(&dumped, batch...)); err != nil {
+			return err
+		} else if err := dst.Do(RestoreKeys(opts.RestoreOpts, dumped...)); err != nil {
+			return err
+		}
+
+		restored := make([]string, 0, len(dumped))
+		for _, dk := range dumped {
+			if dk.Value != nil {
+				restored = append(restored, dk.Key)
+			}
+		}
+		if opts.Delete && len(restored) > 0 {
+			if err := src.Do(ClusterDel(nil, restored...)); err != nil {
+				return err
+			}
+		}
+
+		n += len(restored)
+		batch = batch[:0]
+		return nil
+	}
+
+	var key string
+	for s.Next(&key) {
+		if batch = append(batch, key); len(batch) < opts.BatchSize {
+			continue
+		} else if err := migrate(); err != nil {
+			s.Close()
+			return n, err
+		}
+	}
+	if err := s.Close(); err != nil {
+		return n, err
+	}
+	return n, migrate()
+}
+
+// CopyKey copies the value of the key src, along with its expiry, to the key
+// dst, returning whether it was copied. It isn't copied if src doesn't exist,
+// or if dst already exists and opts.Replace isn't set.
+//
+// If both keys belong to the same slot then COPY is used. Otherwise, since
+// COPY fails with a CROSSSLOT error on a Cluster when the keys belong to
+// different slots, the key is copied using DumpKeys and RestoreKeys. This is
+// also done if COPY isn't supported, as with redis versions before 6.2.
+func CopyKey(c Client, src, dst string, opts RestoreOpts) (bool, error) {
+	if ClusterKeySlot(src) == ClusterKeySlot(dst) {
+		args := []string{src, dst}
+		if opts.Replace {
+			args = append(args, "REPLACE")
+		}
+		var copied bool
+		err := c.Do(Cmd(&copied, "COPY", args...))
+		if !isRespErrPrefix(err, "ERR unknown command") {
+			return copied, err
+		}
+	}
+
+	var dumped []DumpedKey
+	if err := c.Do(DumpKeys(&dumped, src)); err != nil {
+		return false, err
+	} else if dumped[0].Value == nil {
+		return false, nil
+	}
+
+	dumped[0].Key = dst
+	err := c.Do(RestoreKeys(opts, dumped[0]))
+	if isRespErrPrefix(err, "BUSYKEY") {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// MoveKey moves the value of the key src, along with its expiry, to the key
+// dst, returning whether it was moved. It isn't moved if src doesn't exist, or
+// if dst already exists and opts.Replace isn't set.
+//
+// If both keys belong to the same slot then RENAME (or RENAMENX, if
+// opts.Replace isn't set) is used, and the move is atomic. Otherwise, since
+// RENAME fails with a CROSSSLOT error on a Cluster when the keys belong to
+// different slots, the key is copied using CopyKey and then src is deleted.
+//
+// NOTE that in the latter case the move isn't atomic: a write to src in between
+// it being copied and deleted will be lost, and if an error is returned then
+// src may have been copied but not deleted.
+func MoveKey(c Client, src, dst string, opts RestoreOpts) (bool, error) {
+	if ClusterKeySlot(src) == ClusterKeySlot(dst) {
+		if opts.Replace {
+			err := c.Do(Cmd(nil, "RENAME", src, dst))
+			if isRespErrPrefix(err, "ERR no such key") {
+				return false, nil
+			}
+			return err == nil, err
+		}
+
+		var moved bool
+		err := c.Do(Cmd(&moved, "RENAMENX", src, dst))
+		if isRespErrPrefix(err, "ERR no such key") {
+			return false, nil
+		}
+		return moved, err
+	}
+
+	if copied, err := CopyKey(c, src, dst, opts); !copied || err != nil {
+		return false, err
+	} else if err := c.Do(Cmd(nil, "DEL", src)); err != nil {
+		return true, err
+	}
+	return true, nil
+}
@@ -0,0 +1,141 @@
+package radix
+
+import (
+	. "testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDumpRestoreKeys(t *T) {
+	src, dst := dial(), dial(DialSelectDB(1))
+	defer src.Close()
+	defer dst.Close()
+
+	k1, k2, k3 := randStr(), randStr(), randStr()
+	require.Nil(t, src.Do(Cmd(nil, "SET", k1, "foo")))
+	require.Nil(t, src.Do(Cmd(nil, "SET", k2, "bar", "EX", "100")))
+
+	var dumped []DumpedKey
+	require.Nil(t, src.Do(DumpKeys(&dumped, k1, k2, k3)))
+	require.Len(t, dumped, 3)
+	assert.Equal(t, k1, dumped[0].Key)
+	assert.NotNil(t, dumped[0].Value)
+	assert.True(t, dumped[0].ExpireAt.IsZero())
+	assert.NotNil(t, dumped[1].Value)
+	assert.WithinDuration(t, time.Now().Add(100*time.Second), dumped[1].ExpireAt, 5*time.Second)
+	assert.Equal(t, DumpedKey{Key: k3}, dumped[2])
+
+	require.Nil(t, dst.Do(RestoreKeys(RestoreOpts{}, dumped...)))
+	var v1, v2 string
+	var ttl int
+	require.Nil(t, dst.Do(Cmd(&v1, "GET", k1)))
+	require.Nil(t, dst.Do(Cmd(&v2, "GET", k2)))
+	require.Nil(t, dst.Do(Cmd(&ttl, "TTL", k2)))
+	assert.Equal(t, "foo", v1)
+	assert.Equal(t, "bar", v2)
+	assert.InDelta(t, 100, ttl, 5)
+
+	// without Replace existing keys aren't overwritten
+	assert.Error(t, dst.Do(RestoreKeys(RestoreOpts{}, dumped[0])))
+	assert.Nil(t, dst.Do(RestoreKeys(RestoreOpts{Replace: true}, dumped[0])))
+
+	// keys which didn't exist are skipped
+	assert.Nil(t, dst.Do(RestoreKeys(RestoreOpts{}, dumped[2])))
+}
+
+func TestMigrateKeys(t *T) {
+	src, dst := dial(), dial(DialSelectDB(1))
+	defer src.Close()
+	defer dst.Close()
+
+	prefix := randStr()
+	keys := make([]string, 5)
+	for i := range keys {
+		keys[i] = prefix + ":" + randStr()
+		require.Nil(t, src.Do(Cmd(nil, "SET", keys[i], keys[i])))
+	}
+
+	s := NewScanner(src, ScanOpts{Command: "SCAN", Pattern: prefix + ":*"})
+	n, err := MigrateKeys(dst, src, s, MigrateOpts{Delete: true, BatchSize: 2})
+	require.Nil(t, err)
+	assert.Equal(t, len(keys), n)
+
+	for _, key := range keys {
+		var srcExists int
+		var dstVal string
+		require.Nil(t, src.Do(Cmd(&srcExists, "EXISTS", key)))
+		require.Nil(t, dst.Do(Cmd(&dstVal, "GET", key)))
+		assert.Equal(t, 0, srcExists)
+		assert.Equal(t, key, dstVal)
+	}
+}
+
+func TestCopyMoveKey(t *T) {
+	c := dial()
+	defer c.Close()
+
+	// keys are either in the same slot, so COPY and RENAME are used, or in
+	// different slots, so DUMP and RESTORE are used
+	for _, sameSlot := range []bool{true, false} {
+		key := func() string {
+			if sameSlot {
+				return "{" + clusterSlotKeys[0] + "}" + randStr()
+			}
+			return randStr()
+		}
+		src, dst := key(), key()
+		require.Equal(t, sameSlot, ClusterKeySlot(src) == ClusterKeySlot(dst))
+		require.Nil(t, c.Do(Cmd(nil, "SET", src, "foo", "EX", "100")))
+
+		assertKey := func(key, expVal string) {
+			var val string
+			var ttl int
+			require.Nil(t, c.Do(Cmd(&val, "GET", key)))
+			require.Nil(t, c.Do(Cmd(&ttl, "TTL", key)))
+			assert.Equal(t, expVal, val)
+			assert.InDelta(t, 100, ttl, 5)
+		}
+
+		copied, err := CopyKey(c, src, dst, RestoreOpts{})
+		require.Nil(t, err)
+		assert.True(t, copied)
+		assertKey(src, "foo")
+		assertKey(dst, "foo")
+
+		require.Nil(t, c.Do(Cmd(nil, "SET", src, "bar", "EX", "100")))
+		copied, err = CopyKey(c, src, dst, RestoreOpts{})
+		require.Nil(t, err)
+		assert.False(t, copied)
+		copied, err = CopyKey(c, src, dst, RestoreOpts{Replace: true})
+		require.Nil(t, err)
+		assert.True(t, copied)
+		assertKey(dst, "bar")
+
+		dst2 := key()
+		moved, err := MoveKey(c, src, dst, RestoreOpts{})
+		require.Nil(t, err)
+		assert.False(t, moved)
+		moved, err = MoveKey(c, src, dst2, RestoreOpts{})
+		require.Nil(t, err)
+		assert.True(t, moved)
+		assertKey(dst2, "bar")
+
+		var exists int
+		require.Nil(t, c.Do(Cmd(&exists, "EXISTS", src)))
+		assert.Equal(t, 0, exists)
+
+		moved, err = MoveKey(c, dst2, dst, RestoreOpts{Replace: true})
+		require.Nil(t, err)
+		assert.True(t, moved)
+
+		// src no longer exists
+		copied, err = CopyKey(c, src, dst, RestoreOpts{Replace: true})
+		require.Nil(t, err)
+		assert.False(t, copied)
+		moved, err = MoveKey(c, src, dst, RestoreOpts{Replace: true})
+		require.Nil(t, err)
+		assert.False(t, moved)
+	}
+}
@@ -0,0 +1,220 @@
+package radix
+
+import (
+	"bufio"
+	"strconv"
+
+	errors "golang.org/x/xerrors"
+
+	"github.com/mediocregopher/radix/v3/resp/resp2"
+)
+
+// ModuleCmd is used to build the commands of redis modules which don't have
+// dedicated Actions, e.g.
+//
+//	cmd := NewModuleCmd("BF.INSERT").
+//		Key("filter").
+//		Opt("CAPACITY", "1000").
+//		Flag("NOCREATE", noCreate).
+//		Section("ITEMS", items...).
+//		Cmd(&added)
+//
+// The keys of the command are declared explicitly, using Key, rather than
+// being inferred from its arguments like with Cmd, so that commands whose keys
+// don't come first can be used with Cluster.
+//
+// A ModuleCmd's methods modify it in place, and return it so that calls can be
+// chained.
+type ModuleCmd struct {
+	cmd  string
+	args []string
+	keys []string
+}
+
+// NewModuleCmd initializes a ModuleCmd for the given command, e.g. "FT.SEARCH".
+func NewModuleCmd(cmd string) *ModuleCmd {
+	return &ModuleCmd{cmd: cmd}
+}
+
+// Key appends the given keys to the arguments, and declares them as keys of
+// the command.
+func (mc *ModuleCmd) Key(keys ...string) *ModuleCmd {
+	mc.args = append(mc.args, keys...)
+	mc.keys = append(mc.keys, keys...)
+	return mc
+}
+
+// Arg appends the given arguments.
+func (mc *ModuleCmd) Arg(args ...string) *ModuleCmd {
+	mc.args = append(mc.args, args...)
+	return mc
+}
+
+// Flag appends the given flag, e.g. "NOCREATE", if set is true.
+func (mc *ModuleCmd) Flag(name string, set bool) *ModuleCmd {
+	if set {
+		mc.args = append(mc.args, name)
+	}
+	return mc
+}
+
+// Opt appends the name of an option followed by its value, e.g.
+// "CAPACITY 1000", unless value is empty.
+func (mc *ModuleCmd) Opt(name, value string) *ModuleCmd {
+	if value != "" {
+		mc.args = append(mc.args, name, value)
+	}
+	return mc
+}
+
+// Section appends the name of a section followed by its arguments, e.g.
+// "ITEMS a b c", unless there are no arguments.
+func (mc *ModuleCmd) Section(name string, args ...string) *ModuleCmd {
+	if len(args) > 0 {
+		mc.args = append(mc.args, name)
+		mc.args = append(mc.args, args...)
+	}
+	return mc
+}
+
+// CountedSection is like Section, but the number of arguments is given before
+// them, e.g. "PREFIX 2 a b".
+func (mc *ModuleCmd) CountedSection(name string, args ...string) *ModuleCmd {
+	if len(args) > 0 {
+		mc.args = append(mc.args, name, strconv.Itoa(len(args)))
+		mc.args = append(mc.args, args...)
+	}
+	return mc
+}
+
+// Cmd returns a CmdAction which performs the command that's been built,
+// unmarshaling its reply into rcv as with Cmd. ModuleReply may be used as rcv
+// for replies of an arbitrary shape.
+//
+// Cmd may be called multiple times, with each CmdAction being independent of
+// any further changes to the ModuleCmd.
+func (mc *ModuleCmd) Cmd(rcv interface{}) CmdAction {
+	c := getCmdAction()
+	*c = cmdAction{
+		rcv:          rcv,
+		cmd:          mc.cmd,
+		args:         append([]string(nil), mc.args...),
+		explicitKeys: true,
+		keys:         append([]string(nil), mc.keys...),
+	}
+	return c
+}
+
+// ModuleReply is a receiver which unmarshals a reply of any shape, as many
+// module commands (e.g. the INFO commands of various modules) return deeply
+// nested replies.
+//
+// Value is set to a string for simple and bulk strings, an int64 for integers,
+// a []interface{} for arrays, and nil for nil replies.
+type ModuleReply struct {
+	Value interface{}
+}
+
+// normalizeModuleReply converts the values unmarshaled into an interface{} by
+// resp2.Any into the types described by ModuleReply.
+func normalizeModuleReply(v interface{}) interface{} {
+	switch vv := v.(type) {
+	case []byte:
+		if vv == nil {
+			return nil
+		}
+		return string(vv)
+	case []interface{}:
+		if vv == nil {
+			return nil
+		}
+		for i := range vv {
+			vv[i] = normalizeModuleReply(vv[i])
+		}
+		return vv
+	default:
+		return v
+	}
+}
+
+// UnmarshalRESP implements the resp.Unmarshaler interface.
+func (mr *ModuleReply) UnmarshalRESP(br *bufio.Reader) error {
+	var v interface{}
+	if err := (resp2.Any{I: &v}).UnmarshalRESP(br); err != nil {
+		return err
+	}
+	mr.Value = normalizeModuleReply(v)
+	return nil
+}
+
+// Map converts Value, which must be an array of alternating field names and
+// values, into a map of the names to their values.
+//
+// The values of any fields named in nested, at any depth, are converted into
+// maps in the same way. If such a value is an array of arrays, e.g. a list of
+// field descriptions, then each of its elements is converted instead, and the
+// value becomes a []interface{} of maps.
+func (mr ModuleReply) Map(nested ...string) (map[string]interface{}, error) {
+	nestedM := make(map[string]bool, len(nested))
+	for _, name := range nested {
+		nestedM[name] = true
+	}
+	return moduleReplyMap(mr.Value, nestedM)
+}
+
+func moduleReplyMap(v interface{}, nested map[string]bool) (map[string]interface{}, error) {
+	arr, ok := v.([]interface{})
+	if !ok && v != nil {
+		return nil, errors.Errorf("can't convert %T into a map", v)
+	} else if len(arr)%2 != 0 {
+		return nil, errors.Errorf("can't convert array with %d elements into a map", len(arr))
+	}
+
+	m := make(map[string]interface{}, len(arr)/2)
+	for i := 0; i < len(arr); i += 2 {
+		name, ok := arr[i].(string)
+		if !ok {
+			return nil, errors.Errorf("can't use %T as a field name", arr[i])
+		}
+
+		val := arr[i+1]
+		if nested[name] && val != nil {
+			var err error
+			if val, err = moduleReplyNested(val, nested); err != nil {
+				return nil, errors.Errorf("converting field %q: %w", name, err)
+			}
+		}
+		m[name] = val
+	}
+	return m, nil
+}
+
+// moduleReplyNested converts the value of a nested field, which is either
+// itself an array of field names and values, or an array of such arrays.
+func moduleReplyNested(v interface{}, nested map[string]bool) (interface{}, error) {
+	arr, ok := v.([]interface{})
+	if !ok {
+		return nil, errors.Errorf("can't convert %T into a map", v)
+	}
+
+	allArrays := len(arr) > 0
+	for _, el := range arr {
+		if _, ok := el.([]interface{}); !ok {
+			allArrays = false
+			break
+		}
+	}
+	if !allArrays {
+		return moduleReplyMap(arr, nested)
+	}
+
+	ms := make([]interface{}, len(arr))
+	for i, el := range arr {
+		m, err := moduleReplyMap(el, nested)
+		if err != nil {
+			return nil, err
+		}
+		ms[i] = m
+	}
+	return ms, nil
+}
@@ -0,0 +1,103 @@
+package radix
+
+import (
+	. "testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestModuleCmd(t *T) {
+	var gotArgs []string
+	conn := Stub("tcp", "127.0.0.1:6379", func(args []string) interface{} {
+		gotArgs = args
+		return []int64{1, 0}
+	})
+
+	mc := NewModuleCmd("CMS.MERGE").
+		Key("dst").
+		Arg("2").
+		Key("src1", "src2").
+		Flag("NOPE", false).
+		Opt("EMPTY", "").
+		Section("NONE").
+		CountedSection("NONE2").
+		Section("WEIGHTS", "1", "2")
+	cmd := mc.Cmd(nil)
+	assert.Equal(t, []string{"dst", "src1", "src2"}, cmd.Keys())
+	require.NoError(t, conn.Do(cmd))
+	assert.Equal(t, []string{"CMS.MERGE", "dst", "2", "src1", "src2", "WEIGHTS", "1", "2"}, gotArgs)
+
+	// further changes don't affect CmdActions which were already built
+	var added []bool
+	cmd = NewModuleCmd("BF.INSERT").
+		Key("filter").
+		Opt("CAPACITY", "100").
+		Flag("NOCREATE", true).
+		CountedSection("PREFIX", "a", "b").
+		Section("ITEMS", "x", "y").
+		Cmd(&added)
+	mc.Arg("more")
+	require.NoError(t, conn.Do(cmd))
+	assert.Equal(t, []string{
+		"BF.INSERT", "filter", "CAPACITY", "100", "NOCREATE", "PREFIX", "2", "a", "b", "ITEMS", "x", "y",
+	}, gotArgs)
+	assert.Equal(t, []bool{true, false}, added)
+
+	// commands without declared keys have none, rather than them being
+	// inferred from the arguments
+	assert.Empty(t, NewModuleCmd("FT._LIST").Arg("foo").Cmd(nil).Keys())
+}
+
+func TestModuleReply(t *T) {
+	conn := Stub("tcp", "127.0.0.1:6379", func(args []string) interface{} {
+		return []interface{}{
+			"index_name", "idx",
+			"num_docs", int64(3),
+			"index_definition", []interface{}{
+				"key_type", "HASH",
+				"prefixes", []string{"a:", "b:"},
+			},
+			"attributes", []interface{}{
+				[]interface{}{"identifier", "name", "type", "TEXT"},
+				[]interface{}{"identifier", "age", "type", "NUMERIC"},
+			},
+			"gc_stats", nil,
+			"errors", []interface{}{},
+		}
+	})
+
+	var reply ModuleReply
+	require.NoError(t, conn.Do(NewModuleCmd("FT.INFO").Arg("idx").Cmd(&reply)))
+	assert.Equal(t, []interface{}{"a:", "b:"}, reply.Value.([]interface{})[5].([]interface{})[3])
+
+	m, err := reply.Map("index_definition", "attributes", "gc_stats", "errors")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"index_name": "idx",
+		"num_docs":   int64(3),
+		"index_definition": map[string]interface{}{
+			"key_type": "HASH",
+			"prefixes": []interface{}{"a:", "b:"},
+		},
+		"attributes": []interface{}{
+			map[string]interface{}{"identifier": "name", "type": "TEXT"},
+			map[string]interface{}{"identifier": "age", "type": "NUMERIC"},
+		},
+		"gc_stats": nil,
+		"errors":   map[string]interface{}{},
+	}, m)
+
+	m, err = reply.Map()
+	require.NoError(t, err)
+	assert.IsType(t, []interface{}{}, m["attributes"])
+
+	_, err = ModuleReply{Value: "foo"}.Map()
+	assert.Error(t, err)
+	_, err = ModuleReply{Value: []interface{}{"foo"}}.Map()
+	assert.Error(t, err)
+	_, err = ModuleReply{Value: []interface{}{int64(1), "foo"}}.Map()
+	assert.Error(t, err)
+	_, err = ModuleReply{Value: []interface{}{"foo", "bar"}}.Map("foo")
+	assert.Error(t, err)
+}
@@ -0,0 +1,71 @@
+// Package moduletest contains helpers which are shared by the tests of the
+// packages in modules. Actions are tested in two ways: against a stub which
+// gives canned replies, to check the arguments an Action sends and how it
+// unmarshals a reply, and against a real redis server with the module loaded,
+// to check the behavior of the module itself.
+package moduletest
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/mediocregopher/radix/v3"
+)
+
+// Stub returns a Conn which records the arguments of every command performed
+// on it, and a pointer to the recorded arguments.
+//
+// Each command is replied to with the next of the replies given for its name,
+// with the last of them repeated once they run out, or with "OK" if no replies
+// were given for it.
+func Stub(replies map[string][]interface{}) (radix.Conn, *[][]string) {
+	var gotArgs [][]string
+	conn := radix.Stub("tcp", "127.0.0.1:6379", func(args []string) interface{} {
+		gotArgs = append(gotArgs, args)
+		cmdReplies := replies[args[0]]
+		if len(cmdReplies) == 0 {
+			return "OK"
+		}
+		reply := cmdReplies[0]
+		if len(cmdReplies) > 1 {
+			replies[args[0]] = cmdReplies[1:]
+		}
+		return reply
+	})
+	return conn, &gotArgs
+}
+
+// Dial returns a Conn to the redis server at 127.0.0.1:6379, or skips the test
+// if the server can't be connected to or doesn't have the named module loaded.
+func Dial(t *testing.T, module string) radix.Conn {
+	conn, err := radix.Dial("tcp", "127.0.0.1:6379")
+	if err != nil {
+		t.Skipf("can't connect to redis: %v", err)
+	}
+
+	var mods []map[string]interface{}
+	if err := conn.Do(radix.Cmd(&mods, "MODULE", "LIST")); err != nil {
+		conn.Close()
+		t.Skipf("can't list redis modules: %v", err)
+	}
+	for _, mod := range mods {
+		if strings.EqualFold(fmt.Sprintf("%s", mod["name"]), module) {
+			return conn
+		}
+	}
+	conn.Close()
+	t.Skipf("redis module %q isn't loaded", module)
+	return nil
+}
+
+// RandStr returns a random string, suitable for use as a key.
+func RandStr() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,115 @@
+package probabilistic
+
+import (
+	"strconv"
+
+	"github.com/mediocregopher/radix/v3"
+)
+
+// BFReserveOpts are optional parameters which can be given to BFReserve.
+type BFReserveOpts struct {
+	// Expansion is the factor by which the capacity of each sub-filter grows
+	// when the filter fills up, if greater than zero.
+	Expansion int
+
+	// NonScaling prevents the filter from growing when it fills up, instead
+	// returning an error when an item is added to it.
+	NonScaling bool
+}
+
+func (o BFReserveOpts) args() []string {
+	var args []string
+	if o.Expansion > 0 {
+		args = append(args, "EXPANSION", strconv.Itoa(o.Expansion))
+	}
+	if o.NonScaling {
+		args = append(args, "NONSCALING")
+	}
+	return args
+}
+
+// BFReserve returns an Action which performs BF.RESERVE, creating a bloom
+// filter in the given key which holds capacity items with the given rate of
+// false positives, e.g. 0.001 for a 0.1% rate.
+func BFReserve(key string, errorRate float64, capacity int64, opts BFReserveOpts) radix.CmdAction {
+	args := []string{formatFloat(errorRate), strconv.FormatInt(capacity, 10)}
+	return cmd(nil, "BF.RESERVE", key, append(args, opts.args()...)...)
+}
+
+// BFAdd returns an Action which performs BF.ADD, adding the item to the bloom
+// filter in the given key, which is created with the default options if it
+// doesn't exist. added, if not nil, is set to false if the item may have
+// already been added.
+func BFAdd(added *bool, key, item string) radix.CmdAction {
+	return cmd(added, "BF.ADD", key, item)
+}
+
+// BFMAdd is like BFAdd, but adds multiple items, setting added to whether each
+// one was added.
+func BFMAdd(added *[]bool, key string, items ...string) radix.CmdAction {
+	return cmd(added, "BF.MADD", key, items...)
+}
+
+// BFExists returns an Action which performs BF.EXISTS, setting exists to
+// whether the item may have been added to the bloom filter in the given key.
+func BFExists(exists *bool, key, item string) radix.CmdAction {
+	return cmd(exists, "BF.EXISTS", key, item)
+}
+
+// BFMExists is like BFExists, but checks multiple items, setting exists to
+// whether each one may have been added.
+func BFMExists(exists *[]bool, key string, items ...string) radix.CmdAction {
+	return cmd(exists, "BF.MEXISTS", key, items...)
+}
+
+// BFInsertOpts are optional parameters which can be given to BFInsert.
+type BFInsertOpts struct {
+	// Capacity and ErrorRate are used to create the filter if it doesn't
+	// exist, if greater than zero. Otherwise the server's defaults are used.
+	Capacity  int64
+	ErrorRate float64
+
+	// Expansion and NonScaling are used to create the filter if it doesn't
+	// exist, as with BFReserveOpts.
+	BFReserveOpts
+
+	// NoCreate causes an error to be returned if the filter doesn't exist,
+	// rather than creating it.
+	NoCreate bool
+}
+
+// BFInsert is like BFMAdd, but the bloom filter is created with the given
+// options if it doesn't exist.
+func BFInsert(added *[]bool, key string, opts BFInsertOpts, items ...string) radix.CmdAction {
+	var args []string
+	if opts.Capacity > 0 {
+		args = append(args, "CAPACITY", strconv.FormatInt(opts.Capacity, 10))
+	}
+	if opts.ErrorRate > 0 {
+		args = append(args, "ERROR", formatFloat(opts.ErrorRate))
+	}
+	args = append(args, opts.BFReserveOpts.args()...)
+	if opts.NoCreate {
+		args = append(args, "NOCREATE")
+	}
+	args = append(args, "ITEMS")
+	return cmd(added, "BF.INSERT", key, append(args, items...)...)
+}
+
+// BFAddBatch returns an Action which adds the items in each element of batch
+// to the bloom filter in its key, using a BF.MADD per element. added, if not
+// nil, is set immediately to a slice with an element for each element of
+// batch, which are filled in when the Action is performed as with BFMAdd.
+func BFAddBatch(added *[][]bool, batch ...KeyItems) radix.Action {
+	return batchAction(added, batch, func(rcv *[]bool, ki KeyItems) []radix.CmdAction {
+		return []radix.CmdAction{BFMAdd(rcv, ki.Key, ki.Items...)}
+	})
+}
+
+// BFExistsBatch is like BFAddBatch, but checks whether each item may have been
+// added, using a BF.MEXISTS per element of batch.
+func BFExistsBatch(exists *[][]bool, batch ...KeyItems) radix.Action {
+	return batchAction(exists, batch, func(rcv *[]bool, ki KeyItems) []radix.CmdAction {
+		return []radix.CmdAction{BFMExists(rcv, ki.Key, ki.Items...)}
+	})
+}
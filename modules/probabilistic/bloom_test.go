@@ -0,0 +1,96 @@
+package probabilistic
+
+import (
+	. "testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mediocregopher/radix/v3/modules/internal/moduletest"
+)
+
+func TestBloom(t *T) {
+	conn, gotArgs := moduletest.Stub(map[string][]interface{}{
+		"BF.ADD":     {int64(1), int64(0), int64(1)},
+		"BF.EXISTS":  {int64(1)},
+		"BF.MADD":    {[]int64{0, 1}},
+		"BF.MEXISTS": {[]int64{1, 0}},
+		"BF.INSERT":  {[]int64{1, 0}},
+	})
+
+	require.NoError(t, conn.Do(BFReserve("bf", 0.001, 1000, BFReserveOpts{})))
+	require.NoError(t, conn.Do(BFReserve("bf", 0.01, 1000, BFReserveOpts{
+		Expansion: 4, NonScaling: true,
+	})))
+	assert.Equal(t, [][]string{
+		{"BF.RESERVE", "bf", "0.001", "1000"},
+		{"BF.RESERVE", "bf", "0.01", "1000", "EXPANSION", "4", "NONSCALING"},
+	}, *gotArgs)
+
+	var added, exists bool
+	require.NoError(t, conn.Do(BFAdd(&added, "bf", "a")))
+	assert.True(t, added)
+	require.NoError(t, conn.Do(BFAdd(&added, "bf", "a")))
+	assert.False(t, added)
+	require.NoError(t, conn.Do(BFAdd(nil, "bf", "b")))
+	require.NoError(t, conn.Do(BFExists(&exists, "bf", "b")))
+	assert.True(t, exists)
+	assert.Equal(t, [][]string{
+		{"BF.ADD", "bf", "a"},
+		{"BF.ADD", "bf", "a"},
+		{"BF.ADD", "bf", "b"},
+		{"BF.EXISTS", "bf", "b"},
+	}, (*gotArgs)[2:])
+
+	var addedM, existsM []bool
+	require.NoError(t, conn.Do(BFMAdd(&addedM, "bf", "b", "c")))
+	assert.Equal(t, []bool{false, true}, addedM)
+	assert.Equal(t, []string{"BF.MADD", "bf", "b", "c"}, (*gotArgs)[len(*gotArgs)-1])
+	require.NoError(t, conn.Do(BFMExists(&existsM, "bf", "a", "d")))
+	assert.Equal(t, []bool{true, false}, existsM)
+	assert.Equal(t, []string{"BF.MEXISTS", "bf", "a", "d"}, (*gotArgs)[len(*gotArgs)-1])
+
+	require.NoError(t, conn.Do(BFInsert(&addedM, "bf", BFInsertOpts{
+		Capacity:      100,
+		ErrorRate:     0.1,
+		BFReserveOpts: BFReserveOpts{Expansion: 2},
+		NoCreate:      true,
+	}, "d", "a")))
+	assert.Equal(t, []bool{true, false}, addedM)
+	assert.Equal(t, []string{
+		"BF.INSERT", "bf", "CAPACITY", "100", "ERROR", "0.1", "EXPANSION", "2",
+		"NOCREATE", "ITEMS", "d", "a",
+	}, (*gotArgs)[len(*gotArgs)-1])
+}
+
+func TestBloomBatch(t *T) {
+	conn, gotArgs := moduletest.Stub(map[string][]interface{}{
+		"BF.MADD":    {[]int64{1, 1, 0}, []int64{1}},
+		"BF.MEXISTS": {[]int64{1, 0}, []int64{0}},
+	})
+
+	var added [][]bool
+	action := BFAddBatch(&added,
+		KeyItems{Key: "bf1", Items: []string{"a", "b", "a"}},
+		KeyItems{Key: "bf2", Items: []string{"a"}},
+	)
+	assert.Len(t, added, 2)
+	require.NoError(t, conn.Do(action))
+	assert.Equal(t, [][]bool{{true, true, false}, {true}}, added)
+
+	var exists [][]bool
+	require.NoError(t, conn.Do(BFExistsBatch(&exists,
+		KeyItems{Key: "bf1", Items: []string{"a", "c"}},
+		KeyItems{Key: "bf2", Items: []string{"b"}},
+	)))
+	assert.Equal(t, [][]bool{{true, false}, {false}}, exists)
+
+	assert.Equal(t, [][]string{
+		{"BF.MADD", "bf1", "a", "b", "a"},
+		{"BF.MADD", "bf2", "a"},
+		{"BF.MEXISTS", "bf1", "a", "c"},
+		{"BF.MEXISTS", "bf2", "b"},
+	}, *gotArgs)
+
+	require.NoError(t, conn.Do(BFAddBatch(nil, KeyItems{Key: "bf3", Items: []string{"a"}})))
+}
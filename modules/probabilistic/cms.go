@@ -0,0 +1,50 @@
+package probabilistic
+
+import (
+	"strconv"
+
+	"github.com/mediocregopher/radix/v3"
+)
+
+// CMSInitByDim returns an Action which performs CMS.INITBYDIM, creating a
+// count-min sketch in the given key with the given width and depth.
+func CMSInitByDim(key string, width, depth int64) radix.CmdAction {
+	return cmd(nil, "CMS.INITBYDIM", key,
+		strconv.FormatInt(width, 10), strconv.FormatInt(depth, 10))
+}
+
+// CMSInitByProb returns an Action which performs CMS.INITBYPROB, creating a
+// count-min sketch in the given key whose counts overestimate by at most
+// errorRate (as a fraction of the total count) with the given probability of
+// doing so, e.g. 0.001 and 0.01.
+func CMSInitByProb(key string, errorRate, probability float64) radix.CmdAction {
+	return cmd(nil, "CMS.INITBYPROB", key, formatFloat(errorRate), formatFloat(probability))
+}
+
+// CMSIncrBy returns an Action which performs CMS.INCRBY, incrementing the
+// count of each item in the count-min sketch in the given key. counts, if not
+// nil, is set to the new count of each item.
+func CMSIncrBy(counts *[]int64, key string, incrs ...ItemCount) radix.CmdAction {
+	return cmd(counts, "CMS.INCRBY", key, itemCountArgs(incrs)...)
+}
+
+// CMSQuery returns an Action which performs CMS.QUERY, setting counts to the
+// count of each item in the count-min sketch in the given key.
+func CMSQuery(counts *[]int64, key string, items ...string) radix.CmdAction {
+	return cmd(counts, "CMS.QUERY", key, items...)
+}
+
+// CMSMerge returns an Action which performs CMS.MERGE, merging the count-min
+// sketches in the src keys into the one in dst, which must already exist. If
+// weights is not empty then it must have a weight for each src key, by which
+// its counts are multiplied.
+func CMSMerge(dst string, srcs []string, weights []int64) radix.CmdAction {
+	args := append([]string{strconv.Itoa(len(srcs))}, srcs...)
+	if len(weights) > 0 {
+		args = append(args, "WEIGHTS")
+		for _, w := range weights {
+			args = append(args, strconv.FormatInt(w, 10))
+		}
+	}
+	return cmd(nil, "CMS.MERGE", dst, args...)
+}
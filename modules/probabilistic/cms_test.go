@@ -0,0 +1,42 @@
+package probabilistic
+
+import (
+	. "testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mediocregopher/radix/v3/modules/internal/moduletest"
+)
+
+func TestCMS(t *T) {
+	conn, gotArgs := moduletest.Stub(map[string][]interface{}{
+		"CMS.INCRBY": {[]int64{5, 6}},
+		"CMS.QUERY":  {[]int64{5, 6}},
+	})
+
+	require.NoError(t, conn.Do(CMSInitByDim("cms", 2000, 5)))
+	require.NoError(t, conn.Do(CMSInitByProb("cms", 0.001, 0.01)))
+
+	var counts []int64
+	require.NoError(t, conn.Do(CMSIncrBy(&counts, "cms", ItemCount{"a", 1}, ItemCount{"b", 3})))
+	assert.Equal(t, []int64{5, 6}, counts)
+	require.NoError(t, conn.Do(CMSIncrBy(nil, "cms", ItemCount{"a", 1})))
+
+	counts = nil
+	require.NoError(t, conn.Do(CMSQuery(&counts, "cms", "a", "b")))
+	assert.Equal(t, []int64{5, 6}, counts)
+
+	require.NoError(t, conn.Do(CMSMerge("dst", []string{"a", "b"}, nil)))
+	require.NoError(t, conn.Do(CMSMerge("dst", []string{"a", "b"}, []int64{1, 2})))
+
+	assert.Equal(t, [][]string{
+		{"CMS.INITBYDIM", "cms", "2000", "5"},
+		{"CMS.INITBYPROB", "cms", "0.001", "0.01"},
+		{"CMS.INCRBY", "cms", "a", "1", "b", "3"},
+		{"CMS.INCRBY", "cms", "a", "1"},
+		{"CMS.QUERY", "cms", "a", "b"},
+		{"CMS.MERGE", "dst", "2", "a", "b"},
+		{"CMS.MERGE", "dst", "2", "a", "b", "WEIGHTS", "1", "2"},
+	}, *gotArgs)
+}
@@ -0,0 +1,102 @@
+package probabilistic
+
+import (
+	"strconv"
+
+	"github.com/mediocregopher/radix/v3"
+)
+
+// CFReserveOpts are optional parameters which can be given to CFReserve. Each
+// is only used if greater than zero.
+type CFReserveOpts struct {
+	// BucketSize is the number of items in each bucket.
+	BucketSize int
+
+	// MaxIterations is the number of attempts made to swap items between
+	// buckets before the filter is considered full.
+	MaxIterations int
+
+	// Expansion is the factor by which the capacity of each sub-filter grows
+	// when the filter fills up.
+	Expansion int
+}
+
+// CFReserve returns an Action which performs CF.RESERVE, creating a cuckoo
+// filter in the given key which holds capacity items.
+func CFReserve(key string, capacity int64, opts CFReserveOpts) radix.CmdAction {
+	args := []string{strconv.FormatInt(capacity, 10)}
+	if opts.BucketSize > 0 {
+		args = append(args, "BUCKETSIZE", strconv.Itoa(opts.BucketSize))
+	}
+	if opts.MaxIterations > 0 {
+		args = append(args, "MAXITERATIONS", strconv.Itoa(opts.MaxIterations))
+	}
+	if opts.Expansion > 0 {
+		args = append(args, "EXPANSION", strconv.Itoa(opts.Expansion))
+	}
+	return cmd(nil, "CF.RESERVE", key, args...)
+}
+
+// CFAdd returns an Action which performs CF.ADD, adding the item to the cuckoo
+// filter in the given key, which is created with the default options if it
+// doesn't exist. Unlike a bloom filter an item can be added multiple times.
+func CFAdd(key, item string) radix.CmdAction {
+	return cmd(nil, "CF.ADD", key, item)
+}
+
+// CFAddNX returns an Action which performs CF.ADDNX, adding the item to the
+// cuckoo filter in the given key only if it may not have been added already.
+// added, if not nil, is set to whether the item was added.
+func CFAddNX(added *bool, key, item string) radix.CmdAction {
+	return cmd(added, "CF.ADDNX", key, item)
+}
+
+// CFExists returns an Action which performs CF.EXISTS, setting exists to
+// whether the item may have been added to the cuckoo filter in the given key.
+func CFExists(exists *bool, key, item string) radix.CmdAction {
+	return cmd(exists, "CF.EXISTS", key, item)
+}
+
+// CFMExists is like CFExists, but checks multiple items, setting exists to
+// whether each one may have been added.
+func CFMExists(exists *[]bool, key string, items ...string) radix.CmdAction {
+	return cmd(exists, "CF.MEXISTS", key, items...)
+}
+
+// CFDel returns an Action which performs CF.DEL, deleting one occurrence of
+// the item from the cuckoo filter in the given key. deleted, if not nil, is set
+// to whether the item was found.
+func CFDel(deleted *bool, key, item string) radix.CmdAction {
+	return cmd(deleted, "CF.DEL", key, item)
+}
+
+// CFCount returns an Action which performs CF.COUNT, setting count to the
+// number of times the item may have been added to the cuckoo filter in the
+// given key.
+func CFCount(count *int64, key, item string) radix.CmdAction {
+	return cmd(count, "CF.COUNT", key, item)
+}
+
+// CFAddBatch returns an Action which adds the items in each element of batch
+// to the cuckoo filter in its key, using a CF.ADDNX per item. added, if not
+// nil, is set immediately to a slice with an element for each element of
+// batch, which are filled in with whether each item was added when the Action
+// is performed.
+func CFAddBatch(added *[][]bool, batch ...KeyItems) radix.Action {
+	return batchAction(added, batch, func(rcv *[]bool, ki KeyItems) []radix.CmdAction {
+		*rcv = make([]bool, len(ki.Items))
+		cmds := make([]radix.CmdAction, len(ki.Items))
+		for i, item := range ki.Items {
+			cmds[i] = CFAddNX(&(*rcv)[i], ki.Key, item)
+		}
+		return cmds
+	})
+}
+
+// CFExistsBatch is like CFAddBatch, but checks whether each item may have been
+// added, using a CF.MEXISTS per element of batch.
+func CFExistsBatch(exists *[][]bool, batch ...KeyItems) radix.Action {
+	return batchAction(exists, batch, func(rcv *[]bool, ki KeyItems) []radix.CmdAction {
+		return []radix.CmdAction{CFMExists(rcv, ki.Key, ki.Items...)}
+	})
+}
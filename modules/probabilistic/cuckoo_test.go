@@ -0,0 +1,99 @@
+package probabilistic
+
+import (
+	. "testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mediocregopher/radix/v3/modules/internal/moduletest"
+)
+
+func TestCuckoo(t *T) {
+	conn, gotArgs := moduletest.Stub(map[string][]interface{}{
+		"CF.ADD":     {int64(1)},
+		"CF.COUNT":   {int64(2)},
+		"CF.ADDNX":   {int64(0), int64(1)},
+		"CF.EXISTS":  {int64(1)},
+		"CF.DEL":     {int64(1), int64(0)},
+		"CF.MEXISTS": {[]int64{1, 0}},
+	})
+
+	require.NoError(t, conn.Do(CFReserve("cf", 1000, CFReserveOpts{})))
+	require.NoError(t, conn.Do(CFReserve("cf", 1000, CFReserveOpts{
+		BucketSize: 4, MaxIterations: 20, Expansion: 2,
+	})))
+	assert.Equal(t, [][]string{
+		{"CF.RESERVE", "cf", "1000"},
+		{"CF.RESERVE", "cf", "1000", "BUCKETSIZE", "4", "MAXITERATIONS", "20", "EXPANSION", "2"},
+	}, *gotArgs)
+
+	require.NoError(t, conn.Do(CFAdd("cf", "a")))
+	require.NoError(t, conn.Do(CFAdd("cf", "a")))
+
+	var count int64
+	require.NoError(t, conn.Do(CFCount(&count, "cf", "a")))
+	assert.Equal(t, int64(2), count)
+
+	var added bool
+	require.NoError(t, conn.Do(CFAddNX(&added, "cf", "a")))
+	assert.False(t, added)
+	require.NoError(t, conn.Do(CFAddNX(&added, "cf", "b")))
+	assert.True(t, added)
+
+	var exists bool
+	require.NoError(t, conn.Do(CFExists(&exists, "cf", "b")))
+	assert.True(t, exists)
+
+	var deleted bool
+	require.NoError(t, conn.Do(CFDel(&deleted, "cf", "b")))
+	assert.True(t, deleted)
+	require.NoError(t, conn.Do(CFDel(&deleted, "cf", "b")))
+	assert.False(t, deleted)
+
+	var existsM []bool
+	require.NoError(t, conn.Do(CFMExists(&existsM, "cf", "a", "b")))
+	assert.Equal(t, []bool{true, false}, existsM)
+
+	assert.Equal(t, [][]string{
+		{"CF.ADD", "cf", "a"},
+		{"CF.ADD", "cf", "a"},
+		{"CF.COUNT", "cf", "a"},
+		{"CF.ADDNX", "cf", "a"},
+		{"CF.ADDNX", "cf", "b"},
+		{"CF.EXISTS", "cf", "b"},
+		{"CF.DEL", "cf", "b"},
+		{"CF.DEL", "cf", "b"},
+		{"CF.MEXISTS", "cf", "a", "b"},
+	}, (*gotArgs)[2:])
+}
+
+func TestCuckooBatch(t *T) {
+	conn, gotArgs := moduletest.Stub(map[string][]interface{}{
+		"CF.ADDNX":   {int64(1), int64(1), int64(0), int64(1)},
+		"CF.MEXISTS": {[]int64{1, 0}, []int64{1}},
+	})
+
+	var added [][]bool
+	require.NoError(t, conn.Do(CFAddBatch(&added,
+		KeyItems{Key: "cf1", Items: []string{"a", "b", "a"}},
+		KeyItems{Key: "cf2", Items: []string{"a"}},
+	)))
+	assert.Equal(t, [][]bool{{true, true, false}, {true}}, added)
+
+	var exists [][]bool
+	require.NoError(t, conn.Do(CFExistsBatch(&exists,
+		KeyItems{Key: "cf1", Items: []string{"b", "c"}},
+		KeyItems{Key: "cf2", Items: []string{"a"}},
+	)))
+	assert.Equal(t, [][]bool{{true, false}, {true}}, exists)
+
+	assert.Equal(t, [][]string{
+		{"CF.ADDNX", "cf1", "a"},
+		{"CF.ADDNX", "cf1", "b"},
+		{"CF.ADDNX", "cf1", "a"},
+		{"CF.ADDNX", "cf2", "a"},
+		{"CF.MEXISTS", "cf1", "b", "c"},
+		{"CF.MEXISTS", "cf2", "a"},
+	}, *gotArgs)
+}
@@ -0,0 +1,103 @@
+// Package probabilistic implements Actions for the commands of the RedisBloom
+// module, which provides probabilistic data structures: bloom filters (BF.*),
+// cuckoo filters (CF.*), count-min sketches (CMS.*) and top-k (TOPK.*).
+//
+// Each Action operates on a single key, so they can be used with a radix.Pool
+// or a radix.Cluster alike, apart from CMSMerge whose keys must all belong to
+// the same slot when used with a radix.Cluster.
+//
+// The Batch variants of the ADD and EXISTS Actions, e.g. BFAddBatch, operate on
+// items spread over many keys. They send a command per key (or per item, for
+// adding to cuckoo filters) in a single radix.Pipeline, which a radix.Cluster
+// splits up by node.
+package probabilistic
+
+import (
+	"bufio"
+	"reflect"
+	"strconv"
+
+	errors "golang.org/x/xerrors"
+
+	"github.com/mediocregopher/radix/v3"
+	"github.com/mediocregopher/radix/v3/resp/resp2"
+)
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// KeyItems describes a set of items in the structure in Key, for use with the
+// Batch Actions.
+type KeyItems struct {
+	Key   string
+	Items []string
+}
+
+// batchAction returns a radix.Pipeline which performs a command for each element of
+// batch, using mkCmd to create it. The results of each element's items are
+// written into the corresponding element of rcv, which is allocated
+// immediately.
+func batchAction(
+	rcv *[][]bool, batch []KeyItems,
+	mkCmd func(rcv *[]bool, ki KeyItems) []radix.CmdAction,
+) radix.Action {
+	results := make([][]bool, len(batch))
+	if rcv != nil {
+		*rcv = results
+	}
+
+	var cmds []radix.CmdAction
+	for i, ki := range batch {
+		cmds = append(cmds, mkCmd(&results[i], ki)...)
+	}
+	return radix.Pipeline(cmds...)
+}
+
+// ItemCount is an item of a count-min sketch or top-k, along with its count.
+type ItemCount struct {
+	Item  string
+	Count int64
+}
+
+// itemCounts unmarshals a flat array of alternating items and counts.
+type itemCounts []ItemCount
+
+func (ic *itemCounts) UnmarshalRESP(br *bufio.Reader) error {
+	var arrHead resp2.ArrayHeader
+	if err := arrHead.UnmarshalRESP(br); err != nil {
+		return err
+	} else if arrHead.N%2 != 0 {
+		return errors.Errorf("malformed reply with %d elements, expected item/count pairs", arrHead.N)
+	}
+
+	counts := make(itemCounts, arrHead.N/2)
+	for i := range counts {
+		if err := (resp2.Any{I: &counts[i].Item}).UnmarshalRESP(br); err != nil {
+			return err
+		} else if err := (resp2.Any{I: &counts[i].Count}).UnmarshalRESP(br); err != nil {
+			return err
+		}
+	}
+	*ic = counts
+	return nil
+}
+
+// itemCountArgs returns the arguments for the given ItemCounts, as used by
+// CMS.INCRBY and TOPK.INCRBY.
+func itemCountArgs(incrs []ItemCount) []string {
+	args := make([]string, 0, len(incrs)*2)
+	for _, ic := range incrs {
+		args = append(args, ic.Item, strconv.FormatInt(ic.Count, 10))
+	}
+	return args
+}
+
+// cmd returns a CmdAction for the given command, key and items. rcv may be a
+// nil pointer, in which case the reply is discarded.
+func cmd(rcv interface{}, cmd, key string, items ...string) radix.CmdAction {
+	if v := reflect.ValueOf(rcv); v.Kind() == reflect.Ptr && v.IsNil() {
+		rcv = nil
+	}
+	return radix.Cmd(rcv, cmd, append([]string{key}, items...)...)
+}
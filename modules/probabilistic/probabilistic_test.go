@@ -0,0 +1,79 @@
+package probabilistic
+
+import (
+	. "testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mediocregopher/radix/v3"
+	"github.com/mediocregopher/radix/v3/modules/internal/moduletest"
+)
+
+func TestItemCounts(t *T) {
+	conn, gotArgs := moduletest.Stub(map[string][]interface{}{
+		"TOPK.LIST": {[]interface{}{"a", int64(3), "b", int64(1)}},
+	})
+
+	var counts []ItemCount
+	require.NoError(t, conn.Do(TopKListWithCount(&counts, "topk")))
+	assert.Equal(t, []ItemCount{{"a", 3}, {"b", 1}}, counts)
+	assert.Equal(t, []string{"TOPK.LIST", "topk", "WITHCOUNT"}, (*gotArgs)[0])
+
+	require.NoError(t, conn.Do(TopKListWithCount(nil, "topk")))
+}
+
+func TestModule(t *T) {
+	conn := moduletest.Dial(t, "bf")
+	defer conn.Close()
+
+	bf, cf, cms, topk := moduletest.RandStr(), moduletest.RandStr(), moduletest.RandStr(), moduletest.RandStr()
+	defer conn.Do(radix.Cmd(nil, "DEL", bf, cf, cms, topk))
+
+	require.NoError(t, conn.Do(BFReserve(bf, 0.001, 1000, BFReserveOpts{})))
+	var added, exists bool
+	require.NoError(t, conn.Do(BFAdd(&added, bf, "a")))
+	assert.True(t, added)
+	require.NoError(t, conn.Do(BFAdd(&added, bf, "a")))
+	assert.False(t, added)
+	require.NoError(t, conn.Do(BFExists(&exists, bf, "a")))
+	assert.True(t, exists)
+	var addedM, existsM []bool
+	require.NoError(t, conn.Do(BFMAdd(&addedM, bf, "a", "b")))
+	assert.Equal(t, []bool{false, true}, addedM)
+	require.NoError(t, conn.Do(BFMExists(&existsM, bf, "b", "c")))
+	assert.Equal(t, []bool{true, false}, existsM)
+
+	require.NoError(t, conn.Do(CFReserve(cf, 1000, CFReserveOpts{})))
+	require.NoError(t, conn.Do(CFAdd(cf, "a")))
+	require.NoError(t, conn.Do(CFAdd(cf, "a")))
+	var count int64
+	require.NoError(t, conn.Do(CFCount(&count, cf, "a")))
+	assert.Equal(t, int64(2), count)
+	require.NoError(t, conn.Do(CFAddNX(&added, cf, "a")))
+	assert.False(t, added)
+	var deleted bool
+	require.NoError(t, conn.Do(CFDel(&deleted, cf, "a")))
+	assert.True(t, deleted)
+	require.NoError(t, conn.Do(CFMExists(&existsM, cf, "a", "b")))
+	assert.Equal(t, []bool{true, false}, existsM)
+
+	require.NoError(t, conn.Do(CMSInitByDim(cms, 2000, 5)))
+	var counts []int64
+	require.NoError(t, conn.Do(CMSIncrBy(&counts, cms, ItemCount{"a", 1}, ItemCount{"b", 3})))
+	assert.Equal(t, []int64{1, 3}, counts)
+	require.NoError(t, conn.Do(CMSQuery(&counts, cms, "a", "b", "c")))
+	assert.Equal(t, []int64{1, 3, 0}, counts)
+
+	require.NoError(t, conn.Do(TopKReserve(topk, 2, TopKReserveOpts{})))
+	require.NoError(t, conn.Do(TopKIncrBy(nil, topk, ItemCount{"a", 3}, ItemCount{"b", 1})))
+	var inTopK []bool
+	require.NoError(t, conn.Do(TopKQuery(&inTopK, topk, "a", "c")))
+	assert.Equal(t, []bool{true, false}, inTopK)
+	var items []string
+	require.NoError(t, conn.Do(TopKList(&items, topk)))
+	assert.Equal(t, []string{"a", "b"}, items)
+	var itemCounts []ItemCount
+	require.NoError(t, conn.Do(TopKListWithCount(&itemCounts, topk)))
+	assert.Equal(t, []ItemCount{{"a", 3}, {"b", 1}}, itemCounts)
+}
@@ -0,0 +1,87 @@
+package probabilistic
+
+import (
+	"strconv"
+
+	"github.com/mediocregopher/radix/v3"
+)
+
+// TopKReserveOpts are optional parameters which can be given to TopKReserve.
+// If any are set then all must be.
+type TopKReserveOpts struct {
+	// Width and Depth are the dimensions of the count-min sketch used to
+	// count items.
+	Width, Depth int64
+
+	// Decay is the probability of the counts of other items being decayed
+	// when an item is added.
+	Decay float64
+}
+
+// TopKReserve returns an Action which performs TOPK.RESERVE, creating a top-k
+// in the given key which keeps track of the k most frequent items.
+func TopKReserve(key string, k int64, opts TopKReserveOpts) radix.CmdAction {
+	args := []string{strconv.FormatInt(k, 10)}
+	if opts != (TopKReserveOpts{}) {
+		args = append(args,
+			strconv.FormatInt(opts.Width, 10),
+			strconv.FormatInt(opts.Depth, 10),
+			formatFloat(opts.Decay),
+		)
+	}
+	return cmd(nil, "TOPK.RESERVE", key, args...)
+}
+
+// TopKAdd returns an Action which performs TOPK.ADD, adding the items to the
+// top-k in the given key. dropped, if not nil, is set to the item which was
+// dropped from the top-k as a result of adding each item, or an empty string
+// if none was.
+func TopKAdd(dropped *[]string, key string, items ...string) radix.CmdAction {
+	return cmd(dropped, "TOPK.ADD", key, items...)
+}
+
+// TopKIncrBy is like TopKAdd, but increments the count of each item by the
+// given amount.
+func TopKIncrBy(dropped *[]string, key string, incrs ...ItemCount) radix.CmdAction {
+	return cmd(dropped, "TOPK.INCRBY", key, itemCountArgs(incrs)...)
+}
+
+// TopKQuery returns an Action which performs TOPK.QUERY, setting inTopK to
+// whether each item is in the top-k in the given key.
+func TopKQuery(inTopK *[]bool, key string, items ...string) radix.CmdAction {
+	return cmd(inTopK, "TOPK.QUERY", key, items...)
+}
+
+// TopKList returns an Action which performs TOPK.LIST, setting items to the
+// items in the top-k in the given key, most frequent first.
+func TopKList(items *[]string, key string) radix.CmdAction {
+	return cmd(items, "TOPK.LIST", key)
+}
+
+// TopKListWithCount is like TopKList, but sets counts to each item along with
+// its count.
+func TopKListWithCount(counts *[]ItemCount, key string) radix.CmdAction {
+	var rcv *itemCounts
+	if counts != nil {
+		rcv = (*itemCounts)(counts)
+	}
+	return cmd(rcv, "TOPK.LIST", key, "WITHCOUNT")
+}
+
+// TopKAddBatch returns an Action which adds the items in each element of batch
+// to the top-k in its key, using a TOPK.ADD per element.
+func TopKAddBatch(batch ...KeyItems) radix.Action {
+	return batchAction(nil, batch, func(_ *[]bool, ki KeyItems) []radix.CmdAction {
+		return []radix.CmdAction{TopKAdd(nil, ki.Key, ki.Items...)}
+	})
+}
+
+// TopKQueryBatch is like TopKAddBatch, but checks whether each item is in the
+// top-k, using a TOPK.QUERY per element of batch. inTopK, if not nil, is set
+// immediately to a slice with an element for each element of batch, which are
+// filled in when the Action is performed as with TopKQuery.
+func TopKQueryBatch(inTopK *[][]bool, batch ...KeyItems) radix.Action {
+	return batchAction(inTopK, batch, func(rcv *[]bool, ki KeyItems) []radix.CmdAction {
+		return []radix.CmdAction{TopKQuery(rcv, ki.Key, ki.Items...)}
+	})
+}
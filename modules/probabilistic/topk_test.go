@@ -0,0 +1,70 @@
+package probabilistic
+
+import (
+	. "testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mediocregopher/radix/v3/modules/internal/moduletest"
+)
+
+func TestTopK(t *T) {
+	conn, gotArgs := moduletest.Stub(map[string][]interface{}{
+		"TOPK.ADD":   {[]interface{}{"dropped", nil}},
+		"TOPK.QUERY": {[]int64{1, 0}},
+		"TOPK.LIST":  {[]string{"a", "b"}},
+	})
+
+	require.NoError(t, conn.Do(TopKReserve("topk", 10, TopKReserveOpts{})))
+	require.NoError(t, conn.Do(TopKReserve("topk", 10, TopKReserveOpts{
+		Width: 50, Depth: 4, Decay: 0.9,
+	})))
+
+	var dropped []string
+	require.NoError(t, conn.Do(TopKAdd(&dropped, "topk", "a", "b")))
+	assert.Equal(t, []string{"dropped", ""}, dropped)
+	require.NoError(t, conn.Do(TopKIncrBy(nil, "topk", ItemCount{"a", 2})))
+
+	var inTopK []bool
+	require.NoError(t, conn.Do(TopKQuery(&inTopK, "topk", "a", "c")))
+	assert.Equal(t, []bool{true, false}, inTopK)
+
+	var items []string
+	require.NoError(t, conn.Do(TopKList(&items, "topk")))
+	assert.Equal(t, []string{"a", "b"}, items)
+
+	assert.Equal(t, [][]string{
+		{"TOPK.RESERVE", "topk", "10"},
+		{"TOPK.RESERVE", "topk", "10", "50", "4", "0.9"},
+		{"TOPK.ADD", "topk", "a", "b"},
+		{"TOPK.INCRBY", "topk", "a", "2"},
+		{"TOPK.QUERY", "topk", "a", "c"},
+		{"TOPK.LIST", "topk"},
+	}, *gotArgs)
+}
+
+func TestTopKBatch(t *T) {
+	conn, gotArgs := moduletest.Stub(map[string][]interface{}{
+		"TOPK.QUERY": {[]int64{1, 0}, []int64{1}},
+	})
+
+	require.NoError(t, conn.Do(TopKAddBatch(
+		KeyItems{Key: "topk1", Items: []string{"a", "b"}},
+		KeyItems{Key: "topk2", Items: []string{"c"}},
+	)))
+
+	var inTopK [][]bool
+	require.NoError(t, conn.Do(TopKQueryBatch(&inTopK,
+		KeyItems{Key: "topk1", Items: []string{"a", "c"}},
+		KeyItems{Key: "topk2", Items: []string{"c"}},
+	)))
+	assert.Equal(t, [][]bool{{true, false}, {true}}, inTopK)
+
+	assert.Equal(t, [][]string{
+		{"TOPK.ADD", "topk1", "a", "b"},
+		{"TOPK.ADD", "topk2", "c"},
+		{"TOPK.QUERY", "topk1", "a", "c"},
+		{"TOPK.QUERY", "topk2", "c"},
+	}, *gotArgs)
+}
@@ -0,0 +1,235 @@
+// Package rejson implements Actions for the commands of the RedisJSON module,
+// which stores JSON documents in redis keys.
+//
+// Values passed into the Actions are marshaled into JSON using encoding/json,
+// and JSON replies are unmarshaled into the given receivers in the same way, so
+// that documents can be read and written as regular Go structs:
+//
+//	type User struct {
+//		Name string   `json:"name"`
+//		Tags []string `json:"tags"`
+//	}
+//
+//	err := client.Do(rejson.Set("user:1", "$", User{Name: "alice"}))
+//	err = client.Do(rejson.ArrAppend(nil, "user:1", "$.tags", "admin"))
+//
+//	var users []User
+//	err = client.Do(rejson.Get(&users, "user:1", "$"))
+//
+// Paths may be given either as JSONPath, beginning with "$", or using the
+// legacy path syntax, e.g. "." or ".name". When a JSONPath is used the reply to
+// most commands contains a value for each element matched by the path, and so
+// the receiver must be a slice, whereas a legacy path always matches a single
+// element.
+//
+// Every Action operates on a single key, apart from MGet, so the Actions can be
+// used with a radix.Pool or a radix.Cluster alike, and can be pipelined.
+package rejson
+
+import (
+	"bufio"
+	"encoding/json"
+	"reflect"
+
+	errors "golang.org/x/xerrors"
+
+	"github.com/mediocregopher/radix/v3"
+	"github.com/mediocregopher/radix/v3/resp"
+	"github.com/mediocregopher/radix/v3/resp/resp2"
+)
+
+// jsonRcv wraps rcv so that the reply unmarshaled into it is decoded as JSON.
+func jsonRcv(rcv interface{}) interface{} {
+	if rcv == nil {
+		return nil
+	}
+	return resp2.JSON{I: rcv}
+}
+
+// Set returns an Action which performs JSON.SET, marshaling v into JSON and
+// setting it at the given path of the document in key. path must be the root,
+// "$" or ".", if the key doesn't exist yet.
+func Set(key, path string, v interface{}) radix.CmdAction {
+	return radix.FlatCmd(nil, "JSON.SET", key, path, resp2.JSON{I: v})
+}
+
+// setReply unmarshals the reply to a JSON.SET with NX or XX, which is nil if
+// the value wasn't set.
+type setReply struct {
+	set *bool
+}
+
+func (r setReply) UnmarshalRESP(br *bufio.Reader) error {
+	var m resp2.Maybe
+	if err := m.UnmarshalRESP(br); err != nil {
+		return err
+	}
+	if r.set != nil {
+		*r.set = !m.Nil
+	}
+	return nil
+}
+
+// SetNX is like Set, but only sets the value if the path doesn't already
+// exist. set, if not nil, is set to whether the value was set.
+func SetNX(set *bool, key, path string, v interface{}) radix.CmdAction {
+	return radix.FlatCmd(setReply{set: set}, "JSON.SET", key, path, resp2.JSON{I: v}, "NX")
+}
+
+// SetXX is like Set, but only sets the value if the path already exists. set,
+// if not nil, is set to whether the value was set.
+func SetXX(set *bool, key, path string, v interface{}) radix.CmdAction {
+	return radix.FlatCmd(setReply{set: set}, "JSON.SET", key, path, resp2.JSON{I: v}, "XX")
+}
+
+// Get returns an Action which performs JSON.GET, unmarshaling the JSON at the
+// given paths of the document in key into rcv. If no paths are given then the
+// whole document is returned, as if the legacy root path "." were given. If
+// more than one path is given then the reply is a JSON object mapping each
+// path to its value(s).
+//
+// If the key doesn't exist then rcv is left untouched.
+func Get(rcv interface{}, key string, paths ...string) radix.CmdAction {
+	return radix.Cmd(jsonRcv(rcv), "JSON.GET", append([]string{key}, paths...)...)
+}
+
+// mgetReply unmarshals the reply to JSON.MGET, an array containing the JSON
+// for each key or nil, into a pointer to a slice.
+type mgetReply struct {
+	rcv interface{}
+}
+
+func (r mgetReply) UnmarshalRESP(br *bufio.Reader) error {
+	var docs [][]byte
+	if err := (resp2.Any{I: &docs}).UnmarshalRESP(br); err != nil {
+		return err
+	} else if r.rcv == nil {
+		return nil
+	}
+
+	v := reflect.ValueOf(r.rcv)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Slice {
+		return resp.ErrDiscarded{Err: errors.Errorf("can't unmarshal JSON.MGET reply into %T", r.rcv)}
+	}
+
+	s := reflect.MakeSlice(v.Elem().Type(), len(docs), len(docs))
+	for i, doc := range docs {
+		if len(doc) == 0 {
+			continue
+		} else if err := json.Unmarshal(doc, s.Index(i).Addr().Interface()); err != nil {
+			return resp.ErrDiscarded{Err: err}
+		}
+	}
+	v.Elem().Set(s)
+	return nil
+}
+
+// mgetAction overrides the keys of the embedded CmdAction, since those of a
+// JSON.MGET are followed by the path.
+type mgetAction struct {
+	radix.CmdAction
+	keys []string
+}
+
+func (a mgetAction) Keys() []string {
+	return a.keys
+}
+
+// MGet returns an Action which performs JSON.MGET, unmarshaling the JSON at the
+// given path of the document in each of the given keys into rcv, which must be
+// a pointer to a slice. The slice will have an element for each key, which is
+// left as its zero value if the key doesn't exist.
+//
+// When used with a radix.Cluster all of the keys must belong to the same slot.
+func MGet(rcv interface{}, path string, keys ...string) radix.CmdAction {
+	args := append(append([]string{}, keys...), path)
+	return mgetAction{
+		CmdAction: radix.Cmd(mgetReply{rcv: rcv}, "JSON.MGET", args...),
+		keys:      keys,
+	}
+}
+
+// Del returns an Action which performs JSON.DEL, deleting the value(s) at the
+// given path of the document in key, or the whole key if path is empty. n, if
+// not nil, is set to the number of values which were deleted.
+func Del(n *int, key, path string) radix.CmdAction {
+	args := []string{key}
+	if path != "" {
+		args = append(args, path)
+	}
+	if n == nil {
+		return radix.Cmd(nil, "JSON.DEL", args...)
+	}
+	return radix.Cmd(n, "JSON.DEL", args...)
+}
+
+// ArrAppend returns an Action which performs JSON.ARRAPPEND, marshaling each
+// of the given values into JSON and appending them to the array(s) at the given
+// path of the document in key.
+//
+// The new length of each array is unmarshaled into rcv. For a JSONPath rcv
+// should be a pointer to a slice, with -1 (or nil, for a slice of pointers)
+// given for matched elements which aren't arrays. Otherwise it should be a
+// pointer to an integer.
+func ArrAppend(rcv interface{}, key, path string, vs ...interface{}) radix.CmdAction {
+	args := []interface{}{path}
+	for _, v := range vs {
+		args = append(args, resp2.JSON{I: v})
+	}
+	return radix.FlatCmd(lengthsRcv(rcv), "JSON.ARRAPPEND", key, args...)
+}
+
+// lengthsReply unmarshals a reply which is either an integer or an array of
+// integers and nils, replacing the nils with -1 when rcv is a pointer to a
+// slice of integers.
+type lengthsReply struct {
+	rcv interface{}
+}
+
+func lengthsRcv(rcv interface{}) interface{} {
+	if rcv == nil {
+		return nil
+	}
+	return lengthsReply{rcv: rcv}
+}
+
+func (r lengthsReply) UnmarshalRESP(br *bufio.Reader) error {
+	var rm resp2.RawMessage
+	if err := rm.UnmarshalRESP(br); err != nil {
+		return err
+	}
+
+	v := reflect.ValueOf(r.rcv)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Slice {
+		return rm.UnmarshalInto(resp2.Any{I: r.rcv})
+	}
+
+	var lengths []resp2.RawMessage
+	if err := rm.UnmarshalInto(resp2.Any{I: &lengths}); err != nil {
+		return err
+	}
+
+	s := reflect.MakeSlice(v.Elem().Type(), len(lengths), len(lengths))
+	for i, l := range lengths {
+		elem := s.Index(i)
+		if !l.IsNil() {
+			if err := l.UnmarshalInto(resp2.Any{I: elem.Addr().Interface()}); err != nil {
+				return err
+			}
+			continue
+		}
+		switch elem.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			elem.SetInt(-1)
+		}
+	}
+	v.Elem().Set(s)
+	return nil
+}
+
+// NumIncrBy returns an Action which performs JSON.NUMINCRBY, incrementing the
+// number(s) at the given path of the document in key by n. The JSON of the new
+// value(s) is unmarshaled into rcv, which should be a slice for a JSONPath.
+func NumIncrBy(rcv interface{}, key, path string, n float64) radix.CmdAction {
+	return radix.FlatCmd(jsonRcv(rcv), "JSON.NUMINCRBY", key, path, n)
+}
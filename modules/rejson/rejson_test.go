@@ -0,0 +1,147 @@
+package rejson
+
+import (
+	. "testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mediocregopher/radix/v3"
+	"github.com/mediocregopher/radix/v3/modules/internal/moduletest"
+)
+
+type user struct {
+	Name string   `json:"name"`
+	Tags []string `json:"tags"`
+	Age  int      `json:"age,omitempty"`
+}
+
+func TestActions(t *T) {
+	conn, gotArgs := moduletest.Stub(map[string][]interface{}{
+		"JSON.SET":       {"OK", nil, "OK", nil},
+		"JSON.ARRAPPEND": {[]interface{}{int64(2)}, []interface{}{nil}, []interface{}{nil}},
+		"JSON.GET":       {`[{"name":"alice","tags":["admin","ops"]}]`, `["carol"]`, nil},
+		"JSON.MGET":      {[]interface{}{`["alice"]`, nil, `["carol"]`}},
+		"JSON.NUMINCRBY": {"[5]"},
+		"JSON.DEL":       {int64(1)},
+	})
+	lastArgs := func() []string { return (*gotArgs)[len(*gotArgs)-1] }
+
+	require.NoError(t, conn.Do(Set("user:1", "$", user{Name: "alice", Tags: []string{}})))
+	assert.Equal(t, []string{"JSON.SET", "user:1", "$", `{"name":"alice","tags":[]}`}, lastArgs())
+
+	var set bool
+	require.NoError(t, conn.Do(SetNX(&set, "user:1", "$", user{Name: "bob"})))
+	assert.False(t, set)
+	assert.Equal(t, []string{"JSON.SET", "user:1", "$", `{"name":"bob","tags":null}`, "NX"}, lastArgs())
+	require.NoError(t, conn.Do(SetXX(&set, "user:2", "$.name", "carol")))
+	assert.True(t, set)
+	assert.Equal(t, []string{"JSON.SET", "user:2", "$.name", `"carol"`, "XX"}, lastArgs())
+	require.NoError(t, conn.Do(SetXX(&set, "user:3", "$", user{})))
+	assert.False(t, set)
+
+	var lengths []int
+	require.NoError(t, conn.Do(ArrAppend(&lengths, "user:1", "$.tags", "admin", "ops")))
+	assert.Equal(t, []int{2}, lengths)
+	assert.Equal(t, []string{"JSON.ARRAPPEND", "user:1", "$.tags", `"admin"`, `"ops"`}, lastArgs())
+	require.NoError(t, conn.Do(ArrAppend(&lengths, "user:1", "$.name", "x")))
+	assert.Equal(t, []int{-1}, lengths)
+	var lengthPtrs []*int
+	require.NoError(t, conn.Do(ArrAppend(&lengthPtrs, "user:1", "$.name", "x")))
+	assert.Equal(t, []*int{nil}, lengthPtrs)
+
+	var users []user
+	require.NoError(t, conn.Do(Get(&users, "user:1", "$")))
+	assert.Equal(t, []user{{Name: "alice", Tags: []string{"admin", "ops"}}}, users)
+	assert.Equal(t, []string{"JSON.GET", "user:1", "$"}, lastArgs())
+
+	var names []string
+	require.NoError(t, conn.Do(Get(&names, "user:2", "$.name")))
+	assert.Equal(t, []string{"carol"}, names)
+
+	// a nil reply, for a missing key, leaves the receiver untouched
+	require.NoError(t, conn.Do(Get(&names, "user:3", "$.name")))
+	assert.Equal(t, []string{"carol"}, names)
+
+	var mget [][]string
+	require.NoError(t, conn.Do(MGet(&mget, "$.name", "user:1", "user:3", "user:2")))
+	assert.Equal(t, [][]string{{"alice"}, nil, {"carol"}}, mget)
+	assert.Equal(t, []string{"JSON.MGET", "user:1", "user:3", "user:2", "$.name"}, lastArgs())
+	assert.Equal(t, []string{"user:1", "user:3", "user:2"}, MGet(nil, "$", "user:1", "user:3", "user:2").Keys())
+
+	var nums []float64
+	require.NoError(t, conn.Do(NumIncrBy(&nums, "user:1", "$.age", 2.5)))
+	assert.Equal(t, []float64{5}, nums)
+	assert.Equal(t, []string{"JSON.NUMINCRBY", "user:1", "$.age", "2.5"}, lastArgs())
+
+	var n int
+	require.NoError(t, conn.Do(Del(&n, "user:1", "")))
+	assert.Equal(t, 1, n)
+	assert.Equal(t, []string{"JSON.DEL", "user:1"}, lastArgs())
+	require.NoError(t, conn.Do(Del(nil, "user:1", "$")))
+	assert.Equal(t, []string{"JSON.DEL", "user:1", "$"}, lastArgs())
+
+	// Set errors when v can't be marshaled
+	assert.Error(t, conn.Do(Set("user:1", "$", make(chan int))))
+}
+
+func TestPipeline(t *T) {
+	conn, gotArgs := moduletest.Stub(map[string][]interface{}{
+		"JSON.GET": {`[{"name":"alice","tags":null}]`},
+	})
+
+	var users []user
+	require.NoError(t, conn.Do(radix.Pipeline(
+		Set("user:1", "$", user{Name: "alice"}),
+		Get(&users, "user:1", "$"),
+	)))
+	assert.Equal(t, []user{{Name: "alice"}}, users)
+	assert.Len(t, *gotArgs, 2)
+}
+
+func TestModule(t *T) {
+	conn := moduletest.Dial(t, "ReJSON")
+	defer conn.Close()
+
+	key, missingKey := moduletest.RandStr(), moduletest.RandStr()
+	defer conn.Do(radix.Cmd(nil, "DEL", key))
+
+	require.NoError(t, conn.Do(Set(key, "$", user{Name: "alice", Tags: []string{}})))
+
+	var set bool
+	require.NoError(t, conn.Do(SetNX(&set, key, "$", user{Name: "bob"})))
+	assert.False(t, set)
+	require.NoError(t, conn.Do(SetXX(&set, key, "$.name", "carol")))
+	assert.True(t, set)
+	require.NoError(t, conn.Do(SetXX(&set, missingKey, "$", user{})))
+	assert.False(t, set)
+
+	var lengths []int
+	require.NoError(t, conn.Do(ArrAppend(&lengths, key, "$.tags", "admin", "ops")))
+	assert.Equal(t, []int{2}, lengths)
+	require.NoError(t, conn.Do(ArrAppend(&lengths, key, "$.name", "x")))
+	assert.Equal(t, []int{-1}, lengths)
+
+	var users []user
+	require.NoError(t, conn.Do(Get(&users, key, "$")))
+	assert.Equal(t, []user{{Name: "carol", Tags: []string{"admin", "ops"}}}, users)
+
+	names := []string{"untouched"}
+	require.NoError(t, conn.Do(Get(&names, missingKey, "$.name")))
+	assert.Equal(t, []string{"untouched"}, names)
+
+	var mget [][]string
+	require.NoError(t, conn.Do(MGet(&mget, "$.name", key, missingKey)))
+	assert.Equal(t, [][]string{{"carol"}, nil}, mget)
+
+	var nums []float64
+	require.NoError(t, conn.Do(Set(key, "$.age", 2.5)))
+	require.NoError(t, conn.Do(NumIncrBy(&nums, key, "$.age", 2.5)))
+	assert.Equal(t, []float64{5}, nums)
+
+	var n int
+	require.NoError(t, conn.Do(Del(&n, key, "")))
+	assert.Equal(t, 1, n)
+	require.NoError(t, conn.Do(Del(&n, key, "")))
+	assert.Equal(t, 0, n)
+}
@@ -0,0 +1,312 @@
+package search
+
+import (
+	"bufio"
+	"strconv"
+	"time"
+
+	errors "golang.org/x/xerrors"
+
+	"github.com/mediocregopher/radix/v3"
+	"github.com/mediocregopher/radix/v3/resp"
+	"github.com/mediocregopher/radix/v3/resp/resp2"
+)
+
+// AggregateStep is a single step of the pipeline of an aggregation, as created
+// by GroupBy, SortBy, Apply, Filter or Limit.
+type AggregateStep []string
+
+// Reducer describes a reducer function used by a GroupBy step, e.g.
+//
+//	Reducer{Func: "COUNT", As: "count"}
+//	Reducer{Func: "AVG", Args: []string{"@age"}, As: "avg_age"}
+//
+type Reducer struct {
+	Func string
+	Args []string
+
+	// As optionally names the reduced property in the rows of the result.
+	As string
+}
+
+// GroupBy returns an AggregateStep which groups the rows by the given
+// properties, e.g. "@tag", reducing each group with the given reducers.
+func GroupBy(props []string, reducers ...Reducer) AggregateStep {
+	step := AggregateStep{"GROUPBY", strconv.Itoa(len(props))}
+	step = append(step, props...)
+	for _, r := range reducers {
+		step = append(step, "REDUCE", r.Func, strconv.Itoa(len(r.Args)))
+		step = append(step, r.Args...)
+		if r.As != "" {
+			step = append(step, "AS", r.As)
+		}
+	}
+	return step
+}
+
+// SortBy returns an AggregateStep which sorts the rows by the given
+// properties, each of which may be followed by "ASC" or "DESC", e.g.
+//
+//	SortBy("@count", "DESC", "@tag")
+//
+func SortBy(props ...string) AggregateStep {
+	return append(AggregateStep{"SORTBY", strconv.Itoa(len(props))}, props...)
+}
+
+// Apply returns an AggregateStep which evaluates the given expression for each
+// row, storing the result in the property named as.
+func Apply(expr, as string) AggregateStep {
+	return AggregateStep{"APPLY", expr, "AS", as}
+}
+
+// Filter returns an AggregateStep which discards rows for which the given
+// expression isn't true.
+func Filter(expr string) AggregateStep {
+	return AggregateStep{"FILTER", expr}
+}
+
+// Limit returns an AggregateStep which discards all rows but num of them,
+// starting from offset.
+func Limit(offset, num int) AggregateStep {
+	return AggregateStep{"LIMIT", strconv.Itoa(offset), strconv.Itoa(num)}
+}
+
+// AggregateOpts are optional parameters which can be given to Aggregate.
+type AggregateOpts struct {
+	// Verbatim disables stemming of the query terms.
+	Verbatim bool
+
+	// Load loads the given fields of the documents into the rows, so they can
+	// be used by the steps. "*" loads all fields.
+	Load []string
+
+	// Steps is the pipeline of the aggregation, which is applied in order.
+	Steps []AggregateStep
+
+	// Params are substituted for the $-prefixed parameters used in the query.
+	// They require a Dialect of 2 or above.
+	Params map[string]string
+
+	// Dialect sets the query dialect, if greater than zero.
+	Dialect int
+
+	// Timeout overrides the server's timeout for the query, if greater than
+	// zero.
+	Timeout time.Duration
+
+	// WithCursor causes the rows to be returned in batches of CursorCount rows
+	// (or the server's default if zero), with AggregateResult.Cursor being set
+	// so further batches can be read using CursorRead. A cursor which isn't
+	// read for MaxIdle, if greater than zero, is deleted by the server.
+	WithCursor  bool
+	CursorCount int
+	MaxIdle     time.Duration
+}
+
+// Args returns the arguments of FT.AGGREGATE which describe the
+// AggregateOpts, i.e. those following the query.
+func (o AggregateOpts) Args() []string {
+	var args []string
+	if o.Verbatim {
+		args = append(args, "VERBATIM")
+	}
+	if len(o.Load) == 1 && o.Load[0] == "*" {
+		args = append(args, "LOAD", "*")
+	} else if len(o.Load) > 0 {
+		args = append(args, "LOAD", strconv.Itoa(len(o.Load)))
+		args = append(args, o.Load...)
+	}
+	if o.Timeout > 0 {
+		args = append(args, "TIMEOUT", strconv.FormatInt(int64(o.Timeout/time.Millisecond), 10))
+	}
+	for _, step := range o.Steps {
+		args = append(args, step...)
+	}
+	if o.WithCursor {
+		args = append(args, "WITHCURSOR")
+		if o.CursorCount > 0 {
+			args = append(args, "COUNT", strconv.Itoa(o.CursorCount))
+		}
+		if o.MaxIdle > 0 {
+			args = append(args, "MAXIDLE", strconv.FormatInt(int64(o.MaxIdle/time.Millisecond), 10))
+		}
+	}
+	args = append(args, paramsArgs(o.Params)...)
+	if o.Dialect > 0 {
+		args = append(args, "DIALECT", strconv.Itoa(o.Dialect))
+	}
+	return args
+}
+
+// AggregateResult is the result of an Aggregate or CursorRead.
+type AggregateResult struct {
+	// Total is the number of rows reported by the server. It isn't necessarily
+	// accurate when a cursor is used.
+	Total int64
+
+	// Rows contains each row of the result as a map of property names to their
+	// values. Values which aren't strings or numbers, e.g. those produced by
+	// the TOLIST reducer, can't be decoded into Rows; use radix.Cmd with a
+	// custom receiver to retrieve those.
+	Rows []map[string]string
+
+	// Cursor is the ID of the cursor to read the next batch of rows with,
+	// or 0 if there are no further rows or a cursor wasn't used.
+	Cursor int64
+}
+
+// aggregateReply unmarshals the reply to FT.AGGREGATE or FT.CURSOR READ. When
+// a cursor is used the reply is an array of the rows and the cursor ID.
+type aggregateReply struct {
+	res        *AggregateResult
+	withCursor bool
+}
+
+func (r aggregateReply) UnmarshalRESP(br *bufio.Reader) error {
+	var res AggregateResult
+	if r.withCursor {
+		var arrHead resp2.ArrayHeader
+		if err := arrHead.UnmarshalRESP(br); err != nil {
+			return err
+		} else if arrHead.N != 2 {
+			for i := 0; i < arrHead.N; i++ {
+				if err := (resp2.Any{}).UnmarshalRESP(br); err != nil {
+					return err
+				}
+			}
+			return resp.ErrDiscarded{
+				Err: errors.Errorf("malformed cursor reply with %d elements", arrHead.N),
+			}
+		}
+	}
+
+	var arrHead resp2.ArrayHeader
+	if err := arrHead.UnmarshalRESP(br); err != nil {
+		return err
+	}
+	for i := 0; i < arrHead.N; i++ {
+		var err error
+		if i == 0 {
+			err = (resp2.Any{I: &res.Total}).UnmarshalRESP(br)
+		} else {
+			var row map[string]string
+			err = (resp2.Any{I: &row}).UnmarshalRESP(br)
+			res.Rows = append(res.Rows, row)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if r.withCursor {
+		if err := (resp2.Any{I: &res.Cursor}).UnmarshalRESP(br); err != nil {
+			return err
+		}
+	}
+
+	if r.res != nil {
+		*r.res = res
+	}
+	return nil
+}
+
+// Aggregate returns an Action which performs FT.AGGREGATE on the given index,
+// writing the rows resulting from the query and the AggregateOpts' steps into
+// rcv.
+func Aggregate(rcv *AggregateResult, index, query string, opts AggregateOpts) radix.CmdAction {
+	args := append([]string{index, query}, opts.Args()...)
+	return radix.Cmd(aggregateReply{res: rcv, withCursor: opts.WithCursor}, "FT.AGGREGATE", args...)
+}
+
+// CursorRead returns an Action which performs FT.CURSOR READ, writing the next
+// batch of rows of the aggregation with the given cursor into rcv. If count is
+// greater than zero it overrides the batch size given to Aggregate.
+func CursorRead(rcv *AggregateResult, index string, cursor int64, count int) radix.CmdAction {
+	args := []string{"READ", index, strconv.FormatInt(cursor, 10)}
+	if count > 0 {
+		args = append(args, "COUNT", strconv.Itoa(count))
+	}
+	return radix.Cmd(aggregateReply{res: rcv, withCursor: true}, "FT.CURSOR", args...)
+}
+
+// CursorDel returns an Action which performs FT.CURSOR DEL, deleting the given
+// cursor before it has been read to completion.
+func CursorDel(index string, cursor int64) radix.CmdAction {
+	return radix.Cmd(nil, "FT.CURSOR", "DEL", index, strconv.FormatInt(cursor, 10))
+}
+
+// AggregateScanner is used to iterate through the rows of an aggregation,
+// reading them in batches using a cursor.
+//
+// Once created, repeatedly call Next() on it to fill the passed in map pointer
+// with the next row. Next will return false if there's no more rows to
+// retrieve or if an error occurred, at which point Close should be called to
+// retrieve any error.
+type AggregateScanner interface {
+	Next(*map[string]string) bool
+
+	// Close deletes the cursor if the rows weren't read to completion, and
+	// returns any error encountered.
+	Close() error
+}
+
+type aggregateScanner struct {
+	c     radix.Client
+	index string
+	query string
+	opts  AggregateOpts
+
+	started bool
+	res     AggregateResult
+	resIdx  int
+	err     error
+}
+
+// NewAggregateScanner creates a new AggregateScanner which will perform the
+// aggregation on the given index using a cursor, regardless of
+// opts.WithCursor.
+//
+// NOTE cursors are local to the redis instance which created them, so if c is
+// a *radix.Cluster this will not work correctly, use an individual node of the
+// Cluster instead.
+func NewAggregateScanner(c radix.Client, index, query string, opts AggregateOpts) AggregateScanner {
+	opts.WithCursor = true
+	return &aggregateScanner{
+		c:     c,
+		index: index,
+		query: query,
+		opts:  opts,
+	}
+}
+
+func (s *aggregateScanner) Next(row *map[string]string) bool {
+	for {
+		if s.err != nil {
+			return false
+		}
+
+		if s.resIdx < len(s.res.Rows) {
+			*row = s.res.Rows[s.resIdx]
+			s.resIdx++
+			return true
+		}
+
+		if !s.started {
+			s.started = true
+			s.err = s.c.Do(Aggregate(&s.res, s.index, s.query, s.opts))
+		} else if s.res.Cursor != 0 {
+			s.err = s.c.Do(CursorRead(&s.res, s.index, s.res.Cursor, 0))
+		} else {
+			return false
+		}
+		s.resIdx = 0
+	}
+}
+
+func (s *aggregateScanner) Close() error {
+	if s.err == nil && s.res.Cursor != 0 {
+		s.err = s.c.Do(CursorDel(s.index, s.res.Cursor))
+		s.res.Cursor = 0
+	}
+	return s.err
+}
@@ -0,0 +1,127 @@
+package search
+
+import (
+	"strconv"
+	. "testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mediocregopher/radix/v3/modules/internal/moduletest"
+)
+
+func TestAggregateOptsArgs(t *T) {
+	assert.Empty(t, AggregateOpts{}.Args())
+	assert.Equal(t, []string{"LOAD", "*"}, AggregateOpts{Load: []string{"*"}}.Args())
+	assert.Equal(t, []string{
+		"VERBATIM",
+		"LOAD", "2", "@name", "@age",
+		"TIMEOUT", "100",
+		"GROUPBY", "1", "@tag",
+		"REDUCE", "COUNT", "0", "AS", "count",
+		"REDUCE", "AVG", "1", "@age",
+		"APPLY", "@count*2", "AS", "double",
+		"FILTER", "@count>1",
+		"SORTBY", "2", "@count", "DESC",
+		"LIMIT", "0", "5",
+		"WITHCURSOR", "COUNT", "10", "MAXIDLE", "60000",
+		"PARAMS", "2", "a", "1",
+		"DIALECT", "3",
+	}, AggregateOpts{
+		Verbatim: true,
+		Load:     []string{"@name", "@age"},
+		Timeout:  100 * time.Millisecond,
+		Steps: []AggregateStep{
+			GroupBy([]string{"@tag"},
+				Reducer{Func: "COUNT", As: "count"},
+				Reducer{Func: "AVG", Args: []string{"@age"}},
+			),
+			Apply("@count*2", "double"),
+			Filter("@count>1"),
+			SortBy("@count", "DESC"),
+			Limit(0, 5),
+		},
+		WithCursor:  true,
+		CursorCount: 10,
+		MaxIdle:     time.Minute,
+		Params:      map[string]string{"a": "1"},
+		Dialect:     3,
+	}.Args())
+}
+
+// aggregateRows returns a canned FT.AGGREGATE reply containing rows with the
+// given values of "n", and total as the total number of rows.
+func aggregateRows(total int, ns ...int) []interface{} {
+	rows := []interface{}{int64(total)}
+	for _, n := range ns {
+		rows = append(rows, []string{"n", strconv.Itoa(n)})
+	}
+	return rows
+}
+
+func TestAggregate(t *T) {
+	conn, gotArgs := moduletest.Stub(map[string][]interface{}{
+		"FT.AGGREGATE": {
+			aggregateRows(2, 0, 1),
+			[]interface{}{aggregateRows(3, 0, 1), int64(99)},
+		},
+		"FT.CURSOR": {[]interface{}{aggregateRows(3, 2), int64(0)}},
+	})
+
+	var res AggregateResult
+	require.NoError(t, conn.Do(Aggregate(&res, "idx", "*", AggregateOpts{})))
+	assert.Equal(t, []string{"FT.AGGREGATE", "idx", "*"}, (*gotArgs)[0])
+	assert.Equal(t, AggregateResult{
+		Total: 2,
+		Rows:  []map[string]string{{"n": "0"}, {"n": "1"}},
+	}, res)
+
+	require.NoError(t, conn.Do(Aggregate(&res, "idx", "*", AggregateOpts{WithCursor: true})))
+	assert.Equal(t, []string{"FT.AGGREGATE", "idx", "*", "WITHCURSOR"}, (*gotArgs)[1])
+	assert.Equal(t, AggregateResult{
+		Total:  3,
+		Rows:   []map[string]string{{"n": "0"}, {"n": "1"}},
+		Cursor: 99,
+	}, res)
+
+	require.NoError(t, conn.Do(CursorRead(&res, "idx", res.Cursor, 5)))
+	assert.Equal(t, []string{"FT.CURSOR", "READ", "idx", "99", "COUNT", "5"}, (*gotArgs)[2])
+	assert.Equal(t, AggregateResult{
+		Total: 3,
+		Rows:  []map[string]string{{"n": "2"}},
+	}, res)
+}
+
+func TestAggregateScanner(t *T) {
+	conn, gotArgs := moduletest.Stub(map[string][]interface{}{
+		"FT.AGGREGATE": {[]interface{}{aggregateRows(5, 0, 1), int64(99)}},
+		"FT.CURSOR": {
+			[]interface{}{aggregateRows(5, 2, 3), int64(99)},
+			[]interface{}{aggregateRows(5, 4), int64(0)},
+		},
+	})
+	s := NewAggregateScanner(conn, "idx", "*", AggregateOpts{})
+
+	var rows []string
+	var row map[string]string
+	for s.Next(&row) {
+		rows = append(rows, row["n"])
+	}
+	require.NoError(t, s.Close())
+	assert.Equal(t, []string{"0", "1", "2", "3", "4"}, rows)
+	assert.Equal(t, [][]string{
+		{"FT.AGGREGATE", "idx", "*", "WITHCURSOR"},
+		{"FT.CURSOR", "READ", "idx", "99"},
+		{"FT.CURSOR", "READ", "idx", "99"},
+	}, *gotArgs)
+
+	// closing a scanner before it's done deletes its cursor
+	conn, gotArgs = moduletest.Stub(map[string][]interface{}{
+		"FT.AGGREGATE": {[]interface{}{aggregateRows(5, 0, 1), int64(99)}},
+	})
+	s = NewAggregateScanner(conn, "idx", "*", AggregateOpts{})
+	require.True(t, s.Next(&row))
+	require.NoError(t, s.Close())
+	assert.Equal(t, []string{"FT.CURSOR", "DEL", "idx", "99"}, (*gotArgs)[len(*gotArgs)-1])
+}
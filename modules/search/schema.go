@@ -0,0 +1,177 @@
+// Package search implements Actions for the commands of the RediSearch module,
+// which provides secondary indexing and full-text search over hashes and JSON
+// documents.
+//
+// An index is described by a Schema and created using Create:
+//
+//	err := client.Do(search.Create("idx:users", search.Schema{
+//		Prefixes: []string{"user:"},
+//		Fields: []search.Field{
+//			{Name: "name", Type: search.TextField, Sortable: true},
+//			{Name: "tags", Type: search.TagField},
+//			{Name: "age", Type: search.NumericField},
+//		},
+//	}))
+//
+// Search performs a query against the index, decoding the matching documents
+// into a SearchResult, and Aggregate performs an aggregation, decoding the rows
+// into an AggregateResult. Large aggregations can be paged through using a
+// cursor, which NewAggregateScanner takes care of.
+//
+// Only RESP2 replies are supported.
+package search
+
+import (
+	"sort"
+	"strconv"
+)
+
+// FieldType describes the type of a field in an index.
+type FieldType string
+
+// The types of field which can be indexed.
+const (
+	TextField    FieldType = "TEXT"
+	TagField     FieldType = "TAG"
+	NumericField FieldType = "NUMERIC"
+	GeoField     FieldType = "GEO"
+)
+
+// Field describes a single field of an index's Schema.
+type Field struct {
+	// Name is the name of the field in the hash, or the JSONPath of the field
+	// for an index on JSON documents.
+	Name string
+
+	// As optionally gives the field a different name within the index, which
+	// is then used in queries and replies. It's required for fields of an
+	// index on JSON documents if they're to be referred to in queries.
+	As string
+
+	Type FieldType
+
+	// Sortable allows results to be sorted by the field, and NoIndex causes it
+	// not to be searchable, which is useful in combination with Sortable.
+	Sortable bool
+	NoIndex  bool
+
+	// Weight and NoStem only apply to fields of type TextField. Weight is only
+	// used if it's greater than zero.
+	Weight float64
+	NoStem bool
+
+	// Separator and CaseSensitive only apply to fields of type TagField. The
+	// default separator is ",".
+	Separator     string
+	CaseSensitive bool
+}
+
+func (f Field) args() []string {
+	args := []string{f.Name}
+	if f.As != "" {
+		args = append(args, "AS", f.As)
+	}
+	args = append(args, string(f.Type))
+
+	switch f.Type {
+	case TextField:
+		if f.NoStem {
+			args = append(args, "NOSTEM")
+		}
+		if f.Weight > 0 {
+			args = append(args, "WEIGHT", strconv.FormatFloat(f.Weight, 'f', -1, 64))
+		}
+	case TagField:
+		if f.Separator != "" {
+			args = append(args, "SEPARATOR", f.Separator)
+		}
+		if f.CaseSensitive {
+			args = append(args, "CASESENSITIVE")
+		}
+	}
+
+	if f.Sortable {
+		args = append(args, "SORTABLE")
+	}
+	if f.NoIndex {
+		args = append(args, "NOINDEX")
+	}
+	return args
+}
+
+// Schema describes an index, and the documents and fields it covers. Only
+// Fields is required.
+type Schema struct {
+	// OnJSON indexes JSON documents, as stored by the RedisJSON module,
+	// rather than hashes.
+	OnJSON bool
+
+	// Prefixes limits the index to keys which start with one of the given
+	// prefixes. By default all keys are indexed.
+	Prefixes []string
+
+	// Filter is an optional expression which documents must match in order to
+	// be indexed, e.g. "@age>16".
+	Filter string
+
+	// Language is the default language of the documents, used for stemming.
+	Language string
+
+	// StopWords, if not nil, replaces the default list of stop words. An
+	// empty, non-nil, slice disables stop words.
+	StopWords []string
+
+	Fields []Field
+}
+
+// Args returns the arguments of FT.CREATE which describe the Schema, i.e. those
+// following the index name.
+func (s Schema) Args() []string {
+	var args []string
+	if s.OnJSON {
+		args = append(args, "ON", "JSON")
+	} else {
+		args = append(args, "ON", "HASH")
+	}
+
+	if len(s.Prefixes) > 0 {
+		args = append(args, "PREFIX", strconv.Itoa(len(s.Prefixes)))
+		args = append(args, s.Prefixes...)
+	}
+	if s.Filter != "" {
+		args = append(args, "FILTER", s.Filter)
+	}
+	if s.Language != "" {
+		args = append(args, "LANGUAGE", s.Language)
+	}
+	if s.StopWords != nil {
+		args = append(args, "STOPWORDS", strconv.Itoa(len(s.StopWords)))
+		args = append(args, s.StopWords...)
+	}
+
+	args = append(args, "SCHEMA")
+	for _, f := range s.Fields {
+		args = append(args, f.args()...)
+	}
+	return args
+}
+
+// paramsArgs returns the PARAMS arguments for the given parameters, sorted by
+// name so that commands are consistent.
+func paramsArgs(params map[string]string) []string {
+	if len(params) == 0 {
+		return nil
+	}
+
+	names := make([]string, 0, len(params))
+	for name := range params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	args := []string{"PARAMS", strconv.Itoa(len(params) * 2)}
+	for _, name := range names {
+		args = append(args, name, params[name])
+	}
+	return args
+}
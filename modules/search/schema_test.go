@@ -0,0 +1,38 @@
+package search
+
+import (
+	. "testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSchemaArgs(t *T) {
+	assert.Equal(t, []string{"ON", "HASH", "SCHEMA", "name", "TEXT"}, Schema{
+		Fields: []Field{{Name: "name", Type: TextField}},
+	}.Args())
+
+	assert.Equal(t, []string{
+		"ON", "JSON",
+		"PREFIX", "2", "user:", "member:",
+		"FILTER", "@age>16",
+		"LANGUAGE", "english",
+		"STOPWORDS", "0",
+		"SCHEMA",
+		"$.name", "AS", "name", "TEXT", "NOSTEM", "WEIGHT", "2.5", "SORTABLE",
+		"$.tags", "AS", "tags", "TAG", "SEPARATOR", ";", "CASESENSITIVE",
+		"$.age", "AS", "age", "NUMERIC", "SORTABLE", "NOINDEX",
+		"$.loc", "AS", "loc", "GEO",
+	}, Schema{
+		OnJSON:    true,
+		Prefixes:  []string{"user:", "member:"},
+		Filter:    "@age>16",
+		Language:  "english",
+		StopWords: []string{},
+		Fields: []Field{
+			{Name: "$.name", As: "name", Type: TextField, NoStem: true, Weight: 2.5, Sortable: true},
+			{Name: "$.tags", As: "tags", Type: TagField, Separator: ";", CaseSensitive: true},
+			{Name: "$.age", As: "age", Type: NumericField, Sortable: true, NoIndex: true},
+			{Name: "$.loc", As: "loc", Type: GeoField},
+		},
+	}.Args())
+}
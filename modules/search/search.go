@@ -0,0 +1,196 @@
+package search
+
+import (
+	"bufio"
+	"strconv"
+	"time"
+
+	errors "golang.org/x/xerrors"
+
+	"github.com/mediocregopher/radix/v3"
+	"github.com/mediocregopher/radix/v3/resp"
+	"github.com/mediocregopher/radix/v3/resp/resp2"
+)
+
+// Create returns an Action which performs FT.CREATE, creating an index with
+// the given name and Schema.
+func Create(index string, schema Schema) radix.CmdAction {
+	return radix.Cmd(nil, "FT.CREATE", append([]string{index}, schema.Args()...)...)
+}
+
+// DropIndex returns an Action which performs FT.DROPINDEX, deleting the given
+// index. If deleteDocs is true then the documents which were indexed are
+// deleted as well.
+func DropIndex(index string, deleteDocs bool) radix.CmdAction {
+	if deleteDocs {
+		return radix.Cmd(nil, "FT.DROPINDEX", index, "DD")
+	}
+	return radix.Cmd(nil, "FT.DROPINDEX", index)
+}
+
+// SearchOpts are optional parameters which can be given to Search.
+type SearchOpts struct {
+	// NoContent causes only the IDs of documents to be returned, not their
+	// fields.
+	NoContent bool
+
+	// Verbatim disables stemming of the query terms.
+	Verbatim bool
+
+	// WithScores causes the score of each document to be returned.
+	WithScores bool
+
+	// Return limits the fields returned for each document to those given.
+	Return []string
+
+	// SortBy sorts the documents by the given field, which must be sortable,
+	// in ascending order unless SortDesc is set.
+	SortBy   string
+	SortDesc bool
+
+	// Offset and Limit page through the matching documents. If neither is set
+	// then redis returns the first 10 documents.
+	Offset, Limit int
+
+	// Params are substituted for the $-prefixed parameters used in the query.
+	// They require a Dialect of 2 or above.
+	Params map[string]string
+
+	// Dialect sets the query dialect, if greater than zero.
+	Dialect int
+
+	// Language is the language of the query, used for stemming.
+	Language string
+
+	// Timeout overrides the server's timeout for the query, if greater than
+	// zero.
+	Timeout time.Duration
+}
+
+// Args returns the arguments of FT.SEARCH which describe the SearchOpts, i.e.
+// those following the query.
+func (o SearchOpts) Args() []string {
+	var args []string
+	if o.NoContent {
+		args = append(args, "NOCONTENT")
+	}
+	if o.Verbatim {
+		args = append(args, "VERBATIM")
+	}
+	if o.WithScores {
+		args = append(args, "WITHSCORES")
+	}
+	if len(o.Return) > 0 {
+		args = append(args, "RETURN", strconv.Itoa(len(o.Return)))
+		args = append(args, o.Return...)
+	}
+	if o.SortBy != "" {
+		args = append(args, "SORTBY", o.SortBy)
+		if o.SortDesc {
+			args = append(args, "DESC")
+		}
+	}
+	if o.Offset != 0 || o.Limit != 0 {
+		args = append(args, "LIMIT", strconv.Itoa(o.Offset), strconv.Itoa(o.Limit))
+	}
+	if o.Language != "" {
+		args = append(args, "LANGUAGE", o.Language)
+	}
+	if o.Timeout > 0 {
+		args = append(args, "TIMEOUT", strconv.FormatInt(int64(o.Timeout/time.Millisecond), 10))
+	}
+	args = append(args, paramsArgs(o.Params)...)
+	if o.Dialect > 0 {
+		args = append(args, "DIALECT", strconv.Itoa(o.Dialect))
+	}
+	return args
+}
+
+// Document is a single document returned by Search.
+type Document struct {
+	// ID is the key of the document.
+	ID string
+
+	// Score is only set if SearchOpts.WithScores was set.
+	Score float64
+
+	// Fields contains the returned fields of the document, unless
+	// SearchOpts.NoContent was set. For a JSON document the whole document is
+	// returned in the "$" field, unless SearchOpts.Return was set.
+	Fields map[string]string
+}
+
+// SearchResult is the result of a Search.
+type SearchResult struct {
+	// Total is the number of documents which matched the query, which may be
+	// more than the number of Documents returned.
+	Total int64
+
+	Documents []Document
+}
+
+// searchReply unmarshals the reply to FT.SEARCH, whose layout depends on the
+// SearchOpts given.
+type searchReply struct {
+	res  *SearchResult
+	opts SearchOpts
+}
+
+func (r searchReply) UnmarshalRESP(br *bufio.Reader) error {
+	var arrHead resp2.ArrayHeader
+	if err := arrHead.UnmarshalRESP(br); err != nil {
+		return err
+	}
+
+	perDoc := 1
+	if r.opts.WithScores {
+		perDoc++
+	}
+	if !r.opts.NoContent {
+		perDoc++
+	}
+	if arrHead.N < 1 || (arrHead.N-1)%perDoc != 0 {
+		for i := 0; i < arrHead.N; i++ {
+			if err := (resp2.Any{}).UnmarshalRESP(br); err != nil {
+				return err
+			}
+		}
+		return resp.ErrDiscarded{
+			Err: errors.Errorf("malformed FT.SEARCH reply with %d elements", arrHead.N),
+		}
+	}
+
+	var res SearchResult
+	if err := (resp2.Any{I: &res.Total}).UnmarshalRESP(br); err != nil {
+		return err
+	}
+
+	res.Documents = make([]Document, (arrHead.N-1)/perDoc)
+	for i := range res.Documents {
+		doc := &res.Documents[i]
+		dsts := []interface{}{&doc.ID}
+		if r.opts.WithScores {
+			dsts = append(dsts, &doc.Score)
+		}
+		if !r.opts.NoContent {
+			dsts = append(dsts, &doc.Fields)
+		}
+		for _, dst := range dsts {
+			if err := (resp2.Any{I: dst}).UnmarshalRESP(br); err != nil {
+				return err
+			}
+		}
+	}
+
+	if r.res != nil {
+		*r.res = res
+	}
+	return nil
+}
+
+// Search returns an Action which performs FT.SEARCH on the given index,
+// writing the documents matching the query into rcv.
+func Search(rcv *SearchResult, index, query string, opts SearchOpts) radix.CmdAction {
+	args := append([]string{index, query}, opts.Args()...)
+	return radix.Cmd(searchReply{res: rcv, opts: opts}, "FT.SEARCH", args...)
+}
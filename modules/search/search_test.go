@@ -0,0 +1,189 @@
+package search
+
+import (
+	"strconv"
+	. "testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	errors "golang.org/x/xerrors"
+
+	"github.com/mediocregopher/radix/v3"
+	"github.com/mediocregopher/radix/v3/modules/internal/moduletest"
+	"github.com/mediocregopher/radix/v3/resp"
+)
+
+func TestSearchOptsArgs(t *T) {
+	assert.Empty(t, SearchOpts{}.Args())
+	assert.Equal(t, []string{
+		"NOCONTENT", "VERBATIM", "WITHSCORES",
+		"RETURN", "2", "name", "age",
+		"SORTBY", "age", "DESC",
+		"LIMIT", "20", "10",
+		"LANGUAGE", "german",
+		"TIMEOUT", "500",
+		"PARAMS", "4", "a", "1", "b", "2",
+		"DIALECT", "2",
+	}, SearchOpts{
+		NoContent:  true,
+		Verbatim:   true,
+		WithScores: true,
+		Return:     []string{"name", "age"},
+		SortBy:     "age",
+		SortDesc:   true,
+		Offset:     20,
+		Limit:      10,
+		Language:   "german",
+		Timeout:    500 * time.Millisecond,
+		Params:     map[string]string{"b": "2", "a": "1"},
+		Dialect:    2,
+	}.Args())
+}
+
+// searchDocs returns a canned FT.SEARCH reply for the documents user:1 and
+// user:2, including their scores and fields if set.
+func searchDocs(withScores, withContent bool) []interface{} {
+	reply := []interface{}{int64(5)}
+	for _, id := range []string{"user:1", "user:2"} {
+		reply = append(reply, id)
+		if withScores {
+			reply = append(reply, "1.5")
+		}
+		if withContent {
+			reply = append(reply, []string{"name", id + "-name", "age", "30"})
+		}
+	}
+	return reply
+}
+
+func TestSearch(t *T) {
+	conn, gotArgs := moduletest.Stub(map[string][]interface{}{
+		"FT.SEARCH": {
+			searchDocs(false, true),
+			searchDocs(true, true),
+			searchDocs(true, false),
+			// this reply doesn't match the options it's read with
+			searchDocs(false, false),
+			searchDocs(false, false),
+		},
+	})
+	lastArgs := func() []string { return (*gotArgs)[len(*gotArgs)-1] }
+
+	require.NoError(t, conn.Do(Create("idx", Schema{
+		Fields: []Field{{Name: "name", Type: TextField}},
+	})))
+	assert.Equal(t, []string{"FT.CREATE", "idx", "ON", "HASH", "SCHEMA", "name", "TEXT"}, lastArgs())
+
+	var res SearchResult
+	require.NoError(t, conn.Do(Search(&res, "idx", "@name:foo", SearchOpts{})))
+	assert.Equal(t, []string{"FT.SEARCH", "idx", "@name:foo"}, lastArgs())
+	assert.Equal(t, SearchResult{
+		Total: 5,
+		Documents: []Document{
+			{ID: "user:1", Fields: map[string]string{"name": "user:1-name", "age": "30"}},
+			{ID: "user:2", Fields: map[string]string{"name": "user:2-name", "age": "30"}},
+		},
+	}, res)
+
+	require.NoError(t, conn.Do(Search(&res, "idx", "*", SearchOpts{WithScores: true})))
+	assert.Equal(t, []string{"FT.SEARCH", "idx", "*", "WITHSCORES"}, lastArgs())
+	assert.Equal(t, SearchResult{
+		Total: 5,
+		Documents: []Document{
+			{ID: "user:1", Score: 1.5, Fields: map[string]string{"name": "user:1-name", "age": "30"}},
+			{ID: "user:2", Score: 1.5, Fields: map[string]string{"name": "user:2-name", "age": "30"}},
+		},
+	}, res)
+
+	require.NoError(t, conn.Do(Search(&res, "idx", "*", SearchOpts{WithScores: true, NoContent: true})))
+	assert.Equal(t, SearchResult{
+		Total:     5,
+		Documents: []Document{{ID: "user:1", Score: 1.5}, {ID: "user:2", Score: 1.5}},
+	}, res)
+
+	// a reply which doesn't match the options is discarded, leaving the
+	// connection usable
+	err := conn.Do(Search(&res, "idx", "*", SearchOpts{WithScores: true}))
+	assert.True(t, errors.As(err, new(resp.ErrDiscarded)), "err:%v", err)
+	require.NoError(t, conn.Do(Search(&res, "idx", "*", SearchOpts{NoContent: true})))
+	assert.Equal(t, SearchResult{
+		Total:     5,
+		Documents: []Document{{ID: "user:1"}, {ID: "user:2"}},
+	}, res)
+
+	require.NoError(t, conn.Do(DropIndex("idx", true)))
+	assert.Equal(t, []string{"FT.DROPINDEX", "idx", "DD"}, lastArgs())
+}
+
+func TestModule(t *T) {
+	conn := moduletest.Dial(t, "search")
+	defer conn.Close()
+
+	index, prefix := moduletest.RandStr(), moduletest.RandStr()+":"
+	require.NoError(t, conn.Do(Create(index, Schema{
+		Prefixes: []string{prefix},
+		Fields: []Field{
+			{Name: "name", Type: TextField},
+			{Name: "tag", Type: TagField},
+			{Name: "age", Type: NumericField, Sortable: true},
+		},
+	})))
+	defer conn.Do(DropIndex(index, true))
+
+	for i, tag := range []string{"a", "a", "b", "a", "b"} {
+		require.NoError(t, conn.Do(radix.Cmd(nil, "HSET", prefix+strconv.Itoa(i),
+			"name", "user"+strconv.Itoa(i), "tag", tag, "age", strconv.Itoa(20+i))))
+	}
+
+	// the index is populated asynchronously
+	var res SearchResult
+	for i := 0; i < 100; i++ {
+		require.NoError(t, conn.Do(Search(&res, index, "*", SearchOpts{})))
+		if res.Total == 5 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	require.Equal(t, int64(5), res.Total)
+
+	require.NoError(t, conn.Do(Search(&res, index, "@tag:{b}", SearchOpts{
+		WithScores: true,
+		SortBy:     "age",
+		SortDesc:   true,
+		Return:     []string{"name"},
+	})))
+	require.Equal(t, int64(2), res.Total)
+	require.Len(t, res.Documents, 2)
+	assert.Equal(t, prefix+"4", res.Documents[0].ID)
+	assert.Equal(t, map[string]string{"name": "user4"}, res.Documents[0].Fields)
+	assert.Equal(t, prefix+"2", res.Documents[1].ID)
+
+	require.NoError(t, conn.Do(Search(&res, index, "@age:[0 21]", SearchOpts{NoContent: true})))
+	assert.Equal(t, int64(2), res.Total)
+	assert.ElementsMatch(t, []Document{{ID: prefix + "0"}, {ID: prefix + "1"}}, res.Documents)
+
+	var agg AggregateResult
+	require.NoError(t, conn.Do(Aggregate(&agg, index, "*", AggregateOpts{
+		Steps: []AggregateStep{
+			GroupBy([]string{"@tag"}, Reducer{Func: "COUNT", As: "count"}),
+			SortBy("@tag", "ASC"),
+		},
+	})))
+	assert.Equal(t, []map[string]string{
+		{"tag": "a", "count": "3"},
+		{"tag": "b", "count": "2"},
+	}, agg.Rows)
+
+	s := NewAggregateScanner(conn, index, "*", AggregateOpts{
+		Load:        []string{"@name"},
+		CursorCount: 2,
+	})
+	var names []string
+	var row map[string]string
+	for s.Next(&row) {
+		names = append(names, row["name"])
+	}
+	require.NoError(t, s.Close())
+	assert.ElementsMatch(t, []string{"user0", "user1", "user2", "user3", "user4"}, names)
+}
@@ -0,0 +1,263 @@
+package timeseries
+
+import (
+	"bufio"
+	"strconv"
+	"strings"
+	"time"
+
+	errors "golang.org/x/xerrors"
+
+	"github.com/mediocregopher/radix/v3"
+	"github.com/mediocregopher/radix/v3/resp"
+	"github.com/mediocregopher/radix/v3/resp/resp2"
+)
+
+// Aggregation describes how the samples of a series are aggregated into
+// buckets of time when reading them.
+type Aggregation struct {
+	// Type is the aggregation function, e.g. "avg", "sum", "min", "max",
+	// "count", "first" or "last".
+	Type string
+
+	// Bucket is the duration of each bucket.
+	Bucket time.Duration
+
+	// Align optionally aligns the buckets to the given time, rather than to
+	// the unix epoch.
+	Align time.Time
+
+	// Empty causes buckets without samples to be included in the result.
+	Empty bool
+}
+
+func (a Aggregation) args() []string {
+	var args []string
+	if !a.Align.IsZero() {
+		args = append(args, "ALIGN", timestampArg(a.Align))
+	}
+	args = append(args, "AGGREGATION", a.Type, durationArg(a.Bucket))
+	if a.Empty {
+		args = append(args, "EMPTY")
+	}
+	return args
+}
+
+// RangeOpts are optional parameters which can be given to Range and MRange.
+type RangeOpts struct {
+	// Reverse causes the samples to be returned newest first, using
+	// TS.REVRANGE or TS.MREVRANGE.
+	Reverse bool
+
+	// FilterByTS limits the samples to those with one of the given
+	// timestamps.
+	FilterByTS []time.Time
+
+	// FilterByValue, if not nil, limits the samples to those whose values are
+	// between the two given values (inclusive).
+	FilterByValue *[2]float64
+
+	// Count limits the number of samples, or buckets, returned for each
+	// series, if greater than zero.
+	Count int
+
+	// Aggregation, if not nil, aggregates the samples.
+	Aggregation *Aggregation
+}
+
+func (o RangeOpts) args() []string {
+	var args []string
+	if len(o.FilterByTS) > 0 {
+		args = append(args, "FILTER_BY_TS")
+		for _, ts := range o.FilterByTS {
+			args = append(args, timestampArg(ts))
+		}
+	}
+	if o.FilterByValue != nil {
+		args = append(args, "FILTER_BY_VALUE",
+			formatFloat(o.FilterByValue[0]), formatFloat(o.FilterByValue[1]))
+	}
+	if o.Count > 0 {
+		args = append(args, "COUNT", strconv.Itoa(o.Count))
+	}
+	if o.Aggregation != nil {
+		args = append(args, o.Aggregation.args()...)
+	}
+	return args
+}
+
+// rangeArgs returns the from and to arguments of a range command, using the
+// lowest and highest possible timestamps in place of zero times.
+func rangeArgs(from, to time.Time) []string {
+	args := []string{"-", "+"}
+	if !from.IsZero() {
+		args[0] = timestampArg(from)
+	}
+	if !to.IsZero() {
+		args[1] = timestampArg(to)
+	}
+	return args
+}
+
+// Range returns an Action which performs TS.RANGE (or TS.REVRANGE), writing the
+// samples of the series in the given key with timestamps between from and to
+// (inclusive) into rcv. A zero from or to leaves the range unbounded on that
+// side.
+func Range(rcv *[]Sample, key string, from, to time.Time, opts RangeOpts) radix.CmdAction {
+	cmd := "TS.RANGE"
+	if opts.Reverse {
+		cmd = "TS.REVRANGE"
+	}
+	args := append([]string{key}, rangeArgs(from, to)...)
+	args = append(args, opts.args()...)
+	return radix.Cmd(rcv, cmd, args...)
+}
+
+// Filter is a label filter used by MRange to select series.
+type Filter string
+
+// LabelEq returns a Filter matching series whose label has the given value.
+func LabelEq(label, value string) Filter {
+	return Filter(label + "=" + value)
+}
+
+// LabelNe returns a Filter matching series whose label doesn't have the given
+// value, including series without the label.
+func LabelNe(label, value string) Filter {
+	return Filter(label + "!=" + value)
+}
+
+// LabelIn returns a Filter matching series whose label has any of the given
+// values.
+func LabelIn(label string, values ...string) Filter {
+	return Filter(label + "=(" + strings.Join(values, ",") + ")")
+}
+
+// LabelNotIn returns a Filter matching series whose label has none of the
+// given values, including series without the label.
+func LabelNotIn(label string, values ...string) Filter {
+	return Filter(label + "!=(" + strings.Join(values, ",") + ")")
+}
+
+// LabelExists returns a Filter matching series which have the given label.
+func LabelExists(label string) Filter {
+	return Filter(label + "!=")
+}
+
+// LabelMissing returns a Filter matching series which don't have the given
+// label.
+func LabelMissing(label string) Filter {
+	return Filter(label + "=")
+}
+
+// MRangeOpts are optional parameters which can be given to MRange.
+type MRangeOpts struct {
+	RangeOpts
+
+	// WithLabels causes all labels of each series to be returned, while
+	// SelectedLabels causes only the given labels to be returned. Only one
+	// may be set.
+	WithLabels     bool
+	SelectedLabels []string
+}
+
+// Series is a single series returned by MRange.
+type Series struct {
+	Key string
+
+	// Labels is only set if MRangeOpts.WithLabels or SelectedLabels was set.
+	// Selected labels which the series doesn't have are given as an empty
+	// value.
+	Labels map[string]string
+
+	Samples []Sample
+}
+
+// UnmarshalRESP implements the resp.Unmarshaler interface.
+func (s *Series) UnmarshalRESP(br *bufio.Reader) error {
+	var arrHead resp2.ArrayHeader
+	if err := arrHead.UnmarshalRESP(br); err != nil {
+		return err
+	} else if arrHead.N != 3 {
+		for i := 0; i < arrHead.N; i++ {
+			if err := (resp2.Any{}).UnmarshalRESP(br); err != nil {
+				return err
+			}
+		}
+		return resp.ErrDiscarded{
+			Err: errors.Errorf("malformed series with %d elements", arrHead.N),
+		}
+	}
+
+	var series Series
+	if err := (resp2.Any{I: &series.Key}).UnmarshalRESP(br); err != nil {
+		return err
+	}
+
+	// labels are given as a list of name/value pairs, with the value being nil
+	// for selected labels which the series doesn't have.
+	var labels [][]string
+	if err := (resp2.Any{I: &labels}).UnmarshalRESP(br); err != nil {
+		return err
+	}
+	for _, l := range labels {
+		if len(l) != 2 {
+			// discard the samples so the reader isn't left in a broken state
+			if err := (resp2.Any{}).UnmarshalRESP(br); err != nil {
+				return err
+			}
+			return resp.ErrDiscarded{
+				Err: errors.Errorf("malformed label with %d elements", len(l)),
+			}
+		} else if series.Labels == nil {
+			series.Labels = make(map[string]string, len(labels))
+		}
+		series.Labels[l[0]] = l[1]
+	}
+
+	if err := (resp2.Any{I: &series.Samples}).UnmarshalRESP(br); err != nil {
+		return err
+	}
+	*s = series
+	return nil
+}
+
+// mrangeAction overrides the keys of the embedded CmdAction, since TS.MRANGE
+// doesn't have any.
+type mrangeAction struct {
+	radix.CmdAction
+}
+
+func (mrangeAction) Keys() []string {
+	return nil
+}
+
+// MRange returns an Action which performs TS.MRANGE (or TS.MREVRANGE), writing
+// the samples of every series matching all of the given filters, with
+// timestamps between from and to (inclusive), into rcv. At least one filter
+// must be given, and at least one of them must match series by a label's value,
+// e.g. using LabelEq or LabelIn. A zero from or to leaves the range unbounded
+// on that side.
+//
+// When used with a radix.Cluster the command is sent to a random node, and so
+// only the series on that node are returned.
+func MRange(rcv *[]Series, from, to time.Time, filters []Filter, opts MRangeOpts) radix.CmdAction {
+	cmd := "TS.MRANGE"
+	if opts.Reverse {
+		cmd = "TS.MREVRANGE"
+	}
+
+	args := rangeArgs(from, to)
+	args = append(args, opts.RangeOpts.args()...)
+	if opts.WithLabels {
+		args = append(args, "WITHLABELS")
+	} else if len(opts.SelectedLabels) > 0 {
+		args = append(args, "SELECTED_LABELS")
+		args = append(args, opts.SelectedLabels...)
+	}
+	args = append(args, "FILTER")
+	for _, f := range filters {
+		args = append(args, string(f))
+	}
+	return mrangeAction{radix.Cmd(rcv, cmd, args...)}
+}
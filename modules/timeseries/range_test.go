@@ -0,0 +1,157 @@
+package timeseries
+
+import (
+	. "testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	errors "golang.org/x/xerrors"
+
+	"github.com/mediocregopher/radix/v3"
+	"github.com/mediocregopher/radix/v3/modules/internal/moduletest"
+	"github.com/mediocregopher/radix/v3/resp"
+)
+
+func TestRange(t *T) {
+	samplesReply := []interface{}{
+		[]interface{}{int64(1700000000000), "1.5"},
+		[]interface{}{int64(1700000060000), "-2"},
+	}
+	conn, gotArgs := moduletest.Stub(map[string][]interface{}{
+		"TS.RANGE":    {samplesReply},
+		"TS.REVRANGE": {samplesReply},
+	})
+	lastArgs := func() []string { return (*gotArgs)[len(*gotArgs)-1] }
+
+	var samples []Sample
+	require.NoError(t, conn.Do(Range(&samples, "temp", time.Time{}, time.Time{}, RangeOpts{})))
+	assert.Equal(t, []string{"TS.RANGE", "temp", "-", "+"}, lastArgs())
+	require.Len(t, samples, 2)
+	assert.True(t, time.Unix(1700000000, 0).Equal(samples[0].Timestamp))
+	assert.Equal(t, 1.5, samples[0].Value)
+	assert.True(t, time.Unix(1700000060, 0).Equal(samples[1].Timestamp))
+	assert.Equal(t, float64(-2), samples[1].Value)
+
+	from, to := time.Unix(1700000000, 0), time.Unix(1700003600, 0)
+	require.NoError(t, conn.Do(Range(&samples, "temp", from, to, RangeOpts{
+		Reverse:       true,
+		FilterByTS:    []time.Time{from, to},
+		FilterByValue: &[2]float64{0, 10.5},
+		Count:         5,
+		Aggregation: &Aggregation{
+			Type:   "avg",
+			Bucket: time.Minute,
+			Align:  from,
+			Empty:  true,
+		},
+	})))
+	assert.Equal(t, []string{
+		"TS.REVRANGE", "temp", "1700000000000", "1700003600000",
+		"FILTER_BY_TS", "1700000000000", "1700003600000",
+		"FILTER_BY_VALUE", "0", "10.5",
+		"COUNT", "5",
+		"ALIGN", "1700000000000", "AGGREGATION", "avg", "60000", "EMPTY",
+	}, lastArgs())
+}
+
+func TestMRange(t *T) {
+	seriesReply := []interface{}{
+		[]interface{}{
+			"temp:kitchen",
+			[]interface{}{
+				[]interface{}{"room", "kitchen"},
+				// a selected label which the series doesn't have
+				[]interface{}{"floor", nil},
+			},
+			[]interface{}{
+				[]interface{}{int64(1700000000000), "21"},
+			},
+		},
+		[]interface{}{
+			"temp:hall",
+			[]interface{}{},
+			[]interface{}{},
+		},
+	}
+	conn, gotArgs := moduletest.Stub(map[string][]interface{}{
+		"TS.MRANGE":    {seriesReply},
+		"TS.MREVRANGE": {seriesReply},
+	})
+	lastArgs := func() []string { return (*gotArgs)[len(*gotArgs)-1] }
+
+	var series []Series
+	require.NoError(t, conn.Do(MRange(&series, time.Time{}, time.Unix(1700000000, 0),
+		[]Filter{
+			LabelEq("sensor", "temp"),
+			LabelNe("room", "attic"),
+			LabelIn("floor", "1", "2"),
+			LabelNotIn("building", "a", "b"),
+			LabelExists("room"),
+			LabelMissing("broken"),
+		},
+		MRangeOpts{
+			RangeOpts:      RangeOpts{Count: 10},
+			SelectedLabels: []string{"room", "floor"},
+		},
+	)))
+	assert.Equal(t, []string{
+		"TS.MRANGE", "-", "1700000000000", "COUNT", "10",
+		"SELECTED_LABELS", "room", "floor",
+		"FILTER", "sensor=temp", "room!=attic", "floor=(1,2)", "building!=(a,b)",
+		"room!=", "broken=",
+	}, lastArgs())
+
+	require.Len(t, series, 2)
+	assert.Equal(t, "temp:kitchen", series[0].Key)
+	assert.Equal(t, map[string]string{"room": "kitchen", "floor": ""}, series[0].Labels)
+	require.Len(t, series[0].Samples, 1)
+	assert.Equal(t, float64(21), series[0].Samples[0].Value)
+	assert.Equal(t, Series{Key: "temp:hall", Samples: []Sample{}}, series[1])
+
+	require.NoError(t, conn.Do(MRange(&series, time.Time{}, time.Time{},
+		[]Filter{LabelEq("sensor", "temp")},
+		MRangeOpts{RangeOpts: RangeOpts{Reverse: true}, WithLabels: true},
+	)))
+	assert.Equal(t, []string{"TS.MREVRANGE", "-", "+", "WITHLABELS", "FILTER", "sensor=temp"}, lastArgs())
+	assert.Nil(t, MRange(nil, time.Time{}, time.Time{}, nil, MRangeOpts{}).Keys())
+}
+
+func TestMRangeMalformed(t *T) {
+	okSeries := []interface{}{"temp:hall", []interface{}{}, []interface{}{}}
+	conn, _ := moduletest.Stub(map[string][]interface{}{
+		"TS.MRANGE": {[]interface{}{
+			[]interface{}{"temp:kitchen", []interface{}{}},
+			okSeries,
+		}},
+		"TS.MREVRANGE": {[]interface{}{
+			[]interface{}{
+				"temp:kitchen",
+				[]interface{}{[]interface{}{"room"}},
+				[]interface{}{[]interface{}{int64(1700000000000), "21"}},
+			},
+			okSeries,
+		}},
+		"TS.RANGE": {[]interface{}{
+			[]interface{}{int64(1700000000000)},
+		}},
+	})
+
+	// the whole of each malformed element is discarded, so that the rest of
+	// the reply is still read and the Conn remains usable
+	for _, a := range []radix.Action{
+		MRange(new([]Series), time.Time{}, time.Time{},
+			[]Filter{LabelEq("sensor", "temp")}, MRangeOpts{}),
+		MRange(new([]Series), time.Time{}, time.Time{},
+			[]Filter{LabelEq("sensor", "temp")},
+			MRangeOpts{RangeOpts: RangeOpts{Reverse: true}}),
+		Range(new([]Sample), "temp", time.Time{}, time.Time{}, RangeOpts{}),
+	} {
+		err := conn.Do(a)
+		assert.True(t, errors.As(err, new(resp.ErrDiscarded)), "err:%v", err)
+
+		var res string
+		require.NoError(t, conn.Do(radix.Cmd(&res, "PING")))
+		assert.Equal(t, "OK", res)
+	}
+}
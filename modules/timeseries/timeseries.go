@@ -0,0 +1,240 @@
+// Package timeseries implements Actions for the commands of the RedisTimeSeries
+// module, which stores series of timestamped numeric samples in redis keys.
+//
+// Series are created using Create, or implicitly when a sample is first added
+// to them, and are read using Range and MRange:
+//
+//	err := client.Do(timeseries.Create("temp:kitchen", timeseries.CreateOpts{
+//		Retention: 24 * time.Hour,
+//		Labels:    map[string]string{"sensor": "temp", "room": "kitchen"},
+//	}))
+//
+//	err = client.Do(timeseries.Add("temp:kitchen", timeseries.Sample{
+//		Timestamp: time.Now(),
+//		Value:     21.5,
+//	}))
+//
+//	var samples []timeseries.Sample
+//	err = client.Do(timeseries.Range(&samples, "temp:kitchen", time.Time{}, time.Time{},
+//		timeseries.RangeOpts{
+//			Aggregation: &timeseries.Aggregation{Type: "avg", Bucket: time.Hour},
+//		},
+//	))
+//
+// Timestamps are sent to and read from redis as milliseconds since the unix
+// epoch.
+//
+// Only RESP2 replies are supported.
+package timeseries
+
+import (
+	"bufio"
+	"sort"
+	"strconv"
+	"time"
+
+	errors "golang.org/x/xerrors"
+
+	"github.com/mediocregopher/radix/v3"
+	"github.com/mediocregopher/radix/v3/resp"
+	"github.com/mediocregopher/radix/v3/resp/resp2"
+)
+
+func timestampArg(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano()/int64(time.Millisecond), 10)
+}
+
+func durationArg(d time.Duration) string {
+	return strconv.FormatInt(int64(d/time.Millisecond), 10)
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// CreateOpts are optional parameters used when creating a series, either by
+// Create or by AddWithOpts.
+type CreateOpts struct {
+	// Retention is the maximum age of samples, relative to the latest sample,
+	// after which they're deleted. If zero the server's default is used.
+	Retention time.Duration
+
+	// Encoding is the encoding of the series' chunks, "COMPRESSED" or
+	// "UNCOMPRESSED". If empty the server's default is used.
+	Encoding string
+
+	// ChunkSize is the size of each of the series' chunks in bytes, if
+	// greater than zero.
+	ChunkSize int
+
+	// DuplicatePolicy decides what happens when a sample is added with the
+	// same timestamp as an existing sample, e.g. "BLOCK", "LAST" or "SUM". If
+	// empty the server's default is used.
+	DuplicatePolicy string
+
+	// Labels are name/value pairs which describe the series, and by which it
+	// can be found by MRange.
+	Labels map[string]string
+}
+
+// Args returns the arguments of TS.CREATE which describe the CreateOpts, i.e.
+// those following the key.
+func (o CreateOpts) Args() []string {
+	return o.args("DUPLICATE_POLICY")
+}
+
+// args returns the arguments describing the CreateOpts, with the given name of
+// the duplicate policy option, which differs between TS.CREATE and TS.ADD.
+func (o CreateOpts) args(duplicatePolicyOpt string) []string {
+	var args []string
+	if o.Retention > 0 {
+		args = append(args, "RETENTION", durationArg(o.Retention))
+	}
+	if o.Encoding != "" {
+		args = append(args, "ENCODING", o.Encoding)
+	}
+	if o.ChunkSize > 0 {
+		args = append(args, "CHUNK_SIZE", strconv.Itoa(o.ChunkSize))
+	}
+	if o.DuplicatePolicy != "" {
+		args = append(args, duplicatePolicyOpt, o.DuplicatePolicy)
+	}
+	if len(o.Labels) > 0 {
+		// labels are sorted so that commands are consistent
+		names := make([]string, 0, len(o.Labels))
+		for name := range o.Labels {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		args = append(args, "LABELS")
+		for _, name := range names {
+			args = append(args, name, o.Labels[name])
+		}
+	}
+	return args
+}
+
+// Create returns an Action which performs TS.CREATE, creating a series in the
+// given key.
+func Create(key string, opts CreateOpts) radix.CmdAction {
+	return radix.Cmd(nil, "TS.CREATE", append([]string{key}, opts.Args()...)...)
+}
+
+// Sample is a single timestamped value of a series.
+type Sample struct {
+	// Timestamp is rounded down to the millisecond. When adding a sample a
+	// zero Timestamp causes the server's current time to be used.
+	Timestamp time.Time
+	Value     float64
+}
+
+func (s Sample) args() []string {
+	ts := "*"
+	if !s.Timestamp.IsZero() {
+		ts = timestampArg(s.Timestamp)
+	}
+	return []string{ts, formatFloat(s.Value)}
+}
+
+// UnmarshalRESP implements the resp.Unmarshaler interface.
+func (s *Sample) UnmarshalRESP(br *bufio.Reader) error {
+	var arrHead resp2.ArrayHeader
+	if err := arrHead.UnmarshalRESP(br); err != nil {
+		return err
+	} else if arrHead.N != 2 {
+		for i := 0; i < arrHead.N; i++ {
+			if err := (resp2.Any{}).UnmarshalRESP(br); err != nil {
+				return err
+			}
+		}
+		return resp.ErrDiscarded{
+			Err: errors.Errorf("malformed sample with %d elements", arrHead.N),
+		}
+	}
+
+	var ts int64
+	var sample Sample
+	if err := (resp2.Any{I: &ts}).UnmarshalRESP(br); err != nil {
+		return err
+	} else if err := (resp2.Any{I: &sample.Value}).UnmarshalRESP(br); err != nil {
+		return err
+	}
+	sample.Timestamp = time.Unix(0, ts*int64(time.Millisecond))
+	*s = sample
+	return nil
+}
+
+// Add returns an Action which performs TS.ADD, adding the sample to the series
+// in the given key. The series is created with the server's default options if
+// it doesn't exist.
+func Add(key string, sample Sample) radix.CmdAction {
+	return AddWithOpts(key, sample, CreateOpts{})
+}
+
+// AddWithOpts is like Add, but the series is created with the given options if
+// it doesn't exist. If it does exist then opts.DuplicatePolicy, if set,
+// overrides the series' duplicate policy for this sample.
+func AddWithOpts(key string, sample Sample, opts CreateOpts) radix.CmdAction {
+	return addCmd(key, sample, opts.args("ON_DUPLICATE"))
+}
+
+func addCmd(key string, sample Sample, optArgs []string) radix.CmdAction {
+	args := make([]string, 0, 3+len(optArgs))
+	args = append(args, key)
+	args = append(args, sample.args()...)
+	args = append(args, optArgs...)
+	return radix.Cmd(nil, "TS.ADD", args...)
+}
+
+// KeySample is a Sample of the series in Key.
+type KeySample struct {
+	Key string
+	Sample
+}
+
+// maddAction overrides the keys of the embedded CmdAction, since only every
+// third argument of a TS.MADD is a key.
+type maddAction struct {
+	radix.CmdAction
+	keys []string
+}
+
+func (a maddAction) Keys() []string {
+	return a.keys
+}
+
+// MAdd returns an Action which performs TS.MADD, adding each sample to its
+// series in a single command. Unlike Add the series must already exist.
+//
+// When used with a radix.Cluster all of the keys must belong to the same slot;
+// BulkAdd has no such restriction.
+func MAdd(samples ...KeySample) radix.CmdAction {
+	keys := make([]string, 0, len(samples))
+	args := make([]string, 0, len(samples)*3)
+	for _, s := range samples {
+		keys = append(keys, s.Key)
+		args = append(args, s.Key)
+		args = append(args, s.args()...)
+	}
+	return maddAction{
+		CmdAction: radix.Cmd(nil, "TS.MADD", args...),
+		keys:      keys,
+	}
+}
+
+// BulkAdd returns an Action which adds each sample to its series using a
+// separate TS.ADD, all of which are sent in a single radix.Pipeline. This is
+// the most efficient way to ingest many samples spread over many series, and
+// unlike MAdd the series may belong to any slot when used with a
+// radix.Cluster, with the TS.ADDs being split up by node.
+//
+// The series are created with the given options if they don't exist.
+func BulkAdd(opts CreateOpts, samples ...KeySample) radix.Action {
+	optArgs := opts.args("ON_DUPLICATE")
+	cmds := make([]radix.CmdAction, len(samples))
+	for i, s := range samples {
+		cmds[i] = addCmd(s.Key, s.Sample, optArgs)
+	}
+	return radix.Pipeline(cmds...)
+}
@@ -0,0 +1,112 @@
+package timeseries
+
+import (
+	. "testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mediocregopher/radix/v3"
+	"github.com/mediocregopher/radix/v3/modules/internal/moduletest"
+)
+
+func TestCreateAndAdd(t *T) {
+	conn, gotArgs := moduletest.Stub(nil)
+	opts := CreateOpts{
+		Retention:       time.Hour,
+		Encoding:        "COMPRESSED",
+		ChunkSize:       128,
+		DuplicatePolicy: "LAST",
+		Labels:          map[string]string{"sensor": "temp", "room": "kitchen"},
+	}
+	ts := time.Unix(1700000000, 123456789)
+
+	require.NoError(t, conn.Do(Create("temp", opts)))
+	require.NoError(t, conn.Do(Add("temp", Sample{Timestamp: ts, Value: 21.5})))
+	require.NoError(t, conn.Do(Add("temp", Sample{Value: 3})))
+	require.NoError(t, conn.Do(AddWithOpts("temp", Sample{Timestamp: ts, Value: -1}, opts)))
+	require.NoError(t, conn.Do(MAdd(
+		KeySample{Key: "a", Sample: Sample{Timestamp: ts, Value: 1}},
+		KeySample{Key: "b", Sample: Sample{Value: 2}},
+	)))
+
+	assert.Equal(t, [][]string{
+		{
+			"TS.CREATE", "temp", "RETENTION", "3600000", "ENCODING", "COMPRESSED",
+			"CHUNK_SIZE", "128", "DUPLICATE_POLICY", "LAST",
+			"LABELS", "room", "kitchen", "sensor", "temp",
+		},
+		{"TS.ADD", "temp", "1700000000123", "21.5"},
+		{"TS.ADD", "temp", "*", "3"},
+		{
+			"TS.ADD", "temp", "1700000000123", "-1", "RETENTION", "3600000",
+			"ENCODING", "COMPRESSED", "CHUNK_SIZE", "128", "ON_DUPLICATE", "LAST",
+			"LABELS", "room", "kitchen", "sensor", "temp",
+		},
+		{"TS.MADD", "a", "1700000000123", "1", "b", "*", "2"},
+	}, *gotArgs)
+
+	assert.Equal(t, []string{"a", "b"}, MAdd(
+		KeySample{Key: "a"}, KeySample{Key: "b"},
+	).Keys())
+}
+
+func TestBulkAdd(t *T) {
+	conn, gotArgs := moduletest.Stub(nil)
+	ts := time.Unix(1700000000, 0)
+	require.NoError(t, conn.Do(BulkAdd(CreateOpts{Retention: time.Minute},
+		KeySample{Key: "a", Sample: Sample{Timestamp: ts, Value: 1}},
+		KeySample{Key: "b", Sample: Sample{Timestamp: ts, Value: 2}},
+	)))
+	assert.Equal(t, [][]string{
+		{"TS.ADD", "a", "1700000000000", "1", "RETENTION", "60000"},
+		{"TS.ADD", "b", "1700000000000", "2", "RETENTION", "60000"},
+	}, *gotArgs)
+}
+
+func TestModule(t *T) {
+	conn := moduletest.Dial(t, "timeseries")
+	defer conn.Close()
+
+	label := moduletest.RandStr()
+	kitchen, hall := moduletest.RandStr(), moduletest.RandStr()
+	defer conn.Do(radix.Cmd(nil, "DEL", kitchen, hall))
+
+	require.NoError(t, conn.Do(Create(kitchen, CreateOpts{
+		Labels: map[string]string{"sensor": label, "room": "kitchen"},
+	})))
+	require.NoError(t, conn.Do(Create(hall, CreateOpts{
+		Labels: map[string]string{"sensor": label},
+	})))
+
+	ts := time.Unix(1700000000, 0)
+	require.NoError(t, conn.Do(MAdd(
+		KeySample{Key: kitchen, Sample: Sample{Timestamp: ts, Value: 21.5}},
+		KeySample{Key: kitchen, Sample: Sample{Timestamp: ts.Add(time.Minute), Value: 22}},
+		KeySample{Key: hall, Sample: Sample{Timestamp: ts, Value: 18}},
+	)))
+
+	var samples []Sample
+	require.NoError(t, conn.Do(Range(&samples, kitchen, time.Time{}, time.Time{}, RangeOpts{Reverse: true})))
+	require.Len(t, samples, 2)
+	assert.True(t, ts.Add(time.Minute).Equal(samples[0].Timestamp))
+	assert.Equal(t, float64(22), samples[0].Value)
+	assert.Equal(t, 21.5, samples[1].Value)
+
+	var series []Series
+	require.NoError(t, conn.Do(MRange(&series, time.Time{}, time.Time{},
+		[]Filter{LabelEq("sensor", label)},
+		MRangeOpts{SelectedLabels: []string{"room"}},
+	)))
+	require.Len(t, series, 2)
+	byKey := map[string]Series{}
+	for _, s := range series {
+		byKey[s.Key] = s
+	}
+	assert.Equal(t, map[string]string{"room": "kitchen"}, byKey[kitchen].Labels)
+	assert.Len(t, byKey[kitchen].Samples, 2)
+	// the hall series doesn't have the selected label
+	assert.Equal(t, map[string]string{"room": ""}, byKey[hall].Labels)
+	assert.Len(t, byKey[hall].Samples, 1)
+}
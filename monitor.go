@@ -0,0 +1,179 @@
+package radix
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	errors "golang.org/x/xerrors"
+
+	"github.com/mediocregopher/radix/v3/resp/resp2"
+)
+
+// MonitorEntry describes a single command which was processed by a redis
+// instance, as reported by the MONITOR command.
+type MonitorEntry struct {
+	// Time is when the command was processed.
+	Time time.Time
+
+	// DB is the database the command was performed on.
+	DB int
+
+	// ClientAddr is the address of the client which performed the command. It
+	// will be "lua" for commands performed by scripts, and prefixed with
+	// "unix:" for clients connected over a unix socket.
+	ClientAddr string
+
+	// Args is the command and all of its arguments, e.g. ["SET", "foo", "bar"].
+	Args []string
+}
+
+// parseMonitorLine parses a single line returned by MONITOR, e.g.:
+//
+//	1339518083.107412 [0 127.0.0.1:60866] "keys" "*"
+//
+func parseMonitorLine(line string) (MonitorEntry, error) {
+	malformed := func(reason string) error {
+		return errors.Errorf("malformed MONITOR line %q: %s", line, reason)
+	}
+
+	i := strings.Index(line, " [")
+	if i < 0 {
+		return MonitorEntry{}, malformed("no client info")
+	}
+	tsStr, rest := line[:i], line[i+2:]
+
+	var sec, usec int64
+	var err error
+	secStr, usecStr := tsStr, ""
+	if j := strings.IndexByte(tsStr, '.'); j >= 0 {
+		secStr, usecStr = tsStr[:j], tsStr[j+1:]
+	}
+	if sec, err = strconv.ParseInt(secStr, 10, 64); err != nil {
+		return MonitorEntry{}, malformed("invalid timestamp")
+	} else if usecStr != "" {
+		if usec, err = strconv.ParseInt(usecStr, 10, 64); err != nil {
+			return MonitorEntry{}, malformed("invalid timestamp")
+		}
+	}
+
+	// the client address may itself contain brackets, if it's an IPv6
+	// address, but will never be followed by a space.
+	i = strings.Index(rest, "] ")
+	if i < 0 {
+		return MonitorEntry{}, malformed("no arguments")
+	}
+	clientInfo, argsStr := rest[:i], rest[i+2:]
+
+	i = strings.IndexByte(clientInfo, ' ')
+	if i < 0 {
+		return MonitorEntry{}, malformed("no client address")
+	}
+	db, err := strconv.Atoi(clientInfo[:i])
+	if err != nil {
+		return MonitorEntry{}, malformed("invalid db")
+	}
+
+	args, err := parseMonitorArgs(argsStr)
+	if err != nil {
+		return MonitorEntry{}, malformed(err.Error())
+	}
+
+	return MonitorEntry{
+		Time:       time.Unix(sec, usec*1000),
+		DB:         db,
+		ClientAddr: clientInfo[i+1:],
+		Args:       args,
+	}, nil
+}
+
+// parseMonitorArgs parses a space separated list of quoted arguments, as
+// escaped by redis. The escape sequences redis uses are a subset of Go's, so
+// strconv.Unquote can be used on each.
+func parseMonitorArgs(s string) ([]string, error) {
+	var args []string
+	for len(s) > 0 {
+		if s[0] != '"' {
+			return nil, errors.New("unquoted argument")
+		}
+
+		end := -1
+		for i := 1; i < len(s); i++ {
+			if s[i] == '\\' {
+				i++
+			} else if s[i] == '"' {
+				end = i
+				break
+			}
+		}
+		if end < 0 {
+			return nil, errors.New("unterminated argument")
+		}
+
+		arg, err := strconv.Unquote(s[:end+1])
+		if err != nil {
+			return nil, errors.Errorf("invalid argument %s: %w", s[:end+1], err)
+		}
+		args = append(args, arg)
+		s = strings.TrimPrefix(s[end+1:], " ")
+	}
+	return args, nil
+}
+
+// Monitor issues the MONITOR command on the given Conn, and writes every
+// command subsequently processed by the redis instance to the returned channel
+// as a MonitorEntry. Lines which can't be parsed are skipped.
+//
+// The returned function stops monitoring and closes the Conn, after which the
+// channel is closed. The channel is also closed if MONITOR fails or the Conn
+// encounters an error, such as being disconnected. The Conn should not be used
+// for anything else once passed to Monitor.
+//
+// NOTE that MONITOR has a significant performance cost on the redis instance,
+// and is intended for debugging.
+func Monitor(conn Conn) (<-chan MonitorEntry, func()) {
+	ch := make(chan MonitorEntry)
+	closeCh := make(chan struct{})
+	var wg sync.WaitGroup
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer close(ch)
+		// the Conn may go a long time without receiving anything, which
+		// shouldn't be considered a timeout
+		defer suspendReadTimeout(conn)()
+		if err := conn.Do(Cmd(nil, "MONITOR")); err != nil {
+			return
+		}
+
+		for {
+			var line resp2.SimpleString
+			if err := conn.Decode(&line); err != nil {
+				return
+			}
+
+			entry, err := parseMonitorLine(line.S)
+			if err != nil {
+				continue
+			}
+
+			select {
+			case ch <- entry:
+			case <-closeCh:
+				return
+			}
+		}
+	}()
+
+	var stopOnce sync.Once
+	stop := func() {
+		stopOnce.Do(func() {
+			close(closeCh)
+			conn.Close()
+			wg.Wait()
+		})
+	}
+	return ch, stop
+}
@@ -0,0 +1,130 @@
+package radix
+
+import (
+	"bufio"
+	"net"
+	. "testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseMonitorLine(t *T) {
+	for _, test := range []struct {
+		line string
+		exp  MonitorEntry
+		err  bool
+	}{
+		{
+			line: `1339518083.107412 [0 127.0.0.1:60866] "keys" "*"`,
+			exp: MonitorEntry{
+				Time:       time.Unix(1339518083, 107412000),
+				ClientAddr: "127.0.0.1:60866",
+				Args:       []string{"keys", "*"},
+			},
+		},
+		{
+			line: `1339518087.877697 [3 lua] "set" "foo" "bar \"baz\"\n\x00"`,
+			exp: MonitorEntry{
+				Time:       time.Unix(1339518087, 877697000),
+				DB:         3,
+				ClientAddr: "lua",
+				Args:       []string{"set", "foo", "bar \"baz\"\n\x00"},
+			},
+		},
+		{
+			line: `1339518090.000001 [0 [::1]:60866] "get" "a] b"`,
+			exp: MonitorEntry{
+				Time:       time.Unix(1339518090, 1000),
+				ClientAddr: "[::1]:60866",
+				Args:       []string{"get", "a] b"},
+			},
+		},
+		{
+			line: `1339518090.000001 [0 unix:/tmp/redis.sock] "ping"`,
+			exp: MonitorEntry{
+				Time:       time.Unix(1339518090, 1000),
+				ClientAddr: "unix:/tmp/redis.sock",
+				Args:       []string{"ping"},
+			},
+		},
+		{line: "OK", err: true},
+		{line: `foo [0 127.0.0.1:1] "ping"`, err: true},
+		{line: `1339518090.000001 [x 127.0.0.1:1] "ping"`, err: true},
+		{line: `1339518090.000001 [0 127.0.0.1:1] "ping`, err: true},
+		{line: `1339518090.000001 [0 127.0.0.1:1] ping`, err: true},
+	} {
+		entry, err := parseMonitorLine(test.line)
+		if test.err {
+			assert.Error(t, err, "line:%q", test.line)
+			continue
+		}
+		require.Nil(t, err, "line:%q", test.line)
+		assert.Equal(t, test.exp, entry, "line:%q", test.line)
+	}
+}
+
+func TestMonitor(t *T) {
+	client, server := net.Pipe()
+	go func() {
+		br := bufio.NewReader(server)
+		// read the MONITOR command, which is an array of one bulk string
+		for i := 0; i < 3; i++ {
+			if _, err := br.ReadString('\n'); err != nil {
+				return
+			}
+		}
+		server.Write([]byte("+OK\r\n" +
+			"+1339518083.107412 [0 127.0.0.1:60866] \"get\" \"foo\"\r\n" +
+			"+not a monitor line\r\n" +
+			"+1339518083.107413 [1 127.0.0.1:60866] \"del\" \"bar\"\r\n"))
+	}()
+
+	ch, stop := Monitor(NewConn(client))
+	assert.Equal(t, []string{"get", "foo"}, (<-ch).Args)
+	entry := <-ch
+	assert.Equal(t, 1, entry.DB)
+	assert.Equal(t, []string{"del", "bar"}, entry.Args)
+
+	stop()
+	_, ok := <-ch
+	assert.False(t, ok)
+	stop()
+}
+
+func TestMonitorReadTimeout(t *T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	defer l.Close()
+
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		br := bufio.NewReader(conn)
+		for i := 0; i < 3; i++ {
+			if _, err := br.ReadString('\n'); err != nil {
+				return
+			}
+		}
+		conn.Write([]byte("+OK\r\n"))
+
+		// nothing happens on the redis instance for longer than the read
+		// timeout, which shouldn't cause the monitoring Conn to be closed
+		time.Sleep(300 * time.Millisecond)
+		conn.Write([]byte("+1339518083.107412 [0 127.0.0.1:60866] \"get\" \"foo\"\r\n"))
+		br.ReadByte() // wait for the Conn to be closed
+	}()
+
+	conn, err := Dial("tcp", l.Addr().String(), DialReadTimeout(100*time.Millisecond))
+	require.Nil(t, err)
+	ch, stop := Monitor(conn)
+	defer stop()
+
+	entry, ok := <-ch
+	require.True(t, ok)
+	assert.Equal(t, []string{"get", "foo"}, entry.Args)
+}
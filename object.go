@@ -0,0 +1,247 @@
+package radix
+
+import (
+	"math/rand"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mediocregopher/radix/v3/resp/resp2"
+)
+
+// ObjectEncodingCmd returns a CmdAction which performs OBJECT ENCODING on the
+// given key, writing the internal encoding of its value, e.g. "listpack" or
+// "hashtable", into rcv.
+func ObjectEncodingCmd(rcv *string, key string) CmdAction {
+	return NewModuleCmd("OBJECT").Arg("ENCODING").Key(key).Cmd(rcv)
+}
+
+// ObjectFreqCmd returns a CmdAction which performs OBJECT FREQ on the given
+// key, writing its logarithmic access frequency counter into rcv. This only
+// works when the maxmemory-policy is one of the LFU policies.
+func ObjectFreqCmd(rcv *int64, key string) CmdAction {
+	return NewModuleCmd("OBJECT").Arg("FREQ").Key(key).Cmd(rcv)
+}
+
+// ObjectIdleTimeCmd returns a CmdAction which performs OBJECT IDLETIME on the
+// given key, writing the time since it was last accessed into rcv. This only
+// works when the maxmemory-policy isn't one of the LFU policies.
+func ObjectIdleTimeCmd(rcv *time.Duration, key string) CmdAction {
+	return NewModuleCmd("OBJECT").Arg("IDLETIME").Key(key).
+		Cmd(resp2.Any{I: rcv, TimeUnit: time.Second})
+}
+
+// KeyReport describes a key's value and how it's stored, see KeyReports.
+type KeyReport struct {
+	Key string
+
+	// Type is the type of the key's value, as returned by TYPE, or "none" if
+	// the key doesn't exist.
+	Type string
+
+	// Encoding is the internal encoding of the key's value, as returned by
+	// OBJECT ENCODING.
+	Encoding string
+
+	// MemoryUsage is the number of bytes the key and its value use, as
+	// returned by MEMORY USAGE.
+	MemoryUsage int64
+
+	// IdleTime is the time since the key was last accessed, as returned by
+	// OBJECT IDLETIME, or -1 if it's not available due to an LFU
+	// maxmemory-policy being used.
+	IdleTime time.Duration
+
+	// Freq is the logarithmic access frequency counter of the key, as returned
+	// by OBJECT FREQ, or -1 if it's not available due to an LFU
+	// maxmemory-policy not being used.
+	Freq int64
+}
+
+// unmarshalUnlessErr unmarshals the reply into rcv, unless it's an error reply
+// or nil, returning whether it did.
+func unmarshalUnlessErr(raw resp2.RawMessage, rcv interface{}) bool {
+	if len(raw) == 0 || raw.IsNil() {
+		return false
+	}
+	return raw.UnmarshalInto(resp2.Any{I: rcv}) == nil
+}
+
+// KeyReports returns an Action which retrieves a KeyReport for each of the
+// given keys, writing them to rcv in the same order as the keys. Only one of
+// the IdleTime and Freq fields of each KeyReport will be available, depending
+// on the maxmemory-policy. Fields which aren't available, e.g. because OBJECT
+// has been disabled, are left as their zero value, or -1 for IdleTime and Freq.
+//
+// Like ClusterMGet, the keys may belong to any number of slots, and when
+// performed on a Cluster the commands for each node are performed concurrently.
+func KeyReports(rcv *[]KeyReport, keys ...string) Action {
+	reports := make([]KeyReport, len(keys))
+	raws := make([]resp2.RawMessage, len(keys)*3)
+	cmds := make([]CmdAction, 0, len(keys)*5)
+	for i, key := range keys {
+		raw := raws[i*3 : i*3+3]
+		reports[i] = KeyReport{Key: key, IdleTime: -1, Freq: -1}
+		cmds = append(cmds,
+			Cmd(&reports[i].Type, "TYPE", key),
+			MemoryUsageCmd(&reports[i].MemoryUsage, key, -1),
+			NewModuleCmd("OBJECT").Arg("ENCODING").Key(key).Cmd(&raw[0]),
+			NewModuleCmd("OBJECT").Arg("IDLETIME").Key(key).Cmd(&raw[1]),
+			NewModuleCmd("OBJECT").Arg("FREQ").Key(key).Cmd(&raw[2]),
+		)
+	}
+
+	return multiKeyAction{pipeline: cmds, merge: func() error {
+		for i := range reports {
+			raw, r := raws[i*3:i*3+3], &reports[i]
+			unmarshalUnlessErr(raw[0], &r.Encoding)
+			var idleSecs int64
+			if unmarshalUnlessErr(raw[1], &idleSecs) {
+				r.IdleTime = time.Duration(idleSecs) * time.Second
+			}
+			unmarshalUnlessErr(raw[2], &r.Freq)
+		}
+		*rcv = reports
+		return nil
+	}}
+}
+
+// AnalyzeKeyspaceOpts are options which can be passed into AnalyzeKeyspace.
+type AnalyzeKeyspaceOpts struct {
+	// Prefix returns the prefix which the given key is grouped under.
+	//
+	// The default, if Prefix is nil, is DefaultKeyPrefix.
+	Prefix func(key string) string
+
+	// SampleRatio is the fraction of keys returned by the Scanner, chosen at
+	// random, which are analyzed, e.g. 0.1 to analyze 10% of them.
+	//
+	// The default, if SampleRatio is 0, is to analyze all of them.
+	SampleRatio float64
+
+	// MaxKeys, if greater than 0, is the number of keys after which analysis
+	// stops, even if the Scanner has more.
+	MaxKeys int
+
+	// BatchSize is the number of keys which KeyReports are retrieved for at a
+	// time.
+	//
+	// The default, if BatchSize is 0, is 100.
+	BatchSize int
+}
+
+// DefaultKeyPrefix is the default AnalyzeKeyspaceOpts.Prefix. It returns the
+// part of the key up to and including its first ':', or "" if the key doesn't
+// contain one.
+func DefaultKeyPrefix(key string) string {
+	if i := strings.IndexByte(key, ':'); i >= 0 {
+		return key[:i+1]
+	}
+	return ""
+}
+
+// PrefixStats describes the keys which were analyzed by AnalyzeKeyspace that
+// share a prefix.
+type PrefixStats struct {
+	Prefix string
+
+	// Keys is the number of keys analyzed.
+	Keys int
+
+	// MemoryUsage is the total number of bytes used by the keys analyzed.
+	MemoryUsage int64
+
+	// Types is the number of keys analyzed of each type, e.g. "hash".
+	Types map[string]int
+}
+
+// AnalyzeKeyspace retrieves the KeyReports of the keys returned by the
+// Scanner, which should be scanning the given Client, and aggregates them by
+// prefix (see AnalyzeKeyspaceOpts). The returned PrefixStats are sorted by
+// MemoryUsage, largest first.
+//
+// When only a sample of keys is analyzed (see SampleRatio) the PrefixStats
+// only describe that sample. They can be divided by SampleRatio to estimate
+// the totals of the whole keyspace.
+//
+// The Client may be a Cluster, in which case the Scanner should be created
+// using the Cluster's NewScanner method:
+//
+//	s := c.NewScanner(radix.ScanAllKeys)
+//	stats, err := radix.AnalyzeKeyspace(c, s, radix.AnalyzeKeyspaceOpts{
+//		SampleRatio: 0.01,
+//	})
+//
+// The Scanner is always closed.
+func AnalyzeKeyspace(c Client, s Scanner, opts AnalyzeKeyspaceOpts) ([]PrefixStats, error) {
+	if opts.Prefix == nil {
+		opts.Prefix = DefaultKeyPrefix
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 100
+	}
+
+	statsByPrefix := map[string]*PrefixStats{}
+	batch := make([]string, 0, opts.BatchSize)
+	var analyzed int
+	analyze := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+
+		var reports []KeyReport
+		if err := c.Do(KeyReports(&reports, batch...)); err != nil {
+			return err
+		}
+		for _, r := range reports {
+			if r.Type == "none" {
+				// the key was deleted or expired since it was scanned
+				continue
+			}
+
+			prefix := opts.Prefix(r.Key)
+			stats := statsByPrefix[prefix]
+			if stats == nil {
+				stats = &PrefixStats{Prefix: prefix, Types: map[string]int{}}
+				statsByPrefix[prefix] = stats
+			}
+			stats.Keys++
+			stats.MemoryUsage += r.MemoryUsage
+			stats.Types[r.Type]++
+		}
+		batch = batch[:0]
+		return nil
+	}
+
+	var key string
+	for (opts.MaxKeys <= 0 || analyzed < opts.MaxKeys) && s.Next(&key) {
+		if opts.SampleRatio > 0 && rand.Float64() >= opts.SampleRatio {
+			continue
+		}
+
+		analyzed++
+		if batch = append(batch, key); len(batch) < opts.BatchSize {
+			continue
+		} else if err := analyze(); err != nil {
+			s.Close()
+			return nil, err
+		}
+	}
+	if err := s.Close(); err != nil {
+		return nil, err
+	} else if err := analyze(); err != nil {
+		return nil, err
+	}
+
+	stats := make([]PrefixStats, 0, len(statsByPrefix))
+	for _, ps := range statsByPrefix {
+		stats = append(stats, *ps)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].MemoryUsage != stats[j].MemoryUsage {
+			return stats[i].MemoryUsage > stats[j].MemoryUsage
+		}
+		return stats[i].Prefix < stats[j].Prefix
+	})
+	return stats, nil
+}
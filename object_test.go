@@ -0,0 +1,88 @@
+package radix
+
+import (
+	. "testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeyReports(t *T) {
+	c := dial()
+	defer c.Close()
+
+	k1, k2, k3 := randStr(), randStr(), randStr()
+	require.Nil(t, c.Do(Cmd(nil, "SET", k1, "foo")))
+	require.Nil(t, c.Do(Cmd(nil, "HSET", k2, "a", "1", "b", "2")))
+
+	var reports []KeyReport
+	require.Nil(t, c.Do(KeyReports(&reports, k1, k2, k3)))
+	require.Len(t, reports, 3)
+
+	assert.Equal(t, k1, reports[0].Key)
+	assert.Equal(t, "string", reports[0].Type)
+	assert.NotZero(t, reports[0].MemoryUsage)
+	assert.Equal(t, "hash", reports[1].Type)
+	assert.NotZero(t, reports[1].MemoryUsage)
+
+	// exactly one of IdleTime and Freq is available, depending on the
+	// maxmemory-policy
+	for _, r := range reports[:2] {
+		assert.True(t, (r.IdleTime >= 0) != (r.Freq >= 0), "%+v", r)
+	}
+
+	assert.Equal(t, KeyReport{Key: k3, Type: "none", IdleTime: -1, Freq: -1}, reports[2])
+
+	var idle time.Duration
+	require.Nil(t, c.Do(ObjectIdleTimeCmd(&idle, k1)))
+	assert.True(t, idle < time.Minute)
+}
+
+func TestAnalyzeKeyspace(t *T) {
+	c := dial()
+	defer c.Close()
+
+	prefix := randStr()
+	for i := 0; i < 5; i++ {
+		require.Nil(t, c.Do(Cmd(nil, "SET", prefix+":a:"+randStr(), "foo")))
+	}
+	for i := 0; i < 3; i++ {
+		require.Nil(t, c.Do(Cmd(nil, "RPUSH", prefix+":b:"+randStr(), "foo", "bar")))
+	}
+
+	analyze := func(opts AnalyzeKeyspaceOpts) []PrefixStats {
+		opts.Prefix = func(key string) string { return key[:len(prefix)+3] }
+		opts.BatchSize = 2
+		s := NewScanner(c, ScanOpts{Command: "SCAN", Pattern: prefix + ":*"})
+		stats, err := AnalyzeKeyspace(c, s, opts)
+		require.Nil(t, err)
+		return stats
+	}
+
+	stats := analyze(AnalyzeKeyspaceOpts{})
+	require.Len(t, stats, 2)
+	byPrefix := map[string]PrefixStats{}
+	for _, ps := range stats {
+		assert.NotZero(t, ps.MemoryUsage)
+		byPrefix[ps.Prefix] = ps
+	}
+	assert.True(t, stats[0].MemoryUsage >= stats[1].MemoryUsage)
+	assert.Equal(t, 5, byPrefix[prefix+":a:"].Keys)
+	assert.Equal(t, map[string]int{"string": 5}, byPrefix[prefix+":a:"].Types)
+	assert.Equal(t, 3, byPrefix[prefix+":b:"].Keys)
+	assert.Equal(t, map[string]int{"list": 3}, byPrefix[prefix+":b:"].Types)
+
+	var total int
+	for _, ps := range analyze(AnalyzeKeyspaceOpts{MaxKeys: 4}) {
+		total += ps.Keys
+	}
+	assert.Equal(t, 4, total)
+
+	assert.Empty(t, analyze(AnalyzeKeyspaceOpts{SampleRatio: 1e-12}))
+}
+
+func TestDefaultKeyPrefix(t *T) {
+	assert.Equal(t, "user:", DefaultKeyPrefix("user:1:name"))
+	assert.Equal(t, "", DefaultKeyPrefix("user"))
+}
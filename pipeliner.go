@@ -11,15 +11,20 @@ import (
 )
 
 var blockingCmds = map[string]bool{
-	"WAIT": true,
+	"WAIT":    true,
+	"WAITAOF": true,
 
-	// taken from https://github.com/joomcode/redispipe#limitations
+	// taken from https://github.com/joomcode/redispipe#limitations, along
+	// with the blocking commands added since
 	"BLPOP":      true,
 	"BRPOP":      true,
 	"BRPOPLPUSH": true,
+	"BLMOVE":     true,
+	"BLMPOP":     true,
 
 	"BZPOPMIN": true,
 	"BZPOPMAX": true,
+	"BZMPOP":   true,
 
 	"XREAD":      true,
 	"XREADGROUP": true,
@@ -233,3 +233,24 @@ func TestPipeliner(t *T) {
 		})
 	})
 }
+
+func TestPipelinerCanDo(t *T) {
+	conn := dial()
+	defer conn.Close()
+	p := newPipeliner(conn, 0, 0, 0)
+	defer p.Close()
+
+	assert.True(t, p.CanDo(Cmd(nil, "GET", "foo")))
+	assert.False(t, p.CanDo(Pipeline(Cmd(nil, "GET", "foo"))))
+
+	// blocking commands would hold up every other command in the pipeline
+	for _, cmd := range [][]string{
+		{"BLPOP", "foo", "0"},
+		{"blmove", "foo", "bar", "LEFT", "RIGHT", "0"},
+		{"BLMPOP", "0", "1", "foo", "LEFT"},
+		{"BZMPOP", "0", "1", "foo", "MIN"},
+		{"WAITAOF", "1", "0", "0"},
+	} {
+		assert.False(t, p.CanDo(Cmd(nil, cmd[0], cmd[1:]...)), "cmd:%q", cmd)
+	}
+}
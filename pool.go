@@ -1,6 +1,7 @@
 package radix
 
 import (
+	"context"
 	"io"
 	"net"
 	"sync"
@@ -10,6 +11,7 @@ import (
 	errors "golang.org/x/xerrors"
 
 	"github.com/mediocregopher/radix/v3/resp"
+	"github.com/mediocregopher/radix/v3/resp/resp2"
 	"github.com/mediocregopher/radix/v3/trace"
 )
 
@@ -28,6 +30,13 @@ type ioErrConn struct {
 	// level error, e.g. a timeout, disconnect, etc... Close is automatically
 	// called on the client when it encounters a critical network error
 	lastIOErr error
+
+	// Set if redis returned an error indicating that the instance the Conn is
+	// connected to can't currently serve it, i.e. a LOADING error, or a
+	// READONLY error because the instance has been demoted to a replica. Such
+	// Conns are closed rather than being returned to a Pool, so that new Conns
+	// can be created in their place.
+	staleErr error
 }
 
 func newIOErrConn(c Conn) *ioErrConn {
@@ -41,6 +50,8 @@ func (ioc *ioErrConn) Encode(m resp.Marshaler) error {
 	err := ioc.Conn.Encode(m)
 	if nerr, _ := err.(net.Error); nerr != nil {
 		ioc.lastIOErr = err
+	} else if errors.As(err, new(partialEncodeError)) {
+		ioc.lastIOErr = err
 	}
 	return err
 }
@@ -54,12 +65,36 @@ func (ioc *ioErrConn) Decode(m resp.Unmarshaler) error {
 		ioc.lastIOErr = err
 	} else if err != nil && !errors.As(err, new(resp.ErrDiscarded)) {
 		ioc.lastIOErr = err
+	} else if isStaleConnErr(err) {
+		ioc.staleErr = err
 	}
 	return err
 }
 
+func isStaleConnErr(err error) bool {
+	return errors.Is(err, resp2.ErrLoading) || errors.Is(err, resp2.ErrReadOnly)
+}
+
 func (ioc *ioErrConn) Do(a Action) error {
-	return a.Run(ioc)
+	return ioc.traceDo(a, func() error { return a.Run(ioc) })
+}
+
+func (ioc *ioErrConn) DoContext(ctx context.Context, a Action) error {
+	return ioc.traceDo(a, func() error {
+		return withContextDeadline(ctx, ioc.NetConn(), func() error {
+			return a.Run(ioc)
+		})
+	})
+}
+
+// traceDo calls fn, which performs the Action on the ioErrConn itself rather
+// than on the Conn it wraps, such that the ConnTrace of that Conn, if it was
+// created by Dial with DialWithTrace, is still called.
+func (ioc *ioErrConn) traceDo(a Action, fn func() error) error {
+	if tc, ok := ioc.Conn.(*tracedConn); ok {
+		return tc.do(a, fn)
+	}
+	return fn()
 }
 
 func (ioc *ioErrConn) Close() error {
@@ -80,7 +115,14 @@ type poolOpts struct {
 	pipelineConcurrency   int
 	pipelineLimit         int
 	pipelineWindow        time.Duration
+	maxBlocking           int
+	clientName            func() string
+	scriptRegistry        *ScriptRegistry
+	dnsInterval           time.Duration
+	dnsSRV                bool
+	dnsDialOpts           []DialOpt
 	pt                    trace.PoolTrace
+	logger                Logger
 }
 
 // PoolOpt is an optional behavior which can be applied to the NewPool function
@@ -96,6 +138,58 @@ func PoolConnFunc(cf ConnFunc) PoolOpt {
 	}
 }
 
+// PoolClientName tells the Pool to give each Conn it creates the given name,
+// using CLIENT SETNAME, after it's been created by the Pool's ConnFunc. See
+// also PoolClientNameFunc.
+func PoolClientName(name string) PoolOpt {
+	return PoolClientNameFunc(func() string { return name })
+}
+
+// PoolClientNameFunc is like PoolClientName, but fn is called for each Conn
+// the Pool creates to get the name it's given. SequentialClientName can be used
+// to give each Conn a distinct name.
+func PoolClientNameFunc(fn func() string) PoolOpt {
+	return func(po *poolOpts) {
+		po.clientName = fn
+	}
+}
+
+// PoolDNSDiscovery tells the Pool to treat the host of the addr given to
+// NewPool as a DNS name which may resolve to multiple addresses. Each new Conn
+// the Pool creates is connected to the next of those addresses in turn, and the
+// name is resolved again once the given interval has elapsed since it was last
+// resolved, so that new Conns follow changes to the DNS records without the
+// Pool needing to be recreated. Existing Conns are not closed when their
+// address is no longer resolved.
+//
+// If resolving the name fails after it has previously succeeded then the
+// previously resolved addresses continue to be used.
+//
+// If no DialOpts are given then Conns are created by calling the Pool's
+// ConnFunc with the resolved address. Otherwise Conns are created using Dial
+// with the given DialOpts, and the ConnFunc isn't used. In that case, if
+// DialUseTLS is given with a tls.Config whose ServerName isn't set, the
+// certificate of the redis instance is verified against the host of the addr
+// rather than the resolved IP.
+func PoolDNSDiscovery(interval time.Duration, opts ...DialOpt) PoolOpt {
+	return func(po *poolOpts) {
+		po.dnsInterval = interval
+		po.dnsSRV = false
+		po.dnsDialOpts = opts
+	}
+}
+
+// PoolDNSSRVDiscovery is like PoolDNSDiscovery, but the addr given to NewPool
+// is treated as the name of SRV records, e.g. "_redis._tcp.example.com", whose
+// targets and ports are the addresses which Conns are created to.
+func PoolDNSSRVDiscovery(interval time.Duration) PoolOpt {
+	return func(po *poolOpts) {
+		po.dnsInterval = interval
+		po.dnsSRV = true
+		po.dnsDialOpts = nil
+	}
+}
+
 // PoolPingInterval specifies the interval at which a ping event happens. On
 // each ping event the Pool calls the PING redis command over one of it's
 // available connections.
@@ -209,6 +303,20 @@ func PoolPipelineWindow(window time.Duration, limit int) PoolOpt {
 	}
 }
 
+// PoolMaxBlocking limits the number of blocking Actions which may be performed
+// on the Pool concurrently to n. Blocking Actions are single blocking commands,
+// like BLPOP, WAIT, or XREAD with BLOCK, and Actions wrapped using Blocking.
+// Any blocking Actions past the limit will wait for one of the others to
+// complete before being given a connection, so that blocking Actions can't tie
+// up all of the Pool's connections.
+//
+// If n is 0 or less then blocking Actions are not limited.
+func PoolMaxBlocking(n int) PoolOpt {
+	return func(po *poolOpts) {
+		po.maxBlocking = n
+	}
+}
+
 // PoolWithTrace tells the Pool to trace itself with the given PoolTrace
 // Note that PoolTrace will block every point that you set to trace.
 func PoolWithTrace(pt trace.PoolTrace) PoolOpt {
@@ -217,6 +325,18 @@ func PoolWithTrace(pt trace.PoolTrace) PoolOpt {
 	}
 }
 
+// PoolLogger tells the Pool to log events using the given Logger. The Pool
+// logs a warning when it fails to create a connection or when a PING sent due
+// to PoolPingInterval fails, and logs at info level when it discards a
+// connection because of an error encountered on it.
+//
+// The Logger isn't passed into the Pool's ConnFunc, use DialLogger for that.
+func PoolLogger(l Logger) PoolOpt {
+	return func(po *poolOpts) {
+		po.logger = l
+	}
+}
+
 ////////////////////////////////////////////////////////////////////////////////
 
 // Pool is a dynamic connection pool which implements the Client interface. It
@@ -235,12 +355,22 @@ func PoolWithTrace(pt trace.PoolTrace) PoolOpt {
 // performance during high-concurrency usage, at the expense of slightly worse
 // performance during low-concurrency usage. It can be disabled using
 // PoolPipelineWindow(0, 0).
+//
+// Connections on which redis returns a LOADING error, or a READONLY error (e.g.
+// because the instance was demoted to a replica during a failover), are closed
+// rather than being reused, so that subsequent Actions are performed on newly
+// created connections. The error itself is still returned to the caller.
 type Pool struct {
 	// Atomic fields must be at the beginning of the struct since they must be
 	// correctly aligned or else access may cause panics on 32-bit architectures
 	// See https://golang.org/pkg/sync/atomic/#pkg-note-BUG
 	totalConns int64 // atomic, must only be access using functions from sync/atomic
 
+	// these are used by Stats, and are also atomic
+	waitCount, waitDuration   int64
+	dialFailures              int64
+	closedErr, closedUnneeded int64
+
 	opts          poolOpts
 	network, addr string
 	size          int
@@ -253,6 +383,10 @@ type Pool struct {
 
 	pipeliner *pipeliner
 
+	// blockingCh acts as a semaphore for PoolMaxBlocking, it is nil if there is
+	// no limit
+	blockingCh chan struct{}
+
 	wg       sync.WaitGroup
 	closeCh  chan bool
 	initDone chan struct{} // used for tests
@@ -315,9 +449,25 @@ func NewPool(network, addr string, size int, opts ...PoolOpt) (*Pool, error) {
 		}
 	}
 
+	if p.opts.dnsInterval > 0 {
+		dns := newDNSDiscovery(addr, p.opts.dnsSRV, p.opts.dnsInterval)
+		cf := p.opts.cf
+		dialOpts := p.opts.dnsDialOpts
+		p.opts.cf = func(network, _ string) (Conn, error) {
+			if len(dialOpts) > 0 {
+				return dns.DialWithOpts(network, dialOpts)
+			}
+			return dns.Dial(cf, network)
+		}
+	}
+
 	totalSize := size + p.opts.overflowSize
 	p.pool = make(chan *ioErrConn, totalSize)
 
+	if p.opts.maxBlocking > 0 {
+		p.blockingCh = make(chan struct{}, p.opts.maxBlocking)
+	}
+
 	// make one Conn synchronously to ensure there's actually a redis instance
 	// present. The rest will be created asynchronously.
 	ioc, err := p.newConn(trace.PoolConnCreatedReasonInitialization)
@@ -366,7 +516,11 @@ func NewPool(network, addr string, size int, opts ...PoolOpt) (*Pool, error) {
 		)
 	}
 	if p.opts.pingInterval > 0 && size > 0 {
-		p.atIntervalDo(p.opts.pingInterval, func() { p.Do(Cmd(nil, "PING")) })
+		p.atIntervalDo(p.opts.pingInterval, func() {
+			if err := p.Do(Cmd(nil, "PING")); err != nil {
+				p.log(LogLevelWarn, "health check failed", "err", err)
+			}
+		})
 	}
 	if p.opts.refillInterval > 0 && size > 0 {
 		p.atIntervalDo(p.opts.refillInterval, p.doRefill)
@@ -387,6 +541,12 @@ func (p *Pool) traceInitCompleted(elapsedTime time.Duration) {
 	}
 }
 
+func (p *Pool) log(level LogLevel, msg string, keyvals ...interface{}) {
+	if p.opts.logger != nil {
+		p.opts.logger.Log(level, msg, append([]interface{}{"addr", p.addr}, keyvals...)...)
+	}
+}
+
 func (p *Pool) err(err error) {
 	select {
 	case p.ErrCh <- err:
@@ -425,9 +585,21 @@ func (p *Pool) traceConnClosed(reason trace.PoolConnClosedReason) {
 func (p *Pool) newConn(reason trace.PoolConnCreatedReason) (*ioErrConn, error) {
 	start := time.Now()
 	c, err := p.opts.cf(p.network, p.addr)
+	if err == nil && p.opts.clientName != nil {
+		if err = setClientName(c, p.opts.clientName); err != nil {
+			c.Close()
+		}
+	}
+	if err == nil && p.opts.scriptRegistry != nil {
+		if err = c.Do(p.opts.scriptRegistry.Action()); err != nil {
+			c.Close()
+		}
+	}
 	elapsed := time.Since(start)
 	p.traceConnCreated(elapsed, reason, err)
 	if err != nil {
+		atomic.AddInt64(&p.dialFailures, 1)
+		p.log(LogLevelWarn, "failed to create connection", "reason", reason, "err", err)
 		return nil, err
 	}
 	ioc := newIOErrConn(c)
@@ -490,9 +662,10 @@ func (p *Pool) doOverflowDrain() {
 	ioc.Close()
 	p.traceConnClosed(trace.PoolConnClosedReasonBufferDrain)
 	atomic.AddInt64(&p.totalConns, -1)
+	atomic.AddInt64(&p.closedUnneeded, 1)
 }
 
-func (p *Pool) getExisting() (*ioErrConn, error) {
+func (p *Pool) getExisting(ctx context.Context) (*ioErrConn, error) {
 	// Fast-path if the pool is not empty. Return error if pool has been closed.
 	select {
 	case ioc, ok := <-p.pool:
@@ -518,6 +691,12 @@ func (p *Pool) getExisting() (*ioErrConn, error) {
 		tc = t.C
 	}
 
+	start := time.Now()
+	atomic.AddInt64(&p.waitCount, 1)
+	defer func() {
+		atomic.AddInt64(&p.waitDuration, int64(time.Since(start)))
+	}()
+
 	select {
 	case ioc, ok := <-p.pool:
 		if !ok {
@@ -526,11 +705,13 @@ func (p *Pool) getExisting() (*ioErrConn, error) {
 		return ioc, nil
 	case <-tc:
 		return nil, p.opts.errOnEmpty
+	case <-ctx.Done():
+		return nil, ctx.Err()
 	}
 }
 
-func (p *Pool) get() (*ioErrConn, error) {
-	ioc, err := p.getExisting()
+func (p *Pool) get(ctx context.Context) (*ioErrConn, error) {
+	ioc, err := p.getExisting(ctx)
 	if err != nil {
 		return nil, err
 	} else if ioc != nil {
@@ -543,7 +724,7 @@ func (p *Pool) get() (*ioErrConn, error) {
 // discarded.
 func (p *Pool) put(ioc *ioErrConn) bool {
 	p.l.RLock()
-	if ioc.lastIOErr == nil && !p.closed {
+	if ioc.lastIOErr == nil && ioc.staleErr == nil && !p.closed {
 		select {
 		case p.pool <- ioc:
 			p.l.RUnlock()
@@ -551,8 +732,20 @@ func (p *Pool) put(ioc *ioErrConn) bool {
 		default:
 		}
 	}
+	closed := p.closed
 	p.l.RUnlock()
 
+	if ioc.lastIOErr != nil || ioc.staleErr != nil {
+		atomic.AddInt64(&p.closedErr, 1)
+		err := ioc.lastIOErr
+		if err == nil {
+			err = ioc.staleErr
+		}
+		p.log(LogLevelInfo, "discarding connection after error", "err", err)
+	} else if !closed {
+		atomic.AddInt64(&p.closedUnneeded, 1)
+	}
+
 	// the pool might close here, but that's fine, because all that's happening
 	// at this point is that the connection is being closed
 	ioc.Close()
@@ -583,7 +776,14 @@ func (p *Pool) Do(a Action) error {
 		return err
 	}
 
-	c, err := p.get()
+	if p.blockingCh != nil && isBlockingAction(a) {
+		if err := p.acquireBlocking(context.Background()); err != nil {
+			return err
+		}
+		defer func() { <-p.blockingCh }()
+	}
+
+	c, err := p.get(context.Background())
 	if err != nil {
 		return err
 	}
@@ -595,6 +795,50 @@ func (p *Pool) Do(a Action) error {
 	return err
 }
 
+// DoContext implements the method for the ContextClient interface. It is like
+// Do, except that waiting for a Conn to become available and the Action itself
+// are both bound by the given Context.
+//
+// Actions passed to DoContext are never implicitly pipelined, since doing so
+// would tie the Context of one Action to those of the others it was pipelined
+// with.
+func (p *Pool) DoContext(ctx context.Context, a Action) error {
+	startTime := time.Now()
+
+	if p.blockingCh != nil && isBlockingAction(a) {
+		if err := p.acquireBlocking(ctx); err != nil {
+			return err
+		}
+		defer func() { <-p.blockingCh }()
+	}
+
+	c, err := p.get(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = c.DoContext(ctx, a)
+	p.put(c)
+	p.traceDoCompleted(time.Since(startTime), err)
+
+	return err
+}
+
+// acquireBlocking waits for one of the slots for blocking Actions to be
+// available (see PoolMaxBlocking) and takes it, unless the Context is done or
+// the Pool is closed first. The slot must be released by reading from
+// blockingCh once the Action has been performed.
+func (p *Pool) acquireBlocking(ctx context.Context) error {
+	select {
+	case p.blockingCh <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-p.closeCh:
+		return errClientClosed
+	}
+}
+
 func (p *Pool) traceDoCompleted(elapsedTime time.Duration, err error) {
 	if p.opts.pt.DoCompleted != nil {
 		p.opts.pt.DoCompleted(trace.PoolDoCompleted{
@@ -612,6 +856,64 @@ func (p *Pool) NumAvailConns() int {
 	return len(p.pool)
 }
 
+// PoolStats describes the state of a Pool at a moment in time, as well as the
+// totals of various events over the Pool's lifetime. See the Pool's Stats
+// method.
+type PoolStats struct {
+	// TotalConns is the number of connections the Pool currently has open,
+	// whether they are available or in use.
+	TotalConns int
+
+	// AvailConns is the number of connections which are currently available
+	// for use, i.e. NumAvailConns.
+	AvailConns int
+
+	// InUseConns is the number of connections which are currently in use by an
+	// Action.
+	InUseConns int
+
+	// WaitCount is the number of times an Action had to wait for a connection
+	// to become available, and WaitDuration is the total time spent waiting.
+	// See the PoolOnEmpty options.
+	WaitCount    int64
+	WaitDuration time.Duration
+
+	// DialFailures is the number of times the Pool failed to create a new
+	// connection.
+	DialFailures int64
+
+	// ClosedErr is the number of connections which were closed due to them
+	// encountering a network error.
+	ClosedErr int64
+
+	// ClosedUnneeded is the number of connections which were closed because the
+	// Pool had no room for them, either due to the Pool being full or due to
+	// the overflow buffer being drained. See the PoolOnFull options.
+	ClosedUnneeded int64
+}
+
+// Stats returns a PoolStats describing the Pool's current state. Since the Pool
+// is being used concurrently the fields of the PoolStats may not be exactly
+// consistent with each other.
+func (p *Pool) Stats() PoolStats {
+	total := int(atomic.LoadInt64(&p.totalConns))
+	avail := len(p.pool)
+	inUse := total - avail
+	if inUse < 0 {
+		inUse = 0
+	}
+	return PoolStats{
+		TotalConns:     total,
+		AvailConns:     avail,
+		InUseConns:     inUse,
+		WaitCount:      atomic.LoadInt64(&p.waitCount),
+		WaitDuration:   time.Duration(atomic.LoadInt64(&p.waitDuration)),
+		DialFailures:   atomic.LoadInt64(&p.dialFailures),
+		ClosedErr:      atomic.LoadInt64(&p.closedErr),
+		ClosedUnneeded: atomic.LoadInt64(&p.closedUnneeded),
+	}
+}
+
 // Close implements the Close method of the Client
 func (p *Pool) Close() error {
 	p.l.Lock()
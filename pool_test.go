@@ -1,7 +1,9 @@
 package radix
 
 import (
+	"context"
 	"io"
+	"strings"
 	"sync"
 	"sync/atomic"
 	. "testing"
@@ -97,14 +99,14 @@ func TestPool(t *T) {
 func TestPoolGet(t *T) {
 	getBlock := func(p *Pool) (time.Duration, error) {
 		start := time.Now()
-		_, err := p.get()
+		_, err := p.get(context.Background())
 		return time.Since(start), err
 	}
 
 	// this one is a bit weird, cause it would block infinitely if we let it
 	t.Run("onEmptyWait", func(t *T) {
 		pool := testPool(1, PoolOnEmptyWait())
-		conn, err := pool.get()
+		conn, err := pool.get(context.Background())
 		assert.NoError(t, err)
 
 		go func() {
@@ -236,6 +238,124 @@ func TestPoolPut(t *T) {
 	assertPoolConns(0)
 }
 
+func TestPoolClientName(t *T) {
+	pool := testPool(2, PoolClientNameFunc(SequentialClientName("radix-pool")))
+	defer pool.Close()
+
+	getName := func(conn Conn) string {
+		var name string
+		require.Nil(t, conn.Do(Cmd(&name, "CLIENT", "GETNAME")))
+		return name
+	}
+
+	// hold both of the Pool's conns at once, so they're sure to be different
+	var names []string
+	require.Nil(t, pool.Do(WithConn("", func(connA Conn) error {
+		names = append(names, getName(connA))
+		return pool.Do(WithConn("", func(connB Conn) error {
+			names = append(names, getName(connB))
+			return nil
+		}))
+	})))
+
+	require.Len(t, names, 2)
+	assert.NotEqual(t, names[0], names[1])
+	for _, name := range names {
+		assert.True(t, strings.HasPrefix(name, "radix-pool-"), "name: %q", name)
+	}
+}
+
+func TestPoolLogger(t *T) {
+	var l testLogger
+	var dialed int
+	connFunc := func(network, addr string) (Conn, error) {
+		if dialed++; dialed > 1 {
+			return nil, errors.New("dial failed")
+		}
+		return dial(), nil
+	}
+
+	pool := testPool(2, PoolConnFunc(connFunc), PoolLogger(&l))
+	defer pool.Close()
+
+	entries := l.withMsg("failed to create connection")
+	require.Len(t, entries, 1)
+	assert.Equal(t, LogLevelWarn, entries[0].level)
+	assert.Equal(t, []interface{}{
+		"addr", "localhost:6379",
+		"reason", trace.PoolConnCreatedReasonInitialization,
+		"err",
+	}, entries[0].keyvals[:5])
+	assert.EqualError(t, entries[0].keyvals[5].(error), "dial failed")
+}
+
+func TestPoolConnTrace(t *T) {
+	var l sync.Mutex
+	var completed []trace.ConnDoCompleted
+	connFunc := func(network, addr string) (Conn, error) {
+		return Dial(network, addr, DialWithTrace(trace.ConnTrace{
+			DoCompleted: func(d trace.ConnDoCompleted) {
+				l.Lock()
+				defer l.Unlock()
+				completed = append(completed, d)
+			},
+		}))
+	}
+	// the default config is used, so that commands may be implicitly pipelined
+	pool := testPool(1, PoolConnFunc(connFunc))
+	defer pool.Close()
+
+	assertCompleted := func(cmd string) {
+		l.Lock()
+		defer l.Unlock()
+		require.NotEmpty(t, completed)
+		last := completed[len(completed)-1]
+		assert.Equal(t, cmd, last.Cmd)
+		assert.Equal(t, []string{cmd}, last.Cmds)
+		assert.Equal(t, 1, last.NumKeys)
+		assert.True(t, last.BytesWritten > 0)
+		assert.True(t, last.BytesRead > 0)
+		assert.Nil(t, last.Err)
+	}
+
+	key := randStr()
+	require.Nil(t, pool.Do(Cmd(nil, "SET", key, "foo")))
+	assertCompleted("SET")
+	require.Nil(t, pool.DoContext(context.Background(), Cmd(nil, "GET", key)))
+	assertCompleted("GET")
+
+	// concurrent commands may be performed as a single pipeline, but every one
+	// of them is still reported
+	l.Lock()
+	completed = nil
+	l.Unlock()
+
+	const n = 10
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.Nil(t, pool.Do(Cmd(nil, "GET", key)))
+		}()
+	}
+	wg.Wait()
+
+	l.Lock()
+	defer l.Unlock()
+	var cmds []string
+	for _, d := range completed {
+		cmds = append(cmds, d.Cmds...)
+		if len(d.Cmds) > 1 {
+			assert.Empty(t, d.Cmd)
+		}
+	}
+	assert.Len(t, cmds, n)
+	for _, cmd := range cmds {
+		assert.Equal(t, "GET", cmd)
+	}
+}
+
 // TestPoolDoDoesNotBlock checks that with a positive onEmptyWait Pool.Do()
 // does not block longer than the timeout period given by user
 func TestPoolDoDoesNotBlock(t *T) {
@@ -315,4 +435,197 @@ func TestIoErrConn(t *T) {
 		err2 := ioc.Do(Cmd(nil, "GET", randStr()))
 		require.Nil(t, err2)
 	})
+
+	t.Run("StaleAfterLoadingError", func(t *T) {
+		ioc := newIOErrConn(Stub("tcp", "127.0.0.1:6379", func([]string) interface{} {
+			return resp2.Error{E: errors.New("LOADING Redis is loading the dataset in memory")}
+		}))
+		defer ioc.Close()
+
+		err := ioc.Do(Cmd(nil, "GET", randStr()))
+		require.True(t, errors.Is(err, resp2.ErrLoading))
+		require.Nil(t, ioc.lastIOErr)
+		require.Equal(t, err, ioc.staleErr)
+	})
+}
+
+func TestPoolDiscardsStaleConns(t *T) {
+	var dials int64
+	var readOnly int32 = 1
+	connFunc := func(network, addr string) (Conn, error) {
+		atomic.AddInt64(&dials, 1)
+		return Stub(network, addr, func(args []string) interface{} {
+			if atomic.LoadInt32(&readOnly) == 1 && args[0] == "SET" {
+				return resp2.Error{E: errors.New("READONLY You can't write against a read only replica.")}
+			}
+			return "OK"
+		}), nil
+	}
+
+	pool := testPool(1, PoolConnFunc(connFunc), PoolPipelineWindow(0, 0),
+		PoolRefillInterval(time.Hour))
+	defer pool.Close()
+	require.Equal(t, int64(1), atomic.LoadInt64(&dials))
+
+	err := pool.Do(Cmd(nil, "SET", "foo", "bar"))
+	require.True(t, errors.Is(err, resp2.ErrReadOnly))
+	assert.Equal(t, 0, pool.NumAvailConns())
+
+	atomic.StoreInt32(&readOnly, 0)
+	require.Nil(t, pool.Do(Cmd(nil, "SET", "foo", "bar")))
+	assert.Equal(t, int64(2), atomic.LoadInt64(&dials))
+	assert.Equal(t, 1, pool.NumAvailConns())
+}
+
+func TestPoolDoContext(t *T) {
+	pool := testPool(1, PoolOnEmptyErrAfter(time.Second))
+	defer pool.Close()
+
+	var out string
+	require.Nil(t, pool.DoContext(context.Background(), Cmd(&out, "ECHO", "foo")))
+	assert.Equal(t, "foo", out)
+
+	// hold the pool's only connection, so DoContext has to wait for one
+	connCh := make(chan struct{})
+	doneCh := make(chan struct{})
+	go func() {
+		pool.Do(WithConn("", func(Conn) error {
+			close(connCh)
+			<-doneCh
+			return nil
+		}))
+	}()
+	<-connCh
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err := pool.DoContext(ctx, Cmd(nil, "PING"))
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	close(doneCh)
+
+	// a Conn interrupted by the Context is discarded, not put back
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err = pool.DoContext(ctx, Cmd(nil, "BLPOP", randStr(), "0"))
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	require.Nil(t, pool.Do(Cmd(&out, "ECHO", "bar")))
+	assert.Equal(t, "bar", out)
+
+	// a Conn whose Action returned an error other than an IO error once the
+	// Context was done is put back, and its deadline mustn't remain
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err = pool.DoContext(ctx, WithConn("", func(Conn) error {
+		<-ctx.Done()
+		return errors.New("not an IO error")
+	}))
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	require.Nil(t, pool.Do(Cmd(&out, "ECHO", "baz")))
+	assert.Equal(t, "baz", out)
+}
+
+func TestPoolStats(t *T) {
+	var failDial bool
+	pool := testPool(2,
+		PoolConnFunc(func(network, addr string) (Conn, error) {
+			if failDial {
+				return nil, errors.New("dial failed")
+			}
+			return DefaultConnFunc(network, addr)
+		}),
+		PoolOnEmptyErrAfter(100*time.Millisecond),
+		PoolOnFullClose(),
+		PoolRefillInterval(0),
+		PoolPingInterval(0),
+		PoolPipelineWindow(0, 0),
+	)
+	defer pool.Close()
+	assert.Equal(t, PoolStats{TotalConns: 2, AvailConns: 2}, pool.Stats())
+
+	// a connection which encounters a network error is closed
+	require.Nil(t, pool.Do(WithConn("", func(conn Conn) error {
+		stats := pool.Stats()
+		assert.Equal(t, 1, stats.InUseConns)
+		assert.Equal(t, 1, stats.AvailConns)
+		conn.(*ioErrConn).lastIOErr = io.EOF
+		return nil
+	})))
+	stats := pool.Stats()
+	assert.Equal(t, 1, stats.TotalConns)
+	assert.Equal(t, int64(1), stats.ClosedErr)
+
+	// the Pool is now short a connection, but refilling it fails
+	failDial = true
+	pool.doRefill()
+	assert.Equal(t, int64(1), pool.Stats().DialFailures)
+	failDial = false
+
+	// waiting for a connection, while the only one is in use
+	require.Nil(t, pool.Do(WithConn("", func(Conn) error {
+		err := pool.Do(Cmd(nil, "PING"))
+		assert.Equal(t, ErrPoolEmpty, err)
+		return nil
+	})))
+	stats = pool.Stats()
+	assert.Equal(t, int64(1), stats.WaitCount)
+	assert.True(t, stats.WaitDuration >= 100*time.Millisecond)
+
+	// connections which don't fit in the Pool are closed
+	for i := 0; i < 2; i++ {
+		ioc, err := pool.newConn(trace.PoolConnCreatedReasonPoolEmpty)
+		require.Nil(t, err)
+		pool.put(ioc)
+	}
+	stats = pool.Stats()
+	assert.Equal(t, 2, stats.TotalConns)
+	assert.Equal(t, int64(1), stats.ClosedUnneeded)
+}
+
+func TestPoolMaxBlocking(t *T) {
+	pool := testPool(3, PoolMaxBlocking(1))
+	defer pool.Close()
+
+	key := randStr()
+	doneCh := make(chan error)
+	go func() {
+		doneCh <- pool.Do(Cmd(nil, "BLPOP", key, "0"))
+	}()
+	waitFor(t, func() bool { return len(pool.blockingCh) == 1 })
+
+	// a second blocking Action has to wait for the first, while others don't
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	err := pool.DoContext(ctx, Blocking(WithConn(key, func(c Conn) error {
+		return c.Do(Cmd(nil, "PING"))
+	})))
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	require.Nil(t, pool.Do(Cmd(nil, "PING")))
+
+	require.Nil(t, pool.Do(Cmd(nil, "LPUSH", key, "foo")))
+	require.Nil(t, <-doneCh)
+
+	// now that the first has completed another can be performed
+	require.Nil(t, pool.Do(Blocking(Cmd(nil, "PING"))))
+	assert.Empty(t, pool.blockingCh)
+}
+
+func TestPoolMaxBlockingClose(t *T) {
+	pool := testPool(2, PoolMaxBlocking(1))
+
+	key := randStr()
+	doneCh := make(chan error, 2)
+	go func() {
+		doneCh <- pool.Do(Cmd(nil, "BLPOP", key, "1"))
+	}()
+	waitFor(t, func() bool { return len(pool.blockingCh) == 1 })
+
+	// a blocking Action waiting for the first to complete gives up once the
+	// Pool is closed
+	go func() {
+		doneCh <- pool.Do(Blocking(Cmd(nil, "PING")))
+	}()
+	time.Sleep(50 * time.Millisecond)
+	require.Nil(t, pool.Close())
+	assert.Equal(t, errClientClosed, <-doneCh)
+	<-doneCh
 }
@@ -16,7 +16,7 @@ import (
 
 // PubSubMessage describes a message being published to a subscribed channel
 type PubSubMessage struct {
-	Type    string // "message" or "pmessage"
+	Type    string // "message", "pmessage", "smessage", or "reconnected"
 	Pattern string // will be set if Type is "pmessage"
 	Channel string
 	Message []byte
@@ -31,7 +31,7 @@ func (m PubSubMessage) MarshalRESP(w io.Writer) error {
 		}
 	}
 
-	if m.Type == "message" {
+	if m.Type == "message" || m.Type == "smessage" {
 		marshal(resp2.ArrayHeader{N: 3})
 		marshal(resp2.BulkString{S: m.Type})
 	} else if m.Type == "pmessage" {
@@ -84,8 +84,8 @@ func (m *PubSubMessage) UnmarshalRESP(br *bufio.Reader) error {
 	}
 
 	switch string(msgType.B) {
-	case "message":
-		m.Type = "message"
+	case "message", "smessage":
+		m.Type = string(msgType.B)
 		if ah.N != 3 {
 			return errors.New("message has wrong number of elements")
 		}
@@ -101,8 +101,14 @@ func (m *PubSubMessage) UnmarshalRESP(br *bufio.Reader) error {
 		}
 		m.Pattern = pattern.S
 	default:
-		// if it's not a PubSubMessage then discard the rest of the array
-		for i := 1; i < ah.N; i++ {
+		// if it's not a PubSubMessage then discard the rest of the array. The
+		// type and subject (e.g. "subscribe" and the channel name) are kept,
+		// so that the reply can be inspected by the caller.
+		m.Type = string(msgType.B)
+		if err := (resp2.Any{I: &m.Channel}).UnmarshalRESP(br); err != nil {
+			return err
+		}
+		for i := 2; i < ah.N; i++ {
 			if err := (resp2.Any{}).UnmarshalRESP(br); err != nil {
 				return err
 			}
@@ -151,6 +157,16 @@ func (cs chanSet) del(s string, ch chan<- PubSubMessage) bool {
 	return false
 }
 
+// hasChan returns true if ch is in the set for any string.
+func (cs chanSet) hasChan(ch chan<- PubSubMessage) bool {
+	for _, m := range cs {
+		if m[ch] {
+			return true
+		}
+	}
+	return false
+}
+
 func (cs chanSet) missing(ss []string) []string {
 	out := ss[:0]
 	for _, s := range ss {
@@ -224,12 +240,53 @@ type PubSubConn interface {
 	Close() error
 }
 
+// ShardedPubSubConn is a PubSubConn which also supports the shard channels
+// added in redis 7. The PubSubConns returned by PubSub and
+// PersistentPubSubWithOpts implement ShardedPubSubConn, and can be type asserted
+// to it.
+type ShardedPubSubConn interface {
+	PubSubConn
+
+	// SSubscribe is like Subscribe, but it subscribes msgCh to a set of shard
+	// channels using SSUBSCRIBE, as added in redis 7. msgCh will receive a
+	// PubSubMessage with Type "smessage" for every SPUBLISH to any of the
+	// channels.
+	//
+	// In a redis cluster a shard channel is owned by the instance which owns
+	// its slot, just like a key, and all channels given to a single call must
+	// belong to the same slot. If redis unsubscribes the PubSubConn from a
+	// shard channel itself, because the channel's slot was migrated to another
+	// instance, then msgCh will stop receiving messages for it, unless the
+	// ShardedPubSubConn was created by PersistentPubSubWithOpts, in which case
+	// the channel is subscribed to again. See ClusterShardedPubSub for a type
+	// which follows the channel to its new instance.
+	SSubscribe(msgCh chan<- PubSubMessage, channels ...string) error
+
+	// SUnsubscribe is like Unsubscribe, but it unsubscribes msgCh from a set
+	// of shard channels.
+	//
+	// NOTE even if msgCh is not subscribed to any other redis channels, it
+	// should still be considered "active", and therefore still be having
+	// messages read from it, until SUnsubscribe has returned
+	SUnsubscribe(msgCh chan<- PubSubMessage, channels ...string) error
+}
+
 type pubSubConn struct {
 	conn Conn
 
 	csL   sync.RWMutex
 	subs  chanSet
 	psubs chanSet
+	ssubs chanSet
+
+	// optional, if set then messages are written to it rather than directly to
+	// their message channels
+	buf *pubSubBuffer
+
+	// optional, called from spin when redis unsubscribes the connection from a
+	// shard channel without having been asked to. Used by
+	// ClusterShardedPubSub and persistentPubSub.
+	onSUnsubscribed func(channel string)
 
 	// These are used for writing commands and waiting for their response (e.g.
 	// SUBSCRIBE, PING). See the do method for how that works.
@@ -252,16 +309,21 @@ type pubSubConn struct {
 // PubSub wraps the given Conn so that it becomes a PubSubConn. The passed in
 // Conn should not be used after this call.
 func PubSub(rc Conn) PubSubConn {
-	return newPubSub(rc, nil)
+	return newPubSub(rc, nil, nil, nil)
 }
 
-func newPubSub(rc Conn, closeErrCh chan error) PubSubConn {
+func newPubSub(
+	rc Conn, closeErrCh chan error, buf *pubSubBuffer, onSUnsubscribed func(string),
+) ShardedPubSubConn {
 	c := &pubSubConn{
-		conn:       rc,
-		subs:       chanSet{},
-		psubs:      chanSet{},
-		cmdResCh:   make(chan error, 1),
-		closeErrCh: closeErrCh,
+		conn:            rc,
+		subs:            chanSet{},
+		psubs:           chanSet{},
+		ssubs:           chanSet{},
+		buf:             buf,
+		onSUnsubscribed: onSUnsubscribed,
+		cmdResCh:        make(chan error, 1),
+		closeErrCh:      closeErrCh,
 	}
 	go c.spin()
 
@@ -287,20 +349,28 @@ func (c *pubSubConn) testEvent(str string) {
 	}
 }
 
-func (c *pubSubConn) publish(m PubSubMessage) {
+func (c *pubSubConn) publish(m PubSubMessage) error {
 	c.csL.RLock()
 	defer c.csL.RUnlock()
 
 	var subs map[chan<- PubSubMessage]bool
-	if m.Type == "pmessage" {
+	switch m.Type {
+	case "pmessage":
 		subs = c.psubs[m.Pattern]
-	} else {
+	case "smessage":
+		subs = c.ssubs[m.Channel]
+	default:
 		subs = c.subs[m.Channel]
 	}
 
 	for ch := range subs {
-		ch <- m
+		if c.buf == nil {
+			ch <- m
+		} else if err := c.buf.push(ch, m); err != nil {
+			return err
+		}
 	}
+	return nil
 }
 
 func (c *pubSubConn) spin() {
@@ -311,16 +381,39 @@ func (c *pubSubConn) spin() {
 			c.testEvent("timeout")
 			continue
 		} else if errors.Is(err, errNotPubSubMessage) {
+			if m.Type == "sunsubscribe" && c.unsolicitedSUnsubscribe(m.Channel) {
+				continue
+			}
 			c.cmdResCh <- nil
 			continue
 		} else if err != nil {
 			c.closeInner(err)
 			return
 		}
-		c.publish(m)
+		if err := c.publish(m); err != nil {
+			c.closeInner(err)
+			return
+		}
 	}
 }
 
+// unsolicitedSUnsubscribe is called when a sunsubscribe reply is received for
+// the given channel. SUnsubscribe removes channels from ssubs prior to sending
+// SUNSUBSCRIBE, so if the channel is still present then redis must have
+// unsubscribed the connection from it on its own. In that case the channel's
+// subscriptions are dropped and true is returned.
+func (c *pubSubConn) unsolicitedSUnsubscribe(channel string) bool {
+	c.csL.Lock()
+	_, ok := c.ssubs[channel]
+	delete(c.ssubs, channel)
+	c.csL.Unlock()
+
+	if ok && c.onSUnsubscribed != nil {
+		c.onSUnsubscribed(channel)
+	}
+	return ok
+}
+
 // NOTE cmdL _must_ be held to use do
 func (c *pubSubConn) do(exp int, cmd string, args ...string) error {
 	rcmd := Cmd(nil, cmd, args...)
@@ -346,6 +439,7 @@ func (c *pubSubConn) closeInner(cmdResErr error) error {
 		c.closeErr = c.conn.Close()
 		c.subs = nil
 		c.psubs = nil
+		c.ssubs = nil
 
 		if cmdResErr != nil {
 			select {
@@ -452,6 +546,49 @@ func (c *pubSubConn) PUnsubscribe(msgCh chan<- PubSubMessage, patterns ...string
 	return c.do(len(emptyPatterns), "PUNSUBSCRIBE", emptyPatterns...)
 }
 
+func (c *pubSubConn) SSubscribe(msgCh chan<- PubSubMessage, channels ...string) error {
+	c.cmdL.Lock()
+	defer c.cmdL.Unlock()
+
+	c.csL.RLock()
+	missing := c.ssubs.missing(channels)
+	c.csL.RUnlock()
+
+	if len(missing) > 0 {
+		if err := c.do(len(missing), "SSUBSCRIBE", missing...); err != nil {
+			return err
+		}
+	}
+
+	c.csL.Lock()
+	for _, channel := range channels {
+		c.ssubs.add(channel, msgCh)
+	}
+	c.csL.Unlock()
+
+	return nil
+}
+
+func (c *pubSubConn) SUnsubscribe(msgCh chan<- PubSubMessage, channels ...string) error {
+	c.cmdL.Lock()
+	defer c.cmdL.Unlock()
+
+	c.csL.Lock()
+	emptyChannels := make([]string, 0, len(channels))
+	for _, channel := range channels {
+		if empty := c.ssubs.del(channel, msgCh); empty {
+			emptyChannels = append(emptyChannels, channel)
+		}
+	}
+	c.csL.Unlock()
+
+	if len(emptyChannels) == 0 {
+		return nil
+	}
+
+	return c.do(len(emptyChannels), "SUNSUBSCRIBE", emptyChannels...)
+}
+
 func (c *pubSubConn) Ping() error {
 	c.cmdL.Lock()
 	defer c.cmdL.Unlock()
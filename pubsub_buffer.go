@@ -0,0 +1,193 @@
+package radix
+
+import (
+	"sync"
+
+	errors "golang.org/x/xerrors"
+)
+
+// PubSubOverflowPolicy describes what is done when a message is received for a
+// message channel whose buffer is already full. See PersistentPubSubBuffer.
+type PubSubOverflowPolicy int
+
+// All possible PubSubOverflowPolicy values.
+const (
+	// PubSubOverflowBlock causes the reading of messages from the connection
+	// to be blocked until there is space in the buffer.
+	PubSubOverflowBlock PubSubOverflowPolicy = iota
+
+	// PubSubOverflowDropOldest causes the oldest message in the buffer to be
+	// discarded to make room for the new one.
+	PubSubOverflowDropOldest
+
+	// PubSubOverflowDropNew causes the new message to be discarded.
+	PubSubOverflowDropNew
+
+	// PubSubOverflowClose causes the PubSubConn to be closed, with all of its
+	// methods returning ErrPubSubOverflow from then on.
+	PubSubOverflowClose
+)
+
+// ErrPubSubOverflow is returned by the methods of a PubSubConn which was closed
+// due to a message channel's buffer being full, when PubSubOverflowClose is
+// used.
+var ErrPubSubOverflow = errors.New("pubsub message buffer is full")
+
+type pubSubQueue struct {
+	msgs []PubSubMessage
+
+	// removed is set, and stopCh closed, once the queue's msgCh has been
+	// removed, see remove.
+	removed bool
+	stopCh  chan struct{}
+}
+
+// pubSubBuffer sits between a pubSubConn and its message channels, queuing up
+// to size messages per message channel, with a separate goroutine per message
+// channel writing the queued messages to it.
+type pubSubBuffer struct {
+	size   int
+	policy PubSubOverflowPolicy
+	logger Logger
+
+	l       sync.Mutex
+	cond    *sync.Cond
+	queues  map[chan<- PubSubMessage]*pubSubQueue
+	closed  bool
+	closeCh chan struct{}
+	wg      sync.WaitGroup
+}
+
+func newPubSubBuffer(size int, policy PubSubOverflowPolicy) *pubSubBuffer {
+	b := &pubSubBuffer{
+		size:    size,
+		policy:  policy,
+		queues:  map[chan<- PubSubMessage]*pubSubQueue{},
+		closeCh: make(chan struct{}),
+	}
+	b.cond = sync.NewCond(&b.l)
+	return b
+}
+
+// queue returns the pubSubQueue for msgCh, creating it and its forwarding
+// goroutine if necessary. l must be held.
+func (b *pubSubBuffer) queue(msgCh chan<- PubSubMessage) *pubSubQueue {
+	q, ok := b.queues[msgCh]
+	if !ok {
+		q = &pubSubQueue{stopCh: make(chan struct{})}
+		b.queues[msgCh] = q
+		if !b.closed {
+			b.wg.Add(1)
+			go func() {
+				defer b.wg.Done()
+				b.forward(msgCh, q)
+			}()
+		}
+	}
+	return q
+}
+
+func (b *pubSubBuffer) forward(msgCh chan<- PubSubMessage, q *pubSubQueue) {
+	for {
+		b.l.Lock()
+		for len(q.msgs) == 0 && !b.closed && !q.removed {
+			b.cond.Wait()
+		}
+		if b.closed || q.removed {
+			b.l.Unlock()
+			return
+		}
+		m := q.msgs[0]
+		q.msgs[0] = PubSubMessage{}
+		q.msgs = q.msgs[1:]
+		b.cond.Broadcast()
+		b.l.Unlock()
+
+		select {
+		case msgCh <- m:
+		case <-q.stopCh:
+			return
+		case <-b.closeCh:
+			return
+		}
+	}
+}
+
+// remove stops the forwarding goroutine of msgCh, if there is one, discarding
+// any messages which are still queued for it. It's called once msgCh is no
+// longer subscribed to anything, after which it may no longer be read from.
+func (b *pubSubBuffer) remove(msgCh chan<- PubSubMessage) {
+	b.l.Lock()
+	defer b.l.Unlock()
+	q, ok := b.queues[msgCh]
+	if !ok {
+		return
+	}
+	delete(b.queues, msgCh)
+	q.removed = true
+	q.msgs = nil
+	close(q.stopCh)
+	b.cond.Broadcast()
+}
+
+// push queues m to be written to msgCh, applying the overflow policy if the
+// queue is full. It only returns ErrPubSubOverflow, and only when using
+// PubSubOverflowClose.
+func (b *pubSubBuffer) push(msgCh chan<- PubSubMessage, m PubSubMessage) error {
+	b.l.Lock()
+	defer b.l.Unlock()
+
+	q := b.queue(msgCh)
+	for len(q.msgs) >= b.size && !b.closed {
+		switch b.policy {
+		case PubSubOverflowDropOldest:
+			b.logDropped(q.msgs[0])
+			q.msgs[0] = PubSubMessage{}
+			q.msgs = q.msgs[1:]
+		case PubSubOverflowDropNew:
+			b.logDropped(m)
+			return nil
+		case PubSubOverflowClose:
+			return ErrPubSubOverflow
+		default:
+			b.cond.Wait()
+		}
+	}
+
+	b.pushInner(q, m)
+	return nil
+}
+
+func (b *pubSubBuffer) logDropped(m PubSubMessage) {
+	logEvent(b.logger, LogLevelWarn, "dropped pubsub message due to full buffer", "channel", m.Channel)
+}
+
+// pushAlways is like push, but queues m even if the queue is full. It's used
+// for messages which mustn't be lost, regardless of the overflow policy.
+func (b *pubSubBuffer) pushAlways(msgCh chan<- PubSubMessage, m PubSubMessage) {
+	b.l.Lock()
+	defer b.l.Unlock()
+	b.pushInner(b.queue(msgCh), m)
+}
+
+// l must be held.
+func (b *pubSubBuffer) pushInner(q *pubSubQueue, m PubSubMessage) {
+	if b.closed {
+		return
+	}
+	q.msgs = append(q.msgs, m)
+	b.cond.Broadcast()
+}
+
+// close stops all forwarding goroutines, discarding any messages which are
+// still queued.
+func (b *pubSubBuffer) close() {
+	b.l.Lock()
+	if !b.closed {
+		b.closed = true
+		close(b.closeCh)
+		b.cond.Broadcast()
+	}
+	b.l.Unlock()
+	b.wg.Wait()
+}
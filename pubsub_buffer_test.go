@@ -0,0 +1,91 @@
+package radix
+
+import (
+	"strconv"
+	. "testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPubSubBuffer(t *T) {
+	msg := func(i int) PubSubMessage {
+		return PubSubMessage{Type: "message", Channel: "foo", Message: []byte(strconv.Itoa(i))}
+	}
+
+	// setup returns a pubSubBuffer of size 2 whose forwarding goroutine for
+	// the returned msgCh is blocked writing msg(0)
+	setup := func(policy PubSubOverflowPolicy) (*pubSubBuffer, chan PubSubMessage) {
+		b := newPubSubBuffer(2, policy)
+		msgCh := make(chan PubSubMessage)
+		require.Nil(t, b.push(msgCh, msg(0)))
+		for {
+			b.l.Lock()
+			n := len(b.queues[msgCh].msgs)
+			b.l.Unlock()
+			if n == 0 {
+				return b, msgCh
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	assertRead := func(msgCh chan PubSubMessage, is ...int) {
+		for _, i := range is {
+			assert.Equal(t, msg(i), assertMsgRead(t, msgCh))
+		}
+	}
+
+	t.Run("Block", func(t *T) {
+		b, msgCh := setup(PubSubOverflowBlock)
+		defer b.close()
+		require.Nil(t, b.push(msgCh, msg(1)))
+		require.Nil(t, b.push(msgCh, msg(2)))
+
+		pushedCh := make(chan error)
+		go func() { pushedCh <- b.push(msgCh, msg(3)) }()
+		select {
+		case <-pushedCh:
+			t.Fatal("push didn't block")
+		case <-time.After(50 * time.Millisecond):
+		}
+
+		assertRead(msgCh, 0)
+		require.Nil(t, <-pushedCh)
+		assertRead(msgCh, 1, 2, 3)
+	})
+
+	t.Run("DropOldest", func(t *T) {
+		b, msgCh := setup(PubSubOverflowDropOldest)
+		defer b.close()
+		for i := 1; i <= 4; i++ {
+			require.Nil(t, b.push(msgCh, msg(i)))
+		}
+		assertRead(msgCh, 0, 3, 4)
+	})
+
+	t.Run("DropNew", func(t *T) {
+		b, msgCh := setup(PubSubOverflowDropNew)
+		defer b.close()
+		var l testLogger
+		b.logger = &l
+		for i := 1; i <= 4; i++ {
+			require.Nil(t, b.push(msgCh, msg(i)))
+		}
+		assertRead(msgCh, 0, 1, 2)
+		assert.Len(t, l.withMsg("dropped pubsub message due to full buffer"), 2)
+	})
+
+	t.Run("Close", func(t *T) {
+		b, msgCh := setup(PubSubOverflowClose)
+		defer b.close()
+		require.Nil(t, b.push(msgCh, msg(1)))
+		require.Nil(t, b.push(msgCh, msg(2)))
+		assert.Equal(t, ErrPubSubOverflow, b.push(msgCh, msg(3)))
+
+		// pushAlways ignores the policy
+		b.pushAlways(msgCh, msg(4))
+		assertRead(msgCh, 0, 1, 2, 4)
+	})
+}
@@ -4,11 +4,17 @@ import (
 	"fmt"
 	"sync"
 	"time"
+
+	errors "golang.org/x/xerrors"
 )
 
 type persistentPubSubOpts struct {
-	connFn     ConnFunc
-	abortAfter int
+	connFn          ConnFunc
+	abortAfter      int
+	notifyReconnect bool
+	bufSize         int
+	bufPolicy       PubSubOverflowPolicy
+	logger          Logger
 }
 
 // PersistentPubSubOpt is an optional parameter which can be passed into
@@ -37,10 +43,62 @@ func PersistentPubSubAbortAfter(attempts int) PersistentPubSubOpt {
 	}
 }
 
+// PersistentPubSubNotifyReconnect causes PersistentPubSub to write a
+// PubSubMessage with Type "reconnected", and no other fields set, to every
+// subscribed message channel each time it has reconnected and re-subscribed
+// after its connection was lost. Any messages published while the connection
+// was down will not have been received, so this can be used by consumers to
+// detect a possible gap in messages, e.g. in order to reload state from
+// elsewhere.
+//
+// As with messages, the PersistentPubSub blocks until each "reconnected" message
+// has been read, so all subscribed message channels should be continuously
+// read from.
+func PersistentPubSubNotifyReconnect() PersistentPubSubOpt {
+	return func(opts *persistentPubSubOpts) {
+		opts.notifyReconnect = true
+	}
+}
+
+// PersistentPubSubBuffer causes PersistentPubSub to buffer up to size messages
+// for each message channel, rather than writing messages to message channels
+// directly from the goroutine which reads them off the connection. This way a
+// single slow consumer doesn't hold up the delivery of messages to other
+// message channels, nor cause the connection to stop being read.
+//
+// policy determines what happens when a message is received for a message
+// channel whose buffer is full. With PubSubOverflowClose the PersistentPubSub
+// will stop reconnecting and close itself, and all of its methods will return
+// ErrPubSubOverflow. Messages written due to PersistentPubSubNotifyReconnect are
+// always buffered, regardless of policy.
+//
+// Any messages still in a message channel's buffer when it's unsubscribed from
+// its last channel or pattern, or when the PersistentPubSub is closed, are
+// discarded.
+func PersistentPubSubBuffer(size int, policy PubSubOverflowPolicy) PersistentPubSubOpt {
+	return func(opts *persistentPubSubOpts) {
+		opts.bufSize = size
+		opts.bufPolicy = policy
+	}
+}
+
+// PersistentPubSubLogger causes PersistentPubSub to log events using the given
+// Logger. It logs warnings when its connection is lost and when reconnecting
+// fails, logs when it has reconnected, and logs an error when it gives up on
+// reconnecting, or closes itself due to PubSubOverflowClose. When
+// PersistentPubSubBuffer is used with one of the PubSubOverflowDrop policies it
+// also logs a warning for each message which is dropped.
+func PersistentPubSubLogger(l Logger) PersistentPubSubOpt {
+	return func(opts *persistentPubSubOpts) {
+		opts.logger = l
+	}
+}
+
 type pubSubCmd struct {
 	// msgCh can be set along with one of subscribe/unsubscribe/etc...
 	msgCh                                            chan<- PubSubMessage
 	subscribe, unsubscribe, psubscribe, punsubscribe []string
+	ssubscribe, sunsubscribe                         []string
 
 	// ... or one of ping or close can be set
 	ping, close bool
@@ -53,11 +111,22 @@ type persistentPubSub struct {
 	dial func() (Conn, error)
 	opts persistentPubSubOpts
 
-	subs, psubs chanSet
+	subs, psubs, ssubs chanSet
+	buf                *pubSubBuffer
 
-	curr      PubSubConn
+	curr      ShardedPubSubConn
 	currErrCh chan error
 
+	// droppedL guards dropped, which holds the shard channels which redis has
+	// unsubscribed curr from. It's separate from cmdCh since it's written to
+	// from within curr, and droppedCh is used to wake up spin when it is.
+	droppedL  sync.Mutex
+	dropped   map[string]bool
+	droppedCh chan struct{}
+
+	// set if the PersistentPubSub was closed due to ErrPubSubOverflow
+	overflowErr error
+
 	cmdCh chan pubSubCmd
 
 	closeErr  error
@@ -71,7 +140,10 @@ type persistentPubSub struct {
 //
 // This is effectively a way to have a permanent PubSubConn established which
 // supports subscribing/unsubscribing but without the hassle of implementing
-// reconnect/re-subscribe logic.
+// reconnect/re-subscribe logic. See PersistentPubSubNotifyReconnect for being
+// notified when a reconnect has happened. The returned PubSubConn is also a
+// ShardedPubSubConn, and if redis unsubscribes it from a shard channel on its
+// own then it will subscribe to that channel again.
 //
 // With default options, neither this function nor any of the methods on the
 // returned PubSubConn will ever return an error, they will instead block until
@@ -99,9 +171,20 @@ func PersistentPubSubWithOpts(
 		opts:  opts,
 		subs:  chanSet{},
 		psubs: chanSet{},
+		ssubs: chanSet{},
 		cmdCh: make(chan pubSubCmd),
+
+		dropped:   map[string]bool{},
+		droppedCh: make(chan struct{}, 1),
+	}
+	if opts.bufSize > 0 {
+		p.buf = newPubSubBuffer(opts.bufSize, opts.bufPolicy)
+		p.buf.logger = opts.logger
 	}
 	if err := p.refresh(); err != nil {
+		if p.buf != nil {
+			p.buf.close()
+		}
 		return nil, err
 	}
 	go p.spin()
@@ -123,22 +206,27 @@ func PersistentPubSub(network, addr string, connFn ConnFunc) PubSubConn {
 	return p
 }
 
-// refresh only returns an error if the connection could not be made
+// refresh only returns an error if the connection could not be made, or if the
+// previous connection was closed due to ErrPubSubOverflow
 func (p *persistentPubSub) refresh() error {
+	reconnecting := p.curr != nil
 	if p.curr != nil {
 		p.curr.Close()
-		<-p.currErrCh
+		if err := <-p.currErrCh; errors.Is(err, ErrPubSubOverflow) {
+			p.overflowed(err)
+			return err
+		}
 		p.curr = nil
 		p.currErrCh = nil
 	}
 
-	attempt := func() (PubSubConn, chan error, error) {
+	attempt := func() (ShardedPubSubConn, chan error, error) {
 		c, err := p.dial()
 		if err != nil {
 			return nil, nil, err
 		}
 		errCh := make(chan error, 1)
-		pc := newPubSub(c, errCh)
+		pc := newPubSub(c, errCh, p.buf, p.sunsubscribed)
 
 		for msgCh, channels := range p.subs.inverse() {
 			if err := pc.Subscribe(msgCh, channels...); err != nil {
@@ -153,6 +241,13 @@ func (p *persistentPubSub) refresh() error {
 				return nil, nil, err
 			}
 		}
+
+		for msgCh, channels := range p.ssubs.inverse() {
+			if err := pc.SSubscribe(msgCh, channels...); err != nil {
+				pc.Close()
+				return nil, nil, err
+			}
+		}
 		return pc, errCh, nil
 	}
 
@@ -160,18 +255,106 @@ func (p *persistentPubSub) refresh() error {
 	for {
 		var err error
 		if p.curr, p.currErrCh, err = attempt(); err == nil {
+			if reconnecting {
+				p.log(LogLevelInfo, "reconnected", "attempts", attempts+1)
+			}
+			p.notifyReconnect()
 			return nil
 		}
 		attempts++
 		if p.opts.abortAfter > 0 && attempts >= p.opts.abortAfter {
+			p.log(LogLevelError, "giving up on connecting", "attempts", attempts, "err", err)
 			return err
 		}
+		p.log(LogLevelWarn, "failed to connect", "attempts", attempts, "err", err)
 		time.Sleep(200 * time.Millisecond)
 	}
 }
 
-func (p *persistentPubSub) execCmd(cmd pubSubCmd) error {
+// sunsubscribed is called by curr when redis has unsubscribed it from a shard
+// channel, e.g. because the channel's slot was migrated. It mustn't block, as
+// it's called from within curr.
+func (p *persistentPubSub) sunsubscribed(channel string) {
+	p.droppedL.Lock()
+	p.dropped[channel] = true
+	p.droppedL.Unlock()
+
+	select {
+	case p.droppedCh <- struct{}{}:
+	default:
+	}
+}
+
+// resubscribeDropped subscribes curr again to every shard channel which redis
+// unsubscribed it from, and which is still meant to be subscribed to.
+func (p *persistentPubSub) resubscribeDropped() {
+	p.droppedL.Lock()
+	dropped := p.dropped
+	p.dropped = map[string]bool{}
+	p.droppedL.Unlock()
+
 	if p.curr == nil {
+		return
+	}
+
+	for channel := range dropped {
+		for msgCh := range p.ssubs[channel] {
+			if err := p.curr.SSubscribe(msgCh, channel); errors.Is(err, ErrPubSubOverflow) {
+				p.overflowed(err)
+				return
+			} else if err != nil {
+				// refresh subscribes to all channels in ssubs, including the
+				// dropped ones
+				p.log(LogLevelWarn, "failed to re-subscribe to shard channel", "channel", channel, "err", err)
+				p.refresh()
+				return
+			}
+		}
+	}
+}
+
+func (p *persistentPubSub) log(level LogLevel, msg string, keyvals ...interface{}) {
+	logEvent(p.opts.logger, level, msg, keyvals...)
+}
+
+// notifyReconnect writes a "reconnected" PubSubMessage to every subscribed
+// msgCh, if PersistentPubSubNotifyReconnect was given.
+func (p *persistentPubSub) notifyReconnect() {
+	if !p.opts.notifyReconnect {
+		return
+	}
+
+	msgChs := map[chan<- PubSubMessage]bool{}
+	for _, cs := range []chanSet{p.subs, p.psubs, p.ssubs} {
+		for msgCh := range cs.inverse() {
+			msgChs[msgCh] = true
+		}
+	}
+	for msgCh := range msgChs {
+		if p.buf != nil {
+			p.buf.pushAlways(msgCh, PubSubMessage{Type: "reconnected"})
+		} else {
+			msgCh <- PubSubMessage{Type: "reconnected"}
+		}
+	}
+}
+
+// overflowed is called when the current connection was closed due to
+// ErrPubSubOverflow, after which the PersistentPubSub no longer reconnects.
+func (p *persistentPubSub) overflowed(err error) {
+	p.log(LogLevelError, "closing due to full message buffer")
+	p.overflowErr = err
+	p.curr = nil
+	p.currErrCh = nil
+}
+
+func (p *persistentPubSub) execCmd(cmd pubSubCmd) error {
+	if p.overflowErr != nil {
+		if cmd.close {
+			return nil
+		}
+		return p.overflowErr
+	} else if p.curr == nil {
 		if err := p.refresh(); err != nil {
 			return err
 		}
@@ -206,6 +389,18 @@ func (p *persistentPubSub) execCmd(cmd pubSubCmd) error {
 		}
 		err = p.curr.PUnsubscribe(cmd.msgCh, cmd.punsubscribe...)
 
+	case len(cmd.ssubscribe) > 0:
+		for _, channel := range cmd.ssubscribe {
+			p.ssubs.add(channel, cmd.msgCh)
+		}
+		err = p.curr.SSubscribe(cmd.msgCh, cmd.ssubscribe...)
+
+	case len(cmd.sunsubscribe) > 0:
+		for _, channel := range cmd.sunsubscribe {
+			p.ssubs.del(channel, cmd.msgCh)
+		}
+		err = p.curr.SUnsubscribe(cmd.msgCh, cmd.sunsubscribe...)
+
 	case cmd.ping:
 		err = p.curr.Ping()
 
@@ -219,19 +414,41 @@ func (p *persistentPubSub) execCmd(cmd pubSubCmd) error {
 		// don't do anything I guess
 	}
 
-	if err != nil {
+	// once a msgCh is no longer subscribed to anything its buffer is dropped,
+	// so that messages stop being written to it
+	unsubscribing := len(cmd.unsubscribe) > 0 || len(cmd.punsubscribe) > 0 || len(cmd.sunsubscribe) > 0
+	if p.buf != nil && unsubscribing && !p.subscribed(cmd.msgCh) {
+		p.buf.remove(cmd.msgCh)
+	}
+
+	if errors.Is(err, ErrPubSubOverflow) {
+		p.overflowed(err)
+		return err
+	} else if err != nil {
 		return p.refresh()
 	}
 	return nil
 }
 
+// subscribed returns true if msgCh is subscribed to any channel or pattern.
+func (p *persistentPubSub) subscribed(msgCh chan<- PubSubMessage) bool {
+	return p.subs.hasChan(msgCh) || p.psubs.hasChan(msgCh) || p.ssubs.hasChan(msgCh)
+}
+
 func (p *persistentPubSub) spin() {
 	for {
 		select {
-		case <-p.currErrCh:
-			// TODO if refresh fails here the user will never know the error. It
-			// would be good to make that error available somehow.
+		case err := <-p.currErrCh:
+			if errors.Is(err, ErrPubSubOverflow) {
+				p.overflowed(err)
+				continue
+			}
+			// if refresh fails here the error is only logged, it will be
+			// returned from the next method call which refreshes again.
+			p.log(LogLevelWarn, "connection lost", "err", err)
 			p.refresh()
+		case <-p.droppedCh:
+			p.resubscribeDropped()
 		case cmd := <-p.cmdCh:
 			cmd.resCh <- p.execCmd(cmd)
 			if cmd.close {
@@ -275,6 +492,20 @@ func (p *persistentPubSub) PUnsubscribe(msgCh chan<- PubSubMessage, channels ...
 	})
 }
 
+func (p *persistentPubSub) SSubscribe(msgCh chan<- PubSubMessage, channels ...string) error {
+	return p.cmd(pubSubCmd{
+		msgCh:      msgCh,
+		ssubscribe: channels,
+	})
+}
+
+func (p *persistentPubSub) SUnsubscribe(msgCh chan<- PubSubMessage, channels ...string) error {
+	return p.cmd(pubSubCmd{
+		msgCh:        msgCh,
+		sunsubscribe: channels,
+	})
+}
+
 func (p *persistentPubSub) Ping() error {
 	return p.cmd(pubSubCmd{ping: true})
 }
@@ -282,6 +513,9 @@ func (p *persistentPubSub) Ping() error {
 func (p *persistentPubSub) Close() error {
 	p.closeOnce.Do(func() {
 		p.closeErr = p.cmd(pubSubCmd{close: true})
+		if p.buf != nil {
+			p.buf.close()
+		}
 	})
 	return p.closeErr
 }
@@ -1,10 +1,12 @@
 package radix
 
 import (
+	"strconv"
 	. "testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	errors "golang.org/x/xerrors"
 )
 
@@ -70,6 +72,124 @@ func TestPersistentPubSubAbortAfter(t *T) {
 	p.Close()
 }
 
+func TestPersistentPubSubNotifyReconnect(t *T) {
+	connCh := make(chan Conn, 2)
+	p, err := PersistentPubSubWithOpts("", "",
+		PersistentPubSubConnFunc(func(_, _ string) (Conn, error) {
+			c := dial()
+			connCh <- c
+			return c, nil
+		}),
+		PersistentPubSubNotifyReconnect(),
+	)
+	require.NoError(t, err)
+	defer p.Close()
+
+	pubC := dial()
+	defer pubC.Close()
+
+	ch, msgStr := randStr(), randStr()
+	msgCh := make(chan PubSubMessage, 1)
+	require.NoError(t, p.Subscribe(msgCh, ch))
+
+	// the initial connection isn't a reconnect
+	publish(t, pubC, ch, msgStr)
+	assert.Equal(t, "message", assertMsgRead(t, msgCh).Type)
+
+	(<-connCh).Close()
+	assert.Equal(t, PubSubMessage{Type: "reconnected"}, assertMsgRead(t, msgCh))
+	<-connCh
+
+	publish(t, pubC, ch, msgStr)
+	assert.Equal(t, PubSubMessage{
+		Type:    "message",
+		Channel: ch,
+		Message: []byte(msgStr),
+	}, assertMsgRead(t, msgCh))
+}
+
+func TestPersistentPubSubBuffer(t *T) {
+	p, err := PersistentPubSubWithOpts("", "",
+		PersistentPubSubConnFunc(func(_, _ string) (Conn, error) {
+			return dial(), nil
+		}),
+		PersistentPubSubBuffer(2, PubSubOverflowClose),
+	)
+	require.NoError(t, err)
+	defer p.Close()
+
+	pubC := dial()
+	defer pubC.Close()
+
+	// a slow consumer shouldn't hold up another one
+	ch := randStr()
+	slowCh, fastCh := make(chan PubSubMessage), make(chan PubSubMessage)
+	require.NoError(t, p.Subscribe(slowCh, ch))
+	require.NoError(t, p.Subscribe(fastCh, ch))
+	for i := 0; i < 3; i++ {
+		publish(t, pubC, ch, strconv.Itoa(i))
+		assert.Equal(t, strconv.Itoa(i), string(assertMsgRead(t, fastCh).Message))
+	}
+
+	// slowCh's buffer is now full, so the next message closes the
+	// PersistentPubSub
+	publish(t, pubC, ch, "3")
+	deadline := time.Now().Add(5 * time.Second)
+	for p.Ping() == nil {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for overflow")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Equal(t, ErrPubSubOverflow, p.Ping())
+	assert.Equal(t, ErrPubSubOverflow, p.Subscribe(fastCh, randStr()))
+	assert.Equal(t, "0", string(assertMsgRead(t, slowCh).Message))
+}
+
+func TestPersistentPubSubBufferUnsubscribe(t *T) {
+	p, err := PersistentPubSubWithOpts("", "",
+		PersistentPubSubConnFunc(func(_, _ string) (Conn, error) {
+			return dial(), nil
+		}),
+		PersistentPubSubBuffer(2, PubSubOverflowDropNew),
+	)
+	require.NoError(t, err)
+	defer p.Close()
+	buf := p.(*persistentPubSub).buf
+
+	pubC := dial()
+	defer pubC.Close()
+
+	// msgCh isn't read from, so one message is held by its forwarding
+	// goroutine and one is queued
+	ch1, ch2 := randStr(), randStr()
+	msgCh := make(chan PubSubMessage)
+	require.NoError(t, p.Subscribe(msgCh, ch1))
+	require.NoError(t, p.PSubscribe(msgCh, ch2))
+	publish(t, pubC, ch1, "0")
+	publish(t, pubC, ch1, "1")
+	queued := func() int {
+		buf.l.Lock()
+		defer buf.l.Unlock()
+		if q, ok := buf.queues[msgCh]; ok {
+			return len(q.msgs)
+		}
+		return -1
+	}
+	waitFor(t, func() bool { return queued() == 1 })
+
+	// msgCh is still subscribed to a pattern, so its buffer is kept
+	require.NoError(t, p.Unsubscribe(msgCh, ch1))
+	assert.Equal(t, 1, queued())
+
+	// once it's unsubscribed from everything its buffer is dropped, and
+	// nothing more is written to it
+	require.NoError(t, p.PUnsubscribe(msgCh, ch2))
+	assert.Equal(t, -1, queued())
+	time.Sleep(50 * time.Millisecond)
+	assertMsgNoRead(t, msgCh)
+}
+
 // https://github.com/mediocregopher/radix/issues/184
 func TestPersistentPubSubClose(t *T) {
 	channel := "TestPersistentPubSubClose:" + randStr()
@@ -106,3 +226,38 @@ func TestPersistentPubSubClose(t *T) {
 		close(msgCh)
 	}
 }
+
+func TestPersistentPubSubSUnsubscribed(t *T) {
+	stub, stubCh := PubSubStub("tcp", "127.0.0.1:6379", func([]string) interface{} {
+		return nil
+	})
+	p, err := PersistentPubSubWithOpts("", "",
+		PersistentPubSubConnFunc(func(_, _ string) (Conn, error) {
+			return stub, nil
+		}),
+		PersistentPubSubAbortAfter(1),
+	)
+	require.NoError(t, err)
+	defer p.Close()
+
+	sp := p.(ShardedPubSubConn)
+	msgCh := make(chan PubSubMessage, 1)
+	require.NoError(t, sp.SSubscribe(msgCh, "foo"))
+
+	// redis unsubscribing the connection itself should cause the channel to be
+	// subscribed to again
+	stubCh <- PubSubMessage{Type: "sunsubscribe", Channel: "foo"}
+	waitFor(t, func() bool {
+		select {
+		case stubCh <- PubSubMessage{Type: "smessage", Channel: "foo", Message: []byte("a")}:
+		case <-time.After(50 * time.Millisecond):
+			return false
+		}
+		select {
+		case m := <-msgCh:
+			return assert.Equal(t, "foo", m.Channel)
+		case <-time.After(50 * time.Millisecond):
+			return false
+		}
+	})
+}
@@ -36,9 +36,9 @@ type pubSubStub struct {
 	closeCh   chan struct{}
 	closeErr  error
 
-	l               sync.Mutex
-	pubsubMode      bool
-	subbed, psubbed map[string]bool
+	l                        sync.Mutex
+	pubsubMode               bool
+	subbed, psubbed, ssubbed map[string]bool
 
 	// this is only used for tests
 	mDoneCh chan struct{}
@@ -48,13 +48,17 @@ type pubSubStub struct {
 // Conn to a real redis instance, but is instead using the given callback to
 // service requests. It is primarily useful for writing tests.
 //
-// PubSubStub differes from Stub in that Encode calls for (P)SUBSCRIBE,
-// (P)UNSUBSCRIBE, MESSAGE, and PING will be intercepted and handled as per
+// PubSubStub differes from Stub in that Encode calls for (P|S)SUBSCRIBE,
+// (P|S)UNSUBSCRIBE, MESSAGE, and PING will be intercepted and handled as per
 // redis' expected pubsub functionality. A PubSubMessage may be written to the
-// returned channel at any time, and if the PubSubStub has had (P)SUBSCRIBE
+// returned channel at any time, and if the PubSubStub has had (P|S)SUBSCRIBE
 // called matching that PubSubMessage it will be written to the PubSubStub's
 // internal buffer as expected.
 //
+// A PubSubMessage with Type "sunsubscribe" may also be written to the channel,
+// in which case the PubSubStub unsubscribes itself from the message's Channel,
+// as redis does when a shard channel's slot is migrated away.
+//
 // This is intended to be used so that it can mock services which can perform
 // both normal redis commands and pubsub (e.g. a real redis instance, redis
 // sentinel). Once created this stub can be passed into PubSub and treated like
@@ -67,6 +71,7 @@ func PubSubStub(remoteNetwork, remoteAddr string, fn func([]string) interface{})
 		closeCh: make(chan struct{}),
 		subbed:  map[string]bool{},
 		psubbed: map[string]bool{},
+		ssubbed: map[string]bool{},
 		mDoneCh: make(chan struct{}, 1),
 	}
 	s.Conn = Stub(remoteNetwork, remoteAddr, s.innerFn)
@@ -79,7 +84,7 @@ func (s *pubSubStub) innerFn(ss []string) interface{} {
 	defer s.l.Unlock()
 
 	writeRes := func(mm multiMarshal, cmd, subj string) multiMarshal {
-		c := len(s.subbed) + len(s.psubbed)
+		c := len(s.subbed) + len(s.psubbed) + len(s.ssubbed)
 		s.pubsubMode = c > 0
 		return append(mm, resp2.Any{I: []interface{}{cmd, subj, c}})
 	}
@@ -118,6 +123,20 @@ func (s *pubSubStub) innerFn(ss []string) interface{} {
 			mm = writeRes(mm, "punsubscribe", pattern)
 		}
 		return mm
+	case "SSUBSCRIBE":
+		var mm multiMarshal
+		for _, channel := range ss[1:] {
+			s.ssubbed[channel] = true
+			mm = writeRes(mm, "ssubscribe", channel)
+		}
+		return mm
+	case "SUNSUBSCRIBE":
+		var mm multiMarshal
+		for _, channel := range ss[1:] {
+			delete(s.ssubbed, channel)
+			mm = writeRes(mm, "sunsubscribe", channel)
+		}
+		return mm
 	case "MESSAGE":
 		m := PubSubMessage{
 			Type:    "message",
@@ -130,6 +149,18 @@ func (s *pubSubStub) innerFn(ss []string) interface{} {
 			mm = append(mm, m)
 		}
 		return mm
+	case "SMESSAGE":
+		m := PubSubMessage{
+			Type:    "smessage",
+			Channel: ss[1],
+			Message: []byte(ss[2]),
+		}
+
+		var mm multiMarshal
+		if s.ssubbed[m.Channel] {
+			mm = append(mm, m)
+		}
+		return mm
 	case "PMESSAGE":
 		m := PubSubMessage{
 			Type:    "pmessage",
@@ -166,14 +197,23 @@ func (s *pubSubStub) spin() {
 			if !ok {
 				panic("PubSubStub message channel was closed")
 			}
+			var mm resp.Marshaler = m
 			if m.Type == "" {
 				if m.Pattern == "" {
 					m.Type = "message"
 				} else {
 					m.Type = "pmessage"
 				}
+				mm = m
+			} else if m.Type == "sunsubscribe" {
+				s.l.Lock()
+				delete(s.ssubbed, m.Channel)
+				c := len(s.subbed) + len(s.psubbed) + len(s.ssubbed)
+				s.pubsubMode = c > 0
+				s.l.Unlock()
+				mm = resp2.Any{I: []interface{}{m.Type, m.Channel, c}}
 			}
-			if err := s.Conn.Encode(m); err != nil {
+			if err := s.Conn.Encode(mm); err != nil {
 				panic(fmt.Sprintf("error encoding message in PubSubStub: %s", err))
 			}
 			select {
@@ -205,6 +205,55 @@ func TestPubSubPSubscribe(t *T) {
 	assertMsgNoRead(t, msgCh)
 }
 
+func TestPubSubSSubscribe(t *T) {
+	stub, stubCh := PubSubStub("tcp", "127.0.0.1:6379", func([]string) interface{} {
+		return nil
+	})
+	smessage := func(channel, val string) {
+		stubCh <- PubSubMessage{Type: "smessage", Channel: channel, Message: []byte(val)}
+	}
+
+	var unsubbed []string
+	unsubbedCh := make(chan struct{}, 1)
+	c := newPubSub(stub, nil, nil, func(channel string) {
+		unsubbed = append(unsubbed, channel)
+		unsubbedCh <- struct{}{}
+	})
+	defer c.Close()
+
+	msgCh := make(chan PubSubMessage, 1)
+	require.Nil(t, c.SSubscribe(msgCh, "foo", "bar", "baz"))
+
+	smessage("foo", "a")
+	assert.Equal(t, PubSubMessage{
+		Type:    "smessage",
+		Channel: "foo",
+		Message: []byte("a"),
+	}, assertMsgRead(t, msgCh))
+
+	require.Nil(t, c.SUnsubscribe(msgCh, "foo"))
+	assert.Empty(t, unsubbed)
+	smessage("foo", "b")
+	smessage("bar", "c")
+	assert.Equal(t, "bar", assertMsgRead(t, msgCh).Channel)
+
+	// redis unsubscribing the connection itself should drop the subscription
+	// and call the hook, without disrupting the connection
+	stubCh <- PubSubMessage{Type: "sunsubscribe", Channel: "bar"}
+	select {
+	case <-unsubbedCh:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for unsolicited sunsubscribe")
+	}
+	assert.Equal(t, []string{"bar"}, unsubbed)
+	require.Nil(t, c.Ping())
+
+	smessage("bar", "d")
+	smessage("baz", "e")
+	assert.Equal(t, "baz", assertMsgRead(t, msgCh).Channel)
+	assertMsgNoRead(t, msgCh)
+}
+
 func TestPubSubMixedSubscribe(t *T) {
 	pubC := dial()
 	defer pubC.Close()
@@ -0,0 +1,97 @@
+package radix
+
+import (
+	"bufio"
+
+	errors "golang.org/x/xerrors"
+
+	"github.com/mediocregopher/radix/v3/resp"
+	"github.com/mediocregopher/radix/v3/resp/resp2"
+)
+
+// PushMessage describes an out-of-band push message which redis may send on a
+// RESP3 connection, for example an invalidation message when CLIENT TRACKING
+// is enabled without REDIRECT. See DialPushHandler.
+type PushMessage struct {
+	// Kind is the first element of the push message, e.g. "invalidate" or
+	// "message".
+	Kind string
+
+	// Data contains the remaining elements of the push message, each decoded
+	// the same way Cmd decodes a reply into an interface{}. For example, the
+	// Data of an "invalidate" message is a single []interface{} containing the
+	// invalidated keys as []byte, or nil if all keys were invalidated.
+	Data []interface{}
+}
+
+// UnmarshalRESP implements the resp.Unmarshaler interface.
+func (pm *PushMessage) UnmarshalRESP(br *bufio.Reader) error {
+	var ah resp2.ArrayHeader
+	if err := ah.UnmarshalRESP(br); err != nil {
+		return err
+	} else if ah.N < 1 {
+		return resp.ErrDiscarded{Err: errors.New("push message has no kind")}
+	}
+
+	var kind string
+	if err := (resp2.Any{I: &kind}).UnmarshalRESP(br); err != nil {
+		return err
+	}
+
+	data := make([]interface{}, ah.N-1)
+	for i := range data {
+		if err := (resp2.Any{I: &data[i]}).UnmarshalRESP(br); err != nil {
+			return err
+		}
+	}
+
+	*pm = PushMessage{Kind: kind, Data: data}
+	return nil
+}
+
+// DialPushHandler will cause the Conn created by Dial to call fn for each push
+// message of the given kind which it receives, rather than interpreting push
+// messages as the reply to whichever command is being read. If kind is empty
+// then fn is called for push messages of any kind which doesn't have its own
+// handler. DialPushHandler may be given multiple times, once for each kind.
+//
+// Once any push handler has been given, all push messages received by the Conn
+// are consumed by it, and those without a handler are discarded. Push messages
+// are only read while the Conn is reading a reply, so one which arrives while
+// the Conn is idle is handled once the next reply is read. fn is called from
+// the goroutine reading the reply, and must not use the Conn itself.
+//
+// Since subscribed RESP3 connections receive their messages as push messages,
+// a Conn with push handlers should not be used with PubSub.
+func DialPushHandler(kind string, fn func(PushMessage)) DialOpt {
+	return func(do *dialOpts) {
+		if do.pushHandlers == nil {
+			do.pushHandlers = map[string]func(PushMessage){}
+		}
+		do.pushHandlers[kind] = fn
+	}
+}
+
+// handlePushes reads and dispatches any push messages which precede the next
+// reply on the connection.
+func (cw *connWrap) handlePushes() error {
+	for {
+		b, err := cw.brw.Reader.Peek(1)
+		if err != nil {
+			return err
+		} else if b[0] != resp2.PushPrefix[0] {
+			return nil
+		}
+
+		var pm PushMessage
+		if err := pm.UnmarshalRESP(cw.brw.Reader); err != nil {
+			return err
+		}
+
+		if fn := cw.pushHandlers[pm.Kind]; fn != nil {
+			fn(pm)
+		} else if fn := cw.pushHandlers[""]; fn != nil {
+			fn(pm)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package radix
+
+import (
+	"bufio"
+	"net"
+	. "testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mediocregopher/radix/v3/resp/resp2"
+)
+
+func TestPushHandler(t *T) {
+	client, server := net.Pipe()
+	defer server.Close()
+
+	var do dialOpts
+	var invalidated, other []PushMessage
+	DialPushHandler("invalidate", func(pm PushMessage) {
+		invalidated = append(invalidated, pm)
+	})(&do)
+	DialPushHandler("", func(pm PushMessage) {
+		other = append(other, pm)
+	})(&do)
+
+	conn := NewConn(client)
+	conn.(*connWrap).pushHandlers = do.pushHandlers
+	defer conn.Close()
+
+	// the server replies to each command with some push messages preceding
+	// the actual reply
+	go func() {
+		br := bufio.NewReader(server)
+		for {
+			var cmd []string
+			if err := (resp2.Any{I: &cmd}).UnmarshalRESP(br); err != nil {
+				return
+			}
+			server.Write([]byte(">2\r\n$10\r\ninvalidate\r\n*2\r\n$3\r\nfoo\r\n$3\r\nbar\r\n"))
+			server.Write([]byte(">3\r\n+message\r\n$2\r\nch\r\n$2\r\nhi\r\n"))
+			(resp2.BulkString{S: cmd[1]}).MarshalRESP(server)
+		}
+	}()
+
+	var out string
+	require.Nil(t, conn.Do(Cmd(&out, "ECHO", "a")))
+	assert.Equal(t, "a", out)
+	require.Nil(t, conn.Do(Cmd(&out, "ECHO", "b")))
+	assert.Equal(t, "b", out)
+
+	expInvalidate := PushMessage{
+		Kind: "invalidate",
+		Data: []interface{}{[]interface{}{[]byte("foo"), []byte("bar")}},
+	}
+	assert.Equal(t, []PushMessage{expInvalidate, expInvalidate}, invalidated)
+
+	expOther := PushMessage{
+		Kind: "message",
+		Data: []interface{}{[]byte("ch"), []byte("hi")},
+	}
+	assert.Equal(t, []PushMessage{expOther, expOther}, other)
+}
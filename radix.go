@@ -65,13 +65,84 @@
 //	}
 //
 // The same rules for field naming apply when a struct is passed into FlatCmd as
-// an argument.
+// an argument. When doing so the "omitempty" option may also be given in the
+// tag, in which case the field will not be passed if it's empty. Empty follows
+// the same rules as encoding/json, with the addition that structs with an
+// IsZero method, such as time.Time, are empty if it returns true:
+//
+//	type MyHash struct {
+//		Name    string    `redis:"name,omitempty"`
+//		Created time.Time `redis:"created,omitempty"`
+//	}
+//
+//	// if h.Created is the zero time.Time only "name" will be set
+//	client.Do(radix.FlatCmd(nil, "HSET", "myhash", h))
+//
+// The "json" option may also be given in the tag, in which case the field's
+// value is stored as JSON rather than being converted as described below. See
+// resp2.JSON, which can also be used to store a whole value as JSON:
+//
+//	type MyHash struct {
+//		Name  string            `redis:"name"`
+//		Prefs map[string]string `redis:"prefs,json"`
+//	}
+//
+//	client.Do(radix.FlatCmd(nil, "SET", "mydoc", resp2.JSON{I: doc}))
+//	client.Do(radix.Cmd(resp2.JSON{I: &doc}, "GET", "mydoc"))
+//
+// Values are converted to and from strings as they would be for any other
+// argument or result: bools are sent as "1" or "0", and can be read from any
+// value accepted by strconv.ParseBool, and types implementing
+// encoding.TextMarshaler/TextUnmarshaler (such as time.Time) or
+// encoding.BinaryMarshaler/BinaryUnmarshaler use those methods.
+//
+// Large Values
+//
+// When a large value is being read it can be worthwhile to avoid allocating a
+// new buffer for it each time. If the receiver is a *[]byte then the value is
+// read into the existing slice, which is only reallocated if its capacity is
+// too small:
+//
+//	buf := make([]byte, 0, 1<<20)
+//	if err := client.Do(radix.Cmd(&buf, "GET", "bigkey")); err != nil {
+//		panic(err)
+//	}
+//
+// If the receiver is an io.Writer then the value is written to it as it's
+// read off the connection, without being buffered in its entirety first:
+//
+//	f, err := os.Create("bigkey.dump")
+//	if err != nil {
+//		panic(err)
+//	}
+//	defer f.Close()
+//	if err := client.Do(radix.Cmd(f, "DUMP", "bigkey")); err != nil {
+//		panic(err)
+//	}
+//
+// Similarly, a large value can be streamed onto the connection by passing a
+// resp.LenReader into FlatCmd as an argument, rather than reading it into
+// memory first:
+//
+//	f, err := os.Open("bigkey.dump")
+//	if err != nil {
+//		panic(err)
+//	}
+//	defer f.Close()
+//	stat, err := f.Stat()
+//	if err != nil {
+//		panic(err)
+//	}
+//	lr := resp.NewLenReader(f, stat.Size())
+//	if err := client.Do(radix.FlatCmd(nil, "RESTORE", "bigkey", 0, lr)); err != nil {
+//		panic(err)
+//	}
 //
 // Actions
 //
 // Cmd and FlatCmd both implement the Action interface. Other Actions include
-// Pipeline, WithConn, and EvalScript.Cmd. Any of these may be passed into any
-// Client's Do method.
+// Pipeline, WithConn, EvalScript.Cmd, and Function.Cmd. Any of these may be
+// passed into any Client's Do method.
 //
 //	var fooVal string
 //	p := radix.Pipeline(
@@ -140,6 +211,14 @@
 //		log.Printf("redis error returned: %s", redisErr.E)
 //	}
 //
+// The kind of error redis returned can be checked using the errors.Is function
+// and the resp2.ErrorKind values, rather than by matching on the message:
+//
+//	err := client.Do(radix.Cmd(nil, "LPUSH", "some-string-key", "foo"))
+//	if errors.Is(err, resp2.ErrWrongType) {
+//		log.Print("some-string-key isn't a list")
+//	}
+//
 // Use the golang.org/x/xerrors package if you're using an older version of go.
 //
 // Implicit pipelining
@@ -164,16 +243,22 @@ package radix
 
 import (
 	"bufio"
+	"context"
 	"crypto/tls"
 	"net"
 	"net/url"
+	"os"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	errors "golang.org/x/xerrors"
 
 	"github.com/mediocregopher/radix/v3/resp"
+	"github.com/mediocregopher/radix/v3/resp/resp2"
+	"github.com/mediocregopher/radix/v3/trace"
 )
 
 var errClientClosed = errors.New("client is closed")
@@ -192,6 +277,32 @@ type Client interface {
 	Close() error
 }
 
+// ContextClient is a Client which is also able to perform Actions while
+// respecting the deadline and cancellation of a context.Context. Pool, Cluster,
+// Sentinel, and the Conns returned from NewConn and Dial all implement
+// ContextClient.
+type ContextClient interface {
+	Client
+
+	// DoContext is like Do, but if the Context is canceled, or its deadline
+	// passes, before the Action has completed then the Action will be
+	// interrupted and the Context's error returned.
+	DoContext(context.Context, Action) error
+}
+
+// DoContext calls DoContext on the Client if it implements ContextClient.
+// Otherwise it returns the Context's error if it's already done, and falls back
+// to calling Do if not. This is useful for helpers which accept any Client but
+// want to respect a Context where possible.
+func DoContext(ctx context.Context, c Client, a Action) error {
+	if cc, ok := c.(ContextClient); ok {
+		return cc.DoContext(ctx, a)
+	} else if err := ctx.Err(); err != nil {
+		return err
+	}
+	return c.Do(a)
+}
+
 // ClientFunc is a function which can be used to create a Client for a single
 // redis instance on the given network/address.
 type ClientFunc func(network, addr string) (Client, error)
@@ -234,33 +345,199 @@ type Conn interface {
 	NetConn() net.Conn
 }
 
+// connWriter is the io.Writer which a connWrap's bufio.Writer flushes to. It
+// records whether anything has been written to the net.Conn.
+type connWriter struct {
+	net.Conn
+	wrote bool
+}
+
+func (cw *connWriter) Write(b []byte) (int, error) {
+	cw.wrote = true
+	return cw.Conn.Write(b)
+}
+
+func (cw *connWriter) writeBuffers(bufs *net.Buffers) (int64, error) {
+	cw.wrote = true
+	return writeBuffers(cw.Conn, bufs)
+}
+
+// partialEncodeError wraps an error which occurred during Encode after part of
+// the message had already been written to the connection, e.g. because a
+// LenReader being streamed onto it returned an error. The connection can't be
+// used any further.
+type partialEncodeError struct {
+	err error
+}
+
+func (pe partialEncodeError) Error() string {
+	return pe.err.Error()
+}
+
+func (pe partialEncodeError) Unwrap() error {
+	return pe.err
+}
+
 type connWrap struct {
 	net.Conn
 	brw *bufio.ReadWriter
+	w   connWriter
+
+	// bw is used to encode batchMarshalers, if writev is true
+	bw     batchWriter
+	writev bool
+
+	// pushHandlers are set using DialPushHandler
+	pushHandlers map[string]func(PushMessage)
+
+	// limits are set using DialReplyLimits, and are set on the reader for the
+	// duration of each Decode
+	limits resp2.Limits
 }
 
+// defaultBufferSize is the size of a Conn's read and write buffers if it isn't
+// given, the same as bufio's default.
+const defaultBufferSize = 4096
+
 // NewConn takes an existing net.Conn and wraps it to support the Conn interface
 // of this package. The Read and Write methods on the original net.Conn should
 // not be used after calling this method.
 func NewConn(conn net.Conn) Conn {
-	return &connWrap{
-		Conn: conn,
-		brw:  bufio.NewReadWriter(bufio.NewReader(conn), bufio.NewWriter(conn)),
+	return NewConnSize(conn, 0, 0)
+}
+
+// NewConnSize is like NewConn, but the buffers used for reading from and
+// writing to the net.Conn are of the given sizes, rather than the bufio
+// default of 4096 bytes. A size of 0 or less uses the default.
+//
+// Larger buffers reduce the number of syscalls needed to read and write large
+// values, while smaller buffers reduce the memory used by each connection,
+// which can add up when there are thousands of them. Lines of a reply which
+// don't fit in the read buffer, e.g. long errors, are still read, but have to
+// be copied out of it.
+func NewConnSize(conn net.Conn, readSize, writeSize int) Conn {
+	if readSize <= 0 {
+		readSize = defaultBufferSize
 	}
+	if writeSize <= 0 {
+		writeSize = defaultBufferSize
+	}
+
+	cw := &connWrap{Conn: conn, w: connWriter{Conn: conn}}
+	cw.brw = bufio.NewReadWriter(
+		bufio.NewReaderSize(conn, readSize),
+		bufio.NewWriterSize(&cw.w, writeSize),
+	)
+	cw.bw.w = &cw.w
+	cw.writev = canWritev(conn)
+	return cw
 }
 
 func (cw *connWrap) Do(a Action) error {
 	return a.Run(cw)
 }
 
-func (cw *connWrap) Encode(m resp.Marshaler) error {
-	if err := m.MarshalRESP(cw.brw); err != nil {
+// DoContext implements the method for the ContextClient interface. The deadline
+// of the Context is applied to all reads and writes done by the Action, and
+// if the Context is canceled any in-progress read or write is interrupted.
+//
+// If the Action is interrupted by the Context the Conn is closed, since the
+// state of the connection can no longer be known.
+func (cw *connWrap) DoContext(ctx context.Context, a Action) error {
+	err := withContextDeadline(ctx, cw.Conn, func() error {
+		return a.Run(cw)
+	})
+	if err != nil && ctx.Err() != nil {
+		cw.Close()
+	}
+	return err
+}
+
+// withContextDeadline calls fn, applying the deadline of the given Context to
+// the net.Conn for the duration of the call, and forcing the net.Conn to time
+// out if the Context is canceled before fn returns. If fn returns an error and
+// the Context is done then the Context's error is returned instead.
+func withContextDeadline(ctx context.Context, conn net.Conn, fn func() error) error {
+	doneCh := ctx.Done()
+	if doneCh == nil {
+		// the Context can never be canceled and has no deadline
+		return fn()
+	} else if err := ctx.Err(); err != nil {
 		return err
 	}
-	return cw.brw.Flush()
+
+	deadline, hasDeadline := ctx.Deadline()
+	if hasDeadline {
+		conn.SetDeadline(deadline)
+	}
+	// the deadline is always removed, even when the Context is done, since fn
+	// may have returned an error which left the net.Conn usable, in which case
+	// it might be used again
+	defer conn.SetDeadline(time.Time{})
+
+	stopCh, stoppedCh := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(stoppedCh)
+		select {
+		case <-doneCh:
+			// a deadline which has already passed causes any pending or future
+			// reads and writes to fail immediately
+			conn.SetDeadline(time.Now())
+		case <-stopCh:
+		}
+	}()
+
+	err := fn()
+	close(stopCh)
+	<-stoppedCh
+
+	if err != nil && hasDeadline && !time.Now().Before(deadline) {
+		// the net.Conn may time out slightly before the Context's own timer
+		// has fired, but the Context will be done momentarily
+		<-doneCh
+	}
+
+	if ctxErr := ctx.Err(); err != nil && ctxErr != nil {
+		return ctxErr
+	}
+	return err
+}
+
+func (cw *connWrap) Encode(m resp.Marshaler) error {
+	cw.w.wrote = false
+	var err error
+	if _, ok := m.(batchMarshaler); ok && cw.writev {
+		if err = m.MarshalRESP(&cw.bw); err == nil {
+			return cw.bw.flush(nil)
+		}
+		cw.bw.reset()
+	} else if err = m.MarshalRESP(cw.brw); err == nil {
+		return cw.brw.Flush()
+	} else {
+		cw.brw.Writer.Reset(&cw.w)
+	}
+
+	// whatever part of the message was still buffered has been discarded
+	// above, so it isn't sent along with the next one
+	if _, isNetErr := err.(net.Error); cw.w.wrote && !isNetErr {
+		return partialEncodeError{err: err}
+	}
+	return err
 }
 
 func (cw *connWrap) Decode(u resp.Unmarshaler) error {
+	if cw.limits != (resp2.Limits{}) {
+		// the limits are only set while decoding, so that they don't outlive
+		// the Conn, and readers of other Conns aren't checked for them
+		// otherwise
+		resp2.SetReaderLimits(cw.brw.Reader, cw.limits)
+		defer resp2.SetReaderLimits(cw.brw.Reader, resp2.Limits{})
+	}
+	if len(cw.pushHandlers) > 0 {
+		if err := cw.handlePushes(); err != nil {
+			return err
+		}
+	}
 	return u.UnmarshalRESP(cw.brw.Reader)
 }
 
@@ -281,8 +558,11 @@ var DefaultConnFunc = func(network, addr string) (Conn, error) {
 }
 
 func wrapDefaultConnFunc(addr string) ConnFunc {
-	_, opts := parseRedisURL(addr)
+	_, opts, err := parseRedisURL(addr)
 	return func(network, addr string) (Conn, error) {
+		if err != nil {
+			return nil, err
+		}
 		return Dial(network, addr, opts...)
 	}
 }
@@ -293,8 +573,18 @@ type dialOpts struct {
 	connectTimeout, readTimeout, writeTimeout time.Duration
 	authUser, authPass                        string
 	selectDB                                  string
+	clientName                                func() string
+	pushHandlers                              map[string]func(PushMessage)
+	useRESP3                                  bool
 	useTLSConfig                              bool
 	tlsConfig                                 *tls.Config
+	keepAlivePeriod                           time.Duration
+	keepAlivePeriodSet                        bool
+	netDialer                                 *net.Dialer
+	readBufferSize, writeBufferSize           int
+	replyLimits                               resp2.Limits
+	ct                                        *trace.ConnTrace
+	logger                                    Logger
 }
 
 // DialOpt is an optional behavior which can be applied to the Dial function to
@@ -311,6 +601,10 @@ func DialConnectTimeout(d time.Duration) DialOpt {
 
 // DialReadTimeout determines the deadline to set when reading from a dialed
 // connection. If not set then SetReadDeadline is never called.
+//
+// The read timeout is not applied while waiting for the response to a blocking
+// command, such as BLPOP or XREAD, since those may legitimately take longer
+// than the timeout to respond. A deadline set via DoContext is still applied.
 func DialReadTimeout(d time.Duration) DialOpt {
 	return func(do *dialOpts) {
 		do.readTimeout = d
@@ -382,41 +676,406 @@ func DialUseTLS(config *tls.Config) DialOpt {
 	}
 }
 
+// DialClientName will cause Dial to perform a CLIENT SETNAME command once the
+// connection is created, using the given name. The name will show up in the
+// output of CLIENT LIST, which can be useful when debugging.
+func DialClientName(name string) DialOpt {
+	return DialClientNameFunc(func() string { return name })
+}
+
+// DialClientNameFunc is like DialClientName, but fn is called each time a
+// connection is created to get the name it's given. This can be used with
+// SequentialClientName so that each connection made by an application instance
+// can be told apart in the output of CLIENT LIST.
+func DialClientNameFunc(fn func() string) DialOpt {
+	return func(do *dialOpts) {
+		do.clientName = fn
+	}
+}
+
+// SequentialClientName returns a function, for use with DialClientNameFunc and
+// similar options, which returns a new name each time it's called. The names
+// are of the form "<prefix>-<hostname>-<n>", where n starts at 1 and is
+// incremented on every call. If the hostname can't be determined it's left
+// out.
+func SequentialClientName(prefix string) func() string {
+	if host, err := os.Hostname(); err == nil && host != "" {
+		prefix += "-" + host
+	}
+	var n uint64
+	return func() string {
+		return prefix + "-" + strconv.FormatUint(atomic.AddUint64(&n, 1), 10)
+	}
+}
+
+// setClientName performs a CLIENT SETNAME on the Conn using the name returned
+// by fn. If the name is empty the Conn's name is left as-is.
+func setClientName(conn Conn, fn func() string) error {
+	name := fn()
+	if name == "" {
+		return nil
+	}
+	return conn.Do(Cmd(nil, "CLIENT", "SETNAME", name))
+}
+
+// DialUseRESP3 will cause Dial to perform a HELLO 3 command once the
+// connection is created, switching it to the RESP3 protocol. Replies of the
+// types added by RESP3 are decoded by Cmd and FlatCmd as described in the resp2
+// package.
+//
+// Redis may send push messages at any time on a RESP3 connection, for example
+// as a result of using CLIENT TRACKING without REDIRECT. Unless DialPushHandler
+// is used these will be interpreted as the reply to whichever command is
+// currently being read, so anything which could cause them should be avoided on
+// Conns which are used for normal commands. The higher level helpers in this
+// package (StreamReader in particular) expect replies in their RESP2 forms, and
+// so should not be used with RESP3 connections.
+func DialUseRESP3() DialOpt {
+	return func(do *dialOpts) {
+		do.useRESP3 = true
+	}
+}
+
+// DialKeepAlivePeriod determines the TCP keepalive period to use for dialed
+// connections. If d is zero or negative then keepalives will be disabled.
+//
+// If neither this nor DialNetDialer are given then a period of 10 seconds is
+// used.
+func DialKeepAlivePeriod(d time.Duration) DialOpt {
+	return func(do *dialOpts) {
+		do.keepAlivePeriod = d
+		do.keepAlivePeriodSet = true
+	}
+}
+
+// DialNetDialer causes Dial to use the given net.Dialer to create its
+// connections, rather than the zero value of net.Dialer. This can be used to
+// set the local address of the connection, a custom resolver, or other more
+// advanced options.
+//
+// If the given net.Dialer has its Timeout field set then it is used in place
+// of the value given by DialConnectTimeout. Its KeepAlive field is used unless
+// DialKeepAlivePeriod is also given.
+func DialNetDialer(dialer *net.Dialer) DialOpt {
+	return func(do *dialOpts) {
+		do.netDialer = dialer
+	}
+}
+
+// DialReplyLimits sets the resp2.Limits which apply to the replies read from
+// dialed connections, see resp2.SetReaderLimits. By default there are no
+// limits.
+//
+// Once a reply exceeds the limits the Conn can no longer be used, and should be
+// closed. Pool does so automatically.
+func DialReplyLimits(l resp2.Limits) DialOpt {
+	return func(do *dialOpts) {
+		do.replyLimits = l
+	}
+}
+
+// DialReadBufferSize determines the size of the buffer used for reading from
+// dialed connections, see NewConnSize. If not set, or if size is 0 or less,
+// then 4096 bytes is used.
+func DialReadBufferSize(size int) DialOpt {
+	return func(do *dialOpts) {
+		do.readBufferSize = size
+	}
+}
+
+// DialWriteBufferSize determines the size of the buffer used for writing to
+// dialed connections, see NewConnSize. If not set, or if size is 0 or less,
+// then 4096 bytes is used.
+func DialWriteBufferSize(size int) DialOpt {
+	return func(do *dialOpts) {
+		do.writeBufferSize = size
+	}
+}
+
+// DialWithTrace tells Dial to trace the Conn it creates with the given
+// ConnTrace. Note that ConnTrace will block every point that you set to trace.
+func DialWithTrace(ct trace.ConnTrace) DialOpt {
+	return func(do *dialOpts) {
+		do.ct = &ct
+	}
+}
+
+// DialLogger tells Dial to log events using the given Logger. Dial logs a
+// warning when it fails to create a connection.
+func DialLogger(l Logger) DialOpt {
+	return func(do *dialOpts) {
+		do.logger = l
+	}
+}
+
+// timeoutConn applies the read and write timeouts to each individual Read and
+// Write call. Deadlines set explicitly using the SetDeadline methods are
+// remembered, and take precedence over the timeouts when they are earlier.
+//
+// The read timeout may be suspended, in which case only an explicitly set read
+// deadline applies to reads.
 type timeoutConn struct {
 	net.Conn
 	readTimeout, writeTimeout time.Duration
+
+	l                           sync.Mutex
+	readDeadline, writeDeadline time.Time
+	readTimeoutSuspended        bool
+}
+
+func (tc *timeoutConn) suspendReadTimeout(suspend bool) {
+	tc.l.Lock()
+	defer tc.l.Unlock()
+	tc.readTimeoutSuspended = suspend
+}
+
+func timeoutDeadline(timeout time.Duration, deadline time.Time) time.Time {
+	t := time.Now().Add(timeout)
+	if !deadline.IsZero() && deadline.Before(t) {
+		return deadline
+	}
+	return t
 }
 
 func (tc *timeoutConn) Read(b []byte) (int, error) {
 	if tc.readTimeout > 0 {
-		tc.Conn.SetReadDeadline(time.Now().Add(tc.readTimeout))
+		tc.l.Lock()
+		if tc.readTimeoutSuspended {
+			tc.Conn.SetReadDeadline(tc.readDeadline)
+		} else {
+			tc.Conn.SetReadDeadline(timeoutDeadline(tc.readTimeout, tc.readDeadline))
+		}
+		tc.l.Unlock()
 	}
 	return tc.Conn.Read(b)
 }
 
 func (tc *timeoutConn) Write(b []byte) (int, error) {
 	if tc.writeTimeout > 0 {
-		tc.Conn.SetWriteDeadline(time.Now().Add(tc.writeTimeout))
+		tc.l.Lock()
+		tc.Conn.SetWriteDeadline(timeoutDeadline(tc.writeTimeout, tc.writeDeadline))
+		tc.l.Unlock()
 	}
 	return tc.Conn.Write(b)
 }
 
+func (tc *timeoutConn) writeBuffers(bufs *net.Buffers) (int64, error) {
+	if tc.writeTimeout > 0 {
+		tc.l.Lock()
+		tc.Conn.SetWriteDeadline(timeoutDeadline(tc.writeTimeout, tc.writeDeadline))
+		tc.l.Unlock()
+	}
+	return writeBuffers(tc.Conn, bufs)
+}
+
+func (tc *timeoutConn) SetDeadline(t time.Time) error {
+	tc.l.Lock()
+	defer tc.l.Unlock()
+	tc.readDeadline, tc.writeDeadline = t, t
+	return tc.Conn.SetDeadline(t)
+}
+
+func (tc *timeoutConn) SetReadDeadline(t time.Time) error {
+	tc.l.Lock()
+	defer tc.l.Unlock()
+	tc.readDeadline = t
+	return tc.Conn.SetReadDeadline(t)
+}
+
+func (tc *timeoutConn) SetWriteDeadline(t time.Time) error {
+	tc.l.Lock()
+	defer tc.l.Unlock()
+	tc.writeDeadline = t
+	return tc.Conn.SetWriteDeadline(t)
+}
+
+// countingConn counts the number of bytes read from and written to the
+// underlying net.Conn.
+type countingConn struct {
+	net.Conn
+
+	// See https://golang.org/pkg/sync/atomic/#pkg-note-BUG
+	bytesRead, bytesWritten int64 // atomic
+}
+
+func (cc *countingConn) Read(b []byte) (int, error) {
+	n, err := cc.Conn.Read(b)
+	atomic.AddInt64(&cc.bytesRead, int64(n))
+	return n, err
+}
+
+func (cc *countingConn) Write(b []byte) (int, error) {
+	n, err := cc.Conn.Write(b)
+	atomic.AddInt64(&cc.bytesWritten, int64(n))
+	return n, err
+}
+
+func (cc *countingConn) writeBuffers(bufs *net.Buffers) (int64, error) {
+	n, err := writeBuffers(cc.Conn, bufs)
+	atomic.AddInt64(&cc.bytesWritten, n)
+	return n, err
+}
+
+// tracedConn wraps a Conn created by Dial and calls the callbacks of a
+// ConnTrace as Actions are performed on it.
+type tracedConn struct {
+	Conn
+	ct      *trace.ConnTrace
+	common  trace.ConnCommon
+	counter *countingConn
+}
+
+// ActionCmdNames returns the upper-cased names of the redis commands which the
+// given Action performs, in the order they're performed, or nil if they aren't
+// known. This is intended for instrumentation, e.g. when naming spans or
+// metrics, and only describes Actions created by this package: those created
+// by Cmd, FlatCmd, EvalScript.Cmd, Function.Cmd, Pipeline and
+// PipelineWithResults, and any of those wrapped by Blocking or DoWait, as well
+// as the batches a Pool creates when implicitly pipelining commands.
+func ActionCmdNames(a Action) []string {
+	var cmds []CmdAction
+	switch a := a.(type) {
+	case blockingAction:
+		return ActionCmdNames(a.Action)
+	case *waitAction:
+		if names := ActionCmdNames(a.Action); names != nil {
+			return append(names, "WAIT")
+		}
+		return nil
+	case pipeline:
+		cmds = a
+	case pipelineWithResults:
+		cmds = a.pipeline
+	case *pipelinerPipeline:
+		cmds = a.pipeline
+	case *pipelinerCmd:
+		return ActionCmdNames(a.CmdAction)
+	default:
+		if name := actionCmdName(a); name != "" {
+			return []string{name}
+		}
+		return nil
+	}
+
+	names := make([]string, 0, len(cmds))
+	for _, cmd := range cmds {
+		names = append(names, ActionCmdNames(cmd)...)
+	}
+	return names
+}
+
+func actionCmdName(a Action) string {
+	switch a := a.(type) {
+	case *cmdAction:
+		return strings.ToUpper(a.cmd)
+	case *evalAction:
+		return "EVALSHA"
+	case *functionAction:
+		return a.cmd()
+	default:
+		return ""
+	}
+}
+
+func (tc *tracedConn) do(a Action, fn func() error) error {
+	started := trace.ConnDoStarted{
+		ConnCommon: tc.common,
+		Cmds:       ActionCmdNames(a),
+		NumKeys:    len(a.Keys()),
+	}
+	if len(started.Cmds) == 1 {
+		started.Cmd = started.Cmds[0]
+	}
+	if tc.ct.DoStarted != nil {
+		tc.ct.DoStarted(started)
+	}
+
+	startTime := time.Now()
+	written := atomic.LoadInt64(&tc.counter.bytesWritten)
+	read := atomic.LoadInt64(&tc.counter.bytesRead)
+	err := fn()
+
+	if tc.ct.DoCompleted != nil {
+		tc.ct.DoCompleted(trace.ConnDoCompleted{
+			ConnDoStarted: started,
+			BytesWritten:  atomic.LoadInt64(&tc.counter.bytesWritten) - written,
+			BytesRead:     atomic.LoadInt64(&tc.counter.bytesRead) - read,
+			ElapsedTime:   time.Since(startTime),
+			Err:           err,
+		})
+	}
+	return err
+}
+
+func (tc *tracedConn) Do(a Action) error {
+	return tc.do(a, func() error { return a.Run(tc) })
+}
+
+func (tc *tracedConn) DoContext(ctx context.Context, a Action) error {
+	return tc.do(a, func() error { return DoContext(ctx, tc.Conn, a) })
+}
+
+func (tc *tracedConn) Close() error {
+	err := tc.Conn.Close()
+	if tc.ct.Closed != nil {
+		tc.ct.Closed(trace.ConnClosed{ConnCommon: tc.common, Err: err})
+	}
+	return err
+}
+
 var defaultDialOpts = []DialOpt{
 	DialTimeout(10 * time.Second),
 }
 
-func parseRedisURL(urlStr string) (string, []DialOpt) {
+// defaultKeepAlivePeriod is the keepalive period used by Dial if neither
+// DialKeepAlivePeriod nor DialNetDialer are given.
+const defaultKeepAlivePeriod = 10 * time.Second
+
+// urlDurationParams maps the query parameters of a redis URI which hold
+// durations to the DialOpt which should be used for them.
+var urlDurationParams = []struct {
+	param string
+	opt   func(time.Duration) DialOpt
+}{
+	{"timeout", DialTimeout},
+	{"connect_timeout", DialConnectTimeout},
+	{"read_timeout", DialReadTimeout},
+	{"write_timeout", DialWriteTimeout},
+}
+
+// parseURLDuration parses a duration given in a URI query parameter. Both
+// go-style durations ("1.5s") and plain integers, interpreted as seconds, are
+// accepted.
+func parseURLDuration(str string) (time.Duration, error) {
+	if secs, err := strconv.Atoi(str); err == nil {
+		return time.Duration(secs) * time.Second, nil
+	}
+	return time.ParseDuration(str)
+}
+
+func parseRedisURL(urlStr string) (string, []DialOpt, error) {
 	// do a quick check before we bust out url.Parse, in case that is very
 	// unperformant
-	if !strings.HasPrefix(urlStr, "redis://") {
-		return urlStr, nil
+	if !strings.HasPrefix(urlStr, "redis://") &&
+		!strings.HasPrefix(urlStr, "rediss://") &&
+		!strings.HasPrefix(urlStr, "unix://") {
+		return urlStr, nil, nil
 	}
 
 	u, err := url.Parse(urlStr)
 	if err != nil {
-		return urlStr, nil
+		return urlStr, nil, errors.Errorf("parsing redis URI: %w", err)
 	}
+	return redisURLOpts(u)
+}
 
+// redisURLOpts returns the address and DialOpts described by a redis URI. An
+// error is returned if any of the URI's parameters are malformed, but the
+// remaining parameters are still returned.
+//
+// For the unix scheme the address is the path of the socket, and the db can
+// only be given as a query parameter.
+func redisURLOpts(u *url.URL) (string, []DialOpt, error) {
 	q := u.Query()
 
 	username := defaultAuthUser
@@ -435,16 +1094,81 @@ func parseRedisURL(urlStr string) (string, []DialOpt) {
 		DialAuthUser(username, password),
 	}
 
+	var err error
+
 	dbStr := q.Get("db")
-	if u.Path != "" && u.Path != "/" {
+	if u.Scheme != "unix" && u.Path != "" && u.Path != "/" {
 		dbStr = u.Path[1:]
 	}
 
-	if dbStr, err := strconv.Atoi(dbStr); err == nil {
-		opts = append(opts, DialSelectDB(dbStr))
+	if db, dbErr := strconv.Atoi(dbStr); dbErr == nil {
+		opts = append(opts, DialSelectDB(db))
+	} else if dbStr != "" {
+		err = errors.Errorf("invalid db %q in redis URI", dbStr)
 	}
 
-	return u.Host, opts
+	for _, p := range urlDurationParams {
+		str := q.Get(p.param)
+		if str == "" {
+			continue
+		} else if d, dErr := parseURLDuration(str); dErr != nil {
+			err = errors.Errorf("invalid %s %q in redis URI", p.param, str)
+		} else {
+			opts = append(opts, p.opt(d))
+		}
+	}
+
+	if u.Scheme == "rediss" {
+		opts = append(opts, DialUseTLS(nil))
+	} else if u.Scheme == "unix" {
+		return u.Path, opts, err
+	}
+
+	return u.Host, opts, err
+}
+
+// isUnixAddr returns true if the given address is the path of a unix domain
+// socket rather than a host:port, i.e. if it's an absolute path.
+func isUnixAddr(addr string) bool {
+	return strings.HasPrefix(addr, "/")
+}
+
+// DialURL is a convenience function which creates a Conn for the given redis
+// URI, as described by:
+// 	https://www.iana.org/assignments/uri-schemes/prov/redis
+//
+// The rediss scheme may be used in place of redis to have the connection use
+// TLS. Along with the username, password, and db, the URI's query parameters
+// "timeout", "connect_timeout", "read_timeout" and "write_timeout" are
+// interpreted, and are equivalent to the DialOpts of the same name. Their
+// values can either be a number of seconds or a duration string such as
+// "500ms".
+//
+// A unix domain socket may be connected to using the unix scheme, with the
+// socket's path in place of the host and port. The db must then be given as a
+// query parameter, e.g. "unix:///var/run/redis.sock?db=2".
+//
+// Any DialOpts passed in will overwrite the values given by the URI.
+func DialURL(rawurl string, opts ...DialOpt) (Conn, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, errors.Errorf("parsing redis URI: %w", err)
+	}
+
+	network := "tcp"
+	switch u.Scheme {
+	case "redis", "rediss":
+	case "unix":
+		network = "unix"
+	default:
+		return nil, errors.Errorf("unsupported redis URI scheme %q", u.Scheme)
+	}
+
+	addr, urlOpts, err := redisURLOpts(u)
+	if err != nil {
+		return nil, err
+	}
+	return Dial(network, addr, append(urlOpts, opts...)...)
 }
 
 // Dial is a ConnFunc which creates a Conn using net.Dial and NewConn. It takes
@@ -453,21 +1177,32 @@ func parseRedisURL(urlStr string) (string, []DialOpt) {
 // In place of a host:port address, Dial also accepts a URI, as per:
 // 	https://www.iana.org/assignments/uri-schemes/prov/redis
 // If the URI has an AUTH password or db specified Dial will attempt to perform
-// the AUTH and/or SELECT as well.
+// the AUTH and/or SELECT as well. See DialURL for the other URI parameters
+// which are supported. An error is returned if any of them are malformed.
 //
 // If either DialAuthPass or DialSelectDB is used it overwrites the associated
 // value passed in by the URI.
 //
+// If the network is "tcp" but the address is an absolute path, such as
+// "/var/run/redis.sock", then Dial connects to it as a unix domain socket
+// instead. Since Pool, Cluster, and Sentinel all use the "tcp" network, this
+// allows them to be given the paths of unix sockets in place of, or alongside,
+// host:port addresses.
+//
 // The default options Dial uses are:
 //
 //	DialTimeout(10 * time.Second)
+//	DialKeepAlivePeriod(10 * time.Second) // unless DialNetDialer is given
 //
 func Dial(network, addr string, opts ...DialOpt) (Conn, error) {
 	var do dialOpts
 	for _, opt := range defaultDialOpts {
 		opt(&do)
 	}
-	addr, addrOpts := parseRedisURL(addr)
+	addr, addrOpts, err := parseRedisURL(addr)
+	if err != nil {
+		return nil, err
+	}
 	for _, opt := range addrOpts {
 		opt(&do)
 	}
@@ -475,12 +1210,56 @@ func Dial(network, addr string, opts ...DialOpt) (Conn, error) {
 		opt(&do)
 	}
 
-	var netConn net.Conn
-	var err error
-	dialer := net.Dialer{}
-	if do.connectTimeout > 0 {
+	if network == "tcp" && isUnixAddr(addr) {
+		network = "unix"
+	}
+
+	startTime := time.Now()
+	conn, err := dialConn(network, addr, do)
+	if err != nil {
+		logEvent(do.logger, LogLevelWarn, "failed to dial", "network", network, "addr", addr, "err", err)
+	}
+	if do.ct != nil && do.ct.Dialed != nil {
+		do.ct.Dialed(trace.ConnDialed{
+			ConnCommon:  trace.ConnCommon{Network: network, Addr: addr},
+			ConnectTime: time.Since(startTime),
+			Err:         err,
+		})
+	}
+	return conn, err
+}
+
+// dialer returns the net.Dialer which Dial uses, along with the keepalive
+// period which Dial applies to the connection once it's been dialed, if any.
+func (do dialOpts) dialer() (net.Dialer, time.Duration) {
+	var dialer net.Dialer
+	if do.netDialer != nil {
+		dialer = *do.netDialer
+	}
+	if dialer.Timeout == 0 && do.connectTimeout > 0 {
 		dialer.Timeout = do.connectTimeout
 	}
+
+	keepAlivePeriod := do.keepAlivePeriod
+	if !do.keepAlivePeriodSet {
+		if do.netDialer != nil {
+			// the net.Dialer's own KeepAlive is used
+			return dialer, 0
+		}
+		keepAlivePeriod = defaultKeepAlivePeriod
+	}
+	if keepAlivePeriod <= 0 {
+		// net.Dialer enables keepalive by default, this disables it
+		dialer.KeepAlive = -1
+		return dialer, 0
+	}
+	return dialer, keepAlivePeriod
+}
+
+func dialConn(network, addr string, do dialOpts) (Conn, error) {
+	var netConn net.Conn
+	var err error
+	dialer, keepAlivePeriod := do.dialer()
 	if do.useTLSConfig {
 		netConn, err = tls.DialWithDialer(&dialer, network, addr, do.tlsConfig)
 	} else {
@@ -492,9 +1271,9 @@ func Dial(network, addr string, opts ...DialOpt) (Conn, error) {
 	}
 
 	// If the netConn is a net.TCPConn (or some wrapper for it) and so can have
-	// keepalive enabled, do so with a sane (though slightly aggressive)
-	// default.
-	{
+	// keepalive enabled, do so with the configured period, which defaults to
+	// something sane (though slightly aggressive).
+	if keepAlivePeriod > 0 {
 		type keepaliveConn interface {
 			SetKeepAlive(bool) error
 			SetKeepAlivePeriod(time.Duration) error
@@ -504,18 +1283,26 @@ func Dial(network, addr string, opts ...DialOpt) (Conn, error) {
 			if err = kaConn.SetKeepAlive(true); err != nil {
 				netConn.Close()
 				return nil, err
-			} else if err = kaConn.SetKeepAlivePeriod(10 * time.Second); err != nil {
+			} else if err = kaConn.SetKeepAlivePeriod(keepAlivePeriod); err != nil {
 				netConn.Close()
 				return nil, err
 			}
 		}
 	}
 
-	conn := NewConn(&timeoutConn{
+	var counter *countingConn
+	if do.ct != nil {
+		counter = &countingConn{Conn: netConn}
+		netConn = counter
+	}
+
+	conn := NewConnSize(&timeoutConn{
 		readTimeout:  do.readTimeout,
 		writeTimeout: do.writeTimeout,
 		Conn:         netConn,
-	})
+	}, do.readBufferSize, do.writeBufferSize)
+	conn.(*connWrap).pushHandlers = do.pushHandlers
+	conn.(*connWrap).limits = do.replyLimits
 
 	if do.authUser != "" && do.authUser != defaultAuthUser {
 		if err := conn.Do(Cmd(nil, "AUTH", do.authUser, do.authPass)); err != nil {
@@ -529,6 +1316,13 @@ func Dial(network, addr string, opts ...DialOpt) (Conn, error) {
 		}
 	}
 
+	if do.useRESP3 {
+		if err := conn.Do(Cmd(nil, "HELLO", "3")); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+
 	if do.selectDB != "" {
 		if err := conn.Do(Cmd(nil, "SELECT", do.selectDB)); err != nil {
 			conn.Close()
@@ -536,5 +1330,21 @@ func Dial(network, addr string, opts ...DialOpt) (Conn, error) {
 		}
 	}
 
+	if do.clientName != nil {
+		if err := setClientName(conn, do.clientName); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+
+	if do.ct != nil {
+		conn = &tracedConn{
+			Conn:    conn,
+			ct:      do.ct,
+			common:  trace.ConnCommon{Network: network, Addr: addr},
+			counter: counter,
+		}
+	}
+
 	return conn, nil
 }
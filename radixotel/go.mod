@@ -0,0 +1,14 @@
+module github.com/mediocregopher/radix/v3/radixotel
+
+go 1.15
+
+replace github.com/mediocregopher/radix/v3 => ../
+
+require (
+	github.com/mediocregopher/radix/v3 v3.8.1
+	github.com/stretchr/testify v1.7.0
+	go.opentelemetry.io/otel v1.0.0
+	go.opentelemetry.io/otel/sdk v1.0.0
+	go.opentelemetry.io/otel/trace v1.0.0
+	golang.org/x/xerrors v0.0.0-20191204190536-9bdfabe68543
+)
@@ -0,0 +1,182 @@
+// Package radixotel implements OpenTelemetry tracing for radix. It wraps a
+// radix.Client such that a client span is created for each Action performed
+// through it, as a child of any span in the Context passed into DoContext.
+//
+// Spans are given the attributes described by OpenTelemetry's semantic
+// conventions for database clients. db.statement is set to the names of the
+// commands being performed, e.g. "GET" or, for a Pipeline, "MULTI INCR EXEC",
+// and never includes keys or values.
+//
+// This package is its own module, so that radix itself doesn't depend on
+// OpenTelemetry.
+package radixotel
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/mediocregopher/radix/v3"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/mediocregopher/radix/v3/radixotel"
+
+type opts struct {
+	tracerProvider trace.TracerProvider
+	attrs          []attribute.KeyValue
+}
+
+// Opt is an optional behavior which can be applied to Wrap and WrapClientFunc
+// to effect their behavior.
+type Opt func(*opts)
+
+// TracerProvider sets the TracerProvider which spans are created using. By
+// default the global TracerProvider is used, see otel.GetTracerProvider.
+func TracerProvider(tp trace.TracerProvider) Opt {
+	return func(o *opts) {
+		o.tracerProvider = tp
+	}
+}
+
+// Attributes adds the given attributes to every span which is created.
+func Attributes(attrs ...attribute.KeyValue) Opt {
+	return func(o *opts) {
+		o.attrs = append(o.attrs, attrs...)
+	}
+}
+
+// PeerAddr adds the net.* attributes describing the redis instance which is
+// being connected to, given the network and address it's being connected to.
+// WrapClientFunc sets this automatically.
+func PeerAddr(network, addr string) Opt {
+	return func(o *opts) {
+		o.attrs = append(o.attrs, peerAttrs(network, addr)...)
+	}
+}
+
+func newOpts(optsIn []Opt) opts {
+	var o opts
+	for _, opt := range optsIn {
+		opt(&o)
+	}
+	if o.tracerProvider == nil {
+		o.tracerProvider = otel.GetTracerProvider()
+	}
+	return o
+}
+
+func peerAttrs(network, addr string) []attribute.KeyValue {
+	var attrs []attribute.KeyValue
+	switch network {
+	case "tcp", "tcp4", "tcp6":
+		attrs = append(attrs, semconv.NetTransportTCP)
+	case "unix":
+		attrs = append(attrs, semconv.NetTransportUnix)
+		return append(attrs, semconv.NetPeerNameKey.String(addr))
+	}
+
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return append(attrs, semconv.NetPeerNameKey.String(addr))
+	}
+
+	if ip := net.ParseIP(host); ip != nil {
+		attrs = append(attrs, semconv.NetPeerIPKey.String(host))
+	} else {
+		attrs = append(attrs, semconv.NetPeerNameKey.String(host))
+	}
+	if port, err := strconv.Atoi(portStr); err == nil {
+		attrs = append(attrs, semconv.NetPeerPortKey.Int(port))
+	}
+	return attrs
+}
+
+type client struct {
+	radix.Client
+	tracer trace.Tracer
+	attrs  []attribute.KeyValue
+}
+
+// Wrap returns a radix.ContextClient which performs Actions using the given
+// Client, creating a span for each one.
+//
+// Since the Client being wrapped may be a Pool or Cluster, which may each
+// perform an Action on any of a number of redis instances, the net.* attributes
+// aren't set unless the PeerAddr option is given. To have them set for each
+// node of a Cluster, use WrapClientFunc with ClusterPoolFunc instead.
+func Wrap(c radix.Client, opts ...Opt) radix.ContextClient {
+	o := newOpts(opts)
+	return &client{
+		Client: c,
+		tracer: o.tracerProvider.Tracer(instrumentationName),
+		attrs:  append([]attribute.KeyValue{semconv.DBSystemRedis}, o.attrs...),
+	}
+}
+
+// WrapClientFunc returns a radix.ClientFunc which wraps each Client created by
+// the given one using Wrap, with the PeerAddr option set to the network and
+// address the Client was created for. For example, to trace the Actions
+// performed on each node of a Cluster:
+//
+//	pf := radixotel.WrapClientFunc(radix.DefaultClientFunc)
+//	cluster, err := radix.NewCluster(addrs, radix.ClusterPoolFunc(pf))
+//
+func WrapClientFunc(fn radix.ClientFunc, opts ...Opt) radix.ClientFunc {
+	return func(network, addr string) (radix.Client, error) {
+		c, err := fn(network, addr)
+		if err != nil {
+			return nil, err
+		}
+		return Wrap(c, append(opts[:len(opts):len(opts)], PeerAddr(network, addr))...), nil
+	}
+}
+
+// spanName returns the name of the span to be used for an Action performing
+// the given commands.
+func spanName(cmds []string) string {
+	switch {
+	case len(cmds) == 0:
+		return "redis"
+	case len(cmds) == 1:
+		return cmds[0]
+	default:
+		return "PIPELINE"
+	}
+}
+
+func (c *client) Do(a radix.Action) error {
+	return c.DoContext(context.Background(), a)
+}
+
+func (c *client) DoContext(ctx context.Context, a radix.Action) error {
+	cmds := radix.ActionCmdNames(a)
+	attrs := c.attrs
+	if len(cmds) > 0 {
+		attrs = append(attrs[:len(attrs):len(attrs)], semconv.DBStatementKey.String(strings.Join(cmds, " ")))
+	}
+
+	ctx, span := c.tracer.Start(ctx, spanName(cmds),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attrs...),
+	)
+	defer span.End()
+
+	var err error
+	if cc, ok := c.Client.(radix.ContextClient); ok {
+		err = cc.DoContext(ctx, a)
+	} else if err = ctx.Err(); err == nil {
+		err = c.Client.Do(a)
+	}
+
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	return err
+}
@@ -0,0 +1,95 @@
+package radixotel
+
+import (
+	"context"
+	. "testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+	errors "golang.org/x/xerrors"
+
+	"github.com/mediocregopher/radix/v3"
+	"github.com/mediocregopher/radix/v3/resp/resp2"
+)
+
+func TestWrap(t *T) {
+	sr := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr))
+
+	pf := WrapClientFunc(func(network, addr string) (radix.Client, error) {
+		return radix.Stub(network, addr, func(args []string) interface{} {
+			if args[0] == "ERR" {
+				return resp2.Error{E: errors.New("ERR bad")}
+			}
+			return args[len(args)-1]
+		}), nil
+	}, TracerProvider(tp), Attributes(attribute.String("foo", "bar")))
+
+	c, err := pf("tcp", "127.0.0.1:6379")
+	require.NoError(t, err)
+	defer c.Close()
+
+	ctx, parent := tp.Tracer("test").Start(context.Background(), "parent")
+	var out string
+	require.NoError(t, c.(radix.ContextClient).DoContext(ctx, radix.Cmd(&out, "GET", "secret-key")))
+	assert.Equal(t, "secret-key", out)
+	parent.End()
+
+	require.NoError(t, c.Do(radix.Pipeline(
+		radix.Cmd(nil, "MULTI"),
+		radix.Cmd(nil, "SET", "secret-key", "secret-value"),
+		radix.Cmd(nil, "EXEC"),
+	)))
+	assert.Error(t, c.Do(radix.Cmd(nil, "ERR")))
+	require.NoError(t, c.Do(radix.WithConn("", func(radix.Conn) error { return nil })))
+
+	spans := sr.Ended()
+	require.Len(t, spans, 5)
+	spans = append(spans[:1], spans[2:]...) // remove parent
+
+	commonAttrs := []attribute.KeyValue{
+		semconv.DBSystemRedis,
+		attribute.String("foo", "bar"),
+		semconv.NetTransportTCP,
+		semconv.NetPeerIPKey.String("127.0.0.1"),
+		semconv.NetPeerPortKey.Int(6379),
+	}
+	withStatement := func(stmt string) []attribute.KeyValue {
+		return append(append([]attribute.KeyValue(nil), commonAttrs...), semconv.DBStatementKey.String(stmt))
+	}
+
+	assert.Equal(t, "GET", spans[0].Name())
+	assert.Equal(t, trace.SpanKindClient, spans[0].SpanKind())
+	assert.Equal(t, parent.SpanContext().SpanID(), spans[0].Parent().SpanID())
+	assert.Equal(t, withStatement("GET"), spans[0].Attributes())
+
+	assert.Equal(t, "PIPELINE", spans[1].Name())
+	assert.False(t, spans[1].Parent().IsValid())
+	assert.Equal(t, withStatement("MULTI SET EXEC"), spans[1].Attributes())
+
+	assert.Equal(t, "ERR", spans[2].Name())
+	assert.Equal(t, codes.Error, spans[2].Status().Code)
+	assert.Len(t, spans[2].Events(), 1)
+
+	assert.Equal(t, "redis", spans[3].Name())
+	assert.Equal(t, commonAttrs, spans[3].Attributes())
+	assert.Equal(t, codes.Unset, spans[3].Status().Code)
+}
+
+func TestPeerAttrs(t *T) {
+	assert.Equal(t, []attribute.KeyValue{
+		semconv.NetTransportTCP,
+		semconv.NetPeerNameKey.String("redis.example.com"),
+		semconv.NetPeerPortKey.Int(6379),
+	}, peerAttrs("tcp", "redis.example.com:6379"))
+	assert.Equal(t, []attribute.KeyValue{
+		semconv.NetTransportUnix,
+		semconv.NetPeerNameKey.String("/tmp/redis.sock"),
+	}, peerAttrs("unix", "/tmp/redis.sock"))
+}
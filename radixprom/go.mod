@@ -0,0 +1,12 @@
+module github.com/mediocregopher/radix/v3/radixprom
+
+go 1.15
+
+replace github.com/mediocregopher/radix/v3 => ../
+
+require (
+	github.com/mediocregopher/radix/v3 v3.8.1
+	github.com/prometheus/client_golang v1.11.1
+	github.com/stretchr/testify v1.4.0
+	golang.org/x/xerrors v0.0.0-20191204190536-9bdfabe68543
+)
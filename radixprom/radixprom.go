@@ -0,0 +1,276 @@
+// Package radixprom implements prometheus metrics for radix. A Metrics is a
+// prometheus.Collector which gathers:
+//
+//   - The latency of Actions performed through a Client wrapped by Wrap, as a
+//     histogram per command name.
+//
+//   - The errors returned from those Actions, counted per command name and
+//     class of error (see ErrorClass).
+//
+//   - The number of connections available in, and the size of, each Pool
+//     created with the PoolTrace option.
+//
+//   - The number of MOVED and ASK redirects followed by a Cluster created with
+//     the ClusterTrace option.
+//
+// A separate Metrics should be created and registered for each Client being
+// instrumented, using ConstLabels to tell them apart, e.g.
+//
+//	m := radixprom.New(radixprom.ConstLabels(prometheus.Labels{"client": "sessions"}))
+//	prometheus.MustRegister(m)
+//
+//	pool, err := radix.NewPool("tcp", addr, 10, radix.PoolWithTrace(m.PoolTrace()))
+//	if err != nil {
+//		// handle error
+//	}
+//	client := m.Wrap(pool)
+//
+// This package is its own module, so that radix itself doesn't depend on the
+// prometheus client.
+package radixprom
+
+import (
+	"context"
+	"io"
+	"net"
+	"time"
+
+	"github.com/mediocregopher/radix/v3"
+	"github.com/mediocregopher/radix/v3/resp/resp2"
+	"github.com/mediocregopher/radix/v3/trace"
+	"github.com/prometheus/client_golang/prometheus"
+	errors "golang.org/x/xerrors"
+)
+
+type opts struct {
+	namespace   string
+	constLabels prometheus.Labels
+	buckets     []float64
+}
+
+// Opt is an optional behavior which can be applied to New to effect the
+// Metrics it returns.
+type Opt func(*opts)
+
+// Namespace sets the namespace which all metric names are prefixed with.
+func Namespace(namespace string) Opt {
+	return func(o *opts) {
+		o.namespace = namespace
+	}
+}
+
+// ConstLabels sets labels which are added to every metric, e.g. to
+// differentiate between the metrics of different Clients.
+func ConstLabels(labels prometheus.Labels) Opt {
+	return func(o *opts) {
+		o.constLabels = labels
+	}
+}
+
+// Buckets sets the buckets, in seconds, of the command latency histogram.
+func Buckets(buckets []float64) Opt {
+	return func(o *opts) {
+		o.buckets = buckets
+	}
+}
+
+// Metrics is a prometheus.Collector which gathers metrics about the usage of
+// radix Clients. See the package docs for details.
+type Metrics struct {
+	cmdDuration   *prometheus.HistogramVec
+	cmdErrors     *prometheus.CounterVec
+	poolAvailable *prometheus.GaugeVec
+	poolSize      *prometheus.GaugeVec
+	redirects     *prometheus.CounterVec
+}
+
+var _ prometheus.Collector = new(Metrics)
+
+// New initializes and returns a Metrics instance, which must be registered
+// with a prometheus.Registerer for its metrics to be exported.
+//
+// The default options New uses are:
+//
+//	Namespace("radix")
+//	Buckets(prometheus.ExponentialBuckets(0.0001, 2, 16)) // 100µs to ~3.3s
+func New(optsIn ...Opt) *Metrics {
+	defaultOpts := []Opt{
+		Namespace("radix"),
+		Buckets(prometheus.ExponentialBuckets(0.0001, 2, 16)),
+	}
+
+	var o opts
+	for _, opt := range append(defaultOpts, optsIn...) {
+		opt(&o)
+	}
+
+	return &Metrics{
+		cmdDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace:   o.namespace,
+			Name:        "command_duration_seconds",
+			Help:        "Latency of Actions performed by the Client, by command name.",
+			ConstLabels: o.constLabels,
+			Buckets:     o.buckets,
+		}, []string{"command"}),
+		cmdErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   o.namespace,
+			Name:        "command_errors_total",
+			Help:        "Errors returned by Actions performed by the Client, by command name and class of error.",
+			ConstLabels: o.constLabels,
+		}, []string{"command", "class"}),
+		poolAvailable: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace:   o.namespace,
+			Subsystem:   "pool",
+			Name:        "conns_available",
+			Help:        "Number of connections available for use in the Pool, by address.",
+			ConstLabels: o.constLabels,
+		}, []string{"addr"}),
+		poolSize: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace:   o.namespace,
+			Subsystem:   "pool",
+			Name:        "size",
+			Help:        "Size the Pool was created with, by address.",
+			ConstLabels: o.constLabels,
+		}, []string{"addr"}),
+		redirects: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   o.namespace,
+			Subsystem:   "cluster",
+			Name:        "redirects_total",
+			Help:        "MOVED and ASK redirects received by the Cluster, by the address redirected to.",
+			ConstLabels: o.constLabels,
+		}, []string{"addr", "type"}),
+	}
+}
+
+func (m *Metrics) collectors() []prometheus.Collector {
+	return []prometheus.Collector{
+		m.cmdDuration, m.cmdErrors, m.poolAvailable, m.poolSize, m.redirects,
+	}
+}
+
+// Describe implements the method for the prometheus.Collector interface.
+func (m *Metrics) Describe(ch chan<- *prometheus.Desc) {
+	for _, c := range m.collectors() {
+		c.Describe(ch)
+	}
+}
+
+// Collect implements the method for the prometheus.Collector interface.
+func (m *Metrics) Collect(ch chan<- prometheus.Metric) {
+	for _, c := range m.collectors() {
+		c.Collect(ch)
+	}
+}
+
+// ErrorClass returns the class of the given error, as used for the class label
+// of the command errors metric:
+//
+//   - For errors returned by redis, the error's prefix, e.g. "ERR" or
+//     "WRONGTYPE".
+//
+//   - "timeout" for network timeouts and exceeded context deadlines.
+//
+//   - "canceled" for canceled contexts.
+//
+//   - "network" for all other network errors.
+//
+//   - "other" for everything else.
+func ErrorClass(err error) string {
+	var respErr resp2.Error
+	var netErr net.Error
+	switch {
+	case errors.As(err, &respErr):
+		return respErr.Prefix()
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return "timeout"
+	case netErr != nil, errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return "network"
+	default:
+		return "other"
+	}
+}
+
+// commandLabel returns the value of the command label for an Action performing
+// the given commands.
+func commandLabel(cmds []string) string {
+	switch {
+	case len(cmds) == 0:
+		return "unknown"
+	case len(cmds) == 1:
+		return cmds[0]
+	default:
+		return "PIPELINE"
+	}
+}
+
+type client struct {
+	radix.Client
+	m *Metrics
+}
+
+// Wrap returns a radix.ContextClient which performs Actions using the given
+// Client, recording the latency and errors of each one.
+func (m *Metrics) Wrap(c radix.Client) radix.ContextClient {
+	return &client{Client: c, m: m}
+}
+
+func (c *client) Do(a radix.Action) error {
+	return c.DoContext(context.Background(), a)
+}
+
+func (c *client) DoContext(ctx context.Context, a radix.Action) error {
+	start := time.Now()
+
+	var err error
+	if cc, ok := c.Client.(radix.ContextClient); ok {
+		err = cc.DoContext(ctx, a)
+	} else if err = ctx.Err(); err == nil {
+		err = c.Client.Do(a)
+	}
+
+	cmd := commandLabel(radix.ActionCmdNames(a))
+	c.m.cmdDuration.WithLabelValues(cmd).Observe(time.Since(start).Seconds())
+	if err != nil {
+		c.m.cmdErrors.WithLabelValues(cmd, ErrorClass(err)).Inc()
+	}
+	return err
+}
+
+// PoolTrace returns a PoolTrace which records the pool metrics of the Pool it's
+// passed into, via radix.PoolWithTrace. When used with a Cluster, pass it into
+// the Pool of each node using radix.ClusterPoolFunc.
+func (m *Metrics) PoolTrace() trace.PoolTrace {
+	setAvail := func(pc trace.PoolCommon, avail int) {
+		m.poolSize.WithLabelValues(pc.Addr).Set(float64(pc.PoolSize))
+		m.poolAvailable.WithLabelValues(pc.Addr).Set(float64(avail))
+	}
+	return trace.PoolTrace{
+		InitCompleted: func(ic trace.PoolInitCompleted) {
+			setAvail(ic.PoolCommon, ic.AvailCount)
+		},
+		ConnClosed: func(cc trace.PoolConnClosed) {
+			setAvail(cc.PoolCommon, cc.AvailCount)
+		},
+		DoCompleted: func(dc trace.PoolDoCompleted) {
+			setAvail(dc.PoolCommon, dc.AvailCount)
+		},
+	}
+}
+
+// ClusterTrace returns a ClusterTrace which records the cluster metrics of the
+// Cluster it's passed into, via radix.ClusterWithTrace.
+func (m *Metrics) ClusterTrace() trace.ClusterTrace {
+	return trace.ClusterTrace{
+		Redirected: func(r trace.ClusterRedirected) {
+			typ := "moved"
+			if r.Ask {
+				typ = "ask"
+			}
+			m.redirects.WithLabelValues(r.Addr, typ).Inc()
+		},
+	}
+}
@@ -0,0 +1,106 @@
+package radixprom
+
+import (
+	"context"
+	"io"
+	"net"
+	. "testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	errors "golang.org/x/xerrors"
+
+	"github.com/mediocregopher/radix/v3"
+	"github.com/mediocregopher/radix/v3/resp/resp2"
+	"github.com/mediocregopher/radix/v3/trace"
+)
+
+func stubConnFunc(network, addr string) (radix.Conn, error) {
+	return radix.Stub(network, addr, func(args []string) interface{} {
+		if args[0] == "ERR" {
+			return resp2.Error{E: errors.New("WRONGTYPE bad")}
+		}
+		return args[len(args)-1]
+	}), nil
+}
+
+func TestWrap(t *T) {
+	m := New(ConstLabels(prometheus.Labels{"client": "test"}))
+	reg := prometheus.NewPedanticRegistry()
+	require.NoError(t, reg.Register(m))
+
+	conn, _ := stubConnFunc("tcp", "127.0.0.1:6379")
+	c := m.Wrap(conn)
+	require.NoError(t, c.Do(radix.Cmd(nil, "GET", "foo")))
+	require.NoError(t, c.Do(radix.Cmd(nil, "GET", "bar")))
+	require.NoError(t, c.Do(radix.Pipeline(radix.Cmd(nil, "SET", "foo", "1"), radix.Cmd(nil, "GET", "foo"))))
+	assert.Error(t, c.Do(radix.Cmd(nil, "ERR")))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.Error(t, c.DoContext(ctx, radix.WithConn("", func(radix.Conn) error { return nil })))
+
+	assert.Equal(t, 4, testutil.CollectAndCount(m, "radix_command_duration_seconds"))
+	assert.Equal(t, float64(1), testutil.ToFloat64(m.cmdErrors.WithLabelValues("ERR", "WRONGTYPE")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(m.cmdErrors.WithLabelValues("unknown", "canceled")))
+	assert.Equal(t, 2, testutil.CollectAndCount(m, "radix_command_errors_total"))
+
+	hist := m.cmdDuration.WithLabelValues("GET").(prometheus.Histogram)
+	ch := make(chan prometheus.Metric, 1)
+	hist.Collect(ch)
+	assert.Contains(t, (<-ch).Desc().String(), `constLabels: {client="test"}`)
+}
+
+func TestPoolTrace(t *T) {
+	m := New()
+	pt := m.PoolTrace()
+	initDone := make(chan struct{})
+	initCompleted := pt.InitCompleted
+	pt.InitCompleted = func(ic trace.PoolInitCompleted) {
+		initCompleted(ic)
+		close(initDone)
+	}
+
+	pool, err := radix.NewPool("tcp", "127.0.0.1:6379", 3,
+		radix.PoolConnFunc(stubConnFunc),
+		radix.PoolWithTrace(pt),
+	)
+	require.NoError(t, err)
+	defer pool.Close()
+	<-initDone
+
+	assert.Equal(t, float64(3), testutil.ToFloat64(m.poolSize.WithLabelValues("127.0.0.1:6379")))
+	assert.Equal(t, float64(3), testutil.ToFloat64(m.poolAvailable.WithLabelValues("127.0.0.1:6379")))
+
+	require.NoError(t, pool.Do(radix.Cmd(nil, "GET", "foo")))
+	assert.Equal(t, float64(3), testutil.ToFloat64(m.poolAvailable.WithLabelValues("127.0.0.1:6379")))
+}
+
+func TestClusterTrace(t *T) {
+	m := New()
+	ct := m.ClusterTrace()
+	ct.Redirected(trace.ClusterRedirected{Addr: "127.0.0.1:7000", Moved: true})
+	ct.Redirected(trace.ClusterRedirected{Addr: "127.0.0.1:7000", Ask: true})
+	ct.Redirected(trace.ClusterRedirected{Addr: "127.0.0.1:7000", Ask: true})
+	assert.Equal(t, float64(1), testutil.ToFloat64(m.redirects.WithLabelValues("127.0.0.1:7000", "moved")))
+	assert.Equal(t, float64(2), testutil.ToFloat64(m.redirects.WithLabelValues("127.0.0.1:7000", "ask")))
+}
+
+type timeoutErr struct{}
+
+func (timeoutErr) Error() string   { return "timeout" }
+func (timeoutErr) Timeout() bool   { return true }
+func (timeoutErr) Temporary() bool { return true }
+
+func TestErrorClass(t *T) {
+	assert.Equal(t, "ERR", ErrorClass(resp2.Error{E: errors.New("ERR foo")}))
+	assert.Equal(t, "MOVED", ErrorClass(errors.Errorf("wrapped: %w", resp2.Error{E: errors.New("MOVED 1 foo")})))
+	assert.Equal(t, "timeout", ErrorClass(context.DeadlineExceeded))
+	assert.Equal(t, "timeout", ErrorClass(&net.OpError{Op: "read", Err: timeoutErr{}}))
+	assert.Equal(t, "canceled", ErrorClass(context.Canceled))
+	assert.Equal(t, "network", ErrorClass(&net.OpError{Op: "dial", Err: errors.New("refused")}))
+	assert.Equal(t, "network", ErrorClass(io.EOF))
+	assert.Equal(t, "other", ErrorClass(errors.New("foo")))
+}
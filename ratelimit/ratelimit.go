@@ -0,0 +1,325 @@
+// Package ratelimit implements rate limiters whose state is kept in redis, so
+// that a limit can be shared between many processes. Each limiter performs a
+// single lua script per request, using radix.EvalScript, and only touches a
+// single key per limited entity, so they can be used with a radix.Pool or a
+// radix.Cluster alike.
+//
+// Three algorithms are provided:
+//
+// FixedWindow counts requests within consecutive windows of a fixed duration,
+// starting from the first request in each window. It is the cheapest, but
+// allows up to twice the limit in a short period spanning the end of one window
+// and the start of the next.
+//
+// SlidingWindow records the time of each request, and allows a request only if
+// fewer than the limit occurred within the preceding window. It is exact, but
+// its memory usage grows with the limit.
+//
+// TokenBucket refills a bucket of tokens at a constant rate, up to a maximum
+// burst size, and allows a request if there are enough tokens in the bucket.
+//
+// SlidingWindow and TokenBucket use the local clock when performing a request,
+// so the clocks of the processes sharing a limit should be kept in sync.
+package ratelimit
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"strconv"
+	"time"
+
+	errors "golang.org/x/xerrors"
+
+	"github.com/mediocregopher/radix/v3"
+)
+
+// Result describes the outcome of a request made to a Limiter.
+type Result struct {
+	// Allowed indicates whether the request was allowed. If not then the
+	// request has not been counted against the limit.
+	Allowed bool
+
+	// Remaining is the number of further requests which could be allowed right
+	// now.
+	Remaining int64
+
+	// RetryAfter is set when the request was not allowed, and is how long
+	// until the same request could be allowed, assuming no other requests are
+	// made in the meantime.
+	RetryAfter time.Duration
+}
+
+// Limiter is implemented by all rate limiters in this package.
+type Limiter interface {
+	// AllowN reports whether n requests for the given key may happen now,
+	// counting them against the key's limit if so. If n is greater than the
+	// limit, or burst, of the limiter then the requests are never allowed.
+	AllowN(ctx context.Context, key string, n int64) (Result, error)
+
+	// Allow is shorthand for AllowN(ctx, key, 1).
+	Allow(ctx context.Context, key string) (Result, error)
+}
+
+type opts struct {
+	keyPrefix string
+}
+
+// Opt is an optional behavior which can be applied to the constructors in
+// this package to effect their behavior.
+type Opt func(*opts)
+
+// KeyPrefix sets the prefix which is prepended to every key passed into a
+// Limiter, in order to form the redis key the limiter's state is stored in.
+func KeyPrefix(prefix string) Opt {
+	return func(o *opts) {
+		o.keyPrefix = prefix
+	}
+}
+
+func newOpts(optsIn []Opt) opts {
+	defaultOpts := []Opt{
+		KeyPrefix("ratelimit:"),
+	}
+
+	var o opts
+	for _, opt := range append(defaultOpts, optsIn...) {
+		opt(&o)
+	}
+	return o
+}
+
+func ms(d time.Duration) string {
+	return strconv.FormatInt(int64(d/time.Millisecond), 10)
+}
+
+func nowMS() string {
+	return strconv.FormatInt(time.Now().UnixNano()/int64(time.Millisecond), 10)
+}
+
+// newResult converts the result of a limiter's script, which must be an array
+// of allowed (0 or 1), remaining, and retry-after in milliseconds, into a
+// Result.
+func newResult(res []int64) (Result, error) {
+	if len(res) != 3 {
+		return Result{}, errors.Errorf("rate limit script returned %d values, expected 3", len(res))
+	}
+
+	r := Result{Allowed: res[0] == 1, Remaining: res[1]}
+	if !r.Allowed {
+		r.RetryAfter = time.Duration(res[2]) * time.Millisecond
+	}
+	if r.Remaining < 0 {
+		r.Remaining = 0
+	}
+	return r, nil
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+var fixedWindowScript = radix.NewEvalScript(1, `
+	local limit, n, window = tonumber(ARGV[1]), tonumber(ARGV[2]), ARGV[3]
+	local count = tonumber(redis.call("GET", KEYS[1]) or "0")
+	if count + n > limit then
+		local ttl = redis.call("PTTL", KEYS[1])
+		if ttl < 0 then ttl = tonumber(window) end
+		return {0, limit - count, ttl}
+	end
+
+	count = redis.call("INCRBY", KEYS[1], n)
+	if count == n then
+		redis.call("PEXPIRE", KEYS[1], window)
+	end
+	return {1, limit - count, 0}
+`)
+
+// FixedWindow is a Limiter which allows a limited number of requests within
+// each window of time. See the package docs for details.
+type FixedWindow struct {
+	c      radix.Client
+	limit  int64
+	window time.Duration
+	opts   opts
+}
+
+var _ Limiter = new(FixedWindow)
+
+// NewFixedWindow returns a FixedWindow which allows limit requests per window
+// for each key.
+//
+// The default options NewFixedWindow uses are:
+//
+//	KeyPrefix("ratelimit:")
+//
+func NewFixedWindow(c radix.Client, limit int64, window time.Duration, opts ...Opt) *FixedWindow {
+	return &FixedWindow{c: c, limit: limit, window: window, opts: newOpts(opts)}
+}
+
+// AllowN implements the method for the Limiter interface.
+func (fw *FixedWindow) AllowN(ctx context.Context, key string, n int64) (Result, error) {
+	var res []int64
+	err := radix.DoContext(ctx, fw.c, fixedWindowScript.Cmd(&res,
+		fw.opts.keyPrefix+key,
+		strconv.FormatInt(fw.limit, 10), strconv.FormatInt(n, 10), ms(fw.window),
+	))
+	if err != nil {
+		return Result{}, err
+	}
+	return newResult(res)
+}
+
+// Allow implements the method for the Limiter interface.
+func (fw *FixedWindow) Allow(ctx context.Context, key string) (Result, error) {
+	return fw.AllowN(ctx, key, 1)
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+var slidingWindowScript = radix.NewEvalScript(1, `
+	local now, window = tonumber(ARGV[1]), tonumber(ARGV[2])
+	local limit, n = tonumber(ARGV[3]), tonumber(ARGV[4])
+	redis.call("ZREMRANGEBYSCORE", KEYS[1], "-inf", now - window)
+
+	local count = redis.call("ZCARD", KEYS[1])
+	if count + n > limit then
+		-- the request will be allowed once enough of the oldest requests have
+		-- fallen out of the window
+		local retry = window
+		local i = count + n - limit - 1
+		local oldest = redis.call("ZRANGE", KEYS[1], i, i, "WITHSCORES")
+		if oldest[2] then retry = tonumber(oldest[2]) + window - now end
+		return {0, limit - count, retry}
+	end
+
+	for i = 1, n do
+		redis.call("ZADD", KEYS[1], now, ARGV[5] .. ":" .. i)
+	end
+	redis.call("PEXPIRE", KEYS[1], window)
+	return {1, limit - count - n, 0}
+`)
+
+// SlidingWindow is a Limiter which allows a limited number of requests within
+// any window of time. See the package docs for details.
+type SlidingWindow struct {
+	c      radix.Client
+	limit  int64
+	window time.Duration
+	opts   opts
+}
+
+var _ Limiter = new(SlidingWindow)
+
+// NewSlidingWindow returns a SlidingWindow which allows limit requests within
+// any period of the given window for each key.
+//
+// The default options NewSlidingWindow uses are:
+//
+//	KeyPrefix("ratelimit:")
+//
+func NewSlidingWindow(c radix.Client, limit int64, window time.Duration, opts ...Opt) *SlidingWindow {
+	return &SlidingWindow{c: c, limit: limit, window: window, opts: newOpts(opts)}
+}
+
+// AllowN implements the method for the Limiter interface.
+func (sw *SlidingWindow) AllowN(ctx context.Context, key string, n int64) (Result, error) {
+	// each request is stored as a member of a sorted set, and so needs a
+	// unique id
+	idB := make([]byte, 8)
+	if _, err := rand.Read(idB); err != nil {
+		return Result{}, errors.Errorf("generating request id: %w", err)
+	}
+
+	var res []int64
+	err := radix.DoContext(ctx, sw.c, slidingWindowScript.Cmd(&res,
+		sw.opts.keyPrefix+key,
+		nowMS(), ms(sw.window),
+		strconv.FormatInt(sw.limit, 10), strconv.FormatInt(n, 10),
+		hex.EncodeToString(idB),
+	))
+	if err != nil {
+		return Result{}, err
+	}
+	return newResult(res)
+}
+
+// Allow implements the method for the Limiter interface.
+func (sw *SlidingWindow) Allow(ctx context.Context, key string) (Result, error) {
+	return sw.AllowN(ctx, key, 1)
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+var tokenBucketScript = radix.NewEvalScript(1, `
+	local now, rate = tonumber(ARGV[1]), tonumber(ARGV[2])
+	local burst, n = tonumber(ARGV[3]), tonumber(ARGV[4])
+
+	local state = redis.call("HMGET", KEYS[1], "tokens", "ts")
+	local tokens, ts = tonumber(state[1]), tonumber(state[2])
+	if not tokens or not ts then
+		tokens, ts = burst, now
+	elseif now > ts then
+		tokens = math.min(burst, tokens + (now - ts) * rate)
+		ts = now
+	end
+
+	local allowed, retry = 0, 0
+	if tokens >= n then
+		tokens = tokens - n
+		allowed = 1
+	else
+		retry = math.ceil((n - tokens) / rate)
+	end
+
+	redis.call("HSET", KEYS[1], "tokens", tostring(tokens), "ts", ts)
+	redis.call("PEXPIRE", KEYS[1], math.ceil(burst / rate))
+	return {allowed, math.floor(tokens), retry}
+`)
+
+// TokenBucket is a Limiter which allows requests at a constant rate, with some
+// allowance for bursts. See the package docs for details.
+type TokenBucket struct {
+	c     radix.Client
+	rate  float64
+	burst int64
+	opts  opts
+}
+
+var _ Limiter = new(TokenBucket)
+
+// NewTokenBucket returns a TokenBucket which allows rate requests per second
+// for each key, and up to burst requests at once. A key which has not been
+// seen before starts with a full bucket. NewTokenBucket panics if rate or burst
+// aren't positive.
+//
+// The default options NewTokenBucket uses are:
+//
+//	KeyPrefix("ratelimit:")
+//
+func NewTokenBucket(c radix.Client, rate float64, burst int64, opts ...Opt) *TokenBucket {
+	// written this way so that a NaN rate is rejected too
+	if !(rate > 0) {
+		panic("NewTokenBucket requires a positive rate")
+	} else if burst < 1 {
+		panic("NewTokenBucket requires a burst of at least 1")
+	}
+	return &TokenBucket{c: c, rate: rate, burst: burst, opts: newOpts(opts)}
+}
+
+// AllowN implements the method for the Limiter interface.
+func (tb *TokenBucket) AllowN(ctx context.Context, key string, n int64) (Result, error) {
+	var res []int64
+	err := radix.DoContext(ctx, tb.c, tokenBucketScript.Cmd(&res,
+		tb.opts.keyPrefix+key,
+		nowMS(), strconv.FormatFloat(tb.rate/1000, 'f', -1, 64),
+		strconv.FormatInt(tb.burst, 10), strconv.FormatInt(n, 10),
+	))
+	if err != nil {
+		return Result{}, err
+	}
+	return newResult(res)
+}
+
+// Allow implements the method for the Limiter interface.
+func (tb *TokenBucket) Allow(ctx context.Context, key string) (Result, error) {
+	return tb.AllowN(ctx, key, 1)
+}
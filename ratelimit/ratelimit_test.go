@@ -0,0 +1,116 @@
+package ratelimit
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"math"
+	. "testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mediocregopher/radix/v3"
+)
+
+func randStr() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+func testPool(t *T) *radix.Pool {
+	p, err := radix.NewPool("tcp", "127.0.0.1:6379", 2)
+	require.Nil(t, err)
+	return p
+}
+
+func assertAllowN(t *T, l Limiter, key string, n, expRemaining int64) {
+	res, err := l.AllowN(context.Background(), key, n)
+	require.Nil(t, err)
+	assert.True(t, res.Allowed, "res:%+v", res)
+	assert.Equal(t, expRemaining, res.Remaining)
+	assert.Zero(t, res.RetryAfter)
+}
+
+func assertDenied(t *T, l Limiter, key string, maxRetryAfter time.Duration) Result {
+	res, err := l.Allow(context.Background(), key)
+	require.Nil(t, err)
+	assert.False(t, res.Allowed, "res:%+v", res)
+	assert.True(t, res.RetryAfter > 0 && res.RetryAfter <= maxRetryAfter, "res:%+v", res)
+	return res
+}
+
+func TestFixedWindow(t *T) {
+	p := testPool(t)
+	defer p.Close()
+
+	key := randStr()
+	fw := NewFixedWindow(p, 3, time.Hour)
+	assertAllowN(t, fw, key, 1, 2)
+	assertAllowN(t, fw, key, 2, 0)
+	assertDenied(t, fw, key, time.Hour)
+
+	// a request which was denied isn't counted
+	var count int64
+	require.Nil(t, p.Do(radix.Cmd(&count, "GET", "ratelimit:"+key)))
+	assert.Equal(t, int64(3), count)
+
+	// other keys are unaffected
+	assertAllowN(t, fw, randStr(), 1, 2)
+
+	// so are the same keys under a different prefix
+	fw = NewFixedWindow(p, 3, time.Hour, KeyPrefix("other:"))
+	assertAllowN(t, fw, key, 3, 0)
+}
+
+func TestSlidingWindow(t *T) {
+	p := testPool(t)
+	defer p.Close()
+
+	key := randStr()
+	sw := NewSlidingWindow(p, 3, 200*time.Millisecond)
+	assertAllowN(t, sw, key, 1, 2)
+	time.Sleep(100 * time.Millisecond)
+	assertAllowN(t, sw, key, 2, 0)
+
+	// the first request will fall out of the window before the others
+	res := assertDenied(t, sw, key, 200*time.Millisecond)
+	assert.True(t, res.RetryAfter <= 100*time.Millisecond, "res:%+v", res)
+	time.Sleep(res.RetryAfter + 10*time.Millisecond)
+	assertAllowN(t, sw, key, 1, 0)
+
+	// requests for more than the limit are never allowed
+	res, err := sw.AllowN(context.Background(), randStr(), 4)
+	require.Nil(t, err)
+	assert.False(t, res.Allowed)
+}
+
+func TestTokenBucket(t *T) {
+	p := testPool(t)
+	defer p.Close()
+
+	key := randStr()
+	tb := NewTokenBucket(p, 10, 3)
+	assertAllowN(t, tb, key, 3, 0)
+	res := assertDenied(t, tb, key, 100*time.Millisecond)
+
+	// after waiting a token will have been added
+	time.Sleep(res.RetryAfter + 10*time.Millisecond)
+	assertAllowN(t, tb, key, 1, 0)
+
+	// and after waiting longer the bucket will be full, but not overflowing
+	time.Sleep(500 * time.Millisecond)
+	assertAllowN(t, tb, key, 1, 2)
+}
+
+func TestNewTokenBucketInvalid(t *T) {
+	assert.Panics(t, func() { NewTokenBucket(nil, 0, 3) })
+	assert.Panics(t, func() { NewTokenBucket(nil, -1, 3) })
+	assert.Panics(t, func() { NewTokenBucket(nil, math.NaN(), 3) })
+	assert.Panics(t, func() { NewTokenBucket(nil, 10, 0) })
+	assert.NotPanics(t, func() { NewTokenBucket(nil, 0.5, 1) })
+}
@@ -3,6 +3,8 @@ package resp2
 import (
 	"bufio"
 	"fmt"
+	"io/ioutil"
+	"strconv"
 	"strings"
 	"testing"
 )
@@ -86,3 +88,108 @@ func BenchmarkAnyUnmarshalRESP(b *testing.B) {
 		}
 	})
 }
+
+func BenchmarkAnyUnmarshalRESPLargeBulkString(b *testing.B) {
+	const size = 1 << 20
+	input := "$" + strconv.Itoa(size) + "\r\n" + strings.Repeat("a", size) + "\r\n"
+
+	run := func(b *testing.B, rcv interface{}) {
+		b.ReportAllocs()
+		b.SetBytes(size)
+
+		var sr strings.Reader
+		br := bufio.NewReader(&sr)
+
+		for i := 0; i < b.N; i++ {
+			sr.Reset(input)
+			br.Reset(&sr)
+
+			if err := (Any{I: rcv}).UnmarshalRESP(br); err != nil {
+				b.Fatalf("failed to unmarshal: %s", err)
+			}
+		}
+	}
+
+	b.Run("Bytes", func(b *testing.B) {
+		// the []byte's capacity is reused on every iteration
+		buf := make([]byte, 0, size)
+		run(b, &buf)
+	})
+
+	b.Run("Writer", func(b *testing.B) {
+		run(b, ioutil.Discard)
+	})
+}
+
+// reflectStrings and reflectStringMap are marshaled the same as []string and
+// map[string]string, but aren't handled by Any's type switches, so they can be
+// used to compare against marshaling using reflection.
+type (
+	reflectStrings   []string
+	reflectStringMap map[string]string
+)
+
+func BenchmarkAnyMarshalRESP(b *testing.B) {
+	bw := bufio.NewWriter(ioutil.Discard)
+	run := func(in interface{}) func(b *testing.B) {
+		return func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				a := Any{I: in, MarshalBulkString: true, MarshalNoArrayHeaders: true}
+				_ = a.NumElems()
+				if err := a.MarshalRESP(bw); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	}
+
+	strs := []string{"foo", "bar", "baz", "biz"}
+	strMap := map[string]string{"foo": "1", "bar": "2", "baz": "3", "biz": "4"}
+	b.Run("String", run("foo"))
+	b.Run("Bytes", run([]byte("foo")))
+	b.Run("Int", run(123))
+	b.Run("Int64", run(int64(123)))
+	b.Run("Float64", run(1.5))
+	b.Run("Bool", run(true))
+	b.Run("Strings", run(strs))
+	b.Run("StringsReflect", run(reflectStrings(strs)))
+	b.Run("StringMap", run(strMap))
+	b.Run("StringMapReflect", run(reflectStringMap(strMap)))
+}
+
+func BenchmarkAnyUnmarshalRESPSimple(b *testing.B) {
+	run := func(input string, rcv interface{}) func(b *testing.B) {
+		return func(b *testing.B) {
+			b.ReportAllocs()
+			var sr strings.Reader
+			br := bufio.NewReader(&sr)
+			for i := 0; i < b.N; i++ {
+				sr.Reset(input)
+				br.Reset(&sr)
+				if err := (Any{I: rcv}).UnmarshalRESP(br); err != nil {
+					b.Fatalf("failed to unmarshal %q: %s", input, err)
+				}
+			}
+		}
+	}
+
+	var (
+		i   int64
+		s   string
+		bs  []byte
+		f   float64
+		str struct {
+			Foo int64
+			Bar string
+		}
+	)
+	b.Run("Int64", run(":123\r\n", &i))
+	b.Run("IntString", run(":123\r\n", &s))
+	b.Run("OK", run("+OK\r\n", &s))
+	b.Run("SimpleString", run("+foo\r\n", &s))
+	b.Run("SimpleStringBytes", run("+foo\r\n", &bs))
+	b.Run("Float64", run(",1.5\r\n", &f))
+	b.Run("Discard", run(":123\r\n", nil))
+	b.Run("Struct", run("*4\r\n$3\r\nFoo\r\n:123\r\n$3\r\nBar\r\n+OK\r\n", &str))
+}
@@ -4,17 +4,27 @@
 // ties it to redis, it could be used for almost anything.
 //
 // See https://redis.io/topics/protocol for more details on the protocol.
+//
+// The types in this package only encode RESP2, but Any and RawMessage are also
+// able to decode all of the types added by RESP3, which redis will use once a
+// connection has been switched to it using the HELLO command. See the RESP3
+// prefixes below for details on how each type is decoded.
 package resp2
 
 import (
 	"bufio"
 	"bytes"
 	"encoding"
+	"encoding/json"
 	"fmt"
 	"io"
+	"math/big"
 	"reflect"
 	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	errors "golang.org/x/xerrors"
 
@@ -42,6 +52,38 @@ var (
 	ArrayPrefix        = []byte{'*'}
 )
 
+// Enumeration of the message types added by RESP3. These are only ever sent by
+// redis on connections which have been switched to RESP3. Any decodes them in
+// the following ways:
+//
+//	- Null is decoded as if it were a nil bulk string or array.
+//	- Double and BigNumber are decoded like simple strings, so they can be
+//	  decoded into floats, ints, strings, or *big.Int. Into an interface{} they
+//	  become a float64 or *big.Int, respectively.
+//	- Boolean is decoded as if it were the integer 1 or 0, which is what redis
+//	  would have sent over RESP2. Into an interface{} it becomes a bool.
+//	- BlobError is returned as an Error, like a normal error.
+//	- VerbatimString is decoded like a bulk string, with its format prefix
+//	  (e.g. "txt:") stripped.
+//	- Set and Push are decoded as if they were arrays.
+//	- Map is decoded as if it were an array of alternating keys and values, so
+//	  it can be decoded into a map or struct just like the reply from HGETALL
+//	  would be over RESP2. Into an interface{} it becomes a []interface{}.
+//	- Attribute is discarded, and the reply following it is decoded in its
+//	  place.
+var (
+	NullPrefix           = []byte{'_'}
+	DoublePrefix         = []byte{','}
+	BooleanPrefix        = []byte{'#'}
+	BlobErrorPrefix      = []byte{'!'}
+	VerbatimStringPrefix = []byte{'='}
+	BigNumberPrefix      = []byte{'('}
+	MapPrefix            = []byte{'%'}
+	SetPrefix            = []byte{'~'}
+	AttributePrefix      = []byte{'|'}
+	PushPrefix           = []byte{'>'}
+)
+
 // String formats a prefix into a human-readable name for the type it denotes.
 func (p prefix) String() string {
 	pStr := string(p)
@@ -56,6 +98,26 @@ func (p prefix) String() string {
 		return "bulk-string"
 	case string(ArrayPrefix):
 		return "array"
+	case string(NullPrefix):
+		return "null"
+	case string(DoublePrefix):
+		return "double"
+	case string(BooleanPrefix):
+		return "boolean"
+	case string(BlobErrorPrefix):
+		return "blob-error"
+	case string(VerbatimStringPrefix):
+		return "verbatim-string"
+	case string(BigNumberPrefix):
+		return "big-number"
+	case string(MapPrefix):
+		return "map"
+	case string(SetPrefix):
+		return "set"
+	case string(AttributePrefix):
+		return "attribute"
+	case string(PushPrefix):
+		return "push"
 	default:
 		return pStr
 	}
@@ -64,6 +126,7 @@ func (p prefix) String() string {
 var (
 	nilBulkString = []byte("$-1\r\n")
 	nilArray      = []byte("*-1\r\n")
+	null          = []byte("_\r\n")
 )
 
 var bools = [][]byte{
@@ -86,6 +149,106 @@ func (e errUnexpectedPrefix) Error() string {
 	)
 }
 
+// Limits limit the size of the messages which can be unmarshaled from a
+// *bufio.Reader, so that a broken or malicious server can't cause a huge
+// allocation using a bogus length header, or a stack overflow using deeply
+// nested arrays. Unmarshaling a message which exceeds them returns an error
+// wrapping ErrLimitExceeded, after which the rest of the message is still
+// unread, so the connection it was being read from can't be used any further.
+//
+// No limits are applied unless they're set using SetReaderLimits. A limit of 0
+// or less is disabled.
+type Limits struct {
+	// MaxBulkStringLen is the largest length of a bulk string, blob error, or
+	// verbatim string. Redis itself accepts values of up to 512MB by default
+	// (see proto-max-bulk-len).
+	MaxBulkStringLen int64
+
+	// MaxArrayLen is the largest number of elements of an array, set, or push,
+	// or of keys and values (combined) of a map or attribute.
+	MaxArrayLen int64
+
+	// MaxNestingDepth is the deepest which arrays (and maps, sets, pushes,
+	// and attributes) can be nested within each other. The replies which redis
+	// sends are rarely nested more than 4 deep.
+	//
+	// This is applied separately to the messages unmarshaled into each custom
+	// resp.Unmarshaler, e.g. into each element of a []resp2.RawMessage.
+	MaxNestingDepth int
+}
+
+var (
+	// readerLimits holds the Limits of each *bufio.Reader they've been set on,
+	// and numLimitedReaders the number of them, so that readers can be checked
+	// for Limits without a lookup when there are none.
+	readerLimits      sync.Map
+	numLimitedReaders int64
+)
+
+// SetReaderLimits sets the Limits which apply to messages unmarshaled from the
+// given *bufio.Reader by this package. Passing in the zero Limits removes them,
+// which must be done once the *bufio.Reader is no longer being used, or it
+// won't be garbage collected.
+func SetReaderLimits(br *bufio.Reader, l Limits) {
+	if l == (Limits{}) {
+		if _, ok := readerLimits.Load(br); ok {
+			readerLimits.Delete(br)
+			atomic.AddInt64(&numLimitedReaders, -1)
+		}
+	} else if _, loaded := readerLimits.LoadOrStore(br, l); loaded {
+		readerLimits.Store(br, l)
+	} else {
+		atomic.AddInt64(&numLimitedReaders, 1)
+	}
+}
+
+func limitsFor(br *bufio.Reader) Limits {
+	if atomic.LoadInt64(&numLimitedReaders) == 0 {
+		return Limits{}
+	} else if l, ok := readerLimits.Load(br); ok {
+		return l.(Limits)
+	}
+	return Limits{}
+}
+
+// ErrLimitExceeded is wrapped by the error returned when unmarshaling a message
+// which exceeds the Limits set on the *bufio.Reader it's read from.
+var ErrLimitExceeded = errors.New("resp message exceeds limit")
+
+func checkLen(kind string, l, max int64) error {
+	if l < -1 {
+		return errors.Errorf("invalid %s length %d", kind, l)
+	} else if max > 0 && l > max {
+		return errors.Errorf("%s length %d exceeds limit of %d: %w", kind, l, max, ErrLimitExceeded)
+	}
+	return nil
+}
+
+// checkBulkStringLen returns an error if the length of a bulk string, blob
+// error, or verbatim string read from br is invalid or exceeds br's
+// MaxBulkStringLen.
+func checkBulkStringLen(br *bufio.Reader, l int64) error {
+	return checkLen("bulk string", l, limitsFor(br).MaxBulkStringLen)
+}
+
+// checkArrayLen returns an error if the number of elements of an array, or of
+// keys and values of a map, read from br is invalid or exceeds br's
+// MaxArrayLen.
+func checkArrayLen(br *bufio.Reader, l int64) error {
+	return checkLen("array", l, limitsFor(br).MaxArrayLen)
+}
+
+// nestedDepth returns the depth of an array nested within an array at the given
+// depth, where a top-level message has a depth of 0, or an error if it exceeds
+// br's MaxNestingDepth.
+func nestedDepth(br *bufio.Reader, depth int) (int, error) {
+	max := limitsFor(br).MaxNestingDepth
+	if depth++; max > 0 && depth > max {
+		return 0, errors.Errorf("arrays nested more than %d deep: %w", max, ErrLimitExceeded)
+	}
+	return depth, nil
+}
+
 // peekAndAssertPrefix will peek at the next incoming redis message and assert
 // that it is of the type identified by the given RESP prefix (see the resp2
 // package for possible prefices).
@@ -194,6 +357,27 @@ func (e *Error) UnmarshalRESP(br *bufio.Reader) error {
 	return err
 }
 
+// Prefix returns the first word of the Error's message, which redis uses to
+// denote the kind of error it is, e.g. "ERR", "WRONGTYPE", or "MOVED". The word
+// is terminated by either a space or a colon.
+func (e Error) Prefix() string {
+	if e.E == nil {
+		return ""
+	}
+	msg := e.E.Error()
+	if i := strings.IndexAny(msg, " :"); i >= 0 {
+		return msg[:i]
+	}
+	return msg
+}
+
+// Is implements the method for the (x)errors.Is function. It returns true if
+// target is an ErrorKind which matches the Error's Prefix.
+func (e Error) Is(target error) bool {
+	kind, ok := target.(ErrorKind)
+	return ok && e.Prefix() == string(kind)
+}
+
 // As implements the method for the (x)errors.As function.
 func (e Error) As(target interface{}) bool {
 	switch targetT := target.(type) {
@@ -205,6 +389,36 @@ func (e Error) As(target interface{}) bool {
 	}
 }
 
+// ErrorKind identifies a kind of Error by its prefix, i.e. the first word of
+// its message. ErrorKinds can be used with the (x)errors.Is function to check
+// what kind of error redis has returned, without matching on strings:
+//
+//	if errors.Is(err, resp2.ErrWrongType) {
+//		// the key holds a value of the wrong type
+//	}
+//
+type ErrorKind string
+
+// Error implements the error interface.
+func (k ErrorKind) Error() string {
+	return string(k)
+}
+
+// Enumeration of the ErrorKinds which are most commonly returned by redis. The
+// kinds of other errors can be checked by converting their prefix into an
+// ErrorKind, e.g. ErrorKind("ERR").
+const (
+	ErrMoved       ErrorKind = "MOVED"
+	ErrAsk         ErrorKind = "ASK"
+	ErrBusy        ErrorKind = "BUSY"
+	ErrLoading     ErrorKind = "LOADING"
+	ErrReadOnly    ErrorKind = "READONLY"
+	ErrNoScript    ErrorKind = "NOSCRIPT"
+	ErrWrongType   ErrorKind = "WRONGTYPE"
+	ErrClusterDown ErrorKind = "CLUSTERDOWN"
+	ErrTryAgain    ErrorKind = "TRYAGAIN"
+)
+
 ////////////////////////////////////////////////////////////////////////////////
 
 // Int represents an int type in the RESP protocol
@@ -272,6 +486,8 @@ func (b *BulkStringBytes) UnmarshalRESP(br *bufio.Reader) error {
 	nn := int(n)
 	if err != nil {
 		return err
+	} else if err := checkBulkStringLen(br, n); err != nil {
+		return err
 	} else if n == -1 {
 		b.B = nil
 		return nil
@@ -320,6 +536,8 @@ func (b *BulkString) UnmarshalRESP(br *bufio.Reader) error {
 	n, err := bytesutil.BufferedIntDelim(br)
 	if err != nil {
 		return err
+	} else if err := checkBulkStringLen(br, n); err != nil {
+		return err
 	} else if n == -1 {
 		b.S = ""
 		return nil
@@ -366,7 +584,9 @@ func (b BulkReader) MarshalRESP(w io.Writer) error {
 		return err
 	}
 
-	if _, err := io.CopyN(w, b.LR, l); err != nil {
+	if n, err := io.CopyN(w, b.LR, l); err == io.EOF {
+		return errors.Errorf("LenReader returned %d bytes, expected %d: %w", n, l, io.ErrUnexpectedEOF)
+	} else if err != nil {
 		return err
 	} else if _, err := w.Write(delim); err != nil {
 		return err
@@ -381,6 +601,10 @@ func (b BulkReader) MarshalRESP(w io.Writer) error {
 // declares how many elements will come after it.
 //
 // An N of -1 may also be used to indicate a nil response, as per the RESP spec
+//
+// When unmarshaling, the headers of the RESP3 set, push, and map types are also
+// accepted. In the case of a map N will be the number of keys and values, i.e.
+// twice the number of entries in the map.
 type ArrayHeader struct {
 	N int
 }
@@ -398,12 +622,37 @@ func (ah ArrayHeader) MarshalRESP(w io.Writer) error {
 
 // UnmarshalRESP implements the Unmarshaler method
 func (ah *ArrayHeader) UnmarshalRESP(br *bufio.Reader) error {
-	if err := assertBufferedPrefix(br, ArrayPrefix); err != nil {
+	b, err := br.Peek(1)
+	if err != nil {
 		return err
 	}
+
+	var isMap bool
+	switch b[0] {
+	case SetPrefix[0], PushPrefix[0]:
+		br.Discard(1)
+	case MapPrefix[0]:
+		isMap = true
+		br.Discard(1)
+	default:
+		if err := assertBufferedPrefix(br, ArrayPrefix); err != nil {
+			return err
+		}
+	}
+
 	n, err := bytesutil.BufferedIntDelim(br)
+	if err != nil {
+		return err
+	} else if err := checkArrayLen(br, n); err != nil {
+		return err
+	} else if isMap && n > 0 {
+		n *= 2
+		if err := checkArrayLen(br, n); err != nil {
+			return err
+		}
+	}
 	ah.N = int(n)
-	return err
+	return nil
 }
 
 ////////////////////////////////////////////////////////////////////////////////
@@ -434,21 +683,23 @@ func (a Array) MarshalRESP(w io.Writer) error {
 
 ////////////////////////////////////////////////////////////////////////////////
 
-func discardArray(br *bufio.Reader, l int) error {
+// discardArray discards the given number of messages, which are the elements
+// of an array at a's depth.
+func (a Any) discardArray(br *bufio.Reader, l int) error {
 	for i := 0; i < l; i++ {
-		if err := (Any{}).UnmarshalRESP(br); err != nil {
+		if err := (Any{depth: a.depth}).UnmarshalRESP(br); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
-func discardArrayAfterErr(br *bufio.Reader, left int, err error) error {
+func (a Any) discardArrayAfterErr(br *bufio.Reader, left int, err error) error {
 	// if the last error which occurred didn't discard the message it was on, we
 	// can't do anything
 	if !errors.As(err, new(resp.ErrDiscarded)) {
 		return err
-	} else if err := discardArray(br, left); err != nil {
+	} else if err := a.discardArray(br, left); err != nil {
 		return err
 	}
 
@@ -463,6 +714,13 @@ func discardArrayAfterErr(br *bufio.Reader, left int, err error) error {
 // bools, etc... It also includes encoding.Text(Un)Marshalers and
 // encoding.(Un)BinaryMarshalers. It will _not_ marshal resp.Marshalers.
 //
+// Types implementing encoding.TextMarshaler or encoding.BinaryMarshaler, such
+// as net.IP or most UUID types, are marshaled as bulk strings using those
+// methods, even when the methods have pointer receivers and a non-pointer
+// value is given. This applies to map keys and struct fields too. If a type
+// implements both then encoding.TextMarshaler is used. The same goes for
+// encoding.TextUnmarshaler and encoding.BinaryUnmarshaler when unmarshaling.
+//
 // Most things will be treated as bulk strings, except for those that have their
 // own corresponding type in the RESP protocol (e.g. ints). strings and []bytes
 // will always be encoded as bulk strings, never simple strings.
@@ -472,9 +730,20 @@ func discardArrayAfterErr(br *bufio.Reader, left int, err error) error {
 // but they will be flattened into arrays of their alternating keys/values
 // first.
 //
+// A resp.LenReader, or an io.Reader with a Len method returning an int (e.g.
+// *bytes.Buffer, *strings.Reader), is marshaled as a bulk string by streaming
+// its contents to the io.Writer, without reading them into memory first.
+//
 // When using UnmarshalRESP the value of I must be a pointer or nil. If it is
 // nil then the RESP value will be read and discarded.
 //
+// A map may be unmarshaled into from either a RESP3 map or a flat array of
+// alternating keys and values, such as the replies to HGETALL or CONFIG GET.
+// Each key and value is unmarshaled as if also wrapped in an Any, so for
+// example a map[string]int64 or map[string]float64 may be used when the values
+// are numeric, even if redis returns them as bulk strings. Entries are added to
+// the map, which is created if it's nil.
+//
 // If an error type is read in the UnmarshalRESP method then a resp2.Error will
 // be returned with that error, and the value of I won't be touched.
 type Any struct {
@@ -490,6 +759,21 @@ type Any struct {
 	// written, and an ArrayHeader must have been manually marshalled
 	// beforehand.
 	MarshalNoArrayHeaders bool
+
+	// If non-zero then time.Time and time.Duration values are marshaled as
+	// integers in this unit, e.g. time.Second or time.Millisecond, with
+	// time.Times being given as unix timestamps. Integer replies are
+	// unmarshaled into *time.Time and *time.Duration values in the same way.
+	// This applies to all nested values, including struct fields and the
+	// elements of slices and maps.
+	//
+	// If zero then time.Time values are handled as encoding.TextMarshalers,
+	// and time.Durations as integers in nanoseconds.
+	TimeUnit time.Duration
+
+	// depth is the number of arrays which the message being unmarshaled is
+	// nested within, see Limits.
+	depth int
 }
 
 func (a Any) cp(i interface{}) Any {
@@ -509,15 +793,40 @@ var byteSliceT = reflect.TypeOf([]byte{})
 //	Any{I: [][]string{{"foo"}, {"bar", "baz"}, {}}}.NumElems() == 3
 //
 func (a Any) NumElems() int {
+	// the most common argument types are handled without reflection, which
+	// would otherwise dominate the cost of marshaling them
+	switch at := a.I.(type) {
+	case nil, string, []byte, bool, int, int64, float64:
+		return 1
+	case []string:
+		return len(at)
+	case map[string]string:
+		return len(at) * 2
+	}
 	return numElems(reflect.ValueOf(a.I))
 }
 
+// intLenReader is implemented by types like *bytes.Buffer, *bytes.Reader, and
+// *strings.Reader, whose Len method returns an int rather than an int64. Any
+// treats them the same as a resp.LenReader.
+type intLenReader interface {
+	io.Reader
+	Len() int
+}
+
 var (
 	lenReaderT               = reflect.TypeOf(new(resp.LenReader)).Elem()
+	intLenReaderT            = reflect.TypeOf(new(intLenReader)).Elem()
 	encodingTextMarshalerT   = reflect.TypeOf(new(encoding.TextMarshaler)).Elem()
 	encodingBinaryMarshalerT = reflect.TypeOf(new(encoding.BinaryMarshaler)).Elem()
 )
 
+// implementsMarshaler returns whether the given type is an
+// encoding.TextMarshaler or encoding.BinaryMarshaler.
+func implementsMarshaler(tt reflect.Type) bool {
+	return tt.Implements(encodingTextMarshalerT) || tt.Implements(encodingBinaryMarshalerT)
+}
+
 func numElems(vv reflect.Value) int {
 	if !vv.IsValid() {
 		return 1
@@ -525,11 +834,9 @@ func numElems(vv reflect.Value) int {
 
 	tt := vv.Type()
 	switch {
-	case tt.Implements(lenReaderT):
+	case tt.Implements(lenReaderT), tt.Implements(intLenReaderT):
 		return 1
-	case tt.Implements(encodingTextMarshalerT):
-		return 1
-	case tt.Implements(encodingBinaryMarshalerT):
+	case implementsMarshaler(tt), implementsMarshaler(reflect.PtrTo(tt)):
 		return 1
 	}
 
@@ -588,8 +895,13 @@ func numElemsStruct(vv reflect.Value, flat bool) int {
 			continue // continue
 		}
 
+		_, omitEmpty, asJSON := parseStructTag(ft.Tag.Get("redis"))
+		if omitEmpty && isEmptyValue(fv) {
+			continue
+		}
+
 		c++ // for the key
-		if flat {
+		if flat && !asJSON {
 			c += numElems(fv)
 		} else {
 			c++
@@ -605,19 +917,24 @@ func (a Any) MarshalRESP(w io.Writer) error {
 		return bs.MarshalRESP(w)
 	}
 
+	switch at := a.I.(type) {
+	case time.Time:
+		if a.TimeUnit > 0 {
+			return a.cp(at.UnixNano() / int64(a.TimeUnit)).MarshalRESP(w)
+		}
+	case time.Duration:
+		if a.TimeUnit > 0 {
+			at /= a.TimeUnit
+		}
+		return a.cp(int64(at)).MarshalRESP(w)
+	}
+
 	switch at := a.I.(type) {
 	case []byte:
 		return marshalBulk(at)
 	case string:
-		if at == "" {
-			// special case, we never want string to be nil, but appending empty
-			// string to a nil []byte would still be a nil bulk string
-			return BulkStringBytes{MarshalNotNil: true}.MarshalRESP(w)
-		}
-		scratch := bytesutil.GetBytes()
-		defer bytesutil.PutBytes(scratch)
-		*scratch = append(*scratch, at...)
-		return marshalBulk(*scratch)
+		// strings are never marshaled as nil, even when empty
+		return BulkString{S: at}.MarshalRESP(w)
 	case bool:
 		b := bools[0]
 		if at {
@@ -653,8 +970,42 @@ func (a Any) MarshalRESP(w io.Writer) error {
 			return marshalBulk(*scratch)
 		}
 		return Error{E: at}.MarshalRESP(w)
+	case []string:
+		if at == nil && !a.MarshalNoArrayHeaders {
+			_, err := w.Write(nilArray)
+			return err
+		} else if !a.MarshalNoArrayHeaders {
+			if err := (ArrayHeader{N: len(at)}).MarshalRESP(w); err != nil {
+				return err
+			}
+		}
+		for _, s := range at {
+			if err := (BulkString{S: s}).MarshalRESP(w); err != nil {
+				return err
+			}
+		}
+		return nil
+	case map[string]string:
+		if at == nil && !a.MarshalNoArrayHeaders {
+			_, err := w.Write(nilArray)
+			return err
+		} else if !a.MarshalNoArrayHeaders {
+			if err := (ArrayHeader{N: len(at) * 2}).MarshalRESP(w); err != nil {
+				return err
+			}
+		}
+		for k, v := range at {
+			if err := (BulkString{S: k}).MarshalRESP(w); err != nil {
+				return err
+			} else if err := (BulkString{S: v}).MarshalRESP(w); err != nil {
+				return err
+			}
+		}
+		return nil
 	case resp.LenReader:
 		return BulkReader{LR: at}.MarshalRESP(w)
+	case intLenReader:
+		return BulkReader{LR: resp.NewLenReader(at, int64(at.Len()))}.MarshalRESP(w)
 	case encoding.TextMarshaler:
 		b, err := at.MarshalText()
 		if err != nil {
@@ -672,6 +1023,14 @@ func (a Any) MarshalRESP(w io.Writer) error {
 	// now we use.... reflection! duhduhduuuuh....
 	vv := reflect.ValueOf(a.I)
 
+	// if the value's Text/BinaryMarshaler methods have pointer receivers then
+	// a pointer to a copy of it is marshaled instead
+	if vv.Kind() != reflect.Ptr && implementsMarshaler(reflect.PtrTo(vv.Type())) {
+		pv := reflect.New(vv.Type())
+		pv.Elem().Set(vv)
+		return a.cp(pv.Interface()).MarshalRESP(w)
+	}
+
 	// if it's a pointer we de-reference and try the pointed to value directly
 	if vv.Kind() == reflect.Ptr {
 		var ivv reflect.Value
@@ -758,12 +1117,20 @@ func (a Any) marshalStruct(w io.Writer, vv reflect.Value, inline bool) error {
 		}
 
 		keyName := ft.Name
-		if tag != "" {
-			keyName = tag
+		tagName, omitEmpty, asJSON := parseStructTag(tag)
+		if omitEmpty && isEmptyValue(fv) {
+			continue
+		} else if tagName != "" {
+			keyName = tagName
+		}
+
+		var val interface{} = fv.Interface()
+		if asJSON {
+			val = JSON{I: val}
 		}
 		if err := (BulkString{S: keyName}).MarshalRESP(w); err != nil {
 			return err
-		} else if err := a.cp(fv.Interface()).MarshalRESP(w); err != nil {
+		} else if err := a.cp(val).MarshalRESP(w); err != nil {
 			return err
 		}
 	}
@@ -784,6 +1151,18 @@ func saneDefault(prefix byte) interface{} {
 		return new(string)
 	case IntPrefix[0]:
 		return new(int64)
+	case DoublePrefix[0]:
+		return new(float64)
+	case BooleanPrefix[0]:
+		return new(bool)
+	case BigNumberPrefix[0]:
+		return new(big.Int)
+	case VerbatimStringPrefix[0]:
+		bb := make([]byte, 16)
+		return &bb
+	case MapPrefix[0], SetPrefix[0], PushPrefix[0]:
+		ii := make([]interface{}, 8)
+		return &ii
 	}
 	panic("should never get here")
 }
@@ -819,16 +1198,44 @@ func (a Any) UnmarshalRESP(br *bufio.Reader) error {
 	}
 	prefix := b[0]
 
+	// RESP3 attributes carry auxiliary data which precedes the actual reply.
+	// They are discarded, and the actual reply is unmarshaled instead.
+	if prefix == AttributePrefix[0] {
+		br.Discard(1)
+		l, err := bytesutil.BufferedIntDelim(br)
+		if err != nil {
+			return err
+		} else if err := checkArrayLen(br, l); err != nil {
+			return err
+		} else if err := checkArrayLen(br, l * 2); err != nil {
+			return err
+		} else if err := (Any{depth: a.depth}).unmarshalArray(br, l*2); err != nil {
+			return err
+		}
+		return a.UnmarshalRESP(br)
+	}
+
 	// This is a super special case that _must_ be handled before we actually
 	// read from the reader. If an *interface{} is given we instead unmarshal
 	// into a default (created based on the type of th message), then set the
 	// *interface{} to that
 	if ai, ok := a.I.(*interface{}); ok {
-		innerA := Any{I: saneDefault(prefix)}
+		if prefix == NullPrefix[0] {
+			*ai = nil
+			return (Any{}).UnmarshalRESP(br)
+		} else if prefix == ErrorPrefix[0] || prefix == BlobErrorPrefix[0] {
+			return (Any{}).UnmarshalRESP(br)
+		}
+
+		innerA := Any{I: saneDefault(prefix), depth: a.depth}
 		if err := innerA.UnmarshalRESP(br); err != nil {
 			return err
 		}
-		*ai = reflect.ValueOf(innerA.I).Elem().Interface()
+		if n, ok := innerA.I.(*big.Int); ok {
+			*ai = n
+		} else {
+			*ai = reflect.ValueOf(innerA.I).Elem().Interface()
+		}
 		return nil
 	}
 
@@ -845,6 +1252,8 @@ func (a Any) UnmarshalRESP(br *bufio.Reader) error {
 		l, err := bytesutil.ParseInt(b)
 		if err != nil {
 			return err
+		} else if err := checkArrayLen(br, l); err != nil {
+			return err
 		} else if l == -1 {
 			return a.unmarshalNil()
 		}
@@ -853,6 +1262,8 @@ func (a Any) UnmarshalRESP(br *bufio.Reader) error {
 		l, err := bytesutil.ParseInt(b) // fuck DRY
 		if err != nil {
 			return err
+		} else if err := checkBulkStringLen(br, l); err != nil {
+			return err
 		} else if l == -1 {
 			return a.unmarshalNil()
 		}
@@ -871,17 +1282,140 @@ func (a Any) UnmarshalRESP(br *bufio.Reader) error {
 			return discardErr
 		}
 		return err
-	case SimpleStringPrefix[0], IntPrefix[0]:
+	case SimpleStringPrefix[0], IntPrefix[0], DoublePrefix[0], BigNumberPrefix[0]:
+		if ok, err := a.unmarshalSimple(b); ok {
+			return err
+		}
 		reader := byteReaderPool.Get().(*bytes.Reader)
 		reader.Reset(b)
 		err := a.unmarshalSingle(reader, reader.Len())
 		byteReaderPool.Put(reader)
 		return err
+	case NullPrefix[0]:
+		return a.unmarshalNil()
+	case BooleanPrefix[0]:
+		var boolB []byte
+		switch string(b) {
+		case "t":
+			boolB = bools[1]
+		case "f":
+			boolB = bools[0]
+		default:
+			return errors.Errorf("invalid boolean %q", b)
+		}
+		reader := byteReaderPool.Get().(*bytes.Reader)
+		reader.Reset(boolB)
+		err := a.unmarshalSingle(reader, reader.Len())
+		byteReaderPool.Put(reader)
+		return err
+	case BlobErrorPrefix[0]:
+		l, err := bytesutil.ParseInt(b)
+		if err != nil {
+			return err
+		} else if err := checkBulkStringLen(br, l); err != nil {
+			return err
+		}
+		scratch := bytesutil.GetBytes()
+		defer bytesutil.PutBytes(scratch)
+		if *scratch, err = bytesutil.ReadNAppend(br, *scratch, int(l)); err != nil {
+			return err
+		} else if _, err := br.Discard(2); err != nil {
+			return err
+		}
+		return Error{E: errors.New(string(*scratch))}
+	case VerbatimStringPrefix[0]:
+		l, err := bytesutil.ParseInt(b)
+		if err != nil {
+			return err
+		} else if err := checkBulkStringLen(br, l); err != nil {
+			return err
+		} else if l < 4 {
+			return errors.Errorf("verbatim string of length %d is too short", l)
+		}
+
+		// the first 4 bytes are the format, e.g. "txt:", which is discarded.
+		// The rest is handled the same as a bulk string.
+		if _, err := br.Discard(4); err != nil {
+			return err
+		}
+		if err = a.unmarshalSingle(br, int(l-4)); err != nil {
+			if !errors.As(err, new(resp.ErrDiscarded)) {
+				return err
+			}
+		}
+		if _, discardErr := br.Discard(2); discardErr != nil {
+			return discardErr
+		}
+		return err
+	case MapPrefix[0]:
+		l, err := bytesutil.ParseInt(b)
+		if err != nil {
+			return err
+		} else if err := checkArrayLen(br, l); err != nil {
+			return err
+		} else if err := checkArrayLen(br, l * 2); err != nil {
+			return err
+		}
+		return a.unmarshalArray(br, l*2)
+	case SetPrefix[0], PushPrefix[0]:
+		l, err := bytesutil.ParseInt(b)
+		if err != nil {
+			return err
+		} else if err := checkArrayLen(br, l); err != nil {
+			return err
+		}
+		return a.unmarshalArray(br, l)
 	default:
 		return errors.Errorf("unknown type prefix %q", b[0])
 	}
 }
 
+// commonSimpleStrings are the simple string replies which redis sends most
+// often. unmarshalSimple uses these, rather than allocating a new string each
+// time one of them is unmarshaled into a *string.
+var commonSimpleStrings = [...]string{"OK", "PONG", "QUEUED"}
+
+// unmarshalSimple is a fast path of unmarshalSingle for the most common
+// receiver types. It unmarshals the body of a simple string, integer, double,
+// or big number message directly from the bufio.Reader's buffer, without
+// copying it first, returning false if the receiver's type isn't handled.
+func (a Any) unmarshalSimple(b []byte) (bool, error) {
+	switch ai := a.I.(type) {
+	case nil:
+	case *string:
+		for _, s := range commonSimpleStrings {
+			if string(b) == s {
+				*ai = s
+				return true, nil
+			}
+		}
+		*ai = string(b)
+	case *[]byte:
+		*ai = append((*ai)[:0], b...)
+	case *int:
+		i, err := bytesutil.ParseInt(b)
+		if err != nil {
+			return true, resp.ErrDiscarded{Err: err}
+		}
+		*ai = int(i)
+	case *int64:
+		i, err := bytesutil.ParseInt(b)
+		if err != nil {
+			return true, resp.ErrDiscarded{Err: err}
+		}
+		*ai = i
+	case *uint64:
+		ui, err := bytesutil.ParseUint(b)
+		if err != nil {
+			return true, resp.ErrDiscarded{Err: err}
+		}
+		*ai = ui
+	default:
+		return false, nil
+	}
+	return true, nil
+}
+
 func (a Any) unmarshalSingle(body io.Reader, n int) error {
 	var (
 		err error
@@ -889,6 +1423,26 @@ func (a Any) unmarshalSingle(body io.Reader, n int) error {
 		ui  uint64
 	)
 
+	switch ai := a.I.(type) {
+	case *time.Time:
+		if a.TimeUnit <= 0 {
+			break
+		}
+		if i, err = bytesutil.ReadInt(body, n); err == nil {
+			*ai = time.Unix(0, i*int64(a.TimeUnit))
+		}
+		return err
+	case *time.Duration:
+		unit := a.TimeUnit
+		if unit <= 0 {
+			unit = time.Nanosecond
+		}
+		if i, err = bytesutil.ReadInt(body, n); err == nil {
+			*ai = time.Duration(i) * unit
+		}
+		return err
+	}
+
 	switch ai := a.I.(type) {
 	case nil:
 		// just read it and do nothing
@@ -901,8 +1455,7 @@ func (a Any) unmarshalSingle(body io.Reader, n int) error {
 	case *[]byte:
 		*ai, err = bytesutil.ReadNAppend(body, (*ai)[:0], n)
 	case *bool:
-		ui, err = bytesutil.ReadUint(body, n)
-		*ai = ui > 0
+		*ai, err = bytesutil.ReadBool(body, n)
 	case *int:
 		i, err = bytesutil.ReadInt(body, n)
 		*ai = int(i)
@@ -940,7 +1493,7 @@ func (a Any) unmarshalSingle(body io.Reader, n int) error {
 	case *float64:
 		*ai, err = bytesutil.ReadFloat(body, 64, n)
 	case io.Writer:
-		_, err = io.CopyN(ai, body, int64(n))
+		err = bytesutil.ReadNWrite(body, ai, n)
 	case encoding.TextUnmarshaler:
 		scratch := bytesutil.GetBytes()
 		if *scratch, err = bytesutil.ReadNAppend(body, *scratch, n); err != nil {
@@ -983,8 +1536,11 @@ func (a Any) unmarshalNil() error {
 }
 
 func (a Any) unmarshalArray(br *bufio.Reader, l int64) error {
-	if a.I == nil {
-		return discardArray(br, int(l))
+	var err error
+	if a.depth, err = nestedDepth(br, a.depth); err != nil {
+		return err
+	} else if a.I == nil {
+		return a.discardArray(br, int(l))
 	}
 
 	size := int(l)
@@ -993,7 +1549,7 @@ func (a Any) unmarshalArray(br *bufio.Reader, l int64) error {
 		err := resp.ErrDiscarded{
 			Err: errors.Errorf("can't unmarshal array into %T", a.I),
 		}
-		return discardArrayAfterErr(br, int(l), err)
+		return a.discardArrayAfterErr(br, int(l), err)
 	}
 	v = reflect.Indirect(v)
 
@@ -1011,9 +1567,9 @@ func (a Any) unmarshalArray(br *bufio.Reader, l int64) error {
 		}
 
 		for i := 0; i < size; i++ {
-			ai := Any{I: v.Index(i).Addr().Interface()}
+			ai := a.cp(v.Index(i).Addr().Interface())
 			if err := ai.UnmarshalRESP(br); err != nil {
-				return discardArrayAfterErr(br, int(l)-i-1, err)
+				return a.discardArrayAfterErr(br, int(l)-i-1, err)
 			}
 		}
 		return nil
@@ -1021,7 +1577,7 @@ func (a Any) unmarshalArray(br *bufio.Reader, l int64) error {
 	case reflect.Map:
 		if size%2 != 0 {
 			err := resp.ErrDiscarded{Err: errors.New("cannot decode redis array with odd number of elements into map")}
-			return discardArrayAfterErr(br, int(l), err)
+			return a.discardArrayAfterErr(br, int(l), err)
 		} else if v.IsNil() {
 			v.Set(reflect.MakeMapWithSize(v.Type(), size/2))
 		}
@@ -1041,16 +1597,16 @@ func (a Any) unmarshalArray(br *bufio.Reader, l int64) error {
 			if !kv.IsValid() {
 				kv = reflect.New(v.Type().Key())
 			}
-			if err := (Any{I: kv.Interface()}).UnmarshalRESP(br); err != nil {
-				return discardArrayAfterErr(br, int(l)-i-1, err)
+			if err := a.cp(kv.Interface()).UnmarshalRESP(br); err != nil {
+				return a.discardArrayAfterErr(br, int(l)-i-1, err)
 			}
 
 			vv := vvs
 			if !vv.IsValid() {
 				vv = reflect.New(v.Type().Elem())
 			}
-			if err := (Any{I: vv.Interface()}).UnmarshalRESP(br); err != nil {
-				return discardArrayAfterErr(br, int(l)-i-2, err)
+			if err := a.cp(vv.Interface()).UnmarshalRESP(br); err != nil {
+				return a.discardArrayAfterErr(br, int(l)-i-2, err)
 			}
 
 			v.SetMapIndex(kv.Elem(), vv.Elem())
@@ -1060,7 +1616,7 @@ func (a Any) unmarshalArray(br *bufio.Reader, l int64) error {
 	case reflect.Struct:
 		if size%2 != 0 {
 			err := resp.ErrDiscarded{Err: errors.New("cannot decode redis array with odd number of elements into struct")}
-			return discardArrayAfterErr(br, int(l), err)
+			return a.discardArrayAfterErr(br, int(l), err)
 		}
 
 		structFields := getStructFields(v.Type())
@@ -1068,7 +1624,7 @@ func (a Any) unmarshalArray(br *bufio.Reader, l int64) error {
 
 		for i := 0; i < size; i += 2 {
 			if err := field.UnmarshalRESP(br); err != nil {
-				return discardArrayAfterErr(br, int(l)-i-1, err)
+				return a.discardArrayAfterErr(br, int(l)-i-1, err)
 			}
 
 			var vv reflect.Value
@@ -1079,14 +1635,18 @@ func (a Any) unmarshalArray(br *bufio.Reader, l int64) error {
 
 			if !ok || !vv.IsValid() {
 				// discard the value
-				if err := (Any{}).UnmarshalRESP(br); err != nil {
-					return discardArrayAfterErr(br, int(l)-i-2, err)
+				if err := (Any{depth: a.depth}).UnmarshalRESP(br); err != nil {
+					return a.discardArrayAfterErr(br, int(l)-i-2, err)
 				}
 				continue
 			}
 
-			if err := (Any{I: vv.Interface()}).UnmarshalRESP(br); err != nil {
-				return discardArrayAfterErr(br, int(l)-i-2, err)
+			var rcv interface{} = vv.Interface()
+			if structField.asJSON {
+				rcv = JSON{I: rcv}
+			}
+			if err := a.cp(rcv).UnmarshalRESP(br); err != nil {
+				return a.discardArrayAfterErr(br, int(l)-i-2, err)
 			}
 		}
 
@@ -1094,7 +1654,7 @@ func (a Any) unmarshalArray(br *bufio.Reader, l int64) error {
 
 	default:
 		err := resp.ErrDiscarded{Err: errors.Errorf("cannot decode redis array into %v", v.Type())}
-		return discardArrayAfterErr(br, int(l), err)
+		return a.discardArrayAfterErr(br, int(l), err)
 	}
 }
 
@@ -1123,9 +1683,57 @@ func canShareReflectValue(ty reflect.Type) bool {
 	}
 }
 
+// parseStructTag parses the value of a field's "redis" struct tag, which is the
+// name the field should be given, optionally followed by comma separated
+// options. The options currently supported are "omitempty" and "json", the
+// latter causing the field's value to be encoded as JSON (see JSON).
+func parseStructTag(tag string) (name string, omitEmpty, asJSON bool) {
+	parts := strings.Split(tag, ",")
+	for _, opt := range parts[1:] {
+		switch opt {
+		case "omitempty":
+			omitEmpty = true
+		case "json":
+			asJSON = true
+		}
+	}
+	return parts[0], omitEmpty, asJSON
+}
+
+type isZeroer interface {
+	IsZero() bool
+}
+
+// isEmptyValue returns whether a struct field with the omitempty option should
+// be omitted. The rules are the same as encoding/json's, except that structs
+// with an IsZero method (such as time.Time) are also considered empty if it
+// returns true.
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	case reflect.Struct:
+		if z, ok := v.Interface().(isZeroer); ok {
+			return z.IsZero()
+		}
+	}
+	return false
+}
+
 type structField struct {
 	name    string
 	fromTag bool // from a tag overwrites a field name
+	asJSON  bool
 	indices []int
 }
 
@@ -1160,9 +1768,12 @@ func getStructFields(t reflect.Type) map[string]structField {
 				continue
 			}
 
-			key, fromTag := ft.Name, false
+			key, fromTag, asJSON := ft.Name, false, false
 			if tag := ft.Tag.Get("redis"); tag != "" && tag != "-" {
-				key, fromTag = tag, true
+				var tagName string
+				if tagName, _, asJSON = parseStructTag(tag); tagName != "" {
+					key, fromTag = tagName, true
+				}
 			}
 			if m[key].fromTag {
 				continue
@@ -1170,6 +1781,7 @@ func getStructFields(t reflect.Type) map[string]structField {
 			m[key] = structField{
 				name:    key,
 				fromTag: fromTag,
+				asJSON:  asJSON,
 				indices: getIndices(parents, i),
 			}
 		}
@@ -1217,6 +1829,11 @@ func getStructField(v reflect.Value, ii []int) reflect.Value {
 // of a RESP message. When Marshaling the exact bytes of the RawMessage will be
 // written as-is. When Unmarshaling the bytes of a single RESP message will be
 // read into the RawMessage's bytes.
+//
+// Every kind of message is captured in full, including nested aggregates and
+// error messages, which are not returned as errors when unmarshaling into a
+// RawMessage. This makes RawMessage suitable for deferring the decoding of a
+// reply (see UnmarshalInto), or for passing replies on as-is, e.g. in a proxy.
 type RawMessage []byte
 
 // MarshalRESP implements the Marshaler method
@@ -1228,11 +1845,11 @@ func (rm RawMessage) MarshalRESP(w io.Writer) error {
 // UnmarshalRESP implements the Unmarshaler method
 func (rm *RawMessage) UnmarshalRESP(br *bufio.Reader) error {
 	*rm = (*rm)[:0]
-	return rm.unmarshal(br)
+	return rm.unmarshal(br, 0)
 }
 
-func (rm *RawMessage) unmarshal(br *bufio.Reader) error {
-	b, err := br.ReadSlice('\n')
+func (rm *RawMessage) unmarshal(br *bufio.Reader, depth int) error {
+	b, err := bytesutil.BufferedLine(br)
 	if err != nil {
 		return err
 	}
@@ -1244,29 +1861,49 @@ func (rm *RawMessage) unmarshal(br *bufio.Reader) error {
 	body := b[1 : len(b)-2]
 
 	switch b[0] {
-	case ArrayPrefix[0]:
+	case ArrayPrefix[0], MapPrefix[0], SetPrefix[0], AttributePrefix[0], PushPrefix[0]:
 		l, err := bytesutil.ParseInt(body)
 		if err != nil {
 			return err
+		} else if err := checkArrayLen(br, l); err != nil {
+			return err
 		} else if l == -1 {
 			return nil
 		}
+		if b[0] == MapPrefix[0] || b[0] == AttributePrefix[0] {
+			if l *= 2; l > 0 {
+				if err := checkArrayLen(br, l); err != nil {
+					return err
+				}
+			}
+		}
+		elDepth, err := nestedDepth(br, depth)
+		if err != nil {
+			return err
+		}
 		for i := 0; i < int(l); i++ {
-			if err := rm.unmarshal(br); err != nil {
+			if err := rm.unmarshal(br, elDepth); err != nil {
 				return err
 			}
 		}
+		// an attribute is always followed by the reply it's attached to
+		if b[0] == AttributePrefix[0] {
+			return rm.unmarshal(br, depth)
+		}
 		return nil
-	case BulkStringPrefix[0]:
+	case BulkStringPrefix[0], BlobErrorPrefix[0], VerbatimStringPrefix[0]:
 		l, err := bytesutil.ParseInt(body) // fuck DRY
 		if err != nil {
 			return err
+		} else if err := checkBulkStringLen(br, l); err != nil {
+			return err
 		} else if l == -1 {
 			return nil
 		}
 		*rm, err = bytesutil.ReadNAppend(br, *rm, int(l+2))
 		return err
-	case ErrorPrefix[0], SimpleStringPrefix[0], IntPrefix[0]:
+	case ErrorPrefix[0], SimpleStringPrefix[0], IntPrefix[0],
+		NullPrefix[0], DoublePrefix[0], BooleanPrefix[0], BigNumberPrefix[0]:
 		return nil
 	default:
 		return errors.Errorf("unknown type prefix %q", b[0])
@@ -1290,5 +1927,102 @@ func (rm RawMessage) UnmarshalInto(u resp.Unmarshaler) error {
 
 // IsNil returns true if the contents of RawMessage are one of the nil values.
 func (rm RawMessage) IsNil() bool {
-	return bytes.Equal(rm, nilBulkString) || bytes.Equal(rm, nilArray)
+	return bytes.Equal(rm, nilBulkString) || bytes.Equal(rm, nilArray) ||
+		bytes.Equal(rm, null)
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// Maybe is a Marshaler/Unmarshaler which wraps another value, Rcv, and is used
+// to distinguish a nil reply (a nil bulk string, nil array, or RESP3 null) from
+// a reply which is empty, e.g. a missing key from an empty string.
+//
+// When unmarshaling, if the message is nil then Nil is set to true and Rcv is
+// left untouched. Otherwise Nil is set to false and the message is unmarshaled
+// into Rcv as with Any.
+//
+// When marshaling, if Nil is true then a nil bulk string is written, otherwise
+// Rcv is marshaled as with Any.
+type Maybe struct {
+	Nil bool
+	Rcv interface{}
+}
+
+// MarshalRESP implements the Marshaler method.
+func (m Maybe) MarshalRESP(w io.Writer) error {
+	if m.Nil {
+		_, err := w.Write(nilBulkString)
+		return err
+	}
+	return Any{I: m.Rcv}.MarshalRESP(w)
+}
+
+// UnmarshalRESP implements the Unmarshaler method.
+func (m *Maybe) UnmarshalRESP(br *bufio.Reader) error {
+	var rm RawMessage
+	if err := rm.UnmarshalRESP(br); err != nil {
+		return err
+	} else if rm.IsNil() {
+		m.Nil = true
+		return nil
+	}
+
+	m.Nil = false
+	return rm.UnmarshalInto(Any{I: m.Rcv})
+}
+
+////////////////////////////////////////////////////////////////////////////////
+
+// JSON is a Marshaler/Unmarshaler which wraps another value, I, and encodes it
+// as JSON, using encoding/json. This is useful for storing small documents,
+// e.g. structs which would otherwise be flattened into their fields, as a
+// single value.
+//
+// When marshaling, I is encoded as JSON and written as a bulk string. JSON is
+// also an encoding.TextMarshaler, so it may be used wherever Any is, including
+// as an argument to radix.FlatCmd.
+//
+// When unmarshaling, I must be a pointer, and the message is decoded as JSON
+// into it. If the message is nil then I is left untouched. If the message
+// can't be decoded then a resp.ErrDiscarded is returned, since the message has
+// already been read off the wire.
+//
+// A struct field may also be encoded as JSON by giving the "json" option in
+// its "redis" tag, e.g.:
+//
+//	type User struct {
+//		Name  string            `redis:"name"`
+//		Prefs map[string]string `redis:"prefs,json"`
+//	}
+//
+type JSON struct {
+	I interface{}
+}
+
+// MarshalText implements the encoding.TextMarshaler interface.
+func (j JSON) MarshalText() ([]byte, error) {
+	return json.Marshal(j.I)
+}
+
+// MarshalRESP implements the Marshaler method.
+func (j JSON) MarshalRESP(w io.Writer) error {
+	b, err := j.MarshalText()
+	if err != nil {
+		return err
+	}
+	return BulkStringBytes{B: b, MarshalNotNil: true}.MarshalRESP(w)
+}
+
+// UnmarshalRESP implements the Unmarshaler method.
+func (j JSON) UnmarshalRESP(br *bufio.Reader) error {
+	var b []byte
+	m := Maybe{Rcv: &b}
+	if err := m.UnmarshalRESP(br); err != nil {
+		return err
+	} else if m.Nil {
+		return nil
+	} else if err := json.Unmarshal(b, j.I); err != nil {
+		return resp.ErrDiscarded{Err: err}
+	}
+	return nil
 }
@@ -3,9 +3,15 @@ package resp2
 import (
 	"bufio"
 	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
 	"reflect"
 	"strings"
+	"sync/atomic"
 	. "testing"
+	"time"
 
 	errors "golang.org/x/xerrors"
 
@@ -158,6 +164,14 @@ type testStructInner struct {
 	Boz *int
 }
 
+func bigInt(s string) *big.Int {
+	i, ok := new(big.Int).SetString(s, 10)
+	if !ok {
+		panic(fmt.Sprintf("invalid big.Int %q", s))
+	}
+	return i
+}
+
 func intPtr(i int) *int {
 	return &i
 }
@@ -176,6 +190,14 @@ type testStructC struct {
 	Biz *string
 }
 
+type testStructD struct {
+	Name    string    `redis:"name,omitempty"`
+	Age     int       `redis:"age,omitempty"`
+	Admin   bool      `redis:"admin,omitempty"`
+	Score   float64   `redis:",omitempty"`
+	Created time.Time `redis:"created,omitempty"`
+}
+
 type textCPMarshaler []byte
 
 func (cm textCPMarshaler) MarshalText() ([]byte, error) {
@@ -190,6 +212,19 @@ func (cm binCPMarshaler) MarshalBinary() ([]byte, error) {
 	return cm, nil
 }
 
+// textPtrMarshaler's methods have pointer receivers, so it's only a
+// Text(Un)Marshaler when addressed.
+type textPtrMarshaler struct{ ID int }
+
+func (pm *textPtrMarshaler) MarshalText() ([]byte, error) {
+	return []byte(fmt.Sprintf("id:%d", pm.ID)), nil
+}
+
+func (pm *textPtrMarshaler) UnmarshalText(b []byte) error {
+	_, err := fmt.Sscanf(string(b), "id:%d", &pm.ID)
+	return err
+}
+
 func TestAnyMarshal(t *T) {
 	type encodeTest struct {
 		in             interface{}
@@ -212,6 +247,8 @@ func TestAnyMarshal(t *T) {
 		{in: float64(5.5), out: "$3\r\n5.5\r\n"},
 		{in: textCPMarshaler("ohey"), out: "$5\r\nohey_\r\n"},
 		{in: binCPMarshaler("ohey"), out: "$5\r\nohey_\r\n"},
+		{in: textPtrMarshaler{ID: 5}, out: "$4\r\nid:5\r\n"},
+		{in: &textPtrMarshaler{ID: 5}, out: "$4\r\nid:5\r\n"},
 		{in: "ohey", flat: true, out: "$4\r\nohey\r\n"},
 
 		// Int
@@ -231,6 +268,7 @@ func TestAnyMarshal(t *T) {
 		{in: []string{}, out: "*0\r\n"},
 		{in: []string{}, flat: true, out: ""},
 		{in: []string{"a", "b"}, out: "*2\r\n$1\r\na\r\n$1\r\nb\r\n"},
+		{in: []string{"a", ""}, flat: true, out: "$1\r\na\r\n$0\r\n\r\n"},
 		{in: []int{1, 2}, out: "*2\r\n:1\r\n:2\r\n"},
 		{in: []int{1, 2}, flat: true, out: ":1\r\n:2\r\n"},
 		{in: []int{1, 2}, forceStr: true, out: "*2\r\n$1\r\n1\r\n$1\r\n2\r\n"},
@@ -268,6 +306,15 @@ func TestAnyMarshal(t *T) {
 		{in: map[string]int{}, out: "*0\r\n"},
 		{in: map[string]int{}, flat: true, out: ""},
 		{in: map[string]int{"one": 1}, out: "*2\r\n$3\r\none\r\n:1\r\n"},
+		{in: map[string]string(nil), out: "*-1\r\n"},
+		{in: map[string]string(nil), flat: true, out: ""},
+		{in: map[string]string{}, out: "*0\r\n"},
+		{in: map[string]string{"one": "1"}, out: "*2\r\n$3\r\none\r\n$1\r\n1\r\n"},
+		{in: map[string]string{"one": ""}, flat: true, out: "$3\r\none\r\n$0\r\n\r\n"},
+		{
+			in:  map[textPtrMarshaler]textPtrMarshaler{{ID: 1}: {ID: 2}},
+			out: "*2\r\n$4\r\nid:1\r\n$4\r\nid:2\r\n",
+		},
 		{
 			in:  map[string]interface{}{"one": []byte("1")},
 			out: "*2\r\n$3\r\none\r\n$1\r\n1\r\n",
@@ -355,6 +402,18 @@ func TestAnyMarshal(t *T) {
 	}
 }
 
+func TestAnyNumElems(t *T) {
+	// the types which NumElems handles without reflection must give the same
+	// result as with it
+	for _, in := range []interface{}{
+		nil, "", "foo", []byte("foo"), true, 1, int64(1), 1.5,
+		[]string(nil), []string{"a", "b"},
+		map[string]string(nil), map[string]string{"a": "1", "b": "2"},
+	} {
+		assert.Equal(t, numElems(reflect.ValueOf(in)), Any{I: in}.NumElems(), "in: %#v", in)
+	}
+}
+
 type textCPUnmarshaler []byte
 
 func (cu *textCPUnmarshaler) UnmarshalText(b []byte) error {
@@ -400,6 +459,26 @@ func (w *writer) Write(b []byte) (int, error) {
 	return len(b), nil
 }
 
+func TestAnyMarshalLenReader(t *T) {
+	for _, in := range []interface{}{
+		resp.NewLenReader(strings.NewReader("ohey"), 4),
+		bytes.NewBufferString("ohey"),
+		bytes.NewReader([]byte("ohey")),
+		strings.NewReader("ohey"),
+	} {
+		a := Any{I: in}
+		assert.Equal(t, 1, a.NumElems())
+		buf := new(bytes.Buffer)
+		require.Nil(t, a.MarshalRESP(buf))
+		assert.Equal(t, "$4\r\nohey\r\n", buf.String())
+	}
+
+	// a LenReader which ends before its length is reached
+	a := Any{I: resp.NewLenReader(strings.NewReader("oh"), 4)}
+	err := a.MarshalRESP(new(bytes.Buffer))
+	assert.True(t, errors.Is(err, io.ErrUnexpectedEOF))
+}
+
 func TestAnyUnmarshal(t *T) {
 	type decodeTest struct {
 		in  string
@@ -432,6 +511,7 @@ func TestAnyUnmarshal(t *T) {
 			{in: "$4\r\nohey\r\n", preload: []byte("wut"), out: []byte("ohey")},
 			{in: "$4\r\nohey\r\n", preload: []byte("wutwut"), out: []byte("ohey")},
 			{in: "$4\r\nohey\r\n", out: textCPUnmarshaler("ohey")},
+			{in: "$4\r\nid:5\r\n", out: textPtrMarshaler{ID: 5}},
 			{in: "$4\r\nohey\r\n", out: binCPUnmarshaler("ohey")},
 			{in: "$4\r\nohey\r\n", out: writer("ohey")},
 			{in: "$2\r\n10\r\n", out: int(10)},
@@ -445,12 +525,15 @@ func TestAnyUnmarshal(t *T) {
 			{in: "+\r\n", out: ""},
 			{in: "+\r\n", out: []byte(nil)},
 			{in: "+ohey\r\n", out: "ohey"},
+			{in: "+OK\r\n", out: "OK"},
 			{in: "+ohey\r\n", out: []byte("ohey")},
 			{in: "+ohey\r\n", out: textCPUnmarshaler("ohey")},
 			{in: "+ohey\r\n", out: binCPUnmarshaler("ohey")},
 			{in: "+ohey\r\n", out: writer("ohey")},
 			{in: "+10\r\n", out: int(10)},
 			{in: "+10\r\n", out: uint(10)},
+			{in: "+10\r\n", out: int64(10)},
+			{in: "+10\r\n", out: uint64(10)},
 			{in: "+10.5\r\n", out: float32(10.5)},
 			{in: "+10.5\r\n", out: float64(10.5)},
 			{in: "+ohey\r\n", preloadEmpty: true, out: "ohey"},
@@ -468,6 +551,9 @@ func TestAnyUnmarshal(t *T) {
 			{in: ":1024\r\n", out: writer("1024")},
 			{in: ":1024\r\n", out: int(1024)},
 			{in: ":1024\r\n", out: uint(1024)},
+			{in: ":1024\r\n", out: int64(1024)},
+			{in: ":-1024\r\n", out: int64(-1024)},
+			{in: ":1024\r\n", out: uint64(1024)},
 			{in: ":1024\r\n", out: float32(1024)},
 			{in: ":1024\r\n", out: float64(1024)},
 			{in: ":1024\r\n", preloadEmpty: true, out: int64(1024)},
@@ -501,6 +587,14 @@ func TestAnyUnmarshal(t *T) {
 				out: []interface{}{[]interface{}{"foo", "bar"}, "baz"},
 			},
 			{in: "*2\r\n:1\r\n:2\r\n", out: map[string]string{"1": "2"}},
+			{
+				in:  "*4\r\n$1\r\na\r\n$1\r\n1\r\n$1\r\nb\r\n:2\r\n",
+				out: map[string]int64{"a": 1, "b": 2},
+			},
+			{
+				in:  "*4\r\n$1\r\na\r\n$3\r\n1.5\r\n$1\r\nb\r\n:2\r\n",
+				out: map[string]float64{"a": 1.5, "b": 2},
+			},
 			{in: "*2\r\n*2\r\n+foo\r\n+bar\r\n*1\r\n+baz\r\n", out: nil},
 			{
 				in: "*6\r\n" +
@@ -522,6 +616,10 @@ func TestAnyUnmarshal(t *T) {
 					"FOO":   "bar",
 				},
 			},
+			{
+				in:  "*2\r\n$4\r\nid:1\r\n$4\r\nid:2\r\n",
+				out: map[textPtrMarshaler]textPtrMarshaler{{ID: 1}: {ID: 2}},
+			},
 
 			// Arrays (structs)
 			{
@@ -557,6 +655,70 @@ func TestAnyUnmarshal(t *T) {
 					Biz: []byte("5"),
 				},
 			},
+
+			// RESP3 Null
+			{in: "_\r\n", out: []byte(nil)},
+			{in: "_\r\n", preload: []byte{1}, out: []byte(nil)},
+			{in: "_\r\n", preload: map[string]string{"a": "b"}, out: map[string]string(nil)},
+			{in: "_\r\n", out: nil},
+
+			// RESP3 Double
+			{in: ",10.5\r\n", out: float64(10.5)},
+			{in: ",10.5\r\n", out: "10.5"},
+			{in: ",inf\r\n", out: math.Inf(1)},
+			{in: ",-inf\r\n", out: math.Inf(-1)},
+			{in: ",10.5\r\n", preloadEmpty: true, out: float64(10.5)},
+
+			// RESP3 Boolean
+			{in: "#t\r\n", out: true},
+			{in: "#f\r\n", out: false},
+			{in: "#t\r\n", out: int(1)},
+			{in: "#f\r\n", out: int(0)},
+			{in: "#t\r\n", preloadEmpty: true, out: true},
+			{in: "#t\r\n", out: nil},
+
+			// RESP3 Big Number
+			{in: "(3492890328409238509324850943850943825024385\r\n", out: "3492890328409238509324850943850943825024385"},
+			{in: "(3492890328409238509324850943850943825024385\r\n", preloadEmpty: true, out: bigInt("3492890328409238509324850943850943825024385")},
+			{in: "(10\r\n", out: int(10)},
+
+			// RESP3 Blob Error
+			{in: "!9\r\nohey\r\nyou\r\n", out: "", shouldErr: "ohey\r\nyou"},
+			{in: "!4\r\nohey\r\n", out: nil, shouldErr: "ohey"},
+			{in: "!4\r\nohey\r\n", preloadEmpty: true, shouldErr: "ohey"},
+
+			// RESP3 Verbatim String
+			{in: "=8\r\ntxt:ohey\r\n", out: "ohey"},
+			{in: "=8\r\ntxt:ohey\r\n", out: []byte("ohey")},
+			{in: "=4\r\ntxt:\r\n", out: ""},
+			{in: "=8\r\ntxt:ohey\r\n", preloadEmpty: true, out: []byte("ohey")},
+			{in: "=8\r\ntxt:ohey\r\n", out: nil},
+
+			// RESP3 Map
+			{in: "%0\r\n", preload: map[string]string(nil), out: map[string]string{}},
+			{in: "%2\r\n+foo\r\n:1\r\n+bar\r\n:2\r\n", out: map[string]int{"foo": 1, "bar": 2}},
+			{in: "%2\r\n+foo\r\n$1\r\n1\r\n+bar\r\n:2\r\n", out: map[string]int64{"foo": 1, "bar": 2}},
+			{in: "%2\r\n+foo\r\n,1.5\r\n+bar\r\n$1\r\n2\r\n", out: map[string]float64{"foo": 1.5, "bar": 2}},
+			{in: "%2\r\n+foo\r\n$1\r\n1\r\n+bar\r\n:2\r\n", out: map[string]string{"foo": "1", "bar": "2"}},
+			{in: "%2\r\n+foo\r\n:1\r\n+bar\r\n:2\r\n", out: []string{"foo", "1", "bar", "2"}},
+			{
+				in:           "%2\r\n+foo\r\n:1\r\n+bar\r\n#t\r\n",
+				preloadEmpty: true,
+				out:          []interface{}{"foo", int64(1), "bar", true},
+			},
+			{in: "%1\r\n+foo\r\n%1\r\n+bar\r\n+baz\r\n", out: map[string]map[string]string{"foo": {"bar": "baz"}}},
+			{in: "%1\r\n+foo\r\n%1\r\n+bar\r\n+baz\r\n", out: nil},
+
+			// RESP3 Set and Push
+			{in: "~2\r\n+foo\r\n+bar\r\n", out: []string{"foo", "bar"}},
+			{in: "~2\r\n+foo\r\n_\r\n", preloadEmpty: true, out: []interface{}{"foo", nil}},
+			{in: ">3\r\n+message\r\n+foo\r\n+bar\r\n", out: []string{"message", "foo", "bar"}},
+			{in: ">3\r\n+message\r\n+foo\r\n+bar\r\n", out: nil},
+
+			// RESP3 Attribute
+			{in: "|1\r\n+ttl\r\n:3600\r\n+ohey\r\n", out: "ohey"},
+			{in: "|1\r\n+ttl\r\n:3600\r\n*2\r\n:1\r\n:2\r\n", preloadEmpty: true, out: []interface{}{int64(1), int64(2)}},
+			{in: "|1\r\n+ttl\r\n:3600\r\n+ohey\r\n", out: nil},
 		}
 	}
 
@@ -632,6 +794,16 @@ func TestRawMessage(t *T) {
 		{b: "$8\r\nfoo\r\nbar\r\n"},
 		{b: "*2\r\n:1\r\n:2\r\n"},
 		{b: "*-1\r\n", isNil: true},
+		{b: "_\r\n", isNil: true},
+		{b: ",1.5\r\n"},
+		{b: "#t\r\n"},
+		{b: "(3492890328409238509324850943850943825024385\r\n"},
+		{b: "!3\r\nfoo\r\n"},
+		{b: "=7\r\ntxt:foo\r\n"},
+		{b: "%1\r\n+foo\r\n*2\r\n:1\r\n:2\r\n"},
+		{b: "~2\r\n:1\r\n:2\r\n"},
+		{b: ">2\r\n+foo\r\n+bar\r\n"},
+		{b: "|1\r\n+ttl\r\n:5\r\n+foo\r\n"},
 	}
 
 	// one at a time
@@ -666,6 +838,148 @@ func TestRawMessage(t *T) {
 	}
 }
 
+func TestMaybe(t *T) {
+	for _, test := range []struct {
+		in    string
+		isNil bool
+		exp   string
+	}{
+		{in: "$-1\r\n", isNil: true},
+		{in: "*-1\r\n", isNil: true},
+		{in: "_\r\n", isNil: true},
+		{in: "$0\r\n\r\n", exp: ""},
+		{in: "$3\r\nfoo\r\n", exp: "foo"},
+		{in: "+bar\r\n", exp: "bar"},
+	} {
+		// start with Nil set, to ensure it's reset for non-nil messages
+		var s string
+		m := Maybe{Nil: true, Rcv: &s}
+		require.Nil(t, m.UnmarshalRESP(bufio.NewReader(bytes.NewBufferString(test.in))))
+		assert.Equal(t, test.isNil, m.Nil, "in:%q", test.in)
+		assert.Equal(t, test.exp, s, "in:%q", test.in)
+	}
+
+	buf := new(bytes.Buffer)
+	require.Nil(t, Maybe{Nil: true, Rcv: "foo"}.MarshalRESP(buf))
+	require.Nil(t, Maybe{Rcv: "foo"}.MarshalRESP(buf))
+	assert.Equal(t, "$-1\r\n$3\r\nfoo\r\n", buf.String())
+}
+
+func TestAnyTimeUnit(t *T) {
+	ts := time.Unix(1577934245, 0)
+	type withTimes struct {
+		Created time.Time
+		TTL     time.Duration
+	}
+
+	marshal := func(a Any) string {
+		buf := new(bytes.Buffer)
+		require.Nil(t, a.MarshalRESP(buf))
+		return buf.String()
+	}
+	assert.Equal(t, ":1577934245\r\n", marshal(Any{I: ts, TimeUnit: time.Second}))
+	assert.Equal(t, ":1577934245000\r\n", marshal(Any{I: ts, TimeUnit: time.Millisecond}))
+	assert.Equal(t, ":90\r\n", marshal(Any{I: 90 * time.Second, TimeUnit: time.Second}))
+	assert.Equal(t, "$4\r\n1500\r\n", marshal(Any{
+		I: 1500 * time.Millisecond, TimeUnit: time.Millisecond, MarshalBulkString: true,
+	}))
+	assert.Equal(t, "*4\r\n$7\r\nCreated\r\n:1577934245\r\n$3\r\nTTL\r\n:10\r\n", marshal(Any{
+		I: withTimes{Created: ts, TTL: 10 * time.Second}, TimeUnit: time.Second,
+	}))
+
+	// without a TimeUnit the previous behavior is kept
+	assert.Equal(t, "$20\r\n2020-01-02T03:04:05Z\r\n", marshal(Any{I: ts.UTC()}))
+	assert.Equal(t, ":1000\r\n", marshal(Any{I: time.Microsecond}))
+
+	unmarshal := func(in string, a Any) {
+		br := bufio.NewReader(bytes.NewBufferString(in))
+		require.Nil(t, a.UnmarshalRESP(br), "in:%q", in)
+	}
+
+	var d time.Duration
+	unmarshal(":90\r\n", Any{I: &d, TimeUnit: time.Second})
+	assert.Equal(t, 90*time.Second, d)
+	unmarshal("$4\r\n1500\r\n", Any{I: &d, TimeUnit: time.Millisecond})
+	assert.Equal(t, 1500*time.Millisecond, d)
+	unmarshal(":-2\r\n", Any{I: &d, TimeUnit: time.Second})
+	assert.Equal(t, -2*time.Second, d)
+	unmarshal(":1000\r\n", Any{I: &d})
+	assert.Equal(t, time.Microsecond, d)
+
+	var tt time.Time
+	unmarshal(":1577934245\r\n", Any{I: &tt, TimeUnit: time.Second})
+	assert.True(t, ts.Equal(tt))
+	unmarshal(":1577934245000\r\n", Any{I: &tt, TimeUnit: time.Millisecond})
+	assert.True(t, ts.Equal(tt))
+	unmarshal("$20\r\n2020-01-02T03:04:05Z\r\n", Any{I: &tt})
+	assert.True(t, ts.Equal(tt))
+
+	var dd []time.Duration
+	unmarshal("*2\r\n:1\r\n:2\r\n", Any{I: &dd, TimeUnit: time.Second})
+	assert.Equal(t, []time.Duration{time.Second, 2 * time.Second}, dd)
+
+	var m map[string]time.Time
+	unmarshal("*2\r\n$1\r\na\r\n:1577934245\r\n", Any{I: &m, TimeUnit: time.Second})
+	assert.True(t, ts.Equal(m["a"]))
+
+	var wt withTimes
+	unmarshal("*4\r\n$7\r\nCreated\r\n:1577934245\r\n$3\r\nTTL\r\n:10\r\n", Any{I: &wt, TimeUnit: time.Second})
+	assert.True(t, ts.Equal(wt.Created))
+	assert.Equal(t, 10*time.Second, wt.TTL)
+}
+
+func TestJSON(t *T) {
+	type doc struct {
+		Name string   `json:"name"`
+		Tags []string `json:"tags"`
+	}
+	d := doc{Name: "foo", Tags: []string{"a", "b"}}
+	js := `{"name":"foo","tags":["a","b"]}`
+	jsRESP := fmt.Sprintf("$%d\r\n%s\r\n", len(js), js)
+
+	buf := new(bytes.Buffer)
+	require.Nil(t, JSON{I: d}.MarshalRESP(buf))
+	assert.Equal(t, jsRESP, buf.String())
+
+	// as a TextMarshaler it's handled by Any too
+	buf.Reset()
+	require.Nil(t, Any{I: JSON{I: d}}.MarshalRESP(buf))
+	assert.Equal(t, jsRESP, buf.String())
+
+	var got doc
+	require.Nil(t, JSON{I: &got}.UnmarshalRESP(bufio.NewReader(bytes.NewBufferString(jsRESP))))
+	assert.Equal(t, d, got)
+
+	// nil messages leave I untouched
+	require.Nil(t, JSON{I: &got}.UnmarshalRESP(bufio.NewReader(bytes.NewBufferString("$-1\r\n"))))
+	assert.Equal(t, d, got)
+
+	// invalid JSON is discarded, leaving the next message intact
+	br := bufio.NewReader(bytes.NewBufferString("$3\r\nfoo\r\n:1\r\n"))
+	err := JSON{I: &got}.UnmarshalRESP(br)
+	assert.True(t, errors.As(err, new(resp.ErrDiscarded)))
+	var i int
+	require.Nil(t, Any{I: &i}.UnmarshalRESP(br))
+	assert.Equal(t, 1, i)
+
+	type withJSON struct {
+		ID  int  `redis:"id"`
+		Doc doc  `redis:"doc,json"`
+		Ptr *doc `redis:"ptr,json,omitempty"`
+	}
+	wj := withJSON{ID: 1, Doc: d}
+	wjRESP := "*4\r\n$2\r\nid\r\n:1\r\n$3\r\ndoc\r\n" + jsRESP
+	a := Any{I: wj}
+	assert.Equal(t, 4, a.NumElems())
+	buf.Reset()
+	require.Nil(t, a.MarshalRESP(buf))
+	assert.Equal(t, wjRESP, buf.String())
+
+	var gotWJ withJSON
+	require.Nil(t, Any{I: &gotWJ}.UnmarshalRESP(bufio.NewReader(bytes.NewBufferString(wjRESP))))
+	assert.Equal(t, wj, gotWJ)
+}
+
 func TestAnyConsumedOnErr(t *T) {
 	type foo struct {
 		Foo int
@@ -684,6 +998,9 @@ func TestAnyConsumedOnErr(t *T) {
 		{BulkString{S: "bulkStr"}, new(unknownType)},
 		{SimpleString{S: "bulkStr"}, new(unknownType)},
 		{Int{I: 1}, new(unknownType)},
+		{SimpleString{S: "one"}, new(int)},
+		{SimpleString{S: "one"}, new(int64)},
+		{Int{I: -1}, new(uint64)},
 		{Any{I: []string{"one", "2", "three"}}, new([]int)},
 		{Any{I: []string{"1", "2", "three", "four"}}, new([]int)},
 		{Any{I: []string{"1", "2", "3", "four"}}, new([]int)},
@@ -729,3 +1046,152 @@ func TestErrorAs(t *T) {
 		assert.Equal(t, *err, errDiscarded.Err)
 	}
 }
+
+func TestArrayHeaderRESP3(t *T) {
+	for in, n := range map[string]int{
+		"*2\r\n": 2,
+		"~2\r\n": 2,
+		">3\r\n": 3,
+		"%2\r\n": 4,
+	} {
+		var ah ArrayHeader
+		require.Nil(t, ah.UnmarshalRESP(bufio.NewReader(strings.NewReader(in))))
+		assert.Equal(t, n, ah.N, "in:%q", in)
+	}
+}
+
+func TestErrorIs(t *T) {
+	err := Error{E: errors.New("WRONGTYPE Operation against a key holding the wrong kind of value")}
+	assert.Equal(t, "WRONGTYPE", err.Prefix())
+	assert.True(t, errors.Is(err, ErrWrongType))
+	assert.False(t, errors.Is(err, ErrMoved))
+
+	wrapped := errors.Errorf("doing thing: %w", err)
+	assert.True(t, errors.Is(wrapped, ErrWrongType))
+
+	err = Error{E: errors.New("MOVED 3999 127.0.0.1:6381")}
+	assert.True(t, errors.Is(err, ErrMoved))
+	assert.False(t, errors.Is(err, ErrAsk))
+
+	err = Error{E: errors.New("NOSCRIPT: no such script")}
+	assert.True(t, errors.Is(err, ErrNoScript))
+
+	err = Error{E: errors.New("ERR")}
+	assert.Equal(t, "ERR", err.Prefix())
+	assert.True(t, errors.Is(err, ErrorKind("ERR")))
+
+	assert.Equal(t, "", Error{}.Prefix())
+	assert.False(t, errors.Is(Error{}, ErrMoved))
+}
+
+func TestAnyStructTagOpts(t *T) {
+	full := testStructD{
+		Name:    "alice",
+		Age:     30,
+		Admin:   true,
+		Score:   1.5,
+		Created: time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+	fullFlat := "$4\r\nname\r\n" + "$5\r\nalice\r\n" +
+		"$3\r\nage\r\n" + "$2\r\n30\r\n" +
+		"$5\r\nadmin\r\n" + "$1\r\n1\r\n" +
+		"$5\r\nScore\r\n" + "$3\r\n1.5\r\n" +
+		"$7\r\ncreated\r\n" + "$20\r\n2020-01-02T03:04:05Z\r\n"
+
+	// marshal the way FlatCmd does
+	{
+		buf := new(bytes.Buffer)
+		a := Any{I: full, MarshalBulkString: true, MarshalNoArrayHeaders: true}
+		require.Nil(t, a.MarshalRESP(buf))
+		assert.Equal(t, 10, a.NumElems())
+		assert.Equal(t, fullFlat, buf.String())
+	}
+
+	// empty fields are omitted
+	{
+		buf := new(bytes.Buffer)
+		a := Any{I: testStructD{Age: 1}}
+		require.Nil(t, a.MarshalRESP(buf))
+		assert.Equal(t, 2, a.NumElems())
+		assert.Equal(t, "*2\r\n$3\r\nage\r\n:1\r\n", buf.String())
+	}
+
+	// unmarshal the way HGETALL's reply would be, with bools given in any form
+	// strconv.ParseBool supports
+	{
+		in := "*10\r\n" + strings.Replace(fullFlat, "$1\r\n1\r\n", "$4\r\ntrue\r\n", 1)
+		var out testStructD
+		require.Nil(t, Any{I: &out}.UnmarshalRESP(bufio.NewReader(strings.NewReader(in))))
+		assert.Equal(t, full, out)
+	}
+}
+
+func TestLimits(t *T) {
+	var limits Limits
+	unmarshal := func(in string, u resp.Unmarshaler) error {
+		br := bufio.NewReader(strings.NewReader(in))
+		SetReaderLimits(br, limits)
+		defer SetReaderLimits(br, Limits{})
+		return u.UnmarshalRESP(br)
+	}
+	assertExceeded := func(in string, u resp.Unmarshaler) {
+		err := unmarshal(in, u)
+		assert.True(t, errors.Is(err, ErrLimitExceeded), "in:%q u:%T err:%v", in, u, err)
+		assert.False(t, errors.As(err, new(resp.ErrDiscarded)), "in:%q u:%T", in, u)
+	}
+
+	// by default there are no limits
+	assert.NoError(t, unmarshal("*99999999999\r\n", new(ArrayHeader)))
+	assert.NoError(t, unmarshal(strings.Repeat("*1\r\n", 1000)+":1\r\n", Any{}))
+
+	// invalid lengths are always rejected
+	for _, in := range []string{"$-2\r\n", "*-2\r\n", "%-2\r\n"} {
+		assert.Error(t, unmarshal(in, Any{I: new(interface{})}), "in:%q", in)
+		assert.Error(t, unmarshal(in, new(RawMessage)), "in:%q", in)
+	}
+
+	limits = Limits{MaxBulkStringLen: 3, MaxArrayLen: 4, MaxNestingDepth: 2}
+	for _, u := range []func() resp.Unmarshaler{
+		func() resp.Unmarshaler { return new(BulkStringBytes) },
+		func() resp.Unmarshaler { return new(BulkString) },
+		func() resp.Unmarshaler { return new(RawMessage) },
+		func() resp.Unmarshaler { return Any{I: new(string)} },
+		func() resp.Unmarshaler { return Any{I: new(interface{})} },
+		func() resp.Unmarshaler { return Any{} },
+	} {
+		assert.NoError(t, unmarshal("$3\r\nfoo\r\n", u()))
+		assertExceeded("$4\r\nfooo\r\n", u())
+	}
+	assertExceeded("!4\r\nfooo\r\n", Any{})
+	assertExceeded("=8\r\ntxt:fooo\r\n", Any{I: new(string)})
+
+	for _, u := range []func() resp.Unmarshaler{
+		func() resp.Unmarshaler { return new(ArrayHeader) },
+		func() resp.Unmarshaler { return new(RawMessage) },
+		func() resp.Unmarshaler { return Any{I: new([]int)} },
+		func() resp.Unmarshaler { return Any{I: new(interface{})} },
+		func() resp.Unmarshaler { return Any{} },
+	} {
+		assert.NoError(t, unmarshal("*4\r\n:1\r\n:2\r\n:3\r\n:4\r\n", u()))
+		assertExceeded("*5\r\n", u())
+		assert.NoError(t, unmarshal("%2\r\n:1\r\n:2\r\n:3\r\n:4\r\n", u()))
+		assertExceeded("%3\r\n", u())
+	}
+	assertExceeded("|3\r\n", Any{I: new(int)})
+
+	for _, u := range []func() resp.Unmarshaler{
+		func() resp.Unmarshaler { return new(RawMessage) },
+		func() resp.Unmarshaler { return Any{I: new([][]int)} },
+		func() resp.Unmarshaler { return Any{I: new(interface{})} },
+		func() resp.Unmarshaler { return Any{} },
+	} {
+		assert.NoError(t, unmarshal("*1\r\n*1\r\n:1\r\n", u()))
+		assertExceeded("*1\r\n*1\r\n*1\r\n:1\r\n", u())
+	}
+	// nesting is counted when the array is being discarded due to an error
+	assertExceeded("*2\r\n$1\r\na\r\n*1\r\n*1\r\n:1\r\n", Any{I: new([]int)})
+
+	// the limits only apply to the reader they're set on
+	assert.NoError(t, Any{}.UnmarshalRESP(bufio.NewReader(strings.NewReader("*5\r\n:1\r\n:2\r\n:3\r\n:4\r\n:5\r\n"))))
+	assert.Zero(t, atomic.LoadInt64(&numLimitedReaders))
+}
@@ -0,0 +1,214 @@
+package radix
+
+import (
+	"context"
+	"io"
+	"math/rand"
+	"net"
+	"time"
+
+	errors "golang.org/x/xerrors"
+
+	"github.com/mediocregopher/radix/v3/resp/resp2"
+)
+
+type retryOpts struct {
+	maxAttempts            int
+	maxElapsed             time.Duration
+	minBackoff, maxBackoff time.Duration
+	isRetryable            func(error) bool
+}
+
+// RetryOpt is an optional behavior which can be applied to the Retry function
+// to effect its behavior.
+type RetryOpt func(*retryOpts)
+
+// RetryMaxAttempts sets the maximum number of times an Action will be
+// attempted, including the first attempt. If n is 0 or less then there is no
+// limit, and RetryMaxElapsed should be used instead.
+func RetryMaxAttempts(n int) RetryOpt {
+	return func(ro *retryOpts) {
+		ro.maxAttempts = n
+	}
+}
+
+// RetryMaxElapsed sets the maximum amount of time which will be spent on an
+// Action, across all of its attempts. Once the duration has elapsed no further
+// attempts will be made, and the error from the final attempt is returned. If
+// d is 0 then there is no limit.
+func RetryMaxElapsed(d time.Duration) RetryOpt {
+	return func(ro *retryOpts) {
+		ro.maxElapsed = d
+	}
+}
+
+// RetryBackoff sets the bounds on how long to wait between attempts. The
+// upper bound on each wait starts at min and doubles after every failed
+// attempt, up to max. The actual wait is chosen randomly between zero and that
+// upper bound (aka "full jitter"), so that many clients retrying at once won't
+// all do so in lockstep.
+func RetryBackoff(min, max time.Duration) RetryOpt {
+	return func(ro *retryOpts) {
+		ro.minBackoff = min
+		ro.maxBackoff = max
+	}
+}
+
+// RetryIf sets the function used to determine whether an error returned from
+// an Action is retryable. See Retry for the default.
+func RetryIf(fn func(error) bool) RetryOpt {
+	return func(ro *retryOpts) {
+		ro.isRetryable = fn
+	}
+}
+
+// isRetryableErr is the default function used by RetryIf.
+func isRetryableErr(err error) bool {
+	var netErr net.Error
+	switch {
+	case errors.As(err, &netErr):
+		return true
+	case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return true
+	case errors.Is(err, resp2.ErrLoading),
+		errors.Is(err, resp2.ErrClusterDown),
+		errors.Is(err, resp2.ErrTryAgain):
+		return true
+	default:
+		return false
+	}
+}
+
+type retryClient struct {
+	Client
+	opts retryOpts
+}
+
+// Retry wraps a Client such that Actions which fail with a retryable error
+// are performed again, with an exponential backoff between each attempt.
+//
+// Because the Action is simply performed again, Retry should only be used for
+// Actions which are safe to repeat. A network error in particular may occur
+// after redis has already processed a command.
+//
+// Only Actions which implement ClusterCanRetryAction, and whose
+// ClusterCanRetry method returns true, are retried. Of those created by this
+// package this includes Cmd, FlatCmd (unless it's given an io.Reader argument,
+// which can't be read again), EvalScript.Cmd and Function.Cmd, but not
+// Pipeline or WithConn. Any other Action can be retried by wrapping a function
+// which creates it with RetryableAction.
+//
+// By default an error is retryable if it is a net.Error, an io.EOF or
+// io.ErrUnexpectedEOF, or a redis error of the LOADING, CLUSTERDOWN, or
+// TRYAGAIN kinds. RetryIf can be used to change this.
+//
+// The returned Client implements ContextClient. If DoContext is used then the
+// wait between attempts will be cut short if the Context is canceled.
+//
+// The default options Retry uses are:
+//
+//	RetryMaxAttempts(3)
+//	RetryMaxElapsed(0)
+//	RetryBackoff(10 * time.Millisecond, 1 * time.Second)
+//	RetryIf(/* see above */)
+func Retry(c Client, opts ...RetryOpt) Client {
+	rc := &retryClient{Client: c}
+
+	defaultRetryOpts := []RetryOpt{
+		RetryMaxAttempts(3),
+		RetryMaxElapsed(0),
+		RetryBackoff(10*time.Millisecond, 1*time.Second),
+		RetryIf(isRetryableErr),
+	}
+
+	for _, opt := range append(defaultRetryOpts, opts...) {
+		opt(&(rc.opts))
+	}
+	return rc
+}
+
+func (rc *retryClient) backoff(attempt int) time.Duration {
+	max := rc.opts.minBackoff
+	for i := 1; i < attempt && max < rc.opts.maxBackoff; i++ {
+		max *= 2
+	}
+	if max > rc.opts.maxBackoff {
+		max = rc.opts.maxBackoff
+	}
+	if max <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(max) + 1))
+}
+
+// Do implements the method for the Client interface.
+func (rc *retryClient) Do(a Action) error {
+	return rc.DoContext(context.Background(), a)
+}
+
+// DoContext implements the method for the ContextClient interface.
+func (rc *retryClient) DoContext(ctx context.Context, a Action) error {
+	if ccra, ok := a.(ClusterCanRetryAction); !ok || !ccra.ClusterCanRetry() {
+		return DoContext(ctx, rc.Client, a)
+	}
+
+	start := time.Now()
+	for attempt := 1; ; attempt++ {
+		err := DoContext(ctx, rc.Client, a)
+		if err == nil || !rc.opts.isRetryable(err) || ctx.Err() != nil {
+			return err
+		} else if rc.opts.maxAttempts > 0 && attempt >= rc.opts.maxAttempts {
+			return err
+		}
+
+		wait := rc.backoff(attempt)
+		if rc.opts.maxElapsed > 0 && time.Since(start)+wait >= rc.opts.maxElapsed {
+			return err
+		}
+
+		t := getTimer(wait)
+		select {
+		case <-t.C:
+			putTimer(t)
+		case <-ctx.Done():
+			putTimer(t)
+			return err
+		}
+	}
+}
+
+type retryableAction struct {
+	fn   func() Action
+	next Action
+	keys []string
+}
+
+// RetryableAction returns an Action which performs the Action returned by the
+// given function, calling the function again to create a new Action each time
+// it's performed. This allows Actions which can't be performed more than once,
+// such as a Pipeline or a FlatCmd with an io.Reader argument, to be retried by
+// Retry or by a Cluster.
+//
+// The function is called once by RetryableAction itself, in order to determine
+// the Action's keys, with that first Action being the one performed first.
+func RetryableAction(fn func() Action) Action {
+	a := fn()
+	return &retryableAction{fn: fn, next: a, keys: a.Keys()}
+}
+
+func (ra *retryableAction) Keys() []string {
+	return ra.keys
+}
+
+func (ra *retryableAction) Run(c Conn) error {
+	a := ra.next
+	if a == nil {
+		a = ra.fn()
+	}
+	ra.next = nil
+	return a.Run(c)
+}
+
+func (ra *retryableAction) ClusterCanRetry() bool {
+	return true
+}
@@ -0,0 +1,135 @@
+package radix
+
+import (
+	"context"
+	"strings"
+	. "testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	errors "golang.org/x/xerrors"
+
+	"github.com/mediocregopher/radix/v3/resp"
+	"github.com/mediocregopher/radix/v3/resp/resp2"
+)
+
+// failingStub returns a Stub which responds to the first n commands with the
+// given error, and with OK after that. The returned pointer holds the number of
+// commands which have been received.
+func failingStub(n int, err error) (Conn, *int) {
+	var calls int
+	return Stub("tcp", "127.0.0.1:6379", func([]string) interface{} {
+		calls++
+		if calls <= n {
+			return err
+		}
+		return "OK"
+	}), &calls
+}
+
+func TestRetry(t *T) {
+	loadingErr := resp2.Error{E: errors.New("LOADING Redis is loading the dataset in memory")}
+
+	t.Run("Success", func(t *T) {
+		stub, calls := failingStub(2, loadingErr)
+		c := Retry(stub, RetryBackoff(time.Millisecond, time.Millisecond))
+
+		var out string
+		require.Nil(t, c.Do(Cmd(&out, "GET", "foo")))
+		assert.Equal(t, "OK", out)
+		assert.Equal(t, 3, *calls)
+	})
+
+	t.Run("MaxAttempts", func(t *T) {
+		stub, calls := failingStub(5, loadingErr)
+		c := Retry(stub, RetryMaxAttempts(4), RetryBackoff(time.Millisecond, time.Millisecond))
+
+		err := c.Do(Cmd(nil, "GET", "foo"))
+		assert.True(t, errors.Is(err, resp2.ErrLoading))
+		assert.Equal(t, 4, *calls)
+	})
+
+	t.Run("MaxElapsed", func(t *T) {
+		stub, calls := failingStub(100, loadingErr)
+		c := Retry(stub,
+			RetryMaxAttempts(0),
+			RetryMaxElapsed(50*time.Millisecond),
+			RetryBackoff(5*time.Millisecond, 5*time.Millisecond),
+		)
+
+		start := time.Now()
+		err := c.Do(Cmd(nil, "GET", "foo"))
+		assert.True(t, errors.Is(err, resp2.ErrLoading))
+		// the final wait is skipped if it would exceed the maximum, but timers
+		// may still fire a bit late
+		assert.True(t, time.Since(start) < 100*time.Millisecond)
+		assert.True(t, *calls > 1 && *calls < 100)
+	})
+
+	t.Run("NotRetryable", func(t *T) {
+		stub, calls := failingStub(1, resp2.Error{E: errors.New("WRONGTYPE wrong kind of value")})
+		c := Retry(stub)
+
+		err := c.Do(Cmd(nil, "GET", "foo"))
+		assert.True(t, errors.Is(err, resp2.ErrWrongType))
+		assert.Equal(t, 1, *calls)
+	})
+
+	t.Run("RetryIf", func(t *T) {
+		stub, calls := failingStub(1, resp2.Error{E: errors.New("WRONGTYPE wrong kind of value")})
+		c := Retry(stub,
+			RetryBackoff(time.Millisecond, time.Millisecond),
+			RetryIf(func(err error) bool { return errors.Is(err, resp2.ErrWrongType) }),
+		)
+
+		require.Nil(t, c.Do(Cmd(nil, "GET", "foo")))
+		assert.Equal(t, 2, *calls)
+	})
+
+	t.Run("Context", func(t *T) {
+		stub, calls := failingStub(100, loadingErr)
+		// the wait is chosen randomly up to the backoff, so it is very large in
+		// order to make a short wait sufficiently unlikely
+		c := Retry(stub, RetryMaxAttempts(0), RetryBackoff(time.Hour, time.Hour))
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		start := time.Now()
+		err := c.(ContextClient).DoContext(ctx, Cmd(nil, "GET", "foo"))
+		assert.True(t, errors.Is(err, resp2.ErrLoading))
+		assert.True(t, time.Since(start) < time.Second)
+		assert.Equal(t, 1, *calls)
+	})
+
+	t.Run("NotRetryableAction", func(t *T) {
+		// Actions which can't be performed more than once aren't retried
+		stub, calls := failingStub(1, loadingErr)
+		c := Retry(stub, RetryBackoff(time.Millisecond, time.Millisecond))
+		err := c.Do(Pipeline(Cmd(nil, "GET", "foo")))
+		assert.True(t, errors.Is(err, resp2.ErrLoading))
+		assert.Equal(t, 1, *calls)
+
+		stub, calls = failingStub(1, loadingErr)
+		c = Retry(stub, RetryBackoff(time.Millisecond, time.Millisecond))
+		body := strings.NewReader("bar")
+		err = c.Do(FlatCmd(nil, "SET", "foo", resp.NewLenReader(body, int64(body.Len()))))
+		assert.True(t, errors.Is(err, resp2.ErrLoading))
+		assert.Equal(t, 1, *calls)
+	})
+
+	t.Run("RetryableAction", func(t *T) {
+		stub, calls := failingStub(2, loadingErr)
+		c := Retry(stub, RetryBackoff(time.Millisecond, time.Millisecond))
+
+		var created int
+		var out string
+		require.Nil(t, c.Do(RetryableAction(func() Action {
+			created++
+			return Pipeline(Cmd(&out, "GET", "foo"))
+		})))
+		assert.Equal(t, "OK", out)
+		assert.Equal(t, 3, *calls)
+		assert.Equal(t, 3, created)
+	})
+}
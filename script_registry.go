@@ -0,0 +1,105 @@
+package radix
+
+import (
+	"sync"
+)
+
+// LoadScripts returns an Action which loads the given EvalScripts into redis'
+// script cache using SCRIPT LOAD, with all of the commands being sent in a
+// single Pipeline. This avoids the EVALSHA performed by each script's first
+// Action falling back to EVAL.
+//
+// Since SCRIPT LOAD doesn't have any keys, when used with Cluster the scripts
+// are only loaded onto a random node. Use a ScriptRegistry to load them onto
+// every node.
+func LoadScripts(scripts ...EvalScript) Action {
+	cmds := make([]CmdAction, len(scripts))
+	for i, s := range scripts {
+		cmds[i] = Cmd(nil, "SCRIPT", "LOAD", s.script)
+	}
+	return Pipeline(cmds...)
+}
+
+// ScriptRegistry holds a set of EvalScripts which are loaded into the script
+// cache of redis instances ahead of them being used, see PoolScriptRegistry
+// and ClusterScriptRegistry.
+//
+// A ScriptRegistry is safe for concurrent use, and may be shared between many
+// Pools and Clusters.
+type ScriptRegistry struct {
+	l       sync.RWMutex
+	scripts []EvalScript
+}
+
+// NewScriptRegistry initializes a ScriptRegistry holding the given scripts.
+func NewScriptRegistry(scripts ...EvalScript) *ScriptRegistry {
+	return &ScriptRegistry{scripts: append([]EvalScript(nil), scripts...)}
+}
+
+// Register adds the given scripts to the ScriptRegistry. They will be loaded
+// into redis instances which are connected to from then on, but not into those
+// which the ScriptRegistry's scripts have already been loaded into; use Load
+// for those.
+func (sr *ScriptRegistry) Register(scripts ...EvalScript) {
+	sr.l.Lock()
+	defer sr.l.Unlock()
+	sr.scripts = append(sr.scripts, scripts...)
+}
+
+// Scripts returns the scripts held by the ScriptRegistry.
+func (sr *ScriptRegistry) Scripts() []EvalScript {
+	sr.l.RLock()
+	defer sr.l.RUnlock()
+	return append([]EvalScript(nil), sr.scripts...)
+}
+
+// Action returns an Action which loads all of the ScriptRegistry's scripts, as
+// with LoadScripts.
+func (sr *ScriptRegistry) Action() Action {
+	return LoadScripts(sr.Scripts()...)
+}
+
+// Load loads all of the ScriptRegistry's scripts into the redis instance(s)
+// which c is connected to. If c is a *Cluster then they're loaded into every
+// node of the cluster, both primaries and secondaries.
+func (sr *ScriptRegistry) Load(c Client) error {
+	cl, ok := c.(*Cluster)
+	if !ok {
+		return c.Do(sr.Action())
+	}
+
+	for _, node := range cl.Topo() {
+		client, err := cl.Client(node.Addr)
+		if err != nil {
+			return err
+		} else if err := client.Do(sr.Action()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PoolScriptRegistry tells the Pool to load the scripts of the given
+// ScriptRegistry into redis each time it creates a Conn, after the Conn has
+// been created by the Pool's ConnFunc. This ensures the scripts are loaded even
+// if redis is restarted, or its script cache is flushed, since its connections
+// will then be recreated.
+//
+// If loading the scripts fails then so does the creation of the Conn.
+func PoolScriptRegistry(sr *ScriptRegistry) PoolOpt {
+	return func(po *poolOpts) {
+		po.scriptRegistry = sr
+	}
+}
+
+// ClusterScriptRegistry tells the Cluster to load the scripts of the given
+// ScriptRegistry into each node of the cluster, as it creates the Client for
+// that node using its ClusterPoolFunc. This includes nodes which are added to
+// the cluster after the Cluster is created.
+//
+// If loading the scripts fails then so does the creation of the node's Client.
+func ClusterScriptRegistry(sr *ScriptRegistry) ClusterOpt {
+	return func(co *clusterOpts) {
+		co.scriptRegistry = sr
+	}
+}
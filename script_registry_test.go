@@ -0,0 +1,79 @@
+package radix
+
+import (
+	. "testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadScripts(t *T) {
+	c := dial()
+	defer c.Close()
+
+	s1 := NewEvalScript(1, `return redis.call('GET', KEYS[1]) .. "`+randStr()+`"`)
+	s2 := NewEvalScript(0, `return "`+randStr()+`"`)
+	require.NoError(t, c.Do(LoadScripts(s1, s2)))
+
+	var exists []bool
+	require.NoError(t, c.Do(Cmd(&exists, "SCRIPT", "EXISTS", s1.sum, s2.sum)))
+	assert.Equal(t, []bool{true, true}, exists)
+}
+
+func TestPoolScriptRegistry(t *T) {
+	s1 := NewEvalScript(0, `return "foo"`)
+	s2 := NewEvalScript(0, `return "bar"`)
+	sr := NewScriptRegistry(s1)
+
+	var loaded []string
+	connFunc := func(network, addr string) (Conn, error) {
+		return Stub(network, addr, func(args []string) interface{} {
+			if args[0] == "SCRIPT" && args[1] == "LOAD" {
+				loaded = append(loaded, args[2])
+			}
+			return "OK"
+		}), nil
+	}
+
+	pool, err := NewPool("tcp", "127.0.0.1:6379", 1, PoolConnFunc(connFunc))
+	require.NoError(t, err)
+	defer pool.Close()
+	<-pool.initDone
+	assert.Empty(t, loaded)
+
+	pool, err = NewPool("tcp", "127.0.0.1:6379", 2, PoolConnFunc(connFunc), PoolScriptRegistry(sr))
+	require.NoError(t, err)
+	defer pool.Close()
+	<-pool.initDone
+	assert.Equal(t, []string{s1.script, s1.script}, loaded)
+
+	// scripts registered later are loaded on connections created from then on
+	loaded = nil
+	sr.Register(s2)
+	assert.Equal(t, []EvalScript{s1, s2}, sr.Scripts())
+	pool, err = NewPool("tcp", "127.0.0.1:6379", 1, PoolConnFunc(connFunc), PoolScriptRegistry(sr))
+	require.NoError(t, err)
+	defer pool.Close()
+	<-pool.initDone
+	assert.Equal(t, []string{s1.script, s2.script}, loaded)
+}
+
+func TestClusterScriptRegistry(t *T) {
+	s1 := NewEvalScript(0, `return "foo"`)
+	s2 := NewEvalScript(0, `return "bar"`)
+	sr := NewScriptRegistry(s1)
+
+	scl := newStubCluster(testTopo)
+	c := scl.newCluster(ClusterScriptRegistry(sr))
+	defer c.Close()
+
+	for _, s := range scl.stubs {
+		assert.Equal(t, []string{s1.script}, s.scripts, "node %q", s.addr)
+	}
+
+	sr.Register(s2)
+	require.NoError(t, sr.Load(c))
+	for _, s := range scl.stubs {
+		assert.Equal(t, []string{s1.script, s1.script, s2.script}, s.scripts, "node %q", s.addr)
+	}
+}
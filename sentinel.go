@@ -1,17 +1,27 @@
 package radix
 
 import (
+	"context"
+	"math/rand"
 	"net"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 
 	errors "golang.org/x/xerrors"
+
+	"github.com/mediocregopher/radix/v3/resp/resp2"
 )
 
 type sentinelOpts struct {
-	cf ConnFunc
-	pf ClientFunc
+	cf         ConnFunc
+	pf         ClientFunc
+	addrMapper func(announced string) string
+
+	readFromSecondaries bool
+	hedgeDelay          time.Duration
+	logger              Logger
 }
 
 // SentinelOpt is an optional behavior which can be applied to the NewSentinel
@@ -40,6 +50,65 @@ func SentinelPoolFunc(pf ClientFunc) SentinelOpt {
 	}
 }
 
+// SentinelLogger tells the Sentinel to log events using the given Logger. The
+// Sentinel logs changes of the primary, warnings when it fails to communicate
+// with the sentinels or to connect to a secondary, and passes the Logger into
+// the PersistentPubSub it uses to listen for switch-master events.
+//
+// The Logger isn't passed into the Sentinel's SentinelPoolFunc, use PoolLogger
+// for that.
+func SentinelLogger(l Logger) SentinelOpt {
+	return func(so *sentinelOpts) {
+		so.logger = l
+	}
+}
+
+// SentinelAddrMapper tells the Sentinel to pass every address which it learns
+// of from the sentinels, i.e. the addresses of the primary, its secondaries,
+// and other sentinels, through the given function, and to use the returned
+// address instead. The addresses given to NewSentinel are not passed through it.
+//
+// This is needed when the addresses announced by the sentinels aren't reachable
+// by the client, e.g. when redis is running within Docker or Kubernetes, or
+// behind NAT, and is known to the sentinels by its internal IP address.
+func SentinelAddrMapper(fn func(announced string) string) SentinelOpt {
+	return func(so *sentinelOpts) {
+		so.addrMapper = fn
+	}
+}
+
+// SentinelReadFromSecondaries tells the Sentinel to send Actions passed to Do
+// or DoContext which consist of a single read-only command, such as GET or
+// HGETALL, to a random healthy secondary of the primary. A secondary is
+// considered healthy if the sentinel doesn't consider it to be down or
+// disconnected, and its link to the primary is up. If there are no healthy
+// secondaries then the Action is sent to the primary.
+//
+// For this to work secondaries must be configured with replica-read-only
+// enabled. Note that secondaries are replicated to asynchronously, so reads
+// from them may return stale data.
+func SentinelReadFromSecondaries() SentinelOpt {
+	return func(so *sentinelOpts) {
+		so.readFromSecondaries = true
+	}
+}
+
+// SentinelHedgedReads tells the Sentinel that, if an Action sent to a secondary
+// as per SentinelReadFromSecondaries hasn't completed within the given delay, it
+// should also be sent to another healthy secondary, or to the primary if there
+// are no others. The reply of whichever completes successfully first is used.
+// The Action is also sent to the other instance right away if the first fails
+// with a network error.
+//
+// Hedging reduces tail latency caused by a single slow secondary, at the cost
+// of sending more commands to redis. The delay should therefore be set to
+// around the 95th or 99th percentile latency of read-only commands.
+func SentinelHedgedReads(delay time.Duration) SentinelOpt {
+	return func(so *sentinelOpts) {
+		so.hedgeDelay = delay
+	}
+}
+
 // Sentinel is a Client which, in the background, connects to an available
 // sentinel node and handles all of the following:
 //
@@ -64,11 +133,19 @@ type Sentinel struct {
 	clients       map[string]Client
 	sentinelAddrs map[string]bool // the known sentinel addresses
 
+	// addresses of the secondaries which were healthy as of the last time the
+	// sentinel was asked, used by SentinelReadFromSecondaries
+	healthySecAddrs []string
+
 	// We use a persistent PubSubConn here, so we don't need to do much after
 	// initialization. The pconn is only really kept around for closing
 	pconn   PubSubConn
 	pconnCh chan PubSubMessage
 
+	// written to when an Action gets an error indicating that the primary
+	// may have changed, so that the sentinel is asked right away
+	recheckCh chan struct{}
+
 	// Any errors encountered internally will be written to this channel. If
 	// nothing is reading the channel the errors will be dropped. The channel
 	// will be closed when the Close is called.
@@ -105,6 +182,7 @@ func NewSentinel(primaryName string, sentinelAddrs []string, opts ...SentinelOpt
 		name:          primaryName,
 		sentinelAddrs: addrs,
 		pconnCh:       make(chan PubSubMessage, 1),
+		recheckCh:     make(chan struct{}, 1),
 		ErrCh:         make(chan error, 1),
 		closeCh:       make(chan bool),
 		testEventCh:   make(chan string, 1),
@@ -144,10 +222,17 @@ func NewSentinel(primaryName string, sentinelAddrs []string, opts ...SentinelOpt
 		}
 	}
 
-	// because we're using persistent these can't _really_ fail
-	sc.pconn = PersistentPubSub("", "", func(_, _ string) (Conn, error) {
-		return sc.dialSentinel()
-	})
+	// because PersistentPubSubAbortAfter isn't used these can't _really_ fail
+	pconn, err := PersistentPubSubWithOpts("", "",
+		PersistentPubSubConnFunc(func(_, _ string) (Conn, error) {
+			return sc.dialSentinel()
+		}),
+		PersistentPubSubLogger(sc.so.logger),
+	)
+	if err != nil {
+		return nil, err
+	}
+	sc.pconn = pconn
 	sc.pconn.Subscribe(sc.pconnCh, "switch-master")
 
 	sc.closeWG.Add(1)
@@ -155,6 +240,10 @@ func NewSentinel(primaryName string, sentinelAddrs []string, opts ...SentinelOpt
 	return sc, nil
 }
 
+func (sc *Sentinel) log(level LogLevel, msg string, keyvals ...interface{}) {
+	logEvent(sc.so.logger, level, msg, keyvals...)
+}
+
 func (sc *Sentinel) err(err error) {
 	select {
 	case sc.ErrCh <- err:
@@ -200,9 +289,37 @@ func (sc *Sentinel) dialSentinel() (Conn, error) {
 // actually carried out that there could be a failover event. In that case, the
 // Action will likely fail and return an error.
 func (sc *Sentinel) Do(a Action) error {
+	if addr, client := sc.readClient(a); client != nil {
+		return sc.checkErr(sc.doRead(context.Background(), a, addr, client))
+	}
 	sc.l.RLock()
 	defer sc.l.RUnlock()
-	return sc.clients[sc.primAddr].Do(a)
+	return sc.checkErr(sc.clients[sc.primAddr].Do(a))
+}
+
+// DoContext implements the method for the ContextClient interface. It is like
+// Do, but the Action is bound by the given Context.
+func (sc *Sentinel) DoContext(ctx context.Context, a Action) error {
+	if addr, client := sc.readClient(a); client != nil {
+		return sc.checkErr(sc.doRead(ctx, a, addr, client))
+	}
+	sc.l.RLock()
+	defer sc.l.RUnlock()
+	return sc.checkErr(DoContext(ctx, sc.clients[sc.primAddr], a))
+}
+
+// checkErr returns the given error, first triggering an immediate check of the
+// sentinel's state if it's a LOADING error, or a READONLY error indicating that
+// the primary has been demoted to a replica. The Client's connection which got
+// the error will have been discarded by it, if it's a Pool.
+func (sc *Sentinel) checkErr(err error) error {
+	if isStaleConnErr(err) {
+		select {
+		case sc.recheckCh <- struct{}{}:
+		default:
+		}
+	}
+	return err
 }
 
 // DoSecondary is like Do but executes the Action on a random replica if possible.
@@ -218,7 +335,78 @@ func (sc *Sentinel) DoSecondary(a Action) error {
 	if err != nil {
 		return err
 	}
-	return c.Do(a)
+	return sc.checkErr(c.Do(a))
+}
+
+// readClient returns the address and Client of a healthy secondary which the
+// Action should be sent to as per SentinelReadFromSecondaries, or a nil Client
+// if it should be sent to the primary.
+func (sc *Sentinel) readClient(a Action) (string, Client) {
+	if !sc.so.readFromSecondaries || !isReadOnlyAction(a) {
+		return "", nil
+	}
+
+	sc.l.RLock()
+	var addr string
+	if n := len(sc.healthySecAddrs); n > 0 {
+		addr = sc.healthySecAddrs[rand.Intn(n)]
+	}
+	sc.l.RUnlock()
+
+	if addr == "" {
+		return "", nil
+	}
+	return addr, sc.secondaryClient(addr)
+}
+
+// secondaryClient returns the Client for the secondary at the given address,
+// or nil if one couldn't be created.
+func (sc *Sentinel) secondaryClient(addr string) Client {
+	client, err := sc.clientInner(addr)
+	if err != nil {
+		sc.log(LogLevelWarn, "failed to connect to secondary", "addr", addr, "err", err)
+		sc.err(err)
+		return nil
+	}
+	return client
+}
+
+// doRead performs the Action on the Client of the secondary at the given
+// address, hedging it as per SentinelHedgedReads.
+func (sc *Sentinel) doRead(ctx context.Context, a Action, addr string, client Client) error {
+	if sc.so.hedgeDelay <= 0 || !hedgeable(a) {
+		return DoContext(ctx, client, a)
+	}
+
+	doOn := func(client Client) func(Action) error {
+		return func(a Action) error { return DoContext(ctx, client, a) }
+	}
+
+	var second func(Action) error
+	if hedgeClient := sc.hedgeClient(addr); hedgeClient != nil {
+		second = doOn(hedgeClient)
+	}
+	return doHedged(ctx, a, sc.so.hedgeDelay, doOn(client), second)
+}
+
+// hedgeClient returns the Client which a hedged read, which was first sent to
+// the secondary at addr, should be sent to. This is a random healthy secondary
+// other than addr, or the primary if there are none.
+func (sc *Sentinel) hedgeClient(addr string) Client {
+	sc.l.RLock()
+	addrs := make([]string, 0, len(sc.healthySecAddrs))
+	for _, secAddr := range sc.healthySecAddrs {
+		if secAddr != addr {
+			addrs = append(addrs, secAddr)
+		}
+	}
+	primClient := sc.clients[sc.primAddr]
+	sc.l.RUnlock()
+
+	if len(addrs) == 0 {
+		return primClient
+	}
+	return sc.secondaryClient(addrs[rand.Intn(len(addrs))])
 }
 
 // Addrs returns the currently known network address of the current primary
@@ -330,11 +518,20 @@ func (sc *Sentinel) Close() error {
 }
 
 // cmd should be the command called which generated m
-func sentinelMtoAddr(m map[string]string, cmd string) (string, error) {
+func (sc *Sentinel) sentinelMtoAddr(m map[string]string, cmd string) (string, error) {
 	if m["ip"] == "" || m["port"] == "" {
 		return "", errors.Errorf("malformed %q response: %#v", cmd, m)
 	}
-	return net.JoinHostPort(m["ip"], m["port"]), nil
+	return sc.mapAddr(net.JoinHostPort(m["ip"], m["port"])), nil
+}
+
+// mapAddr returns the given announced address as mapped by SentinelAddrMapper,
+// if it was given.
+func (sc *Sentinel) mapAddr(addr string) string {
+	if sc.so.addrMapper == nil {
+		return addr
+	}
+	return sc.so.addrMapper(addr)
 }
 
 // given a connection to a sentinel, ensures that the Clients currently being
@@ -342,28 +539,60 @@ func sentinelMtoAddr(m map[string]string, cmd string) (string, error) {
 func (sc *Sentinel) ensureClients(conn Conn) error {
 	var primM map[string]string
 	var secMM []map[string]string
-	if err := conn.Do(Pipeline(
+	secCmd := "REPLICAS"
+	err := conn.Do(Pipeline(
 		Cmd(&primM, "SENTINEL", "MASTER", sc.name),
-		Cmd(&secMM, "SENTINEL", "SLAVES", sc.name),
-	)); err != nil {
+		Cmd(&secMM, "SENTINEL", secCmd, sc.name),
+	))
+	if errors.As(err, new(resp2.Error)) && primM != nil {
+		// SENTINEL REPLICAS was only added in redis 5, fall back to its older
+		// name.
+		secCmd = "SLAVES"
+		err = conn.Do(Cmd(&secMM, "SENTINEL", secCmd, sc.name))
+	}
+	if err != nil {
 		return err
 	}
 
-	newPrimAddr, err := sentinelMtoAddr(primM, "SENTINEL MASTER")
+	newPrimAddr, err := sc.sentinelMtoAddr(primM, "SENTINEL MASTER")
 	if err != nil {
 		return err
 	}
 
 	newClients := map[string]Client{newPrimAddr: nil}
+	var healthySecAddrs []string
 	for _, secM := range secMM {
-		newSecAddr, err := sentinelMtoAddr(secM, "SENTINEL SLAVES")
+		newSecAddr, err := sc.sentinelMtoAddr(secM, "SENTINEL "+secCmd)
 		if err != nil {
 			return err
 		}
 		newClients[newSecAddr] = nil
+		if newSecAddr != newPrimAddr && sentinelSecIsHealthy(secM) {
+			healthySecAddrs = append(healthySecAddrs, newSecAddr)
+		}
+	}
+
+	if err := sc.setClients(newPrimAddr, newClients); err != nil {
+		return err
 	}
 
-	return sc.setClients(newPrimAddr, newClients)
+	sc.l.Lock()
+	sc.healthySecAddrs = healthySecAddrs
+	sc.l.Unlock()
+	return nil
+}
+
+// sentinelSecIsHealthy returns whether the secondary described by the given
+// SENTINEL REPLICAS entry can be read from.
+func sentinelSecIsHealthy(m map[string]string) bool {
+	for _, flag := range strings.Split(m["flags"], ",") {
+		switch flag {
+		case "s_down", "o_down", "disconnected":
+			return false
+		}
+	}
+	linkStatus, ok := m["master-link-status"]
+	return !ok || linkStatus == "ok"
 }
 
 // all values of newClients should be nil
@@ -417,10 +646,15 @@ func (sc *Sentinel) setClients(newPrimAddr string, newClients map[string]Client)
 	}
 
 	sc.l.Lock()
+	prevPrimAddr := sc.primAddr
 	sc.primAddr = newPrimAddr
 	sc.clients = newClients
 	sc.l.Unlock()
 
+	if prevPrimAddr != "" && prevPrimAddr != newPrimAddr {
+		sc.log(LogLevelInfo, "primary changed", "prev", prevPrimAddr, "addr", newPrimAddr)
+	}
+
 	for _, client := range toClose {
 		client.Close()
 	}
@@ -439,7 +673,7 @@ func (sc *Sentinel) ensureSentinelAddrs(conn Conn) error {
 
 	addrs := map[string]bool{conn.NetConn().RemoteAddr().String(): true}
 	for _, m := range mm {
-		addrs[net.JoinHostPort(m["ip"], m["port"])] = true
+		addrs[sc.mapAddr(net.JoinHostPort(m["ip"], m["port"]))] = true
 	}
 
 	sc.l.Lock()
@@ -453,6 +687,7 @@ func (sc *Sentinel) spin() {
 	defer sc.pconn.Close()
 	for {
 		if err := sc.innerSpin(); err != nil {
+			sc.log(LogLevelWarn, "failed to communicate with sentinel", "err", err)
 			sc.err(err)
 			// sleep a second so we don't end up in a tight loop
 			time.Sleep(1 * time.Second)
@@ -476,6 +711,8 @@ func (sc *Sentinel) spin() {
 // * Periodically re-ensuring that the list of sentinel addresses is up-to-date
 // * Periodically re-checking the current primary, in case the switch-master was
 //   missed somehow
+// * Re-checking the current primary right away when an Action gets an error
+//   indicating it may have changed (see checkErr)
 func (sc *Sentinel) innerSpin() error {
 	conn, err := sc.dialSentinel()
 	if err != nil {
@@ -486,7 +723,7 @@ func (sc *Sentinel) innerSpin() error {
 	tick := time.NewTicker(5 * time.Second)
 	defer tick.Stop()
 
-	var switchMaster bool
+	var switchMaster, recheck bool
 	for {
 		if err := sc.ensureSentinelAddrs(conn); err != nil {
 			return err
@@ -496,11 +733,15 @@ func (sc *Sentinel) innerSpin() error {
 		sc.pconn.Ping()
 
 		// the tests want to know when the client state has been updated due to
-		// a switch-master event
+		// a switch-master event or a recheck
 		if switchMaster {
 			sc.testEvent("switch-master completed")
 			switchMaster = false
 		}
+		if recheck {
+			sc.testEvent("recheck completed")
+			recheck = false
+		}
 
 		select {
 		case <-tick.C:
@@ -511,6 +752,9 @@ func (sc *Sentinel) innerSpin() error {
 				time.Sleep(time.Duration(waitFor) * time.Millisecond)
 			}
 			// loop
+		case <-sc.recheckCh:
+			recheck = true
+			// loop
 		case <-sc.closeCh:
 			return nil
 		}
@@ -12,6 +12,8 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+
+	"github.com/mediocregopher/radix/v3/resp/resp2"
 )
 
 type sentinelStub struct {
@@ -25,6 +27,13 @@ type sentinelStub struct {
 	// addresses of all "sentinels" in the cluster
 	sentAddrs []string
 
+	// secondaries which will be reported as being down
+	downSecAddrs map[string]bool
+
+	// if set then SENTINEL REPLICAS is treated as an unknown subcommand, as
+	// with redis versions before 5
+	noReplicasCmd bool
+
 	// stubChs which have been created for stubs and want to know about
 	// switch-master messages
 	stubChs map[chan<- PubSubMessage]bool
@@ -32,10 +41,11 @@ type sentinelStub struct {
 
 func newSentinelStub(primAddr string, secAddrs, sentAddrs []string) sentinelStub {
 	return sentinelStub{
-		primAddr:  primAddr,
-		secAddrs:  secAddrs,
-		sentAddrs: sentAddrs,
-		stubChs:   map[chan<- PubSubMessage]bool{},
+		primAddr:     primAddr,
+		secAddrs:     secAddrs,
+		sentAddrs:    sentAddrs,
+		downSecAddrs: map[string]bool{},
+		stubChs:      map[chan<- PubSubMessage]bool{},
 	}
 }
 
@@ -86,10 +96,17 @@ func (s *sentinelStub) newConn(network, addr string) (Conn, error) {
 		case "MASTER":
 			return addrToM(s.primAddr)
 
-		case "SLAVES":
+		case "REPLICAS", "SLAVES":
+			if args[1] == "REPLICAS" && s.noReplicasCmd {
+				return resp2.Error{E: errors.New("ERR Unknown sentinel subcommand 'replicas'")}
+			}
 			mm := make([]map[string]string, len(s.secAddrs))
 			for i := range s.secAddrs {
 				mm[i] = addrToM(s.secAddrs[i])
+				mm[i]["flags"], mm[i]["master-link-status"] = "slave", "ok"
+				if s.downSecAddrs[s.secAddrs[i]] {
+					mm[i]["flags"], mm[i]["master-link-status"] = "slave,s_down", "err"
+				}
 			}
 			return mm
 
@@ -130,6 +147,154 @@ func (s *sentinelStub) switchPrimary(newPrimAddr string, newSecAddrs ...string)
 	}
 }
 
+func TestSentinelAddrMapper(t *T) {
+	stub := newSentinelStub(
+		"10.0.0.1:6379",             // primAddr
+		[]string{"10.0.0.2:6379"},   // secAddrs
+		[]string{"127.0.0.1:26379"}, // sentAddrs
+	)
+
+	var poolAddrsL sync.Mutex
+	var poolAddrs []string
+	poolFn := func(_, addr string) (Client, error) {
+		poolAddrsL.Lock()
+		poolAddrs = append(poolAddrs, addr)
+		poolAddrsL.Unlock()
+		return NewPool("tcp", "127.0.0.1:6379", 1)
+	}
+
+	scc, err := NewSentinel(
+		"stub", stub.sentAddrs,
+		SentinelConnFunc(stub.newConn), SentinelPoolFunc(poolFn),
+		SentinelAddrMapper(func(addr string) string {
+			return strings.Replace(addr, "10.0.0.", "127.0.0.", 1)
+		}),
+	)
+	require.Nil(t, err)
+	defer scc.Close()
+
+	primAddr, secAddrs := scc.Addrs()
+	assert.Equal(t, "127.0.0.1:6379", primAddr)
+	assert.Equal(t, []string{"127.0.0.2:6379"}, secAddrs)
+	assert.Equal(t, []string{"127.0.0.1:26379"}, scc.SentinelAddrs())
+
+	poolAddrsL.Lock()
+	assert.Equal(t, []string{"127.0.0.1:6379"}, poolAddrs)
+	poolAddrsL.Unlock()
+}
+
+// sentinelAddrClient records the address its Client was created for whenever
+// an Action is performed on it.
+type sentinelAddrClient struct {
+	Client
+	addr string
+	rec  func(string)
+}
+
+func (c sentinelAddrClient) Do(a Action) error {
+	c.rec(c.addr)
+	return c.Client.Do(a)
+}
+
+func TestSentinelReadFromSecondaries(t *T) {
+	for _, noReplicasCmd := range []bool{false, true} {
+		stub := newSentinelStub(
+			"127.0.0.1:6379", // primAddr
+			[]string{"127.0.0.2:6379", "127.0.0.3:6379"}, // secAddrs
+			[]string{"127.0.0.1:26379"},                  // sentAddrs
+		)
+		stub.noReplicasCmd = noReplicasCmd
+		stub.downSecAddrs["127.0.0.3:6379"] = true
+
+		var lastAddr string
+		poolFn := func(_, addr string) (Client, error) {
+			p, err := NewPool("tcp", "127.0.0.1:6379", 1)
+			if err != nil {
+				return nil, err
+			}
+			return sentinelAddrClient{Client: p, addr: addr, rec: func(addr string) {
+				lastAddr = addr
+			}}, nil
+		}
+
+		scc, err := NewSentinel(
+			"stub", stub.sentAddrs,
+			SentinelConnFunc(stub.newConn), SentinelPoolFunc(poolFn),
+			SentinelReadFromSecondaries(),
+		)
+		require.Nil(t, err)
+
+		k := randStr()
+		require.Nil(t, scc.Do(Cmd(nil, "SET", k, "foo")))
+		assert.Equal(t, "127.0.0.1:6379", lastAddr)
+
+		// only the healthy secondary should ever be read from
+		for i := 0; i < 10; i++ {
+			require.Nil(t, scc.Do(Cmd(nil, "GET", k)))
+			assert.Equal(t, "127.0.0.2:6379", lastAddr)
+		}
+
+		// with no healthy secondaries reads go to the primary
+		stub.Lock()
+		stub.downSecAddrs["127.0.0.2:6379"] = true
+		stub.Unlock()
+		conn, err := stub.newConn("tcp", stub.sentAddrs[0])
+		require.Nil(t, err)
+		require.Nil(t, scc.ensureClients(conn))
+		conn.Close()
+
+		require.Nil(t, scc.Do(Cmd(nil, "GET", k)))
+		assert.Equal(t, "127.0.0.1:6379", lastAddr)
+
+		_, secAddrs := scc.Addrs()
+		assert.Len(t, secAddrs, 2)
+		scc.Close()
+	}
+}
+
+func TestSentinelRecheckOnReadOnly(t *T) {
+	stub := newSentinelStub(
+		"127.0.0.1:6379",            // primAddr
+		[]string{"127.0.0.2:6379"},  // secAddrs
+		[]string{"127.0.0.1:26379"}, // sentAddrs
+	)
+
+	// each instance rejects writes unless it's the stub's current primary
+	poolFn := func(network, addr string) (Client, error) {
+		return NewPool(network, addr, 1, PoolConnFunc(func(network, addr string) (Conn, error) {
+			return Stub(network, addr, func(args []string) interface{} {
+				stub.Lock()
+				defer stub.Unlock()
+				if addr != stub.primAddr {
+					return resp2.Error{E: errors.New("READONLY You can't write against a read only replica.")}
+				}
+				return "OK"
+			}), nil
+		}))
+	}
+
+	scc, err := NewSentinel(
+		"stub", stub.sentAddrs,
+		SentinelConnFunc(stub.newConn), SentinelPoolFunc(poolFn),
+	)
+	require.Nil(t, err)
+	defer scc.Close()
+	require.Nil(t, scc.Do(Cmd(nil, "SET", "foo", "bar")))
+
+	// fail over without the switch-master message being published
+	stub.Lock()
+	stub.primAddr, stub.secAddrs = "127.0.0.2:6379", []string{"127.0.0.1:6379"}
+	stub.Unlock()
+
+	err = scc.Do(Cmd(nil, "SET", "foo", "bar"))
+	assert.True(t, errors.Is(err, resp2.ErrReadOnly))
+	assert.Equal(t, "recheck completed", <-scc.testEventCh)
+
+	primAddr, _ := scc.Addrs()
+	assert.Equal(t, "127.0.0.2:6379", primAddr)
+	require.Nil(t, scc.Do(Cmd(nil, "SET", "foo", "bar")))
+}
+
 func TestSentinel(t *T) {
 	stub := newSentinelStub(
 		"127.0.0.1:6379", // primAddr
@@ -0,0 +1,124 @@
+package radix
+
+import (
+	"bufio"
+	"strconv"
+	"time"
+
+	errors "golang.org/x/xerrors"
+
+	"github.com/mediocregopher/radix/v3/resp/resp2"
+)
+
+// SlowlogEntry describes a single entry of the slow log, as returned by
+// SLOWLOG GET.
+type SlowlogEntry struct {
+	// ID uniquely identifies the entry. IDs are never reused, even after
+	// SLOWLOG RESET.
+	ID int64
+
+	// Timestamp is when the command was processed.
+	Timestamp time.Time
+
+	// Duration is how long the command took to execute, with microsecond
+	// precision.
+	Duration time.Duration
+
+	// Args is the command and its arguments, which redis may have truncated.
+	Args []string
+
+	// ClientAddr and ClientName describe the client which performed the
+	// command. They are only returned by redis 4.0 and above.
+	ClientAddr, ClientName string
+}
+
+// UnmarshalRESP implements the resp.Unmarshaler interface.
+func (e *SlowlogEntry) UnmarshalRESP(br *bufio.Reader) error {
+	var arrHead resp2.ArrayHeader
+	if err := arrHead.UnmarshalRESP(br); err != nil {
+		return err
+	} else if arrHead.N < 4 {
+		// discard the elements so the reader isn't left in a broken state
+		for i := 0; i < arrHead.N; i++ {
+			if err := (resp2.Any{}).UnmarshalRESP(br); err != nil {
+				return err
+			}
+		}
+		return errors.Errorf("malformed slowlog entry with %d elements", arrHead.N)
+	}
+
+	var id, ts, us int64
+	for _, i := range []*int64{&id, &ts, &us} {
+		if err := (resp2.Any{I: i}).UnmarshalRESP(br); err != nil {
+			return err
+		}
+	}
+
+	var args []string
+	if err := (resp2.Any{I: &args}).UnmarshalRESP(br); err != nil {
+		return err
+	}
+
+	var addr, name string
+	for i := 4; i < arrHead.N; i++ {
+		var into interface{}
+		switch i {
+		case 4:
+			into = &addr
+		case 5:
+			into = &name
+		}
+		if err := (resp2.Any{I: into}).UnmarshalRESP(br); err != nil {
+			return err
+		}
+	}
+
+	*e = SlowlogEntry{
+		ID:         id,
+		Timestamp:  time.Unix(ts, 0),
+		Duration:   time.Duration(us) * time.Microsecond,
+		Args:       args,
+		ClientAddr: addr,
+		ClientName: name,
+	}
+	return nil
+}
+
+type slowlogEntries []SlowlogEntry
+
+func (ee *slowlogEntries) UnmarshalRESP(br *bufio.Reader) error {
+	var arrHead resp2.ArrayHeader
+	if err := arrHead.UnmarshalRESP(br); err != nil {
+		return err
+	}
+	*ee = make(slowlogEntries, arrHead.N)
+	for i := range *ee {
+		if err := (&(*ee)[i]).UnmarshalRESP(br); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SlowlogGetCmd returns a CmdAction which performs SLOWLOG GET, decoding the
+// entries into rcv, newest first. At most count entries are returned. If count
+// is negative then it isn't passed to redis, which then uses its default of 10.
+func SlowlogGetCmd(rcv *[]SlowlogEntry, count int) CmdAction {
+	var args []string
+	if count >= 0 {
+		args = []string{strconv.Itoa(count)}
+	}
+	return Cmd((*slowlogEntries)(rcv), "SLOWLOG", append([]string{"GET"}, args...)...)
+}
+
+// SlowlogLenCmd returns a CmdAction which performs SLOWLOG LEN, writing the
+// number of entries in the slow log into rcv.
+func SlowlogLenCmd(rcv *int) CmdAction {
+	return Cmd(rcv, "SLOWLOG", "LEN")
+}
+
+// SlowlogResetCmd returns a CmdAction which performs SLOWLOG RESET, removing
+// all entries from the slow log.
+func SlowlogResetCmd() CmdAction {
+	return Cmd(nil, "SLOWLOG", "RESET")
+}
@@ -0,0 +1,69 @@
+package radix
+
+import (
+	. "testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSlowlogCmds(t *T) {
+	var gotArgs [][]string
+	conn := Stub("tcp", "127.0.0.1:6379", func(args []string) interface{} {
+		gotArgs = append(gotArgs, args)
+		switch args[1] {
+		case "GET":
+			return []interface{}{
+				[]interface{}{
+					int64(14), int64(1309448221), int64(15),
+					[]string{"ping"}, "127.0.0.1:58217", "worker-123",
+				},
+				// entries from before redis 4.0 have no client info
+				[]interface{}{
+					int64(13), int64(1309448128), int64(30),
+					[]string{"slowlog", "get", "100"},
+				},
+			}
+		case "LEN":
+			return int64(2)
+		default:
+			return "OK"
+		}
+	})
+
+	var entries []SlowlogEntry
+	require.Nil(t, conn.Do(SlowlogGetCmd(&entries, 100)))
+	assert.Equal(t, []SlowlogEntry{
+		{
+			ID:         14,
+			Timestamp:  time.Unix(1309448221, 0),
+			Duration:   15 * time.Microsecond,
+			Args:       []string{"ping"},
+			ClientAddr: "127.0.0.1:58217",
+			ClientName: "worker-123",
+		},
+		{
+			ID:        13,
+			Timestamp: time.Unix(1309448128, 0),
+			Duration:  30 * time.Microsecond,
+			Args:      []string{"slowlog", "get", "100"},
+		},
+	}, entries)
+
+	require.Nil(t, conn.Do(SlowlogGetCmd(&entries, -1)))
+	assert.Len(t, entries, 2)
+
+	var n int
+	require.Nil(t, conn.Do(SlowlogLenCmd(&n)))
+	assert.Equal(t, 2, n)
+
+	require.Nil(t, conn.Do(SlowlogResetCmd()))
+
+	assert.Equal(t, [][]string{
+		{"SLOWLOG", "GET", "100"},
+		{"SLOWLOG", "GET"},
+		{"SLOWLOG", "LEN"},
+		{"SLOWLOG", "RESET"},
+	}, gotArgs)
+}
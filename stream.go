@@ -208,9 +208,10 @@ type StreamReaderOpts struct {
 	//
 	// The default, if Block is 0, is 5 seconds.
 	//
-	// If Block is non-negative, the Client used for the StreamReader must not have a timeout for commands or
-	// the timeout duration must be substantial higher than the Block duration (at least 50% for small Block values,
-	// but may be less for higher values).
+	// If Block is non-negative and the Client used for the StreamReader uses Conns which were not created by
+	// Dial, those Conns must not have a timeout for commands or the timeout duration must be substantial higher
+	// than the Block duration (at least 50% for small Block values, but may be less for higher values). Conns
+	// created by Dial do not apply DialReadTimeout to blocking commands.
 	Block time.Duration
 
 	// NoBlock disables blocking when no new data is available.
@@ -222,6 +223,35 @@ type StreamReaderOpts struct {
 	//
 	// If Count is 0, all available entries will be retrieved.
 	Count int
+
+	// AutoClaimIdle optionally enables automatically claiming entries which
+	// have been pending in Group, e.g. because the consumer they were delivered
+	// to has died, for at least AutoClaimIdle. At most once every
+	// AutoClaimInterval Next claims such entries on behalf of Consumer and
+	// returns them, before reading any new entries.
+	//
+	// Claiming uses XAUTOCLAIM, or XPENDING and XCLAIM on versions of redis
+	// before 6.2 which don't support it. Up to Count entries are claimed from
+	// each stream at a time, or 100 if Count is 0.
+	//
+	// AutoClaimIdle has no effect if Group is not set.
+	AutoClaimIdle time.Duration
+
+	// AutoClaimInterval is the interval at which entries are claimed when
+	// AutoClaimIdle is set. Defaults to AutoClaimIdle.
+	AutoClaimInterval time.Duration
+
+	// MaxDeliveries optionally limits the number of times an entry is
+	// delivered when AutoClaimIdle is set. Entries which would be claimed, but
+	// which have already been delivered at least MaxDeliveries times, are
+	// instead passed to DeadLetter, if it's set, and then acknowledged.
+	MaxDeliveries int64
+
+	// DeadLetter is called with each entry which has been delivered at least
+	// MaxDeliveries times, along with its number of deliveries, e.g. to move
+	// it to another stream. If it returns an error the entry is not
+	// acknowledged, and the error is returned by Err.
+	DeadLetter func(stream string, entry StreamEntry, deliveries int64) error
 }
 
 // StreamReader allows reading from on or more streams, always returning newer entries
@@ -241,9 +271,29 @@ type StreamReader interface {
 	Next() (stream string, entries []StreamEntry, ok bool)
 }
 
+// StreamGroupReader is a StreamReader which reads from streams using a consumer
+// group, and which can therefore also acknowledge and claim entries on behalf
+// of its consumer.
+type StreamGroupReader interface {
+	StreamReader
+
+	// Ack acknowledges the entries with the given IDs in the given stream using
+	// XACK, removing them from the consumer group's pending entries list.
+	Ack(stream string, ids ...StreamEntryID) error
+
+	// Claim uses XCLAIM to take ownership of the pending entries with the given
+	// IDs in the given stream, as long as they have been idle for at least
+	// minIdleTime. The entries which were claimed are returned. Entries which
+	// no longer exist in the stream are not returned.
+	Claim(stream string, minIdleTime time.Duration, ids ...StreamEntryID) ([]StreamEntry, error)
+}
+
 // NewStreamReader returns a new StreamReader for the given client.
 //
 // Any changes on opts after calling NewStreamReader will have no effect.
+//
+// The returned StreamReader always implements the StreamGroupReader interface,
+// but its Ack and Claim methods return an error unless opts.Group is set.
 func NewStreamReader(c Client, opts StreamReaderOpts) StreamReader {
 	sr := &streamReader{c: c, opts: opts}
 
@@ -315,6 +365,10 @@ type streamReader struct {
 
 	unread []streamReaderEntry
 	err    error
+
+	lastAutoClaim   time.Time
+	autoClaimStarts map[string]string // the XAUTOCLAIM cursor of each stream
+	noXAutoClaim    bool              // set if redis doesn't support XAUTOCLAIM
 }
 
 func (sr *streamReader) backfill() bool {
@@ -342,7 +396,9 @@ func (sr *streamReader) Next() (stream string, entries []StreamEntry, ok bool) {
 		return "", nil, false
 	}
 
-	if len(sr.unread) == 0 && !sr.backfill() {
+	if len(sr.unread) == 0 && !sr.autoClaim() {
+		return "", nil, false
+	} else if len(sr.unread) == 0 && !sr.backfill() {
 		return "", nil, false
 	}
 
@@ -357,8 +413,9 @@ func (sr *streamReader) Next() (stream string, entries []StreamEntry, ok bool) {
 
 		stream = sre.stream
 
-		// do not update the ID for XREADGROUP when we are not reading unacknowledged entries.
-		if sr.cmd == "XREAD" || (sr.cmd == "XREADGROUP" && sr.ids[stream] != ">") {
+		// do not update the ID for XREADGROUP when we are not reading unacknowledged entries,
+		// or for entries which were claimed from other consumers.
+		if !sre.claimed && (sr.cmd == "XREAD" || (sr.cmd == "XREADGROUP" && sr.ids[stream] != ">")) {
 			sr.ids[stream] = sre.entries[len(sre.entries)-1].ID.String()
 		}
 
@@ -368,9 +425,260 @@ func (sr *streamReader) Next() (stream string, entries []StreamEntry, ok bool) {
 	return "", nil, true
 }
 
+var errNoStreamGroup = errors.New("StreamReader was not created with a Group")
+
+// Ack implements the StreamGroupReader interface.
+func (sr *streamReader) Ack(stream string, ids ...StreamEntryID) error {
+	if sr.opts.Group == "" {
+		return errNoStreamGroup
+	}
+
+	args := make([]string, 0, 2+len(ids))
+	args = append(args, stream, sr.opts.Group)
+	for _, id := range ids {
+		args = append(args, id.String())
+	}
+	return sr.c.Do(Cmd(nil, "XACK", args...))
+}
+
+// Claim implements the StreamGroupReader interface.
+func (sr *streamReader) Claim(stream string, minIdleTime time.Duration, ids ...StreamEntryID) ([]StreamEntry, error) {
+	if sr.opts.Group == "" {
+		return nil, errNoStreamGroup
+	}
+
+	args := make([]string, 0, 4+len(ids))
+	args = append(args,
+		stream, sr.opts.Group, sr.opts.Consumer,
+		strconv.FormatInt(int64(minIdleTime/time.Millisecond), 10),
+	)
+	for _, id := range ids {
+		args = append(args, id.String())
+	}
+
+	// older versions of redis return nil for entries which no longer exist, so
+	// those need to be filtered out
+	var rms []resp2.RawMessage
+	if err := sr.c.Do(Cmd(&rms, "XCLAIM", args...)); err != nil {
+		return nil, err
+	}
+
+	entries := make([]StreamEntry, 0, len(rms))
+	for _, rm := range rms {
+		if rm.IsNil() {
+			continue
+		}
+		var entry StreamEntry
+		if err := rm.UnmarshalInto(&entry); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// autoClaim claims stale entries from each stream into unread, if
+// AutoClaimIdle is set and AutoClaimInterval has passed since it was last
+// done. It returns false if an error occurred, which is stored in err.
+func (sr *streamReader) autoClaim() bool {
+	if sr.opts.Group == "" || sr.opts.AutoClaimIdle <= 0 {
+		return true
+	}
+
+	interval := sr.opts.AutoClaimInterval
+	if interval <= 0 {
+		interval = sr.opts.AutoClaimIdle
+	}
+	now := time.Now()
+	if now.Sub(sr.lastAutoClaim) < interval {
+		return true
+	}
+	sr.lastAutoClaim = now
+
+	for _, stream := range sr.streams {
+		var entries []StreamEntry
+		if entries, sr.err = sr.claimStale(stream); sr.err != nil {
+			return false
+		} else if len(entries) > 0 {
+			sr.unread = append(sr.unread, streamReaderEntry{
+				stream:  stream,
+				entries: entries,
+				claimed: true,
+			})
+		}
+	}
+	return true
+}
+
+func (sr *streamReader) autoClaimCount() int {
+	if sr.opts.Count > 0 {
+		return sr.opts.Count
+	}
+	return 100
+}
+
+// stalePending returns the pending entries of the stream which have been idle
+// for at least AutoClaimIdle.
+func (sr *streamReader) stalePending(stream string) ([]StreamPendingEntry, error) {
+	var pending []StreamPendingEntry
+	err := sr.c.Do(XPendingCmd(&pending, stream, sr.opts.Group, "-", "+", sr.autoClaimCount()))
+	if err != nil {
+		return nil, err
+	}
+
+	stale := pending[:0]
+	for _, p := range pending {
+		if p.Idle >= sr.opts.AutoClaimIdle {
+			stale = append(stale, p)
+		}
+	}
+	return stale, nil
+}
+
+// deadLetter acknowledges the stale entries of the stream which have been
+// delivered at least MaxDeliveries times, passing each to DeadLetter first.
+// The whole PEL is paged through, so that entries beyond the first page of it
+// aren't claimed over and over.
+func (sr *streamReader) deadLetter(stream string) error {
+	start, count := "-", sr.autoClaimCount()
+	for {
+		var pending []StreamPendingEntry
+		err := sr.c.Do(XPendingCmd(&pending, stream, sr.opts.Group, start, "+", count))
+		if err != nil {
+			return err
+		}
+
+		for _, p := range pending {
+			if p.Idle < sr.opts.AutoClaimIdle || p.Deliveries < sr.opts.MaxDeliveries {
+				continue
+			} else if err := sr.deadLetterEntry(stream, p); err != nil {
+				return err
+			}
+		}
+
+		if len(pending) < count {
+			return nil
+		}
+		start = pending[len(pending)-1].ID.Next().String()
+	}
+}
+
+func (sr *streamReader) deadLetterEntry(stream string, p StreamPendingEntry) error {
+	if sr.opts.DeadLetter != nil {
+		id := p.ID.String()
+		var entries []StreamEntry
+		if err := sr.c.Do(Cmd(&entries, "XRANGE", stream, id, id)); err != nil {
+			return err
+		}
+		// the entry may have been deleted from the stream, in which case
+		// there's nothing to pass to DeadLetter
+		for _, entry := range entries {
+			if err := sr.opts.DeadLetter(stream, entry, p.Deliveries); err != nil {
+				return err
+			}
+		}
+	}
+	return sr.Ack(stream, p.ID)
+}
+
+func (sr *streamReader) claimStale(stream string) ([]StreamEntry, error) {
+	if sr.opts.MaxDeliveries > 0 {
+		if err := sr.deadLetter(stream); err != nil {
+			return nil, err
+		}
+	}
+
+	if !sr.noXAutoClaim {
+		entries, err := sr.xautoclaim(stream)
+		if !isRespErrPrefix(err, "ERR unknown command") {
+			return entries, err
+		}
+		sr.noXAutoClaim = true
+	}
+
+	stale, err := sr.stalePending(stream)
+	if err != nil || len(stale) == 0 {
+		return nil, err
+	}
+	ids := make([]StreamEntryID, len(stale))
+	for i := range stale {
+		ids[i] = stale[i].ID
+	}
+	return sr.Claim(stream, sr.opts.AutoClaimIdle, ids...)
+}
+
+func (sr *streamReader) xautoclaim(stream string) ([]StreamEntry, error) {
+	start, ok := sr.autoClaimStarts[stream]
+	if !ok {
+		start = "0-0"
+	}
+
+	var res xautoclaimResult
+	err := sr.c.Do(Cmd(&res, "XAUTOCLAIM",
+		stream, sr.opts.Group, sr.opts.Consumer,
+		strconv.FormatInt(int64(sr.opts.AutoClaimIdle/time.Millisecond), 10),
+		start, "COUNT", strconv.Itoa(sr.autoClaimCount()),
+	))
+	if err != nil {
+		return nil, err
+	}
+
+	if sr.autoClaimStarts == nil {
+		sr.autoClaimStarts = map[string]string{}
+	}
+	sr.autoClaimStarts[stream] = res.next.String()
+	return res.entries, nil
+}
+
+type xautoclaimResult struct {
+	next    StreamEntryID
+	entries []StreamEntry
+}
+
+func (res *xautoclaimResult) UnmarshalRESP(br *bufio.Reader) error {
+	var ah resp2.ArrayHeader
+	if err := ah.UnmarshalRESP(br); err != nil {
+		return err
+	} else if ah.N < 2 {
+		return errors.New("invalid xautoclaim response")
+	}
+
+	if err := res.next.UnmarshalRESP(br); err != nil {
+		return err
+	}
+
+	// redis 6.2 returns nil for entries which no longer exist, so those need
+	// to be filtered out
+	var rms []resp2.RawMessage
+	if err := (resp2.Any{I: &rms}).UnmarshalRESP(br); err != nil {
+		return err
+	}
+	res.entries = make([]StreamEntry, 0, len(rms))
+	for _, rm := range rms {
+		if rm.IsNil() {
+			continue
+		}
+		var entry StreamEntry
+		if err := rm.UnmarshalInto(&entry); err != nil {
+			return err
+		}
+		res.entries = append(res.entries, entry)
+	}
+
+	// redis 7.0 also returns the IDs of entries which no longer exist, which
+	// aren't needed
+	for i := 2; i < ah.N; i++ {
+		if err := (resp2.Any{}).UnmarshalRESP(br); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 type streamReaderEntry struct {
 	stream  string
 	entries []StreamEntry
+	claimed bool
 }
 
 func (s *streamReaderEntry) UnmarshalRESP(br *bufio.Reader) error {
@@ -0,0 +1,63 @@
+package radix
+
+import (
+	"strings"
+
+	errors "golang.org/x/xerrors"
+
+	"github.com/mediocregopher/radix/v3/resp/resp2"
+)
+
+// isRespErrPrefix returns whether err is an error returned by redis whose
+// message begins with the given prefix.
+func isRespErrPrefix(err error, prefix string) bool {
+	var respErr resp2.Error
+	return errors.As(err, &respErr) && strings.HasPrefix(respErr.Error(), prefix)
+}
+
+type streamGroupAction struct {
+	stream string
+	args   []string
+
+	// ignoreErr is the prefix of an error returned by redis which is ignored.
+	ignoreErr string
+}
+
+func (a streamGroupAction) Keys() []string {
+	return []string{a.stream}
+}
+
+func (a streamGroupAction) Run(c Conn) error {
+	err := c.Do(Cmd(nil, "XGROUP", a.args...))
+	if isRespErrPrefix(err, a.ignoreErr) {
+		return nil
+	}
+	return err
+}
+
+// EnsureGroup returns an Action which creates the given consumer group on the
+// given stream, creating the stream as well if it doesn't exist. start is the
+// ID of the last entry considered delivered to the group, e.g. "$" to only
+// deliver entries added after the group is created, or "0" to deliver all of
+// the stream's entries.
+//
+// If the group already exists it's left as-is, and no error is returned, so
+// that services can call EnsureGroup each time they start consuming.
+func EnsureGroup(stream, group, start string) Action {
+	return streamGroupAction{
+		stream:    stream,
+		args:      []string{"CREATE", stream, group, start, "MKSTREAM"},
+		ignoreErr: "BUSYGROUP",
+	}
+}
+
+// DestroyGroup returns an Action which destroys the given consumer group of the
+// given stream, along with its consumers and pending entries. No error is
+// returned if the group or the stream doesn't exist.
+func DestroyGroup(stream, group string) Action {
+	return streamGroupAction{
+		stream:    stream,
+		args:      []string{"DESTROY", stream, group},
+		ignoreErr: "ERR The XGROUP subcommand requires the key to exist",
+	}
+}
@@ -0,0 +1,38 @@
+package radix
+
+import (
+	. "testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStreamGroupLifecycle(t *T) {
+	c := dial()
+	defer c.Close()
+
+	stream, group := randStr(), randStr()
+	assert.Equal(t, []string{stream}, EnsureGroup(stream, group, "$").Keys())
+
+	// the stream is created along with the group, and ensuring the group
+	// again does nothing
+	require.Nil(t, c.Do(EnsureGroup(stream, group, "$")))
+	require.Nil(t, c.Do(EnsureGroup(stream, group, "0")))
+	var groups []StreamGroupInfo
+	require.Nil(t, c.Do(XInfoGroupsCmd(&groups, stream)))
+	require.Len(t, groups, 1)
+	assert.Equal(t, group, groups[0].Name)
+
+	require.Nil(t, c.Do(DestroyGroup(stream, group)))
+	require.Nil(t, c.Do(XInfoGroupsCmd(&groups, stream)))
+	assert.Empty(t, groups)
+
+	// destroying groups which don't exist does nothing
+	require.Nil(t, c.Do(DestroyGroup(stream, group)))
+	require.Nil(t, c.Do(DestroyGroup(randStr(), group)))
+
+	// other errors are still returned
+	key := randStr()
+	require.Nil(t, c.Do(Cmd(nil, "SET", key, "foo")))
+	assert.Error(t, c.Do(EnsureGroup(key, group, "$")))
+}
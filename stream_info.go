@@ -0,0 +1,300 @@
+package radix
+
+import (
+	"bufio"
+	"strconv"
+	"time"
+
+	errors "golang.org/x/xerrors"
+
+	"github.com/mediocregopher/radix/v3/resp/resp2"
+)
+
+// unmarshalStreamFields unmarshals a reply consisting of alternating field
+// names and values, either as a flat array or a RESP3 map, such as the replies
+// to XINFO. For each field the value to unmarshal it into is looked up in
+// fields; the values of fields not in fields are discarded.
+func unmarshalStreamFields(br *bufio.Reader, fields map[string]interface{}) error {
+	var arrHead resp2.ArrayHeader
+	if err := arrHead.UnmarshalRESP(br); err != nil {
+		return err
+	} else if arrHead.N%2 != 0 {
+		return errors.Errorf("malformed reply with %d elements, expected field/value pairs", arrHead.N)
+	}
+
+	for i := 0; i < arrHead.N; i += 2 {
+		var name string
+		if err := (resp2.Any{I: &name}).UnmarshalRESP(br); err != nil {
+			return err
+		}
+		if err := (resp2.Any{I: fields[name]}).UnmarshalRESP(br); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// StreamInfo describes a stream, as returned by XINFO STREAM. Fields which
+// weren't returned, e.g. because they aren't supported by the version of
+// redis, are left as their zero value.
+type StreamInfo struct {
+	// Length is the number of entries in the stream.
+	Length int64
+
+	RadixTreeKeys  int64
+	RadixTreeNodes int64
+
+	// Groups is the number of consumer groups of the stream.
+	Groups int64
+
+	LastGeneratedID StreamEntryID
+
+	// MaxDeletedEntryID and EntriesAdded require redis 7.0 or above.
+	MaxDeletedEntryID StreamEntryID
+	EntriesAdded      int64
+
+	// FirstEntry and LastEntry are nil if the stream is empty.
+	FirstEntry, LastEntry *StreamEntry
+}
+
+// UnmarshalRESP implements the resp.Unmarshaler interface.
+func (si *StreamInfo) UnmarshalRESP(br *bufio.Reader) error {
+	var info StreamInfo
+	var first, last resp2.RawMessage
+	err := unmarshalStreamFields(br, map[string]interface{}{
+		"length":               &info.Length,
+		"radix-tree-keys":      &info.RadixTreeKeys,
+		"radix-tree-nodes":     &info.RadixTreeNodes,
+		"groups":               &info.Groups,
+		"last-generated-id":    &info.LastGeneratedID,
+		"max-deleted-entry-id": &info.MaxDeletedEntryID,
+		"entries-added":        &info.EntriesAdded,
+		"first-entry":          &first,
+		"last-entry":           &last,
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, e := range []struct {
+		rm  resp2.RawMessage
+		dst **StreamEntry
+	}{
+		{first, &info.FirstEntry},
+		{last, &info.LastEntry},
+	} {
+		if len(e.rm) == 0 || e.rm.IsNil() {
+			continue
+		}
+		*e.dst = new(StreamEntry)
+		if err := e.rm.UnmarshalInto(*e.dst); err != nil {
+			return err
+		}
+	}
+
+	*si = info
+	return nil
+}
+
+// StreamGroupInfo describes a consumer group of a stream, as returned by XINFO
+// GROUPS.
+type StreamGroupInfo struct {
+	Name string
+
+	// Consumers is the number of consumers in the group, and Pending is the
+	// number of entries which have been delivered to them but not yet
+	// acknowledged.
+	Consumers int64
+	Pending   int64
+
+	LastDeliveredID StreamEntryID
+
+	// EntriesRead and Lag require redis 7.0 or above. Lag is -1 if redis
+	// didn't return it or couldn't determine it.
+	EntriesRead int64
+	Lag         int64
+}
+
+// UnmarshalRESP implements the resp.Unmarshaler interface.
+func (gi *StreamGroupInfo) UnmarshalRESP(br *bufio.Reader) error {
+	info := StreamGroupInfo{Lag: -1}
+	lag := resp2.Maybe{Rcv: &info.Lag}
+	err := unmarshalStreamFields(br, map[string]interface{}{
+		"name":              &info.Name,
+		"consumers":         &info.Consumers,
+		"pending":           &info.Pending,
+		"last-delivered-id": &info.LastDeliveredID,
+		"entries-read":      &info.EntriesRead,
+		"lag":               &lag,
+	})
+	if err != nil {
+		return err
+	}
+	*gi = info
+	return nil
+}
+
+// StreamConsumerInfo describes a consumer in a consumer group, as returned by
+// XINFO CONSUMERS.
+type StreamConsumerInfo struct {
+	Name string
+
+	// Pending is the number of entries which have been delivered to the
+	// consumer but not yet acknowledged.
+	Pending int64
+
+	// Idle is the time since the consumer last interacted with the server.
+	Idle time.Duration
+
+	// Inactive is the time since the consumer last successfully read entries,
+	// or -1 if it never has. It requires redis 7.2 or above.
+	Inactive time.Duration
+}
+
+// UnmarshalRESP implements the resp.Unmarshaler interface.
+func (ci *StreamConsumerInfo) UnmarshalRESP(br *bufio.Reader) error {
+	var info StreamConsumerInfo
+	var idle, inactive int64 = 0, -1
+	err := unmarshalStreamFields(br, map[string]interface{}{
+		"name":     &info.Name,
+		"pending":  &info.Pending,
+		"idle":     &idle,
+		"inactive": &inactive,
+	})
+	if err != nil {
+		return err
+	}
+
+	info.Idle = time.Duration(idle) * time.Millisecond
+	info.Inactive = -1
+	if inactive >= 0 {
+		info.Inactive = time.Duration(inactive) * time.Millisecond
+	}
+	*ci = info
+	return nil
+}
+
+// StreamPendingSummary describes the pending entries of a consumer group, as
+// returned by the summary form of XPENDING.
+type StreamPendingSummary struct {
+	// Count is the number of pending entries.
+	Count int64
+
+	// Lowest and Highest are the lowest and highest IDs of the pending
+	// entries. They are the zero StreamEntryID if Count is 0.
+	Lowest, Highest StreamEntryID
+
+	// Consumers maps the name of each consumer with pending entries to the
+	// number of entries pending for it.
+	Consumers map[string]int64
+}
+
+// UnmarshalRESP implements the resp.Unmarshaler interface.
+func (ps *StreamPendingSummary) UnmarshalRESP(br *bufio.Reader) error {
+	var arrHead resp2.ArrayHeader
+	if err := arrHead.UnmarshalRESP(br); err != nil {
+		return err
+	} else if arrHead.N != 4 {
+		return errors.Errorf("malformed XPENDING summary with %d elements", arrHead.N)
+	}
+
+	summary := StreamPendingSummary{Consumers: map[string]int64{}}
+	if err := (resp2.Any{I: &summary.Count}).UnmarshalRESP(br); err != nil {
+		return err
+	}
+	for _, id := range []*StreamEntryID{&summary.Lowest, &summary.Highest} {
+		if err := (&resp2.Maybe{Rcv: id}).UnmarshalRESP(br); err != nil {
+			return err
+		}
+	}
+
+	// each consumer is given as a pair of its name and its count, the latter
+	// as a bulk string. When there are no consumers the whole list is nil.
+	var consumers [][]string
+	if err := (resp2.Any{I: &consumers}).UnmarshalRESP(br); err != nil {
+		return err
+	}
+	for _, c := range consumers {
+		if len(c) != 2 {
+			return errors.Errorf("malformed XPENDING consumer with %d elements", len(c))
+		}
+		n, err := strconv.ParseInt(c[1], 10, 64)
+		if err != nil {
+			return errors.Errorf("malformed XPENDING count %q for consumer %q", c[1], c[0])
+		}
+		summary.Consumers[c[0]] = n
+	}
+
+	*ps = summary
+	return nil
+}
+
+// StreamPendingEntry describes a single pending entry of a consumer group, as
+// returned by the extended form of XPENDING.
+type StreamPendingEntry struct {
+	ID StreamEntryID
+
+	// Consumer is the name of the consumer which the entry was delivered to.
+	Consumer string
+
+	// Idle is the time since the entry was last delivered.
+	Idle time.Duration
+
+	// Deliveries is the number of times the entry has been delivered.
+	Deliveries int64
+}
+
+// UnmarshalRESP implements the resp.Unmarshaler interface.
+func (pe *StreamPendingEntry) UnmarshalRESP(br *bufio.Reader) error {
+	var arrHead resp2.ArrayHeader
+	if err := arrHead.UnmarshalRESP(br); err != nil {
+		return err
+	} else if arrHead.N != 4 {
+		return errors.Errorf("malformed XPENDING entry with %d elements", arrHead.N)
+	}
+
+	var entry StreamPendingEntry
+	var idle int64
+	for _, dst := range []interface{}{&entry.ID, &entry.Consumer, &idle, &entry.Deliveries} {
+		if err := (resp2.Any{I: dst}).UnmarshalRESP(br); err != nil {
+			return err
+		}
+	}
+	entry.Idle = time.Duration(idle) * time.Millisecond
+	*pe = entry
+	return nil
+}
+
+// XInfoStreamCmd returns a CmdAction which performs XINFO STREAM on the given
+// stream, writing its description into rcv.
+func XInfoStreamCmd(rcv *StreamInfo, stream string) CmdAction {
+	return Cmd(rcv, "XINFO", "STREAM", stream)
+}
+
+// XInfoGroupsCmd returns a CmdAction which performs XINFO GROUPS on the given
+// stream, writing the description of each of its consumer groups into rcv.
+func XInfoGroupsCmd(rcv *[]StreamGroupInfo, stream string) CmdAction {
+	return Cmd(rcv, "XINFO", "GROUPS", stream)
+}
+
+// XInfoConsumersCmd returns a CmdAction which performs XINFO CONSUMERS on the
+// given consumer group of the given stream, writing the description of each of
+// its consumers into rcv.
+func XInfoConsumersCmd(rcv *[]StreamConsumerInfo, stream, group string) CmdAction {
+	return Cmd(rcv, "XINFO", "CONSUMERS", stream, group)
+}
+
+// XPendingSummaryCmd returns a CmdAction which performs the summary form of
+// XPENDING on the given consumer group of the given stream, writing the summary
+// into rcv.
+func XPendingSummaryCmd(rcv *StreamPendingSummary, stream, group string) CmdAction {
+	return Cmd(rcv, "XPENDING", stream, group)
+}
+
+// XPendingCmd returns a CmdAction which performs the extended form of XPENDING
+// on the given consumer group of the given stream, writing up to count pending
+// entries with IDs between start and end (inclusive) into rcv. start and end
+// may be "-" and "+" to mean the lowest and highest possible IDs.
+func XPendingCmd(rcv *[]StreamPendingEntry, stream, group, start, end string, count int) CmdAction {
+	return Cmd(rcv, "XPENDING", stream, group, start, end, strconv.Itoa(count))
+}
@@ -0,0 +1,155 @@
+package radix
+
+import (
+	. "testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStreamInfoCmds(t *T) {
+	var gotArgs [][]string
+	conn := Stub("tcp", "127.0.0.1:6379", func(args []string) interface{} {
+		gotArgs = append(gotArgs, args)
+		switch {
+		case args[0] == "XINFO" && args[1] == "STREAM" && args[2] == "empty":
+			return []interface{}{
+				"length", int64(0),
+				"last-generated-id", "0-0",
+				"first-entry", nil,
+				"last-entry", nil,
+			}
+		case args[0] == "XINFO" && args[1] == "STREAM":
+			return []interface{}{
+				"length", int64(2),
+				"radix-tree-keys", int64(1),
+				"radix-tree-nodes", int64(2),
+				"last-generated-id", "1638125141232-0",
+				"max-deleted-entry-id", "0-0",
+				"entries-added", int64(2),
+				"recorded-first-entry-id", "1638125133432-0",
+				"groups", int64(1),
+				"first-entry", []interface{}{"1638125133432-0", []string{"message", "apple"}},
+				"last-entry", []interface{}{"1638125141232-0", []string{"message", "banana"}},
+			}
+		case args[0] == "XINFO" && args[1] == "GROUPS":
+			return []interface{}{
+				[]interface{}{
+					"name", "mygroup",
+					"consumers", int64(2),
+					"pending", int64(2),
+					"last-delivered-id", "1638126030001-0",
+					"entries-read", int64(2),
+					"lag", int64(0),
+				},
+				// groups from before redis 7.0 have no entries-read or lag
+				[]interface{}{
+					"name", "oldgroup",
+					"consumers", int64(0),
+					"pending", int64(0),
+					"last-delivered-id", "0-0",
+				},
+			}
+		case args[0] == "XINFO" && args[1] == "CONSUMERS":
+			return []interface{}{
+				[]interface{}{
+					"name", "Alice",
+					"pending", int64(1),
+					"idle", int64(9104628),
+					"inactive", int64(18104698),
+				},
+				[]interface{}{
+					"name", "Bob",
+					"pending", int64(1),
+					"idle", int64(83841983),
+				},
+			}
+		case args[0] == "XPENDING" && args[1] == "empty":
+			return []interface{}{int64(0), nil, nil, nil}
+		case args[0] == "XPENDING" && len(args) == 3:
+			return []interface{}{
+				int64(2), "1526984818136-0", "1526984818137-0",
+				[]interface{}{
+					[]string{"Alice", "1"},
+					[]string{"Bob", "1"},
+				},
+			}
+		default: // extended XPENDING
+			return []interface{}{
+				[]interface{}{"1526984818136-0", "Alice", int64(196415), int64(1)},
+			}
+		}
+	})
+
+	var si StreamInfo
+	require.Nil(t, conn.Do(XInfoStreamCmd(&si, "mystream")))
+	assert.Equal(t, StreamInfo{
+		Length:          2,
+		RadixTreeKeys:   1,
+		RadixTreeNodes:  2,
+		Groups:          1,
+		LastGeneratedID: StreamEntryID{Time: 1638125141232},
+		EntriesAdded:    2,
+		FirstEntry: &StreamEntry{
+			ID:     StreamEntryID{Time: 1638125133432},
+			Fields: map[string]string{"message": "apple"},
+		},
+		LastEntry: &StreamEntry{
+			ID:     StreamEntryID{Time: 1638125141232},
+			Fields: map[string]string{"message": "banana"},
+		},
+	}, si)
+
+	require.Nil(t, conn.Do(XInfoStreamCmd(&si, "empty")))
+	assert.Equal(t, StreamInfo{}, si)
+
+	var groups []StreamGroupInfo
+	require.Nil(t, conn.Do(XInfoGroupsCmd(&groups, "mystream")))
+	assert.Equal(t, []StreamGroupInfo{
+		{
+			Name:            "mygroup",
+			Consumers:       2,
+			Pending:         2,
+			LastDeliveredID: StreamEntryID{Time: 1638126030001},
+			EntriesRead:     2,
+			Lag:             0,
+		},
+		{Name: "oldgroup", Lag: -1},
+	}, groups)
+
+	var consumers []StreamConsumerInfo
+	require.Nil(t, conn.Do(XInfoConsumersCmd(&consumers, "mystream", "mygroup")))
+	assert.Equal(t, []StreamConsumerInfo{
+		{
+			Name:     "Alice",
+			Pending:  1,
+			Idle:     9104628 * time.Millisecond,
+			Inactive: 18104698 * time.Millisecond,
+		},
+		{Name: "Bob", Pending: 1, Idle: 83841983 * time.Millisecond, Inactive: -1},
+	}, consumers)
+
+	var ps StreamPendingSummary
+	require.Nil(t, conn.Do(XPendingSummaryCmd(&ps, "mystream", "mygroup")))
+	assert.Equal(t, StreamPendingSummary{
+		Count:     2,
+		Lowest:    StreamEntryID{Time: 1526984818136},
+		Highest:   StreamEntryID{Time: 1526984818137},
+		Consumers: map[string]int64{"Alice": 1, "Bob": 1},
+	}, ps)
+
+	require.Nil(t, conn.Do(XPendingSummaryCmd(&ps, "empty", "mygroup")))
+	assert.Equal(t, StreamPendingSummary{Consumers: map[string]int64{}}, ps)
+
+	var pending []StreamPendingEntry
+	require.Nil(t, conn.Do(XPendingCmd(&pending, "mystream", "mygroup", "-", "+", 10)))
+	assert.Equal(t, []StreamPendingEntry{{
+		ID:         StreamEntryID{Time: 1526984818136},
+		Consumer:   "Alice",
+		Idle:       196415 * time.Millisecond,
+		Deliveries: 1,
+	}}, pending)
+
+	assert.Equal(t, []string{"XPENDING", "mystream", "mygroup", "-", "+", "10"}, gotArgs[len(gotArgs)-1])
+}
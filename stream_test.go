@@ -12,6 +12,9 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	errors "golang.org/x/xerrors"
+
+	"github.com/mediocregopher/radix/v3/resp/resp2"
 )
 
 func TestStreamEntryID(t *T) {
@@ -650,9 +653,209 @@ func TestStreamReader(t *T) {
 			assertNoStreamReaderEntries(t, r2)
 			assertConsumer(t, c, stream, group, consumer, 2)
 		})
+
+		t.Run("AckAndClaim", func(t *T) {
+			c := dial()
+			defer c.Close()
+
+			consumer1, consumer2, group := randStr(), randStr(), randStr()
+			stream := randStr()
+
+			newReader := func(consumer string) StreamGroupReader {
+				return NewStreamReader(c, StreamReaderOpts{
+					Streams: map[string]*StreamEntryID{
+						stream: nil,
+					},
+					Group:    group,
+					Consumer: consumer,
+					NoBlock:  true,
+				}).(StreamGroupReader)
+			}
+			r1, r2 := newReader(consumer1), newReader(consumer2)
+
+			addStreamGroup(t, c, stream, group, "0-0")
+
+			ids := addNStreamEntries(t, c, stream, 2)
+			assertStreamReaderEntries(t, r1, map[string][]StreamEntryID{stream: ids})
+			assertConsumer(t, c, stream, group, consumer1, 2)
+
+			require.NoError(t, r1.Ack(stream, ids[0]))
+			assertConsumer(t, c, stream, group, consumer1, 1)
+
+			claimed, err := r2.Claim(stream, 0, ids[1])
+			require.NoError(t, err)
+			require.Len(t, claimed, 1)
+			assert.Equal(t, ids[1], claimed[0].ID)
+			assertConsumer(t, c, stream, group, consumer1, 0)
+			assertConsumer(t, c, stream, group, consumer2, 1)
+
+			require.NoError(t, r2.Ack(stream, ids[1]))
+			assertConsumer(t, c, stream, group, consumer2, 0)
+		})
+
+		t.Run("AutoClaim", func(t *T) {
+			c := dial()
+			defer c.Close()
+
+			consumer1, consumer2, group := randStr(), randStr(), randStr()
+			stream := randStr()
+
+			newReader := func(consumer string, opts StreamReaderOpts) StreamReader {
+				opts.Streams = map[string]*StreamEntryID{stream: nil}
+				opts.Group, opts.Consumer = group, consumer
+				opts.NoBlock = true
+				return NewStreamReader(c, opts)
+			}
+			r1 := newReader(consumer1, StreamReaderOpts{})
+			r2 := newReader(consumer2, StreamReaderOpts{
+				AutoClaimIdle:     10 * time.Millisecond,
+				AutoClaimInterval: time.Hour,
+			})
+
+			addStreamGroup(t, c, stream, group, "0-0")
+			ids := addNStreamEntries(t, c, stream, 2)
+			assertStreamReaderEntries(t, r1, map[string][]StreamEntryID{stream: ids})
+			time.Sleep(20 * time.Millisecond)
+
+			// the first call to Next claims the entries delivered to r1, and
+			// later ones don't until the interval has passed
+			assertStreamReaderEntries(t, r2, map[string][]StreamEntryID{stream: ids})
+			assertConsumer(t, c, stream, group, consumer1, 0)
+			assertConsumer(t, c, stream, group, consumer2, 2)
+			assertNoStreamReaderEntries(t, r2)
+		})
+
+		t.Run("AutoClaimFallback", func(t *T) {
+			// versions of redis before 6.2 don't support XAUTOCLAIM, in which
+			// case XPENDING and XCLAIM are used
+			var cmds []string
+			conn := Stub("tcp", "127.0.0.1:6379", func(args []string) interface{} {
+				cmds = append(cmds, args[0])
+				switch args[0] {
+				case "XAUTOCLAIM":
+					return resp2.Error{E: errors.New("ERR unknown command 'XAUTOCLAIM'")}
+				case "XPENDING":
+					return []interface{}{
+						[]interface{}{"1-0", "other", int64(60000), int64(1)},
+						[]interface{}{"2-0", "other", int64(1), int64(1)},
+					}
+				case "XCLAIM":
+					return []interface{}{
+						[]interface{}{"1-0", []string{"foo", "bar"}},
+					}
+				default: // XREADGROUP
+					return nil
+				}
+			})
+
+			r := NewStreamReader(conn, StreamReaderOpts{
+				Streams:       map[string]*StreamEntryID{"stream": nil},
+				Group:         "group",
+				Consumer:      "consumer",
+				NoBlock:       true,
+				AutoClaimIdle: time.Second,
+			})
+			assertStreamReaderEntries(t, r, map[string][]StreamEntryID{
+				"stream": {{Time: 1}},
+			})
+			assert.Equal(t, []string{"XAUTOCLAIM", "XPENDING", "XCLAIM"}, cmds)
+		})
+
+		t.Run("DeadLetter", func(t *T) {
+			c := dial()
+			defer c.Close()
+
+			consumer1, consumer2, group := randStr(), randStr(), randStr()
+			stream := randStr()
+
+			type deadLetter struct {
+				stream     string
+				id         StreamEntryID
+				deliveries int64
+			}
+			var deadLetters []deadLetter
+
+			newReader := func(consumer string, opts StreamReaderOpts) StreamReader {
+				opts.Streams = map[string]*StreamEntryID{stream: nil}
+				opts.Group, opts.Consumer = group, consumer
+				opts.NoBlock = true
+				return NewStreamReader(c, opts)
+			}
+			r1 := newReader(consumer1, StreamReaderOpts{})
+			r2 := newReader(consumer2, StreamReaderOpts{
+				AutoClaimIdle: 10 * time.Millisecond,
+				MaxDeliveries: 1,
+				DeadLetter: func(stream string, entry StreamEntry, deliveries int64) error {
+					deadLetters = append(deadLetters, deadLetter{stream, entry.ID, deliveries})
+					return nil
+				},
+			})
+
+			addStreamGroup(t, c, stream, group, "0-0")
+			ids := addNStreamEntries(t, c, stream, 1)
+			assertStreamReaderEntries(t, r1, map[string][]StreamEntryID{stream: ids})
+			time.Sleep(20 * time.Millisecond)
+
+			// the entry has already been delivered once, so it's passed to
+			// DeadLetter and acknowledged rather than being claimed
+			assertNoStreamReaderEntries(t, r2)
+			assert.Equal(t, []deadLetter{{stream, ids[0], 1}}, deadLetters)
+			assertConsumer(t, c, stream, group, consumer1, 0)
+		})
+
+		t.Run("DeadLetterPaged", func(t *T) {
+			c := dial()
+			defer c.Close()
+
+			consumer1, consumer2, group := randStr(), randStr(), randStr()
+			stream := randStr()
+
+			var deadLetters []StreamEntryID
+			newReader := func(consumer string, opts StreamReaderOpts) StreamReader {
+				opts.Streams = map[string]*StreamEntryID{stream: nil}
+				opts.Group, opts.Consumer = group, consumer
+				opts.NoBlock = true
+				return NewStreamReader(c, opts)
+			}
+			r1 := newReader(consumer1, StreamReaderOpts{})
+			r2 := newReader(consumer2, StreamReaderOpts{
+				Count:         2,
+				AutoClaimIdle: 10 * time.Millisecond,
+				MaxDeliveries: 1,
+				DeadLetter: func(stream string, entry StreamEntry, deliveries int64) error {
+					deadLetters = append(deadLetters, entry.ID)
+					return nil
+				},
+			})
+
+			// there are more pending entries than r2's Count, all of which
+			// should be dead-lettered
+			addStreamGroup(t, c, stream, group, "0-0")
+			ids := addNStreamEntries(t, c, stream, 5)
+			assertStreamReaderEntries(t, r1, map[string][]StreamEntryID{stream: ids})
+			time.Sleep(20 * time.Millisecond)
+
+			assertNoStreamReaderEntries(t, r2)
+			assert.Equal(t, ids, deadLetters)
+			assertConsumer(t, c, stream, group, consumer1, 0)
+		})
 	})
 
 	t.Run("NoGroup", func(t *T) {
+		t.Run("NoAckOrClaim", func(t *T) {
+			c := dial()
+			defer c.Close()
+
+			stream := randStr()
+			r := NewStreamReader(c, StreamReaderOpts{
+				Streams: map[string]*StreamEntryID{stream: nil},
+				NoBlock: true,
+			}).(StreamGroupReader)
+			assert.Error(t, r.Ack(stream, StreamEntryID{Time: 1}))
+			_, err := r.Claim(stream, 0, StreamEntryID{Time: 1})
+			assert.Error(t, err)
+		})
+
 		t.Run("Empty", func(t *T) {
 			c := dial()
 			defer c.Close()
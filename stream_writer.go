@@ -0,0 +1,150 @@
+package radix
+
+import (
+	"sort"
+	"strconv"
+	"time"
+
+	errors "golang.org/x/xerrors"
+)
+
+// StreamWriterOpts contains various options given for NewStreamWriter that
+// influence the behaviour.
+//
+// All fields are optional.
+type StreamWriterOpts struct {
+	// MaxLen optionally trims each stream to at most MaxLen entries whenever
+	// an entry is added to it, using XADD's MAXLEN option.
+	MaxLen int64
+
+	// MaxAge optionally trims entries whose IDs are older than MaxAge from
+	// each stream whenever an entry is added to it, using XADD's MINID option.
+	// This assumes the entries' IDs are generated by redis, or are otherwise
+	// based on the time in milliseconds. MINID requires redis 6.2 or above.
+	//
+	// MaxLen and MaxAge can't both be set.
+	MaxAge time.Duration
+
+	// ExactTrim causes streams to be trimmed exactly as specified by MaxLen or
+	// MaxAge. By default trimming is approximate, with redis only removing
+	// entries when it can do so efficiently, which may leave some more entries
+	// in the stream.
+	ExactTrim bool
+
+	// BatchSize optionally enables batching of added entries. If it's greater
+	// than 1 then entries are buffered by Add, and added using a single
+	// Pipeline once BatchSize entries are buffered, or Flush is called.
+	BatchSize int
+}
+
+// StreamWriter adds entries to one or more streams using XADD.
+//
+// A StreamWriter is not safe for concurrent use.
+type StreamWriter interface {
+	// Add adds an entry with the given fields to the given stream.
+	//
+	// If id is nil or points to the zero StreamEntryID then redis generates
+	// the entry's ID, and it's written into id if it's not nil. Otherwise the
+	// entry is given the ID which id points to.
+	//
+	// If batching is enabled then the entry may only be buffered, in which
+	// case any generated ID is written into id when the batch is added, and
+	// any error adding it is returned from the call to Add or Flush which
+	// does so.
+	Add(stream string, id *StreamEntryID, fields map[string]string) error
+
+	// Flush adds any entries which were buffered by Add. If an error is
+	// returned then some of the buffered entries may not have been added.
+	Flush() error
+}
+
+// NewStreamWriter returns a new StreamWriter for the given client.
+//
+// Any changes on opts after calling NewStreamWriter will have no effect.
+func NewStreamWriter(c Client, opts StreamWriterOpts) StreamWriter {
+	return &streamWriter{c: c, opts: opts}
+}
+
+// streamWriter implements the StreamWriter interface.
+type streamWriter struct {
+	c    Client
+	opts StreamWriterOpts
+
+	batch []CmdAction
+}
+
+func (sw *streamWriter) trimArgs() ([]string, error) {
+	op := "~"
+	if sw.opts.ExactTrim {
+		op = "="
+	}
+
+	switch {
+	case sw.opts.MaxLen > 0 && sw.opts.MaxAge > 0:
+		return nil, errors.New("StreamWriterOpts.MaxLen and MaxAge can't both be set")
+	case sw.opts.MaxLen > 0:
+		return []string{"MAXLEN", op, strconv.FormatInt(sw.opts.MaxLen, 10)}, nil
+	case sw.opts.MaxAge > 0:
+		minID := StreamEntryID{
+			Time: uint64(time.Now().Add(-sw.opts.MaxAge).UnixNano() / int64(time.Millisecond)),
+		}
+		return []string{"MINID", op, minID.String()}, nil
+	default:
+		return nil, nil
+	}
+}
+
+// Add implements the StreamWriter interface.
+func (sw *streamWriter) Add(stream string, id *StreamEntryID, fields map[string]string) error {
+	if len(fields) == 0 {
+		return errors.New("stream entries must have at least one field")
+	}
+
+	args, err := sw.trimArgs()
+	if err != nil {
+		return err
+	}
+	args = append([]string{stream}, args...)
+
+	if id == nil || *id == (StreamEntryID{}) {
+		args = append(args, "*")
+	} else {
+		args = append(args, id.String())
+	}
+
+	// fields are sorted so that entries are added consistently
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		args = append(args, k, fields[k])
+	}
+
+	var cmd CmdAction
+	if id == nil {
+		cmd = Cmd(nil, "XADD", args...)
+	} else {
+		cmd = Cmd(id, "XADD", args...)
+	}
+
+	if sw.opts.BatchSize <= 1 {
+		return sw.c.Do(cmd)
+	}
+	sw.batch = append(sw.batch, cmd)
+	if len(sw.batch) < sw.opts.BatchSize {
+		return nil
+	}
+	return sw.Flush()
+}
+
+// Flush implements the StreamWriter interface.
+func (sw *streamWriter) Flush() error {
+	if len(sw.batch) == 0 {
+		return nil
+	}
+	batch := sw.batch
+	sw.batch = nil
+	return sw.c.Do(Pipeline(batch...))
+}
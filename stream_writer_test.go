@@ -0,0 +1,99 @@
+package radix
+
+import (
+	"strconv"
+	"strings"
+	. "testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStreamWriter(t *T) {
+	c := dial()
+	defer c.Close()
+
+	t.Run("Add", func(t *T) {
+		stream := randStr()
+		sw := NewStreamWriter(c, StreamWriterOpts{})
+
+		var id StreamEntryID
+		require.NoError(t, sw.Add(stream, &id, map[string]string{"foo": "1"}))
+		assert.NotEqual(t, StreamEntryID{}, id)
+
+		explicit := StreamEntryID{Time: id.Time + 1, Seq: 5}
+		require.NoError(t, sw.Add(stream, &explicit, map[string]string{"foo": "2"}))
+		assert.Equal(t, StreamEntryID{Time: id.Time + 1, Seq: 5}, explicit)
+
+		require.NoError(t, sw.Add(stream, nil, map[string]string{"foo": "3"}))
+
+		var entries []StreamEntry
+		require.NoError(t, c.Do(Cmd(&entries, "XRANGE", stream, "-", "+")))
+		require.Len(t, entries, 3)
+		assert.Equal(t, id, entries[0].ID)
+		assert.Equal(t, explicit, entries[1].ID)
+		for i, e := range entries {
+			assert.Equal(t, map[string]string{"foo": string('1' + rune(i))}, e.Fields)
+		}
+
+		// IDs must increase
+		assert.Error(t, sw.Add(stream, &explicit, map[string]string{"foo": "4"}))
+	})
+
+	t.Run("Batch", func(t *T) {
+		stream := randStr()
+		sw := NewStreamWriter(c, StreamWriterOpts{BatchSize: 3})
+
+		ids := make([]StreamEntryID, 4)
+		for i := range ids {
+			require.NoError(t, sw.Add(stream, &ids[i], map[string]string{"foo": "bar"}))
+		}
+
+		var n int
+		require.NoError(t, c.Do(Cmd(&n, "XLEN", stream)))
+		assert.Equal(t, 3, n)
+		assert.NotEqual(t, StreamEntryID{}, ids[2])
+		assert.Equal(t, StreamEntryID{}, ids[3])
+
+		require.NoError(t, sw.Flush())
+		require.NoError(t, c.Do(Cmd(&n, "XLEN", stream)))
+		assert.Equal(t, 4, n)
+		assert.True(t, ids[2].Before(ids[3]))
+
+		// flushing with nothing buffered does nothing
+		require.NoError(t, sw.Flush())
+	})
+
+}
+
+func TestStreamWriterArgs(t *T) {
+	var gotArgs [][]string
+	conn := Stub("tcp", "127.0.0.1:6379", func(args []string) interface{} {
+		gotArgs = append(gotArgs, args)
+		return "1-1"
+	})
+	fields := map[string]string{"b": "2", "a": "1"}
+
+	sw := NewStreamWriter(conn, StreamWriterOpts{MaxLen: 10})
+	require.NoError(t, sw.Add("stream", nil, fields))
+	assert.Equal(t, []string{"XADD", "stream", "MAXLEN", "~", "10", "*", "a", "1", "b", "2"}, gotArgs[0])
+
+	sw = NewStreamWriter(conn, StreamWriterOpts{MaxAge: time.Hour, ExactTrim: true})
+	id := StreamEntryID{Time: 5, Seq: 6}
+	require.NoError(t, sw.Add("stream", &id, fields))
+	args := gotArgs[1]
+	require.Len(t, args, 10)
+	assert.Equal(t, []string{"XADD", "stream", "MINID", "="}, args[:4])
+	require.True(t, strings.HasSuffix(args[4], "-0"), "minID:%q", args[4])
+	minMs, err := strconv.ParseInt(strings.TrimSuffix(args[4], "-0"), 10, 64)
+	require.NoError(t, err)
+	minAge := time.Since(time.Unix(0, minMs*int64(time.Millisecond)))
+	assert.True(t, minAge >= time.Hour && minAge < time.Hour+time.Minute, "minAge:%v", minAge)
+	assert.Equal(t, []string{"5-6", "a", "1", "b", "2"}, args[5:])
+
+	sw = NewStreamWriter(conn, StreamWriterOpts{MaxLen: 10, MaxAge: time.Hour})
+	assert.Error(t, sw.Add("stream", nil, fields))
+	assert.Error(t, NewStreamWriter(conn, StreamWriterOpts{}).Add("stream", nil, nil))
+	assert.Len(t, gotArgs, 2)
+}
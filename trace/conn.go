@@ -0,0 +1,92 @@
+package trace
+
+import "time"
+
+// ConnTrace is passed into radix.Dial via radix.DialWithTrace, and contains
+// callbacks which will be triggered for specific events during the Conn's
+// lifetime.
+//
+// All callbacks are called synchronously.
+type ConnTrace struct {
+	// Dialed is called once Dial has finished creating the connection,
+	// including any AUTH, SELECT, etc... commands it performs. The provided
+	// Err indicates whether the connection was successfully created.
+	Dialed func(ConnDialed)
+
+	// Closed is called after the connection has been closed.
+	Closed func(ConnClosed)
+
+	// DoStarted is called before an Action is performed on the connection.
+	DoStarted func(ConnDoStarted)
+
+	// DoCompleted is called after an Action has been performed on the
+	// connection.
+	DoCompleted func(ConnDoCompleted)
+}
+
+// ConnCommon contains information which is passed into all Conn-related
+// callbacks.
+type ConnCommon struct {
+	// Network and Addr indicate the network/address the Conn was dialed with.
+	Network, Addr string
+}
+
+// ConnDialed is passed into the ConnTrace.Dialed callback whenever Dial
+// finishes.
+type ConnDialed struct {
+	ConnCommon
+
+	// How long it took to create the connection.
+	ConnectTime time.Duration
+
+	// If connection creation failed, this is the error it failed with.
+	Err error
+}
+
+// ConnClosed is passed into the ConnTrace.Closed callback whenever the Conn is
+// closed.
+type ConnClosed struct {
+	ConnCommon
+
+	// The error returned from closing the connection, if any.
+	Err error
+}
+
+// ConnDoStarted is passed into the ConnTrace.DoStarted callback whenever an
+// Action is about to be performed on the Conn.
+type ConnDoStarted struct {
+	ConnCommon
+
+	// Cmd is the name of the command being performed, e.g. "GET". It will be
+	// empty if the Action doesn't perform exactly one known command, such as
+	// for a Pipeline of several commands or WithConn.
+	Cmd string
+
+	// Cmds are the names of all commands being performed, in order, as
+	// returned by radix.ActionCmdNames. Unlike Cmd this is set for Pipelines,
+	// including the ones which a Pool creates when implicitly pipelining
+	// concurrent commands, in which case a single Action is performed on the
+	// Conn for the whole batch.
+	Cmds []string
+
+	// NumKeys is the number of keys the Action indicates it will act on.
+	NumKeys int
+}
+
+// ConnDoCompleted is passed into the ConnTrace.DoCompleted callback whenever an
+// Action has been performed on the Conn.
+type ConnDoCompleted struct {
+	ConnDoStarted
+
+	// BytesWritten and BytesRead are the number of bytes written to and read
+	// from the network while performing the Action. Since reads are buffered
+	// BytesRead may include the beginning of data belonging to a later
+	// response, or exclude data which was read by an earlier one.
+	BytesWritten, BytesRead int64
+
+	// How long it took to perform the Action.
+	ElapsedTime time.Duration
+
+	// This is the error returned from performing the Action.
+	Err error
+}
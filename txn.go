@@ -0,0 +1,205 @@
+package radix
+
+import (
+	"bufio"
+
+	errors "golang.org/x/xerrors"
+
+	"github.com/mediocregopher/radix/v3/resp/resp2"
+)
+
+// ErrTxnAborted is returned from a WithTxn Action when the transaction was
+// aborted on every attempt, due to one of the watched keys being modified.
+var ErrTxnAborted = errors.New("transaction aborted: watched key was modified")
+
+// Txn is used to queue the commands of a transaction within the callback of a
+// WithTxn Action.
+type Txn struct {
+	cmds []CmdAction
+}
+
+// Queue adds the given CmdActions to the transaction. The CmdActions are sent
+// to redis within a MULTI/EXEC block once the callback returns, and their
+// receivers are only filled once the EXEC has succeeded.
+func (txn *Txn) Queue(cmds ...CmdAction) {
+	txn.cmds = append(txn.cmds, cmds...)
+}
+
+type txnOpts struct {
+	maxAttempts int
+}
+
+// TxnOpt is an optional behavior which can be applied to the WithTxn function
+// to effect its behavior.
+type TxnOpt func(*txnOpts)
+
+// TxnMaxAttempts sets the maximum number of times the transaction will be
+// attempted, including the first attempt, before ErrTxnAborted is returned. If
+// n is 0 or less then the transaction will be attempted until it succeeds.
+func TxnMaxAttempts(n int) TxnOpt {
+	return func(to *txnOpts) {
+		to.maxAttempts = n
+	}
+}
+
+type txnAction struct {
+	keys []string
+	fn   func(Conn, *Txn) error
+	opts txnOpts
+}
+
+// WithTxn is used to perform an optimistic transaction, as described by:
+// 	https://redis.io/topics/transactions#optimistic-locking-using-check-and-set
+//
+// On each attempt WithTxn will WATCH the given keys and call the callback. The
+// callback may perform Actions on the given Conn in order to read the current
+// state of the keys, and should then Queue the commands of the transaction on
+// the given Txn. Those commands are performed within a MULTI/EXEC block. If
+// EXEC indicates that one of the watched keys was modified then the whole
+// process is attempted again, with a fresh Txn.
+//
+// Because the callback may be called more than once it must create new
+// CmdActions on each call, and it should not have side-effects other than the
+// Actions it performs on the Conn.
+//
+// If the callback returns an error then the keys are UNWATCHed and that error
+// is returned as-is. If a command fails to be queued, or fails during the EXEC,
+// then the first such error is returned and the transaction is not
+// re-attempted. Note that redis does not roll back the other commands of a
+// transaction when one fails during EXEC.
+//
+// When used with a Cluster all given keys must belong to the same slot.
+//
+// The default options WithTxn uses are:
+//
+//	TxnMaxAttempts(5)
+//
+func WithTxn(keys []string, fn func(Conn, *Txn) error, opts ...TxnOpt) Action {
+	ta := &txnAction{keys: keys, fn: fn}
+
+	defaultTxnOpts := []TxnOpt{
+		TxnMaxAttempts(5),
+	}
+
+	for _, opt := range append(defaultTxnOpts, opts...) {
+		opt(&(ta.opts))
+	}
+	return ta
+}
+
+func (ta *txnAction) Keys() []string {
+	return ta.keys
+}
+
+func (ta *txnAction) Run(conn Conn) error {
+	for attempt := 1; ; attempt++ {
+		ok, err := ta.attempt(conn)
+		if err != nil || ok {
+			return err
+		} else if ta.opts.maxAttempts > 0 && attempt >= ta.opts.maxAttempts {
+			return ErrTxnAborted
+		}
+	}
+}
+
+// attempt performs the transaction once, returning false if the EXEC was
+// aborted because a watched key was modified.
+func (ta *txnAction) attempt(conn Conn) (bool, error) {
+	if len(ta.keys) > 0 {
+		if err := conn.Do(Cmd(nil, "WATCH", ta.keys...)); err != nil {
+			return false, err
+		}
+	}
+
+	var txn Txn
+	if err := ta.fn(conn, &txn); err != nil {
+		if len(ta.keys) > 0 {
+			// if UNWATCH fails the Conn is likely broken, in which case the
+			// watched keys are of no concern, and the error from fn is the more
+			// interesting one
+			_ = conn.Do(Cmd(nil, "UNWATCH"))
+		}
+		return false, err
+	}
+
+	block := make(pipeline, 0, len(txn.cmds)+2)
+	block = append(block, Cmd(nil, "MULTI"))
+	block = append(block, txn.cmds...)
+	block = append(block, Cmd(nil, "EXEC"))
+	if err := conn.Encode(block); err != nil {
+		return false, err
+	}
+
+	// MULTI replies with OK and each command with QUEUED, unless the command
+	// could not be queued
+	var firstErr error
+	for range block[:len(block)-1] {
+		err := conn.Decode(resp2.Any{})
+		if errors.As(err, new(resp2.Error)) {
+			if firstErr == nil {
+				firstErr = err
+			}
+		} else if err != nil {
+			return false, err
+		}
+	}
+
+	exec := txnExec{cmds: txn.cmds}
+	err := conn.Decode(&exec)
+	if firstErr != nil {
+		// EXEC will have replied with EXECABORT, the original error is more
+		// useful
+		return false, firstErr
+	} else if err != nil {
+		return false, err
+	}
+	return !exec.aborted, exec.err
+}
+
+// txnExec unmarshals the reply to an EXEC into the receivers of the
+// transaction's CmdActions.
+type txnExec struct {
+	cmds    []CmdAction
+	aborted bool
+	err     error
+}
+
+func (te *txnExec) UnmarshalRESP(br *bufio.Reader) error {
+	b, err := br.Peek(1)
+	if err != nil {
+		return err
+	} else if b[0] == resp2.ErrorPrefix[0] || b[0] == resp2.BlobErrorPrefix[0] {
+		return (resp2.Any{}).UnmarshalRESP(br)
+	}
+
+	// an aborted EXEC replies with a nil array, or with a null in RESP3
+	if b[0] == resp2.NullPrefix[0] {
+		_, err := br.Discard(len(resp2.NullPrefix) + 2)
+		te.aborted = true
+		return err
+	}
+
+	var ah resp2.ArrayHeader
+	if err := ah.UnmarshalRESP(br); err != nil {
+		return err
+	} else if ah.N < 0 {
+		te.aborted = true
+		return nil
+	} else if ah.N != len(te.cmds) {
+		return errors.Errorf("EXEC returned %d results for %d commands", ah.N, len(te.cmds))
+	}
+
+	for _, cmd := range te.cmds {
+		err := cmd.UnmarshalRESP(br)
+		if errors.As(err, new(resp2.Error)) {
+			// an error from an individual command doesn't prevent the rest of
+			// the results from being read
+			if te.err == nil {
+				te.err = err
+			}
+		} else if err != nil {
+			return err
+		}
+	}
+	return nil
+}
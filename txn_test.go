@@ -0,0 +1,122 @@
+package radix
+
+import (
+	"strconv"
+	. "testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	errors "golang.org/x/xerrors"
+
+	"github.com/mediocregopher/radix/v3/resp/resp2"
+)
+
+func TestWithTxn(t *T) {
+	c, other := dial(), dial()
+	defer c.Close()
+	defer other.Close()
+
+	// incr reads the current value of key and queues setting it to one more
+	// than that. If modify is true then key is modified by another connection
+	// before the transaction is performed.
+	var calls int
+	incr := func(key string, modify func() bool, rcv *string) func(Conn, *Txn) error {
+		return func(conn Conn, txn *Txn) error {
+			calls++
+			var i int
+			if err := conn.Do(Cmd(&i, "GET", key)); err != nil {
+				return err
+			}
+			if modify() {
+				if err := other.Do(Cmd(nil, "INCR", key)); err != nil {
+					return err
+				}
+			}
+			txn.Queue(
+				Cmd(nil, "SET", key, strconv.Itoa(i+1)),
+				Cmd(rcv, "GET", key),
+			)
+			return nil
+		}
+	}
+
+	t.Run("Success", func(t *T) {
+		calls = 0
+		key := randStr()
+		var out string
+		require.Nil(t, c.Do(WithTxn([]string{key}, incr(key, func() bool { return false }, &out))))
+		assert.Equal(t, "1", out)
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("Retry", func(t *T) {
+		calls = 0
+		key := randStr()
+		var out string
+		modify := func() bool { return calls == 1 }
+		require.Nil(t, c.Do(WithTxn([]string{key}, incr(key, modify, &out))))
+		assert.Equal(t, "2", out)
+		assert.Equal(t, 2, calls)
+	})
+
+	t.Run("Aborted", func(t *T) {
+		calls = 0
+		key := randStr()
+		var out string
+		modify := func() bool { return true }
+		err := c.Do(WithTxn([]string{key}, incr(key, modify, &out), TxnMaxAttempts(3)))
+		assert.True(t, errors.Is(err, ErrTxnAborted))
+		assert.Equal(t, 3, calls)
+		assert.Empty(t, out)
+
+		var i int
+		require.Nil(t, c.Do(Cmd(&i, "GET", key)))
+		assert.Equal(t, 3, i)
+	})
+
+	t.Run("CallbackError", func(t *T) {
+		key := randStr()
+		cbErr := errors.New("callback error")
+		err := c.Do(WithTxn([]string{key}, func(Conn, *Txn) error {
+			return cbErr
+		}))
+		assert.Equal(t, cbErr, err)
+
+		// the key should no longer be watched, so modifying it shouldn't abort
+		// a later transaction on the same Conn
+		require.Nil(t, other.Do(Cmd(nil, "SET", key, "foo")))
+		err = c.Do(WithTxn(nil, func(_ Conn, txn *Txn) error {
+			txn.Queue(Cmd(nil, "SET", key, "bar"))
+			return nil
+		}, TxnMaxAttempts(1)))
+		assert.Nil(t, err)
+	})
+
+	t.Run("QueueError", func(t *T) {
+		key := randStr()
+		err := c.Do(WithTxn([]string{key}, func(_ Conn, txn *Txn) error {
+			txn.Queue(Cmd(nil, "SET", key, "foo"), Cmd(nil, "SET", key))
+			return nil
+		}))
+		assert.True(t, errors.As(err, new(resp2.Error)))
+
+		var mn MaybeNil
+		require.Nil(t, c.Do(Cmd(&mn, "GET", key)))
+		assert.True(t, mn.Nil)
+	})
+
+	t.Run("ExecError", func(t *T) {
+		key := randStr()
+		var out string
+		err := c.Do(WithTxn(nil, func(_ Conn, txn *Txn) error {
+			txn.Queue(
+				Cmd(nil, "SET", key, "foo"),
+				Cmd(nil, "LPUSH", key, "bar"),
+				Cmd(&out, "GET", key),
+			)
+			return nil
+		}))
+		assert.True(t, errors.Is(err, resp2.ErrWrongType))
+		assert.Equal(t, "foo", out)
+	})
+}
@@ -0,0 +1,68 @@
+package radix
+
+import (
+	"strconv"
+	"time"
+)
+
+// WaitAction is an Action which performs another Action and then waits for its
+// writes to be acknowledged by replicas, see DoWait.
+type WaitAction interface {
+	Action
+
+	// Acked returns the number of replicas which acknowledged the writes
+	// performed by the inner Action. It's only valid once the WaitAction has
+	// been performed without error.
+	Acked() int
+}
+
+type waitAction struct {
+	Action
+	numReplicas int
+	timeout     time.Duration
+	acked       int
+}
+
+// DoWait returns a WaitAction which performs the given Action and then, on the
+// same Conn, the WAIT command, which blocks until all preceding writes on that
+// Conn have been acknowledged by at least numReplicas replicas, or until the
+// timeout has elapsed. The number of replicas which acknowledged the writes is
+// returned by the Acked method of the WaitAction once it's been performed.
+//
+// A timeout of 0 blocks until enough replicas have acknowledged the writes. The
+// read timeout of the Conn (see DialReadTimeout) is suspended while waiting,
+// and the WaitAction counts against the limit set by PoolMaxBlocking.
+//
+// Note that WAIT doesn't make redis strongly consistent: writes which weren't
+// acknowledged by enough replicas are not rolled back, and may be lost in a
+// failover even if they were.
+//
+//	wa := radix.DoWait(radix.Cmd(nil, "SET", "foo", "bar"), 1, time.Second)
+//	if err := client.Do(wa); err != nil {
+//		// handle error
+//	} else if wa.Acked() < 1 {
+//		// the write may not have been replicated
+//	}
+//
+func DoWait(a Action, numReplicas int, timeout time.Duration) WaitAction {
+	return &waitAction{Action: a, numReplicas: numReplicas, timeout: timeout}
+}
+
+func (wa *waitAction) Run(c Conn) error {
+	if err := c.Do(wa.Action); err != nil {
+		return err
+	}
+	return c.Do(Cmd(&wa.acked, "WAIT",
+		strconv.Itoa(wa.numReplicas),
+		strconv.FormatInt(int64(wa.timeout/time.Millisecond), 10),
+	))
+}
+
+func (wa *waitAction) Acked() int {
+	return wa.acked
+}
+
+func (wa *waitAction) ClusterCanRetry() bool {
+	ccra, ok := wa.Action.(ClusterCanRetryAction)
+	return ok && ccra.ClusterCanRetry()
+}
@@ -0,0 +1,42 @@
+package radix
+
+import (
+	. "testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	errors "golang.org/x/xerrors"
+
+	"github.com/mediocregopher/radix/v3/resp/resp2"
+)
+
+func TestDoWait(t *T) {
+	var cmds [][]string
+	stub := Stub("tcp", "127.0.0.1:6379", func(args []string) interface{} {
+		cmds = append(cmds, args)
+		switch args[0] {
+		case "WAIT":
+			return 2
+		case "INCR":
+			return resp2.Error{E: errors.New("ERR value is not an integer")}
+		default:
+			return "OK"
+		}
+	})
+
+	wa := DoWait(Cmd(nil, "SET", "foo", "bar"), 3, 1500*time.Millisecond)
+	assert.Equal(t, []string{"foo"}, wa.Keys())
+	require.NoError(t, stub.Do(wa))
+	assert.Equal(t, 2, wa.Acked())
+	assert.Equal(t, [][]string{
+		{"SET", "foo", "bar"},
+		{"WAIT", "3", "1500"},
+	}, cmds)
+
+	// WAIT isn't performed if the Action fails
+	cmds = nil
+	wa = DoWait(Cmd(nil, "INCR", "foo"), 1, 0)
+	assert.Error(t, stub.Do(wa))
+	assert.Equal(t, [][]string{{"INCR", "foo"}}, cmds)
+}
@@ -0,0 +1,134 @@
+package radix
+
+import (
+	"bufio"
+
+	errors "golang.org/x/xerrors"
+
+	"github.com/mediocregopher/radix/v3/resp"
+	"github.com/mediocregopher/radix/v3/resp/resp2"
+)
+
+// ZMember describes a member of a sorted set along with its score.
+//
+// When given as an argument to FlatCmd a ZMember is flattened into its score
+// followed by its member, as expected by ZADD:
+//
+//	FlatCmd(nil, "ZADD", key, ZMember{Member: "foo", Score: 1.5})
+//
+// A slice of ZMembers, either as a []ZMember or a ZMembers, is flattened into
+// each ZMember in turn.
+type ZMember struct {
+	Member string
+	Score  float64
+}
+
+func (zm ZMember) flatArgs() []interface{} {
+	return []interface{}{zm.Score, zm.Member}
+}
+
+// UnmarshalRESP implements the resp.Unmarshaler interface. It unmarshals a
+// member/score pair, e.g. an element of a RESP3 WITHSCORES reply, or the reply
+// to ZPOPMIN when not given a count.
+func (zm *ZMember) UnmarshalRESP(br *bufio.Reader) error {
+	var arrHead resp2.ArrayHeader
+	if err := arrHead.UnmarshalRESP(br); err != nil {
+		return err
+	} else if arrHead.N != 2 {
+		if err := discardElems(br, arrHead.N); err != nil {
+			return err
+		}
+		return resp.ErrDiscarded{
+			Err: errors.Errorf("malformed sorted set member with %d elements", arrHead.N),
+		}
+	}
+	return zm.unmarshalPair(br)
+}
+
+func (zm *ZMember) unmarshalPair(br *bufio.Reader) error {
+	var m ZMember
+	if err := (resp2.Any{I: &m.Member}).UnmarshalRESP(br); err != nil {
+		return err
+	} else if err := (resp2.Any{I: &m.Score}).UnmarshalRESP(br); err != nil {
+		return err
+	}
+	*zm = m
+	return nil
+}
+
+// ZMembers is a list of sorted set members along with their scores, as
+// returned by commands such as ZRANGE or ZPOPMIN when given WITHSCORES or a
+// count.
+//
+// ZMembers can be unmarshaled from either the flat array of alternating members
+// and scores which redis returns over RESP2, or the array of member/score pairs
+// it returns over RESP3:
+//
+//	var members ZMembers
+//	err := client.Do(Cmd(&members, "ZRANGE", key, "0", "-1", "WITHSCORES"))
+//
+type ZMembers []ZMember
+
+// UnmarshalRESP implements the resp.Unmarshaler interface.
+func (zz *ZMembers) UnmarshalRESP(br *bufio.Reader) error {
+	var arrHead resp2.ArrayHeader
+	if err := arrHead.UnmarshalRESP(br); err != nil {
+		return err
+	} else if arrHead.N <= 0 {
+		*zz = (*zz)[:0]
+		return nil
+	}
+
+	b, err := br.Peek(1)
+	if err != nil {
+		return err
+	}
+	nested := b[0] == resp2.ArrayPrefix[0]
+
+	n := arrHead.N
+	if !nested {
+		if n%2 != 0 {
+			if err := discardElems(br, n); err != nil {
+				return err
+			}
+			return resp.ErrDiscarded{
+				Err: errors.Errorf("malformed sorted set members with %d elements", n),
+			}
+		}
+		n /= 2
+	}
+
+	members := (*zz)[:0]
+	for i := 0; i < n; i++ {
+		var m ZMember
+		if nested {
+			err = m.UnmarshalRESP(br)
+		} else {
+			err = m.unmarshalPair(br)
+		}
+		if nested && errors.As(err, new(resp.ErrDiscarded)) {
+			// the malformed member has been discarded, but the rest of the
+			// members must be too
+			if discardErr := discardElems(br, n-i-1); discardErr != nil {
+				return discardErr
+			}
+			return err
+		} else if err != nil {
+			return err
+		}
+		members = append(members, m)
+	}
+	*zz = members
+	return nil
+}
+
+// discardElems reads and discards the next n RESP messages from br, so that
+// the reader isn't left in a broken state after a malformed reply.
+func discardElems(br *bufio.Reader, n int) error {
+	for i := 0; i < n; i++ {
+		if err := (resp2.Any{}).UnmarshalRESP(br); err != nil {
+			return err
+		}
+	}
+	return nil
+}
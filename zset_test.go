@@ -0,0 +1,78 @@
+package radix
+
+import (
+	"bufio"
+	"bytes"
+	"math"
+	. "testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	errors "golang.org/x/xerrors"
+
+	"github.com/mediocregopher/radix/v3/resp"
+	"github.com/mediocregopher/radix/v3/resp/resp2"
+)
+
+func TestZMembers(t *T) {
+	c := dial()
+	defer c.Close()
+
+	key := randStr()
+	require.Nil(t, c.Do(FlatCmd(nil, "ZADD", key, ZMember{Member: "a", Score: 1.5})))
+	require.Nil(t, c.Do(FlatCmd(nil, "ZADD", key, []ZMember{
+		{Member: "b", Score: 2},
+		{Member: "c", Score: math.Inf(1)},
+	})))
+
+	var members ZMembers
+	require.Nil(t, c.Do(Cmd(&members, "ZRANGE", key, "0", "-1", "WITHSCORES")))
+	assert.Equal(t, ZMembers{
+		{Member: "a", Score: 1.5},
+		{Member: "b", Score: 2},
+		{Member: "c", Score: math.Inf(1)},
+	}, members)
+
+	var popped ZMember
+	require.Nil(t, c.Do(Cmd(&popped, "ZPOPMIN", key)))
+	assert.Equal(t, ZMember{Member: "a", Score: 1.5}, popped)
+
+	require.Nil(t, c.Do(Cmd(&members, "ZRANGE", randStr(), "0", "-1", "WITHSCORES")))
+	assert.Empty(t, members)
+
+	// RESP3 replies are arrays of pairs, with scores as doubles
+	br := bufio.NewReader(bytes.NewBufferString(
+		"*2\r\n*2\r\n$1\r\na\r\n,1.5\r\n*2\r\n$1\r\nb\r\n,inf\r\n",
+	))
+	require.Nil(t, members.UnmarshalRESP(br))
+	assert.Equal(t, ZMembers{
+		{Member: "a", Score: 1.5},
+		{Member: "b", Score: math.Inf(1)},
+	}, members)
+}
+
+func TestZMembersMalformed(t *T) {
+	// each malformed reply is followed by "+OK", which must still be readable
+	// once the malformed reply has been discarded
+	for _, str := range []string{
+		"*3\r\n$1\r\na\r\n$1\r\n1\r\n$1\r\nb\r\n",
+		"*2\r\n*1\r\n$1\r\na\r\n*2\r\n$1\r\nb\r\n,1\r\n",
+	} {
+		br := bufio.NewReader(bytes.NewBufferString(str + "+OK\r\n"))
+		var members ZMembers
+		err := members.UnmarshalRESP(br)
+		assert.True(t, errors.As(err, new(resp.ErrDiscarded)), "err:%v", err)
+
+		var ok string
+		require.Nil(t, (resp2.Any{I: &ok}).UnmarshalRESP(br))
+		assert.Equal(t, "OK", ok)
+	}
+
+	br := bufio.NewReader(bytes.NewBufferString("*3\r\n$1\r\na\r\n$1\r\n1\r\n$1\r\nb\r\n+OK\r\n"))
+	var member ZMember
+	err := member.UnmarshalRESP(br)
+	assert.True(t, errors.As(err, new(resp.ErrDiscarded)), "err:%v", err)
+	var ok string
+	require.Nil(t, (resp2.Any{I: &ok}).UnmarshalRESP(br))
+	assert.Equal(t, "OK", ok)
+}